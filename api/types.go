@@ -0,0 +1,80 @@
+// Package api holds the wire types of the HTTP API, shared by the server
+// and the clients generated from its OpenAPI document: the envelope of
+// response bodies, the page of paginated lists and the problem documents
+// of errors.
+package api
+
+import (
+	"encoding/xml"
+	"strconv"
+)
+
+// StatusOK is the status of the envelope of successful responses.
+const StatusOK = "ok"
+
+// HeaderRequestID is the header carrying the ID of a request, in both
+// directions.
+const HeaderRequestID = "X-Request-ID"
+
+// Envelope is the body of the API responses in JSON, XML and MessagePack:
+// a status, StatusOK on success, a message for people, the Data of type T
+// and, on paginated lists, the page in Meta.
+//
+// Error and RequestID complete the envelope in clients: they decode the
+// problem document of a failed request into Error and read RequestID from
+// HeaderRequestID, so that every call returns one shape. The server only
+// sends the request ID in problems, so that the bodies of successful
+// responses stay the same between requests, as ETags and the response
+// cache need.
+type Envelope[T any] struct {
+	XMLName   xml.Name `json:"-" xml:"response"`
+	Status    string   `json:"status" xml:"status"`
+	Message   string   `json:"message" xml:"message"`
+	Data      T        `json:"data,omitempty" xml:"data,omitempty"`
+	Meta      *Page    `json:"meta,omitempty" xml:"meta,omitempty"`
+	Error     *Problem `json:"error,omitempty" xml:"error,omitempty"`
+	RequestID string   `json:"request_id,omitempty" xml:"request_id,omitempty"`
+}
+
+// Page describes the page of a paginated list: Limit items at most, the
+// Page number of offset pagination, or NextCursor to pass as cursor for
+// the next page of cursor pagination while HasMore.
+type Page struct {
+	Limit      int    `json:"limit" xml:"limit"`
+	Page       int    `json:"page,omitempty" xml:"page,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty" xml:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more" xml:"has_more"`
+}
+
+// ProblemType is the media type of problem documents.
+const ProblemType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document, the body of every
+// error response, with the field violations of invalid requests and the
+// ID of the request as extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// Error returns the status, title and detail of p.
+func (p *Problem) Error() string {
+	s := strconv.Itoa(p.Status) + " " + p.Title
+	if p.Detail != "" {
+		s += ": " + p.Detail
+	}
+	return s
+}
+
+// FieldError describes one violated constraint of an invalid request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,3 @@
+package client
+
+//go:generate go run ../cmd/sdkgen -o client.go
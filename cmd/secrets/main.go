@@ -0,0 +1,142 @@
+// Command secrets writes the encrypted configuration values the server
+// decrypts at startup, as enc:<scheme>:<ciphertext>.
+//
+//	secrets encrypt -r age1... < plaintext
+//	secrets encrypt -kms-key alias/app < plaintext
+//	secrets rotate -i old.key -r age1new... config.yaml
+//
+// encrypt encrypts its standard input, without a trailing newline, to
+// every age recipient given or with a KMS key. rotate re-encrypts every
+// value of the files given, decrypting them with the -i identity files or
+// KMS, so that the old key can be retired once the server was given the
+// new identity. KMS is configured by SECRETS_KMS_REGION, _ENDPOINT,
+// _ACCESS_KEY_ID, _SECRET_ACCESS_KEY and _SESSION_TOKEN, as for the server.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"go-flylike-example/internal/secrets"
+)
+
+// value matches the encrypted values of a file.
+var value = regexp.MustCompile(`enc:[a-z0-9]+:[A-Za-z0-9+/]+=*`)
+
+// list implements flag.Value for repeated flags.
+type list []string
+
+func (l *list) String() string { return strings.Join(*l, ",") }
+
+func (l *list) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: secrets encrypt|rotate [flags] [files]")
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("secrets "+os.Args[1], flag.ExitOnError)
+	var recipients, identities list
+	fs.Var(&recipients, "r", "age recipient to encrypt to, repeatable")
+	fs.Var(&identities, "i", "age identity file to decrypt with, repeatable (rotate)")
+	kmsKey := fs.String("kms-key", "", "KMS key to encrypt with, an ID, ARN or alias")
+	fs.Parse(os.Args[2:])
+
+	kms := secrets.KMSOptions{
+		Region:          os.Getenv("SECRETS_KMS_REGION"),
+		Endpoint:        os.Getenv("SECRETS_KMS_ENDPOINT"),
+		AccessKeyID:     os.Getenv("SECRETS_KMS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("SECRETS_KMS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("SECRETS_KMS_SESSION_TOKEN"),
+	}
+	if (len(recipients) == 0) == (*kmsKey == "") {
+		fmt.Fprintln(os.Stderr, "secrets: give either age recipients or a KMS key")
+		os.Exit(2)
+	}
+	encrypt := func(ctx context.Context, plaintext []byte) (string, error) {
+		if *kmsKey != "" {
+			return secrets.NewKMS(kms, nil).Encrypt(ctx, *kmsKey, plaintext)
+		}
+		return secrets.EncryptAge(plaintext, recipients...)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "encrypt":
+		err = encryptStdin(encrypt)
+	case "rotate":
+		err = rotate(secrets.Options{AgeIdentities: identities, KMS: kms}, encrypt, fs.Args())
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "secrets:", err)
+		os.Exit(1)
+	}
+}
+
+func encryptStdin(encrypt func(context.Context, []byte) (string, error)) error {
+	plaintext, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	plaintext = []byte(strings.TrimSuffix(string(plaintext), "\n"))
+	v, err := encrypt(context.Background(), plaintext)
+	if err != nil {
+		return err
+	}
+	fmt.Println(v)
+	return nil
+}
+
+// rotate re-encrypts the values of files in place, keeping the rest of
+// each file as it is.
+func rotate(opts secrets.Options, encrypt func(context.Context, []byte) (string, error), files []string) error {
+	keys, err := secrets.NewKeyring(opts)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var failed error
+		n := 0
+		out := value.ReplaceAllStringFunc(string(b), func(v string) string {
+			if failed != nil {
+				return v
+			}
+			plaintext, err := keys.Decrypt(ctx, v)
+			if err == nil {
+				v, err = encrypt(ctx, []byte(plaintext))
+			}
+			if err != nil {
+				failed = err
+			}
+			n++
+			return v
+		})
+		if failed != nil {
+			return fmt.Errorf("%s: %w", file, failed)
+		}
+		if err := os.WriteFile(file, []byte(out), info.Mode().Perm()); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d values re-encrypted\n", file, n)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/region"
+	"go-flylike-example/internal/server"
+	"go-flylike-example/internal/store"
+)
+
+// command is a subcommand of the binary. Its flags are those of the
+// configuration, and its own.
+type command struct {
+	name    string // words, such as "config validate"
+	args    string
+	summary string
+	run     func(args []string)
+}
+
+// commands are the subcommands of the binary. Without one, or with flags
+// only, it serves.
+var commands = []command{
+	{name: "serve", summary: "run the server (the default)", run: serve},
+	{name: "migrate", summary: "apply the pending migrations and exit, as --migrate-only", run: func(args []string) {
+		serve(append([]string{"--migrate-only"}, args...))
+	}},
+	{name: "routes", summary: "print the route table", run: routes},
+	{name: "config validate", summary: "load and check the configuration", run: validateConfig},
+	{name: "jobs run", args: "<kind> [payload]", summary: "run a job once in the foreground, with a JSON payload", run: runJob},
+	{name: "healthcheck", summary: "probe the readiness of the local server; exit 0 when ready", run: healthcheck},
+}
+
+// lookup returns the command args start with and the arguments following
+// its name, or nil for an unknown command.
+func lookup(args []string) (*command, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return &commands[0], args
+	}
+	for i, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: server [command] [flags]")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run server <command> -h for the flags of a command.")
+}
+
+// parse parses the flags of a command, those of the configuration and its
+// own, registered on fs by the caller, and resolves the configuration. It
+// exits on errors.
+func parse(fs *flag.FlagSet, args []string) *config.Config {
+	resolve := config.Flags(fs)
+	fs.Parse(args)
+	cfg, err := resolve()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
+
+// tool builds the application of cfg for the commands that use it without
+// serving, logging to stderr. Its database is prepared as for serving,
+// and the files of the serving process, the key-value store and the
+// recovery journal, are left alone; caches kept in the key-value store
+// are kept in memory instead. The returned function releases it.
+func tool(cfg *config.Config) (*server.Server, func()) {
+	logger, err := logging.Setup(os.Stderr, cfg.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.KV.Path = ""
+	cfg.Journal.Dir = ""
+	if cfg.Cache.Backend == "kv" {
+		cfg.Cache.Backend = "memory"
+	}
+	if cfg.DataCache.Backend == "kv" {
+		cfg.DataCache.Backend = "memory"
+	}
+	// Standard output is the command's.
+	gin.DefaultWriter = os.Stderr
+	gin.DebugPrintRouteFunc = func(method, path, handler string, _ int) {
+		logger.Debug("route registered", "method", method, "path", path, "handler", handler)
+	}
+
+	db, err := store.Open(context.Background(), region.Database(cfg.Database))
+	if err != nil {
+		log.Fatalf("database connection failed: %v", err)
+	}
+	if err := prepare(db, cfg.Database); err != nil {
+		log.Fatalf("database migration failed: %v", err)
+	}
+	var rdb *redis.Client
+	if cfg.Redis.URL != "" {
+		opts, err := redis.ParseURL(cfg.Redis.URL)
+		if err != nil {
+			log.Fatalf("invalid redis url: %v", err)
+		}
+		rdb = redis.NewClient(opts)
+	}
+	application, err := server.New(config.NewLive(cfg, nil), db, rdb, logger, server.Deps{})
+	if err != nil {
+		log.Fatalf("server setup failed: %v", err)
+	}
+	return application, func() {
+		if rdb != nil {
+			rdb.Close()
+		}
+		db.Close()
+	}
+}
+
+func routes(args []string) {
+	cfg := parse(flag.NewFlagSet("server routes", flag.ExitOnError), args)
+	application, release := tool(cfg)
+	defer release()
+
+	list := application.Routes()
+	slices.SortFunc(list, func(a, b gin.RouteInfo) int {
+		return cmp.Or(cmp.Compare(a.Path, b.Path), cmp.Compare(a.Method, b.Method))
+	})
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tHANDLER")
+	for _, r := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Method, r.Path, r.Handler)
+	}
+	tw.Flush()
+}
+
+func validateConfig(args []string) {
+	cfg := parse(flag.NewFlagSet("server config validate", flag.ExitOnError), args)
+	file := cfg.File
+	if file == "" {
+		file = "none"
+	}
+	fmt.Printf("configuration is valid (file: %s, profile: %s)\n", file, cmp.Or(cfg.Profile, "none"))
+}
+
+func runJob(args []string) {
+	fs := flag.NewFlagSet("server jobs run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: server jobs run [flags] <kind> [payload]")
+		fs.PrintDefaults()
+	}
+	cfg := parse(fs, args)
+	if fs.NArg() == 0 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	kind, payload := fs.Arg(0), json.RawMessage("{}")
+	if fs.NArg() == 2 {
+		payload = json.RawMessage(fs.Arg(1))
+		if !json.Valid(payload) {
+			log.Fatalf("jobs run: payload is not JSON: %s", payload)
+		}
+	}
+	application, release := tool(cfg)
+	defer release()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	start := time.Now()
+	if err := application.Jobs().Run(ctx, kind, payload); err != nil {
+		slog.Error("job failed", "kind", kind, "error", err)
+		release()
+		os.Exit(1)
+	}
+	slog.Info("job done", "kind", kind, "duration", time.Since(start).String())
+}
+
+func healthcheck(args []string) {
+	fs := flag.NewFlagSet("server healthcheck", flag.ExitOnError)
+	live := fs.Bool("live", false, "probe liveness (/healthz) instead of readiness (/readyz)")
+	url := fs.String("url", "", "URL to probe instead of the probe on the listen address; the configuration is not loaded")
+	timeout := fs.Duration("timeout", 5*time.Second, "deadline of the probe")
+	resolve := config.Flags(fs)
+	fs.Parse(args)
+	if *url == "" {
+		cfg, err := resolve()
+		if err != nil {
+			log.Fatal(err)
+		}
+		*url = probeURL(cfg, *live)
+	}
+
+	client := &http.Client{
+		Timeout: *timeout,
+		// The certificate is that of the public name, not of localhost.
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	resp, err := client.Get(*url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "healthcheck:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		fmt.Fprintf(os.Stderr, "healthcheck: %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		os.Exit(1)
+	}
+	fmt.Println(resp.Status)
+}
+
+// probeURL returns the URL of the probe of the server of cfg on this host.
+func probeURL(cfg *config.Config, live bool) string {
+	host, port, _ := net.SplitHostPort(cfg.Addr)
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	scheme, path := "http", "/readyz"
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+	}
+	if live {
+		path = "/healthz"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port) + path
+}
@@ -0,0 +1,180 @@
+// Package admin serves operational endpoints (profiling, runtime
+// variables, GC control, restarts, introspection, feature flags, scheduled
+// tasks, maintenance mode, test mail, fault injection, usage counters,
+// service level objectives, log levels, IDs and, optionally, metrics) on a
+// separate listener that is never exposed through the public router.
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/chaos"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/featureflag"
+	"go-flylike-example/internal/ipfilter"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/mailer"
+	"go-flylike-example/internal/maintenance"
+	"go-flylike-example/internal/mtls"
+	"go-flylike-example/internal/quota"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/scheduler"
+	"go-flylike-example/internal/slo"
+	"go-flylike-example/internal/store"
+)
+
+// Restarter replaces the running process without dropping connections.
+type Restarter interface {
+	Upgrade() error
+}
+
+// Deps are what the admin endpoints inspect and control.
+type Deps struct {
+	Config      *config.Live
+	Restarter   Restarter
+	Flags       *featureflag.Service
+	Replica     func() store.ReplicaStatus
+	Scheduler   *scheduler.Scheduler
+	Maintenance *maintenance.Mode
+	Mailer      *mailer.Mailer
+	// Chaos, when set, has its rules managed under /admin/chaos.
+	Chaos *chaos.Injector
+	// Quota, when set, has its counters read under /admin/usage.
+	Quota *quota.Enforcer
+	// Metrics, when set, serves the Prometheus metrics at /metrics.
+	Metrics gin.HandlerFunc
+	// IPFilter, when set, admits only the addresses it allows, as seen
+	// through TrustedProxies.
+	IPFilter       *ipfilter.Filter
+	TrustedProxies []string
+	// Routes lists the routes of the public router.
+	Routes func() gin.RoutesInfo
+	// Reporter, when set, is sent the server errors of admin requests.
+	Reporter apperror.Reporter
+	// SLO, when set, has its objectives reported under /admin/slo.
+	SLO *slo.Tracker
+}
+
+// NewHandler returns the admin router. When cfg.Token is set every request
+// must present it as a bearer token, and with cfg.ClientCAFile an allowed
+// client certificate, or both when both are set.
+func NewHandler(cfg config.Admin, logger *slog.Logger, d Deps) http.Handler {
+	r := gin.New()
+	// Route templates are passed path-escaped in /admin/routes/:route/debug.
+	r.UseRawPath = true
+	// The list was validated with the rest of the configuration.
+	if err := ipfilter.TrustProxies(r, d.TrustedProxies); err != nil {
+		logger.Error("admin trusted proxies not set", "error", err)
+	}
+	r.Use(logging.RequestID(), logging.AccessLog(logger), apperror.Middleware(d.Reporter))
+	if d.IPFilter != nil {
+		r.Use(d.IPFilter.Middleware())
+	}
+	if cfg.ClientCAFile != "" {
+		r.Use(mtls.Require(cfg.ClientSANs))
+	}
+	if cfg.Token != "" {
+		r.Use(requireToken(cfg.Token))
+	}
+
+	if d.Metrics != nil {
+		r.GET("/metrics", d.Metrics)
+	}
+
+	debugGroup := r.Group("/debug")
+	debugGroup.GET("/pprof/", gin.WrapF(pprof.Index))
+	debugGroup.GET("/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	debugGroup.GET("/pprof/profile", gin.WrapF(pprof.Profile))
+	debugGroup.GET("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debugGroup.POST("/pprof/symbol", gin.WrapF(pprof.Symbol))
+	debugGroup.GET("/pprof/trace", gin.WrapF(pprof.Trace))
+	// Named profiles: heap, goroutine, allocs, block, mutex, threadcreate.
+	debugGroup.GET("/pprof/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+	debugGroup.GET("/vars", gin.WrapH(expvar.Handler()))
+	debugGroup.POST("/gc", gc)
+
+	adminGroup := r.Group("/admin")
+	adminGroup.GET("/build", build)
+	adminGroup.GET("/config", currentConfig(d.Config))
+	adminGroup.GET("/routes", routes(d.Routes))
+	adminGroup.GET("/runtime", runtimeStats)
+	adminGroup.GET("/errors", recentErrors)
+	adminGroup.GET("/replica", replicaStatus(d.Replica))
+	adminGroup.POST("/restart", restart(d.Restarter))
+	registerFlags(adminGroup.Group("/flags"), d.Flags)
+	registerScheduler(adminGroup.Group("/scheduler"), d.Scheduler)
+	registerMaintenance(adminGroup.Group("/maintenance"), d.Maintenance)
+	registerMail(adminGroup.Group("/mail"), d.Mailer, d.Config)
+	registerID(adminGroup.Group("/id"))
+	registerLogging(adminGroup, d.Config, d.Routes)
+	if d.Chaos != nil {
+		registerChaos(adminGroup.Group("/chaos"), d.Chaos)
+	}
+	if d.Quota != nil {
+		registerUsage(adminGroup.Group("/usage"), d.Quota)
+	}
+	if d.SLO != nil {
+		registerSLO(adminGroup.Group("/slo"), d.SLO)
+	}
+	return r
+}
+
+// restart hands over to a freshly started binary and answers once it is
+// serving; this process then drains in the background.
+func restart(restarter Restarter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("restart requested", "trigger", "admin")
+		if err := restarter.Upgrade(); err != nil {
+			var appErr *apperror.Error
+			if !errors.As(err, &appErr) {
+				err = &apperror.Error{Kind: apperror.KindUnavailable, Message: "restart failed", Err: err}
+			}
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("new process is serving, this one is draining", nil))
+	}
+}
+
+// gc forces a collection, returns freed memory to the OS and reports heap
+// usage before and after.
+func gc(c *gin.Context) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	debug.FreeOSMemory()
+	runtime.ReadMemStats(&after)
+
+	c.JSON(http.StatusOK, render.OK("garbage collection completed", gin.H{
+		"heap_alloc_before": before.HeapAlloc,
+		"heap_alloc_after":  after.HeapAlloc,
+		"heap_released":     after.HeapReleased,
+		"num_gc":            after.NumGC,
+	}))
+}
+
+func requireToken(token string) gin.HandlerFunc {
+	want := []byte(token)
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), want) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.Error(apperror.Unauthorized("admin token required"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/chaos"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type putChaosRequest struct {
+	Rules []chaos.Rule `json:"rules" binding:"dive"`
+}
+
+func registerChaos(g *gin.RouterGroup, in *chaos.Injector) {
+	g.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.OK("chaos rules listed", in.Rules()))
+	})
+
+	// PUT replaces the rules of this instance; other instances keep theirs.
+	g.PUT("", func(c *gin.Context) {
+		var req putChaosRequest
+		if !validation.BindJSON(c, &req) {
+			return
+		}
+		if err := in.SetRules(req.Rules); err != nil {
+			c.Error(apperror.BadRequest(err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("chaos rules saved", in.Rules()))
+	})
+
+	g.DELETE("", func(c *gin.Context) {
+		_ = in.SetRules(nil)
+		c.JSON(http.StatusOK, render.OK("chaos rules cleared", nil))
+	})
+}
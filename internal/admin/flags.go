@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/featureflag"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type putFlagRequest struct {
+	Enabled    bool `json:"enabled"`
+	Percentage *int `json:"percentage" binding:"omitempty,gte=0,lte=100"`
+}
+
+func registerFlags(g *gin.RouterGroup, flags *featureflag.Service) {
+	g.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.OK("flags listed", flags.Flags(c.Request.Context())))
+	})
+
+	// PUT overrides the configured flag until DELETE; without a percentage
+	// the flag applies to everyone.
+	g.PUT("/:name", func(c *gin.Context) {
+		var req putFlagRequest
+		if !validation.BindJSON(c, &req) {
+			return
+		}
+		pct := 100
+		if req.Percentage != nil {
+			pct = *req.Percentage
+		}
+		f, err := flags.Set(c.Request.Context(), featureflag.Flag{Name: c.Param("name"), Enabled: req.Enabled, Percentage: pct})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("flag saved", f))
+	})
+
+	g.DELETE("/:name", func(c *gin.Context) {
+		if err := flags.Delete(c.Request.Context(), c.Param("name")); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("flag override removed", nil))
+	})
+}
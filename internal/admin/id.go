@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type newIDsQuery struct {
+	Count int `form:"count" binding:"omitempty,min=1,max=1000"`
+}
+
+type idInfo struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Node uint16    `json:"node"`
+}
+
+func infoOf(v id.ID) idInfo {
+	return idInfo{ID: v.String(), Time: v.Time(), Node: v.Node()}
+}
+
+// registerID hands out IDs from this machine's generator, for tooling that
+// creates records outside the API, and decodes them.
+func registerID(g *gin.RouterGroup) {
+	// GET /new?count=n returns n new IDs, one by default.
+	g.GET("/new", func(c *gin.Context) {
+		var q newIDsQuery
+		if !validation.BindQuery(c, &q) {
+			return
+		}
+		list := make([]idInfo, max(q.Count, 1))
+		for i := range list {
+			list[i] = infoOf(id.Default.New())
+		}
+		c.JSON(http.StatusOK, render.OK("ids generated", list))
+	})
+
+	g.GET("/:id", func(c *gin.Context) {
+		v, err := id.Parse(c.Param("id"))
+		if err != nil {
+			c.Error(apperror.BadRequest("invalid id"))
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("id decoded", infoOf(v)))
+	})
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/buildinfo"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/store"
+)
+
+// started approximates the process start for uptime.
+var started = time.Now()
+
+func build(c *gin.Context) {
+	c.JSON(http.StatusOK, render.OK("build info", buildinfo.Get()))
+}
+
+// currentConfig shows the configuration in effect, after reloads, with
+// credentials redacted.
+func currentConfig(live *config.Live) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := live.Load()
+		doc, err := cfg.Redacted()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("current config", doc))
+	}
+}
+
+type route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+func routes(list func() gin.RoutesInfo) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out := []route{}
+		for _, ri := range list() {
+			out = append(out, route{Method: ri.Method, Path: ri.Path, Handler: ri.Handler})
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Path != out[j].Path {
+				return out[i].Path < out[j].Path
+			}
+			return out[i].Method < out[j].Method
+		})
+		c.JSON(http.StatusOK, render.OK("routes listed", out))
+	}
+}
+
+func runtimeStats(c *gin.Context) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	c.JSON(http.StatusOK, render.OK("runtime stats", gin.H{
+		"uptime_seconds": int64(time.Since(started).Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"num_cpu":        runtime.NumCPU(),
+		"heap_alloc":     ms.HeapAlloc,
+		"heap_sys":       ms.HeapSys,
+		"heap_objects":   ms.HeapObjects,
+		"num_gc":         ms.NumGC,
+		"pause_total_ns": ms.PauseTotalNs,
+	}))
+}
+
+// recentErrors returns the latest 5xx errors and panics, newest first.
+func recentErrors(c *gin.Context) {
+	c.JSON(http.StatusOK, render.OK("recent errors", apperror.Recent()))
+}
+
+// replicaStatus reports whether reads currently go to the read replica.
+func replicaStatus(status func() store.ReplicaStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.OK("read replica status", status()))
+	}
+}
@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type putLogLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+	// For is how long the level lasts, such as "30m"; LogDebugTTL when
+	// empty.
+	For string `json:"for"`
+}
+
+type putRouteDebugRequest struct {
+	For string `json:"for"`
+}
+
+// registerLogging serves the log level under /admin/log-level and the
+// route debug toggles under /admin/routes/:route/debug. Both apply to this
+// instance only and expire on their own.
+func registerLogging(g *gin.RouterGroup, live *config.Live, list func() gin.RoutesInfo) {
+	g.GET("/log-level", func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.OK("log levels", logging.Status()))
+	})
+
+	g.PUT("/log-level", func(c *gin.Context) {
+		var req putLogLevelRequest
+		if !validation.BindJSON(c, &req) {
+			return
+		}
+		d, ok := lasting(c, req.For, live)
+		if !ok {
+			return
+		}
+		if err := logging.Override(req.Level, d); err != nil {
+			c.Error(apperror.BadRequest(err.Error()))
+			return
+		}
+		logging.FromContext(c.Request.Context()).Warn("log level overridden", "level", req.Level, "for", d.String())
+		c.JSON(http.StatusOK, render.OK("log level overridden", logging.Status()))
+	})
+
+	g.DELETE("/log-level", func(c *gin.Context) {
+		logging.EndOverride()
+		c.JSON(http.StatusOK, render.OK("log level override ended", logging.Status()))
+	})
+
+	// The route is a template of the public router, path-escaped, such as
+	// %2Fapi%2Fv2%2Fusers%2F:id.
+	g.PUT("/routes/:route/debug", func(c *gin.Context) {
+		var req putRouteDebugRequest
+		if c.Request.ContentLength > 0 && !validation.BindJSON(c, &req) {
+			return
+		}
+		route := c.Param("route")
+		if !registered(list, route) {
+			c.Error(apperror.NotFound("route not found"))
+			return
+		}
+		d, ok := lasting(c, req.For, live)
+		if !ok {
+			return
+		}
+		logging.DebugRoute(route, d)
+		logging.FromContext(c.Request.Context()).Warn("route debug logging on", "route", route, "for", d.String())
+		c.JSON(http.StatusOK, render.OK("route debug logging on", logging.Status()))
+	})
+
+	g.DELETE("/routes/:route/debug", func(c *gin.Context) {
+		if !logging.EndDebugRoute(c.Param("route")) {
+			c.Error(apperror.NotFound("route not being debugged"))
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("route debug logging off", logging.Status()))
+	})
+}
+
+// lasting parses how long a toggle lasts, LogDebugTTL when s is empty.
+func lasting(c *gin.Context, s string, live *config.Live) (time.Duration, bool) {
+	if s == "" {
+		return live.Load().LogDebugTTL, true
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		c.Error(apperror.BadRequest("for must be a positive duration, such as 30m"))
+		return 0, false
+	}
+	return d, true
+}
+
+func registered(list func() gin.RoutesInfo, route string) bool {
+	for _, r := range list() {
+		if r.Path == route {
+			return true
+		}
+	}
+	return false
+}
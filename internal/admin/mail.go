@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/mailer"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type testMailRequest struct {
+	To string `json:"to" binding:"required,email"`
+}
+
+// registerMail lets operators check the mail provider settings by queueing
+// a test message.
+func registerMail(g *gin.RouterGroup, m *mailer.Mailer, live *config.Live) {
+	g.POST("/test", func(c *gin.Context) {
+		var req testMailRequest
+		if !validation.BindJSON(c, &req) {
+			return
+		}
+		err := m.SendTemplate(c.Request.Context(), req.To, "test", map[string]string{
+			"Provider": live.Load().Mail.Provider,
+			"SentAt":   time.Now().UTC().Format(time.RFC1123),
+		})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusAccepted, render.OK("test mail queued", nil))
+	})
+}
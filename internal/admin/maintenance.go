@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/maintenance"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type putMaintenanceRequest struct {
+	Message string `json:"message" binding:"max=200"`
+}
+
+func registerMaintenance(g *gin.RouterGroup, mode *maintenance.Mode) {
+	g.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.OK("maintenance status", mode.Status()))
+	})
+
+	// PUT switches maintenance on; the body, and its message, are optional.
+	g.PUT("", func(c *gin.Context) {
+		var req putMaintenanceRequest
+		if c.Request.ContentLength > 0 && !validation.BindJSON(c, &req) {
+			return
+		}
+		mode.Enable(req.Message)
+		c.JSON(http.StatusOK, render.OK("maintenance mode on", mode.Status()))
+	})
+
+	g.DELETE("", func(c *gin.Context) {
+		mode.Disable()
+		c.JSON(http.StatusOK, render.OK("maintenance mode off", mode.Status()))
+	})
+}
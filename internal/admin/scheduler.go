@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/scheduler"
+)
+
+func registerScheduler(g *gin.RouterGroup, sched *scheduler.Scheduler) {
+	g.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.OK("scheduled tasks listed", gin.H{
+			"leader": sched.Leader(),
+			"tasks":  sched.Tasks(),
+		}))
+	})
+
+	// Runs the task on this instance, even when another one leads.
+	g.POST("/:task/run", func(c *gin.Context) {
+		if err := sched.Trigger(c.Param("task")); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusAccepted, render.OK("task started", nil))
+	})
+}
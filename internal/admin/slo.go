@@ -0,0 +1,19 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/slo"
+)
+
+// registerSLO serves where the service level objectives of the routes
+// stand: the error budget left over the period, the burn rates and the
+// alerts firing.
+func registerSLO(g *gin.RouterGroup, t *slo.Tracker) {
+	g.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, render.OK("service level objectives listed", t.Report()))
+	})
+}
@@ -0,0 +1,24 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/quota"
+	"go-flylike-example/internal/render"
+)
+
+// registerUsage serves the usage counters of any scope, such as
+// /admin/usage/tenant:acme?period=2026-09, for the invoice run.
+func registerUsage(g *gin.RouterGroup, q *quota.Enforcer) {
+	g.GET("/:scope", func(c *gin.Context) {
+		u, err := q.Usage(c.Request.Context(), c.Param("scope"), c.DefaultQuery("period", quota.Period(time.Now())))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, render.OK("usage found", u))
+	})
+}
@@ -0,0 +1,151 @@
+// Package admission queues the requests to the expensive route groups, so
+// that the bulk traffic of one instance, exports, imports and result
+// streams, cannot starve its interactive requests. Requests are classed by
+// the priorities of load shedding; each class has its own slots, that its
+// requests take in arrival order, waiting in a bounded queue for at most the
+// wait of the class while all are taken. Critical requests are never
+// queued.
+package admission
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/shed"
+	"go-flylike-example/internal/validation"
+)
+
+// class holds the slots of one priority class.
+type class struct {
+	slots   chan struct{}
+	queue   int64
+	waiting atomic.Int64
+}
+
+func newClass(cfg config.AdmissionClass) *class {
+	return &class{slots: make(chan struct{}, cfg.Limit), queue: int64(cfg.Queue)}
+}
+
+// Queue admits requests by priority class.
+type Queue struct {
+	live    *config.Live
+	classes map[shed.Priority]*class
+
+	rejected *prometheus.CounterVec
+	waits    *prometheus.HistogramVec
+	queued   *prometheus.GaugeVec
+	running  *prometheus.GaugeVec
+}
+
+// New returns a Queue for the admission configuration of live, recording on
+// reg, or nil when admission is off.
+func New(live *config.Live, reg prometheus.Registerer) *Queue {
+	cfg := live.Load().Admission
+	if !cfg.Enabled {
+		return nil
+	}
+	q := &Queue{
+		live: live,
+		classes: map[shed.Priority]*class{
+			shed.Low:    newClass(cfg.Low),
+			shed.Normal: newClass(cfg.Normal),
+		},
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "admission",
+			Name:      "rejected_total",
+			Help:      "Requests refused by the admission queue, by priority and reason (full, timeout or canceled).",
+		}, []string{"priority", "reason"}),
+		waits: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "admission",
+			Name:      "wait_duration_seconds",
+			Help:      "Time admitted requests waited for a slot, by priority.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"priority"}),
+		queued: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "admission",
+			Name:      "queued",
+			Help:      "Requests waiting for a slot, by priority.",
+		}, []string{"priority"}),
+		running: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "admission",
+			Name:      "in_flight",
+			Help:      "Requests holding a slot, by priority.",
+		}, []string{"priority"}),
+	}
+	reg.MustRegister(q.rejected, q.waits, q.queued, q.running)
+	return q
+}
+
+// acquire takes a slot of cl within wait, returning the reason it could not
+// otherwise.
+func (q *Queue) acquire(ctx context.Context, prio shed.Priority, cl *class, wait time.Duration) (string, bool) {
+	select {
+	case cl.slots <- struct{}{}:
+		q.waits.WithLabelValues(prio.String()).Observe(0)
+		return "", true
+	default:
+	}
+	if cl.waiting.Add(1) > cl.queue {
+		cl.waiting.Add(-1)
+		return "full", false
+	}
+	queued := q.queued.WithLabelValues(prio.String())
+	queued.Inc()
+	defer func() {
+		cl.waiting.Add(-1)
+		queued.Dec()
+	}()
+	start := time.Now()
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case cl.slots <- struct{}{}:
+		q.waits.WithLabelValues(prio.String()).Observe(time.Since(start).Seconds())
+		return "", true
+	case <-t.C:
+		return "timeout", false
+	case <-ctx.Done():
+		return "canceled", false
+	}
+}
+
+// Middleware runs the requests of each class once they get a slot, and
+// answers those that do not get one in time with 503 and a Retry-After of
+// the wait of their class.
+func (q *Queue) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prio := shed.Classify(c.Request, q.live.Load().Shedding)
+		cl, ok := q.classes[prio]
+		if !ok {
+			c.Next()
+			return
+		}
+		wait := q.live.Load().Admission.Normal.Wait
+		if prio == shed.Low {
+			wait = q.live.Load().Admission.Low.Wait
+		}
+		if reason, ok := q.acquire(c.Request.Context(), prio, cl, wait); !ok {
+			q.rejected.WithLabelValues(prio.String(), reason).Inc()
+			c.Header("Retry-After", strconv.Itoa(max(1, int((wait+time.Second-1)/time.Second))))
+			// Like load shedding, an intended refusal that must not fill
+			// the error log.
+			validation.Abort(c, validation.NewProblem(http.StatusServiceUnavailable, i18n.T(c, "too many requests are waiting, try again later")))
+			return
+		}
+		running := q.running.WithLabelValues(prio.String())
+		running.Inc()
+		defer func() {
+			running.Dec()
+			<-cl.slots
+		}()
+		c.Next()
+	}
+}
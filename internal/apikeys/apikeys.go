@@ -0,0 +1,276 @@
+// Package apikeys manages long-lived API keys for machine clients. Keys
+// are shown once at creation and stored only as SHA-256 hashes; each one
+// carries its own scopes and, optionally, its own rate limit.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/cache"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/store"
+)
+
+// Prefix starts every key so it can be told apart from a JWT in an
+// Authorization header, and found by secret scanners.
+const Prefix = "fk_"
+
+// touchInterval limits how often last_used_at is written for a busy key.
+const touchInterval = time.Minute
+
+var (
+	// ErrNotFound is returned when no key of the owner matches.
+	ErrNotFound = apperror.NotFound("api key not found")
+	// ErrInvalidKey is returned for unknown or revoked keys.
+	ErrInvalidKey = apperror.Unauthorized("invalid or revoked api key")
+)
+
+// Key is an API key as listed to its owner. The secret itself is never
+// stored.
+type Key struct {
+	ID         string     `json:"id"`
+	Owner      string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Rate       float64    `json:"rate,omitempty"`
+	Burst      int        `json:"burst,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether k grants scope.
+func (k *Key) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// NewKey describes a key to create. A zero Rate leaves the key on the
+// server-wide rate limit; a zero Burst defaults to one second of Rate.
+type NewKey struct {
+	Name   string
+	Scopes []string
+	Rate   float64
+	Burst  int
+}
+
+// Repository reads and writes API keys.
+type Repository struct {
+	db    *store.Store
+	cache *cache.Cache
+}
+
+// NewRepository returns a Repository backed by db.
+func NewRepository(db *store.Store) *Repository {
+	return &Repository{db: db}
+}
+
+// CacheLookups makes Authenticate look keys up in c before the database.
+// Revoke evicts the key, so with a Redis-backed c a revocation takes effect
+// everywhere at once; with a memory one other instances accept the key
+// until their entry expires. It must be called before the repository is
+// used.
+func (r *Repository) CacheLookups(c *cache.Cache) {
+	r.cache = c
+}
+
+// cachedKey is a Key as cached, with the owner its JSON form leaves out.
+type cachedKey struct {
+	Key
+	Owner string `json:"owner"`
+}
+
+// Create stores a new key for owner and returns it together with the
+// plaintext secret, which cannot be recovered later.
+func (r *Repository) Create(ctx context.Context, owner string, nk NewKey) (*Key, string, error) {
+	for _, s := range nk.Scopes {
+		if s == "" || strings.ContainsFunc(s, isSpace) {
+			return nil, "", apperror.Newf(apperror.KindBadRequest, "invalid scope %q", s)
+		}
+	}
+	if nk.Rate > 0 && nk.Burst == 0 {
+		nk.Burst = int(math.Ceil(nk.Rate))
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("apikeys: generate key: %w", err)
+	}
+	secret := Prefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	k := &Key{
+		ID:        id.New(),
+		Owner:     owner,
+		Name:      nk.Name,
+		Prefix:    secret[:len(Prefix)+8],
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(nk.Scopes))),
+		Rate:      nk.Rate,
+		Burst:     nk.Burst,
+		CreatedAt: time.Now().UTC(),
+	}
+	if k.Scopes == nil {
+		k.Scopes = []string{}
+	}
+	_, err := r.db.Writer(ctx).ExecContext(ctx, r.db.Rebind(
+		`INSERT INTO api_keys (id, owner, name, prefix, key_hash, scopes, rate, burst, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		k.ID, k.Owner, k.Name, k.Prefix, hashKey(secret), strings.Join(k.Scopes, " "), k.Rate, k.Burst, k.CreatedAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("apikeys: create: %w", err)
+	}
+	return k, secret, nil
+}
+
+// List returns the keys of owner, revoked ones included, newest first.
+func (r *Repository) List(ctx context.Context, owner string) ([]Key, error) {
+	rows, err := r.db.Writer(ctx).QueryContext(ctx, r.db.Rebind(
+		`SELECT `+columns+` FROM api_keys WHERE owner = ? ORDER BY created_at DESC, id`), owner)
+	if err != nil {
+		return nil, fmt.Errorf("apikeys: list: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Key{}
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("apikeys: list: %w", err)
+		}
+		list = append(list, *k)
+	}
+	return list, rows.Err()
+}
+
+// Revoke disables the key id of owner immediately. Revoking a key twice
+// is not an error.
+func (r *Repository) Revoke(ctx context.Context, owner, id string) error {
+	res, err := r.db.Writer(ctx).ExecContext(ctx, r.db.Rebind(
+		`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE owner = ? AND id = ?`),
+		time.Now().UTC(), owner, id)
+	if err != nil {
+		return fmt.Errorf("apikeys: revoke: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	if r.cache == nil {
+		return nil
+	}
+	var hash string
+	err = r.db.Writer(ctx).QueryRowContext(ctx, r.db.Rebind(
+		`SELECT key_hash FROM api_keys WHERE id = ?`), id).Scan(&hash)
+	if err == nil {
+		err = r.cache.Delete(ctx, hash)
+	}
+	if err != nil {
+		return fmt.Errorf("apikeys: revoke: evict cached key: %w", err)
+	}
+	return nil
+}
+
+// Authenticate returns the active key matching secret.
+func (r *Repository) Authenticate(ctx context.Context, secret string) (*Key, error) {
+	if !strings.HasPrefix(secret, Prefix) {
+		return nil, ErrInvalidKey
+	}
+	hash := hashKey(secret)
+	k, err := r.lookup(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if k.RevokedAt != nil {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now().UTC()
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > touchInterval {
+		// Best effort: a failed write must not fail the request.
+		_, _ = r.db.Writer(ctx).ExecContext(ctx, r.db.Rebind(
+			`UPDATE api_keys SET last_used_at = ? WHERE id = ?`), now, k.ID)
+		k.LastUsedAt = &now
+		if r.cache != nil {
+			_ = r.cache.Set(ctx, hash, cachedKey{Key: *k, Owner: k.Owner})
+		}
+	}
+	return k, nil
+}
+
+// lookup returns the key stored under hash, revoked or not.
+func (r *Repository) lookup(ctx context.Context, hash string) (*Key, error) {
+	if r.cache == nil {
+		return r.byHash(ctx, hash)
+	}
+	e, err := cache.GetOrSet(ctx, r.cache, hash, func(ctx context.Context) (cachedKey, error) {
+		k, err := r.byHash(ctx, hash)
+		if err != nil {
+			return cachedKey{}, err
+		}
+		return cachedKey{Key: *k, Owner: k.Owner}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	k := e.Key
+	k.Owner = e.Owner
+	return &k, nil
+}
+
+func (r *Repository) byHash(ctx context.Context, hash string) (*Key, error) {
+	k, err := scanKey(r.db.Writer(ctx).QueryRowContext(ctx, r.db.Rebind(
+		`SELECT `+columns+` FROM api_keys WHERE key_hash = ?`), hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("apikeys: authenticate: %w", err)
+	}
+	return k, nil
+}
+
+const columns = `id, owner, name, prefix, scopes, rate, burst, created_at, last_used_at, revoked_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKey(s scanner) (*Key, error) {
+	var (
+		k                 Key
+		scopes            string
+		lastUsed, revoked sql.NullTime
+	)
+	err := s.Scan(&k.ID, &k.Owner, &k.Name, &k.Prefix, &scopes, &k.Rate, &k.Burst,
+		&k.CreatedAt, &lastUsed, &revoked)
+	if err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		k.RevokedAt = &revoked.Time
+	}
+	return &k, nil
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,22 @@
+package apikeys
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"api-keys"}
+	forbidden := []int{http.StatusForbidden}
+	return []openapi.Operation{
+		{ID: "listAPIKeys", Method: http.MethodGet, Path: "", Tags: tags, Summary: "List your API keys", Auth: true,
+			Response: openapi.Envelope([]Key{}), Errors: forbidden},
+		{ID: "createAPIKey", Method: http.MethodPost, Path: "", Tags: tags, Summary: "Create an API key", Auth: true,
+			Description: "The secret is only returned in this response.",
+			Request:     createRequest{}, Response: openapi.Envelope(created{}), Status: http.StatusCreated, Errors: forbidden},
+		{ID: "revokeAPIKey", Method: http.MethodDelete, Path: "/:id", Tags: tags, Summary: "Revoke an API key", Auth: true,
+			Response: openapi.Envelope(nil), Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	}
+}
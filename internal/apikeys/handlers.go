@@ -0,0 +1,85 @@
+package apikeys
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type createRequest struct {
+	Name   string   `json:"name" binding:"required,notblank,max=100"`
+	Scopes []string `json:"scopes" binding:"max=20,dive,required,max=64"`
+	Rate   float64  `json:"rate" binding:"gte=0"`
+	Burst  int      `json:"burst" binding:"gte=0"`
+}
+
+// created is the only response that ever includes the secret.
+type created struct {
+	*Key
+	Secret string `json:"key"`
+}
+
+// Register mounts the key management endpoints on g. They require a user
+// access token: keys cannot mint or revoke other keys.
+func (r *Repository) Register(g *gin.RouterGroup) {
+	g.Use(auth.Required(), userOnly())
+	g.GET("", r.handleList)
+	g.POST("", r.handleCreate)
+	g.DELETE("/:id", r.handleRevoke)
+}
+
+func userOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := FromContext(c); ok {
+			c.Error(apperror.Forbidden("api keys cannot manage api keys"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func owner(c *gin.Context) string {
+	claims, _ := auth.ClaimsFrom(c)
+	return claims.Subject
+}
+
+func (r *Repository) handleList(c *gin.Context) {
+	list, err := r.List(c.Request.Context(), owner(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("api keys listed", list))
+}
+
+func (r *Repository) handleCreate(c *gin.Context) {
+	var req createRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	k, secret, err := r.Create(c.Request.Context(), owner(c), NewKey{
+		Name:   req.Name,
+		Scopes: req.Scopes,
+		Rate:   req.Rate,
+		Burst:  req.Burst,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, render.OK("api key created", created{Key: k, Secret: secret}))
+}
+
+func (r *Repository) handleRevoke(c *gin.Context) {
+	if err := r.Revoke(c.Request.Context(), owner(c), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("api key revoked", nil))
+}
@@ -0,0 +1,282 @@
+// Package app runs the long-lived parts of the server, such as listeners,
+// workers and consumers, as one unit. Components are started in
+// dependency order, each waiting for the ones it depends on to be ready; a
+// failure of any of them, or the end of the run context, stops them all.
+// Shutdown runs in reverse: a component stops once everything depending on
+// it has stopped, independent components stop in parallel, and each one
+// gets at most its stop timeout.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Component is a part of the application with a lifetime. Start must not
+// block beyond getting the component going, and Stop must give up when ctx
+// is done.
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Readier is implemented by components that become usable some time after
+// Start returned. Components depending on one start once Ready returns.
+type Readier interface {
+	Ready(ctx context.Context) error
+}
+
+// Failer is implemented by components that can fail after they started,
+// like a server whose listener breaks. An error on the channel shuts the
+// application down.
+type Failer interface {
+	Failed() <-chan error
+}
+
+var (
+	// ErrStarting is reported by Check until every component started.
+	ErrStarting = errors.New("app: starting")
+	// ErrStopping is reported by Check once shutdown began.
+	ErrStopping = errors.New("app: stopping")
+)
+
+const (
+	stateStarting int32 = iota
+	stateRunning
+	stateStopping
+)
+
+// Option configures a component added to an App.
+type Option func(*component)
+
+// After makes the component start after, and stop before, the named ones.
+func After(names ...string) Option {
+	return func(c *component) { c.after = append(c.after, names...) }
+}
+
+// StopTimeout bounds how long the component may take to stop, within the
+// shutdown timeout of the App.
+func StopTimeout(d time.Duration) Option {
+	return func(c *component) { c.stopTimeout = d }
+}
+
+type component struct {
+	name        string
+	c           Component
+	after       []string
+	stopTimeout time.Duration
+
+	dependents []*component
+	started    bool
+	stopped    chan struct{}
+}
+
+// App is a registry of components. Components are added before Run, which
+// may only be called once.
+type App struct {
+	logger          *slog.Logger
+	shutdownTimeout time.Duration
+
+	components []*component
+	stopping   []func()
+	state      atomic.Int32
+}
+
+// New returns an App whose shutdown takes at most shutdownTimeout.
+func New(logger *slog.Logger, shutdownTimeout time.Duration) *App {
+	return &App{logger: logger, shutdownTimeout: shutdownTimeout}
+}
+
+// Add registers c under name.
+func (a *App) Add(name string, c Component, opts ...Option) {
+	comp := &component{name: name, c: c, stopped: make(chan struct{})}
+	for _, opt := range opts {
+		opt(comp)
+	}
+	a.components = append(a.components, comp)
+}
+
+// OnStopping registers fn to run when shutdown begins, before any
+// component stops, such as failing readiness so that traffic drains.
+func (a *App) OnStopping(fn func()) {
+	a.stopping = append(a.stopping, fn)
+}
+
+// Check is a readiness check that passes only while every component is
+// running.
+func (a *App) Check(context.Context) error {
+	switch a.state.Load() {
+	case stateStarting:
+		return ErrStarting
+	case stateStopping:
+		return ErrStopping
+	}
+	return nil
+}
+
+// Run starts the components and blocks until ctx is done or one of them
+// fails, then stops them. It returns the failure and the errors of stopping
+// joined.
+func (a *App) Run(ctx context.Context) error {
+	levels, err := a.levels()
+	if err != nil {
+		return err
+	}
+
+	failed := make(chan error, len(a.components))
+	for _, level := range levels {
+		errs := make([]error, len(level))
+		var wg sync.WaitGroup
+		for i, c := range level {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = a.start(ctx, c)
+			}()
+		}
+		wg.Wait()
+		for i, c := range level {
+			if errs[i] != nil {
+				continue
+			}
+			c.started = true
+			if f, ok := c.c.(Failer); ok {
+				go watch(c.name, f, failed)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return errors.Join(err, a.stop())
+		}
+	}
+	a.state.Store(stateRunning)
+	a.logger.Info("application started", "components", len(a.components))
+
+	var cause error
+	select {
+	case <-ctx.Done():
+	case cause = <-failed:
+		a.logger.Error("component failed, shutting down", "error", cause)
+	}
+	return errors.Join(cause, a.stop())
+}
+
+func (a *App) start(ctx context.Context, c *component) error {
+	start := time.Now()
+	if err := c.c.Start(ctx); err != nil {
+		return fmt.Errorf("start %s: %w", c.name, err)
+	}
+	if r, ok := c.c.(Readier); ok {
+		if err := r.Ready(ctx); err != nil {
+			// It started, so it is stopped with the rest.
+			c.started = true
+			return fmt.Errorf("%s not ready: %w", c.name, err)
+		}
+	}
+	a.logger.Debug("component started", "component", c.name, "duration", time.Since(start).String())
+	return nil
+}
+
+func watch(name string, f Failer, failed chan<- error) {
+	if err, ok := <-f.Failed(); ok && err != nil {
+		select {
+		case failed <- fmt.Errorf("%s: %w", name, err):
+		default:
+		}
+	}
+}
+
+// stop stops the started components, each after the components depending
+// on it, within the shutdown timeout.
+func (a *App) stop() error {
+	a.state.Store(stateStopping)
+	for _, fn := range a.stopping {
+		fn()
+	}
+	a.logger.Info("shutting down", "drain_timeout", a.shutdownTimeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	errs := make([]error, len(a.components))
+	var wg sync.WaitGroup
+	for i, c := range a.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(c.stopped)
+			for _, d := range c.dependents {
+				<-d.stopped
+			}
+			if c.started {
+				errs[i] = a.stopOne(ctx, c)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (a *App) stopOne(ctx context.Context, c *component) error {
+	if c.stopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.stopTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	if err := c.c.Stop(ctx); err != nil {
+		a.logger.Warn("component stop incomplete", "component", c.name, "error", err)
+		return fmt.Errorf("stop %s: %w", c.name, err)
+	}
+	a.logger.Debug("component stopped", "component", c.name, "duration", time.Since(start).String())
+	return nil
+}
+
+// levels groups the components into batches that can start in parallel,
+// each depending only on earlier ones, and links every component to its
+// dependents.
+func (a *App) levels() ([][]*component, error) {
+	byName := make(map[string]*component, len(a.components))
+	for _, c := range a.components {
+		if _, dup := byName[c.name]; dup {
+			return nil, fmt.Errorf("app: component %s added twice", c.name)
+		}
+		byName[c.name] = c
+	}
+	remaining := make(map[*component]int, len(a.components))
+	for _, c := range a.components {
+		remaining[c] = len(c.after)
+		for _, name := range c.after {
+			dep, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("app: %s depends on unknown component %s", c.name, name)
+			}
+			dep.dependents = append(dep.dependents, c)
+		}
+	}
+
+	var levels [][]*component
+	for len(remaining) > 0 {
+		var level []*component
+		for _, c := range a.components {
+			if n, ok := remaining[c]; ok && n == 0 {
+				level = append(level, c)
+			}
+		}
+		if len(level) == 0 {
+			return nil, errors.New("app: components depend on each other in a cycle")
+		}
+		for _, c := range level {
+			delete(remaining, c)
+			for _, d := range c.dependents {
+				remaining[d]--
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Hook is a Component made of functions; either may be nil.
+type Hook struct {
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Start calls OnStart.
+func (h Hook) Start(ctx context.Context) error {
+	if h.OnStart == nil {
+		return nil
+	}
+	return h.OnStart(ctx)
+}
+
+// Stop calls OnStop.
+func (h Hook) Stop(ctx context.Context) error {
+	if h.OnStop == nil {
+		return nil
+	}
+	return h.OnStop(ctx)
+}
+
+// server runs serve, which blocks until shutdown is called.
+type server struct {
+	serve    func() error
+	shutdown func(ctx context.Context) error
+	failed   chan error
+}
+
+// Server adapts a server such as an http.Server: serve blocks until
+// shutdown makes it return. An error serve returns otherwise, except
+// http.ErrServerClosed, fails the application.
+func Server(serve func() error, shutdown func(ctx context.Context) error) Component {
+	return &server{serve: serve, shutdown: shutdown, failed: make(chan error, 1)}
+}
+
+func (s *server) Start(context.Context) error {
+	go func() {
+		if err := s.serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.failed <- err
+		}
+	}()
+	return nil
+}
+
+func (s *server) Stop(ctx context.Context) error {
+	return s.shutdown(ctx)
+}
+
+func (s *server) Failed() <-chan error {
+	return s.failed
+}
+
+// background runs a function until the component stops.
+type background struct {
+	run    func(ctx context.Context)
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Background runs run in its own goroutine from Start until Stop cancels
+// its context, and waits for it to return on Stop. It suits watchers that
+// loop until their context is done.
+func Background(run func(ctx context.Context)) Component {
+	return &background{run: run, done: make(chan struct{})}
+}
+
+func (b *background) Start(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	go func() {
+		defer close(b.done)
+		b.run(ctx)
+	}()
+	return nil
+}
+
+func (b *background) Stop(ctx context.Context) error {
+	b.cancel()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
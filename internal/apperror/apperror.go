@@ -0,0 +1,178 @@
+// Package apperror defines the application's error taxonomy. Handlers
+// report failures with c.Error(err) and the Middleware turns them into
+// consistent problem+json responses.
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// Kind classifies an error and determines its HTTP status.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindGone
+	KindTooManyRequests
+	KindUnavailable
+	KindBadGateway
+	KindGatewayTimeout
+	KindRequestTimeout
+	KindPayloadTooLarge
+	KindUnprocessable
+	KindUnsupportedMediaType
+	KindPaymentRequired
+	KindPreconditionFailed
+	KindPreconditionRequired
+)
+
+var statuses = map[Kind]int{
+	KindInternal:             http.StatusInternalServerError,
+	KindBadRequest:           http.StatusBadRequest,
+	KindUnauthorized:         http.StatusUnauthorized,
+	KindForbidden:            http.StatusForbidden,
+	KindNotFound:             http.StatusNotFound,
+	KindConflict:             http.StatusConflict,
+	KindGone:                 http.StatusGone,
+	KindTooManyRequests:      http.StatusTooManyRequests,
+	KindUnavailable:          http.StatusServiceUnavailable,
+	KindBadGateway:           http.StatusBadGateway,
+	KindGatewayTimeout:       http.StatusGatewayTimeout,
+	KindRequestTimeout:       http.StatusRequestTimeout,
+	KindPayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	KindUnprocessable:        http.StatusUnprocessableEntity,
+	KindUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	KindPaymentRequired:      http.StatusPaymentRequired,
+	KindPreconditionFailed:   http.StatusPreconditionFailed,
+	KindPreconditionRequired: http.StatusPreconditionRequired,
+}
+
+// Status returns the HTTP status code for k.
+func (k Kind) Status() int {
+	if s, ok := statuses[k]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an error with a kind and a client-safe message. The wrapped
+// cause is logged but never sent to clients.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+	stack   []uintptr
+	// panicked marks internal errors made from a recovered panic.
+	panicked bool
+	// format and args are what Newf formatted Message from.
+	format string
+	args   []any
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Status returns the HTTP status code for the error.
+func (e *Error) Status() int { return e.Kind.Status() }
+
+// Stack returns the formatted call stack captured when an internal error
+// was created, or "" for other kinds.
+func (e *Error) Stack() string {
+	if len(e.stack) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// StackTrace returns the program counters behind Stack, in the form error
+// trackers read them.
+func (e *Error) StackTrace() []uintptr { return e.stack }
+
+// New returns an error of the given kind.
+func New(kind Kind, message string) *Error {
+	e := &Error{Kind: kind, Message: message}
+	if kind == KindInternal {
+		e.stack = callers()
+	}
+	return e
+}
+
+// Newf returns an error of the given kind with a message formatted from
+// format and args. Responses translate format, keeping the values apart.
+func Newf(kind Kind, format string, args ...any) *Error {
+	e := &Error{Kind: kind, Message: fmt.Sprintf(format, args...), format: format, args: args}
+	if kind == KindInternal {
+		e.stack = callers()
+	}
+	return e
+}
+
+func BadRequest(message string) *Error           { return New(KindBadRequest, message) }
+func Unauthorized(message string) *Error         { return New(KindUnauthorized, message) }
+func Forbidden(message string) *Error            { return New(KindForbidden, message) }
+func NotFound(message string) *Error             { return New(KindNotFound, message) }
+func Conflict(message string) *Error             { return New(KindConflict, message) }
+func Gone(message string) *Error                 { return New(KindGone, message) }
+func TooManyRequests(message string) *Error      { return New(KindTooManyRequests, message) }
+func Unavailable(message string) *Error          { return New(KindUnavailable, message) }
+func BadGateway(message string) *Error           { return New(KindBadGateway, message) }
+func GatewayTimeout(message string) *Error       { return New(KindGatewayTimeout, message) }
+func RequestTimeout(message string) *Error       { return New(KindRequestTimeout, message) }
+func PayloadTooLarge(message string) *Error      { return New(KindPayloadTooLarge, message) }
+func Unprocessable(message string) *Error        { return New(KindUnprocessable, message) }
+func UnsupportedMediaType(message string) *Error { return New(KindUnsupportedMediaType, message) }
+func PaymentRequired(message string) *Error      { return New(KindPaymentRequired, message) }
+func PreconditionFailed(message string) *Error   { return New(KindPreconditionFailed, message) }
+func PreconditionRequired(message string) *Error { return New(KindPreconditionRequired, message) }
+
+// Internal wraps an unexpected error and records the call stack.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Message: "internal server error", Err: err, stack: callers()}
+}
+
+// From classifies any error: *Error values are returned as is, an expired
+// deadline becomes a gateway timeout, a cancelled context means the server
+// gave up on the request and anything else becomes an internal error.
+func From(err error) *Error {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Kind: KindGatewayTimeout, Message: "request deadline exceeded", Err: err}
+	case errors.Is(err, context.Canceled):
+		return &Error{Kind: KindUnavailable, Message: "request cancelled", Err: err}
+	}
+	return &Error{Kind: KindInternal, Message: "internal server error", Err: err}
+}
+
+func callers() []uintptr {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
@@ -0,0 +1,97 @@
+package apperror
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var grpcCodes = map[Kind]codes.Code{
+	KindInternal:             codes.Internal,
+	KindBadRequest:           codes.InvalidArgument,
+	KindUnauthorized:         codes.Unauthenticated,
+	KindForbidden:            codes.PermissionDenied,
+	KindNotFound:             codes.NotFound,
+	KindConflict:             codes.AlreadyExists,
+	KindGone:                 codes.NotFound,
+	KindTooManyRequests:      codes.ResourceExhausted,
+	KindUnavailable:          codes.Unavailable,
+	KindBadGateway:           codes.Unavailable,
+	KindGatewayTimeout:       codes.DeadlineExceeded,
+	KindRequestTimeout:       codes.DeadlineExceeded,
+	KindPayloadTooLarge:      codes.ResourceExhausted,
+	KindUnprocessable:        codes.FailedPrecondition,
+	KindUnsupportedMediaType: codes.InvalidArgument,
+	KindPaymentRequired:      codes.ResourceExhausted,
+	KindPreconditionFailed:   codes.Aborted,
+	KindPreconditionRequired: codes.FailedPrecondition,
+}
+
+// Code returns the gRPC status code for k.
+func (k Kind) Code() codes.Code {
+	if c, ok := grpcCodes[k]; ok {
+		return c
+	}
+	return codes.Internal
+}
+
+// UnaryServerInterceptor is the gRPC counterpart of Middleware: it recovers
+// panics and converts returned errors into status errors carrying only the
+// client-safe message.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, r)
+			}
+		}()
+		resp, err = handler(ctx, req)
+		return resp, toStatus(ctx, err)
+	}
+}
+
+// StreamServerInterceptor is the streaming variant of UnaryServerInterceptor.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), r)
+			}
+		}()
+		return toStatus(ss.Context(), handler(srv, ss))
+	}
+}
+
+func recovered(ctx context.Context, r any) error {
+	slog.ErrorContext(ctx, "panic recovered",
+		"panic", fmt.Sprint(r),
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// toStatus leaves status errors untouched and maps everything else through
+// the error taxonomy, logging server-side failures like Render does.
+func toStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	e := From(err)
+	code := e.Kind.Code()
+	if code == codes.Internal || code == codes.Unavailable {
+		attrs := []any{"error", err.Error(), "code", code.String()}
+		if stack := e.Stack(); stack != "" {
+			attrs = append(attrs, "stack", stack)
+		}
+		slog.ErrorContext(ctx, "rpc failed", attrs...)
+	}
+	return status.Error(code, e.Message)
+}
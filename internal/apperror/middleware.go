@@ -0,0 +1,132 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/validation"
+)
+
+// reporterKey is the gin.Context key under which Middleware leaves its
+// Reporter for Render and Recover.
+const reporterKey = "apperror.reporter"
+
+// Middleware recovers panics and renders the last error attached with
+// c.Error as a problem+json response, unless the handler already wrote a
+// body. 5xx errors are logged with their stack trace, kept as samples for
+// Recent and passed to reporter, which may be nil.
+func Middleware(reporter Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if reporter != nil {
+			c.Set(reporterKey, reporter)
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					// The client went away; let net/http close the connection.
+					panic(r)
+				}
+				panicked(c, r)
+				if !c.Writer.Written() {
+					validation.Abort(c, validation.NewProblem(http.StatusInternalServerError, i18n.T(c, "internal server error")))
+				} else {
+					c.Abort()
+				}
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		Render(c, c.Errors.Last().Err)
+	}
+}
+
+// Recover turns a panic in the handlers after it into an internal error on
+// c.Errors, so that the middleware between it and Middleware, such as the
+// audit log and idempotency, finishes as for any other failure instead of
+// being unwound. It belongs last in a route's middleware; Middleware still
+// catches panics everywhere else.
+func Recover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+				if c.Writer.Written() {
+					// Too late for an error response; report it here.
+					panicked(c, r)
+					c.Abort()
+					return
+				}
+				e := Internal(fmt.Errorf("panic: %v", r))
+				e.panicked = true
+				_ = c.Error(e)
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
+
+// panicked logs, records and reports a recovered panic.
+func panicked(c *gin.Context, r any) {
+	e := Internal(fmt.Errorf("panic: %v", r))
+	e.panicked = true
+	stack := e.Stack()
+	slog.ErrorContext(c.Request.Context(), "panic recovered",
+		"panic", fmt.Sprint(r),
+		"stack", stack,
+	)
+	record(c, http.StatusInternalServerError, e.Err.Error(), stack)
+	report(c, Report{Err: e, Status: http.StatusInternalServerError, Panic: true})
+}
+
+// StatusClientClosed is recorded, after nginx, for requests whose client
+// disconnected before the response was written.
+const StatusClientClosed = 499
+
+// Render writes err as a problem response and aborts the chain. When the
+// client has already gone away there is nobody to answer, so the request is
+// only recorded with StatusClientClosed.
+func Render(c *gin.Context, err error) {
+	if errors.Is(c.Request.Context().Err(), context.Canceled) {
+		slog.InfoContext(c.Request.Context(), "client closed request", "error", err.Error())
+		c.AbortWithStatus(StatusClientClosed)
+		return
+	}
+	e := From(err)
+	status := e.Status()
+	if status >= http.StatusInternalServerError {
+		msg := "request failed"
+		if e.panicked {
+			msg = "panic recovered"
+		}
+		attrs := []any{"error", err.Error(), "status", status}
+		if stack := e.Stack(); stack != "" {
+			attrs = append(attrs, "stack", stack)
+		}
+		slog.ErrorContext(c.Request.Context(), msg, attrs...)
+		record(c, status, err.Error(), e.Stack())
+		report(c, Report{Err: err, Status: status, Panic: e.panicked})
+	}
+	validation.Abort(c, validation.NewProblem(status, e.Localize(c.Request.Context())))
+}
+
+// Localize returns the message of e in the locale of the request ctx
+// belongs to.
+func (e *Error) Localize(ctx context.Context) string {
+	if e.format != "" {
+		return i18n.Translate(ctx, e.format, e.args...)
+	}
+	return i18n.Translate(ctx, e.Message)
+}
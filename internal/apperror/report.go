@@ -0,0 +1,24 @@
+package apperror
+
+import "github.com/gin-gonic/gin"
+
+// Reporter forwards server errors to an error tracker such as Sentry. It
+// is called on the request goroutine for every 5xx response and recovered
+// panic, after they were logged, and must not hold the request up.
+type Reporter interface {
+	Report(c *gin.Context, r Report)
+}
+
+// Report is a server error as the middleware saw it. Err is as attached
+// by the handler; errors.As finds the *Error, whose StackTrace locates it.
+type Report struct {
+	Err    error
+	Status int
+	Panic  bool
+}
+
+func report(c *gin.Context, r Report) {
+	if v, ok := c.Get(reporterKey); ok {
+		v.(Reporter).Report(c, r)
+	}
+}
@@ -0,0 +1,59 @@
+package apperror
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/logging"
+)
+
+// maxSamples is how many recent server errors are kept for inspection.
+const maxSamples = 50
+
+// Sample is a server error recorded by the middleware.
+type Sample struct {
+	Time      time.Time `json:"time"`
+	Status    int       `json:"status"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	RequestID string    `json:"request_id,omitempty"`
+	Error     string    `json:"error"`
+	Stack     string    `json:"stack,omitempty"`
+}
+
+var samples struct {
+	mu   sync.Mutex
+	ring [maxSamples]Sample
+	next int
+	n    int
+}
+
+func record(c *gin.Context, status int, msg, stack string) {
+	s := Sample{
+		Time:      time.Now().UTC(),
+		Status:    status,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		RequestID: logging.RequestIDFrom(c.Request.Context()),
+		Error:     msg,
+		Stack:     stack,
+	}
+	samples.mu.Lock()
+	defer samples.mu.Unlock()
+	samples.ring[samples.next] = s
+	samples.next = (samples.next + 1) % maxSamples
+	samples.n = min(samples.n+1, maxSamples)
+}
+
+// Recent returns the latest 5xx errors and recovered panics, newest first.
+func Recent() []Sample {
+	samples.mu.Lock()
+	defer samples.mu.Unlock()
+	out := make([]Sample, 0, samples.n)
+	for i := 1; i <= samples.n; i++ {
+		out = append(out, samples.ring[(samples.next-i+maxSamples)%maxSamples])
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+// Package audit keeps the audit log: every POST, PUT, PATCH and DELETE
+// through the API is appended to the audit_log table with who made it,
+// what it targeted, when, how it ended and the state before and after,
+// with sensitive fields redacted. Rows are never updated or deleted, and
+// admins read them through the API.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+)
+
+// PermRead guards the audit log API.
+const PermRead = "audit:read"
+
+// ErrNotFound is returned when no entry matches.
+var ErrNotFound = apperror.NotFound("audit entry not found")
+
+// Entry is one audited request. Request, Before and After are redacted
+// JSON: the request body, the resource as the handler found it and as the
+// response returned it.
+type Entry struct {
+	ID        string          `json:"id"`
+	Actor     string          `json:"actor"`
+	Method    string          `json:"method"`
+	Route     string          `json:"route"`
+	Path      string          `json:"path"`
+	Status    int             `json:"status"`
+	RequestID string          `json:"request_id"`
+	ClientIP  string          `json:"client_ip"`
+	Request   json.RawMessage `json:"request,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// columns are the audit_log columns scan reads, in order.
+const columns = "id, actor, method, route, path, status, request_id, client_ip, request, before_state, after_state, created_at"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(row scanner, e *Entry) error {
+	var request, before, after sql.NullString
+	if err := row.Scan(&e.ID, &e.Actor, &e.Method, &e.Route, &e.Path, &e.Status, &e.RequestID, &e.ClientIP,
+		&request, &before, &after, &e.CreatedAt); err != nil {
+		return err
+	}
+	e.Request, e.Before, e.After = raw(request), raw(before), raw(after)
+	return nil
+}
+
+func raw(s sql.NullString) json.RawMessage {
+	if !s.Valid {
+		return nil
+	}
+	return json.RawMessage(s.String)
+}
+
+func nullable(m json.RawMessage) any {
+	if m == nil {
+		return nil
+	}
+	return string(m)
+}
+
+// Log appends to and reads the audit log, scoped to the tenant carried by
+// the context.
+type Log struct {
+	db *store.Store
+}
+
+// NewLog returns a Log backed by db.
+func NewLog(db *store.Store) *Log {
+	return &Log{db: db}
+}
+
+// Append records e, filling in its id and time.
+func (l *Log) Append(ctx context.Context, e *Entry) error {
+	e.ID = id.New()
+	e.CreatedAt = time.Now().UTC()
+	if _, err := l.db.Writer(ctx).ExecContext(ctx, l.db.Rebind(
+		`INSERT INTO audit_log (id, tenant_id, actor, method, route, path, status, request_id, client_ip, request, before_state, after_state, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		e.ID, tenant.ID(ctx), e.Actor, e.Method, e.Route, e.Path, e.Status, e.RequestID, e.ClientIP,
+		nullable(e.Request), nullable(e.Before), nullable(e.After), e.CreatedAt); err != nil {
+		return fmt.Errorf("audit: append: %w", err)
+	}
+	return nil
+}
+
+// ListOptions are the sort and filter fields of the audit log.
+var ListOptions = &query.Options{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Sort: true, Ops: []query.Op{query.Eq}},
+		"actor":      {Column: "actor", Ops: []query.Op{query.Eq, query.In}},
+		"method":     {Column: "method", Ops: []query.Op{query.Eq, query.In}},
+		"route":      {Column: "route", Ops: []query.Op{query.Eq, query.Contains}},
+		"path":       {Column: "path", Ops: []query.Op{query.Eq, query.Contains}},
+		"status":     {Column: "status", Type: query.Int, Sort: true, Ops: []query.Op{query.Eq, query.Gte, query.Lt}},
+		"request_id": {Column: "request_id", Ops: []query.Op{query.Eq}},
+		"created_at": {Column: "created_at", Type: query.Time, Sort: true, Ops: []query.Op{query.Gt, query.Gte, query.Lt, query.Lte}},
+	},
+	Key:          "id",
+	Sort:         "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// List returns the page of entries spec selects.
+func (l *Log) List(ctx context.Context, spec *query.Spec) ([]Entry, query.Page, error) {
+	list, err := l.list(ctx, spec)
+	if err != nil {
+		return nil, query.Page{}, err
+	}
+	list, page := query.Paginate(spec, list, entryField)
+	return list, page, nil
+}
+
+// streamBatch is how many entries Stream reads per query.
+const streamBatch = 500
+
+// Stream yields every entry spec selects from its cursor on, reading them
+// in batches rather than all at once.
+func (l *Log) Stream(ctx context.Context, spec *query.Spec) iter.Seq2[Entry, error] {
+	return query.All(spec, streamBatch, func(s *query.Spec) ([]Entry, error) {
+		return l.list(ctx, s)
+	}, entryField)
+}
+
+// Revision returns a value that changes whenever entries are appended or
+// removed, for exports to tell whether the log is still as they read it.
+// Entries never change otherwise.
+func (l *Log) Revision(ctx context.Context) (string, error) {
+	var (
+		n           int64
+		first, last sql.NullString
+	)
+	if err := l.db.Reader(ctx).QueryRowContext(ctx, l.db.Rebind(
+		`SELECT COUNT(*), MIN(id), MAX(id) FROM audit_log WHERE tenant_id = ?`), tenant.ID(ctx)).Scan(&n, &first, &last); err != nil {
+		return "", fmt.Errorf("audit: revision: %w", err)
+	}
+	return fmt.Sprintf("%d-%s-%s", n, first.String, last.String), nil
+}
+
+func (l *Log) list(ctx context.Context, spec *query.Spec) ([]Entry, error) {
+	q, args := spec.Build(`SELECT `+columns+` FROM audit_log WHERE tenant_id = ?`, tenant.ID(ctx))
+	rows, err := l.db.Reader(ctx).QueryContext(ctx, l.db.Rebind(q), args...)
+	if err != nil {
+		return nil, fmt.Errorf("audit: list: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Entry{}
+	for rows.Next() {
+		var e Entry
+		if err := scan(rows, &e); err != nil {
+			return nil, fmt.Errorf("audit: list: %w", err)
+		}
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("audit: list: %w", err)
+	}
+	return list, nil
+}
+
+func entryField(e Entry, field string) any {
+	switch field {
+	case "status":
+		return e.Status
+	case "created_at":
+		return e.CreatedAt
+	}
+	return e.ID
+}
+
+// Get returns the entry with the given id.
+func (l *Log) Get(ctx context.Context, id string) (*Entry, error) {
+	var e Entry
+	err := scan(l.db.Reader(ctx).QueryRowContext(ctx, l.db.Rebind(
+		`SELECT `+columns+` FROM audit_log WHERE tenant_id = ? AND id = ?`), tenant.ID(ctx), id), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: get: %w", err)
+	}
+	return &e, nil
+}
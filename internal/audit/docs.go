@@ -0,0 +1,22 @@
+package audit
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+	"go-flylike-example/internal/query"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"audit"}
+	desc := "Requires the " + PermRead + " permission."
+	return []openapi.Operation{
+		{ID: "listAuditEntries", Method: http.MethodGet, Path: "", Tags: tags, Summary: "List audit log entries", Auth: true,
+			Description: desc + " Every POST, PUT, PATCH and DELETE through the API, newest first by default.",
+			Query:       ListOptions.Params(), Response: openapi.Paginated([]Entry{}, query.Page{}),
+			Errors: []int{http.StatusForbidden, http.StatusUnprocessableEntity}},
+		{ID: "getAuditEntry", Method: http.MethodGet, Path: "/:id", Tags: tags, Summary: "Get an audit log entry", Description: desc, Auth: true,
+			Response: openapi.Envelope(Entry{}), Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	}
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/render"
+)
+
+// Register mounts the read-only audit log API on g for subjects holding
+// PermRead:
+//
+//	GET /      list entries, newest first
+//	GET /:id   one entry
+func (l *Log) Register(g *gin.RouterGroup) {
+	g.Use(rbac.Require(PermRead))
+	g.GET("", l.handleList)
+	g.GET("/:id", l.handleGet)
+}
+
+func (l *Log) handleList(c *gin.Context) {
+	spec, ok := query.Bind(c, ListOptions)
+	if !ok {
+		return
+	}
+	list, page, err := l.List(c.Request.Context(), spec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	body := render.OK("audit entries listed", list)
+	body.Meta = &page
+	c.JSON(http.StatusOK, body)
+}
+
+func (l *Log) handleGet(c *gin.Context) {
+	e, err := l.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("audit entry found", e))
+}
@@ -0,0 +1,213 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/redact"
+)
+
+// truncatedBody stands in for bodies beyond the configured size.
+var truncatedBody = json.RawMessage(`{"truncated":true}`)
+
+type recordingKey struct{}
+
+// recording collects what handlers report about the request being audited.
+type recording struct {
+	mu                  sync.Mutex
+	before, after       any
+	hasBefore, hasAfter bool
+}
+
+// Recording reports whether the request of ctx is being audited, so that
+// handlers only load a before state when it will be recorded.
+func Recording(ctx context.Context) bool {
+	_, ok := ctx.Value(recordingKey{}).(*recording)
+	return ok
+}
+
+// Before records v as the state of the resource before the request changed
+// it. Only the first call counts, so the earliest state wins when several
+// layers report one.
+func Before(ctx context.Context, v any) {
+	if r, ok := ctx.Value(recordingKey{}).(*recording); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if !r.hasBefore {
+			r.before, r.hasBefore = v, true
+		}
+	}
+}
+
+// After records v as the state after the request, in place of the data the
+// response carries.
+func After(ctx context.Context, v any) {
+	if r, ok := ctx.Value(recordingKey{}).(*recording); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.after, r.hasAfter = v, true
+	}
+}
+
+// Middleware appends an entry to l for every POST, PUT, PATCH and DELETE,
+// whatever its outcome. It must run after the tenant middleware; requests
+// it never reaches, such as rate-limited ones or idempotent replays placed
+// before it, changed nothing and are not recorded. Reloads of the audit
+// configuration apply to the next request.
+func Middleware(l *Log, live *config.Live) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := live.Load().Audit
+		if !cfg.Enabled || !mutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		var request []byte
+		truncated := false
+		if isJSON(c.Request.Header.Get("Content-Type")) && c.Request.Body != nil {
+			request, truncated = peek(c.Request, cfg.MaxBodyBytes)
+		}
+
+		rec := &recording{}
+		ctx := context.WithValue(c.Request.Context(), recordingKey{}, rec)
+		c.Request = c.Request.WithContext(ctx)
+		w := &recorder{ResponseWriter: c.Writer, max: cfg.MaxBodyBytes}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		// Errors are rendered further out, by apperror.Middleware.
+		status := w.Status()
+		if !w.Written() && len(c.Errors) > 0 {
+			status = apperror.From(c.Errors.Last().Err).Status()
+		}
+		e := &Entry{
+			Method:    c.Request.Method,
+			Route:     c.FullPath(),
+			Path:      c.Request.URL.Path,
+			Status:    status,
+			RequestID: logging.RequestIDFrom(ctx),
+			ClientIP:  c.ClientIP(),
+		}
+		if claims, ok := auth.ClaimsFrom(c); ok {
+			e.Actor = claims.Subject
+		}
+		if truncated {
+			e.Request = truncatedBody
+		} else {
+			e.Request = redact.JSON(request, cfg.Redact)
+		}
+
+		rec.mu.Lock()
+		if rec.hasBefore {
+			e.Before = redact.Value(rec.before, cfg.Redact)
+		}
+		switch {
+		case rec.hasAfter:
+			e.After = redact.Value(rec.after, cfg.Redact)
+		case w.overflow:
+			e.After = truncatedBody
+		case status < http.StatusBadRequest && isJSON(w.Header().Get("Content-Type")):
+			e.After = redact.JSON(data(w.buf.Bytes()), cfg.Redact)
+		}
+		rec.mu.Unlock()
+
+		// The request happened whether or not the client is still waiting.
+		if err := l.Append(context.WithoutCancel(ctx), e); err != nil {
+			logging.FromContext(ctx).Error("audit entry not recorded", "method", e.Method, "path", e.Path, "error", err)
+		}
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// peek reads up to max bytes of the request body and puts them back in
+// front of the rest. It reports whether the body was longer.
+func peek(r *http.Request, max int) ([]byte, bool) {
+	head, err := io.ReadAll(io.LimitReader(r.Body, int64(max)+1))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil {
+		// The handler runs into the same error and reports it.
+		return nil, false
+	}
+	if len(head) > max {
+		return nil, true
+	}
+	return head, false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// data returns the data member of a v2 envelope, nil for an envelope
+// without one, or the whole body of other responses.
+func data(body []byte) []byte {
+	var envelope map[string]json.RawMessage
+	if json.Unmarshal(body, &envelope) != nil {
+		return body
+	}
+	if d, ok := envelope["data"]; ok {
+		return d
+	}
+	if _, ok := envelope["status"]; ok {
+		if _, ok := envelope["message"]; ok {
+			return nil
+		}
+	}
+	return body
+}
+
+// recorder keeps a copy of the response body up to max bytes.
+type recorder struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.capture(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.capture([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *recorder) capture(b []byte) {
+	if r.overflow {
+		return
+	}
+	if r.buf.Len()+len(b) > r.max {
+		r.overflow = true
+		r.buf = bytes.Buffer{}
+		return
+	}
+	r.buf.Write(b)
+}
@@ -0,0 +1,205 @@
+// Package auth issues and verifies JWT access tokens backed by rotating,
+// server-side refresh tokens, and provides the Gin middleware that lets
+// route groups opt into authentication with Required.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/store"
+)
+
+var (
+	// ErrInvalidCredentials is returned by Credentials for a bad login.
+	ErrInvalidCredentials = apperror.Unauthorized("invalid username or password")
+	// ErrInvalidToken is returned for malformed, expired or revoked tokens.
+	ErrInvalidToken = apperror.Unauthorized("invalid or expired token")
+)
+
+// Credentials verifies a username and password and returns the subject
+// that tokens are issued for.
+type Credentials interface {
+	Verify(ctx context.Context, username, password string) (subject string, err error)
+}
+
+// Claims are the JWT claims carried by access tokens.
+type Claims struct {
+	jwt.RegisteredClaims
+}
+
+// TokenPair is returned by Login and Refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Service issues, verifies and revokes tokens.
+type Service struct {
+	creds     Credentials
+	refresh   *refreshStore
+	issuer    string
+	accessTTL time.Duration
+
+	method     jwt.SigningMethod
+	signingKey any
+	hmacSecret []byte
+	publicKey  *rsa.PublicKey
+}
+
+// New builds a Service from cfg. Refresh tokens are persisted in db.
+func New(cfg config.Auth, db *store.Store, creds Credentials) (*Service, error) {
+	s := &Service{
+		creds:     creds,
+		refresh:   &refreshStore{db: db, ttl: cfg.RefreshTTL},
+		issuer:    cfg.Issuer,
+		accessTTL: cfg.AccessTTL,
+	}
+	if err := s.loadKeys(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Service) loadKeys(cfg config.Auth) error {
+	if cfg.JWTSecret != "" {
+		s.hmacSecret = []byte(cfg.JWTSecret)
+	}
+	if cfg.PublicKeyFile != "" {
+		pub, err := readPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return err
+		}
+		s.publicKey = pub
+	}
+	if cfg.PrivateKeyFile != "" {
+		priv, err := readPrivateKey(cfg.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		s.method, s.signingKey = jwt.SigningMethodRS256, priv
+		if s.publicKey == nil {
+			s.publicKey = &priv.PublicKey
+		}
+		return nil
+	}
+
+	if s.hmacSecret == nil {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("auth: generate secret: %w", err)
+		}
+		s.hmacSecret = secret
+		slog.Warn("JWT_SECRET not set; using an ephemeral secret, tokens will not survive restarts or work across instances")
+	}
+	s.method, s.signingKey = jwt.SigningMethodHS256, s.hmacSecret
+	return nil
+}
+
+// Login verifies the credentials and issues a new token pair.
+func (s *Service) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	if s.creds == nil {
+		return nil, ErrInvalidCredentials
+	}
+	subject, err := s.creds.Verify(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.refresh.create(ctx, subject, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.pair(subject, refresh)
+}
+
+// Refresh rotates a refresh token: the presented token is revoked and a new
+// pair is issued. Presenting an already rotated token revokes the whole
+// token family, since it indicates the token was stolen.
+func (s *Service) Refresh(ctx context.Context, token string) (*TokenPair, error) {
+	subject, refresh, err := s.refresh.rotate(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.pair(subject, refresh)
+}
+
+// Logout revokes the refresh token family the token belongs to. Access
+// tokens already issued stay valid until they expire.
+func (s *Service) Logout(ctx context.Context, token string) error {
+	return s.refresh.revokeFamily(ctx, token)
+}
+
+// RevokeSubject revokes every refresh token issued for subject, logging it
+// out everywhere once its access tokens expire.
+func (s *Service) RevokeSubject(ctx context.Context, subject string) error {
+	return s.refresh.revokeSubject(ctx, subject)
+}
+
+// Verify parses and validates an access token.
+func (s *Service) Verify(token string) (*Claims, error) {
+	var methods []string
+	if s.hmacSecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if s.publicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			return s.hmacSecret, nil
+		case *jwt.SigningMethodRSA:
+			return s.publicKey, nil
+		}
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	},
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (s *Service) pair(subject, refresh string) (*TokenPair, error) {
+	now := time.Now()
+	claims := Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    s.issuer,
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		ID:        randomID(),
+	}}
+	access, err := jwt.NewWithClaims(s.method, claims).SignedString(s.signingKey)
+	if err != nil {
+		return nil, fmt.Errorf("auth: sign token: %w", err)
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+	}, nil
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,306 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/store"
+)
+
+const secret = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// newService returns a Service logging in "alice" with "password", with
+// its refresh tokens in an in-memory database of its own.
+func newService(t *testing.T, configure ...func(*config.Auth)) *Service {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.URL = "file:auth-" + rand.Text() + "?mode=memory&cache=shared"
+	cfg.Auth.JWTSecret = secret
+	for _, f := range configure {
+		f(&cfg.Auth)
+	}
+	db, err := store.Open(t.Context(), cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg.Auth, db, StaticCredentials{Username: "alice", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func login(t *testing.T, s *Service) *TokenPair {
+	t.Helper()
+	pair, err := s.Login(t.Context(), "alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+// sign signs claims as s would, with method and key instead of its own.
+func sign(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, Claims{RegisteredClaims: claims}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// claimsOf returns claims valid for s for the next minute.
+func claimsOf(s *Service) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    s.issuer,
+		Subject:   "alice",
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}
+}
+
+// tamper replaces the subject in the payload of token, keeping its
+// signature.
+func tamper(t *testing.T, token string) string {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload = []byte(strings.Replace(string(payload), `"sub":"alice"`, `"sub":"admin"`, 1))
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return strings.Join(parts, ".")
+}
+
+func TestLogin(t *testing.T) {
+	s := newService(t)
+	tests := []struct {
+		name, username, password string
+		ok                       bool
+	}{
+		{"valid", "alice", "password", true},
+		{"wrong password", "alice", "passw0rd", false},
+		{"unknown user", "bob", "password", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := s.Login(t.Context(), tt.username, tt.password)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("Login = %v, want ErrInvalidCredentials", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := s.Verify(pair.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "alice" {
+				t.Errorf("subject = %q, want alice", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := newService(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := claimsOf(s)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Second))
+	early := claimsOf(s)
+	early.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	noExpiry := claimsOf(s)
+	noExpiry.ExpiresAt = nil
+	otherIssuer := claimsOf(s)
+	otherIssuer.Issuer = "someone-else"
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"issued", login(t, s).AccessToken, true},
+		{"signed with the secret", sign(t, jwt.SigningMethodHS256, []byte(secret), claimsOf(s)), true},
+		{"expired", sign(t, jwt.SigningMethodHS256, []byte(secret), expired), false},
+		{"not yet valid", sign(t, jwt.SigningMethodHS256, []byte(secret), early), false},
+		{"without expiry", sign(t, jwt.SigningMethodHS256, []byte(secret), noExpiry), false},
+		{"other issuer", sign(t, jwt.SigningMethodHS256, []byte(secret), otherIssuer), false},
+		{"tampered payload", tamper(t, login(t, s).AccessToken), false},
+		{"other secret", sign(t, jwt.SigningMethodHS256, []byte(secret+"!"), claimsOf(s)), false},
+		{"other hmac method", sign(t, jwt.SigningMethodHS512, []byte(secret), claimsOf(s)), false},
+		{"rsa without a public key", sign(t, jwt.SigningMethodRS256, rsaKey, claimsOf(s)), false},
+		{"unsigned", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claimsOf(s)), false},
+		{"garbage", "not.a.token", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Verify(tt.token)
+			if tt.ok && err != nil {
+				t.Fatalf("Verify = %v, want success", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRSA(t *testing.T) {
+	s := newService(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.method, s.signingKey, s.publicKey = jwt.SigningMethodRS256, key, &key.PublicKey
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"issued", login(t, s).AccessToken, true},
+		{"other key", sign(t, jwt.SigningMethodRS256, other, claimsOf(s)), false},
+		{"tampered payload", tamper(t, login(t, s).AccessToken), false},
+		{"public key as hmac secret", sign(t, jwt.SigningMethodHS256, public, claimsOf(s)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Verify(tt.token)
+			if tt.ok && err != nil {
+				t.Fatalf("Verify = %v, want success", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		// steps are run against a fresh login; each returns the error of
+		// the last refresh it made.
+		steps func(t *testing.T, s *Service, pair *TokenPair) error
+		ok    bool
+	}{
+		{"rotates", func(t *testing.T, s *Service, pair *TokenPair) error {
+			next, err := s.Refresh(t.Context(), pair.RefreshToken)
+			if err != nil {
+				return err
+			}
+			if next.RefreshToken == pair.RefreshToken {
+				t.Error("refresh token was not rotated")
+			}
+			_, err = s.Refresh(t.Context(), next.RefreshToken)
+			return err
+		}, true},
+		{"reused token", func(t *testing.T, s *Service, pair *TokenPair) error {
+			if _, err := s.Refresh(t.Context(), pair.RefreshToken); err != nil {
+				t.Fatal(err)
+			}
+			_, err := s.Refresh(t.Context(), pair.RefreshToken)
+			return err
+		}, false},
+		{"successor of a reused token", func(t *testing.T, s *Service, pair *TokenPair) error {
+			next, err := s.Refresh(t.Context(), pair.RefreshToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Refresh(t.Context(), pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("reuse = %v, want ErrInvalidToken", err)
+			}
+			// The reuse revoked the family, the thief's token included.
+			_, err = s.Refresh(t.Context(), next.RefreshToken)
+			return err
+		}, false},
+		{"other families survive reuse", func(t *testing.T, s *Service, pair *TokenPair) error {
+			other := login(t, s)
+			if _, err := s.Refresh(t.Context(), pair.RefreshToken); err != nil {
+				t.Fatal(err)
+			}
+			s.Refresh(t.Context(), pair.RefreshToken)
+			_, err := s.Refresh(t.Context(), other.RefreshToken)
+			return err
+		}, true},
+		{"after logout", func(t *testing.T, s *Service, pair *TokenPair) error {
+			next, err := s.Refresh(t.Context(), pair.RefreshToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Logout(t.Context(), pair.RefreshToken); err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.Refresh(t.Context(), next.RefreshToken)
+			return err
+		}, false},
+		{"after revoking the subject", func(t *testing.T, s *Service, pair *TokenPair) error {
+			if err := s.RevokeSubject(t.Context(), "alice"); err != nil {
+				t.Fatal(err)
+			}
+			_, err := s.Refresh(t.Context(), pair.RefreshToken)
+			return err
+		}, false},
+		{"unknown token", func(t *testing.T, s *Service, pair *TokenPair) error {
+			_, err := s.Refresh(t.Context(), pair.RefreshToken+"x")
+			return err
+		}, false},
+		{"access token", func(t *testing.T, s *Service, pair *TokenPair) error {
+			_, err := s.Refresh(t.Context(), pair.AccessToken)
+			return err
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newService(t)
+			err := tt.steps(t, s, login(t, s))
+			if tt.ok && err != nil {
+				t.Fatalf("Refresh = %v, want success", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Refresh = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestRefreshExpired(t *testing.T) {
+	s := newService(t, func(cfg *config.Auth) { cfg.RefreshTTL = -time.Second })
+	pair := login(t, s)
+	if _, err := s.Refresh(t.Context(), pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Refresh = %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+)
+
+// CredentialsChain tries each Credentials in turn. A login fails with the
+// first error other than ErrInvalidCredentials, or with
+// ErrInvalidCredentials when none accepts it.
+type CredentialsChain []Credentials
+
+// Verify implements Credentials.
+func (cc CredentialsChain) Verify(ctx context.Context, username, password string) (string, error) {
+	for _, c := range cc {
+		subject, err := c.Verify(ctx, username, password)
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return subject, err
+		}
+	}
+	return "", ErrInvalidCredentials
+}
+
+// StaticCredentials accepts a single username/password pair. It exists so
+// the example can log in before a real user store is wired up.
+type StaticCredentials struct {
+	Username string
+	Password string
+}
+
+// Verify implements Credentials using constant-time comparisons.
+func (s StaticCredentials) Verify(_ context.Context, username, password string) (string, error) {
+	if s.Username == "" || s.Password == "" {
+		return "", ErrInvalidCredentials
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(s.Username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.Password)) == 1
+	if !userOK || !passOK {
+		return "", ErrInvalidCredentials
+	}
+	return s.Username, nil
+}
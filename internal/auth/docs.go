@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"auth"}
+	return []openapi.Operation{
+		{ID: "login", Method: http.MethodPost, Path: "/login", Tags: tags, Summary: "Exchange credentials for a token pair",
+			Request: loginRequest{}, Response: openapi.Envelope(TokenPair{}), Errors: []int{http.StatusUnauthorized}},
+		{ID: "refresh", Method: http.MethodPost, Path: "/refresh", Tags: tags, Summary: "Rotate a refresh token",
+			Request: refreshRequest{}, Response: openapi.Envelope(TokenPair{}), Errors: []int{http.StatusUnauthorized}},
+		{ID: "logout", Method: http.MethodPost, Path: "/logout", Tags: tags, Summary: "Revoke a refresh token",
+			Request: refreshRequest{}, Response: openapi.Envelope(nil)},
+		{ID: "me", Method: http.MethodGet, Path: "/me", Tags: tags, Summary: "Describe the authenticated caller", Auth: true,
+			Response: openapi.Envelope(struct {
+				Subject   string     `json:"subject"`
+				ExpiresAt *time.Time `json:"expires_at,omitempty"`
+			}{})},
+	}
+}
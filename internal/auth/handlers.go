@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Register mounts /login, /refresh, /logout and the authenticated /me on g.
+func (s *Service) Register(g *gin.RouterGroup) {
+	g.POST("/login", s.handleLogin)
+	g.POST("/refresh", s.handleRefresh)
+	g.POST("/logout", s.handleLogout)
+	g.GET("/me", Required(), s.handleMe)
+}
+
+func (s *Service) handleMe(c *gin.Context) {
+	claims, _ := ClaimsFrom(c)
+	data := gin.H{"subject": claims.Subject}
+	// Credentials other than access tokens, such as API keys, do not expire.
+	if claims.ExpiresAt != nil {
+		data["expires_at"] = claims.ExpiresAt.Time
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "authenticated"), data))
+}
+
+func (s *Service) handleLogin(c *gin.Context) {
+	var req loginRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	pair, err := s.Login(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "logged in"), pair))
+}
+
+func (s *Service) handleRefresh(c *gin.Context) {
+	var req refreshRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	pair, err := s.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "token refreshed"), pair))
+}
+
+func (s *Service) handleLogout(c *gin.Context) {
+	var req refreshRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if err := s.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "logged out"), nil))
+}
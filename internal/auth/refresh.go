@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-flylike-example/internal/store"
+)
+
+// refreshStore persists refresh tokens as SHA-256 hashes. Tokens issued by
+// rotating one another share a family so reuse of a rotated token can
+// revoke every descendant.
+type refreshStore struct {
+	db  *store.Store
+	ttl time.Duration
+}
+
+func (r *refreshStore) create(ctx context.Context, subject, family string) (string, error) {
+	return r.insert(ctx, r.db.Writer(ctx), subject, family)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (r *refreshStore) insert(ctx context.Context, db execer, subject, family string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("auth: generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	if family == "" {
+		family = randomID()
+	}
+
+	now := time.Now()
+	_, err := db.ExecContext(ctx, r.db.Rebind(
+		`INSERT INTO refresh_tokens (token_hash, subject, family, created_at, expires_at) VALUES (?, ?, ?, ?, ?)`),
+		hashToken(token), subject, family, now.Unix(), now.Add(r.ttl).Unix())
+	if err != nil {
+		return "", fmt.Errorf("auth: store refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// rotate runs in a transaction of its own rather than that of the request,
+// which is rolled back when a reused token makes it fail.
+func (r *refreshStore) rotate(ctx context.Context, token string) (subject, next string, err error) {
+	tx, err := r.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	var (
+		family    string
+		expiresAt int64
+		revokedAt sql.NullInt64
+	)
+	err = tx.QueryRowContext(ctx, r.db.Rebind(
+		`SELECT subject, family, expires_at, revoked_at FROM refresh_tokens WHERE token_hash = ?`),
+		hashToken(token)).Scan(&subject, &family, &expiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("auth: load refresh token: %w", err)
+	}
+
+	now := time.Now().Unix()
+	if revokedAt.Valid {
+		// A rotated token came back: assume it leaked and kill the family.
+		if err := r.revokeFamilyTx(ctx, tx, family, now); err != nil {
+			return "", "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrInvalidToken
+	}
+	if expiresAt <= now {
+		return "", "", ErrInvalidToken
+	}
+
+	res, err := tx.ExecContext(ctx, r.db.Rebind(
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE token_hash = ? AND revoked_at IS NULL`),
+		now, hashToken(token))
+	if err != nil {
+		return "", "", fmt.Errorf("auth: revoke refresh token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return "", "", ErrInvalidToken
+	}
+
+	if next, err = r.insert(ctx, tx, subject, family); err != nil {
+		return "", "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", "", err
+	}
+	return subject, next, nil
+}
+
+func (r *refreshStore) revokeFamily(ctx context.Context, token string) error {
+	var family string
+	err := r.db.Writer(ctx).QueryRowContext(ctx, r.db.Rebind(
+		`SELECT family FROM refresh_tokens WHERE token_hash = ?`), hashToken(token)).Scan(&family)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return fmt.Errorf("auth: load refresh token: %w", err)
+	}
+	return r.revokeFamilyTx(ctx, r.db.Writer(ctx), family, time.Now().Unix())
+}
+
+func (r *refreshStore) revokeFamilyTx(ctx context.Context, db execer, family string, now int64) error {
+	_, err := db.ExecContext(ctx, r.db.Rebind(
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE family = ? AND revoked_at IS NULL`), now, family)
+	if err != nil {
+		return fmt.Errorf("auth: revoke token family: %w", err)
+	}
+	return nil
+}
+
+func (r *refreshStore) revokeSubject(ctx context.Context, subject string) error {
+	_, err := r.db.Writer(ctx).ExecContext(ctx, r.db.Rebind(
+		`UPDATE refresh_tokens SET revoked_at = ? WHERE subject = ? AND revoked_at IS NULL`), time.Now().Unix(), subject)
+	if err != nil {
+		return fmt.Errorf("auth: revoke subject tokens: %w", err)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,240 @@
+// Package bandwidth throttles how fast responses are sent, so that a few
+// large downloads cannot take all of the egress of the instance. Every
+// connection has a token bucket of bytes of its own, and all of them draw
+// from a global one too; a write waits until both have the tokens, which
+// slows the handler down as a slow client would.
+//
+// Middleware sits beneath compression, so that the bytes counted are
+// those sent, and throttles only the routes that opt in with Throttled,
+// those of files, exports and streams: small answers are never held up.
+package bandwidth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+)
+
+// maxChunk bounds the bytes a single wait is for, so that a large write is
+// sent in steps rather than in one go after a long wait.
+const maxChunk = 32 << 10
+
+// throttledKey marks the requests of routes that opted in.
+const throttledKey = "bandwidth.throttled"
+
+// Bucket is a token bucket of bytes, safe for concurrent use.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket refilling at rate bytes per second up
+// to burst bytes.
+func NewBucket(rate, burst int64) *Bucket {
+	return &Bucket{rate: float64(rate), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes n bytes, going into debt when there are fewer, and
+// returns how long to wait until the debt is paid off.
+func (b *Bucket) reserve(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Writer writes to an io.Writer no faster than all of its buckets allow.
+type Writer struct {
+	w       io.Writer
+	ctx     context.Context
+	buckets []*Bucket
+	chunk   int
+}
+
+// NewWriter returns a Writer to w drawing from buckets, whose waits end
+// early with the error of ctx when it is done.
+func NewWriter(ctx context.Context, w io.Writer, buckets ...*Bucket) *Writer {
+	chunk := maxChunk
+	for _, b := range buckets {
+		chunk = min(chunk, max(int(b.burst), 1))
+	}
+	return &Writer{w: w, ctx: ctx, buckets: buckets, chunk: chunk}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), w.chunk)
+		if err := w.wait(n); err != nil {
+			return written, err
+		}
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// wait takes n bytes from every bucket and waits for the slowest.
+func (w *Writer) wait(n int) error {
+	now := time.Now()
+	var d time.Duration
+	for _, b := range w.buckets {
+		d = max(d, b.reserve(n, now))
+	}
+	if d == 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
+
+// Throttle holds the buckets of the connections and the global one.
+type Throttle struct {
+	perConn int64
+	global  *Bucket // nil without a global cap
+
+	mu    sync.Mutex
+	conns map[string]*conn
+}
+
+// conn is the bucket of a connection, shared by its requests in flight.
+type conn struct {
+	bucket   *Bucket // nil without a per-connection cap
+	requests int
+}
+
+// New returns the Throttle of cfg, or nil when it limits nothing.
+func New(cfg config.Bandwidth) *Throttle {
+	if cfg.PerConnection == 0 && cfg.Global == 0 {
+		return nil
+	}
+	t := &Throttle{perConn: cfg.PerConnection, conns: map[string]*conn{}}
+	if cfg.Global > 0 {
+		// Bursts are one second's worth.
+		t.global = NewBucket(cfg.Global, cfg.Global)
+	}
+	return t
+}
+
+// Throttled opts the routes it is used on in to throttling.
+func Throttled() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(throttledKey, true)
+		c.Next()
+	}
+}
+
+// Middleware throttles the responses of the routes that opted in. It must
+// run before the compression middleware. A nil Throttle passes everything
+// through.
+func (t *Throttle) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if t == nil {
+			c.Next()
+			return
+		}
+		w := &writer{ResponseWriter: c.Writer, c: c, t: t}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			if w.conn != nil {
+				t.release(c.Request.RemoteAddr)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// acquire returns the bucket of the connection from addr, which is unique
+// to the connection: HTTP/2 streams share it.
+func (t *Throttle) acquire(addr string) *conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cn, ok := t.conns[addr]
+	if !ok {
+		cn = &conn{}
+		if t.perConn > 0 {
+			cn.bucket = NewBucket(t.perConn, t.perConn)
+		}
+		t.conns[addr] = cn
+	}
+	cn.requests++
+	return cn
+}
+
+// release forgets the bucket of the connection from addr once no request
+// uses it.
+func (t *Throttle) release(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cn := t.conns[addr]; cn != nil {
+		if cn.requests--; cn.requests == 0 {
+			delete(t.conns, addr)
+		}
+	}
+}
+
+// writer throttles the body once the route turns out to have opted in,
+// which is only known after the middleware of its group ran.
+type writer struct {
+	gin.ResponseWriter
+	c    *gin.Context
+	t    *Throttle
+	conn *conn
+	out  *Writer // nil until decided, and for routes not throttled
+	done bool
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.done {
+		w.done = true
+		if w.c.GetBool(throttledKey) {
+			w.conn = w.t.acquire(w.c.Request.RemoteAddr)
+			var buckets []*Bucket
+			for _, b := range []*Bucket{w.conn.bucket, w.t.global} {
+				if b != nil {
+					buckets = append(buckets, b)
+				}
+			}
+			w.out = NewWriter(w.c.Request.Context(), w.ResponseWriter, buckets...)
+		}
+	}
+	if w.out == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.out.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection, for example
+// to lift the write deadline of a stream.
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
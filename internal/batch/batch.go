@@ -0,0 +1,201 @@
+// Package batch runs several API requests sent in one body, so that a
+// client with many small changes to make pays for one round trip instead
+// of one per change. Each request of a batch goes through the router on
+// its own, with the headers of the batch and the middleware of its route,
+// and succeeds or fails on its own: the batch answers 207 Multi-Status with
+// the status, headers and body of every request, in the order sent.
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/validation"
+)
+
+// Path is where batches are posted.
+const Path = "/api/v1/batch"
+
+// Request is one request of a batch.
+type Request struct {
+	// ID is an optional client reference, echoed in the response.
+	ID     string `json:"id,omitempty" binding:"max=64"`
+	Method string `json:"method" binding:"required,oneof=GET POST PUT PATCH DELETE"`
+	// Path is an API path below /api/, with its query.
+	Path    string            `json:"path" binding:"required,startswith=/api/"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is sent as JSON.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is the outcome of one request of a batch.
+type Response struct {
+	ID      string            `json:"id,omitempty"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the JSON response, or other responses as a string.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+type batchRequest struct {
+	Requests []Request `json:"requests" binding:"required,min=1,dive"`
+}
+
+type batchResponse struct {
+	Responses []Response `json:"responses"`
+}
+
+// droppedHeaders are the headers of a batch its requests do not inherit:
+// those describing the batch body, and those that only make sense for one
+// request.
+var droppedHeaders = []string{
+	"Content-Type", "Content-Length", "Content-Encoding", "Accept-Encoding",
+	"Idempotency-Key", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since",
+}
+
+// keptHeaders are the response headers reported for each request.
+var keptHeaders = []string{"Content-Type", "Location", "ETag", "Retry-After", "Deprecation", "Sunset"}
+
+// Handler answers batches by running their requests through h, up to the
+// configured concurrency at a time. Requests inherit the headers of the
+// batch, such as Authorization, which their own headers override, and run
+// within its deadline; batches cannot be nested.
+func Handler(h http.Handler, live *config.Live) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The batch writes nothing itself; holding a transaction open
+		// would only block the writes of its requests.
+		store.SkipTransaction(c)
+
+		var req batchRequest
+		if !validation.BindJSON(c, &req) {
+			return
+		}
+		cfg := live.Load().Batch
+		if len(req.Requests) > cfg.MaxItems {
+			c.Error(apperror.Newf(apperror.KindBadRequest, "a batch holds at most %d requests", cfg.MaxItems))
+			return
+		}
+		for _, r := range req.Requests {
+			if u, err := url.Parse(r.Path); err != nil || strings.HasPrefix(u.Path, Path) {
+				c.Error(apperror.Newf(apperror.KindBadRequest, "invalid batch request path %s", r.Path))
+				return
+			}
+		}
+
+		responses := make([]Response, len(req.Requests))
+		sem := make(chan struct{}, cfg.Concurrency)
+		var wg sync.WaitGroup
+		for i, r := range req.Requests {
+			sem <- struct{}{}
+			wg.Go(func() {
+				defer func() { <-sem }()
+				responses[i] = run(c, h, r)
+			})
+		}
+		wg.Wait()
+		c.JSON(http.StatusMultiStatus, batchResponse{Responses: responses})
+	}
+}
+
+// run sends r through h as a request of its own.
+func run(c *gin.Context, h http.Handler, r Request) Response {
+	sub, err := http.NewRequestWithContext(c.Request.Context(), r.Method, r.Path, bytes.NewReader(r.Body))
+	if err != nil {
+		return failed(r, http.StatusBadRequest, "invalid batch request")
+	}
+	sub.Header = c.Request.Header.Clone()
+	for _, name := range droppedHeaders {
+		sub.Header.Del(name)
+	}
+	if len(r.Body) > 0 {
+		sub.Header.Set("Content-Type", gin.MIMEJSON)
+	}
+	sub.Header.Set("Accept", gin.MIMEJSON)
+	for name, value := range r.Headers {
+		sub.Header.Set(name, value)
+	}
+	sub.RemoteAddr = c.Request.RemoteAddr
+	sub.Host = c.Request.Host
+
+	rec := &recorder{header: http.Header{}, status: http.StatusOK}
+	if !serve(h, rec, sub) {
+		return failed(r, http.StatusInternalServerError, "internal server error")
+	}
+
+	res := Response{ID: r.ID, Status: rec.status}
+	for _, name := range keptHeaders {
+		if v := rec.header.Get(name); v != "" {
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			res.Headers[name] = v
+		}
+	}
+	switch b := rec.body.Bytes(); {
+	case len(b) == 0:
+	case isJSON(rec.header.Get("Content-Type")) && json.Valid(b):
+		res.Body = b
+	default:
+		res.Body, _ = json.Marshal(string(b))
+	}
+	return res
+}
+
+// serve runs h, reporting false if it panicked. Panics are recovered by
+// the routes themselves, but not in the middleware in front of them, and
+// one escaping here would take down the process rather than a request.
+func serve(h http.Handler, w http.ResponseWriter, r *http.Request) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.ErrorContext(r.Context(), "batch request panicked", "path", r.URL.Path, "panic", p)
+			ok = false
+		}
+	}()
+	h.ServeHTTP(w, r)
+	return true
+}
+
+// recorder keeps the response of a request of a batch.
+type recorder struct {
+	header      http.Header
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = code, true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// Flush does nothing: the response is sent with the whole batch.
+func (r *recorder) Flush() {}
+
+func failed(r Request, status int, detail string) Response {
+	body, _ := json.Marshal(validation.NewProblem(status, detail))
+	return Response{ID: r.ID, Status: status, Headers: map[string]string{"Content-Type": validation.ContentType}, Body: body}
+}
+
+func isJSON(contentType string) bool {
+	ct, _, _ := strings.Cut(contentType, ";")
+	ct = strings.TrimSpace(ct)
+	return ct == gin.MIMEJSON || strings.HasSuffix(ct, "+json")
+}
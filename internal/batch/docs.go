@@ -0,0 +1,18 @@
+package batch
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the route of Handler, mounted at /batch.
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{ID: "batch", Method: http.MethodPost, Path: "/batch", Tags: []string{"batch"}, Summary: "Run several API requests at once",
+			Description: "Each request runs on its own through its route, with the headers of the batch and its own, and succeeds or fails without affecting the others. " +
+				"The response lists the status, headers and body of every request in the order sent; the batch itself only fails if it is malformed.",
+			Request: batchRequest{}, Response: batchResponse{}, Status: http.StatusMultiStatus,
+			Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+	}
+}
@@ -0,0 +1,99 @@
+// Package buildinfo describes the running binary. Release builds inject
+// the values with the linker:
+//
+//	go build -ldflags "-X go-flylike-example/internal/buildinfo.Version=v1.2.3 \
+//	  -X go-flylike-example/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X go-flylike-example/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them the VCS stamp recorded by the Go toolchain is used, along
+// with the module version it stamps: the tag for go install module@v1.2.3,
+// and a pseudo-version such as v0.0.0-20260102150405-abcdef123456 for
+// builds in a git checkout. Versions are semantic versions; "1.2.3" is read
+// as "v1.2.3".
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+)
+
+// Header carries the version on every response.
+const Header = "X-App-Version"
+
+// Set with -ldflags "-X ...".
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info is what the binary knows about itself.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information, read once.
+func Get() Info {
+	once.Do(func() {
+		info = Info{
+			Version:   Version,
+			Commit:    Commit,
+			BuildTime: BuildTime,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		}
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			info.Version = canonical(info.Version)
+			return
+		}
+		if info.Version == "dev" && semver.IsValid(bi.Main.Version) {
+			info.Version = bi.Main.Version
+		}
+		info.Version = canonical(info.Version)
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	})
+	return info
+}
+
+// canonical adds the "v" that semantic versions are written with in Go to
+// a version lacking it.
+func canonical(v string) string {
+	if !strings.HasPrefix(v, "v") && semver.IsValid("v"+v) {
+		return "v" + v
+	}
+	return v
+}
+
+// Semver reports whether the version is a semantic version, as release
+// builds are; development builds are "dev".
+func (i Info) Semver() bool {
+	return semver.IsValid(i.Version)
+}
@@ -0,0 +1,13 @@
+package buildinfo
+
+import "github.com/gin-gonic/gin"
+
+// Middleware sets Header on every response, so that deploy checks and
+// clients can tell which build answered.
+func Middleware() gin.HandlerFunc {
+	version := Get().Version
+	return func(c *gin.Context) {
+		c.Header(Header, version)
+		c.Next()
+	}
+}
@@ -0,0 +1,187 @@
+// Package bus publishes domain events to a message broker and runs the
+// consumers that react to them. Delivery is at least once: a message is
+// acknowledged only after its handler returned, so handlers must tolerate
+// seeing a message again. Failed messages are redelivered with backoff and,
+// once they have failed too often, moved to a dead-letter topic instead of
+// blocking the consumer. The broker is NATS JetStream, Kafka or, for
+// development and single instances, process memory.
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/tenant"
+)
+
+// Message is one event on a topic. Key groups related messages: brokers
+// that partition topics store those with the same key in order.
+type Message struct {
+	ID      string            `json:"id"`
+	Topic   string            `json:"topic"`
+	Type    string            `json:"type"`
+	Key     string            `json:"key,omitempty"`
+	Tenant  string            `json:"tenant,omitempty"`
+	Time    time.Time         `json:"time"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Data    json.RawMessage   `json:"data"`
+}
+
+// Delivery is a message handed to a consumer. Attempt counts deliveries to
+// the consumer group, starting at 1.
+type Delivery struct {
+	*Message
+	Attempt int
+
+	ack   func() error
+	retry func(delay time.Duration) error
+}
+
+// Subscription delivers the messages of one topic to one consumer group.
+type Subscription interface {
+	// Next blocks until a message is delivered or ctx is done.
+	Next(ctx context.Context) (*Delivery, error)
+	// Close stops delivery. Deliveries already returned may still be
+	// acknowledged.
+	Close() error
+}
+
+// Broker moves messages from publishers to consumer groups. Every group
+// subscribed to a topic receives each message once, shared between the
+// subscriptions of the group.
+type Broker interface {
+	Publish(ctx context.Context, m *Message) error
+	Subscribe(ctx context.Context, topic, group string) (Subscription, error)
+	Close() error
+}
+
+// Header names under which brokers carry the fields of a message.
+const (
+	headerID     = "Bus-Id"
+	headerType   = "Bus-Type"
+	headerTenant = "Bus-Tenant"
+	headerTime   = "Bus-Time"
+	headerKey    = "Bus-Key"
+	// HeaderError, HeaderConsumer and HeaderAttempts are set on
+	// dead-lettered messages.
+	HeaderError     = "Bus-Error"
+	HeaderConsumer  = "Bus-Consumer"
+	HeaderAttempts  = "Bus-Attempts"
+	headerRequestID = "Bus-Request-Id"
+)
+
+// DeadLetterTopic is where messages of topic go once consumers gave up on
+// them.
+func DeadLetterTopic(topic string) string {
+	return topic + ".dead-letter"
+}
+
+// errPermanent marks errors that retrying cannot fix.
+type errPermanent struct{ err error }
+
+func (e errPermanent) Error() string { return e.err.Error() }
+func (e errPermanent) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the message is dead-lettered at once instead
+// of being retried, for messages a handler can never process.
+func Permanent(err error) error {
+	return errPermanent{err}
+}
+
+func isPermanent(err error) bool {
+	var p errPermanent
+	return errors.As(err, &p)
+}
+
+// NewBroker connects to the broker cfg selects.
+func NewBroker(ctx context.Context, cfg config.Bus) (Broker, error) {
+	switch cfg.Backend {
+	case "nats":
+		return newNATS(ctx, cfg)
+	case "kafka":
+		return newKafka(cfg), nil
+	default:
+		return newMemory(), nil
+	}
+}
+
+// Publish sends data as an event of type event to topic, stamped with the
+// tenant and request ID of ctx so that consumers run in the same tenant.
+func Publish(ctx context.Context, b Broker, topic, event, key string, data any) error {
+	m, err := NewMessage(ctx, topic, event, key, data)
+	if err != nil {
+		return err
+	}
+	if err := b.Publish(ctx, m); err != nil {
+		return fmt.Errorf("bus: publish %s: %w", event, err)
+	}
+	return nil
+}
+
+// NewMessage returns the message Publish would send, for publishers that
+// send it later themselves.
+func NewMessage(ctx context.Context, topic, event, key string, data any) (*Message, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("bus: publish %s: %w", event, err)
+	}
+	m := &Message{
+		ID:     id.New(),
+		Topic:  topic,
+		Type:   event,
+		Key:    key,
+		Tenant: tenant.ID(ctx),
+		Time:   time.Now().UTC(),
+		Data:   body,
+	}
+	if id := logging.RequestIDFrom(ctx); id != "" {
+		m.Headers = map[string]string{headerRequestID: id}
+	}
+	return m, nil
+}
+
+// headers flattens the fields of m that have no place of their own in a
+// broker message.
+func headers(m *Message) map[string]string {
+	h := make(map[string]string, len(m.Headers)+4)
+	for k, v := range m.Headers {
+		h[k] = v
+	}
+	h[headerID] = m.ID
+	h[headerType] = m.Type
+	h[headerTime] = m.Time.Format(time.RFC3339Nano)
+	if m.Tenant != "" {
+		h[headerTenant] = m.Tenant
+	}
+	return h
+}
+
+// fromHeaders is the reverse of headers.
+func fromHeaders(topic, key string, data []byte, h map[string]string) *Message {
+	m := &Message{
+		ID:     h[headerID],
+		Topic:  topic,
+		Type:   h[headerType],
+		Key:    key,
+		Tenant: h[headerTenant],
+		Data:   data,
+	}
+	m.Time, _ = time.Parse(time.RFC3339Nano, h[headerTime])
+	for k, v := range h {
+		switch k {
+		case headerID, headerType, headerTenant, headerTime:
+		default:
+			if m.Headers == nil {
+				m.Headers = map[string]string{}
+			}
+			m.Headers[k] = v
+		}
+	}
+	return m
+}
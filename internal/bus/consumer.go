@@ -0,0 +1,219 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/tenant"
+)
+
+// Handler processes one message. Returning nil acknowledges it; any other
+// error has it redelivered, unless it is Permanent. ctx carries the tenant
+// and request ID of the publisher.
+type Handler func(ctx context.Context, m *Message) error
+
+// Consumer reacts to the messages of Topic. Instances running a consumer of
+// the same Name form a group sharing the messages, so each is handled once
+// per name. Concurrency and MaxAttempts default to the bus configuration.
+type Consumer struct {
+	Name        string
+	Topic       string
+	Concurrency int
+	MaxAttempts int
+	Handle      Handler
+}
+
+// Runner runs consumers against a broker.
+type Runner struct {
+	broker    Broker
+	cfg       config.Bus
+	consumers []Consumer
+
+	subs   []Subscription
+	stop   context.CancelFunc
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRunner returns a Runner for broker, which it closes on Shutdown.
+func NewRunner(broker Broker, cfg config.Bus) *Runner {
+	return &Runner{broker: broker, cfg: cfg}
+}
+
+// Register adds c; it must be called before Start.
+func (r *Runner) Register(c Consumer) {
+	if c.Concurrency <= 0 {
+		c.Concurrency = r.cfg.Concurrency
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = r.cfg.MaxAttempts
+	}
+	r.consumers = append(r.consumers, c)
+}
+
+// Start subscribes every consumer and starts its workers. It fails, and
+// starts none, when a subscription cannot be made.
+func (r *Runner) Start(ctx context.Context) error {
+	for _, c := range r.consumers {
+		sub, err := r.broker.Subscribe(ctx, c.Topic, c.Name)
+		if err != nil {
+			for _, s := range r.subs {
+				_ = s.Close()
+			}
+			r.subs = nil
+			return fmt.Errorf("bus: subscribe %s to %s: %w", c.Name, c.Topic, err)
+		}
+		r.subs = append(r.subs, sub)
+	}
+
+	// Workers stop taking messages once fetch is done and abandon the ones
+	// they hold once work is, which only happens when Shutdown runs late.
+	fetch, stop := context.WithCancel(context.Background())
+	work, cancel := context.WithCancel(context.Background())
+	r.stop, r.cancel = stop, cancel
+	for i, c := range r.consumers {
+		for range c.Concurrency {
+			r.wg.Add(1)
+			go r.worker(fetch, work, c, r.subs[i])
+		}
+		slog.Info("bus consumer started", "consumer", c.Name, "topic", c.Topic, "concurrency", c.Concurrency)
+	}
+	return nil
+}
+
+// Shutdown stops taking messages, waits for the handlers that are running
+// and closes the broker. If ctx expires first the running handlers are
+// cancelled; their messages are redelivered later.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	if r.stop != nil {
+		r.stop()
+	}
+	finished := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(finished)
+	}()
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		r.cancel()
+		<-finished
+		err = ctx.Err()
+	}
+	for _, s := range r.subs {
+		_ = s.Close()
+	}
+	return errors.Join(err, r.broker.Close())
+}
+
+func (r *Runner) worker(fetch, work context.Context, c Consumer, sub Subscription) {
+	defer r.wg.Done()
+	for {
+		d, err := sub.Next(fetch)
+		if fetch.Err() != nil {
+			if d != nil {
+				// Taken as shutdown began: hand it back untouched.
+				_ = d.retry(0)
+			}
+			return
+		}
+		if err != nil {
+			slog.Error("bus receive failed", "consumer", c.Name, "topic", c.Topic, "error", err)
+			select {
+			case <-fetch.Done():
+				return
+			case <-time.After(r.cfg.RetryDelay):
+			}
+			continue
+		}
+		r.process(work, c, d)
+	}
+}
+
+func (r *Runner) process(ctx context.Context, c Consumer, d *Delivery) {
+	logger := slog.With("consumer", c.Name, "topic", d.Topic, "message_id", d.ID, "type", d.Type, "attempt", d.Attempt)
+	if d.Attempt > c.MaxAttempts {
+		// Handlers that crash the process never return an error; the
+		// broker's delivery count catches them.
+		r.deadLetter(ctx, logger, c, d, errors.New("delivered more often than max attempts"))
+		return
+	}
+
+	hctx := tenant.WithTenant(ctx, &tenant.Tenant{ID: d.Tenant})
+	if id := d.Headers[headerRequestID]; id != "" {
+		hctx = logging.WithRequestID(hctx, id)
+	}
+	hctx, cancel := context.WithTimeout(hctx, r.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := safeHandle(hctx, c.Handle, d.Message)
+	switch {
+	case err == nil:
+		if err := d.ack(); err != nil {
+			logger.Warn("bus ack failed, message will be redelivered", "error", err)
+			return
+		}
+		logger.Debug("bus message handled", "duration", time.Since(start).String())
+	case ctx.Err() != nil:
+		logger.Warn("bus handler interrupted by shutdown, releasing", "error", err)
+		_ = d.retry(0)
+	case isPermanent(err) || d.Attempt >= c.MaxAttempts:
+		r.deadLetter(ctx, logger, c, d, err)
+	default:
+		delay := r.backoff(d.Attempt)
+		logger.Warn("bus handler failed, retrying", "error", err, "retry_in", delay.String())
+		if err := d.retry(delay); err != nil {
+			logger.Error("bus retry failed", "error", err)
+		}
+	}
+}
+
+// deadLetter moves d to the dead-letter topic of its topic. If that fails
+// the message stays where it is and is delivered again.
+func (r *Runner) deadLetter(ctx context.Context, logger *slog.Logger, c Consumer, d *Delivery, cause error) {
+	m := *d.Message
+	m.Topic = DeadLetterTopic(d.Topic)
+	m.Headers = maps.Clone(d.Headers)
+	if m.Headers == nil {
+		m.Headers = map[string]string{}
+	}
+	m.Headers[HeaderError] = cause.Error()
+	m.Headers[HeaderConsumer] = c.Name
+	m.Headers[HeaderAttempts] = strconv.Itoa(d.Attempt)
+
+	bg := context.WithoutCancel(ctx)
+	if err := r.broker.Publish(bg, &m); err != nil {
+		logger.Error("bus dead-letter failed, retrying", "error", err, "cause", cause)
+		_ = d.retry(r.backoff(d.Attempt))
+		return
+	}
+	logger.Error("bus message dead-lettered", "error", cause, "dead_letter_topic", m.Topic)
+	if err := d.ack(); err != nil {
+		logger.Warn("bus ack failed, message will be redelivered", "error", err)
+	}
+}
+
+// backoff doubles the retry delay with every attempt, up to an hour.
+func (r *Runner) backoff(attempt int) time.Duration {
+	return min(r.cfg.RetryDelay<<min(attempt-1, 12), time.Hour)
+}
+
+func safeHandle(ctx context.Context, h Handler, m *Message) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return h(ctx, m)
+}
@@ -0,0 +1,195 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"go-flylike-example/internal/config"
+)
+
+// kafkaCommitTimeout bounds the offset commit that follows an ack.
+const kafkaCommitTimeout = 10 * time.Second
+
+// kafkaBroker maps topics to Kafka topics and groups to consumer groups.
+// Kafka keeps no per-message state: an offset is committed once every
+// message before it was acknowledged, and retries are scheduled in the
+// consuming process, restarting at the first attempt after a crash.
+type kafkaBroker struct {
+	brokers []string
+	w       *kafka.Writer
+}
+
+func newKafka(cfg config.Bus) *kafkaBroker {
+	return &kafkaBroker{
+		brokers: cfg.Brokers,
+		w: &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.Brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+func (b *kafkaBroker) Publish(ctx context.Context, m *Message) error {
+	msg := kafka.Message{Topic: m.Topic, Key: []byte(m.Key), Value: m.Data}
+	for k, v := range headers(m) {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return b.w.WriteMessages(ctx, msg)
+}
+
+func (b *kafkaBroker) Subscribe(_ context.Context, topic, group string) (Subscription, error) {
+	s := &kafkaSubscription{
+		r: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: b.brokers,
+			GroupID: group,
+			Topic:   topic,
+		}),
+		retries: make(chan *Delivery),
+		done:    make(chan struct{}),
+		pending: map[int]*kafkaPartition{},
+	}
+	return s, nil
+}
+
+func (b *kafkaBroker) Close() error {
+	return b.w.Close()
+}
+
+type kafkaSubscription struct {
+	r       *kafka.Reader
+	retries chan *Delivery
+	done    chan struct{}
+	once    sync.Once
+
+	mu      sync.Mutex
+	pending map[int]*kafkaPartition
+}
+
+// kafkaPartition tracks the fetched offsets of a partition that are not
+// committed yet, in fetch order, and which of them were acknowledged.
+type kafkaPartition struct {
+	offsets []int64
+	acked   map[int64]bool
+}
+
+func (s *kafkaSubscription) Next(ctx context.Context) (*Delivery, error) {
+	// Retries due now go first, so that the partitions they hold back can
+	// move on.
+	select {
+	case d := <-s.retries:
+		return d, nil
+	default:
+	}
+
+	fetched := make(chan kafka.Message, 1)
+	errs := make(chan error, 1)
+	fctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		msg, err := s.r.FetchMessage(fctx)
+		if err != nil {
+			errs <- err
+			return
+		}
+		fetched <- msg
+	}()
+
+	select {
+	case d := <-s.retries:
+		cancel()
+		// A message fetched meanwhile must not get lost.
+		select {
+		case msg := <-fetched:
+			go s.requeue(s.delivery(msg))
+		case <-errs:
+		}
+		return d, nil
+	case msg := <-fetched:
+		return s.delivery(msg), nil
+	case err := <-errs:
+		if errors.Is(err, io.EOF) {
+			return nil, errClosed
+		}
+		return nil, err
+	case <-s.done:
+		return nil, errClosed
+	}
+}
+
+func (s *kafkaSubscription) delivery(msg kafka.Message) *Delivery {
+	h := make(map[string]string, len(msg.Headers))
+	for _, hdr := range msg.Headers {
+		h[hdr.Key] = string(hdr.Value)
+	}
+	s.mu.Lock()
+	p := s.pending[msg.Partition]
+	if p == nil {
+		p = &kafkaPartition{acked: map[int64]bool{}}
+		s.pending[msg.Partition] = p
+	}
+	p.offsets = append(p.offsets, msg.Offset)
+	s.mu.Unlock()
+	return s.attempt(fromHeaders(msg.Topic, string(msg.Key), msg.Value, h), msg, 1)
+}
+
+func (s *kafkaSubscription) attempt(m *Message, msg kafka.Message, n int) *Delivery {
+	d := &Delivery{Message: m, Attempt: n}
+	d.ack = func() error { return s.ack(msg) }
+	d.retry = func(delay time.Duration) error {
+		time.AfterFunc(delay, func() { s.requeue(s.attempt(m, msg, n+1)) })
+		return nil
+	}
+	return d
+}
+
+// requeue hands d to the next call of Next. Once the subscription is
+// closed it is dropped; its offset was not committed, so it is fetched
+// again when the group next reads the partition.
+func (s *kafkaSubscription) requeue(d *Delivery) {
+	select {
+	case s.retries <- d:
+	case <-s.done:
+	}
+}
+
+// ack marks msg done and commits the offsets acknowledged without a gap.
+func (s *kafkaSubscription) ack(msg kafka.Message) error {
+	s.mu.Lock()
+	p := s.pending[msg.Partition]
+	p.acked[msg.Offset] = true
+	committable := int64(-1)
+	for len(p.offsets) > 0 && p.acked[p.offsets[0]] {
+		committable = p.offsets[0]
+		delete(p.acked, committable)
+		p.offsets = p.offsets[1:]
+	}
+	s.mu.Unlock()
+	if committable < 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaCommitTimeout)
+	defer cancel()
+	msg.Offset = committable
+	if err := s.r.CommitMessages(ctx, msg); err != nil {
+		return fmt.Errorf("commit %s/%d@%d: %w", msg.Topic, msg.Partition, committable, err)
+	}
+	return nil
+}
+
+func (s *kafkaSubscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		err = s.r.Close()
+	})
+	return err
+}
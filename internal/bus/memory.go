@@ -0,0 +1,118 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go-flylike-example/internal/journal"
+)
+
+// memoryQueueSize bounds the messages waiting for one group; publishers
+// block while it is full.
+const memoryQueueSize = 1024
+
+// errClosed is returned by brokers and subscriptions used after Close.
+var errClosed = errors.New("bus: closed")
+
+// memory is an in-process broker. Messages published to a topic reach the
+// groups subscribed to it at that moment; nothing survives the process,
+// but the journal records those not yet handled, for the outbox to publish
+// again on the next start.
+type memory struct {
+	mu     sync.Mutex
+	groups map[string]map[string]*memoryGroup // topic, group
+	closed chan struct{}
+	once   sync.Once
+}
+
+type memoryGroup struct {
+	queue chan *Delivery
+}
+
+func newMemory() *memory {
+	return &memory{groups: map[string]map[string]*memoryGroup{}, closed: make(chan struct{})}
+}
+
+func (b *memory) Publish(ctx context.Context, m *Message) error {
+	b.mu.Lock()
+	groups := make([]*memoryGroup, 0, len(b.groups[m.Topic]))
+	for _, g := range b.groups[m.Topic] {
+		groups = append(groups, g)
+	}
+	b.mu.Unlock()
+
+	for _, g := range groups {
+		// Every group gets its own copy to count attempts on.
+		c := *m
+		done := journal.Track(journal.KindEvent, c.ID, &c)
+		if err := b.enqueue(ctx, g, &c, 1, done); err != nil {
+			done()
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue hands m to g; done ends its tracking once g handled it.
+func (b *memory) enqueue(ctx context.Context, g *memoryGroup, m *Message, attempt int, done func()) error {
+	d := &Delivery{Message: m, Attempt: attempt}
+	d.ack = func() error { done(); return nil }
+	d.retry = func(delay time.Duration) error {
+		time.AfterFunc(delay, func() {
+			// Once closed, it is left to the journal.
+			_ = b.enqueue(context.Background(), g, m, attempt+1, done)
+		})
+		return nil
+	}
+	select {
+	case g.queue <- d:
+		return nil
+	case <-b.closed:
+		return errClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *memory) Subscribe(_ context.Context, topic, group string) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.groups[topic] == nil {
+		b.groups[topic] = map[string]*memoryGroup{}
+	}
+	g := b.groups[topic][group]
+	if g == nil {
+		g = &memoryGroup{queue: make(chan *Delivery, memoryQueueSize)}
+		b.groups[topic][group] = g
+	}
+	return &memorySubscription{group: g, done: make(chan struct{})}, nil
+}
+
+func (b *memory) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+type memorySubscription struct {
+	group *memoryGroup
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (s *memorySubscription) Next(ctx context.Context) (*Delivery, error) {
+	select {
+	case d := <-s.group.queue:
+		return d, nil
+	case <-s.done:
+		return nil, errClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *memorySubscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
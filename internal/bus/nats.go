@@ -0,0 +1,125 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"go-flylike-example/internal/config"
+)
+
+// natsBroker keeps every topic in one JetStream stream, as the subject
+// "<stream>.<topic>", and each group as a durable consumer of its topic.
+// The server tracks acknowledgements and delivery counts, so messages
+// survive restarts on both sides.
+type natsBroker struct {
+	nc      *nats.Conn
+	js      jetstream.JetStream
+	stream  string
+	ackWait time.Duration
+}
+
+func newNATS(ctx context.Context, cfg config.Bus) (*natsBroker, error) {
+	nc, err := nats.Connect(cfg.URL, nats.Name("go-flylike-example"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("bus: connect to nats: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("bus: jetstream: %w", err)
+	}
+	if _, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Stream,
+		Subjects: []string{cfg.Stream + ".>"},
+	}); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("bus: create stream %s: %w", cfg.Stream, err)
+	}
+	// A handler holds a message for at most the handler timeout; the
+	// margin keeps the server from redelivering it while it is acked.
+	return &natsBroker{nc: nc, js: js, stream: cfg.Stream, ackWait: cfg.Timeout + 30*time.Second}, nil
+}
+
+func (b *natsBroker) subject(topic string) string {
+	return b.stream + "." + topic
+}
+
+func (b *natsBroker) Publish(ctx context.Context, m *Message) error {
+	msg := nats.NewMsg(b.subject(m.Topic))
+	msg.Data = m.Data
+	for k, v := range headers(m) {
+		msg.Header.Set(k, v)
+	}
+	if m.Key != "" {
+		msg.Header.Set(headerKey, m.Key)
+	}
+	// The server drops duplicates of a message ID, such as a publish
+	// retried after a lost acknowledgement. The topic is part of it so
+	// that a dead-lettered copy is not taken for one.
+	msg.Header.Set(jetstream.MsgIDHeader, m.Topic+":"+m.ID)
+	_, err := b.js.PublishMsg(ctx, msg)
+	return err
+}
+
+// durableNames replaces the characters consumer names may not contain.
+var durableNames = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_")
+
+func (b *natsBroker) Subscribe(ctx context.Context, topic, group string) (Subscription, error) {
+	cons, err := b.js.CreateOrUpdateConsumer(ctx, b.stream, jetstream.ConsumerConfig{
+		Durable:       durableNames.Replace(group + "-" + topic),
+		FilterSubject: b.subject(topic),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       b.ackWait,
+	})
+	if err != nil {
+		return nil, err
+	}
+	it, err := cons.Messages()
+	if err != nil {
+		return nil, err
+	}
+	return &natsSubscription{topic: topic, it: it}, nil
+}
+
+func (b *natsBroker) Close() error {
+	return b.nc.Drain()
+}
+
+type natsSubscription struct {
+	topic string
+	it    jetstream.MessagesContext
+}
+
+func (s *natsSubscription) Next(ctx context.Context) (*Delivery, error) {
+	msg, err := s.it.Next(jetstream.NextContext(ctx))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			return nil, errClosed
+		}
+		return nil, err
+	}
+	h := make(map[string]string, len(msg.Headers()))
+	for k := range msg.Headers() {
+		if k != jetstream.MsgIDHeader && k != headerKey {
+			h[k] = msg.Headers().Get(k)
+		}
+	}
+	d := &Delivery{Message: fromHeaders(s.topic, msg.Headers().Get(headerKey), msg.Data(), h), Attempt: 1}
+	if md, err := msg.Metadata(); err == nil {
+		d.Attempt = int(md.NumDelivered)
+	}
+	d.ack = msg.Ack
+	d.retry = msg.NakWithDelay
+	return d, nil
+}
+
+func (s *natsSubscription) Close() error {
+	s.it.Stop()
+	return nil
+}
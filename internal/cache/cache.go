@@ -0,0 +1,186 @@
+// Package cache caches values computed by handlers and repositories in a
+// Store shared by every instance (Redis) or kept per instance (memory).
+// GetOrSet loads a missed key once however many requests want it at the
+// same time, and expiry is jittered so that keys written together do not
+// all expire together; between them they keep a popular key from sending
+// a stampede to the database when it expires.
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache holds the values of one kind, under keys prefixed with its name.
+type Cache struct {
+	name    string
+	store   Store
+	codec   Codec
+	ttl     time.Duration
+	jitter  float64
+	metrics *Metrics
+	group   singleflight.Group
+	// onDelete are called with the keys deleted.
+	onDelete []func(ctx context.Context, keys []string)
+}
+
+// Option configures a Cache.
+type Option func(*Cache)
+
+// WithTTL sets how long values are kept (default: one minute).
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) { c.ttl = ttl }
+}
+
+// WithJitter shortens each TTL by a random fraction of up to jitter, which
+// is between 0 and 1 (default: 0.1).
+func WithJitter(jitter float64) Option {
+	return func(c *Cache) { c.jitter = jitter }
+}
+
+// WithCodec sets how values are encoded (default: JSON).
+func WithCodec(codec Codec) Option {
+	return func(c *Cache) { c.codec = codec }
+}
+
+// WithMetrics records lookups and loads in m under the name of the cache.
+func WithMetrics(m *Metrics) Option {
+	return func(c *Cache) { c.metrics = m }
+}
+
+// New returns a Cache called name that keeps its values in store.
+func New(name string, store Store, opts ...Option) *Cache {
+	c := &Cache{name: name, store: store, codec: JSON, ttl: time.Minute, jitter: 0.1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Cache) key(key string) string {
+	return c.name + ":" + key
+}
+
+// expiry returns the TTL of a value written now.
+func (c *Cache) expiry() time.Duration {
+	if c.jitter <= 0 {
+		return c.ttl
+	}
+	return c.ttl - time.Duration(rand.Float64()*c.jitter*float64(c.ttl))
+}
+
+// Get returns the value cached under key, or ErrMiss. A value that no
+// longer decodes into T, say after a deploy changed the type, is a miss.
+func Get[T any](ctx context.Context, c *Cache, key string) (T, error) {
+	var v T
+	b, err := c.store.Get(ctx, c.key(key))
+	if errors.Is(err, ErrMiss) {
+		c.metrics.lookup(c.name, "miss")
+		return v, ErrMiss
+	}
+	if err != nil {
+		c.metrics.lookup(c.name, "error")
+		return v, err
+	}
+	if err := c.codec.Unmarshal(b, &v); err != nil {
+		slog.WarnContext(ctx, "cached value does not decode", "cache", c.name, "error", err)
+		c.metrics.lookup(c.name, "miss")
+		var zero T
+		return zero, ErrMiss
+	}
+	c.metrics.lookup(c.name, "hit")
+	return v, nil
+}
+
+// GetOrSet returns the value cached under key, or calls load and caches
+// what it returns. Concurrent calls for the same key on this instance share
+// a single load, which runs without the cancellation of the caller that
+// started it so that the others do not fail with it; each caller stops
+// waiting when its own ctx is done. The key must therefore identify
+// everything load depends on, the tenant included. Errors of load are
+// returned and not cached, and an unavailable store degrades to calling
+// load every time.
+func GetOrSet[T any](ctx context.Context, c *Cache, key string, load func(context.Context) (T, error)) (T, error) {
+	v, err := Get[T](ctx, c, key)
+	if err == nil {
+		return v, nil
+	}
+	if !errors.Is(err, ErrMiss) {
+		slog.WarnContext(ctx, "cache unavailable", "cache", c.name, "error", err)
+	}
+
+	loadCtx := context.WithoutCancel(ctx)
+	ch := c.group.DoChan(key, func() (any, error) {
+		start := time.Now()
+		v, err := load(loadCtx)
+		c.metrics.loaded(c.name, time.Since(start), err)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Set(loadCtx, key, v); err != nil {
+			slog.WarnContext(loadCtx, "cache write failed", "cache", c.name, "error", err)
+		}
+		return v, nil
+	})
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case r := <-ch:
+		if r.Shared {
+			c.metrics.sharedLoad(c.name)
+		}
+		if r.Err != nil {
+			var zero T
+			return zero, r.Err
+		}
+		v, _ := r.Val.(T)
+		return v, nil
+	}
+}
+
+// Set caches v under key.
+func (c *Cache) Set(ctx context.Context, key string, v any) error {
+	b, err := c.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, c.key(key), b, c.expiry())
+}
+
+// Delete forgets the value under key, so that the next lookup loads it
+// again.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if err := c.store.Delete(ctx, c.key(key)); err != nil {
+		return err
+	}
+	for _, fn := range c.onDelete {
+		fn(ctx, []string{key})
+	}
+	return nil
+}
+
+// Name returns the name of the cache.
+func (c *Cache) Name() string { return c.name }
+
+// OnDelete registers fn to be called with the keys Delete forgets, such as
+// to tell other instances to forget them too. It must be called before the
+// cache is shared.
+func (c *Cache) OnDelete(fn func(ctx context.Context, keys []string)) {
+	c.onDelete = append(c.onDelete, fn)
+}
+
+// Evict forgets the values under keys without calling the OnDelete
+// functions, for deletions made elsewhere.
+func (c *Cache) Evict(ctx context.Context, keys ...string) error {
+	var errs []error
+	for _, key := range keys {
+		errs = append(errs, c.store.Delete(ctx, c.key(key)))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Codec turns cached values into bytes and back.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSON encodes values with encoding/json, so that they honour json tags
+// and stay readable in redis-cli.
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// MsgPack encodes values as MessagePack, which is smaller and faster to
+// decode than JSON. Field names come from json tags as well, and times
+// use the msgpack timestamp extension.
+var MsgPack Codec = msgpackCodec{}
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.Canonical = true
+	return h
+}()
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var b []byte
+	if err := codec.NewEncoderBytes(&b, msgpackHandle).Encode(v); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
+
+// CodecByName returns the codec named in configuration: "json" or
+// "msgpack".
+func CodecByName(name string) (Codec, error) {
+	switch name {
+	case "json":
+		return JSON, nil
+	case "msgpack":
+		return MsgPack, nil
+	}
+	return nil, fmt.Errorf("cache: unknown codec %q", name)
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are the collectors shared by every cache built WithMetrics.
+// A nil *Metrics records nothing.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	loads    *prometheus.HistogramVec
+	shared   *prometheus.CounterVec
+}
+
+// NewMetrics creates the cache collectors and registers them on reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cache",
+			Name:      "requests_total",
+			Help:      "Cache lookups by cache and result (hit, miss or error).",
+		}, []string{"cache", "result"}),
+		loads: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cache",
+			Name:      "load_duration_seconds",
+			Help:      "Time spent loading missed values, by cache and result (ok or error).",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"cache", "result"}),
+		shared: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cache",
+			Name:      "shared_loads_total",
+			Help:      "Lookups whose load was shared with other lookups of the same key in flight.",
+		}, []string{"cache"}),
+	}
+	reg.MustRegister(m.requests, m.loads, m.shared)
+	return m
+}
+
+func (m *Metrics) lookup(name, result string) {
+	if m != nil {
+		m.requests.WithLabelValues(name, result).Inc()
+	}
+}
+
+func (m *Metrics) loaded(name string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.loads.WithLabelValues(name, result).Observe(d.Seconds())
+}
+
+func (m *Metrics) sharedLoad(name string) {
+	if m != nil {
+		m.shared.WithLabelValues(name).Inc()
+	}
+}
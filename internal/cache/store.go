@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/kv"
+)
+
+// ErrMiss is returned for absent or expired keys.
+var ErrMiss = errors.New("cache: miss")
+
+// Store holds encoded values. A zero ttl keeps the value until evicted.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// maxEntries caps the memory store; it is simply emptied when full.
+const maxEntries = 10000
+
+// MemoryStore keeps values in process memory, per instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		return nil, ErrMiss
+	}
+	return e.value, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= maxEntries {
+		clear(s.entries)
+	}
+	e := memoryEntry{value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	s.entries[key] = e
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// RedisStore keeps values in Redis, shared by every instance.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client, prefix: "cache:"}
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: redis get: %w", err)
+	}
+	return v, nil
+}
+
+// Set implements Store.
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.client.Set(ctx, s.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("cache: redis set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+		return fmt.Errorf("cache: redis del: %w", err)
+	}
+	return nil
+}
+
+// KVStore keeps values in a bucket of the embedded store, kept across
+// restarts of a single node.
+type KVStore struct {
+	bucket *kv.Bucket
+}
+
+// NewKVStore returns a Store backed by db.
+func NewKVStore(db *kv.DB) *KVStore {
+	return &KVStore{bucket: db.Bucket("cache")}
+}
+
+// Get implements Store.
+func (s *KVStore) Get(_ context.Context, key string) ([]byte, error) {
+	v, err := s.bucket.Get(key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: kv get: %w", err)
+	}
+	return v, nil
+}
+
+// Set implements Store.
+func (s *KVStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.bucket.Put(key, value, ttl); err != nil {
+		return fmt.Errorf("cache: kv set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *KVStore) Delete(_ context.Context, key string) error {
+	if err := s.bucket.Delete(key); err != nil {
+		return fmt.Errorf("cache: kv delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package cachebus tells the other instances of the app which cache
+// entries one of them invalidated, so that caches kept in process memory
+// do not go on serving values that were changed elsewhere until they
+// expire. A write on one instance that deletes a data cache key or
+// invalidates a response cache tag publishes the keys or tags on a Redis
+// channel or NATS subject; every instance listens on it and evicts them
+// from its own memory.
+//
+// Delivery is best effort: messages published while an instance is not
+// listening, such as while it reconnects, are lost to it, and only the
+// TTLs bound how long it serves what it missed.
+package cachebus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/cache"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/httpcache"
+	"go-flylike-example/internal/id"
+)
+
+// ResponseCache is the name the response cache is published under.
+const ResponseCache = "responses"
+
+// retryDelay is how long the bus waits before listening again after the
+// connection failed.
+const retryDelay = 5 * time.Second
+
+// message is an invalidation published on the bus.
+type message struct {
+	// Origin is the instance that published it, which ignores it.
+	Origin string   `json:"origin"`
+	Cache  string   `json:"cache"`
+	Keys   []string `json:"keys"`
+}
+
+// transport carries messages between the instances.
+type transport interface {
+	publish(ctx context.Context, data []byte) error
+	// listen passes the messages received to fn until ctx is done or the
+	// connection fails.
+	listen(ctx context.Context, fn func(data []byte)) error
+	close() error
+}
+
+// EvictFunc drops keys from a cache of this instance.
+type EvictFunc func(ctx context.Context, keys ...string) error
+
+// Bus publishes and applies invalidations. A nil *Bus attaches nothing,
+// so caches can be attached unconditionally.
+type Bus struct {
+	t      transport
+	origin string
+
+	mu    sync.RWMutex
+	evict map[string]EvictFunc
+}
+
+// New returns the Bus of cfg, or nil when it is off. The redis backend
+// uses rdb.
+func New(cfg config.CacheBus, rdb *redis.Client) (*Bus, error) {
+	var t transport
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case "redis":
+		t = &redisTransport{client: rdb, channel: cfg.Channel}
+	case "nats":
+		nt, err := newNATS(cfg)
+		if err != nil {
+			return nil, err
+		}
+		t = nt
+	default:
+		return nil, fmt.Errorf("cachebus: unknown backend %q", cfg.Backend)
+	}
+	return &Bus{t: t, origin: id.New(), evict: make(map[string]EvictFunc)}, nil
+}
+
+// Handle has the invalidations of the cache called name applied by evict.
+func (b *Bus) Handle(name string, evict EvictFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.evict[name] = evict
+}
+
+// Publish tells the other instances to evict keys from the cache called
+// name. Failures are logged: the write that invalidated them has happened,
+// and the TTLs still bound what the others serve.
+func (b *Bus) Publish(ctx context.Context, name string, keys []string) {
+	data, err := json.Marshal(message{Origin: b.origin, Cache: name, Keys: keys})
+	if err == nil {
+		err = b.t.publish(ctx, data)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "cache invalidation not published", "cache", name, "error", err)
+	}
+}
+
+// Data publishes the deletions of c and applies those of other instances.
+func (b *Bus) Data(c *cache.Cache) {
+	if b == nil {
+		return
+	}
+	c.OnDelete(func(ctx context.Context, keys []string) { b.Publish(ctx, c.Name(), keys) })
+	b.Handle(c.Name(), c.Evict)
+}
+
+// Responses publishes the tags c invalidates and applies those of other
+// instances. A nil c is left alone.
+func (b *Bus) Responses(c *httpcache.Cache) {
+	if b == nil || c == nil {
+		return
+	}
+	c.OnInvalidate(func(ctx context.Context, tags []string) { b.Publish(ctx, ResponseCache, tags) })
+	b.Handle(ResponseCache, c.Evict)
+}
+
+// Run listens for the invalidations of other instances until ctx is done,
+// listening again whenever the connection fails, and then closes the
+// connection.
+func (b *Bus) Run(ctx context.Context) {
+	defer func() {
+		if err := b.t.close(); err != nil {
+			slog.Warn("cache bus not closed", "error", err)
+		}
+	}()
+	for {
+		err := b.t.listen(ctx, func(data []byte) { b.apply(ctx, data) })
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("cache bus disconnected", "error", err, "retry_in", retryDelay.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (b *Bus) apply(ctx context.Context, data []byte) {
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		slog.Warn("invalid cache invalidation", "error", err)
+		return
+	}
+	if m.Origin == b.origin {
+		return
+	}
+	b.mu.RLock()
+	evict := b.evict[m.Cache]
+	b.mu.RUnlock()
+	if evict == nil {
+		return
+	}
+	if err := evict(ctx, m.Keys...); err != nil {
+		slog.Warn("cache invalidation not applied", "cache", m.Cache, "error", err)
+	}
+}
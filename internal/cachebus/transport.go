@@ -0,0 +1,82 @@
+package cachebus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/config"
+)
+
+// redisTransport publishes on a channel of the shared Redis.
+type redisTransport struct {
+	client  *redis.Client
+	channel string
+}
+
+func (t *redisTransport) publish(ctx context.Context, data []byte) error {
+	return t.client.Publish(ctx, t.channel, data).Err()
+}
+
+func (t *redisTransport) listen(ctx context.Context, fn func(data []byte)) error {
+	sub := t.client.Subscribe(ctx, t.channel)
+	defer sub.Close()
+	// Receive waits for the subscription to be confirmed, so that a Redis
+	// that is down is reported rather than waited for.
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return fmt.Errorf("subscription to %s closed", t.channel)
+			}
+			fn([]byte(msg.Payload))
+		}
+	}
+}
+
+// close leaves the client, which is shared, open.
+func (t *redisTransport) close() error { return nil }
+
+// natsTransport publishes on a subject of a NATS server. Unlike the bus,
+// it uses core NATS: every instance must see every message, and none
+// needs them kept.
+type natsTransport struct {
+	nc      *nats.Conn
+	subject string
+}
+
+func newNATS(cfg config.CacheBus) (*natsTransport, error) {
+	nc, err := nats.Connect(cfg.URL, nats.Name("go-flylike-example"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("cachebus: connect to nats: %w", err)
+	}
+	return &natsTransport{nc: nc, subject: cfg.Channel}, nil
+}
+
+func (t *natsTransport) publish(_ context.Context, data []byte) error {
+	return t.nc.Publish(t.subject, data)
+}
+
+// listen subscribes until ctx is done; the connection reconnects by itself.
+func (t *natsTransport) listen(ctx context.Context, fn func(data []byte)) error {
+	sub, err := t.nc.Subscribe(t.subject, func(msg *nats.Msg) { fn(msg.Data) })
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	<-ctx.Done()
+	return nil
+}
+
+func (t *natsTransport) close() error {
+	t.nc.Close()
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package canary splits traffic between the stable version of the API and
+// a canary, so that a new version can take a growing share of real
+// requests without a separate edge proxy. Every request is assigned a
+// variant: callers can ask for one with a header or cookie, and the rest
+// are assigned by a hash of who they are, so that a user does not flip
+// between versions from one request to the next. Canary requests are
+// forwarded to a canary upstream, or handled by the alternate handlers
+// that routes pick with Switch, and counted per variant.
+package canary
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/httpclient"
+	"go-flylike-example/internal/proxy"
+)
+
+// The variants.
+const (
+	Stable = "stable"
+	Canary = "canary"
+)
+
+// HeaderVariant names the variant that answered a request.
+const HeaderVariant = "X-Variant"
+
+// The values that pin a request to a variant in the header or cookie.
+const (
+	pinCanary = "always"
+	pinStable = "never"
+)
+
+const variantKey = "canary.variant"
+
+// Router assigns requests to variants.
+type Router struct {
+	live    *config.Live
+	forward gin.HandlerFunc
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New returns a Router for the canary configuration of live, forwarding to
+// its upstream if it has one and recording on reg, or nil when canary
+// routing is off.
+func New(live *config.Live, m *httpclient.Metrics, reg prometheus.Registerer) (*Router, error) {
+	cfg := live.Load().Canary
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	r := &Router{
+		live: live,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "canary",
+			Name:      "requests_total",
+			Help:      "Requests routed by canary variant and status.",
+		}, []string{"variant", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "canary",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the requests routed, by canary variant.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"variant"}),
+	}
+	if cfg.Upstream != "" {
+		// The canary is told to keep forwarded requests, should it have
+		// canary routing on itself.
+		fwd, err := proxy.Forward(config.ProxyRoute{
+			Upstream:   cfg.Upstream,
+			Timeout:    cfg.Timeout,
+			SetHeaders: map[string]string{cfg.Header: pinStable},
+		}, m)
+		if err != nil {
+			return nil, err
+		}
+		r.forward = fwd
+	}
+	reg.MustRegister(r.requests, r.duration)
+	return r, nil
+}
+
+// Middleware assigns each request below the configured paths to a
+// variant and forwards canary requests to the upstream, if there is one,
+// instead of handling them here. It must run after authentication, which
+// identifies callers.
+func (r *Router) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := r.live.Load().Canary
+		if !below(c.Request.URL.Path, cfg.Paths) {
+			c.Next()
+			return
+		}
+		variant := assign(c, cfg)
+		c.Set(variantKey, variant)
+		c.Header(HeaderVariant, variant)
+
+		start := time.Now()
+		if variant == Canary && r.forward != nil {
+			r.forward(c)
+			c.Abort()
+		} else {
+			c.Next()
+		}
+		r.requests.WithLabelValues(variant, strconv.Itoa(c.Writer.Status())).Inc()
+		r.duration.WithLabelValues(variant).Observe(time.Since(start).Seconds())
+	}
+}
+
+// assign picks the variant of the request.
+func assign(c *gin.Context, cfg config.Canary) string {
+	pin := c.GetHeader(cfg.Header)
+	if pin == "" && cfg.Cookie != "" {
+		pin, _ = c.Cookie(cfg.Cookie)
+	}
+	switch strings.ToLower(pin) {
+	case pinCanary:
+		return Canary
+	case pinStable:
+		return Stable
+	}
+	if cfg.Percent <= 0 {
+		return Stable
+	}
+	h := fnv.New32a()
+	h.Write([]byte(caller(c)))
+	// Buckets of a hundredth of a percent.
+	if float64(h.Sum32()%10000) < cfg.Percent*100 {
+		return Canary
+	}
+	return Stable
+}
+
+// caller identifies who sent the request: the subject of its token, its
+// API key, or its address.
+func caller(c *gin.Context) string {
+	if claims, ok := auth.ClaimsFrom(c); ok {
+		return "user:" + claims.Subject
+	}
+	if key, ok := apikeys.FromContext(c); ok {
+		return "key:" + key.ID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func below(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Variant returns the variant the request was assigned, Stable when canary
+// routing is off or does not cover it.
+func Variant(c *gin.Context) string {
+	if v := c.GetString(variantKey); v != "" {
+		return v
+	}
+	return Stable
+}
+
+// Switch returns a handler running canary for requests assigned to the
+// canary variant and stable for the others, for routes with a new version
+// in this binary.
+func Switch(stable, canary gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Variant(c) == Canary {
+			canary(c)
+			return
+		}
+		stable(c)
+	}
+}
@@ -0,0 +1,76 @@
+// Package certs configures HTTPS for bare-VM deployments, either from a
+// certificate/key pair on disk or with automatic Let's Encrypt certificates,
+// and provides the plain HTTP handler for ACME challenges and redirects.
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"go-flylike-example/internal/config"
+)
+
+// Setup is the result of Configure.
+type Setup struct {
+	// TLSConfig is assigned to the HTTPS server.
+	TLSConfig *tls.Config
+	// Mode is "files" or "autocert", for logging.
+	Mode string
+
+	manager *autocert.Manager
+}
+
+// Configure returns the TLS setup for cfg, or nil when TLS is disabled.
+func Configure(cfg config.TLS) (*Setup, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("certs: load key pair: %w", err)
+		}
+		return &Setup{
+			Mode: "files",
+			TLSConfig: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{cert},
+			},
+		}, nil
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(cfg.AutocertDomains...),
+		Cache:      autocert.DirCache(cfg.AutocertCacheDir),
+		Email:      cfg.AutocertEmail,
+	}
+	tlsCfg := m.TLSConfig()
+	tlsCfg.MinVersion = tls.VersionTLS12
+	return &Setup{Mode: "autocert", TLSConfig: tlsCfg, manager: m}, nil
+}
+
+// RedirectHandler answers ACME HTTP-01 challenges (in autocert mode) and
+// permanently redirects every other request to HTTPS on httpsAddr's port.
+func (s *Setup) RedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+	if s.manager != nil {
+		return s.manager.HTTPHandler(redirect)
+	}
+	return redirect
+}
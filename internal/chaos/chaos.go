@@ -0,0 +1,231 @@
+// Package chaos injects faults into requests, so that the retry behaviour
+// and timeout budgets of clients can be tested against a real instance.
+// Faults come from rules set through the admin API, matched by route, or
+// from X-Chaos-* headers of the request itself. It is meant for
+// development and staging and is off unless configured.
+package chaos
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+)
+
+// Headers that inject a fault into the request carrying them, when allowed.
+const (
+	// HeaderLatency delays the request by a duration such as "250ms".
+	HeaderLatency = "X-Chaos-Latency"
+	// HeaderError answers with a status from Statuses.
+	HeaderError = "X-Chaos-Error"
+	// HeaderDrop, set to "true", closes the connection without a response.
+	HeaderDrop = "X-Chaos-Drop"
+)
+
+// maxLatency bounds injected delays.
+const maxLatency = time.Minute
+
+// kinds maps the statuses that can be injected to the errors producing them.
+var kinds = map[int]apperror.Kind{
+	http.StatusInternalServerError: apperror.KindInternal,
+	http.StatusBadGateway:          apperror.KindBadGateway,
+	http.StatusServiceUnavailable:  apperror.KindUnavailable,
+	http.StatusGatewayTimeout:      apperror.KindGatewayTimeout,
+	http.StatusTooManyRequests:     apperror.KindTooManyRequests,
+	http.StatusRequestTimeout:      apperror.KindRequestTimeout,
+}
+
+// Statuses lists the statuses that can be injected.
+func Statuses() []int {
+	return slices.Sorted(maps.Keys(kinds))
+}
+
+// Rule injects faults into the requests of a route. Latency plus a random
+// share of Jitter is added before the request is handled; then ErrorPercent
+// of the requests are answered with ErrorStatus (default 503) and
+// DropPercent have their connection closed instead.
+type Rule struct {
+	// Route is a route pattern such as "/api/v2/users/:id"; a trailing "*"
+	// matches every route starting with the rest, and "*" alone all of
+	// them.
+	Route string `json:"route" binding:"required"`
+	// Method limits the rule to one method; empty matches all.
+	Method       string   `json:"method,omitempty"`
+	Latency      Duration `json:"latency,omitempty"`
+	Jitter       Duration `json:"jitter,omitempty"`
+	ErrorPercent float64  `json:"error_percent,omitempty" binding:"gte=0,lte=100"`
+	ErrorStatus  int      `json:"error_status,omitempty"`
+	DropPercent  float64  `json:"drop_percent,omitempty" binding:"gte=0,lte=100"`
+}
+
+// Duration is a time.Duration written as a string such as "1.5s" in JSON.
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (r Rule) validate() error {
+	if r.Latency < 0 || r.Jitter < 0 || time.Duration(r.Latency+r.Jitter) > maxLatency {
+		return fmt.Errorf("chaos: latency and jitter of %s must add up to at most %s", r.Route, maxLatency)
+	}
+	if _, ok := kinds[r.ErrorStatus]; r.ErrorStatus != 0 && !ok {
+		return fmt.Errorf("chaos: error status of %s must be one of %v", r.Route, Statuses())
+	}
+	return nil
+}
+
+func (r Rule) matches(method, route string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Route, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return r.Route == route
+}
+
+// Injector holds the rules of this instance.
+type Injector struct {
+	headers bool
+
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// New returns an Injector without rules. With headers set any client can
+// inject faults into its own requests.
+func New(headers bool) *Injector {
+	return &Injector{headers: headers}
+}
+
+// Rules returns the rules in the order they are matched.
+func (in *Injector) Rules() []Rule {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return append([]Rule{}, in.rules...)
+}
+
+// SetRules replaces the rules; the first one matching a request applies.
+func (in *Injector) SetRules(rules []Rule) error {
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.rules = append([]Rule{}, rules...)
+	if len(rules) > 0 {
+		slog.Warn("chaos rules active", "rules", len(rules))
+	} else {
+		slog.Info("chaos rules cleared")
+	}
+	return nil
+}
+
+func (in *Injector) match(method, route string) (Rule, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	for _, r := range in.rules {
+		if r.matches(method, route) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// fault is what happens to one request.
+type fault struct {
+	latency time.Duration
+	status  int
+	drop    bool
+}
+
+func (in *Injector) fault(c *gin.Context) (fault, bool) {
+	var f fault
+	r, ok := in.match(c.Request.Method, c.FullPath())
+	if ok {
+		f.latency = time.Duration(r.Latency)
+		if r.Jitter > 0 {
+			f.latency += time.Duration(rand.Int64N(int64(r.Jitter)))
+		}
+		switch p := rand.Float64() * 100; {
+		case p < r.ErrorPercent:
+			f.status = r.ErrorStatus
+			if f.status == 0 {
+				f.status = http.StatusServiceUnavailable
+			}
+		case p < r.ErrorPercent+r.DropPercent:
+			f.drop = true
+		}
+	}
+	if in.headers {
+		if d, err := time.ParseDuration(c.GetHeader(HeaderLatency)); err == nil && d > 0 {
+			f.latency, ok = min(d, maxLatency), true
+		}
+		if s, err := strconv.Atoi(c.GetHeader(HeaderError)); err == nil {
+			if _, known := kinds[s]; known {
+				f.status, ok = s, true
+			}
+		}
+		if c.GetHeader(HeaderDrop) == "true" {
+			f.drop, ok = true, true
+		}
+	}
+	return f, ok
+}
+
+// Middleware applies the faults of each request. It has to run after
+// apperror.Middleware, which renders injected errors and lets dropped
+// connections be closed by net/http.
+func (in *Injector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := in.fault(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Header("X-Chaos", "injected")
+		if f.latency > 0 {
+			t := time.NewTimer(f.latency)
+			select {
+			case <-t.C:
+			case <-c.Request.Context().Done():
+				t.Stop()
+			}
+		}
+		switch {
+		case f.drop:
+			// Closes the connection, or resets the stream on HTTP/2 and
+			// HTTP/3, without a response.
+			panic(http.ErrAbortHandler)
+		case f.status != 0:
+			c.Error(apperror.New(kinds[f.status], "injected fault"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,262 @@
+// Package compression encodes responses with brotli or gzip when the
+// client accepts it. Bodies are streamed through the encoder; only the
+// first MinSize bytes are held back to decide whether compressing is worth
+// it.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+)
+
+// brotliLevel trades ratio for speed; responses are compressed per
+// request, unlike the frontend assets which are compressed once.
+const brotliLevel = 4
+
+// incompressible content types are already compressed or, for event
+// streams, must reach the client without delay.
+var incompressible = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+	"application/octet-stream", "application/vnd.apache.parquet", "text/event-stream",
+}
+
+var (
+	gzipPool   = sync.Pool{New: func() any { w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression); return w }}
+	brotliPool = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, brotliLevel) }}
+)
+
+// Middleware compresses responses of at least cfg.MinSize bytes for
+// clients whose Accept-Encoding allows it. Responses that already carry a
+// Content-Encoding, partial content and WebSocket upgrades pass through.
+func Middleware(cfg config.Compression) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enc := negotiate(c.GetHeader("Accept-Encoding"))
+		if !cfg.Enabled || enc == "" || c.Request.Method == http.MethodHead ||
+			c.GetHeader("Range") != "" || strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		w := &writer{ResponseWriter: c.Writer, encoding: enc, minSize: cfg.MinSize, status: http.StatusOK}
+		c.Writer = w
+		defer func() {
+			w.Close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// writer defers the decision to compress until MinSize bytes have been
+// written, the handler flushes, or the response ends.
+type writer struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	written bool
+	size    int
+	decided bool
+	buf     bytes.Buffer
+	enc     io.WriteCloser // nil when the body is sent as is
+}
+
+func (w *writer) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow only marks the header as written; it is sent once the
+// encoding is decided, because the decision changes it.
+func (w *writer) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.written = true
+	w.size += len(b)
+	if !w.decided {
+		w.buf.Write(b)
+		if w.buf.Len() < w.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection, for example
+// to lift the write deadline of a stream.
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *writer) Status() int   { return w.status }
+func (w *writer) Written() bool { return w.written }
+
+// Size reports the uncompressed body size written by the handler.
+func (w *writer) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.size
+}
+
+// Flush sends what is buffered. A stream flushed before reaching MinSize
+// is sent uncompressed.
+func (w *writer) Flush() {
+	if !w.decided {
+		w.minSize = max(w.minSize, w.buf.Len()+1)
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Close decides if the handler never reached MinSize, and finishes the
+// encoded stream.
+func (w *writer) Close() {
+	if !w.written {
+		return
+	}
+	if !w.decided {
+		_ = w.decide()
+	}
+	if w.enc == nil {
+		return
+	}
+	_ = w.enc.Close()
+	switch e := w.enc.(type) {
+	case *gzip.Writer:
+		e.Reset(io.Discard)
+		gzipPool.Put(e)
+	case *brotli.Writer:
+		e.Reset(io.Discard)
+		brotliPool.Put(e)
+	}
+	w.enc = nil
+}
+
+// decide picks the encoding, writes the header and the buffered bytes.
+func (w *writer) decide() error {
+	w.decided = true
+	h := w.Header()
+	if w.buf.Len() > 0 && w.buf.Len() >= w.minSize && w.status != http.StatusPartialContent && w.status >= http.StatusOK &&
+		h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" {
+		ct := h.Get("Content-Type")
+		if ct == "" {
+			ct = http.DetectContentType(w.buf.Bytes())
+			h.Set("Content-Type", ct)
+		}
+		if compressible(ct) {
+			w.start(h)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf = bytes.Buffer{}
+	return err
+}
+
+func (w *writer) start(h http.Header) {
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	// The encoded body differs byte for byte, so a strong validator would
+	// be wrong.
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	switch w.encoding {
+	case "br":
+		bw := brotliPool.Get().(*brotli.Writer)
+		bw.Reset(w.ResponseWriter)
+		w.enc = bw
+	case "gzip":
+		gw := gzipPool.Get().(*gzip.Writer)
+		gw.Reset(w.ResponseWriter)
+		w.enc = gw
+	}
+}
+
+func compressible(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt == "image/svg+xml" {
+		return true
+	}
+	for _, prefix := range incompressible {
+		if strings.HasPrefix(mt, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// negotiate picks brotli or gzip from an Accept-Encoding header by quality,
+// preferring brotli on a tie. It returns "" when neither is acceptable.
+func negotiate(header string) string {
+	q := map[string]float64{}
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[name] = weight
+	}
+	pick := func(enc string) float64 {
+		if v, ok := q[enc]; ok {
+			return v
+		}
+		return q["*"]
+	}
+	br, gz := pick("br"), pick("gzip")
+	switch {
+	case br > 0 && br >= gz:
+		return "br"
+	case gz > 0:
+		return "gzip"
+	}
+	return ""
+}
@@ -0,0 +1,1825 @@
+// Package config loads application settings from defaults, an optional
+// YAML/TOML file, environment variables and command-line flags.
+//
+// Sources are applied in increasing order of precedence:
+//
+//	defaults < config file < environment < flags
+//
+// so a flag always wins over an environment variable, which in turn wins
+// over the file.
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config is the fully resolved application configuration.
+type Config struct {
+	// File is the config file the configuration was loaded from, if any.
+	File string `yaml:"-"`
+	// Profile is the deployment profile whose overrides in the config file
+	// were applied, if any.
+	Profile string `yaml:"-"`
+	// Region is the region whose overrides in the config file were
+	// applied, if any: that the instance runs in.
+	Region string `yaml:"-"`
+
+	Addr     string `yaml:"addr"`
+	LogLevel string `yaml:"log_level"`
+	// LogDebugTTL is how long a level override or a route debug toggle set
+	// through the admin API lasts unless it says otherwise.
+	LogDebugTTL time.Duration `yaml:"log_debug_ttl"`
+	// DefaultLocale answers requests whose Accept-Language matches none
+	// of the message catalogs.
+	DefaultLocale string          `yaml:"default_locale"`
+	Timeouts      Timeouts        `yaml:"timeouts"`
+	Limits        Limits          `yaml:"limits"`
+	Features      map[string]bool `yaml:"features"`
+	// Rollouts limits enabled features to a percentage of users or
+	// tenants; features without an entry are on for everyone.
+	Rollouts    map[string]int `yaml:"rollouts"`
+	Database    Database       `yaml:"database"`
+	Auth        Auth           `yaml:"auth"`
+	Redis       Redis          `yaml:"redis"`
+	RateLimit   RateLimit      `yaml:"rate_limit"`
+	API         API            `yaml:"api"`
+	TLS         TLS            `yaml:"tls"`
+	Protocols   Protocols      `yaml:"protocols"`
+	Chaos       Chaos          `yaml:"chaos"`
+	Autostop    Autostop       `yaml:"autostop"`
+	Session     Session        `yaml:"session"`
+	Jobs        Jobs           `yaml:"jobs"`
+	GRPC        GRPC           `yaml:"grpc"`
+	Tenancy     Tenancy        `yaml:"tenancy"`
+	Admin       Admin          `yaml:"admin"`
+	Web         Web            `yaml:"web"`
+	Views       Views          `yaml:"views"`
+	Proxy       []ProxyRoute   `yaml:"proxy"`
+	Policies    []Policy       `yaml:"policies"`
+	CORS        CORS           `yaml:"cors"`
+	OIDC        OIDC           `yaml:"oidc"`
+	RBAC        RBAC           `yaml:"rbac"`
+	Idempotency Idempotency    `yaml:"idempotency"`
+	Restart     Restart        `yaml:"restart"`
+	Cache       Cache          `yaml:"cache"`
+	DataCache   DataCache      `yaml:"data_cache"`
+	Quota       Quota          `yaml:"quota"`
+	Realtime    Realtime       `yaml:"realtime"`
+	Presence    Presence       `yaml:"presence"`
+	Compression Compression    `yaml:"compression"`
+	Uploads     Uploads        `yaml:"uploads"`
+	Webhooks    Webhooks       `yaml:"webhooks"`
+	Mail        Mail           `yaml:"mail"`
+	Accounts    Accounts       `yaml:"accounts"`
+	GraphQL     GraphQL        `yaml:"graphql"`
+	Audit       Audit          `yaml:"audit"`
+	Bus         Bus            `yaml:"bus"`
+	BodyLog     BodyLog        `yaml:"body_log"`
+	Contract    Contract       `yaml:"contract"`
+	Sentry      Sentry         `yaml:"sentry"`
+	Scheduler   Scheduler      `yaml:"scheduler"`
+	Maintenance Maintenance    `yaml:"maintenance"`
+	IPFilter    IPFilter       `yaml:"ip_filter"`
+	Security    Security       `yaml:"security"`
+	// Preconditions configures optimistic concurrency on versioned
+	// resources.
+	Preconditions Preconditions `yaml:"preconditions"`
+	// Mirror configures copying live traffic to a shadow deployment.
+	Mirror Mirror `yaml:"mirror"`
+	// Plugins configures the request hooks loaded from scripts and WASM
+	// modules.
+	Plugins Plugins `yaml:"plugins"`
+	// SignedURLs configures links granting access without credentials.
+	SignedURLs SignedURLs `yaml:"signed_urls"`
+	// Batch configures the batch endpoint.
+	Batch Batch `yaml:"batch"`
+	// CacheBus configures telling other instances of cache invalidations.
+	CacheBus CacheBus `yaml:"cache_bus"`
+	// Canary configures sending part of the traffic to a canary version.
+	Canary Canary `yaml:"canary"`
+	// Imports configures importing files into resources.
+	Imports Imports `yaml:"imports"`
+	// Shedding configures refusing requests when the instance is
+	// overloaded.
+	Shedding Shedding `yaml:"shedding"`
+	// Admission configures queueing requests to the expensive route groups.
+	Admission Admission `yaml:"admission"`
+	// Secrets configures decrypting the values written encrypted.
+	Secrets Secrets `yaml:"secrets"`
+	// Geo configures locating clients.
+	Geo Geo `yaml:"geo"`
+	// Journal configures recording the work in flight for recovery.
+	Journal Journal `yaml:"journal"`
+	// SLO configures tracking the service level objectives of the routes.
+	SLO SLO `yaml:"slo"`
+	// RequestSigning configures authenticating requests signed by internal
+	// callers.
+	RequestSigning RequestSigning `yaml:"request_signing"`
+	// KV configures the embedded key-value store of single-node
+	// deployments.
+	KV KV `yaml:"kv"`
+	// Telemetry selects how metrics and logs are exported.
+	Telemetry Telemetry `yaml:"telemetry"`
+	// Bandwidth throttles downloads.
+	Bandwidth Bandwidth `yaml:"bandwidth"`
+	// TrustedProxies are the CIDR ranges or addresses of the proxies in
+	// front of the server. The client address is read from Fly-Client-IP
+	// or X-Forwarded-For only when the connecting peer is one of them; with
+	// none, it is always the peer.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// Security configures the security headers sent with every response of the
+// public listener; an empty value leaves its header out. CSPRoutes replaces
+// CSP below a path prefix, the longest match winning, and an empty policy
+// there sends none. With CSPReportOnly the policy is sent as
+// Content-Security-Policy-Report-Only, so browsers only report violations
+// to /csp-report, which logs them.
+type Security struct {
+	Enabled               bool              `yaml:"enabled"`
+	CSP                   string            `yaml:"csp"`
+	CSPReportOnly         bool              `yaml:"csp_report_only"`
+	CSPRoutes             map[string]string `yaml:"csp_routes"`
+	HSTSMaxAge            time.Duration     `yaml:"hsts_max_age"`
+	HSTSIncludeSubdomains bool              `yaml:"hsts_include_subdomains"`
+	HSTSPreload           bool              `yaml:"hsts_preload"`
+	ContentTypeOptions    string            `yaml:"content_type_options"`
+	FrameOptions          string            `yaml:"frame_options"`
+	ReferrerPolicy        string            `yaml:"referrer_policy"`
+	PermissionsPolicy     string            `yaml:"permissions_policy"`
+}
+
+// IPFilter restricts the client addresses served by the public listener
+// (Allow and Deny) and by the admin listener (AdminAllow). Entries are CIDR
+// ranges or single addresses; deny wins over allow, and an empty allow
+// list admits every address not denied.
+type IPFilter struct {
+	Allow      []string `yaml:"allow"`
+	Deny       []string `yaml:"deny"`
+	AdminAllow []string `yaml:"admin_allow"`
+}
+
+// Maintenance configures maintenance mode, in which every route except the
+// health probes and metrics answers 503 with a Retry-After of RetryAfter.
+// Readiness stays green for Grace after it is switched on, so that deploy
+// tooling can watch traffic drain before the platform takes the instance
+// out of rotation. Enabled is the state at startup and after a reload that
+// changes it; the admin API and SIGUSR1 switch it at runtime.
+type Maintenance struct {
+	Enabled    bool          `yaml:"enabled"`
+	RetryAfter time.Duration `yaml:"retry_after"`
+	Grace      time.Duration `yaml:"grace"`
+	Message    string        `yaml:"message"`
+}
+
+// Scheduler configures the in-process cron runner. Only the instance
+// holding the leader lease runs scheduled tasks; the lease is renewed well
+// before Lease runs out and taken over by another instance once it has.
+// Schedules replaces the cron expression of a task by name, and "off"
+// disables it.
+type Scheduler struct {
+	Enabled   bool              `yaml:"enabled"`
+	Lease     time.Duration     `yaml:"lease"`
+	Schedules map[string]string `yaml:"schedules"`
+}
+
+// Audit configures the audit log of mutating API requests. Redact names the
+// JSON members, compared case-insensitively and also matching as a "_"
+// suffix, whose values are replaced in recorded bodies; a body beyond
+// MaxBodyBytes is recorded as truncated instead.
+type Audit struct {
+	Enabled      bool     `yaml:"enabled"`
+	Redact       []string `yaml:"redact"`
+	MaxBodyBytes int      `yaml:"max_body_bytes"`
+}
+
+// Sentry configures error reporting, which is off until DSN is set. With
+// it, 5xx responses and recovered panics are sent with their request,
+// user and tags; Release defaults to the build version and commit, and
+// SampleRate is the fraction of errors sent.
+type Sentry struct {
+	DSN         string  `yaml:"dsn"`
+	Environment string  `yaml:"environment"`
+	Release     string  `yaml:"release"`
+	SampleRate  float64 `yaml:"sample_rate"`
+}
+
+// Autostop gives the machine back after it has been idle, without requests
+// or realtime connections, for After; zero disables it. Action is "exit", a graceful shutdown
+// with status 0 after which the Fly proxy's autostart boots the machine
+// on the next request, or "stop" or "suspend" through the Machines API at
+// APIURL with APIToken. A suspend first flushes error reports and logs and
+// closes idle database connections, which would be stale on resume.
+type Autostop struct {
+	After    time.Duration `yaml:"after"`
+	Action   string        `yaml:"action"`
+	APIURL   string        `yaml:"api_url"`
+	APIToken string        `yaml:"api_token"`
+}
+
+// Chaos enables fault injection for resilience testing in development and
+// staging; Validate refuses it in the prod profile. Rules are managed per
+// instance through the admin API, and with Headers any client can also
+// inject faults into its own requests with the X-Chaos-* headers.
+type Chaos struct {
+	Enabled bool `yaml:"enabled"`
+	Headers bool `yaml:"headers"`
+}
+
+// BodyLog configures the logging of request and response bodies, meant for
+// debugging client integrations in staging. SamplePercent of the requests
+// below one of the Paths prefixes, or of all requests when there are none,
+// are logged with bodies cut at MaxBytes and the JSON and form members
+// named in Redact blanked as in Audit.
+type BodyLog struct {
+	Enabled       bool     `yaml:"enabled"`
+	SamplePercent float64  `yaml:"sample_percent"`
+	MaxBytes      int      `yaml:"max_bytes"`
+	Paths         []string `yaml:"paths"`
+	Redact        []string `yaml:"redact"`
+}
+
+// Contract configures recording the fixtures of the contract tests, meant
+// for capturing real traffic in staging: SamplePercent of the requests
+// below one of the Paths prefixes, or of all requests when there are none,
+// are written to Dir with their responses, one JSON file each. Credentials
+// are left out and the JSON and form members and query parameters named in
+// Redact blanked; exchanges with a body over MaxBytes, streams and
+// WebSocket upgrades are not recorded. Recording is off without a Dir.
+type Contract struct {
+	Dir           string   `yaml:"dir"`
+	SamplePercent float64  `yaml:"sample_percent"`
+	Paths         []string `yaml:"paths"`
+	MaxBytes      int      `yaml:"max_bytes"`
+	Redact        []string `yaml:"redact"`
+}
+
+// Mirror configures traffic mirroring, for trying a new version against
+// real traffic before cutting over: SamplePercent of the requests below one
+// of the Paths prefixes, or of all requests when there are none, are sent
+// again to the shadow deployment at URL in the background and its
+// responses ignored. Credentials are stripped, with the StripHeaders, and
+// the JSON and form members and query parameters named in Redact blanked;
+// bodies over MaxBytes and WebSocket upgrades are not mirrored. Up to Queue
+// copies wait for one of Concurrency senders, each getting Timeout; more
+// are dropped so that a slow shadow never holds up production. Mirroring is
+// off without a URL.
+type Mirror struct {
+	URL           string        `yaml:"url"`
+	SamplePercent float64       `yaml:"sample_percent"`
+	Paths         []string      `yaml:"paths"`
+	MaxBytes      int           `yaml:"max_bytes"`
+	Redact        []string      `yaml:"redact"`
+	StripHeaders  []string      `yaml:"strip_headers"`
+	Queue         int           `yaml:"queue"`
+	Concurrency   int           `yaml:"concurrency"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+// SignedURLs configures signed links, which let anybody holding one use a
+// single endpoint for a limited time without credentials, such as to
+// download a shared upload. Links are signed with the first of Secrets,
+// "id:secret" entries of at least 32 bytes of secret, and accepted with any
+// of them, so keys are rotated by putting the new one first and removing
+// the old one once the links it signed expired. Links are absolute with
+// BaseURL, the public origin of the API, and valid for TTL unless asked
+// otherwise, at most MaxTTL. They are disabled without secrets.
+type SignedURLs struct {
+	Secrets []string      `yaml:"secrets"`
+	BaseURL string        `yaml:"base_url"`
+	TTL     time.Duration `yaml:"ttl"`
+	MaxTTL  time.Duration `yaml:"max_ttl"`
+}
+
+// Batch configures POST /api/v1/batch, which runs up to MaxItems requests
+// given in one body, Concurrency of them at a time.
+type Batch struct {
+	MaxItems    int `yaml:"max_items"`
+	Concurrency int `yaml:"concurrency"`
+}
+
+// CacheBus configures the broadcast of cache invalidations between
+// instances, which keeps the memory backends of the response and data
+// caches from serving what another instance changed. Backend is "" (off),
+// "redis", publishing on Channel of the shared Redis, or "nats", the
+// subject Channel of a NATS server at URL.
+type CacheBus struct {
+	Backend string `yaml:"backend"`
+	Channel string `yaml:"channel"`
+	URL     string `yaml:"url"`
+}
+
+// Canary configures progressive rollouts. Requests below one of Paths, or
+// all requests when there are none, go to the canary variant when they
+// send Header or Cookie with the value "always", and to the stable one
+// with "never"; of the others, Percent go to the canary, chosen by caller
+// so that each one stays on a variant. The canary is the upstream at
+// Upstream, which gets Timeout, or, without one, the alternate handlers
+// routes pick with canary.Switch. Percent and the routing are reloadable.
+type Canary struct {
+	Enabled  bool          `yaml:"enabled"`
+	Percent  float64       `yaml:"percent"`
+	Header   string        `yaml:"header"`
+	Cookie   string        `yaml:"cookie"`
+	Paths    []string      `yaml:"paths"`
+	Upstream string        `yaml:"upstream"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// Imports configures file imports. Files are at most MaxBytes, and an
+// import reports at most MaxErrors row errors.
+type Imports struct {
+	MaxBytes  int64 `yaml:"max_bytes"`
+	MaxErrors int   `yaml:"max_errors"`
+}
+
+// Shedding configures load shedding. The instance is under pressure once
+// it runs more than MaxGoroutines goroutines, uses more than MemoryRatio of
+// GOMEMLIMIT, if one is set, or answers normal requests slower than
+// MaxLatency at the 99th percentile over Window; a zero threshold is not
+// checked. Under pressure, low-priority requests, those below LowPaths or
+// sending X-Priority: low, get 503 with a Retry-After of RetryAfter, and
+// normal ones too once the pressure reaches NormalAt times a threshold.
+// Requests below CriticalPaths, and the health probes, are always
+// admitted. Everything but Enabled is reloadable.
+type Shedding struct {
+	Enabled       bool          `yaml:"enabled"`
+	MaxGoroutines int           `yaml:"max_goroutines"`
+	MemoryRatio   float64       `yaml:"memory_ratio"`
+	MaxLatency    time.Duration `yaml:"max_latency"`
+	Window        time.Duration `yaml:"window"`
+	NormalAt      float64       `yaml:"normal_at"`
+	RetryAfter    time.Duration `yaml:"retry_after"`
+	LowPaths      []string      `yaml:"low_paths"`
+	CriticalPaths []string      `yaml:"critical_paths"`
+}
+
+// Admission configures the admission queue of the expensive route groups:
+// exports, imports, result streams and uploads. The requests of
+// each priority class, those of load shedding, run at most Limit at a time
+// across the groups, so bulk traffic cannot take the slots of interactive
+// requests; at most Queue more wait, each for at most Wait, before getting
+// 503. Critical requests are never queued. Only Wait is reloadable.
+type Admission struct {
+	Enabled bool           `yaml:"enabled"`
+	Low     AdmissionClass `yaml:"low"`
+	Normal  AdmissionClass `yaml:"normal"`
+}
+
+// AdmissionClass bounds the requests of one priority class.
+type AdmissionClass struct {
+	Limit int           `yaml:"limit"`
+	Queue int           `yaml:"queue"`
+	Wait  time.Duration `yaml:"wait"`
+}
+
+// Secrets configures decrypting the values of the configuration written
+// as enc:<scheme>:<ciphertext>, in the file, the environment or flags:
+// "age" values with the identities of the AgeIdentities files, several
+// while rotating keys, and "awskms" values with AWS KMS in KMS.Region.
+// Decrypted values are scrubbed from logs and the admin config endpoint.
+// The section itself is read as is, and values are decrypted again on
+// every reload.
+type Secrets struct {
+	AgeIdentities []string   `yaml:"age_identities"`
+	KMS           SecretsKMS `yaml:"kms"`
+}
+
+// SecretsKMS configures AWS KMS; Endpoint replaces the regional one.
+type SecretsKMS struct {
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+}
+
+// Geo configures locating clients by their address, in the MaxMind
+// GeoIP2 or GeoLite2 database at Database, a City or Country file, or the
+// one embedded at build time, and with Headers by the location headers of
+// the CDN in front, Cloudflare, CloudFront, Vercel or App Engine, which
+// clients could forge without one. Regions are the regions the app runs
+// in, for the nearest-region hints; all known regions when empty. Only
+// Database requires a restart.
+type Geo struct {
+	Database string   `yaml:"database"`
+	Headers  bool     `yaml:"headers"`
+	Regions  []string `yaml:"regions"`
+}
+
+// Journal configures the recovery journal: the work in flight, running
+// jobs, open transactions and events not yet handled, is written every
+// Interval while it changes and once more on shutdown, to Dir or, when
+// Bucket is set, to that bucket of the uploads object storage, which
+// outlives the machine. The next start of the instance hands back what the
+// last journal names. Fatal errors are written to Dir too. An empty Dir
+// turns the journal off.
+type Journal struct {
+	Dir      string        `yaml:"dir"`
+	Bucket   string        `yaml:"bucket"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SLO configures tracking the service level objectives the routes
+// declare: the error budget of each is that of the last Period, of at
+// most 90 days. It requires a restart.
+type SLO struct {
+	Period time.Duration `yaml:"period"`
+}
+
+// RequestSigning configures authenticating the requests of internal
+// callers by an HMAC signature over the method, path, query, host, body
+// and time of the request. Secrets are "id:secret" entries of at least 32
+// bytes of secret; a request signed with one is authenticated as
+// "service:<id>". Keys are rotated by adding the new one, moving the
+// caller to it and removing the old one. Requests signed more than
+// Tolerance before or after now are refused. It is disabled without
+// secrets and requires a restart.
+type RequestSigning struct {
+	Secrets   []string      `yaml:"secrets"`
+	Tolerance time.Duration `yaml:"tolerance"`
+}
+
+// Telemetry selects how metrics leave the process: Metrics is
+// "prometheus", scraped from /metrics, "otlp", pushed every Interval over
+// OTLP/HTTP to the collector of the standard OTEL_EXPORTER_OTLP_*
+// variables, for platforms that cannot scrape, or "both". With Logs, log
+// records are pushed to the collector too, besides being written to
+// stdout. It requires a restart.
+type Telemetry struct {
+	Metrics  string        `yaml:"metrics"`
+	Interval time.Duration `yaml:"interval"`
+	Logs     bool          `yaml:"logs"`
+}
+
+// Scraped reports whether metrics are served at /metrics.
+func (t Telemetry) Scraped() bool {
+	return t.Metrics != "otlp"
+}
+
+// Pushed reports whether metrics are pushed over OTLP.
+func (t Telemetry) Pushed() bool {
+	return t.Metrics != "prometheus"
+}
+
+// Bandwidth throttles the responses of the routes of exports and streams,
+// counted as sent after compression: each connection to PerConnection
+// bytes per second and all of them together to Global, with bursts of one
+// second's worth. Zero leaves either unlimited. It requires a restart.
+type Bandwidth struct {
+	PerConnection int64 `yaml:"per_connection"`
+	Global        int64 `yaml:"global"`
+}
+
+// KV configures the embedded key-value store, a bbolt file at Path that
+// keeps what is otherwise kept in Redis, such as sessions, cached values
+// and responses, feature flag overrides and the webhook deliveries seen,
+// across restarts when no Redis is configured, so that a single node needs
+// no external services. With BackupBucket, a snapshot of the file is
+// copied to that bucket of the uploads object storage every BackupInterval
+// and on shutdown, the last BackupKeep are kept, and the latest is
+// restored when the file is missing at startup. An empty Path turns it
+// off. It requires a restart.
+type KV struct {
+	Path           string        `yaml:"path"`
+	BackupBucket   string        `yaml:"backup_bucket"`
+	BackupInterval time.Duration `yaml:"backup_interval"`
+	BackupKeep     int           `yaml:"backup_keep"`
+}
+
+// Plugins configures plugins, request hooks that operators write as Lua
+// scripts (.lua) or WebAssembly modules (.wasm) and load from Files at
+// startup, running in the order listed. Each hook call gets Timeout, and
+// a WASM module at most MaxMemory bytes of memory.
+type Plugins struct {
+	Files     []string      `yaml:"files"`
+	Timeout   time.Duration `yaml:"timeout"`
+	MaxMemory int           `yaml:"max_memory"`
+}
+
+// Bus configures the message broker that domain events are published to
+// and consumers read from. Backend is "memory", which only reaches the
+// consumers of this process and loses what is in flight on exit, "nats",
+// a JetStream server at URL whose Stream holds every topic, or "kafka",
+// the Brokers of a cluster. Consumers handle up to Concurrency messages at
+// a time, each within Timeout; failures are redelivered after RetryDelay,
+// doubling per attempt, and a message failing MaxAttempts times is moved to
+// the dead-letter topic. Events written to the outbox are relayed to the
+// broker every OutboxPollInterval, up to OutboxBatch at a time.
+type Bus struct {
+	Backend     string        `yaml:"backend"`
+	URL         string        `yaml:"url"`
+	Stream      string        `yaml:"stream"`
+	Brokers     []string      `yaml:"brokers"`
+	Concurrency int           `yaml:"concurrency"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	Timeout     time.Duration `yaml:"timeout"`
+	RetryDelay  time.Duration `yaml:"retry_delay"`
+
+	OutboxPollInterval time.Duration `yaml:"outbox_poll_interval"`
+	OutboxBatch        int           `yaml:"outbox_batch"`
+}
+
+// GraphQL configures the /graphql endpoint, which serves the REST domain
+// model behind the same authentication and middleware. MaxComplexity
+// bounds the cost of one query, each selected field counting one and list
+// fields their limit times their selection. Playground serves GraphiQL on
+// GET /graphql and is meant for development only.
+type GraphQL struct {
+	Enabled       bool `yaml:"enabled"`
+	Playground    bool `yaml:"playground"`
+	MaxComplexity int  `yaml:"max_complexity"`
+}
+
+// Accounts configures self-service user accounts. Registration opens
+// sign-up to anyone; with RequireVerified a password only logs in once its
+// email address is verified. Verification and password reset emails link
+// to LinkBaseURL, the frontend that posts the token back to the API, and
+// their tokens expire after VerifyTTL and ResetTTL.
+type Accounts struct {
+	Registration    bool          `yaml:"registration"`
+	RequireVerified bool          `yaml:"require_verified"`
+	LinkBaseURL     string        `yaml:"link_base_url"`
+	VerifyTTL       time.Duration `yaml:"verify_ttl"`
+	ResetTTL        time.Duration `yaml:"reset_ttl"`
+}
+
+// Mail configures outgoing email. Provider is "log" (messages are only
+// logged, for development), "smtp", "ses", "sendgrid" or "mailgun"; each
+// uses its own block of settings below. Messages are sent by the job queue,
+// MaxAttempts times at most, and every attempt gets Timeout.
+type Mail struct {
+	Provider    string        `yaml:"provider"`
+	From        string        `yaml:"from"`
+	Timeout     time.Duration `yaml:"timeout"`
+	MaxAttempts int           `yaml:"max_attempts"`
+
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	// SMTPImplicitTLS connects with TLS from the start, as on port 465;
+	// otherwise STARTTLS is used when the server offers it.
+	SMTPImplicitTLS bool `yaml:"smtp_implicit_tls"`
+
+	// APIKey authenticates with SendGrid and Mailgun; Domain is the
+	// Mailgun sending domain. Endpoint replaces the provider's API base
+	// URL, e.g. https://api.eu.mailgun.net for Mailgun's EU region.
+	APIKey   string `yaml:"api_key"`
+	Domain   string `yaml:"domain"`
+	Endpoint string `yaml:"endpoint"`
+
+	// SES credentials and region.
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// Webhooks configures outbound webhook delivery. Each attempt gets Timeout
+// to be answered with a 2xx; a delivery is dead-lettered after MaxAttempts.
+// Subscriber URLs resolving to loopback, private or link-local addresses
+// are refused unless AllowPrivate is set, which local development needs.
+//
+// For inbound webhooks, Tolerance bounds the clock skew accepted on signed
+// timestamps. GitHubSecrets enables the GitHub receiver at
+// /webhooks/github; several may be set while rotating.
+type Webhooks struct {
+	Timeout       time.Duration `yaml:"timeout"`
+	MaxAttempts   int           `yaml:"max_attempts"`
+	AllowPrivate  bool          `yaml:"allow_private"`
+	Tolerance     time.Duration `yaml:"tolerance"`
+	GitHubSecrets []string      `yaml:"github_secrets"`
+}
+
+// Uploads configures file uploads to S3-compatible object storage such as
+// Tigris or MinIO; they are disabled until Bucket is set. Files larger than
+// MaxBytes or whose sniffed type is not in AllowedTypes ("image/*" style
+// wildcards allowed) are refused. Download URLs are signed for URLTTL.
+// Timeout replaces the handler and read timeouts for uploads.
+//
+// Stored files are then processed by jobs, each step retried up to
+// ProcessAttempts times: scanned by the ClamAV daemon at ClamAVAddr
+// ("host:port") when set, images given a thumbnail fitting ThumbnailSize
+// pixels, none with zero, and upload.completed published.
+type Uploads struct {
+	Endpoint        string        `yaml:"endpoint"`
+	Region          string        `yaml:"region"`
+	Bucket          string        `yaml:"bucket"`
+	AccessKeyID     string        `yaml:"access_key_id"`
+	SecretAccessKey string        `yaml:"secret_access_key"`
+	MaxBytes        int64         `yaml:"max_bytes"`
+	AllowedTypes    []string      `yaml:"allowed_types"`
+	URLTTL          time.Duration `yaml:"url_ttl"`
+	Timeout         time.Duration `yaml:"timeout"`
+	ThumbnailSize   int           `yaml:"thumbnail_size"`
+	ClamAVAddr      string        `yaml:"clamav_addr"`
+	ProcessAttempts int           `yaml:"process_attempts"`
+}
+
+// Compression configures gzip and brotli response encoding. Bodies shorter
+// than MinSize bytes are sent as is.
+type Compression struct {
+	Enabled bool `yaml:"enabled"`
+	MinSize int  `yaml:"min_size"`
+}
+
+// Cache configures the shared cache of full GET responses. Backend is
+// "memory" (per instance), "redis" or "kv", the embedded store; TTL
+// applies to routes that do not set their own. Conditional GET with ETags
+// is always on.
+type Cache struct {
+	Enabled bool          `yaml:"enabled"`
+	Backend string        `yaml:"backend"`
+	TTL     time.Duration `yaml:"ttl"`
+}
+
+// DataCache configures the cache of values that repositories look up on
+// every request, such as API keys. Backend is "memory" (per instance),
+// "redis" or "kv", the embedded store; each TTL is shortened by a random
+// fraction of up to Jitter so that entries written together do not expire
+// together. Codec is "json" or "msgpack".
+type DataCache struct {
+	Enabled bool          `yaml:"enabled"`
+	Backend string        `yaml:"backend"`
+	TTL     time.Duration `yaml:"ttl"`
+	Jitter  float64       `yaml:"jitter"`
+	Codec   string        `yaml:"codec"`
+}
+
+// Quota enforces monthly usage limits per tenant and per user. Backend
+// keeps the counters: "sql", the authoritative default that billing reads,
+// or "redis", which costs less per request but expires old months. A
+// tenant's quota.requests, quota.storage and quota.jobs settings override
+// the Tenant limits.
+type Quota struct {
+	Enabled bool        `yaml:"enabled"`
+	Backend string      `yaml:"backend"`
+	Tenant  QuotaLimits `yaml:"tenant"`
+	User    QuotaLimits `yaml:"user"`
+}
+
+// QuotaLimits are the monthly limits of one tenant or user; 0 leaves a
+// metric unlimited. Storage counts the bytes uploaded in the month.
+type QuotaLimits struct {
+	Requests int64 `yaml:"requests"`
+	Storage  int64 `yaml:"storage"`
+	Jobs     int64 `yaml:"jobs"`
+}
+
+// Realtime configures the realtime endpoints. PollTimeout is how long a
+// long poll of /poll is held while no event arrives; keep it below the
+// idle timeout of the proxies in between.
+type Realtime struct {
+	PollTimeout time.Duration `yaml:"poll_timeout"`
+}
+
+// Presence tracks the users connected to the realtime endpoints per
+// channel. A user whose connections were not seen for TTL has left; the
+// presence is kept in Redis when REDIS_URL is set, else in memory.
+type Presence struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+}
+
+// Restart configures zero-downtime upgrades: on SIGUSR2 or POST
+// /admin/restart the binary is started again with the listening sockets
+// and the old process drains once the new one is ready within Timeout.
+// Leave it off where the process is PID 1 of a container, which would
+// take the container down with it. PIDFile tracks the serving process.
+type Restart struct {
+	Enabled bool          `yaml:"enabled"`
+	PIDFile string        `yaml:"pid_file"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Idempotency configures Idempotency-Key handling. Responses are replayed
+// for TTL; Lock bounds how long an unfinished request, for example on a
+// crashed instance, keeps its key reserved.
+type Idempotency struct {
+	TTL  time.Duration `yaml:"ttl"`
+	Lock time.Duration `yaml:"lock"`
+}
+
+// Preconditions configures If-Match handling. Writes to versioned
+// resources always honour If-Match; with Required they are refused with 428
+// Precondition Required without it, so that no client can overwrite a
+// change it has not seen.
+type Preconditions struct {
+	Required bool `yaml:"required"`
+}
+
+// RBAC configures role-based access control. Admins are subjects granted
+// the admin role at startup, so that somebody can assign the others.
+type RBAC struct {
+	Admins []string `yaml:"admins"`
+}
+
+// OIDC configures social login through the authorization-code flow.
+// Providers are keyed by the name used in /auth/oidc/:provider; "google"
+// and "github" have built-in endpoints, others need an IssuerURL for OpenID
+// Connect discovery. RedirectBaseURL is the public origin the provider
+// sends users back to.
+type OIDC struct {
+	RedirectBaseURL string                  `yaml:"redirect_base_url"`
+	Providers       map[string]OIDCProvider `yaml:"providers"`
+}
+
+// OIDCProvider holds the client registration with one identity provider.
+// Empty Scopes request the provider's defaults.
+type OIDCProvider struct {
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	IssuerURL    string   `yaml:"issuer_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// CORS configures cross-origin access for browsers. AllowedOrigins entries
+// are exact origins, "*", wildcards such as "https://*.example.com", or
+// regular expressions prefixed with "~". No origins disables CORS.
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+	// Policies are further CORS settings by name, for "cors:<name>"
+	// route policies. They apply on top of the settings above.
+	Policies map[string]CORS `yaml:"policies"`
+}
+
+// ProxyRoute forwards every request below Prefix to Upstream. Timeout is
+// the budget for the whole exchange including retries (zero uses the
+// proxy default); Retries applies to idempotent requests without a body
+// that failed to connect or got 429/502/503/504. SetHeaders and
+// RemoveHeaders rewrite the outgoing request.
+type ProxyRoute struct {
+	Prefix        string            `yaml:"prefix"`
+	Upstream      string            `yaml:"upstream"`
+	StripPrefix   bool              `yaml:"strip_prefix"`
+	Timeout       time.Duration     `yaml:"timeout"`
+	Retries       int               `yaml:"retries"`
+	SetHeaders    map[string]string `yaml:"set_headers"`
+	RemoveHeaders []string          `yaml:"remove_headers"`
+}
+
+// Policy attaches middleware to the requests whose path matches Match, a
+// pattern of slash-separated segments in path.Match syntax where a final
+// "*" stands for any number of segments, so that "/api/v1/admin/*"
+// covers everything below /api/v1/admin. Methods, when set, narrows the
+// policy to those methods. Require lists what matching requests go
+// through, in order: "auth", "rbac:<permission>", "scope:<api key
+// scope>", "ratelimit:<name of a rate_limit policy>", "cors:<name of a
+// cors policy>" and "cache" or "cache:<ttl>".
+type Policy struct {
+	Match   string   `yaml:"match"`
+	Methods []string `yaml:"methods"`
+	Require []string `yaml:"require"`
+}
+
+// Web configures serving the embedded frontend. In SPA mode unknown paths
+// outside the API fall back to index.html.
+type Web struct {
+	Enabled bool `yaml:"enabled"`
+	SPA     bool `yaml:"spa"`
+}
+
+// Views configures the server-rendered pages below /ui. Templates and
+// assets are embedded unless Dir names a directory laid out like
+// internal/views, whose files are then read anew on every request so that
+// edits show without a rebuild; it is meant for development.
+type Views struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"`
+}
+
+// Admin configures the private listener for profiling and debug endpoints.
+// An empty Addr disables it; a non-loopback Addr, such as
+// fly-local-6pn:6060 on the private network, requires Token or client
+// certificates. With Metrics the Prometheus metrics move there from the
+// public listener.
+//
+// With CertFile and KeyFile the listener serves HTTPS, and with
+// ClientCAFile it also requires client certificates signed by one of the
+// CAs in that bundle. ClientSANs, when set, further limits them to those
+// with a DNS, URI or email SAN matching one of its path.Match patterns,
+// such as *.internal or spiffe://example.org/billing/*.
+type Admin struct {
+	Addr         string   `yaml:"addr"`
+	Token        string   `yaml:"token"`
+	Metrics      bool     `yaml:"metrics"`
+	CertFile     string   `yaml:"cert_file"`
+	KeyFile      string   `yaml:"key_file"`
+	ClientCAFile string   `yaml:"client_ca_file"`
+	ClientSANs   []string `yaml:"client_sans"`
+}
+
+// Tenancy configures tenant resolution for the API. The tenant slug is
+// taken from Header when present, otherwise from the leftmost label of a
+// Host below BaseDomain (acme.example.com with base example.com). Without
+// Required, requests that name no tenant use the default tenant.
+type Tenancy struct {
+	Enabled    bool   `yaml:"enabled"`
+	BaseDomain string `yaml:"base_domain"`
+	Header     string `yaml:"header"`
+	Required   bool   `yaml:"required"`
+}
+
+// GRPC configures the gRPC listener that runs next to the HTTP server.
+// An empty Addr disables it.
+type GRPC struct {
+	Addr string `yaml:"addr"`
+}
+
+// Jobs configures the background worker pool. Lease is how long a running
+// job is reserved before another worker may assume its owner died.
+type Jobs struct {
+	Concurrency  int           `yaml:"concurrency"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	Lease        time.Duration `yaml:"lease"`
+}
+
+// Session configures cookie-based sessions. They are stored in Redis when
+// a Redis URL is set and in process memory otherwise.
+type Session struct {
+	CookieName string        `yaml:"cookie_name"`
+	TTL        time.Duration `yaml:"ttl"`
+	Secure     bool          `yaml:"secure"`
+	Domain     string        `yaml:"domain"`
+}
+
+// TLS enables serving HTTPS directly. CertFile/KeyFile take precedence over
+// AutocertDomains, which obtains certificates from Let's Encrypt via the
+// HTTP-01 challenge. RedirectAddr runs a plain HTTP listener answering the
+// challenge and redirecting everything else to HTTPS; empty disables it.
+type TLS struct {
+	CertFile         string   `yaml:"cert_file"`
+	KeyFile          string   `yaml:"key_file"`
+	AutocertDomains  []string `yaml:"autocert_domains"`
+	AutocertEmail    string   `yaml:"autocert_email"`
+	AutocertCacheDir string   `yaml:"autocert_cache_dir"`
+	RedirectAddr     string   `yaml:"redirect_addr"`
+}
+
+// Enabled reports whether HTTPS is configured.
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || len(t.AutocertDomains) > 0
+}
+
+// Protocols configures the HTTP versions served on Addr besides HTTP/1.1.
+// With TLS, HTTP/2 is always negotiated; H2C also serves it without TLS,
+// for proxies and internal services that speak it with prior knowledge.
+// HTTP3 additionally serves HTTP/3 over QUIC on the UDP port of HTTP3Addr
+// (default: Addr) and advertises it in Alt-Svc. It is experimental,
+// requires TLS and cannot be handed over by zero-downtime restarts.
+type Protocols struct {
+	H2C       bool   `yaml:"h2c"`
+	HTTP3     bool   `yaml:"http3"`
+	HTTP3Addr string `yaml:"http3_addr"`
+}
+
+// API configures the versioned REST API. A zero V1DeprecatedAt leaves v1
+// undeprecated; once V1SunsetAt has passed v1 answers 410 Gone.
+type API struct {
+	V1DeprecatedAt time.Time `yaml:"v1_deprecated_at"`
+	V1SunsetAt     time.Time `yaml:"v1_sunset_at"`
+	DocsURL        string    `yaml:"docs_url"`
+}
+
+// Redis configures the shared Redis client. Features backed by Redis are
+// only available when URL is set.
+type Redis struct {
+	URL string `yaml:"url"`
+}
+
+// RateLimit configures the token-bucket limiter: Rate tokens are added per
+// second up to Burst. Backend is "memory" (per instance) or "redis"
+// (shared across instances). Policies are further limits by name, for
+// "ratelimit:<name>" route policies, with buckets of their own.
+type RateLimit struct {
+	Enabled  bool                       `yaml:"enabled"`
+	Backend  string                     `yaml:"backend"`
+	Rate     float64                    `yaml:"rate"`
+	Burst    int                        `yaml:"burst"`
+	Policies map[string]RateLimitPolicy `yaml:"policies"`
+}
+
+// RateLimitPolicy is a named token bucket: Rate tokens per second up to
+// Burst.
+type RateLimitPolicy struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Auth configures JWT issuance. RS256 is used when a private key file is
+// set, HS256 with JWTSecret otherwise; tokens signed with either configured
+// key are accepted.
+type Auth struct {
+	JWTSecret      string        `yaml:"jwt_secret"`
+	PrivateKeyFile string        `yaml:"private_key_file"`
+	PublicKeyFile  string        `yaml:"public_key_file"`
+	Issuer         string        `yaml:"issuer"`
+	AccessTTL      time.Duration `yaml:"access_ttl"`
+	RefreshTTL     time.Duration `yaml:"refresh_ttl"`
+	DemoUser       string        `yaml:"demo_user"`
+	DemoPassword   string        `yaml:"demo_password"`
+}
+
+// Database configures the store connection pool and migrations. URL
+// schemes postgres:// and postgresql:// select Postgres; sqlite:// (or a
+// bare path) selects SQLite.
+//
+// Outside the primary region, reads use ReplicaURL when set; "{region}"
+// in it is replaced with FLY_REGION so one value serves every region.
+// Reads fall back to URL while the replica is unreachable or more than
+// MaxReplicaLag behind. WriteMode "replay" sends writes to the primary
+// region with fly-replay; "forward" writes over the network to URL from
+// any region, which needs a database reachable from everywhere (not
+// LiteFS).
+//
+// With AutoMigrate, instances take a lock to migrate, so that one applies
+// the migrations while the others wait for it; without, they wait until
+// another brings the schema to their version. Either wait is bounded by
+// MigrationTimeout. MigrateOnly, set by --migrate-only, exits once the
+// schema is migrated, for release commands of deploy pipelines.
+type Database struct {
+	URL              string        `yaml:"url"`
+	ReplicaURL       string        `yaml:"replica_url"`
+	MaxReplicaLag    time.Duration `yaml:"max_replica_lag"`
+	WriteMode        string        `yaml:"write_mode"`
+	MaxOpenConns     int           `yaml:"max_open_conns"`
+	MaxIdleConns     int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time"`
+	AutoMigrate      bool          `yaml:"auto_migrate"`
+	MigrationTimeout time.Duration `yaml:"migration_timeout"`
+	MigrateOnly      bool          `yaml:"-"`
+}
+
+// Timeouts groups the HTTP server and lifecycle timeouts. A zero value
+// disables the corresponding server timeout. Handler bounds the time an
+// API handler may take before the request fails with 504.
+type Timeouts struct {
+	ReadHeader time.Duration `yaml:"read_header"`
+	Read       time.Duration `yaml:"read"`
+	Write      time.Duration `yaml:"write"`
+	Idle       time.Duration `yaml:"idle"`
+	Handler    time.Duration `yaml:"handler"`
+	Shutdown   time.Duration `yaml:"shutdown"`
+}
+
+// Limits bounds request sizes. Bodies larger than MaxBodyBytes are refused
+// with 413.
+type Limits struct {
+	MaxBodyBytes   int64 `yaml:"max_body_bytes"`
+	MaxHeaderBytes int   `yaml:"max_header_bytes"`
+}
+
+// Default returns the configuration used when no other source sets a value.
+func Default() *Config {
+	return &Config{
+		Addr:          ":9090",
+		LogLevel:      "info",
+		LogDebugTTL:   15 * time.Minute,
+		DefaultLocale: "en",
+		Timeouts: Timeouts{
+			ReadHeader: 10 * time.Second,
+			Read:       60 * time.Second,
+			Write:      60 * time.Second,
+			Idle:       120 * time.Second,
+			Handler:    30 * time.Second,
+			Shutdown:   15 * time.Second,
+		},
+		Limits: Limits{
+			MaxBodyBytes:   1 << 20,
+			MaxHeaderBytes: 1 << 20,
+		},
+		Features: map[string]bool{},
+		Database: Database{
+			URL:              "sqlite://data/app.db",
+			MaxReplicaLag:    5 * time.Second,
+			WriteMode:        "replay",
+			MaxOpenConns:     10,
+			MaxIdleConns:     5,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+			AutoMigrate:      true,
+			MigrationTimeout: 10 * time.Minute,
+		},
+		Auth: Auth{
+			Issuer:     "go-flylike-example",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Backend: "memory",
+			Rate:    10,
+			Burst:   20,
+		},
+		TLS: TLS{
+			AutocertCacheDir: "data/autocert",
+			RedirectAddr:     ":80",
+		},
+		Session: Session{
+			CookieName: "sid",
+			TTL:        24 * time.Hour,
+			Secure:     true,
+		},
+		Jobs: Jobs{
+			Concurrency:  4,
+			PollInterval: time.Second,
+			MaxAttempts:  5,
+			Lease:        5 * time.Minute,
+		},
+		GRPC:     GRPC{Addr: "127.0.0.1:9091"},
+		Tenancy:  Tenancy{Header: "X-Tenant"},
+		Admin:    Admin{Addr: "127.0.0.1:6060"},
+		Web:      Web{Enabled: true, SPA: true},
+		Views:    Views{Enabled: true},
+		Restart:  Restart{Timeout: time.Minute},
+		Cache:    Cache{Backend: "memory", TTL: 30 * time.Second},
+		Autostop: Autostop{Action: "exit", APIURL: "http://_api.internal:4280"},
+		DataCache: DataCache{
+			Backend: "memory",
+			TTL:     time.Minute,
+			Jitter:  0.1,
+			Codec:   "json",
+		},
+		Quota:    Quota{Backend: "sql"},
+		Realtime: Realtime{PollTimeout: 25 * time.Second},
+		Presence: Presence{TTL: 30 * time.Second},
+		Compression: Compression{
+			Enabled: true,
+			MinSize: 1024,
+		},
+		Uploads: Uploads{
+			Endpoint:        "https://fly.storage.tigris.dev",
+			Region:          "auto",
+			MaxBytes:        100 << 20,
+			AllowedTypes:    []string{"image/*", "application/pdf", "text/plain"},
+			URLTTL:          15 * time.Minute,
+			Timeout:         10 * time.Minute,
+			ThumbnailSize:   256,
+			ProcessAttempts: 5,
+		},
+		Scheduler: Scheduler{
+			Enabled: true,
+			Lease:   30 * time.Second,
+		},
+		Security: Security{
+			Enabled: true,
+			CSP:     "default-src 'self'; base-uri 'self'; object-src 'none'; frame-ancestors 'none'",
+			CSPRoutes: map[string]string{
+				// Swagger UI comes from unpkg and starts with an inline script.
+				"/docs": "default-src 'self'; script-src 'self' 'unsafe-inline' https://unpkg.com; " +
+					"style-src 'self' https://unpkg.com; img-src 'self' data:; frame-ancestors 'none'",
+				// So is the GraphiQL playground, from jsDelivr.
+				"/graphql": "default-src 'self'; script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
+					"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; font-src 'self' data: https://cdn.jsdelivr.net; " +
+					"img-src 'self' data:; frame-ancestors 'none'",
+			},
+			HSTSMaxAge:         365 * 24 * time.Hour,
+			ContentTypeOptions: "nosniff",
+			FrameOptions:       "DENY",
+			ReferrerPolicy:     "strict-origin-when-cross-origin",
+			PermissionsPolicy:  "camera=(), microphone=(), geolocation=()",
+		},
+		Maintenance: Maintenance{
+			RetryAfter: 30 * time.Second,
+			Grace:      30 * time.Second,
+			Message:    "down for maintenance",
+		},
+		Mail: Mail{
+			Provider:    "log",
+			From:        "go-flylike-example <no-reply@example.com>",
+			Timeout:     10 * time.Second,
+			MaxAttempts: 5,
+			SMTPPort:    587,
+			Region:      "us-east-1",
+		},
+		Accounts: Accounts{
+			Registration: true,
+			LinkBaseURL:  "http://localhost:9090",
+			VerifyTTL:    48 * time.Hour,
+			ResetTTL:     time.Hour,
+		},
+		GraphQL: GraphQL{
+			Enabled:       true,
+			MaxComplexity: 1000,
+		},
+		Audit: Audit{
+			Enabled:      true,
+			Redact:       []string{"password", "token", "secret", "key", "authorization"},
+			MaxBodyBytes: 64 << 10,
+		},
+		Sentry: Sentry{Environment: "production", SampleRate: 1},
+		BodyLog: BodyLog{
+			SamplePercent: 100,
+			MaxBytes:      4 << 10,
+			Redact:        []string{"password", "token", "secret", "key", "authorization"},
+		},
+		Journal: Journal{
+			Dir:      "data/journal",
+			Interval: 5 * time.Second,
+		},
+		SLO:            SLO{Period: 30 * 24 * time.Hour},
+		RequestSigning: RequestSigning{Tolerance: 5 * time.Minute},
+		KV:             KV{BackupInterval: time.Hour, BackupKeep: 24},
+		Telemetry:      Telemetry{Metrics: "prometheus", Interval: 30 * time.Second},
+		Contract: Contract{
+			SamplePercent: 100,
+			Paths:         []string{"/api/"},
+			MaxBytes:      64 << 10,
+			Redact:        []string{"password", "token", "secret", "key", "authorization"},
+		},
+		Mirror: Mirror{
+			SamplePercent: 10,
+			MaxBytes:      64 << 10,
+			Redact:        []string{"password", "token", "secret", "key", "authorization"},
+			Queue:         1000,
+			Concurrency:   4,
+			Timeout:       5 * time.Second,
+		},
+		SignedURLs: SignedURLs{
+			BaseURL: "http://localhost:9090",
+			TTL:     time.Hour,
+			MaxTTL:  7 * 24 * time.Hour,
+		},
+		Batch: Batch{
+			MaxItems:    20,
+			Concurrency: 4,
+		},
+		Canary: Canary{
+			Header:  "X-Canary",
+			Cookie:  "canary",
+			Timeout: 30 * time.Second,
+		},
+		Imports: Imports{
+			MaxBytes:  16 << 20,
+			MaxErrors: 100,
+		},
+		Shedding: Shedding{
+			MaxGoroutines: 10000,
+			MemoryRatio:   0.9,
+			MaxLatency:    2 * time.Second,
+			Window:        10 * time.Second,
+			NormalAt:      1.5,
+			RetryAfter:    5 * time.Second,
+			LowPaths:      []string{"/api/v1/export", "/api/v2/imports", "/api/v2/users/stream"},
+		},
+		Admission: Admission{
+			Low:    AdmissionClass{Limit: 4, Queue: 32, Wait: 30 * time.Second},
+			Normal: AdmissionClass{Limit: 64, Queue: 256, Wait: 5 * time.Second},
+		},
+		CacheBus: CacheBus{
+			Channel: "cache.invalidate",
+			URL:     "nats://127.0.0.1:4222",
+		},
+		Plugins: Plugins{
+			Timeout:   50 * time.Millisecond,
+			MaxMemory: 16 << 20,
+		},
+		Bus: Bus{
+			Backend:     "memory",
+			URL:         "nats://127.0.0.1:4222",
+			Stream:      "events",
+			Brokers:     []string{"127.0.0.1:9092"},
+			Concurrency: 4,
+			MaxAttempts: 5,
+			Timeout:     30 * time.Second,
+			RetryDelay:  time.Second,
+
+			OutboxPollInterval: time.Second,
+			OutboxBatch:        100,
+		},
+		Webhooks: Webhooks{
+			Timeout:     10 * time.Second,
+			MaxAttempts: 10,
+			Tolerance:   5 * time.Minute,
+		},
+		Idempotency: Idempotency{
+			TTL:  24 * time.Hour,
+			Lock: time.Minute,
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID", "X-CSRF-Token", "If-Match", "If-None-Match"},
+			ExposedHeaders: []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After", "ETag"},
+			MaxAge:         10 * time.Minute,
+		},
+	}
+}
+
+// Enabled reports whether the named feature flag is switched on.
+func (c *Config) Enabled(feature string) bool {
+	return c.Features[strings.ToLower(feature)]
+}
+
+// Validate checks that the configuration is usable.
+func (c *Config) Validate() error {
+	for name, pct := range c.Rollouts {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("config: rollout %s must be between 0 and 100", name)
+		}
+	}
+	switch c.Profile {
+	case "", "dev", "staging", "prod":
+	default:
+		return fmt.Errorf("config: unknown profile %q, want dev, staging or prod", c.Profile)
+	}
+	if c.Addr == "" {
+		return fmt.Errorf("config: addr must not be empty")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("config: unknown log level %q", c.LogLevel)
+	}
+	if c.LogDebugTTL <= 0 {
+		return fmt.Errorf("config: log debug ttl must be positive")
+	}
+	for name, d := range map[string]time.Duration{
+		"read header": c.Timeouts.ReadHeader,
+		"read":        c.Timeouts.Read,
+		"write":       c.Timeouts.Write,
+		"idle":        c.Timeouts.Idle,
+		"handler":     c.Timeouts.Handler,
+		"shutdown":    c.Timeouts.Shutdown,
+	} {
+		if d < 0 {
+			return fmt.Errorf("config: %s timeout must not be negative", name)
+		}
+	}
+	if c.Timeouts.Shutdown == 0 {
+		return fmt.Errorf("config: shutdown timeout must be positive")
+	}
+	if c.Limits.MaxBodyBytes < 0 || c.Limits.MaxHeaderBytes < 0 {
+		return fmt.Errorf("config: request size limits must not be negative")
+	}
+	if c.GRPC.Addr != "" && c.GRPC.Addr == c.Addr {
+		return fmt.Errorf("config: grpc and http listen addresses must differ")
+	}
+	for _, list := range [][]string{c.IPFilter.Allow, c.IPFilter.Deny, c.IPFilter.AdminAllow, c.TrustedProxies} {
+		for _, entry := range list {
+			if !validPrefix(entry) {
+				return fmt.Errorf("config: invalid address range %q", entry)
+			}
+		}
+	}
+	if c.Admin.Addr != "" && c.Admin.Token == "" && c.Admin.ClientCAFile == "" && !loopback(c.Admin.Addr) {
+		return fmt.Errorf("config: admin token or client ca is required when the admin listener is not on loopback")
+	}
+	if (c.Admin.CertFile == "") != (c.Admin.KeyFile == "") {
+		return fmt.Errorf("config: admin cert file and key file must be set together")
+	}
+	if c.Admin.ClientCAFile != "" && c.Admin.CertFile == "" {
+		return fmt.Errorf("config: admin client ca requires the admin cert file")
+	}
+	if len(c.Admin.ClientSANs) > 0 && c.Admin.ClientCAFile == "" {
+		return fmt.Errorf("config: admin client sans require the admin client ca")
+	}
+	for _, p := range c.Admin.ClientSANs {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("config: invalid admin client san pattern %q", p)
+		}
+	}
+	if c.Admin.Metrics && c.Admin.Addr == "" {
+		return fmt.Errorf("config: admin metrics require the admin listener")
+	}
+	for _, o := range c.CORS.AllowedOrigins {
+		if expr, ok := strings.CutPrefix(o, "~"); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("config: cors origin %q: %w", o, err)
+			}
+		}
+	}
+	// Otherwise every site could read what its visitors are shown.
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		return fmt.Errorf("config: cors origin \"*\" cannot allow credentials; list the origins")
+	}
+	for name, p := range c.CORS.Policies {
+		if p.AllowCredentials && slices.Contains(p.AllowedOrigins, "*") {
+			return fmt.Errorf("config: cors policy %s: origin \"*\" cannot allow credentials; list the origins", name)
+		}
+	}
+	for _, r := range c.Proxy {
+		if !strings.HasPrefix(r.Prefix, "/") || r.Prefix == "/" || strings.HasSuffix(r.Prefix, "/") {
+			return fmt.Errorf("config: proxy prefix %q must start and not end with /", r.Prefix)
+		}
+		if u, err := url.Parse(r.Upstream); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: proxy %s: invalid upstream %q", r.Prefix, r.Upstream)
+		}
+		if r.Timeout < 0 || r.Retries < 0 {
+			return fmt.Errorf("config: proxy %s: timeout and retries must not be negative", r.Prefix)
+		}
+	}
+	if len(c.OIDC.Providers) > 0 {
+		if u, err := url.Parse(c.OIDC.RedirectBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("config: oidc redirect base url must be an absolute url")
+		}
+	}
+	for name, p := range c.OIDC.Providers {
+		if p.ClientID == "" || p.ClientSecret == "" {
+			return fmt.Errorf("config: oidc %s: client id and secret must be set", name)
+		}
+		if name != "google" && name != "github" && p.IssuerURL == "" {
+			return fmt.Errorf("config: oidc %s: issuer url is required", name)
+		}
+	}
+	if c.Restart.Enabled && c.Restart.Timeout <= 0 {
+		return fmt.Errorf("config: restart timeout must be positive")
+	}
+	if c.Uploads.Bucket != "" {
+		if c.Uploads.Endpoint == "" || c.Uploads.AccessKeyID == "" || c.Uploads.SecretAccessKey == "" {
+			return fmt.Errorf("config: uploads require an endpoint and credentials")
+		}
+		if c.Uploads.MaxBytes <= 0 || c.Uploads.URLTTL <= 0 || c.Uploads.Timeout <= 0 {
+			return fmt.Errorf("config: uploads max bytes, url ttl and timeout must be positive")
+		}
+		// Signatures V4 cannot outlive seven days.
+		if c.Uploads.URLTTL > 7*24*time.Hour {
+			return fmt.Errorf("config: uploads url ttl must not exceed 7 days")
+		}
+		if c.Uploads.ThumbnailSize < 0 || c.Uploads.ProcessAttempts <= 0 {
+			return fmt.Errorf("config: uploads thumbnail size must not be negative and process attempts must be positive")
+		}
+	}
+	if c.Compression.MinSize < 0 {
+		return fmt.Errorf("config: compression min size must not be negative")
+	}
+	if c.Cache.Enabled {
+		if c.Cache.TTL <= 0 {
+			return fmt.Errorf("config: cache ttl must be positive")
+		}
+		switch c.Cache.Backend {
+		case "memory":
+		case "redis":
+			if c.Redis.URL == "" {
+				return fmt.Errorf("config: redis cache backend requires a redis url")
+			}
+		case "kv":
+			if c.KV.Path == "" {
+				return fmt.Errorf("config: kv cache backend requires a kv path")
+			}
+		default:
+			return fmt.Errorf("config: unknown cache backend %q", c.Cache.Backend)
+		}
+	}
+	if c.Autostop.After < 0 {
+		return fmt.Errorf("config: autostop after must not be negative")
+	}
+	switch c.Autostop.Action {
+	case "exit":
+	case "stop", "suspend":
+		if c.Autostop.After > 0 && c.Autostop.APIToken == "" {
+			return fmt.Errorf("config: autostop action %s requires a machines api token", c.Autostop.Action)
+		}
+	default:
+		return fmt.Errorf("config: unknown autostop action %q", c.Autostop.Action)
+	}
+	if c.Chaos.Headers && !c.Chaos.Enabled {
+		return fmt.Errorf("config: chaos headers require chaos to be enabled")
+	}
+	if (c.Chaos.Enabled || c.Chaos.Headers) && c.Profile == "prod" {
+		return fmt.Errorf("config: chaos must not be enabled in the prod profile")
+	}
+	if c.Protocols.HTTP3 {
+		if !c.TLS.Enabled() {
+			return fmt.Errorf("config: http3 requires tls")
+		}
+		if c.Restart.Enabled {
+			return fmt.Errorf("config: http3 cannot be combined with zero-downtime restarts")
+		}
+	}
+	if c.DataCache.Enabled {
+		if c.DataCache.TTL <= 0 {
+			return fmt.Errorf("config: data cache ttl must be positive")
+		}
+		if c.DataCache.Jitter < 0 || c.DataCache.Jitter >= 1 {
+			return fmt.Errorf("config: data cache jitter must be at least 0 and below 1")
+		}
+		switch c.DataCache.Backend {
+		case "memory":
+		case "redis":
+			if c.Redis.URL == "" {
+				return fmt.Errorf("config: redis data cache backend requires a redis url")
+			}
+		case "kv":
+			if c.KV.Path == "" {
+				return fmt.Errorf("config: kv data cache backend requires a kv path")
+			}
+		default:
+			return fmt.Errorf("config: unknown data cache backend %q", c.DataCache.Backend)
+		}
+		if c.DataCache.Codec != "json" && c.DataCache.Codec != "msgpack" {
+			return fmt.Errorf("config: unknown data cache codec %q", c.DataCache.Codec)
+		}
+	}
+	if c.Quota.Enabled {
+		switch c.Quota.Backend {
+		case "sql":
+		case "redis":
+			if c.Redis.URL == "" {
+				return fmt.Errorf("config: redis quota backend requires a redis url")
+			}
+		default:
+			return fmt.Errorf("config: unknown quota backend %q", c.Quota.Backend)
+		}
+		for _, l := range []QuotaLimits{c.Quota.Tenant, c.Quota.User} {
+			if l.Requests < 0 || l.Storage < 0 || l.Jobs < 0 {
+				return fmt.Errorf("config: quota limits must not be negative")
+			}
+		}
+	}
+	if c.Realtime.PollTimeout < time.Second {
+		return fmt.Errorf("config: realtime poll timeout must be at least 1s")
+	}
+	if c.Presence.Enabled && c.Presence.TTL < 3*time.Second {
+		return fmt.Errorf("config: presence ttl must be at least 3s")
+	}
+	if c.Scheduler.Enabled && c.Scheduler.Lease < 3*time.Second {
+		return fmt.Errorf("config: scheduler lease must be at least 3s")
+	}
+	if c.Security.HSTSMaxAge < 0 {
+		return fmt.Errorf("config: hsts max age must not be negative")
+	}
+	if c.Maintenance.RetryAfter < time.Second || c.Maintenance.Grace < 0 {
+		return fmt.Errorf("config: maintenance retry after must be at least 1s and grace not negative")
+	}
+	if err := c.Mail.validate(); err != nil {
+		return err
+	}
+	if u, err := url.Parse(c.Accounts.LinkBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("config: accounts link base url must be an absolute url")
+	}
+	if c.Accounts.VerifyTTL <= 0 || c.Accounts.ResetTTL <= 0 {
+		return fmt.Errorf("config: accounts verify and reset ttls must be positive")
+	}
+	if c.Audit.MaxBodyBytes <= 0 {
+		return fmt.Errorf("config: audit max body bytes must be positive")
+	}
+	if c.Sentry.SampleRate < 0 || c.Sentry.SampleRate > 1 {
+		return fmt.Errorf("config: sentry sample rate must be between 0 and 1")
+	}
+	if c.Contract.SamplePercent < 0 || c.Contract.SamplePercent > 100 {
+		return fmt.Errorf("config: contract sample percent must be between 0 and 100")
+	}
+	if c.Contract.MaxBytes <= 0 {
+		return fmt.Errorf("config: contract max bytes must be positive")
+	}
+	if c.BodyLog.SamplePercent < 0 || c.BodyLog.SamplePercent > 100 {
+		return fmt.Errorf("config: body log sample percent must be between 0 and 100")
+	}
+	if c.BodyLog.MaxBytes <= 0 {
+		return fmt.Errorf("config: body log max bytes must be positive")
+	}
+	if m := c.Mirror; m.URL != "" {
+		if u, err := url.Parse(m.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: mirror url must be an absolute http(s) url")
+		}
+		if m.SamplePercent < 0 || m.SamplePercent > 100 {
+			return fmt.Errorf("config: mirror sample percent must be between 0 and 100")
+		}
+		if m.MaxBytes < 0 || m.Queue <= 0 || m.Concurrency <= 0 || m.Timeout <= 0 {
+			return fmt.Errorf("config: mirror queue, concurrency and timeout must be positive")
+		}
+	}
+	if su := c.SignedURLs; len(su.Secrets) > 0 {
+		ids := map[string]bool{}
+		for _, entry := range su.Secrets {
+			id, secret, _ := strings.Cut(entry, ":")
+			if id == "" || len(secret) < 32 {
+				return fmt.Errorf("config: signed url secrets must be id:secret with a secret of at least 32 bytes")
+			}
+			if ids[id] {
+				return fmt.Errorf("config: signed url secret %s is given twice", id)
+			}
+			ids[id] = true
+		}
+		if u, err := url.Parse(su.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("config: signed url base url must be an absolute url")
+		}
+		if su.TTL <= 0 || su.MaxTTL < su.TTL {
+			return fmt.Errorf("config: signed url ttl must be positive and at most the max ttl")
+		}
+	}
+	if c.Batch.MaxItems <= 0 || c.Batch.Concurrency <= 0 {
+		return fmt.Errorf("config: batch max items and concurrency must be positive")
+	}
+	if cn := c.Canary; cn.Enabled {
+		if cn.Percent < 0 || cn.Percent > 100 {
+			return fmt.Errorf("config: canary percent must be between 0 and 100")
+		}
+		if cn.Upstream != "" {
+			if u, err := url.Parse(cn.Upstream); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("config: canary upstream must be an absolute url")
+			}
+			if cn.Timeout <= 0 {
+				return fmt.Errorf("config: canary timeout must be positive")
+			}
+		}
+	}
+	if sh := c.Shedding; sh.Enabled {
+		if sh.MaxGoroutines < 0 || sh.MaxLatency < 0 || sh.MemoryRatio < 0 || sh.MemoryRatio > 1 {
+			return fmt.Errorf("config: shedding thresholds must not be negative, and the memory ratio at most 1")
+		}
+		if sh.Window <= 0 || sh.RetryAfter <= 0 || sh.NormalAt < 1 {
+			return fmt.Errorf("config: shedding window and retry after must be positive, and normal at at least 1")
+		}
+	}
+	if ad := c.Admission; ad.Enabled {
+		for _, cl := range []AdmissionClass{ad.Low, ad.Normal} {
+			if cl.Limit <= 0 || cl.Queue < 0 || cl.Wait <= 0 {
+				return fmt.Errorf("config: admission limits and waits must be positive, and queues not negative")
+			}
+		}
+	}
+	for _, r := range c.Geo.Regions {
+		if !regionCode.MatchString(r) {
+			return fmt.Errorf("config: geo region %q is not a region code such as ams", r)
+		}
+	}
+	if c.Journal.Dir != "" && c.Journal.Interval < time.Second {
+		return fmt.Errorf("config: journal interval must be at least 1s")
+	}
+	if c.Journal.Bucket != "" && (c.Uploads.Endpoint == "" || c.Uploads.AccessKeyID == "" || c.Uploads.SecretAccessKey == "") {
+		return fmt.Errorf("config: journal bucket requires the uploads endpoint and credentials")
+	}
+	if c.KV.Path != "" && c.Restart.Enabled {
+		return fmt.Errorf("config: kv cannot be combined with zero-downtime restarts, which open the file twice")
+	}
+	if kv := c.KV; kv.Path != "" && kv.BackupBucket != "" {
+		if c.Uploads.Endpoint == "" || c.Uploads.AccessKeyID == "" || c.Uploads.SecretAccessKey == "" {
+			return fmt.Errorf("config: kv backup bucket requires the uploads endpoint and credentials")
+		}
+		if kv.BackupInterval < time.Minute || kv.BackupKeep <= 0 {
+			return fmt.Errorf("config: kv backup interval must be at least 1m and backup keep positive")
+		}
+	}
+	if c.SLO.Period < time.Hour || c.SLO.Period > 90*24*time.Hour {
+		return fmt.Errorf("config: slo period must be between 1h and 90 days")
+	}
+	if rs := c.RequestSigning; len(rs.Secrets) > 0 {
+		ids := map[string]bool{}
+		for _, entry := range rs.Secrets {
+			id, secret, _ := strings.Cut(entry, ":")
+			if id == "" || len(secret) < 32 {
+				return fmt.Errorf("config: request signing secrets must be id:secret with a secret of at least 32 bytes")
+			}
+			if ids[id] {
+				return fmt.Errorf("config: request signing secret %s is given twice", id)
+			}
+			ids[id] = true
+		}
+		if rs.Tolerance <= 0 {
+			return fmt.Errorf("config: request signing tolerance must be positive")
+		}
+	}
+	switch c.Telemetry.Metrics {
+	case "prometheus", "otlp", "both":
+	default:
+		return fmt.Errorf("config: telemetry metrics must be prometheus, otlp or both")
+	}
+	if c.Telemetry.Interval < time.Second {
+		return fmt.Errorf("config: telemetry interval must be at least 1s")
+	}
+	if c.Admin.Metrics && !c.Telemetry.Scraped() {
+		return fmt.Errorf("config: admin metrics require prometheus telemetry")
+	}
+	if c.Bandwidth.PerConnection < 0 || c.Bandwidth.Global < 0 {
+		return fmt.Errorf("config: bandwidth limits must not be negative")
+	}
+	if c.Imports.MaxBytes <= 0 || c.Imports.MaxErrors <= 0 {
+		return fmt.Errorf("config: import max bytes and max errors must be positive")
+	}
+	switch c.CacheBus.Backend {
+	case "":
+	case "redis":
+		if c.Redis.URL == "" {
+			return fmt.Errorf("config: redis cache bus requires a redis url")
+		}
+	case "nats":
+		if c.CacheBus.URL == "" {
+			return fmt.Errorf("config: nats cache bus requires a url")
+		}
+	default:
+		return fmt.Errorf("config: unknown cache bus backend %q", c.CacheBus.Backend)
+	}
+	if c.CacheBus.Backend != "" && c.CacheBus.Channel == "" {
+		return fmt.Errorf("config: cache bus channel must not be empty")
+	}
+	if p := c.Plugins; len(p.Files) > 0 {
+		for _, f := range p.Files {
+			if ext := strings.ToLower(path.Ext(f)); ext != ".lua" && ext != ".wasm" {
+				return fmt.Errorf("config: plugin %s must be a .lua or .wasm file", f)
+			}
+		}
+		if p.Timeout <= 0 || p.MaxMemory < 64<<10 {
+			return fmt.Errorf("config: plugin timeout must be positive and max memory at least 64 KiB")
+		}
+	}
+	switch c.Bus.Backend {
+	case "memory", "nats", "kafka":
+	default:
+		return fmt.Errorf("config: unknown bus backend %q", c.Bus.Backend)
+	}
+	if c.Bus.Backend == "nats" && (c.Bus.URL == "" || c.Bus.Stream == "") {
+		return fmt.Errorf("config: nats bus requires a url and a stream")
+	}
+	if c.Bus.Backend == "kafka" && len(c.Bus.Brokers) == 0 {
+		return fmt.Errorf("config: kafka bus requires brokers")
+	}
+	if c.Bus.Concurrency <= 0 || c.Bus.MaxAttempts <= 0 || c.Bus.Timeout <= 0 || c.Bus.RetryDelay <= 0 {
+		return fmt.Errorf("config: bus concurrency, max attempts, timeout and retry delay must be positive")
+	}
+	if c.Bus.OutboxPollInterval <= 0 || c.Bus.OutboxBatch <= 0 {
+		return fmt.Errorf("config: bus outbox poll interval and batch must be positive")
+	}
+	if c.GraphQL.MaxComplexity <= 0 {
+		return fmt.Errorf("config: graphql max complexity must be positive")
+	}
+	if c.Webhooks.Timeout <= 0 || c.Webhooks.MaxAttempts <= 0 || c.Webhooks.Tolerance <= 0 {
+		return fmt.Errorf("config: webhooks timeout, max attempts and tolerance must be positive")
+	}
+	if c.Idempotency.TTL <= 0 || c.Idempotency.Lock <= 0 {
+		return fmt.Errorf("config: idempotency ttl and lock must be positive")
+	}
+	if c.Database.URL == "" {
+		return fmt.Errorf("config: database url must not be empty")
+	}
+	if c.Database.WriteMode != "replay" && c.Database.WriteMode != "forward" {
+		return fmt.Errorf("config: unknown database write mode %q", c.Database.WriteMode)
+	}
+	if c.Database.ReplicaURL != "" && c.Database.MaxReplicaLag <= 0 {
+		return fmt.Errorf("config: database max replica lag must be positive")
+	}
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+		return fmt.Errorf("config: auth token ttls must be positive")
+	}
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Rate <= 0 || c.RateLimit.Burst <= 0 {
+			return fmt.Errorf("config: rate limit rate and burst must be positive")
+		}
+		switch c.RateLimit.Backend {
+		case "memory":
+		case "redis":
+			if c.Redis.URL == "" {
+				return fmt.Errorf("config: redis rate limit backend requires a redis url")
+			}
+		default:
+			return fmt.Errorf("config: unknown rate limit backend %q", c.RateLimit.Backend)
+		}
+	}
+	for name, p := range c.RateLimit.Policies {
+		if p.Rate <= 0 || p.Burst <= 0 {
+			return fmt.Errorf("config: rate limit policy %s: rate and burst must be positive", name)
+		}
+	}
+	for _, p := range c.Policies {
+		if err := c.validatePolicy(p); err != nil {
+			return err
+		}
+	}
+	if !c.API.V1SunsetAt.IsZero() && c.API.V1SunsetAt.Before(c.API.V1DeprecatedAt) {
+		return fmt.Errorf("config: api v1 sunset must not precede its deprecation")
+	}
+	if c.Session.CookieName == "" || c.Session.TTL <= 0 {
+		return fmt.Errorf("config: session cookie name and ttl must be set")
+	}
+	if c.Jobs.Concurrency < 0 || c.Jobs.PollInterval <= 0 || c.Jobs.MaxAttempts <= 0 || c.Jobs.Lease <= 0 {
+		return fmt.Errorf("config: invalid jobs settings")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("config: tls cert and key files must be set together")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		return fmt.Errorf("config: database pool sizes must not be negative")
+	}
+	if c.Database.MigrationTimeout <= 0 {
+		return fmt.Errorf("config: database migration timeout must be positive")
+	}
+	if c.Database.MigrateOnly && !c.Database.AutoMigrate {
+		return fmt.Errorf("config: --migrate-only and --skip-migrations exclude each other")
+	}
+	return nil
+}
+
+func (m Mail) validate() error {
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return fmt.Errorf("config: mail from: %w", err)
+	}
+	if m.Timeout <= 0 || m.MaxAttempts <= 0 {
+		return fmt.Errorf("config: mail timeout and max attempts must be positive")
+	}
+	switch m.Provider {
+	case "log":
+	case "smtp":
+		if m.SMTPHost == "" || m.SMTPPort <= 0 {
+			return fmt.Errorf("config: smtp mail requires a host and port")
+		}
+	case "ses":
+		if m.Region == "" || m.AccessKeyID == "" || m.SecretAccessKey == "" {
+			return fmt.Errorf("config: ses mail requires a region and credentials")
+		}
+	case "sendgrid":
+		if m.APIKey == "" {
+			return fmt.Errorf("config: sendgrid mail requires an api key")
+		}
+	case "mailgun":
+		if m.APIKey == "" || m.Domain == "" {
+			return fmt.Errorf("config: mailgun mail requires an api key and domain")
+		}
+	default:
+		return fmt.Errorf("config: unknown mail provider %q", m.Provider)
+	}
+	return nil
+}
+
+func (c *Config) validatePolicy(p Policy) error {
+	if !strings.HasPrefix(p.Match, "/") {
+		return fmt.Errorf("config: policy match %q must start with /", p.Match)
+	}
+	if _, err := path.Match(p.Match, ""); err != nil {
+		return fmt.Errorf("config: policy match %q: %w", p.Match, err)
+	}
+	if len(p.Require) == 0 {
+		return fmt.Errorf("config: policy %s requires nothing", p.Match)
+	}
+	for _, req := range p.Require {
+		kind, arg, _ := strings.Cut(req, ":")
+		switch kind {
+		case "auth":
+			if arg != "" {
+				return fmt.Errorf("config: policy %s: auth takes no argument", p.Match)
+			}
+		case "rbac", "scope":
+			if arg == "" {
+				return fmt.Errorf("config: policy %s: %s requires an argument", p.Match, kind)
+			}
+		case "ratelimit":
+			if !c.RateLimit.Enabled {
+				return fmt.Errorf("config: policy %s: ratelimit requires rate limiting to be enabled", p.Match)
+			}
+			if _, ok := c.RateLimit.Policies[arg]; !ok {
+				return fmt.Errorf("config: policy %s: unknown rate limit policy %q", p.Match, arg)
+			}
+		case "cors":
+			if _, ok := c.CORS.Policies[arg]; !ok {
+				return fmt.Errorf("config: policy %s: unknown cors policy %q", p.Match, arg)
+			}
+		case "cache":
+			if !c.Cache.Enabled {
+				return fmt.Errorf("config: policy %s: cache requires the response cache to be enabled", p.Match)
+			}
+			// The policies run before tenants are resolved, so cached
+			// responses would be shared between them.
+			if c.Tenancy.Enabled {
+				return fmt.Errorf("config: policy %s: cache cannot be used with tenancy", p.Match)
+			}
+			if arg != "" {
+				if ttl, err := time.ParseDuration(arg); err != nil || ttl <= 0 {
+					return fmt.Errorf("config: policy %s: invalid cache ttl %q", p.Match, arg)
+				}
+			}
+		default:
+			return fmt.Errorf("config: policy %s: unknown requirement %q", p.Match, req)
+		}
+	}
+	return nil
+}
+
+// regionCode matches the three letter codes of regions.
+var regionCode = regexp.MustCompile(`^[a-z]{3}$`)
+
+// validPrefix accepts a CIDR range or a single address.
+func validPrefix(s string) bool {
+	if _, err := netip.ParsePrefix(s); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// loopback reports whether addr binds only to a loopback interface.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
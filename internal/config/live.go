@@ -0,0 +1,295 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce coalesces the burst of events editors produce on save.
+const reloadDebounce = 250 * time.Millisecond
+
+// Live holds the current configuration behind an atomic pointer so request
+// paths read it lock-free while Reload swaps in new versions. Settings that
+// are only read at startup (listen address, database, TLS, Redis) still
+// require a restart; Reload logs when they change.
+type Live struct {
+	current atomic.Pointer[Config]
+	args    []string
+
+	mu          sync.Mutex
+	subscribers []func(old, new *Config)
+}
+
+// NewLive wraps cfg. args are the flags cfg was loaded with; they are
+// re-applied on every reload so flags keep their precedence.
+func NewLive(cfg *Config, args []string) *Live {
+	l := &Live{args: args}
+	l.current.Store(cfg)
+	return l
+}
+
+// Load returns the current configuration. Callers must treat it as
+// read-only.
+func (l *Live) Load() *Config {
+	return l.current.Load()
+}
+
+// OnReload registers fn to run after every successful reload.
+func (l *Live) OnReload(fn func(old, new *Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subscribers = append(l.subscribers, fn)
+}
+
+// Reload re-reads every source. An invalid configuration is rejected and
+// the current one stays in effect.
+func (l *Live) Reload() error {
+	next, err := Load(l.args)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prev := l.current.Swap(next)
+	if fields := restartRequired(prev, next); len(fields) > 0 {
+		slog.Warn("config changes require a restart to take effect", "fields", fields)
+	}
+	for _, fn := range l.subscribers {
+		fn(prev, next)
+	}
+	slog.Info("config reloaded")
+	return nil
+}
+
+// Watch reloads on SIGHUP and whenever the config file changes, until ctx
+// is done.
+func (l *Live) Watch(ctx context.Context) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	path := l.Load().File
+	if path != "" {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		// Watch the directory: editors and ConfigMap mounts replace the
+		// file atomically, which would drop a watch on the file itself.
+		if err := w.Add(filepath.Dir(path)); err != nil {
+			return err
+		}
+		events, errs = w.Events, w.Errors
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			l.reload("sighup")
+		case ev := <-events:
+			if filepath.Clean(ev.Name) == filepath.Clean(path) || isConfigMapSwap(ev.Name) {
+				debounce = time.After(reloadDebounce)
+			}
+		case err := <-errs:
+			slog.Warn("config watcher error", "error", err)
+		case <-debounce:
+			debounce = nil
+			l.reload("file change")
+		}
+	}
+}
+
+func (l *Live) reload(trigger string) {
+	if err := l.Reload(); err != nil {
+		slog.Error("config reload failed, keeping current config", "trigger", trigger, "error", err)
+	}
+}
+
+// isConfigMapSwap matches the ..data symlink Kubernetes swaps when a
+// mounted ConfigMap is updated.
+func isConfigMapSwap(name string) bool {
+	return filepath.Base(name) == "..data"
+}
+
+func restartRequired(prev, next *Config) []string {
+	var fields []string
+	if prev.Addr != next.Addr {
+		fields = append(fields, "addr")
+	}
+	if prev.DefaultLocale != next.DefaultLocale {
+		fields = append(fields, "default_locale")
+	}
+	if !reflect.DeepEqual(prev.Database, next.Database) {
+		fields = append(fields, "database")
+	}
+	if !reflect.DeepEqual(prev.Proxy, next.Proxy) {
+		fields = append(fields, "proxy")
+	}
+	if !reflect.DeepEqual(prev.TrustedProxies, next.TrustedProxies) {
+		fields = append(fields, "trusted_proxies")
+	}
+	if !reflect.DeepEqual(prev.OIDC, next.OIDC) {
+		fields = append(fields, "oidc")
+	}
+	if !reflect.DeepEqual(prev.RBAC, next.RBAC) {
+		fields = append(fields, "rbac")
+	}
+	if prev.Idempotency != next.Idempotency {
+		fields = append(fields, "idempotency")
+	}
+	if !reflect.DeepEqual(prev.Uploads, next.Uploads) {
+		fields = append(fields, "uploads")
+	}
+	if !reflect.DeepEqual(prev.Scheduler, next.Scheduler) {
+		fields = append(fields, "scheduler")
+	}
+	if prev.Mail != next.Mail {
+		fields = append(fields, "mail")
+	}
+	if !reflect.DeepEqual(prev.Webhooks, next.Webhooks) {
+		fields = append(fields, "webhooks")
+	}
+	if prev.Compression != next.Compression {
+		fields = append(fields, "compression")
+	}
+	if prev.Sentry != next.Sentry {
+		fields = append(fields, "sentry")
+	}
+	if !reflect.DeepEqual(prev.Mirror, next.Mirror) {
+		fields = append(fields, "mirror")
+	}
+	if !reflect.DeepEqual(prev.SignedURLs, next.SignedURLs) {
+		fields = append(fields, "signed_urls")
+	}
+	if !reflect.DeepEqual(prev.RequestSigning, next.RequestSigning) {
+		fields = append(fields, "request_signing")
+	}
+	if prev.KV != next.KV {
+		fields = append(fields, "kv")
+	}
+	if prev.Telemetry != next.Telemetry {
+		fields = append(fields, "telemetry")
+	}
+	if prev.Bandwidth != next.Bandwidth {
+		fields = append(fields, "bandwidth")
+	}
+	if !reflect.DeepEqual(prev.Plugins, next.Plugins) {
+		fields = append(fields, "plugins")
+	}
+	if !reflect.DeepEqual(prev.Bus, next.Bus) {
+		fields = append(fields, "bus")
+	}
+	if prev.Canary.Enabled != next.Canary.Enabled || prev.Canary.Upstream != next.Canary.Upstream || prev.Canary.Timeout != next.Canary.Timeout {
+		fields = append(fields, "canary")
+	}
+	if prev.CacheBus != next.CacheBus {
+		fields = append(fields, "cache_bus")
+	}
+	if prev.Shedding.Enabled != next.Shedding.Enabled {
+		fields = append(fields, "shedding")
+	}
+	if a, b := prev.Admission, next.Admission; a.Enabled != b.Enabled || a.Low.Limit != b.Low.Limit || a.Low.Queue != b.Low.Queue ||
+		a.Normal.Limit != b.Normal.Limit || a.Normal.Queue != b.Normal.Queue {
+		fields = append(fields, "admission")
+	}
+	if prev.Geo.Database != next.Geo.Database {
+		fields = append(fields, "geo")
+	}
+	if prev.Journal != next.Journal {
+		fields = append(fields, "journal")
+	}
+	if prev.SLO != next.SLO {
+		fields = append(fields, "slo")
+	}
+	if prev.Imports.MaxBytes != next.Imports.MaxBytes {
+		fields = append(fields, "imports")
+	}
+	if prev.GraphQL != next.GraphQL {
+		fields = append(fields, "graphql")
+	}
+	if prev.Cache != next.Cache {
+		fields = append(fields, "cache")
+	}
+	if prev.Autostop != next.Autostop {
+		fields = append(fields, "autostop")
+	}
+	if prev.Chaos != next.Chaos {
+		fields = append(fields, "chaos")
+	}
+	if prev.Protocols != next.Protocols {
+		fields = append(fields, "protocols")
+	}
+	if prev.DataCache != next.DataCache {
+		fields = append(fields, "data_cache")
+	}
+	if prev.Realtime != next.Realtime {
+		fields = append(fields, "realtime")
+	}
+	if prev.Presence != next.Presence {
+		fields = append(fields, "presence")
+	}
+	if prev.Quota.Enabled != next.Quota.Enabled || prev.Quota.Backend != next.Quota.Backend {
+		fields = append(fields, "quota")
+	}
+	if prev.Restart != next.Restart {
+		fields = append(fields, "restart")
+	}
+	if prev.Web != next.Web {
+		fields = append(fields, "web")
+	}
+	if prev.Views != next.Views {
+		fields = append(fields, "views")
+	}
+	if !reflect.DeepEqual(prev.Admin, next.Admin) {
+		fields = append(fields, "admin")
+	}
+	if prev.Tenancy != next.Tenancy {
+		fields = append(fields, "tenancy")
+	}
+	if prev.Timeouts != next.Timeouts || prev.Limits != next.Limits {
+		fields = append(fields, "timeouts", "limits")
+	}
+	if prev.GRPC != next.GRPC {
+		fields = append(fields, "grpc")
+	}
+	if !reflect.DeepEqual(prev.TLS, next.TLS) {
+		fields = append(fields, "tls")
+	}
+	if prev.Redis != next.Redis {
+		fields = append(fields, "redis")
+	}
+	if prev.Auth != next.Auth {
+		fields = append(fields, "auth")
+	}
+	if prev.RateLimit.Enabled != next.RateLimit.Enabled || prev.RateLimit.Backend != next.RateLimit.Backend {
+		fields = append(fields, "rate_limit.enabled", "rate_limit.backend")
+	}
+	if !reflect.DeepEqual(prev.Policies, next.Policies) {
+		fields = append(fields, "policies")
+	}
+	if !reflect.DeepEqual(prev.RateLimit.Policies, next.RateLimit.Policies) {
+		fields = append(fields, "rate_limit.policies")
+	}
+	if !reflect.DeepEqual(prev.CORS.Policies, next.CORS.Policies) {
+		fields = append(fields, "cors.policies")
+	}
+	return fields
+}
@@ -0,0 +1,815 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"go-flylike-example/internal/secrets"
+)
+
+const (
+	featureEnvPrefix  = "FEATURE_"
+	oidcEnvPrefix     = "OIDC_"
+	scheduleEnvPrefix = "SCHEDULE_"
+)
+
+// Load resolves the configuration from every source. args are the
+// command-line arguments without the program name.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("go-flylike-example", flag.ContinueOnError)
+	resolve := Flags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return resolve()
+}
+
+// Flags registers the configuration flags on fs, for commands with flags
+// of their own, and returns the function resolving the configuration once
+// fs was parsed.
+func Flags(fs *flag.FlagSet) func() (*Config, error) {
+	fl := registerFlags(fs)
+	return func() (*Config, error) { return fl.load(fs) }
+}
+
+func (fl *flags) load(fs *flag.FlagSet) (*Config, error) {
+	cfg := Default()
+	cfg.Profile = strings.ToLower(os.Getenv("CONFIG_PROFILE"))
+	if fl.profile != "" {
+		cfg.Profile = strings.ToLower(fl.profile)
+	}
+	cfg.Region = strings.ToLower(os.Getenv("FLY_REGION"))
+
+	path := os.Getenv("CONFIG_FILE")
+	if fl.configFile != "" {
+		path = fl.configFile
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+		cfg.File = path
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	fl.apply(cfg, fs)
+
+	cfg.LogLevel = strings.ToLower(cfg.LogLevel)
+	features := make(map[string]bool, len(cfg.Features))
+	for name, on := range cfg.Features {
+		features[strings.ToLower(name)] = on
+	}
+	cfg.Features = features
+	rollouts := make(map[string]int, len(cfg.Rollouts))
+	for name, pct := range cfg.Rollouts {
+		rollouts[strings.ToLower(name)] = pct
+	}
+	cfg.Rollouts = rollouts
+
+	if err := revealSecrets(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// revealTimeout bounds decrypting the values of a configuration, which
+// may call KMS once per value.
+const revealTimeout = 30 * time.Second
+
+// revealSecrets decrypts the encrypted values of cfg in place, and has
+// them and the other credentials it holds scrubbed from logs.
+func revealSecrets(cfg *Config) error {
+	keys, err := secrets.NewKeyring(secrets.Options{
+		AgeIdentities: cfg.Secrets.AgeIdentities,
+		KMS: secrets.KMSOptions{
+			Region:          cfg.Secrets.KMS.Region,
+			Endpoint:        cfg.Secrets.KMS.Endpoint,
+			AccessKeyID:     cfg.Secrets.KMS.AccessKeyID,
+			SecretAccessKey: cfg.Secrets.KMS.SecretAccessKey,
+			SessionToken:    cfg.Secrets.KMS.SessionToken,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), revealTimeout)
+	defer cancel()
+	if err := keys.Reveal(ctx, cfg); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return cfg.rememberCredentials()
+}
+
+// overlays are the sections of a config file that only apply to some
+// deployments.
+type overlays struct {
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+	Regions  map[string]yaml.Node `yaml:"regions"`
+}
+
+// loadFile decodes a YAML or TOML file on top of cfg, then the section of
+// its profiles for cfg.Profile and that of its regions for cfg.Region, each
+// overriding only the settings it names. TOML documents are normalised
+// through the YAML decoder so both formats share one set of struct tags
+// and duration parsing.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	case ".toml":
+		var doc map[string]any
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("config: parse %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return fmt.Errorf("config: parse %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config: unsupported file type %q", filepath.Ext(path))
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	var ov overlays
+	if err := yaml.Unmarshal(data, &ov); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	for _, section := range []struct {
+		kind, name string
+		nodes      map[string]yaml.Node
+	}{
+		{"profile", cfg.Profile, ov.Profiles},
+		{"region", cfg.Region, ov.Regions},
+	} {
+		if section.name == "" {
+			continue
+		}
+		node, ok := section.nodes[section.name]
+		if !ok {
+			continue
+		}
+		if err := node.Decode(cfg); err != nil {
+			return fmt.Errorf("config: parse %s: %s %s: %w", path, section.kind, section.name, err)
+		}
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	if v, ok := os.LookupEnv("PORT"); ok && v != "" {
+		cfg.Addr = ":" + v
+	}
+	envString("LISTEN_ADDR", &cfg.Addr)
+	envString("LOG_LEVEL", &cfg.LogLevel)
+	envString("DEFAULT_LOCALE", &cfg.DefaultLocale)
+	envString("VIEWS_DIR", &cfg.Views.Dir)
+	envString("DATABASE_URL", &cfg.Database.URL)
+	envString("DATABASE_REPLICA_URL", &cfg.Database.ReplicaURL)
+	envString("DATABASE_WRITE_MODE", &cfg.Database.WriteMode)
+	envString("JWT_SECRET", &cfg.Auth.JWTSecret)
+	envString("JWT_PRIVATE_KEY_FILE", &cfg.Auth.PrivateKeyFile)
+	envString("JWT_PUBLIC_KEY_FILE", &cfg.Auth.PublicKeyFile)
+	envString("JWT_ISSUER", &cfg.Auth.Issuer)
+	envString("AUTH_DEMO_USER", &cfg.Auth.DemoUser)
+	envString("AUTH_DEMO_PASSWORD", &cfg.Auth.DemoPassword)
+	envString("REDIS_URL", &cfg.Redis.URL)
+	envString("API_DOCS_URL", &cfg.API.DocsURL)
+	envString("TLS_CERT_FILE", &cfg.TLS.CertFile)
+	envString("TLS_KEY_FILE", &cfg.TLS.KeyFile)
+	envList("TLS_AUTOCERT_DOMAINS", &cfg.TLS.AutocertDomains)
+	envList("RBAC_ADMINS", &cfg.RBAC.Admins)
+	envList("GITHUB_WEBHOOK_SECRET", &cfg.Webhooks.GitHubSecrets)
+	envString("RESTART_PID_FILE", &cfg.Restart.PIDFile)
+	envList("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	envList("CORS_ALLOWED_METHODS", &cfg.CORS.AllowedMethods)
+	envList("CORS_ALLOWED_HEADERS", &cfg.CORS.AllowedHeaders)
+	envList("CORS_EXPOSED_HEADERS", &cfg.CORS.ExposedHeaders)
+	envString("TLS_AUTOCERT_EMAIL", &cfg.TLS.AutocertEmail)
+	envString("TLS_AUTOCERT_CACHE_DIR", &cfg.TLS.AutocertCacheDir)
+	envString("HTTP3_ADDR", &cfg.Protocols.HTTP3Addr)
+	envString("AUTOSTOP_ACTION", &cfg.Autostop.Action)
+	envString("AUTOSTOP_API_URL", &cfg.Autostop.APIURL)
+	envString("FLY_API_TOKEN", &cfg.Autostop.APIToken)
+	envString("SESSION_COOKIE_NAME", &cfg.Session.CookieName)
+	envString("SESSION_DOMAIN", &cfg.Session.Domain)
+	envString("TENANCY_BASE_DOMAIN", &cfg.Tenancy.BaseDomain)
+	envString("TENANCY_HEADER", &cfg.Tenancy.Header)
+	envString("ADMIN_TOKEN", &cfg.Admin.Token)
+	envString("ADMIN_TLS_CERT_FILE", &cfg.Admin.CertFile)
+	envString("ADMIN_TLS_KEY_FILE", &cfg.Admin.KeyFile)
+	envString("ADMIN_CLIENT_CA_FILE", &cfg.Admin.ClientCAFile)
+	envList("ADMIN_CLIENT_SANS", &cfg.Admin.ClientSANs)
+	envString("MAINTENANCE_MESSAGE", &cfg.Maintenance.Message)
+	envString("MAIL_PROVIDER", &cfg.Mail.Provider)
+	envString("ACCOUNTS_LINK_BASE_URL", &cfg.Accounts.LinkBaseURL)
+	envString("MAIL_FROM", &cfg.Mail.From)
+	envString("MAIL_SMTP_HOST", &cfg.Mail.SMTPHost)
+	envString("MAIL_SMTP_USERNAME", &cfg.Mail.SMTPUsername)
+	envString("MAIL_SMTP_PASSWORD", &cfg.Mail.SMTPPassword)
+	envString("MAIL_API_KEY", &cfg.Mail.APIKey)
+	envString("MAIL_DOMAIN", &cfg.Mail.Domain)
+	envString("MAIL_ENDPOINT", &cfg.Mail.Endpoint)
+	envString("MAIL_REGION", &cfg.Mail.Region)
+	envString("MAIL_ACCESS_KEY_ID", &cfg.Mail.AccessKeyID)
+	envString("MAIL_SECRET_ACCESS_KEY", &cfg.Mail.SecretAccessKey)
+	envString("CSP", &cfg.Security.CSP)
+	envString("REFERRER_POLICY", &cfg.Security.ReferrerPolicy)
+	envString("PERMISSIONS_POLICY", &cfg.Security.PermissionsPolicy)
+	envString("FRAME_OPTIONS", &cfg.Security.FrameOptions)
+	envList("IP_ALLOW", &cfg.IPFilter.Allow)
+	envList("IP_DENY", &cfg.IPFilter.Deny)
+	envList("ADMIN_IP_ALLOW", &cfg.IPFilter.AdminAllow)
+	envList("TRUSTED_PROXIES", &cfg.TrustedProxies)
+	envList("AUDIT_REDACT", &cfg.Audit.Redact)
+	envString("GEO_DATABASE", &cfg.Geo.Database)
+	envList("GEO_REGIONS", &cfg.Geo.Regions)
+	envString("JOURNAL_DIR", &cfg.Journal.Dir)
+	envString("JOURNAL_BUCKET", &cfg.Journal.Bucket)
+	envString("KV_PATH", &cfg.KV.Path)
+	envString("KV_BACKUP_BUCKET", &cfg.KV.BackupBucket)
+	envString("TELEMETRY_METRICS", &cfg.Telemetry.Metrics)
+	envList("SECRETS_AGE_IDENTITIES", &cfg.Secrets.AgeIdentities)
+	envString("SECRETS_KMS_REGION", &cfg.Secrets.KMS.Region)
+	envString("SECRETS_KMS_ENDPOINT", &cfg.Secrets.KMS.Endpoint)
+	envString("SECRETS_KMS_ACCESS_KEY_ID", &cfg.Secrets.KMS.AccessKeyID)
+	envString("SECRETS_KMS_SECRET_ACCESS_KEY", &cfg.Secrets.KMS.SecretAccessKey)
+	envString("SECRETS_KMS_SESSION_TOKEN", &cfg.Secrets.KMS.SessionToken)
+	envString("SENTRY_DSN", &cfg.Sentry.DSN)
+	envString("SENTRY_ENVIRONMENT", &cfg.Sentry.Environment)
+	envString("SENTRY_RELEASE", &cfg.Sentry.Release)
+	if err := envFloat("SENTRY_SAMPLE_RATE", &cfg.Sentry.SampleRate); err != nil {
+		return err
+	}
+	envList("BODY_LOG_PATHS", &cfg.BodyLog.Paths)
+	envList("BODY_LOG_REDACT", &cfg.BodyLog.Redact)
+	envString("CONTRACT_RECORD_DIR", &cfg.Contract.Dir)
+	envList("CONTRACT_PATHS", &cfg.Contract.Paths)
+	envList("CONTRACT_REDACT", &cfg.Contract.Redact)
+	if err := envFloat("CONTRACT_SAMPLE_PERCENT", &cfg.Contract.SamplePercent); err != nil {
+		return err
+	}
+	envList("MIRROR_PATHS", &cfg.Mirror.Paths)
+	envList("MIRROR_REDACT", &cfg.Mirror.Redact)
+	envList("MIRROR_STRIP_HEADERS", &cfg.Mirror.StripHeaders)
+	envList("PLUGINS", &cfg.Plugins.Files)
+	envList("SIGNED_URL_SECRETS", &cfg.SignedURLs.Secrets)
+	envString("SIGNED_URL_BASE_URL", &cfg.SignedURLs.BaseURL)
+	envList("REQUEST_SIGNING_SECRETS", &cfg.RequestSigning.Secrets)
+	if err := envFloat("BODY_LOG_SAMPLE_PERCENT", &cfg.BodyLog.SamplePercent); err != nil {
+		return err
+	}
+	envString("MIRROR_URL", &cfg.Mirror.URL)
+	if err := envFloat("MIRROR_SAMPLE_PERCENT", &cfg.Mirror.SamplePercent); err != nil {
+		return err
+	}
+	envString("BUS_BACKEND", &cfg.Bus.Backend)
+	envString("BUS_URL", &cfg.Bus.URL)
+	envString("BUS_STREAM", &cfg.Bus.Stream)
+	envList("BUS_BROKERS", &cfg.Bus.Brokers)
+	if v, ok := os.LookupEnv("ADMIN_ADDR"); ok {
+		// An explicitly empty value disables the admin listener.
+		cfg.Admin.Addr = v
+	}
+	if v, ok := os.LookupEnv("GRPC_ADDR"); ok {
+		// An explicitly empty value disables the gRPC listener.
+		cfg.GRPC.Addr = v
+	}
+	if v, ok := os.LookupEnv("TLS_REDIRECT_ADDR"); ok {
+		// An explicitly empty value disables the redirect listener.
+		cfg.TLS.RedirectAddr = v
+	}
+	if err := envProxyRoutes("PROXY_ROUTES", &cfg.Proxy); err != nil {
+		return err
+	}
+	envString("OIDC_REDIRECT_BASE_URL", &cfg.OIDC.RedirectBaseURL)
+	envOIDCProviders(&cfg.OIDC.Providers)
+	envString("RATE_LIMIT_BACKEND", &cfg.RateLimit.Backend)
+	envString("CACHE_BACKEND", &cfg.Cache.Backend)
+	envString("DATA_CACHE_BACKEND", &cfg.DataCache.Backend)
+	envString("DATA_CACHE_CODEC", &cfg.DataCache.Codec)
+	envString("CACHE_BUS_BACKEND", &cfg.CacheBus.Backend)
+	envString("CACHE_BUS_CHANNEL", &cfg.CacheBus.Channel)
+	envString("CACHE_BUS_URL", &cfg.CacheBus.URL)
+	envString("CANARY_HEADER", &cfg.Canary.Header)
+	envString("CANARY_COOKIE", &cfg.Canary.Cookie)
+	envString("CANARY_UPSTREAM", &cfg.Canary.Upstream)
+	envList("CANARY_PATHS", &cfg.Canary.Paths)
+	if err := envFloat("CANARY_PERCENT", &cfg.Canary.Percent); err != nil {
+		return err
+	}
+	envList("SHED_LOW_PATHS", &cfg.Shedding.LowPaths)
+	envList("SHED_CRITICAL_PATHS", &cfg.Shedding.CriticalPaths)
+	if err := envFloat("SHED_MEMORY_RATIO", &cfg.Shedding.MemoryRatio); err != nil {
+		return err
+	}
+	if err := envFloat("SHED_NORMAL_AT", &cfg.Shedding.NormalAt); err != nil {
+		return err
+	}
+	envString("QUOTA_BACKEND", &cfg.Quota.Backend)
+	// The AWS names are what a Tigris bucket attached with fly storage
+	// create sets; the UPLOADS_ ones win when both are present.
+	envString("AWS_ENDPOINT_URL_S3", &cfg.Uploads.Endpoint)
+	envString("AWS_REGION", &cfg.Uploads.Region)
+	envString("BUCKET_NAME", &cfg.Uploads.Bucket)
+	envString("AWS_ACCESS_KEY_ID", &cfg.Uploads.AccessKeyID)
+	envString("AWS_SECRET_ACCESS_KEY", &cfg.Uploads.SecretAccessKey)
+	envString("UPLOADS_ENDPOINT", &cfg.Uploads.Endpoint)
+	envString("UPLOADS_REGION", &cfg.Uploads.Region)
+	envString("UPLOADS_BUCKET", &cfg.Uploads.Bucket)
+	envString("UPLOADS_ACCESS_KEY_ID", &cfg.Uploads.AccessKeyID)
+	envString("UPLOADS_SECRET_ACCESS_KEY", &cfg.Uploads.SecretAccessKey)
+	envList("UPLOADS_ALLOWED_TYPES", &cfg.Uploads.AllowedTypes)
+	envString("UPLOADS_CLAMAV_ADDR", &cfg.Uploads.ClamAVAddr)
+	if err := envInt64("UPLOADS_MAX_BYTES", &cfg.Uploads.MaxBytes); err != nil {
+		return err
+	}
+	if err := envInt64("IMPORT_MAX_BYTES", &cfg.Imports.MaxBytes); err != nil {
+		return err
+	}
+	for key, dst := range map[string]*int64{
+		"QUOTA_TENANT_REQUESTS":    &cfg.Quota.Tenant.Requests,
+		"QUOTA_TENANT_STORAGE":     &cfg.Quota.Tenant.Storage,
+		"QUOTA_TENANT_JOBS":        &cfg.Quota.Tenant.Jobs,
+		"QUOTA_USER_REQUESTS":      &cfg.Quota.User.Requests,
+		"QUOTA_USER_STORAGE":       &cfg.Quota.User.Storage,
+		"QUOTA_USER_JOBS":          &cfg.Quota.User.Jobs,
+		"BANDWIDTH_PER_CONNECTION": &cfg.Bandwidth.PerConnection,
+		"BANDWIDTH_GLOBAL":         &cfg.Bandwidth.Global,
+	} {
+		if err := envInt64(key, dst); err != nil {
+			return err
+		}
+	}
+	if err := envFloat("DATA_CACHE_JITTER", &cfg.DataCache.Jitter); err != nil {
+		return err
+	}
+	if err := envFloat("RATE_LIMIT_RPS", &cfg.RateLimit.Rate); err != nil {
+		return err
+	}
+	if err := envInt64("MAX_BODY_BYTES", &cfg.Limits.MaxBodyBytes); err != nil {
+		return err
+	}
+
+	for key, dst := range map[string]*time.Duration{
+		"READ_HEADER_TIMEOUT":       &cfg.Timeouts.ReadHeader,
+		"READ_TIMEOUT":              &cfg.Timeouts.Read,
+		"HANDLER_TIMEOUT":           &cfg.Timeouts.Handler,
+		"WRITE_TIMEOUT":             &cfg.Timeouts.Write,
+		"IDLE_TIMEOUT":              &cfg.Timeouts.Idle,
+		"SHUTDOWN_TIMEOUT":          &cfg.Timeouts.Shutdown,
+		"DB_CONN_MAX_LIFETIME":      &cfg.Database.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME":     &cfg.Database.ConnMaxIdleTime,
+		"DB_MAX_REPLICA_LAG":        &cfg.Database.MaxReplicaLag,
+		"DB_MIGRATION_TIMEOUT":      &cfg.Database.MigrationTimeout,
+		"JWT_ACCESS_TTL":            &cfg.Auth.AccessTTL,
+		"JWT_REFRESH_TTL":           &cfg.Auth.RefreshTTL,
+		"SESSION_TTL":               &cfg.Session.TTL,
+		"JOBS_POLL_INTERVAL":        &cfg.Jobs.PollInterval,
+		"CORS_MAX_AGE":              &cfg.CORS.MaxAge,
+		"JOBS_LEASE":                &cfg.Jobs.Lease,
+		"IDEMPOTENCY_TTL":           &cfg.Idempotency.TTL,
+		"IDEMPOTENCY_LOCK":          &cfg.Idempotency.Lock,
+		"RESTART_TIMEOUT":           &cfg.Restart.Timeout,
+		"CACHE_TTL":                 &cfg.Cache.TTL,
+		"DATA_CACHE_TTL":            &cfg.DataCache.TTL,
+		"AUTOSTOP_AFTER":            &cfg.Autostop.After,
+		"UPLOADS_URL_TTL":           &cfg.Uploads.URLTTL,
+		"UPLOADS_TIMEOUT":           &cfg.Uploads.Timeout,
+		"WEBHOOKS_TIMEOUT":          &cfg.Webhooks.Timeout,
+		"MIRROR_TIMEOUT":            &cfg.Mirror.Timeout,
+		"CANARY_TIMEOUT":            &cfg.Canary.Timeout,
+		"SHED_MAX_LATENCY":          &cfg.Shedding.MaxLatency,
+		"SHED_WINDOW":               &cfg.Shedding.Window,
+		"SHED_RETRY_AFTER":          &cfg.Shedding.RetryAfter,
+		"ADMISSION_LOW_WAIT":        &cfg.Admission.Low.Wait,
+		"ADMISSION_NORMAL_WAIT":     &cfg.Admission.Normal.Wait,
+		"PLUGINS_TIMEOUT":           &cfg.Plugins.Timeout,
+		"SIGNED_URL_TTL":            &cfg.SignedURLs.TTL,
+		"SIGNED_URL_MAX_TTL":        &cfg.SignedURLs.MaxTTL,
+		"WEBHOOKS_TOLERANCE":        &cfg.Webhooks.Tolerance,
+		"SCHEDULER_LEASE":           &cfg.Scheduler.Lease,
+		"MAINTENANCE_RETRY_AFTER":   &cfg.Maintenance.RetryAfter,
+		"MAINTENANCE_GRACE":         &cfg.Maintenance.Grace,
+		"HSTS_MAX_AGE":              &cfg.Security.HSTSMaxAge,
+		"MAIL_TIMEOUT":              &cfg.Mail.Timeout,
+		"ACCOUNTS_VERIFY_TTL":       &cfg.Accounts.VerifyTTL,
+		"ACCOUNTS_RESET_TTL":        &cfg.Accounts.ResetTTL,
+		"BUS_TIMEOUT":               &cfg.Bus.Timeout,
+		"BUS_RETRY_DELAY":           &cfg.Bus.RetryDelay,
+		"BUS_OUTBOX_INTERVAL":       &cfg.Bus.OutboxPollInterval,
+		"PRESENCE_TTL":              &cfg.Presence.TTL,
+		"REALTIME_POLL_TIMEOUT":     &cfg.Realtime.PollTimeout,
+		"JOURNAL_INTERVAL":          &cfg.Journal.Interval,
+		"SLO_PERIOD":                &cfg.SLO.Period,
+		"LOG_DEBUG_TTL":             &cfg.LogDebugTTL,
+		"REQUEST_SIGNING_TOLERANCE": &cfg.RequestSigning.Tolerance,
+		"KV_BACKUP_INTERVAL":        &cfg.KV.BackupInterval,
+		"TELEMETRY_INTERVAL":        &cfg.Telemetry.Interval,
+	} {
+		if err := envDuration(key, dst); err != nil {
+			return err
+		}
+	}
+	for key, dst := range map[string]*time.Time{
+		"API_V1_DEPRECATED_AT": &cfg.API.V1DeprecatedAt,
+		"API_V1_SUNSET_AT":     &cfg.API.V1SunsetAt,
+	} {
+		if err := envTime(key, dst); err != nil {
+			return err
+		}
+	}
+	for key, dst := range map[string]*int{
+		"DB_MAX_OPEN_CONNS":        &cfg.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":        &cfg.Database.MaxIdleConns,
+		"RATE_LIMIT_BURST":         &cfg.RateLimit.Burst,
+		"JOBS_CONCURRENCY":         &cfg.Jobs.Concurrency,
+		"JOBS_MAX_ATTEMPTS":        &cfg.Jobs.MaxAttempts,
+		"MAX_HEADER_BYTES":         &cfg.Limits.MaxHeaderBytes,
+		"COMPRESSION_MIN_SIZE":     &cfg.Compression.MinSize,
+		"WEBHOOKS_MAX_ATTEMPTS":    &cfg.Webhooks.MaxAttempts,
+		"MAIL_SMTP_PORT":           &cfg.Mail.SMTPPort,
+		"MAIL_MAX_ATTEMPTS":        &cfg.Mail.MaxAttempts,
+		"GRAPHQL_MAX_COMPLEXITY":   &cfg.GraphQL.MaxComplexity,
+		"AUDIT_MAX_BODY_BYTES":     &cfg.Audit.MaxBodyBytes,
+		"BUS_CONCURRENCY":          &cfg.Bus.Concurrency,
+		"BODY_LOG_MAX_BYTES":       &cfg.BodyLog.MaxBytes,
+		"BUS_MAX_ATTEMPTS":         &cfg.Bus.MaxAttempts,
+		"BUS_OUTBOX_BATCH":         &cfg.Bus.OutboxBatch,
+		"MIRROR_MAX_BYTES":         &cfg.Mirror.MaxBytes,
+		"CONTRACT_MAX_BYTES":       &cfg.Contract.MaxBytes,
+		"MIRROR_QUEUE":             &cfg.Mirror.Queue,
+		"MIRROR_CONCURRENCY":       &cfg.Mirror.Concurrency,
+		"PLUGINS_MAX_MEMORY":       &cfg.Plugins.MaxMemory,
+		"BATCH_MAX_ITEMS":          &cfg.Batch.MaxItems,
+		"BATCH_CONCURRENCY":        &cfg.Batch.Concurrency,
+		"IMPORT_MAX_ERRORS":        &cfg.Imports.MaxErrors,
+		"SHED_MAX_GOROUTINES":      &cfg.Shedding.MaxGoroutines,
+		"ADMISSION_LOW_LIMIT":      &cfg.Admission.Low.Limit,
+		"ADMISSION_LOW_QUEUE":      &cfg.Admission.Low.Queue,
+		"ADMISSION_NORMAL_LIMIT":   &cfg.Admission.Normal.Limit,
+		"ADMISSION_NORMAL_QUEUE":   &cfg.Admission.Normal.Queue,
+		"KV_BACKUP_KEEP":           &cfg.KV.BackupKeep,
+		"UPLOADS_THUMBNAIL_SIZE":   &cfg.Uploads.ThumbnailSize,
+		"UPLOADS_PROCESS_ATTEMPTS": &cfg.Uploads.ProcessAttempts,
+	} {
+		if err := envInt(key, dst); err != nil {
+			return err
+		}
+	}
+	for key, dst := range map[string]*bool{
+		"DB_AUTO_MIGRATE":           &cfg.Database.AutoMigrate,
+		"RATE_LIMIT_ENABLED":        &cfg.RateLimit.Enabled,
+		"SESSION_SECURE":            &cfg.Session.Secure,
+		"TENANCY_ENABLED":           &cfg.Tenancy.Enabled,
+		"TENANCY_REQUIRED":          &cfg.Tenancy.Required,
+		"WEB_ENABLED":               &cfg.Web.Enabled,
+		"WEB_SPA":                   &cfg.Web.SPA,
+		"VIEWS_ENABLED":             &cfg.Views.Enabled,
+		"CORS_ALLOW_CREDENTIALS":    &cfg.CORS.AllowCredentials,
+		"RESTART_ENABLED":           &cfg.Restart.Enabled,
+		"CACHE_ENABLED":             &cfg.Cache.Enabled,
+		"DATA_CACHE_ENABLED":        &cfg.DataCache.Enabled,
+		"CANARY_ENABLED":            &cfg.Canary.Enabled,
+		"SHED_ENABLED":              &cfg.Shedding.Enabled,
+		"ADMISSION_ENABLED":         &cfg.Admission.Enabled,
+		"GEO_HEADERS":               &cfg.Geo.Headers,
+		"QUOTA_ENABLED":             &cfg.Quota.Enabled,
+		"PRESENCE_ENABLED":          &cfg.Presence.Enabled,
+		"H2C_ENABLED":               &cfg.Protocols.H2C,
+		"HTTP3_ENABLED":             &cfg.Protocols.HTTP3,
+		"CHAOS_ENABLED":             &cfg.Chaos.Enabled,
+		"CHAOS_HEADERS":             &cfg.Chaos.Headers,
+		"COMPRESSION_ENABLED":       &cfg.Compression.Enabled,
+		"WEBHOOKS_ALLOW_PRIVATE":    &cfg.Webhooks.AllowPrivate,
+		"SCHEDULER_ENABLED":         &cfg.Scheduler.Enabled,
+		"MAINTENANCE_MODE":          &cfg.Maintenance.Enabled,
+		"SECURITY_HEADERS":          &cfg.Security.Enabled,
+		"CSP_REPORT_ONLY":           &cfg.Security.CSPReportOnly,
+		"MAIL_SMTP_IMPLICIT_TLS":    &cfg.Mail.SMTPImplicitTLS,
+		"HSTS_INCLUDE_SUBDOMAINS":   &cfg.Security.HSTSIncludeSubdomains,
+		"HSTS_PRELOAD":              &cfg.Security.HSTSPreload,
+		"ACCOUNTS_REGISTRATION":     &cfg.Accounts.Registration,
+		"REQUIRE_IF_MATCH":          &cfg.Preconditions.Required,
+		"ACCOUNTS_REQUIRE_VERIFIED": &cfg.Accounts.RequireVerified,
+		"GRAPHQL_ENABLED":           &cfg.GraphQL.Enabled,
+		"GRAPHQL_PLAYGROUND":        &cfg.GraphQL.Playground,
+		"BODY_LOG_ENABLED":          &cfg.BodyLog.Enabled,
+		"ADMIN_METRICS":             &cfg.Admin.Metrics,
+		"TELEMETRY_LOGS":            &cfg.Telemetry.Logs,
+	} {
+		if err := envBool(key, dst); err != nil {
+			return err
+		}
+	}
+
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		// SCHEDULE_CLEANUP="0 3 * * *" moves the cleanup task to 03:00.
+		if name, ok := strings.CutPrefix(key, scheduleEnvPrefix); ok {
+			if cfg.Scheduler.Schedules == nil {
+				cfg.Scheduler.Schedules = map[string]string{}
+			}
+			cfg.Scheduler.Schedules[strings.ToLower(name)] = value
+			continue
+		}
+		if !strings.HasPrefix(key, featureEnvPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, featureEnvPrefix)
+		if cfg.Features == nil {
+			cfg.Features = map[string]bool{}
+		}
+		// FEATURE_NAME=25% enables the feature for a quarter of callers.
+		if pct, ok := strings.CutSuffix(value, "%"); ok {
+			n, err := strconv.Atoi(pct)
+			if err != nil {
+				return fmt.Errorf("config: %s: %w", key, err)
+			}
+			if cfg.Rollouts == nil {
+				cfg.Rollouts = map[string]int{}
+			}
+			cfg.Features[name] = true
+			cfg.Rollouts[name] = n
+			continue
+		}
+		on, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+		cfg.Features[name] = on
+	}
+	return nil
+}
+
+func envString(key string, dst *string) {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		*dst = v
+	}
+}
+
+// envList splits a comma-separated value, dropping empty items.
+func envList(key string, dst *[]string) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*dst = list
+}
+
+// envProxyRoutes parses comma-separated prefix=upstream pairs. Routes
+// given this way strip their prefix and use the default timeout; the config
+// file offers the remaining options.
+func envProxyRoutes(key string, dst *[]ProxyRoute) error {
+	var pairs []string
+	envList(key, &pairs)
+	if pairs == nil {
+		return nil
+	}
+	routes := make([]ProxyRoute, 0, len(pairs))
+	for _, pair := range pairs {
+		prefix, upstream, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("config: %s: %q is not prefix=upstream", key, pair)
+		}
+		routes = append(routes, ProxyRoute{Prefix: prefix, Upstream: upstream, StripPrefix: true})
+	}
+	*dst = routes
+	return nil
+}
+
+// envOIDCProviders configures a provider for every OIDC_<NAME>_CLIENT_ID,
+// reading OIDC_<NAME>_CLIENT_SECRET, _ISSUER_URL and _SCOPES alongside it.
+func envOIDCProviders(dst *map[string]OIDCProvider) {
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(key, oidcEnvPrefix)
+		if !ok {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, "_CLIENT_ID"); !ok || name == "" {
+			continue
+		}
+		prefix := oidcEnvPrefix + name + "_"
+		name = strings.ToLower(name)
+		if *dst == nil {
+			*dst = make(map[string]OIDCProvider)
+		}
+		p := (*dst)[name]
+		envString(prefix+"CLIENT_ID", &p.ClientID)
+		envString(prefix+"CLIENT_SECRET", &p.ClientSecret)
+		envString(prefix+"ISSUER_URL", &p.IssuerURL)
+		envList(prefix+"SCOPES", &p.Scopes)
+		(*dst)[name] = p
+	}
+}
+
+func envDuration(key string, dst *time.Duration) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
+
+// envTime accepts RFC 3339 timestamps or plain 2006-01-02 dates (UTC).
+func envTime(key string, dst *time.Time) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, v); err != nil {
+			return fmt.Errorf("config: %s: want RFC 3339 or YYYY-MM-DD: %w", key, err)
+		}
+	}
+	*dst = t
+	return nil
+}
+
+func envInt(key string, dst *int) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envInt64(key string, dst *int64) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envFloat(key string, dst *float64) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", key, err)
+	}
+	*dst = f
+	return nil
+}
+
+func envBool(key string, dst *bool) error {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+type flags struct {
+	configFile     string
+	profile        string
+	addr           string
+	logLevel       string
+	timeouts       Timeouts
+	features       featureFlags
+	dbURL          string
+	grpcAddr       string
+	playground     bool
+	migrateOnly    bool
+	skipMigrations bool
+}
+
+func registerFlags(fs *flag.FlagSet) *flags {
+	fl := &flags{features: featureFlags{}}
+	fs.StringVar(&fl.configFile, "config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	fs.StringVar(&fl.profile, "profile", "", "config profile: dev, staging or prod (env CONFIG_PROFILE)")
+	fs.StringVar(&fl.addr, "addr", "", "listen address, e.g. :9090 (env LISTEN_ADDR or PORT)")
+	fs.StringVar(&fl.logLevel, "log-level", "", "log level: debug, info, warn, error (env LOG_LEVEL)")
+	fs.DurationVar(&fl.timeouts.ReadHeader, "read-header-timeout", 0, "HTTP request header read timeout (env READ_HEADER_TIMEOUT)")
+	fs.DurationVar(&fl.timeouts.Read, "read-timeout", 0, "HTTP read timeout (env READ_TIMEOUT)")
+	fs.DurationVar(&fl.timeouts.Write, "write-timeout", 0, "HTTP write timeout (env WRITE_TIMEOUT)")
+	fs.DurationVar(&fl.timeouts.Idle, "idle-timeout", 0, "HTTP keep-alive idle timeout (env IDLE_TIMEOUT)")
+	fs.DurationVar(&fl.timeouts.Handler, "handler-timeout", 0, "API handler deadline (env HANDLER_TIMEOUT)")
+	fs.DurationVar(&fl.timeouts.Shutdown, "shutdown-timeout", 0, "connection drain period on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&fl.dbURL, "database-url", "", "database connection URL (env DATABASE_URL)")
+	fs.StringVar(&fl.grpcAddr, "grpc-addr", "", "gRPC listen address, empty disables (env GRPC_ADDR)")
+	fs.BoolVar(&fl.playground, "graphql-playground", false, "serve the GraphiQL playground on GET /graphql, for development (env GRAPHQL_PLAYGROUND)")
+	fs.BoolVar(&fl.migrateOnly, "migrate-only", false, "apply the pending migrations and exit, for release commands")
+	fs.BoolVar(&fl.skipMigrations, "skip-migrations", false, "never apply migrations, wait for the schema instead (env DB_AUTO_MIGRATE=false)")
+	fs.Var(fl.features, "feature", "toggle a feature flag as name=bool; repeatable (env FEATURE_<NAME>)")
+	return fl
+}
+
+// apply copies only the flags that were given explicitly, so unset flags
+// never mask values from the file or the environment.
+func (fl *flags) apply(cfg *Config, fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = fl.addr
+		case "log-level":
+			cfg.LogLevel = fl.logLevel
+		case "read-header-timeout":
+			cfg.Timeouts.ReadHeader = fl.timeouts.ReadHeader
+		case "handler-timeout":
+			cfg.Timeouts.Handler = fl.timeouts.Handler
+		case "read-timeout":
+			cfg.Timeouts.Read = fl.timeouts.Read
+		case "write-timeout":
+			cfg.Timeouts.Write = fl.timeouts.Write
+		case "idle-timeout":
+			cfg.Timeouts.Idle = fl.timeouts.Idle
+		case "shutdown-timeout":
+			cfg.Timeouts.Shutdown = fl.timeouts.Shutdown
+		case "database-url":
+			cfg.Database.URL = fl.dbURL
+		case "grpc-addr":
+			cfg.GRPC.Addr = fl.grpcAddr
+		case "graphql-playground":
+			cfg.GraphQL.Playground = fl.playground
+		case "migrate-only":
+			cfg.Database.MigrateOnly = fl.migrateOnly
+			cfg.Database.AutoMigrate = cfg.Database.AutoMigrate || fl.migrateOnly
+		case "skip-migrations":
+			cfg.Database.AutoMigrate = !fl.skipMigrations
+		case "feature":
+			if cfg.Features == nil {
+				cfg.Features = map[string]bool{}
+			}
+			for name, on := range fl.features {
+				cfg.Features[name] = on
+			}
+		}
+	})
+}
+
+// featureFlags implements flag.Value for repeated --feature name=bool flags.
+type featureFlags map[string]bool
+
+func (f featureFlags) String() string {
+	parts := make([]string, 0, len(f))
+	for name, on := range f {
+		parts = append(parts, name+"="+strconv.FormatBool(on))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f featureFlags) Set(v string) error {
+	name, value, found := strings.Cut(v, "=")
+	if name == "" {
+		return fmt.Errorf("feature name must not be empty")
+	}
+	on := true
+	if found {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("feature %s: %w", name, err)
+		}
+		on = b
+	}
+	f[name] = on
+	return nil
+}
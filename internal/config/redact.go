@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"go-flylike-example/internal/secrets"
+)
+
+// redactedValue replaces secrets in Redacted.
+const redactedValue = secrets.Scrubbed
+
+// secretKeys are the config keys, or key suffixes, holding credentials.
+var secretKeys = []string{"secret", "secrets", "password", "token", "api_key", "access_key_id", "secret_access_key", "authorization"}
+
+// Redacted returns the configuration keyed like the config file, with
+// credentials and decrypted values replaced and passwords stripped from
+// connection URLs, for display.
+func (c *Config) Redacted() (map[string]any, error) {
+	doc, err := c.document()
+	if err != nil {
+		return nil, fmt.Errorf("config: redact: %w", err)
+	}
+	redact(doc, func(string) {})
+	return doc, nil
+}
+
+// rememberCredentials has the values Redacted replaces scrubbed from logs
+// too.
+func (c *Config) rememberCredentials() error {
+	doc, err := c.document()
+	if err != nil {
+		return fmt.Errorf("config: redact: %w", err)
+	}
+	redact(doc, secrets.Remember)
+	return nil
+}
+
+// document returns c keyed like the config file.
+func (c *Config) document() (map[string]any, error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// redact replaces the credentials in v, passing each to replaced.
+func redact(v any, replaced func(string)) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if list, ok := value.([]any); ok && len(list) > 0 && isSecretKey(key) {
+				for _, item := range list {
+					if s, ok := item.(string); ok {
+						replaced(s)
+					}
+				}
+				v[key] = redactedValue
+				continue
+			}
+			if s, ok := value.(string); ok && s != "" {
+				switch {
+				case isSecretKey(key):
+					replaced(s)
+					v[key] = redactedValue
+				case secrets.Known(s):
+					v[key] = redactedValue
+				case strings.HasSuffix(key, "url"):
+					if u, err := url.Parse(s); err == nil && u.User != nil {
+						if password, ok := u.User.Password(); ok {
+							replaced(password)
+						}
+						v[key] = u.Redacted()
+					}
+				}
+				continue
+			}
+			redact(value, replaced)
+		}
+	case []any:
+		for i, item := range v {
+			if s, ok := item.(string); ok && secrets.Known(s) {
+				v[i] = redactedValue
+				continue
+			}
+			redact(item, replaced)
+		}
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if key == s || strings.HasSuffix(key, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Package contract checks the API against its OpenAPI document with
+// recorded traffic. A fixture is one request and the response it got,
+// captured by Recorder, for example in staging, or written by hand. A
+// Harness sends the requests of fixtures again to an instance of the
+// server and checks that the recorded and the new responses both match the
+// schemas of the document, and that the status did not change; package
+// contracttest runs it from go test against an in-process server.
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fixture is a recorded exchange.
+type Fixture struct {
+	// Name is the file the fixture was loaded from, without .json.
+	Name     string   `json:"-"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded request. Path holds the query string too.
+type Request struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitzero"`
+}
+
+// Response is the recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitzero"`
+}
+
+// Body is a recorded body: JSON inline, so that fixtures stay readable and
+// editable, and any other text as Text.
+type Body struct {
+	JSON json.RawMessage `json:"json,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// IsZero reports whether the body is empty.
+func (b Body) IsZero() bool {
+	return len(b.JSON) == 0 && b.Text == ""
+}
+
+// Bytes returns the body as sent.
+func (b Body) Bytes() []byte {
+	if len(b.JSON) > 0 {
+		return b.JSON
+	}
+	return []byte(b.Text)
+}
+
+// Load reads the fixtures of dir, the *.json files, in the order of their
+// names, which is the order Recorder recorded them in.
+func Load(dir string) ([]Fixture, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	fixtures := make([]Fixture, 0, len(files))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("contract: %s: %w", file, err)
+		}
+		if f.Request.Method == "" || !strings.HasPrefix(f.Request.Path, "/") {
+			return nil, fmt.Errorf("contract: %s: request needs a method and a path", file)
+		}
+		f.Name = strings.TrimSuffix(filepath.Base(file), ".json")
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
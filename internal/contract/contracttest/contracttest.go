@@ -0,0 +1,135 @@
+// Package contracttest replays the fixtures of package contract from go
+// test, against the server assembled in process on an in-memory database:
+//
+//	func TestContract(t *testing.T) {
+//		contracttest.Run(t, "testdata/contract")
+//	}
+//
+// Record the fixtures with CONTRACT_RECORD_DIR, copy those worth keeping,
+// and edit them where the state of the instance they were recorded on
+// matters, such as the IDs in their paths, or where a redacted value made
+// the request fail.
+package contracttest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/contract"
+	"go-flylike-example/internal/server"
+	"go-flylike-example/internal/store"
+)
+
+// User is the admin whose token the harness of New sends.
+const User = "contract"
+
+// Run replays the fixtures of dir against a server from New, and skips t
+// when there are none.
+func Run(t testing.TB, dir string, configure ...func(*config.Config)) {
+	t.Helper()
+	fixtures, err := contract.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Skipf("no contract fixtures in %s", dir)
+	}
+	Replay(t, New(t, configure...), fixtures...)
+}
+
+// Replay checks every fixture in order, failing t with each problem found.
+func Replay(t testing.TB, h *contract.Harness, fixtures ...contract.Fixture) {
+	t.Helper()
+	for _, f := range fixtures {
+		for _, err := range h.Check(t.Context(), f) {
+			t.Errorf("%s: %s %s: %v", f.Name, f.Request.Method, f.Request.Path, err)
+		}
+	}
+}
+
+// New starts a server for the duration of t, with the default
+// configuration changed by configure, and returns a harness sending to it
+// as User, an admin. The optional API routes that configuration turns on,
+// such as presence, are on; background workers are not started.
+func New(t testing.TB, configure ...func(*config.Config)) *contract.Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	password := rand.Text()
+	cfg := config.Default()
+	cfg.Database.URL = "file:contract-" + rand.Text() + "?mode=memory&cache=shared"
+	cfg.Auth.JWTSecret = rand.Text() + rand.Text()
+	cfg.Auth.DemoUser, cfg.Auth.DemoPassword = User, password
+	cfg.RBAC.Admins = []string{User}
+	cfg.Presence.Enabled = true
+	// Replays come in faster than clients would.
+	cfg.RateLimit.Enabled = false
+	for _, f := range configure {
+		f(cfg)
+	}
+
+	db, err := store.Open(t.Context(), cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := server.New(config.NewLive(cfg, nil), db, nil, slog.New(slog.DiscardHandler), server.Deps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	h := &contract.Harness{URL: ts.URL, Client: ts.Client()}
+	doc := get(t, ts.Client(), ts.URL+"/openapi.json")
+	if h.Spec, err = contract.ParseSpec(doc); err != nil {
+		t.Fatal(err)
+	}
+	token := login(t, ts, password)
+	h.Header = http.Header{"Authorization": {"Bearer " + token}}
+	return h
+}
+
+func get(t testing.TB, client *http.Client, url string) []byte {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get %s: %s %v", url, resp.Status, err)
+	}
+	return b
+}
+
+func login(t testing.TB, ts *httptest.Server, password string) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"username": User, "password": password})
+	resp, err := ts.Client().Post(ts.URL+"/auth/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Data.AccessToken == "" {
+		t.Fatalf("log in as %s: %s %v", User, resp.Status, err)
+	}
+	return out.Data.AccessToken
+}
@@ -0,0 +1,192 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/redact"
+)
+
+// requestHeaders and responseHeaders are kept in fixtures. Others are left
+// out: credentials, so that fixtures can be shared, and the rest, such as
+// the headers of proxies, as noise.
+var (
+	requestHeaders  = []string{"Accept", "Accept-Language", "Content-Type", "If-Match", "If-None-Match", "If-Modified-Since"}
+	responseHeaders = []string{"Content-Type", "Location"}
+)
+
+// seq orders the fixtures recorded within a nanosecond tick.
+var seq atomic.Uint64
+
+// Recorder writes a sample of the exchanges to the directory of the
+// contract configuration of live, as fixtures named after the time and
+// the route, so that Load replays them in order. Reloads apply to the
+// next request.
+func Recorder(live *config.Live) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := live.Load().Contract
+		if cfg.Dir == "" || !sampled(cfg.SamplePercent) || !below(c.Request.URL.Path, cfg.Paths) ||
+			c.GetHeader("Upgrade") != "" || c.Request.ContentLength > int64(cfg.MaxBytes) {
+			c.Next()
+			return
+		}
+		var request []byte
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			head, cut, err := logging.PeekBody(c.Request, cfg.MaxBytes)
+			if cut || err != nil {
+				c.Next()
+				return
+			}
+			request = head
+		}
+		w := &bodyRecorder{ResponseWriter: c.Writer, max: cfg.MaxBytes}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		// Unmatched routes are not in the document, and cut or streamed
+		// responses cannot be checked.
+		contentType := w.Header().Get("Content-Type")
+		if c.FullPath() == "" || w.cut || strings.HasPrefix(contentType, "text/event-stream") {
+			return
+		}
+		f := Fixture{
+			Request: Request{
+				Method: c.Request.Method,
+				Path:   redactedPath(c.Request.URL, cfg.Redact),
+				Header: keep(c.Request.Header, requestHeaders),
+				Body:   body(c.Request.Header.Get("Content-Type"), request, cfg.Redact),
+			},
+			Response: Response{
+				Status: w.Status(),
+				Header: keep(w.Header(), responseHeaders),
+				Body:   body(contentType, w.buf.Bytes(), cfg.Redact),
+			},
+		}
+		if err := write(cfg.Dir, c.Request.Method, c.FullPath(), f); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("contract fixture not recorded", "error", err)
+		}
+	}
+}
+
+func sampled(percent float64) bool {
+	return percent >= 100 || rand.Float64()*100 < percent
+}
+
+func below(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedPath returns the path and query of u without signed link
+// parameters and with the sensitive query parameters blanked.
+func redactedPath(u *url.URL, names []string) string {
+	if q := redact.Query(u.Query(), names); q != "" {
+		return u.Path + "?" + q
+	}
+	return u.Path
+}
+
+func keep(h http.Header, names []string) http.Header {
+	kept := http.Header{}
+	for _, name := range names {
+		if v := h.Values(name); len(v) > 0 {
+			kept[name] = v
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// body returns b as recorded: JSON with the sensitive members blanked,
+// forms likewise, other text as it is and binary data as its size.
+func body(contentType string, b []byte, names []string) Body {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return Body{}
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		if doc := redact.JSON(b, names); doc != nil {
+			return Body{JSON: doc}
+		}
+	case mt == "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(string(b)); err == nil {
+			for k := range form {
+				if redact.Sensitive(k, names) {
+					form[k] = []string{redact.Placeholder}
+				}
+			}
+			return Body{Text: form.Encode()}
+		}
+	}
+	if !utf8.Valid(b) {
+		return Body{Text: fmt.Sprintf("[%s, %d bytes]", mt, len(b))}
+	}
+	return Body{Text: string(b)}
+}
+
+// write stores f in dir as <time>-<method>-<route>.json.
+func write(dir, method, route string, f Fixture) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	route = strings.NewReplacer("/", "-", ":", "", "*", "").Replace(strings.Trim(route, "/"))
+	name := fmt.Sprintf("%s.%03d-%s-%s.json", time.Now().UTC().Format("20060102T150405.000000000"),
+		seq.Add(1)%1000, strings.ToLower(method), route)
+	return os.WriteFile(filepath.Join(dir, name), append(b, '\n'), 0o644)
+}
+
+// bodyRecorder keeps the first max bytes of the response body.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+	max int
+	cut bool
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.capture(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) WriteString(s string) (int, error) {
+	r.capture([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *bodyRecorder) capture(b []byte) {
+	if room := r.max - r.buf.Len(); len(b) > room {
+		b = b[:max(room, 0)]
+		r.cut = true
+	}
+	r.buf.Write(b)
+}
@@ -0,0 +1,85 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxResponse bounds a replayed response body read.
+const maxResponse = 8 << 20
+
+// Harness replays fixtures against the server at URL.
+type Harness struct {
+	URL  string
+	Spec *Spec
+	// Client sends the requests; redirects are not followed. Nil uses a
+	// client like http.DefaultClient.
+	Client *http.Client
+	// Header is set on every request, such as the credentials of the
+	// instance under test: Recorder does not keep the recorded ones.
+	Header http.Header
+}
+
+// Check replays the request of f and returns how the exchange breaks the
+// contract: the route is not documented, the recorded request or response
+// do not match the document, the new response does not either, or its
+// status is not the recorded one. Bodies are compared with the schemas
+// only, as IDs and times differ from one instance to the next.
+func (h *Harness) Check(ctx context.Context, f Fixture) []error {
+	op, ok := h.Spec.Operation(f.Request.Method, f.Request.Path)
+	if !ok {
+		return []error{fmt.Errorf("not in the OpenAPI document")}
+	}
+	var errs []error
+	for _, err := range h.Spec.CheckRequest(op, f.Request.Header.Get("Content-Type"), f.Request.Body.Bytes()) {
+		errs = append(errs, fmt.Errorf("recorded %w", err))
+	}
+	for _, err := range h.Spec.CheckResponse(op, f.Response.Status, f.Response.Header.Get("Content-Type"), f.Response.Body.Bytes()) {
+		errs = append(errs, fmt.Errorf("recorded %w", err))
+	}
+
+	status, contentType, body, err := h.send(ctx, f.Request)
+	if err != nil {
+		return append(errs, err)
+	}
+	if status != f.Response.Status {
+		errs = append(errs, fmt.Errorf("status %d, recorded %d", status, f.Response.Status))
+	}
+	return append(errs, h.Spec.CheckResponse(op, status, contentType, body)...)
+}
+
+func (h *Harness) send(ctx context.Context, rec Request) (status int, contentType string, body []byte, err error) {
+	var reqBody io.Reader
+	if !rec.Body.IsZero() {
+		reqBody = bytes.NewReader(rec.Body.Bytes())
+	}
+	r, err := http.NewRequestWithContext(ctx, rec.Method, h.URL+rec.Path, reqBody)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	for name, values := range rec.Header {
+		r.Header[name] = values
+	}
+	for name, values := range h.Header {
+		r.Header[name] = values
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	noRedirects := *client
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := noRedirects.Do(r)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer resp.Body.Close()
+	body, err = io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), body, nil
+}
@@ -0,0 +1,297 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Spec is the part of an OpenAPI document the checks read.
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*openapi.Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// Operation is a documented route.
+type Operation struct {
+	OperationID string `json:"operationId"`
+	RequestBody *struct {
+		Content map[string]content `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]content `json:"content"`
+	} `json:"responses"`
+}
+
+type content struct {
+	Schema *openapi.Schema `json:"schema"`
+}
+
+// anyRoute are the statuses any route can answer with a problem, such as
+// when rate limited or shedding load, which operations do not document.
+// A 304 answers a conditional request without a body.
+var anyRoute = map[int]bool{
+	http.StatusNotModified:         true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// ParseSpec reads an OpenAPI document, such as the one served at
+// /openapi.json.
+func ParseSpec(doc []byte) (*Spec, error) {
+	var s Spec
+	if err := json.Unmarshal(doc, &s); err != nil {
+		return nil, fmt.Errorf("contract: parse document: %w", err)
+	}
+	return &s, nil
+}
+
+// Operation returns the operation documenting method on path, which may
+// hold a query string. Fixed segments win over parameters, so that
+// /users/me is not taken for /users/{id}.
+func (s *Spec) Operation(method, path string) (*Operation, bool) {
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var best *Operation
+	bestFixed := -1
+	for template, methods := range s.Paths {
+		op, ok := methods[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+		parts := strings.Split(strings.Trim(template, "/"), "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		fixed := 0
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				continue
+			}
+			if part != segments[i] {
+				fixed = -1
+				break
+			}
+			fixed++
+		}
+		if fixed > bestFixed {
+			best, bestFixed = op, fixed
+		}
+	}
+	return best, best != nil
+}
+
+// CheckRequest returns how a request body of contentType breaks the
+// contract of op.
+func (s *Spec) CheckRequest(op *Operation, contentType string, body []byte) []error {
+	if op.RequestBody == nil {
+		return nil
+	}
+	schema, err := match(op.RequestBody.Content, contentType)
+	if err != nil {
+		return []error{fmt.Errorf("request: %w", err)}
+	}
+	return s.checkBody("request", schema, contentType, body)
+}
+
+// CheckResponse returns how a response with status, of contentType, breaks
+// the contract of op.
+func (s *Spec) CheckResponse(op *Operation, status int, contentType string, body []byte) []error {
+	documented, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if !anyRoute[status] {
+			return []error{fmt.Errorf("response: status %d is not documented", status)}
+		}
+		if len(body) == 0 {
+			return nil
+		}
+		return s.checkBody("response", &openapi.Schema{Ref: "#/components/schemas/Problem"}, contentType, body)
+	}
+	if len(documented.Content) == 0 {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return []error{fmt.Errorf("response: status %d is documented without a body", status)}
+		}
+		return nil
+	}
+	schema, err := match(documented.Content, contentType)
+	if err != nil {
+		return []error{fmt.Errorf("response: %w", err)}
+	}
+	return s.checkBody("response", schema, contentType, body)
+}
+
+// match returns the schema documented for contentType.
+func match(documented map[string]content, contentType string) (*openapi.Schema, error) {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if c, ok := documented[mt]; ok {
+		return c.Schema, nil
+	}
+	types := make([]string, 0, len(documented))
+	for t := range documented {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return nil, fmt.Errorf("content type %q is not documented, expected %s", mt, strings.Join(types, " or "))
+}
+
+// checkBody validates JSON bodies against schema; other formats are only
+// checked for their content type.
+func (s *Spec) checkBody(what string, schema *openapi.Schema, contentType string, body []byte) []error {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if schema == nil || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []error{fmt.Errorf("%s: invalid JSON: %w", what, err)}
+	}
+	var errs []error
+	s.validate(schema, v, what, &errs)
+	return errs
+}
+
+// validate appends to errs how v, found at at, breaks schema.
+func (s *Spec) validate(schema *openapi.Schema, v any, at string, errs *[]error) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, fmt.Errorf("%s: %s", at, fmt.Sprintf(format, args...)))
+	}
+	if schema.Ref != "" {
+		ref, ok := s.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			fail("unknown schema %s", schema.Ref)
+			return
+		}
+		schema = ref
+	}
+	if v == nil {
+		if !schema.Nullable && schema.Type != "" {
+			fail("null where %s expected", schema.Type)
+		}
+		return
+	}
+	switch schema.Type {
+	case "":
+		// Any value.
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("%s where object expected", kind(v))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				fail("missing required member %q", name)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(obj)) {
+			member := obj[name]
+			if ps, ok := schema.Properties[name]; ok {
+				s.validate(ps, member, at+"."+name, errs)
+			} else if schema.AdditionalProperties != nil {
+				s.validate(schema.AdditionalProperties, member, at+"."+name, errs)
+			} else if len(schema.Properties) > 0 {
+				// Clients generated from the document do not know it.
+				fail("undocumented member %q", name)
+			}
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			fail("%s where array expected", kind(v))
+			return
+		}
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			fail("%d items, at most %d allowed", len(items), *schema.MaxItems)
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				s.validate(schema.Items, item, at+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("%s where string expected", kind(v))
+			return
+		}
+		if err := checkString(schema, str); err != nil {
+			fail("%v", err)
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			fail("%s where %s expected", kind(v), schema.Type)
+			return
+		}
+		if _, err := n.Int64(); schema.Type == "integer" && err != nil {
+			fail("%s is not an integer", n)
+			return
+		}
+		f, _ := n.Float64()
+		if schema.Minimum != nil && f < *schema.Minimum {
+			fail("%s is below the minimum %v", n, *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			fail("%s is above the maximum %v", n, *schema.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("%s where boolean expected", kind(v))
+		}
+	}
+}
+
+// checkString checks the length and format of str.
+func checkString(schema *openapi.Schema, str string) error {
+	n := len([]rune(str))
+	if schema.MinLength != nil && n < *schema.MinLength {
+		return fmt.Errorf("%d characters, at least %d required", n, *schema.MinLength)
+	}
+	if schema.MaxLength != nil && n > *schema.MaxLength {
+		return fmt.Errorf("%d characters, at most %d allowed", n, *schema.MaxLength)
+	}
+	switch schema.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+			return fmt.Errorf("%q is not a date-time", str)
+		}
+	case "email":
+		if !strings.Contains(str, "@") {
+			return fmt.Errorf("%q is not an email address", str)
+		}
+	}
+	return nil
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
@@ -0,0 +1,217 @@
+// Package cors implements Cross-Origin Resource Sharing for browser
+// clients served from other origins. The policy comes from the config
+// subsystem and can be swapped at runtime.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+)
+
+// CORS applies the current policy to every request.
+type CORS struct {
+	policy atomic.Pointer[policy]
+}
+
+type policy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	patterns    []*regexp.Regexp
+	methods     string
+	methodSet   map[string]bool
+	headers     string
+	headerSet   map[string]bool
+	anyHeader   bool
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// New compiles cfg. With no allowed origins the middleware is a no-op.
+func New(cfg config.CORS) (*CORS, error) {
+	c := &CORS{}
+	if err := c.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetConfig replaces the policy; requests in flight keep the old one.
+func (c *CORS) SetConfig(cfg config.CORS) error {
+	p, err := compile(cfg)
+	if err != nil {
+		return err
+	}
+	c.policy.Store(p)
+	return nil
+}
+
+func compile(cfg config.CORS) (*policy, error) {
+	p := &policy{
+		origins:     make(map[string]bool),
+		methods:     strings.Join(upper(cfg.AllowedMethods), ", "),
+		methodSet:   make(map[string]bool),
+		headerSet:   make(map[string]bool),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	for _, o := range cfg.AllowedOrigins {
+		re, err := pattern(o)
+		switch {
+		case err != nil:
+			return nil, err
+		case o == "*" && cfg.AllowCredentials:
+			return nil, fmt.Errorf("cors: origin \"*\" cannot allow credentials")
+		case o == "*":
+			p.anyOrigin = true
+		case re != nil:
+			p.patterns = append(p.patterns, re)
+		default:
+			p.origins[strings.ToLower(o)] = true
+		}
+	}
+	for _, m := range upper(cfg.AllowedMethods) {
+		p.methodSet[m] = true
+	}
+	var headers []string
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			p.anyHeader = true
+			continue
+		}
+		headers = append(headers, h)
+		p.headerSet[strings.ToLower(h)] = true
+	}
+	p.headers = strings.Join(headers, ", ")
+	return p, nil
+}
+
+// pattern compiles an allowed-origin entry that is not a literal origin:
+// "~<regexp>" is a regular expression and an entry containing "*" matches
+// any run of host characters there, e.g. "https://*.example.com". It
+// returns nil for literal origins and for the lone "*".
+func pattern(origin string) (*regexp.Regexp, error) {
+	switch {
+	case strings.HasPrefix(origin, "~"):
+		re, err := regexp.Compile("(?i)" + origin[1:])
+		if err != nil {
+			return nil, fmt.Errorf("cors: origin %q: %w", origin, err)
+		}
+		return re, nil
+	case origin != "*" && strings.Contains(origin, "*"):
+		parts := strings.Split(origin, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		return regexp.MustCompile("(?i)^" + strings.Join(parts, "[a-z0-9.-]+") + "$"), nil
+	}
+	return nil, nil
+}
+
+func (p *policy) allowed(origin string) bool {
+	if p.anyOrigin || p.origins[strings.ToLower(origin)] {
+		return true
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware answers preflight requests itself and decorates every other
+// cross-origin response. It must run before authentication and rate
+// limiting so preflights, which carry no credentials, are not rejected.
+func (c *CORS) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		p := c.policy.Load()
+		origin := ctx.GetHeader("Origin")
+		if origin == "" || (len(p.origins) == 0 && len(p.patterns) == 0 && !p.anyOrigin) {
+			ctx.Next()
+			return
+		}
+
+		h := ctx.Writer.Header()
+		h.Add("Vary", "Origin")
+		preflight := ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		method := strings.ToUpper(ctx.GetHeader("Access-Control-Request-Method"))
+		requested := ctx.GetHeader("Access-Control-Request-Headers")
+		if !p.allowed(origin) || (preflight && (!p.methodSet[method] || !p.headersAllowed(requested))) {
+			// Without the allow headers the browser blocks the request.
+			if preflight {
+				ctx.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			ctx.Next()
+			return
+		}
+
+		if p.anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if p.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", p.exposed)
+			}
+			ctx.Next()
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", p.methods)
+		switch {
+		case p.anyHeader && requested != "":
+			h.Set("Access-Control-Allow-Headers", requested)
+		case p.headers != "":
+			h.Set("Access-Control-Allow-Headers", p.headers)
+		}
+		if p.maxAge != "" {
+			h.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+func (p *policy) headersAllowed(requested string) bool {
+	if p.anyHeader {
+		return true
+	}
+	for name := range strings.SplitSeq(requested, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && !p.headerSet[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func upper(list []string) []string {
+	out := slices.Clone(list)
+	for i, s := range out {
+		out[i] = strings.ToUpper(s)
+	}
+	return out
+}
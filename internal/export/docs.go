@@ -0,0 +1,33 @@
+package export
+
+import (
+	"net/http"
+	"strings"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func (e *Exporter) Operations() []openapi.Operation {
+	tags := []string{"export"}
+	var ops []openapi.Operation
+	for _, r := range e.resources {
+		names := make([]string, len(r.columns))
+		for i, col := range r.columns {
+			names[i] = col.name
+		}
+		params := append([]openapi.Param{
+			{Name: "format", Description: "csv (default) or parquet"},
+			{Name: "columns", Description: "Comma-separated columns to export, in order, of " + strings.Join(names, ", ") + "; all by default"},
+			{Name: "compress", Description: "gzip to download the file gzipped"},
+		}, r.options.Params()...)
+		ops = append(ops, openapi.Operation{
+			ID: "export" + strings.ToUpper(r.name[:1]) + r.name[1:], Method: http.MethodGet, Path: "/" + r.name, Tags: tags, Summary: "Export " + r.name, Auth: true,
+			Description: "Requires the " + r.perm + " permission. Streams every row the filters and sort select, whatever " +
+				"the limit; Range requests resume an interrupted download while the ETag still matches.",
+			Query: params, Response: "", ResponseType: csvFormat.contentType,
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+		})
+	}
+	return ops
+}
@@ -0,0 +1,312 @@
+// Package export serves whole resources as files for analysis: every row a
+// list query selects, as CSV or Parquet, optionally gzipped. Exports are
+// streamed as the rows are read rather than built first, so they start at
+// once and use little memory however large they get.
+//
+// An interrupted download can be resumed with a Range request. A stream
+// has no length until it ends, so a Range request renders the whole export
+// to a temporary file first and serves the bytes asked for from it; the
+// ETag of an export changes with its rows, so If-Range tells a resume
+// whose rows changed in between to start over.
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/stream"
+	"go-flylike-example/internal/tenant"
+)
+
+// Type is the type of a column's values.
+type Type int
+
+// Column types.
+const (
+	String Type = iota
+	Int
+	Time
+	Bool
+)
+
+// Column is a column of an exported resource of T.
+type Column[T any] struct {
+	Name string
+	Type Type
+	// Optional columns may be nil.
+	Optional bool
+	Value    func(T) any
+}
+
+// column is a Column without its value.
+type column struct {
+	name     string
+	typ      Type
+	optional bool
+}
+
+// Resource is a resource that can be exported.
+type Resource struct {
+	name    string
+	perm    string
+	options *query.Options
+	columns []column
+	rows    func(ctx context.Context, spec *query.Spec) iter.Seq2[[]any, error]
+	// revision changes whenever the rows do.
+	revision func(ctx context.Context) (string, error)
+}
+
+// Table returns the resource name, exported by subjects holding perm. Its
+// rows are those rows yields for the filters and sort of opts, and the
+// output changes only when revision does.
+func Table[T any](name, perm string, opts *query.Options, rows func(ctx context.Context, spec *query.Spec) iter.Seq2[T, error],
+	revision func(ctx context.Context) (string, error), cols ...Column[T]) *Resource {
+	r := &Resource{name: name, perm: perm, options: opts, revision: revision}
+	for _, col := range cols {
+		r.columns = append(r.columns, column{name: col.Name, typ: col.Type, optional: col.Optional})
+	}
+	r.rows = func(ctx context.Context, spec *query.Spec) iter.Seq2[[]any, error] {
+		return func(yield func([]any, error) bool) {
+			for item, err := range rows(ctx, spec) {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				values := make([]any, len(cols))
+				for i, col := range cols {
+					values[i] = col.Value(item)
+				}
+				if !yield(values, nil) {
+					return
+				}
+			}
+		}
+	}
+	return r
+}
+
+// Exporter serves the exports of its resources.
+type Exporter struct {
+	resources []*Resource
+}
+
+// New returns an Exporter of resources.
+func New(resources ...*Resource) *Exporter {
+	return &Exporter{resources: resources}
+}
+
+// Register mounts one route per resource on g:
+//
+//	GET /:resource   the export, for subjects holding its permission
+//
+// The routes stream, so g must not buffer responses or bound their time.
+func (e *Exporter) Register(g *gin.RouterGroup) {
+	for _, r := range e.resources {
+		g.GET("/"+r.name, rbac.Require(r.perm), r.handle)
+	}
+}
+
+// request is a parsed export request.
+type request struct {
+	spec    *query.Spec
+	format  format
+	columns []int
+	gzip    bool
+}
+
+func (r *Resource) parse(c *gin.Context) (*request, bool) {
+	spec, ok := query.Bind(c, r.options)
+	if !ok {
+		return nil, false
+	}
+	req := &request{spec: spec}
+	switch c.DefaultQuery("format", "csv") {
+	case "csv":
+		req.format = csvFormat
+	case "parquet":
+		req.format = parquetFormat
+	default:
+		c.Error(apperror.BadRequest("format must be csv or parquet"))
+		return nil, false
+	}
+	switch c.Query("compress") {
+	case "":
+	case "gzip":
+		req.gzip = true
+	default:
+		c.Error(apperror.BadRequest("compress must be gzip"))
+		return nil, false
+	}
+	if raw := c.Query("columns"); raw != "" {
+		for name := range strings.SplitSeq(raw, ",") {
+			i := slices.IndexFunc(r.columns, func(col column) bool { return col.name == strings.TrimSpace(name) })
+			if i < 0 {
+				c.Error(apperror.Newf(apperror.KindBadRequest, "unknown column %s", name))
+				return nil, false
+			}
+			if !slices.Contains(req.columns, i) {
+				req.columns = append(req.columns, i)
+			}
+		}
+	} else {
+		for i := range r.columns {
+			req.columns = append(req.columns, i)
+		}
+	}
+	return req, true
+}
+
+func (r *Resource) handle(c *gin.Context) {
+	req, ok := r.parse(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	revision, err := r.revision(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	name := r.name + req.format.ext
+	contentType := req.format.contentType
+	if req.gzip {
+		name += ".gz"
+		contentType = "application/gzip"
+	}
+	h := c.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	h.Set("ETag", r.etag(ctx, c, revision))
+	h.Set("Cache-Control", "private, no-cache")
+	h.Set("Accept-Ranges", "bytes")
+
+	if c.GetHeader("Range") != "" {
+		r.serveRange(c, req, name)
+		return
+	}
+	h.Set("Trailer", stream.TrailerError)
+	c.Status(http.StatusOK)
+	w := stream.NewWriter(c)
+	if err := r.write(ctx, w, req); err != nil {
+		if c.Writer.Written() {
+			stream.Fail(c, w.Flush, err)
+			return
+		}
+		for _, name := range []string{"Trailer", "Content-Disposition", "ETag", "Accept-Ranges"} {
+			h.Del(name)
+		}
+		c.Error(err)
+	}
+}
+
+// serveRange renders the export to a temporary file and serves the range
+// asked for from it, or the whole file when If-Range no longer matches.
+func (r *Resource) serveRange(c *gin.Context, req *request, name string) {
+	ctx := c.Request.Context()
+	f, err := os.CreateTemp("", "export-*")
+	if err != nil {
+		c.Error(apperror.Internal(err))
+		return
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	if err := r.write(ctx, f, req); err != nil {
+		c.Error(err)
+		return
+	}
+	http.ServeContent(c.Writer, c.Request, name, time.Time{}, f)
+}
+
+// write writes the export of req to w.
+func (r *Resource) write(ctx context.Context, w io.Writer, req *request) error {
+	var zw *gzip.Writer
+	if req.gzip {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	cols := make([]column, len(req.columns))
+	for i, idx := range req.columns {
+		cols[i] = r.columns[idx]
+	}
+	// The encoder starts writing once the first rows are read, so that an
+	// export the database fails right away is answered with an error.
+	var enc encoder
+	start := func() (err error) {
+		if enc == nil {
+			enc, err = req.format.encoder(w, r.name, cols)
+		}
+		return err
+	}
+	row := make([]any, len(cols))
+	n := 0
+	for values, err := range r.rows(ctx, req.spec) {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if err := start(); err != nil {
+			return apperror.Internal(err)
+		}
+		for i, idx := range req.columns {
+			row[i] = values[idx]
+		}
+		if err := enc.write(row); err != nil {
+			return r.gone(ctx, err)
+		}
+		n++
+	}
+	if err := start(); err != nil {
+		return apperror.Internal(err)
+	}
+	if err := enc.close(); err != nil {
+		return r.gone(ctx, err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return r.gone(ctx, err)
+		}
+	}
+	logging.FromContext(ctx).Info("export written", "resource", r.name, "rows", n, "format", req.format.ext[1:], "gzip", req.gzip)
+	return nil
+}
+
+// gone reports a failed write, which for a response is the client going
+// away.
+func (r *Resource) gone(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	return apperror.Internal(err)
+}
+
+// etag names the content of an export: the resource, the revision of its
+// rows and the parameters shaping the file, which make the same bytes
+// every time for the same rows.
+func (r *Resource) etag(ctx context.Context, c *gin.Context, revision string) string {
+	params := c.Request.URL.Query()
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s", r.name, tenant.ID(ctx), revision, params.Encode())
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
@@ -0,0 +1,203 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// encoder writes rows in a file format.
+type encoder interface {
+	write(row []any) error
+	// close writes what the format needs after the last row.
+	close() error
+}
+
+type format struct {
+	ext         string
+	contentType string
+	encoder     func(w io.Writer, name string, cols []column) (encoder, error)
+}
+
+var (
+	csvFormat     = format{ext: ".csv", contentType: "text/csv; charset=utf-8", encoder: newCSV}
+	parquetFormat = format{ext: ".parquet", contentType: "application/vnd.apache.parquet", encoder: newParquet}
+)
+
+// csvEncoder writes a header line with the column names, then a line per
+// row. Times are RFC 3339 in UTC and nil values empty. Cells that a
+// spreadsheet would take for a formula get a leading "'".
+type csvEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSV(w io.Writer, _ string, cols []column) (encoder, error) {
+	e := &csvEncoder{w: csv.NewWriter(w), record: make([]string, len(cols))}
+	for i, col := range cols {
+		e.record[i] = col.name
+	}
+	return e, e.w.Write(e.record)
+}
+
+func (e *csvEncoder) write(row []any) error {
+	for i, v := range row {
+		e.record[i] = text(v)
+		switch v.(type) {
+		case int, int64:
+			// Negative numbers stay numbers.
+		default:
+			e.record[i] = defuse(e.record[i])
+		}
+	}
+	return e.w.Write(e.record)
+}
+
+// defuse prefixes s with "'" if it starts with a character that makes
+// spreadsheets evaluate a cell, so an exported value cannot run as a
+// formula on the machine of whoever opens the file.
+func defuse(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (e *csvEncoder) close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// parquetRowGroup is how many rows a Parquet row group holds; each is
+// written out once full, so memory stays bounded.
+const parquetRowGroup = 10000
+
+// parquetEncoder writes a Parquet file, snappy compressed. The format
+// orders the columns of its schema by name, whatever order they were
+// asked in.
+type parquetEncoder struct {
+	w *parquet.Writer
+	// index is the schema column of each column of a row.
+	index []int
+	cols  []column
+	rows  int
+	row   parquet.Row
+}
+
+func newParquet(w io.Writer, name string, cols []column) (encoder, error) {
+	group := parquet.Group{}
+	for _, col := range cols {
+		var node parquet.Node
+		switch col.typ {
+		case Int:
+			node = parquet.Int(64)
+		case Time:
+			node = parquet.Timestamp(parquet.Microsecond)
+		case Bool:
+			node = parquet.Leaf(parquet.BooleanType)
+		default:
+			node = parquet.String()
+		}
+		if col.optional {
+			node = parquet.Optional(node)
+		}
+		group[col.name] = node
+	}
+	schema := parquet.NewSchema(name, group)
+	e := &parquetEncoder{
+		w:     parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy)),
+		index: make([]int, len(cols)),
+		cols:  cols,
+		row:   make(parquet.Row, len(cols)),
+	}
+	for i, path := range schema.Columns() {
+		for j, col := range cols {
+			if path[0] == col.name {
+				e.index[j] = i
+			}
+		}
+	}
+	return e, nil
+}
+
+func (e *parquetEncoder) write(row []any) error {
+	for j, v := range row {
+		i, col := e.index[j], e.cols[j]
+		value := parquetValue(col.typ, v)
+		def := 0
+		if col.optional && !value.IsNull() {
+			def = 1
+		}
+		e.row[i] = value.Level(0, def, i)
+	}
+	if _, err := e.w.WriteRows([]parquet.Row{e.row}); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%parquetRowGroup == 0 {
+		return e.w.Flush()
+	}
+	return nil
+}
+
+func (e *parquetEncoder) close() error {
+	return e.w.Close()
+}
+
+func parquetValue(typ Type, v any) parquet.Value {
+	if t, ok := v.(*time.Time); ok {
+		if t == nil {
+			return parquet.NullValue()
+		}
+		v = *t
+	}
+	if v == nil {
+		return parquet.NullValue()
+	}
+	switch typ {
+	case Int:
+		switch n := v.(type) {
+		case int:
+			return parquet.Int64Value(int64(n))
+		case int64:
+			return parquet.Int64Value(n)
+		}
+	case Time:
+		if t, ok := v.(time.Time); ok {
+			return parquet.Int64Value(t.UnixMicro())
+		}
+	case Bool:
+		if b, ok := v.(bool); ok {
+			return parquet.BooleanValue(b)
+		}
+	}
+	return parquet.ByteArrayValue([]byte(text(v)))
+}
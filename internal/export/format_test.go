@@ -0,0 +1,45 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVFormulas(t *testing.T) {
+	tests := []struct {
+		name string
+		cell any
+		want string
+	}{
+		{"text", "Ada", "Ada"},
+		{"empty", "", ""},
+		{"formula", "=HYPERLINK(\"http://evil.example\")", `"'=HYPERLINK(""http://evil.example"")"`},
+		{"plus", "+1+1", "'+1+1"},
+		{"minus", "-1+1", "'-1+1"},
+		{"at", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\t=1", "'\t=1"},
+		{"carriage return", "\r=1", "\"'\r=1\""},
+		{"inside", "a=1", "a=1"},
+		{"negative number", int64(-5), "-5"},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			e, err := newCSV(&b, "users", []column{{name: "v"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := e.write([]any{tt.cell}); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSuffix(strings.TrimPrefix(b.String(), "v\n"), "\n"); got != tt.want {
+				t.Fatalf("cell = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"go-flylike-example/internal/audit"
+	"go-flylike-example/internal/users"
+)
+
+// PermUsers and PermAudit guard the exports of users and of the audit log.
+const (
+	PermUsers = "users:export"
+	PermAudit = audit.PermRead
+)
+
+// Users is the export of the users of the tenant.
+func Users(repo *users.Repository) *Resource {
+	return Table("users", PermUsers, users.ListOptions, repo.Stream, repo.Revision,
+		Column[users.User]{Name: "id", Value: func(u users.User) any { return u.ID }},
+		Column[users.User]{Name: "name", Value: func(u users.User) any { return u.Name }},
+		Column[users.User]{Name: "email", Value: func(u users.User) any { return u.Email }},
+		Column[users.User]{Name: "email_verified_at", Type: Time, Optional: true, Value: func(u users.User) any { return u.EmailVerifiedAt }},
+		Column[users.User]{Name: "created_at", Type: Time, Value: func(u users.User) any { return u.CreatedAt }},
+		Column[users.User]{Name: "updated_at", Type: Time, Value: func(u users.User) any { return u.UpdatedAt }},
+		Column[users.User]{Name: "version", Type: Int, Value: func(u users.User) any { return u.Version }},
+	)
+}
+
+// Audit is the export of the audit log of the tenant. The JSON members are
+// exported as text.
+func Audit(log *audit.Log) *Resource {
+	return Table("audit", PermAudit, audit.ListOptions, log.Stream, log.Revision,
+		Column[audit.Entry]{Name: "id", Value: func(e audit.Entry) any { return e.ID }},
+		Column[audit.Entry]{Name: "actor", Value: func(e audit.Entry) any { return e.Actor }},
+		Column[audit.Entry]{Name: "method", Value: func(e audit.Entry) any { return e.Method }},
+		Column[audit.Entry]{Name: "route", Value: func(e audit.Entry) any { return e.Route }},
+		Column[audit.Entry]{Name: "path", Value: func(e audit.Entry) any { return e.Path }},
+		Column[audit.Entry]{Name: "status", Type: Int, Value: func(e audit.Entry) any { return e.Status }},
+		Column[audit.Entry]{Name: "request_id", Value: func(e audit.Entry) any { return e.RequestID }},
+		Column[audit.Entry]{Name: "client_ip", Value: func(e audit.Entry) any { return e.ClientIP }},
+		Column[audit.Entry]{Name: "request", Optional: true, Value: func(e audit.Entry) any { return jsonText(e.Request) }},
+		Column[audit.Entry]{Name: "before", Optional: true, Value: func(e audit.Entry) any { return jsonText(e.Before) }},
+		Column[audit.Entry]{Name: "after", Optional: true, Value: func(e audit.Entry) any { return jsonText(e.After) }},
+		Column[audit.Entry]{Name: "created_at", Type: Time, Value: func(e audit.Entry) any { return e.CreatedAt }},
+	)
+}
+
+func jsonText(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
@@ -0,0 +1,158 @@
+// Package featureflag evaluates boolean and percentage-rollout flags per
+// request. Flags come from the configuration (features and rollouts) and
+// can be overridden at runtime through a Store; a caller lands in the same
+// rollout bucket on every request because buckets are derived from a
+// stable key such as the user or tenant.
+package featureflag
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+)
+
+// ErrInvalidFlag is returned for flags that cannot be stored.
+var ErrInvalidFlag = apperror.BadRequest("flag name must be set and percentage between 0 and 100")
+
+// overridesTTL bounds how long overrides are reused before the store is
+// read again, which is also how long a toggle takes to reach every
+// instance.
+const overridesTTL = 5 * time.Second
+
+// Flag is a feature flag. Percentage applies only when Enabled; 100 turns
+// the flag on for everyone.
+type Flag struct {
+	Name       string `json:"name"`
+	Enabled    bool   `json:"enabled"`
+	Percentage int    `json:"percentage"`
+	// Source is "config" or "override".
+	Source string `json:"source"`
+}
+
+// Service evaluates flags.
+type Service struct {
+	live  *config.Live
+	store Store
+
+	mu       sync.Mutex
+	cached   map[string]Flag
+	cachedAt time.Time
+}
+
+// New returns a Service reading the configured flags from live and
+// overrides from store.
+func New(live *config.Live, store Store) *Service {
+	return &Service{live: live, store: store}
+}
+
+// Flags returns every known flag with overrides applied, sorted by name.
+func (s *Service) Flags(ctx context.Context) []Flag {
+	all := s.merged(ctx)
+	list := make([]Flag, 0, len(all))
+	for _, f := range all {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Enabled reports whether name is on for the caller identified by key.
+// Unknown flags are off.
+func (s *Service) Enabled(ctx context.Context, name, key string) bool {
+	f, ok := s.merged(ctx)[strings.ToLower(name)]
+	return ok && f.on(key)
+}
+
+// Set stores a runtime override.
+func (s *Service) Set(ctx context.Context, f Flag) (Flag, error) {
+	f.Name = strings.ToLower(strings.TrimSpace(f.Name))
+	if f.Name == "" || f.Percentage < 0 || f.Percentage > 100 {
+		return Flag{}, ErrInvalidFlag
+	}
+	f.Source = "override"
+	if err := s.store.Set(ctx, f); err != nil {
+		return Flag{}, err
+	}
+	s.invalidate()
+	return f, nil
+}
+
+// Delete removes the override of name, if any, so the configured value
+// applies again.
+func (s *Service) Delete(ctx context.Context, name string) error {
+	if err := s.store.Delete(ctx, strings.ToLower(name)); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (f Flag) on(key string) bool {
+	switch {
+	case !f.Enabled || f.Percentage <= 0:
+		return false
+	case f.Percentage >= 100:
+		return true
+	}
+	return bucket(f.Name, key) < f.Percentage
+}
+
+// bucket maps key to 0-99. The flag name is mixed in so that one caller
+// is not first in line for every rollout.
+func bucket(name, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+func (s *Service) merged(ctx context.Context) map[string]Flag {
+	cfg := s.live.Load()
+	all := make(map[string]Flag, len(cfg.Features))
+	for name, on := range cfg.Features {
+		pct, ok := cfg.Rollouts[name]
+		if !ok {
+			pct = 100
+		}
+		all[name] = Flag{Name: name, Enabled: on, Percentage: pct, Source: "config"}
+	}
+	for name, f := range s.overrides(ctx) {
+		all[name] = f
+	}
+	return all
+}
+
+func (s *Service) overrides(ctx context.Context) map[string]Flag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && time.Since(s.cachedAt) < overridesTTL {
+		return s.cached
+	}
+	flags, err := s.store.List(ctx)
+	if err != nil {
+		// Keep serving the last known overrides rather than flipping
+		// flags back to their configured values.
+		slog.WarnContext(ctx, "feature flag overrides unavailable", "error", err)
+		if s.cached == nil {
+			s.cached = map[string]Flag{}
+		}
+		s.cachedAt = time.Now()
+		return s.cached
+	}
+	s.cached, s.cachedAt = flags, time.Now()
+	return flags
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+}
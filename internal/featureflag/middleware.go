@@ -0,0 +1,69 @@
+package featureflag
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/tenant"
+)
+
+const serviceKey = "featureflag.service"
+
+// Middleware makes s available to Enabled and Require. Install it once on
+// the router.
+func (s *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(serviceKey, s)
+		c.Next()
+	}
+}
+
+// Key is what a request is bucketed on: the authenticated subject, else
+// the tenant, else the client IP.
+func Key(c *gin.Context) string {
+	if claims, ok := auth.ClaimsFrom(c); ok {
+		return "user:" + claims.Subject
+	}
+	if id := tenant.ID(c.Request.Context()); id != "" {
+		return "tenant:" + id
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Enabled reports whether name is on for the request. It is false when
+// the middleware is not installed.
+func Enabled(c *gin.Context, name string) bool {
+	v, _ := c.Get(serviceKey)
+	s, ok := v.(*Service)
+	return ok && s.Enabled(c.Request.Context(), name, Key(c))
+}
+
+// Require answers 404 for requests that name is off for, so dark-launched
+// routes are indistinguishable from missing ones.
+func Require(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Enabled(c, name) {
+			c.Error(apperror.NotFound("route not found"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Handler lists the flags as evaluated for the caller, for clients that
+// gate features themselves.
+func (s *Service) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := Key(c)
+		flags := map[string]bool{}
+		for _, f := range s.Flags(c.Request.Context()) {
+			flags[f.Name] = f.on(key)
+		}
+		c.JSON(http.StatusOK, render.OK("flags evaluated", flags))
+	}
+}
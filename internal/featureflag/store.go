@@ -0,0 +1,152 @@
+package featureflag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/kv"
+)
+
+// Store persists runtime overrides set through the admin API. Overrides
+// win over the configured flags until they are deleted.
+type Store interface {
+	List(ctx context.Context) (map[string]Flag, error)
+	Set(ctx context.Context, f Flag) error
+	Delete(ctx context.Context, name string) error
+}
+
+// MemoryStore keeps overrides in process memory; they are neither shared
+// between instances nor kept across restarts.
+type MemoryStore struct {
+	mu    sync.Mutex
+	flags map[string]Flag
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{flags: make(map[string]Flag)}
+}
+
+// List implements Store.
+func (s *MemoryStore) List(context.Context) (map[string]Flag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.flags), nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(_ context.Context, f Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[f.Name] = f
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.flags, name)
+	return nil
+}
+
+// RedisStore keeps overrides in one Redis hash shared by every instance.
+type RedisStore struct {
+	client redis.Cmdable
+	key    string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client, key: "featureflags"}
+}
+
+// List implements Store.
+func (s *RedisStore) List(ctx context.Context) (map[string]Flag, error) {
+	raw, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("featureflag: redis list: %w", err)
+	}
+	flags := make(map[string]Flag, len(raw))
+	for name, v := range raw {
+		var f Flag
+		if err := json.Unmarshal([]byte(v), &f); err != nil {
+			return nil, fmt.Errorf("featureflag: redis list %s: %w", name, err)
+		}
+		flags[name] = f
+	}
+	return flags, nil
+}
+
+// Set implements Store.
+func (s *RedisStore) Set(ctx context.Context, f Flag) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("featureflag: redis set: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.key, f.Name, b).Err(); err != nil {
+		return fmt.Errorf("featureflag: redis set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *RedisStore) Delete(ctx context.Context, name string) error {
+	if err := s.client.HDel(ctx, s.key, name).Err(); err != nil {
+		return fmt.Errorf("featureflag: redis delete: %w", err)
+	}
+	return nil
+}
+
+// KVStore keeps overrides in a bucket of the embedded store, kept across
+// restarts of a single node.
+type KVStore struct {
+	bucket *kv.Bucket
+}
+
+// NewKVStore returns a Store backed by db.
+func NewKVStore(db *kv.DB) *KVStore {
+	return &KVStore{bucket: db.Bucket("featureflags")}
+}
+
+// List implements Store.
+func (s *KVStore) List(context.Context) (map[string]Flag, error) {
+	raw, err := s.bucket.All()
+	if err != nil {
+		return nil, fmt.Errorf("featureflag: kv list: %w", err)
+	}
+	flags := make(map[string]Flag, len(raw))
+	for name, v := range raw {
+		var f Flag
+		if err := json.Unmarshal(v, &f); err != nil {
+			return nil, fmt.Errorf("featureflag: kv list %s: %w", name, err)
+		}
+		flags[name] = f
+	}
+	return flags, nil
+}
+
+// Set implements Store.
+func (s *KVStore) Set(_ context.Context, f Flag) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("featureflag: kv set: %w", err)
+	}
+	if err := s.bucket.Put(f.Name, b, 0); err != nil {
+		return fmt.Errorf("featureflag: kv set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *KVStore) Delete(_ context.Context, name string) error {
+	if err := s.bucket.Delete(name); err != nil {
+		return fmt.Errorf("featureflag: kv delete: %w", err)
+	}
+	return nil
+}
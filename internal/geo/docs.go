@@ -0,0 +1,16 @@
+package geo
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{ID: "getGeo", Method: http.MethodGet, Path: "/geo", Tags: []string{"geo"}, Summary: "Locate the client",
+			Description: "The country, subdivision, city and coordinates of the client address, or of the CDN headers when trusted, the country name in the language of the response, its likely language and currency, and the nearest regions the app runs in.",
+			Response:    openapi.Envelope(Info{})},
+	}
+}
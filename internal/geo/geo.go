@@ -0,0 +1,231 @@
+// Package geo locates clients: by their address, from Fly-Client-IP or
+// the peer as the trusted proxies allow, in a MaxMind database, and by the
+// location headers of the CDN in front when configured to trust them.
+// Middleware makes the location of each request available to FromContext,
+// looked up the first time it is asked for.
+//
+// A database dropped into data/ before building, such as
+// GeoLite2-City.mmdb, is embedded into the binary; a Database file given in
+// the configuration replaces it.
+package geo
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/maxminddb-golang/v2"
+
+	"go-flylike-example/internal/config"
+)
+
+//go:embed data
+var embedded embed.FS
+
+// Location is where a client is, as precisely as its source knows.
+type Location struct {
+	// Country is the ISO 3166-1 code of the country, such as "DE".
+	Country string `json:"country,omitempty"`
+	// Subdivision is the ISO 3166-2 code of the state or region within
+	// the country, without the country, such as "BE" for Berlin.
+	Subdivision string `json:"subdivision,omitempty"`
+	City        string `json:"city,omitempty"`
+	// Continent is the two letter code of the continent, such as "EU".
+	Continent string `json:"continent,omitempty"`
+	TimeZone  string `json:"time_zone,omitempty"`
+	// Latitude and Longitude are set together, or not at all.
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// Source is what the location was read from: "maxmind" or the CDN
+	// whose headers told it.
+	Source string `json:"source,omitempty"`
+}
+
+// Found reports whether anything is known of the location.
+func (l Location) Found() bool {
+	return l.Country != ""
+}
+
+// Locator looks up the location of requests.
+type Locator struct {
+	live *config.Live
+	db   *maxminddb.Reader
+}
+
+// New returns a Locator for the geo configuration of live, opening its
+// database file, or the embedded one. Without either, clients are only
+// located by headers.
+func New(live *config.Live) (*Locator, error) {
+	cfg := live.Load().Geo
+	for _, code := range cfg.Regions {
+		if _, ok := regions[code]; !ok {
+			return nil, fmt.Errorf("geo: unknown region %q", code)
+		}
+	}
+	l := &Locator{live: live}
+	var err error
+	if cfg.Database != "" {
+		l.db, err = maxminddb.Open(cfg.Database)
+	} else if name, ok := embeddedDatabase(); ok {
+		var b []byte
+		if b, err = embedded.ReadFile(name); err == nil {
+			l.db, err = maxminddb.OpenBytes(b)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("geo: open database: %w", err)
+	}
+	return l, nil
+}
+
+// embeddedDatabase returns the name of the first database embedded.
+func embeddedDatabase() (string, bool) {
+	matches, _ := fs.Glob(embedded, "data/*.mmdb")
+	if len(matches) == 0 {
+		return "", false
+	}
+	return matches[0], true
+}
+
+type ctxKey struct{}
+
+// Middleware makes the location of each request available to
+// FromContext.
+func (l *Locator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ip := c.Request, c.ClientIP()
+		locate := sync.OnceValue(func() Location { return l.Locate(r, ip) })
+		c.Request = r.WithContext(context.WithValue(r.Context(), ctxKey{}, locate))
+		c.Next()
+	}
+}
+
+// FromContext returns the location of the request ctx belongs to, empty
+// outside one or when it is not known.
+func FromContext(ctx context.Context) Location {
+	if locate, ok := ctx.Value(ctxKey{}).(func() Location); ok {
+		return locate()
+	}
+	return Location{}
+}
+
+// Locate returns the location of r, sent from the address ip: by the CDN
+// headers first when they are trusted, the database otherwise.
+func (l *Locator) Locate(r *http.Request, ip string) Location {
+	if l.live.Load().Geo.Headers {
+		if loc := fromHeaders(r.Header); loc.Found() {
+			return loc
+		}
+	}
+	addr, err := netip.ParseAddr(ip)
+	if l.db == nil || err != nil {
+		return Location{}
+	}
+	var rec record
+	if err := l.db.Lookup(addr.Unmap()).Decode(&rec); err != nil {
+		return Location{}
+	}
+	loc := Location{
+		Country:   rec.Country.ISOCode,
+		City:      rec.City.Names["en"],
+		Continent: rec.Continent.Code,
+		TimeZone:  rec.Location.TimeZone,
+		Latitude:  rec.Location.Latitude,
+		Longitude: rec.Location.Longitude,
+		Source:    "maxmind",
+	}
+	if len(rec.Subdivisions) > 0 {
+		loc.Subdivision = rec.Subdivisions[0].ISOCode
+	}
+	if loc.Latitude == nil || loc.Longitude == nil {
+		loc.Latitude, loc.Longitude = nil, nil
+	}
+	return loc
+}
+
+// record is the part of a City or Country database record read.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+}
+
+// platform names the location headers of a CDN; LatLong holds both
+// coordinates where Latitude and Longitude are empty.
+type platform struct {
+	Source, Country, Subdivision, City, Continent, TimeZone, Latitude, Longitude, LatLong string
+}
+
+var platforms = []platform{
+	{Source: "cloudflare", Country: "CF-IPCountry", Subdivision: "CF-Region-Code", City: "CF-IPCity", Continent: "CF-IPContinent",
+		TimeZone: "CF-Timezone", Latitude: "CF-IPLatitude", Longitude: "CF-IPLongitude"},
+	{Source: "cloudfront", Country: "CloudFront-Viewer-Country", Subdivision: "CloudFront-Viewer-Country-Region", City: "CloudFront-Viewer-City",
+		TimeZone: "CloudFront-Viewer-Time-Zone", Latitude: "CloudFront-Viewer-Latitude", Longitude: "CloudFront-Viewer-Longitude"},
+	{Source: "vercel", Country: "X-Vercel-IP-Country", Subdivision: "X-Vercel-IP-Country-Region", City: "X-Vercel-IP-City",
+		TimeZone: "X-Vercel-IP-Timezone", Latitude: "X-Vercel-IP-Latitude", Longitude: "X-Vercel-IP-Longitude"},
+	{Source: "appengine", Country: "X-AppEngine-Country", Subdivision: "X-AppEngine-Region", City: "X-AppEngine-City", LatLong: "X-AppEngine-CityLatLong"},
+}
+
+// fromHeaders returns the location the headers of the first platform that
+// sent a country tell.
+func fromHeaders(h http.Header) Location {
+	for _, p := range platforms {
+		country := strings.ToUpper(h.Get(p.Country))
+		// XX and ZZ are unknown countries, T1 Tor exits.
+		if len(country) != 2 || country == "XX" || country == "ZZ" || country == "T1" {
+			continue
+		}
+		loc := Location{
+			Country:     country,
+			Subdivision: strings.ToUpper(h.Get(p.Subdivision)),
+			City:        h.Get(p.City),
+			Continent:   h.Get(p.Continent),
+			TimeZone:    h.Get(p.TimeZone),
+			Source:      p.Source,
+		}
+		// Vercel URL-encodes city names.
+		if city, err := url.QueryUnescape(loc.City); err == nil {
+			loc.City = city
+		}
+		lat, lon := h.Get(p.Latitude), h.Get(p.Longitude)
+		if p.LatLong != "" {
+			lat, lon, _ = strings.Cut(h.Get(p.LatLong), ",")
+		}
+		la, errLat := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+		lo, errLon := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+		if errLat == nil && errLon == nil && !(la == 0 && lo == 0) {
+			loc.Latitude, loc.Longitude = &la, &lo
+		}
+		// App Engine sends "?" for what it does not know.
+		if loc.City == "?" {
+			loc.City = ""
+		}
+		if loc.Subdivision == "?" {
+			loc.Subdivision = ""
+		}
+		return loc
+	}
+	return Location{}
+}
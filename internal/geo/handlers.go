@@ -0,0 +1,82 @@
+package geo
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/region"
+	"go-flylike-example/internal/render"
+)
+
+// maxHints bounds the nearest regions listed.
+const maxHints = 3
+
+// Info is what a client is told of where it is.
+type Info struct {
+	IP       string   `json:"ip"`
+	Location Location `json:"location"`
+	// CountryName is in the language of the response, Language the one
+	// most likely spoken in the country, such as "de", for clients to
+	// offer, and Currency the ISO 4217 code of its currency.
+	CountryName string `json:"country_name,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Currency    string `json:"currency,omitempty"`
+	Greeting    string `json:"greeting"`
+	// EdgeRegion received the request; PreferRegion is the nearest region
+	// the app runs in, for clients to send as Fly-Prefer-Region.
+	EdgeRegion   string `json:"edge_region,omitempty"`
+	PreferRegion string `json:"prefer_region,omitempty"`
+	Nearest      []Hint `json:"nearest_regions,omitempty"`
+}
+
+// Register mounts the example endpoint on g:
+//
+//	GET /geo   where the client is, localized, and its nearest regions
+func (l *Locator) Register(g *gin.RouterGroup) {
+	g.GET("/geo", l.handleInfo)
+}
+
+func (l *Locator) handleInfo(c *gin.Context) {
+	loc := FromContext(c.Request.Context())
+	info := Info{
+		IP:         c.ClientIP(),
+		Location:   loc,
+		Greeting:   i18n.T(c, "welcome"),
+		EdgeRegion: region.Edge(c),
+	}
+	if r, err := language.ParseRegion(loc.Country); loc.Found() && err == nil {
+		names := display.Regions(language.Make(i18n.Locale(c.Request.Context())))
+		if names == nil {
+			names = display.Regions(language.English)
+		}
+		info.CountryName = names.Name(r)
+		info.Greeting = i18n.T(c, "welcome, visitor from %s", info.CountryName)
+		if t, err := language.Compose(r); err == nil {
+			if base, conf := t.Base(); conf >= language.High {
+				info.Language = base.String()
+			}
+		}
+		if unit, ok := currency.FromRegion(r); ok {
+			info.Currency = unit.String()
+		}
+	}
+	codes := l.live.Load().Geo.Regions
+	info.Nearest = Nearest(loc, codes, maxHints)
+	switch {
+	case len(info.Nearest) > 0:
+		info.PreferRegion = info.Nearest[0].Code
+	case info.EdgeRegion != "" && (len(codes) == 0 || slices.Contains(codes, info.EdgeRegion)):
+		// Without coordinates, the edge that received the request is the
+		// nearest guess.
+		info.PreferRegion = info.EdgeRegion
+	}
+	// The answer depends on the address, which shared caches do not key on.
+	c.Header("Cache-Control", "private")
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "location found"), info))
+}
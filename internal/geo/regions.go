@@ -0,0 +1,111 @@
+package geo
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Region is a region an app can run in.
+type Region struct {
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"-"`
+	Longitude float64 `json:"-"`
+}
+
+// regions are the regions of the platform, by code, at their airport.
+var regions = map[string]Region{}
+
+func init() {
+	for _, r := range []Region{
+		{"ams", "Amsterdam, Netherlands", 52.31, 4.76},
+		{"arn", "Stockholm, Sweden", 59.65, 17.92},
+		{"atl", "Atlanta, Georgia (US)", 33.64, -84.43},
+		{"bog", "Bogotá, Colombia", 4.70, -74.15},
+		{"bom", "Mumbai, India", 19.09, 72.87},
+		{"bos", "Boston, Massachusetts (US)", 42.36, -71.01},
+		{"cdg", "Paris, France", 49.01, 2.55},
+		{"den", "Denver, Colorado (US)", 39.86, -104.67},
+		{"dfw", "Dallas, Texas (US)", 32.90, -97.04},
+		{"ewr", "Secaucus, NJ (US)", 40.69, -74.17},
+		{"eze", "Ezeiza, Argentina", -34.82, -58.54},
+		{"fra", "Frankfurt, Germany", 50.03, 8.57},
+		{"gdl", "Guadalajara, Mexico", 20.52, -103.31},
+		{"gig", "Rio de Janeiro, Brazil", -22.81, -43.25},
+		{"gru", "Sao Paulo, Brazil", -23.43, -46.47},
+		{"hkg", "Hong Kong, Hong Kong", 22.31, 113.91},
+		{"iad", "Ashburn, Virginia (US)", 38.94, -77.46},
+		{"jnb", "Johannesburg, South Africa", -26.14, 28.25},
+		{"lax", "Los Angeles, California (US)", 33.94, -118.41},
+		{"lhr", "London, United Kingdom", 51.47, -0.46},
+		{"mad", "Madrid, Spain", 40.49, -3.57},
+		{"mia", "Miami, Florida (US)", 25.80, -80.29},
+		{"nrt", "Tokyo, Japan", 35.77, 140.39},
+		{"ord", "Chicago, Illinois (US)", 41.98, -87.90},
+		{"otp", "Bucharest, Romania", 44.57, 26.10},
+		{"phx", "Phoenix, Arizona (US)", 33.43, -112.01},
+		{"qro", "Querétaro, Mexico", 20.62, -100.19},
+		{"scl", "Santiago, Chile", -33.39, -70.79},
+		{"sea", "Seattle, Washington (US)", 47.45, -122.31},
+		{"sin", "Singapore, Singapore", 1.36, 103.99},
+		{"sjc", "San Jose, California (US)", 37.36, -121.93},
+		{"syd", "Sydney, Australia", -33.95, 151.18},
+		{"waw", "Warsaw, Poland", 52.17, 20.97},
+		{"yul", "Montreal, Canada", 45.47, -73.74},
+		{"yyz", "Toronto, Canada", 43.68, -79.61},
+	} {
+		regions[r.Code] = r
+	}
+}
+
+// Hint is a region and how far a client is from it.
+type Hint struct {
+	Region
+	DistanceKM int `json:"distance_km"`
+}
+
+// Nearest returns the regions of codes, all regions when empty, by their
+// distance from loc, at most n of them; none when loc has no coordinates.
+// Unknown codes are skipped.
+func Nearest(loc Location, codes []string, n int) []Hint {
+	if loc.Latitude == nil || loc.Longitude == nil {
+		return nil
+	}
+	if len(codes) == 0 {
+		for code := range regions {
+			codes = append(codes, code)
+		}
+	}
+	var hints []Hint
+	for _, code := range codes {
+		r, ok := regions[code]
+		if !ok {
+			continue
+		}
+		km := distance(*loc.Latitude, *loc.Longitude, r.Latitude, r.Longitude)
+		hints = append(hints, Hint{Region: r, DistanceKM: int(math.Round(km))})
+	}
+	slices.SortFunc(hints, func(a, b Hint) int {
+		return cmp.Or(cmp.Compare(a.DistanceKM, b.DistanceKM), cmp.Compare(a.Code, b.Code))
+	})
+	return hints[:min(n, len(hints))]
+}
+
+// Lookup returns the region of code.
+func Lookup(code string) (Region, bool) {
+	r, ok := regions[code]
+	return r, ok
+}
+
+// earthRadiusKM is the mean radius of the earth.
+const earthRadiusKM = 6371.0
+
+// distance returns the great-circle distance in kilometers between two
+// points, by the haversine formula.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Sqrt(a))
+}
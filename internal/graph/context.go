@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vikstrous/dataloadgen"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/users"
+)
+
+type callerKey struct{}
+
+type loadersKey struct{}
+
+// caller is who sent the request, as established by the auth and API key
+// middleware on the gin context.
+type caller struct {
+	claims *auth.Claims
+	key    *apikeys.Key
+}
+
+func withCaller(ctx context.Context, c *gin.Context) context.Context {
+	var who caller
+	who.claims, _ = auth.ClaimsFrom(c)
+	who.key, _ = apikeys.FromContext(c)
+	return context.WithValue(ctx, callerKey{}, who)
+}
+
+func callerFrom(ctx context.Context) caller {
+	who, _ := ctx.Value(callerKey{}).(caller)
+	return who
+}
+
+// requireScope is apikeys.RequireScope for resolvers: it refuses callers
+// authenticated by a key that lacks scope.
+func requireScope(ctx context.Context, scope string) error {
+	if k := callerFrom(ctx).key; k != nil && !k.HasScope(scope) {
+		return apperror.Newf(apperror.KindForbidden, "api key lacks scope %s", scope)
+	}
+	return nil
+}
+
+// requireCaller is auth.Required for resolvers: it refuses callers that
+// are not authenticated.
+func requireCaller(ctx context.Context) (*auth.Claims, error) {
+	claims := callerFrom(ctx).claims
+	if claims == nil {
+		return nil, apperror.Unauthorized("authentication required")
+	}
+	return claims, nil
+}
+
+// requirePermission is rbac.Require for resolvers: it refuses callers that
+// are not authenticated or whose subject lacks perm.
+func requirePermission(ctx context.Context, s *rbac.Service, perm string) error {
+	claims, err := requireCaller(ctx)
+	if err != nil {
+		return err
+	}
+	allowed, err := s.Allowed(ctx, claims.Subject, perm)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperror.Newf(apperror.KindForbidden, "missing permission %s", perm)
+	}
+	return nil
+}
+
+// loaderWait is how long a dataloader collects keys before it queries the
+// store. Sibling fields resolve concurrently, so a short wait suffices.
+const loaderWait = 2 * time.Millisecond
+
+// loaders batch the lookups of one request. They cache what they load, so
+// they must not outlive it.
+type loaders struct {
+	users *dataloadgen.Loader[string, *users.User]
+	roles *dataloadgen.Loader[string, []string]
+}
+
+func withLoaders(ctx context.Context, r *Resolver) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		users: dataloadgen.NewMappedLoader(r.Repository.GetMany, dataloadgen.WithWait(loaderWait)),
+		roles: dataloadgen.NewMappedLoader(r.RBAC.RolesOf, dataloadgen.WithWait(loaderWait)),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// loadUser returns the user with the given id, or nil if there is none.
+func loadUser(ctx context.Context, id string) (*users.User, error) {
+	u, err := loadersFrom(ctx).users.Load(ctx, id)
+	if errors.Is(err, dataloadgen.ErrNotFound) {
+		return nil, nil
+	}
+	return u, err
+}
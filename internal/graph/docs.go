@@ -0,0 +1,40 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+type request struct {
+	Query         string                     `json:"query" binding:"required"`
+	OperationName string                     `json:"operationName,omitempty"`
+	Variables     map[string]json.RawMessage `json:"variables,omitempty"`
+}
+
+type responseError struct {
+	Message    string          `json:"message"`
+	Path       []any           `json:"path,omitempty"`
+	Extensions json.RawMessage `json:"extensions,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []responseError `json:"errors,omitempty"`
+}
+
+// Operations documents the endpoint, relative to its group. The schema
+// itself is served by introspection.
+func Operations() []openapi.Operation {
+	tags := []string{"graphql"}
+	desc := "Serves the users of /api/v2 with the same authentication and API key scopes. " +
+		"Resolver errors carry the HTTP status and code REST would answer with in their extensions."
+	return []openapi.Operation{
+		{ID: "postGraphQL", Method: http.MethodPost, Path: "", Tags: tags, Summary: "Run a GraphQL query or mutation", Description: desc,
+			Request: request{}, Response: response{}, Errors: []int{http.StatusUnprocessableEntity}},
+		{ID: "getGraphQL", Method: http.MethodGet, Path: "", Tags: tags, Summary: "Run a GraphQL query", Description: desc,
+			Query:    []openapi.Param{{Name: "query"}, {Name: "operationName"}, {Name: "variables", Description: "JSON object"}},
+			Response: response{}, Errors: []int{http.StatusUnprocessableEntity}},
+	}
+}
@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/validation"
+)
+
+// invalidError reports the fields of an argument that broke its rules.
+type invalidError struct {
+	message string
+	errors  []validation.FieldError
+}
+
+func (e *invalidError) Error() string { return e.message }
+
+// present maps resolver errors through the error taxonomy like
+// apperror.Render: clients get the client-safe message, with the status and
+// code REST would have answered in the extensions. Errors in the query
+// itself, from parsing and validation, are passed through.
+func present(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if gqlErr.Err == nil {
+		return gqlErr
+	}
+	var invalid *invalidError
+	var listErr *query.Error
+	switch {
+	case errors.As(gqlErr.Err, &invalid):
+		gqlErr.Message = invalid.message
+		gqlErr.Extensions = extensions(http.StatusUnprocessableEntity)
+		gqlErr.Extensions["errors"] = invalid.errors
+	case errors.As(gqlErr.Err, &listErr):
+		gqlErr.Message = "the arguments contain invalid list parameters"
+		gqlErr.Extensions = extensions(http.StatusUnprocessableEntity)
+		gqlErr.Extensions["errors"] = listErr.Errors
+	default:
+		e := apperror.From(gqlErr.Err)
+		status := e.Status()
+		if status >= http.StatusInternalServerError {
+			attrs := []any{"error", gqlErr.Err.Error(), "status", status, "path", gqlErr.Path.String()}
+			if stack := e.Stack(); stack != "" {
+				attrs = append(attrs, "stack", stack)
+			}
+			slog.ErrorContext(ctx, "graphql resolver failed", attrs...)
+		}
+		gqlErr.Message = e.Localize(ctx)
+		gqlErr.Extensions = extensions(status)
+	}
+	return gqlErr
+}
+
+// extensions names status as a code, e.g. 404 as "NOT_FOUND".
+func extensions(status int) map[string]any {
+	code := strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	return map[string]any{"code": code, "status": status}
+}
+
+// recovered turns a resolver panic into an internal error, which present
+// logs with the stack of the panic.
+func recovered(_ context.Context, r any) error {
+	return apperror.Internal(fmt.Errorf("panic: %v", r))
+}
@@ -0,0 +1,83 @@
+// Package graph serves the domain model of the REST API over GraphQL at
+// /graphql, for frontends that consume GraphQL only. Resolvers run behind
+// the same middleware as /api/v2 and call the same services, so
+// authentication, API key scopes, tenancy and errors behave alike; lookups
+// by id go through per-request dataloaders, which batch the users and roles
+// a query touches into one store query each.
+//
+// The schema is schema.graphqls; regenerate the executor after changing it.
+package graph
+
+//go:generate go tool gqlgen generate
+
+import (
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gin-gonic/gin"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/users"
+)
+
+// queryCacheSize bounds the parsed queries kept; frontends send the same
+// few queries over and over.
+const queryCacheSize = 1000
+
+// Handler serves GraphQL requests.
+type Handler struct {
+	srv        *handler.Server
+	resolver   *Resolver
+	playground bool
+}
+
+// New returns a Handler resolving queries with r.
+func New(cfg config.GraphQL, r *Resolver) *Handler {
+	c := Config{Resolvers: r}
+	// A user list costs its page size times the selection, so that a large
+	// limit cannot hide behind a small query.
+	c.Complexity.Query.Users = func(child int, limit, _ *int, _, _ *string, _ []*UserFilter) int {
+		n := users.ListOptions.DefaultLimit
+		if limit != nil && *limit > 0 {
+			n = *limit
+		}
+		return 1 + n*child
+	}
+
+	srv := handler.New(NewExecutableSchema(c))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](queryCacheSize))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(cfg.MaxComplexity))
+	srv.SetErrorPresenter(present)
+	srv.SetRecoverFunc(recovered)
+	return &Handler{srv: srv, resolver: r, playground: cfg.Playground}
+}
+
+// Register mounts the endpoint on g. GET serves queries passed in the URL
+// and, with the playground enabled, GraphiQL to requests without one.
+func (h *Handler) Register(g *gin.RouterGroup) {
+	g.POST("", h.serve)
+	if !h.playground {
+		g.GET("", h.serve)
+		return
+	}
+	ui := playground.Handler("go-flylike-example", g.BasePath())
+	g.GET("", func(c *gin.Context) {
+		if c.Query("query") == "" {
+			ui(c.Writer, c.Request)
+			return
+		}
+		h.serve(c)
+	})
+}
+
+func (h *Handler) serve(c *gin.Context) {
+	ctx := withCaller(c.Request.Context(), c)
+	ctx = withLoaders(ctx, h.resolver)
+	h.srv.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
@@ -0,0 +1,107 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"go-flylike-example/internal/users"
+	"io"
+	"strconv"
+)
+
+type Mutation struct {
+}
+
+// Places a page of results, like the meta member of REST lists.
+type PageInfo struct {
+	Limit int `json:"limit"`
+	// The page number of offset pagination, null when paging by cursor.
+	Page *int `json:"page,omitempty"`
+	// Set whenever more users follow.
+	NextCursor *string `json:"nextCursor,omitempty"`
+	HasMore    bool    `json:"hasMore"`
+}
+
+type Query struct {
+}
+
+type UserConnection struct {
+	Nodes    []*users.User `json:"nodes"`
+	PageInfo *PageInfo     `json:"pageInfo"`
+}
+
+// A filter condition, like filter[field][op]=value in REST.
+type UserFilter struct {
+	Field string   `json:"field"`
+	Op    FilterOp `json:"op"`
+	// The value; a comma-separated list for IN.
+	Value string `json:"value"`
+}
+
+type FilterOp string
+
+const (
+	FilterOpEq       FilterOp = "EQ"
+	FilterOpNe       FilterOp = "NE"
+	FilterOpLt       FilterOp = "LT"
+	FilterOpLte      FilterOp = "LTE"
+	FilterOpGt       FilterOp = "GT"
+	FilterOpGte      FilterOp = "GTE"
+	FilterOpContains FilterOp = "CONTAINS"
+	FilterOpIn       FilterOp = "IN"
+)
+
+var AllFilterOp = []FilterOp{
+	FilterOpEq,
+	FilterOpNe,
+	FilterOpLt,
+	FilterOpLte,
+	FilterOpGt,
+	FilterOpGte,
+	FilterOpContains,
+	FilterOpIn,
+}
+
+func (e FilterOp) IsValid() bool {
+	switch e {
+	case FilterOpEq, FilterOpNe, FilterOpLt, FilterOpLte, FilterOpGt, FilterOpGte, FilterOpContains, FilterOpIn:
+		return true
+	}
+	return false
+}
+
+func (e FilterOp) String() string {
+	return string(e)
+}
+
+func (e *FilterOp) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FilterOp(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FilterOp", str)
+	}
+	return nil
+}
+
+func (e FilterOp) MarshalGQL(w io.Writer) {
+	_, _ = fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *FilterOp) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e FilterOp) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,59 @@
+package graph
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/users"
+)
+
+// Resolver holds the services resolvers use, the same ones behind the REST
+// handlers.
+type Resolver struct {
+	Repository *users.Repository
+	Accounts   *users.Accounts
+	RBAC       *rbac.Service
+	Sessions   users.Sessions
+}
+
+// CreateUserInput carries the rules of POST /api/v2/users.
+type CreateUserInput struct {
+	Name  string `json:"name" binding:"required,notblank,max=100"`
+	Email string `json:"email" binding:"required,email,max=254"`
+}
+
+// listParams spells list arguments as the query parameters of REST lists,
+// so that both are parsed and checked by the same query.Options.
+func listParams(limit, page *int, cursor, sort *string, filters []*UserFilter) url.Values {
+	v := url.Values{}
+	if limit != nil {
+		v.Set("limit", strconv.Itoa(*limit))
+	}
+	if page != nil {
+		v.Set("page", strconv.Itoa(*page))
+	}
+	if cursor != nil {
+		v.Set("cursor", *cursor)
+	}
+	if sort != nil {
+		v.Set("sort", *sort)
+	}
+	for _, f := range filters {
+		v.Set("filter["+f.Field+"]["+strings.ToLower(string(f.Op))+"]", f.Value)
+	}
+	return v
+}
+
+func pageInfo(p query.Page) *PageInfo {
+	info := &PageInfo{Limit: p.Limit, HasMore: p.HasMore}
+	if p.Page > 0 {
+		info.Page = &p.Page
+	}
+	if p.NextCursor != "" {
+		info.NextCursor = &p.NextCursor
+	}
+	return info
+}
@@ -0,0 +1,119 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver
+// implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.95
+
+import (
+	"context"
+	"errors"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/users"
+	"go-flylike-example/internal/validation"
+	"strings"
+)
+
+// CreateUser is the resolver for the createUser field.
+func (r *mutationResolver) CreateUser(ctx context.Context, input CreateUserInput) (*users.User, error) {
+	if err := requirePermission(ctx, r.RBAC, users.PermWrite); err != nil {
+		return nil, err
+	}
+	if err := requireScope(ctx, users.PermWrite); err != nil {
+		return nil, err
+	}
+	if errs := validation.Struct(&input); errs != nil {
+		return nil, &invalidError{message: "the input contains invalid fields", errors: errs}
+	}
+	return r.Repository.Create(ctx, input.Name, input.Email)
+}
+
+// DeleteUser is the resolver for the deleteUser field.
+func (r *mutationResolver) DeleteUser(ctx context.Context, id string) (bool, error) {
+	if err := requirePermission(ctx, r.RBAC, users.PermWrite); err != nil {
+		return false, err
+	}
+	if err := requireScope(ctx, users.PermWrite); err != nil {
+		return false, err
+	}
+	if err := r.Repository.Delete(ctx, id); err != nil {
+		return false, err
+	}
+	if err := r.Sessions.RevokeSubject(context.WithoutCancel(ctx), users.Subject(id)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, limit *int, page *int, cursor *string, sort *string, filter []*UserFilter) (*UserConnection, error) {
+	if _, err := requireCaller(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireScope(ctx, users.PermRead); err != nil {
+		return nil, err
+	}
+	spec, err := query.Parse(listParams(limit, page, cursor, sort, filter), users.ListOptions)
+	if err != nil {
+		return nil, err
+	}
+	list, p, err := r.Repository.ListPage(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	conn := &UserConnection{Nodes: make([]*users.User, len(list)), PageInfo: pageInfo(p)}
+	l := loadersFrom(ctx)
+	for i := range list {
+		conn.Nodes[i] = &list[i]
+		l.users.Prime(list[i].ID, &list[i])
+	}
+	return conn, nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*users.User, error) {
+	if _, err := requireCaller(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireScope(ctx, users.PermRead); err != nil {
+		return nil, err
+	}
+	return loadUser(ctx, id)
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*users.User, error) {
+	claims, err := requireCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := strings.CutPrefix(claims.Subject, users.SubjectPrefix)
+	if !ok {
+		return nil, nil
+	}
+	u, err := r.Accounts.Get(ctx, id)
+	if errors.Is(err, users.ErrNotFound) {
+		return nil, nil
+	}
+	return u, err
+}
+
+// Roles is the resolver for the roles field.
+func (r *userResolver) Roles(ctx context.Context, obj *users.User) ([]string, error) {
+	return loadersFrom(ctx).roles.Load(ctx, users.Subject(obj.ID))
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type (
+	mutationResolver struct{ *Resolver }
+	queryResolver    struct{ *Resolver }
+	userResolver     struct{ *Resolver }
+)
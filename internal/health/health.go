@@ -0,0 +1,179 @@
+// Package health provides a registry of liveness and readiness checks and
+// the Gin handlers that expose them as /healthz and /readyz probes.
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/api"
+)
+
+// DefaultTimeout bounds a check registered without an explicit timeout.
+const DefaultTimeout = 2 * time.Second
+
+// ErrShuttingDown is reported by readiness once Shutdown has been called.
+var ErrShuttingDown = errors.New("shutting down")
+
+// CheckFunc reports whether a subsystem is healthy. It must honour ctx.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name    string
+	fn      CheckFunc
+	timeout time.Duration
+}
+
+// Checker is a registry of named checks. Liveness checks should only fail
+// when the process needs restarting; readiness checks gate traffic.
+type Checker struct {
+	mu        sync.RWMutex
+	liveness  []check
+	readiness []check
+
+	started      time.Time
+	shuttingDown atomic.Bool
+}
+
+// New returns an empty Checker.
+func New() *Checker {
+	return &Checker{started: time.Now()}
+}
+
+// AddLiveness registers a liveness check. A zero timeout uses DefaultTimeout.
+func (h *Checker) AddLiveness(name string, fn CheckFunc, timeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, check{name: name, fn: fn, timeout: timeout})
+}
+
+// AddReadiness registers a readiness check. A zero timeout uses DefaultTimeout.
+func (h *Checker) AddReadiness(name string, fn CheckFunc, timeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, check{name: name, fn: fn, timeout: timeout})
+}
+
+// Shutdown makes readiness fail so load balancers stop routing new
+// requests while in-flight ones drain. Liveness is unaffected.
+func (h *Checker) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// Result is the outcome of a single check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report aggregates the results of a probe.
+type Report struct {
+	Healthy bool
+	Checks  map[string]Result
+}
+
+// Live runs all liveness checks.
+func (h *Checker) Live(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := append([]check(nil), h.liveness...)
+	h.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+// Ready runs all readiness checks. It fails fast once Shutdown was called.
+func (h *Checker) Ready(ctx context.Context) Report {
+	if h.shuttingDown.Load() {
+		return Report{Checks: map[string]Result{
+			"shutdown": {Status: "fail", Error: ErrShuttingDown.Error(), Duration: "0s"},
+		}}
+	}
+	h.mu.RLock()
+	checks := append([]check(nil), h.readiness...)
+	h.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+func run(ctx context.Context, checks []check) Report {
+	report := Report{Healthy: true, Checks: make(map[string]Result, len(checks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+
+			timeout := c.timeout
+			if timeout <= 0 {
+				timeout = DefaultTimeout
+			}
+			cctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := runOne(cctx, c.fn)
+			res := Result{Status: "ok", Duration: time.Since(start).Round(time.Microsecond).String()}
+			if err != nil {
+				res.Status = "fail"
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = res
+			if err != nil {
+				report.Healthy = false
+			}
+		}(c)
+	}
+	wg.Wait()
+	return report
+}
+
+// runOne returns as soon as either the check or its deadline finishes, so a
+// check that ignores ctx cannot hold the probe open.
+func runOne(ctx context.Context, fn CheckFunc) error {
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LivenessHandler serves the liveness probe.
+func (h *Checker) LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.respond(c, h.Live(c.Request.Context()), "alive")
+	}
+}
+
+// ReadinessHandler serves the readiness probe.
+func (h *Checker) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.respond(c, h.Ready(c.Request.Context()), "ready")
+	}
+}
+
+func (h *Checker) respond(c *gin.Context, r Report, state string) {
+	status, code, message := "ok", http.StatusOK, "service is "+state
+	if !r.Healthy {
+		status, code, message = "fail", http.StatusServiceUnavailable, "service is not "+state
+	}
+	c.JSON(code, api.Envelope[any]{Status: status, Message: message, Data: gin.H{
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"uptime":    time.Since(h.started).Round(time.Second).String(),
+		"checks":    r.Checks,
+	}})
+}
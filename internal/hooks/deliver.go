@@ -0,0 +1,179 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/httpclient"
+	"go-flylike-example/internal/jobs"
+	"go-flylike-example/internal/tracing"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderSignature = "Webhook-Signature"
+)
+
+// maxErrorBody is how much of a failed response is kept as last_error.
+const maxErrorBody = 512
+
+// ErrPrivateAddress is returned when a subscriber URL resolves to an
+// address that is not publicly routable.
+var ErrPrivateAddress = errors.New("hooks: refusing to connect to a private address")
+
+type deliverJob struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// Sign returns the Webhook-Signature header value for body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Including the
+// timestamp lets receivers reject replayed deliveries.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver is the jobs.Handler for KindDeliver. A response other than 2xx
+// fails the job so the queue retries it; the final failure dead-letters
+// the delivery.
+func (s *Service) Deliver(ctx context.Context, job *jobs.Job) error {
+	var p deliverJob
+	if err := job.Decode(&p); err != nil {
+		return fmt.Errorf("hooks: decode job: %w", err)
+	}
+
+	var (
+		d             Delivery
+		payload, url  string
+		secret, state string
+	)
+	err := s.db.Writer(ctx).QueryRowContext(ctx, s.db.Rebind(
+		`SELECT d.id, d.event, d.state, d.payload, s.url, s.secret
+		 FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		 WHERE d.id = ?`), p.DeliveryID).
+		Scan(&d.ID, &d.Event, &state, &payload, &url, &secret)
+	if errors.Is(err, sql.ErrNoRows) || state != StatePending {
+		// Unsubscribed since, or already sent by an earlier job.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("hooks: load delivery: %w", err)
+	}
+
+	status, sendErr := s.send(ctx, url, secret, &d, []byte(payload))
+
+	next := StatePending
+	switch {
+	case sendErr == nil:
+		next = StateDelivered
+	case job.Attempts >= job.MaxAttempts:
+		next = StateDead
+	}
+	var (
+		lastStatus, lastErr any
+		deliveredAt         any
+	)
+	if status > 0 {
+		lastStatus = status
+	}
+	if sendErr != nil {
+		lastErr = sendErr.Error()
+	} else {
+		deliveredAt = time.Now().UTC()
+	}
+	// Bookkeeping must not be lost to the job's deadline.
+	_, err = s.db.DB().ExecContext(context.WithoutCancel(ctx), s.db.Rebind(
+		`UPDATE webhook_deliveries SET state = ?, attempts = attempts + 1, last_status = ?, last_error = ?,
+		 delivered_at = COALESCE(?, delivered_at) WHERE id = ?`),
+		next, lastStatus, lastErr, deliveredAt, d.ID)
+	if err != nil {
+		return fmt.Errorf("hooks: record delivery: %w", err)
+	}
+	return sendErr
+}
+
+// send posts body once and returns the response status, if any.
+func (s *Service) send(ctx context.Context, url, secret string, d *Delivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-flylike-example-webhooks/1")
+	req.Header.Set(HeaderID, d.ID)
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderSignature, Sign(secret, time.Now(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return resp.StatusCode, nil
+	}
+	err = fmt.Errorf("subscriber answered %s", resp.Status)
+	if snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody)); len(bytes.TrimSpace(snippet)) > 0 {
+		err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(snippet))
+	}
+	return resp.StatusCode, err
+}
+
+// newClient builds the delivery client. Redirects are not followed, and
+// unless cfg.AllowPrivate is set connections to internal addresses are
+// refused after DNS resolution, so subscriptions cannot probe the private
+// network.
+func newClient(cfg config.Webhooks, m *httpclient.Metrics) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if !cfg.AllowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !public(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		}
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DialContext = dialer.DialContext
+	base.Proxy = nil
+
+	client := httpclient.New(
+		httpclient.WithBase(tracing.Transport(base)),
+		httpclient.WithTimeout(cfg.Timeout),
+		httpclient.WithRetries(0), // the job queue retries with its own backoff
+		httpclient.WithMetrics(m),
+	)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client
+}
+
+func public(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
@@ -0,0 +1,39 @@
+package hooks
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+	"go-flylike-example/internal/query"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"webhooks"}
+	notFound := []int{http.StatusNotFound}
+	return []openapi.Operation{
+		{ID: "listWebhooks", Method: http.MethodGet, Path: "", Tags: tags, Summary: "List your webhook subscriptions", Auth: true,
+			Scope: "webhooks:read", Response: openapi.Envelope([]Subscription{})},
+		{ID: "subscribeWebhook", Method: http.MethodPost, Path: "", Tags: tags, Summary: "Subscribe a URL to events", Auth: true,
+			Scope: "webhooks:write",
+			Description: "Deliveries are signed with the returned secret, which is only shown in this response: " +
+				"the Webhook-Signature header is t=<unix time>,v1=<hex HMAC-SHA256 of \"<t>.<body>\">. " +
+				`Use "*" to receive every event. ` +
+				`Subscribing to user events, or to "*", also requires the users:read permission and, for API keys, scope.`,
+			Request: subscribeRequest{}, Response: openapi.Envelope(subscribed{}), Status: http.StatusCreated,
+			Errors: []int{http.StatusForbidden}},
+		{ID: "getWebhook", Method: http.MethodGet, Path: "/:id", Tags: tags, Summary: "Get a webhook subscription", Auth: true,
+			Scope: "webhooks:read", Response: openapi.Envelope(Subscription{}), Errors: notFound},
+		{ID: "deleteWebhook", Method: http.MethodDelete, Path: "/:id", Tags: tags, Summary: "Delete a webhook subscription", Auth: true,
+			Scope: "webhooks:write", Response: openapi.Envelope(nil), Errors: notFound},
+		{ID: "pingWebhook", Method: http.MethodPost, Path: "/:id/ping", Tags: tags, Summary: "Send a ping event", Auth: true,
+			Scope: "webhooks:write", Response: openapi.Envelope(Delivery{}), Status: http.StatusAccepted, Errors: notFound},
+		{ID: "listDeliveries", Method: http.MethodGet, Path: "/:id/deliveries", Tags: tags, Summary: "List deliveries", Auth: true,
+			Scope: "webhooks:read", Description: "Deliveries with their state: pending, delivered or dead; newest first by default.",
+			Query: DeliveryOptions.Params(), Response: openapi.Paginated([]Delivery{}, query.Page{}),
+			Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity}},
+		{ID: "redeliver", Method: http.MethodPost, Path: "/:id/deliveries/:delivery/redeliver", Tags: tags, Summary: "Queue a delivery again",
+			Auth: true, Scope: "webhooks:write", Response: openapi.Envelope(Delivery{}), Status: http.StatusAccepted,
+			Errors: notFound},
+	}
+}
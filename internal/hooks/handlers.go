@@ -0,0 +1,141 @@
+package hooks
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/users"
+	"go-flylike-example/internal/validation"
+)
+
+type subscribeRequest struct {
+	URL    string   `json:"url" binding:"required,url,max=2048"`
+	Events []string `json:"events" binding:"required,min=1,max=50,dive,required,max=100"`
+}
+
+// subscribed is the only response that ever includes the secret.
+type subscribed struct {
+	*Subscription
+	Secret string `json:"secret"`
+}
+
+// Register mounts the subscription endpoints on g. API keys need the
+// webhooks:read or webhooks:write scope. User events carry the users they
+// are about, so subscribing to them, or to "*", also takes what reading
+// users does: the users:read scope and permission.
+func (s *Service) Register(g *gin.RouterGroup) {
+	read, write := apikeys.RequireScope("webhooks:read"), apikeys.RequireScope("webhooks:write")
+	g.Use(auth.Required())
+	g.GET("", read, s.handleList)
+	g.POST("", write, s.handleSubscribe)
+	g.GET("/:id", read, s.handleGet)
+	g.DELETE("/:id", write, s.handleUnsubscribe)
+	g.POST("/:id/ping", write, s.handlePing)
+	g.GET("/:id/deliveries", read, s.handleDeliveries)
+	g.POST("/:id/deliveries/:delivery/redeliver", write, s.handleRedeliver)
+}
+
+func owner(c *gin.Context) string {
+	claims, _ := auth.ClaimsFrom(c)
+	return claims.Subject
+}
+
+func (s *Service) handleList(c *gin.Context) {
+	list, err := s.List(c.Request.Context(), owner(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("webhook subscriptions listed", list))
+}
+
+func (s *Service) handleSubscribe(c *gin.Context) {
+	var req subscribeRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if slices.ContainsFunc(req.Events, userEvent) {
+		if err := canReadUsers(c); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	sub, err := s.Subscribe(c.Request.Context(), owner(c), req.URL, req.Events)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, render.OK("webhook subscription created", subscribed{Subscription: sub, Secret: sub.Secret}))
+}
+
+// userEvent reports whether subscribing to event receives user events.
+func userEvent(event string) bool {
+	return event == "*" || strings.HasPrefix(event, users.EventPrefix)
+}
+
+// canReadUsers is apikeys.RequireScope and rbac.Require for users:read.
+func canReadUsers(c *gin.Context) error {
+	if k, ok := apikeys.FromContext(c); ok && !k.HasScope(users.PermRead) {
+		return apperror.Newf(apperror.KindForbidden, "api key lacks scope %s", users.PermRead)
+	}
+	return rbac.Check(c, users.PermRead)
+}
+
+func (s *Service) handleGet(c *gin.Context) {
+	sub, err := s.Get(c.Request.Context(), owner(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("webhook subscription found", sub))
+}
+
+func (s *Service) handleUnsubscribe(c *gin.Context) {
+	if err := s.Unsubscribe(c.Request.Context(), owner(c), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("webhook subscription deleted", nil))
+}
+
+func (s *Service) handlePing(c *gin.Context) {
+	d, err := s.Ping(c.Request.Context(), owner(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, render.OK("ping queued", d))
+}
+
+func (s *Service) handleDeliveries(c *gin.Context) {
+	spec, ok := query.Bind(c, DeliveryOptions)
+	if !ok {
+		return
+	}
+	list, page, err := s.Deliveries(c.Request.Context(), owner(c), c.Param("id"), spec)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	body := render.OK("webhook deliveries listed", list)
+	body.Meta = &page
+	c.JSON(http.StatusOK, body)
+}
+
+func (s *Service) handleRedeliver(c *gin.Context) {
+	d, err := s.Redeliver(c.Request.Context(), owner(c), c.Param("id"), c.Param("delivery"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, render.OK("redelivery queued", d))
+}
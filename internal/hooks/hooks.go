@@ -0,0 +1,393 @@
+// Package hooks delivers events to subscriber URLs as outbound webhooks.
+// Each delivery is stored, pushed by the job queue with retries and
+// exponential backoff, signed with the subscription's secret, and
+// dead-lettered once it runs out of attempts; its status stays queryable
+// through the API.
+package hooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/httpclient"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/jobs"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+)
+
+// KindDeliver is the job kind of a single delivery.
+const KindDeliver = "webhook.deliver"
+
+// SecretPrefix starts every signing secret.
+const SecretPrefix = "whsec_"
+
+// EventPing is sent on request to check that a subscriber is reachable.
+const EventPing = "ping"
+
+// Delivery states as stored in the webhook_deliveries table.
+const (
+	StatePending   = "pending"
+	StateDelivered = "delivered"
+	StateDead      = "dead" // attempts exhausted; redeliver to try again
+)
+
+// DeliveryOptions are the sort and filter fields of delivery lists.
+var DeliveryOptions = &query.Options{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Sort: true},
+		"event":      {Column: "event", Ops: []query.Op{query.Eq, query.In}},
+		"state":      {Column: "state", Ops: []query.Op{query.Eq, query.In}},
+		"attempts":   {Column: "attempts", Type: query.Int, Sort: true, Ops: []query.Op{query.Gte, query.Lte}},
+		"created_at": {Column: "created_at", Type: query.Time, Sort: true, Ops: []query.Op{query.Gt, query.Gte, query.Lt, query.Lte}},
+	},
+	Key:          "id",
+	Sort:         "-created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+var (
+	// ErrNotFound is returned when no subscription of the owner matches.
+	ErrNotFound = apperror.NotFound("webhook subscription not found")
+	// ErrDeliveryNotFound is returned when no delivery of the subscription
+	// matches.
+	ErrDeliveryNotFound = apperror.NotFound("webhook delivery not found")
+)
+
+// Subscription routes events to a URL. The secret is only shown when the
+// subscription is created.
+type Subscription struct {
+	ID        string    `json:"id"`
+	Owner     string    `json:"-"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Wants reports whether the subscription receives event.
+func (s *Subscription) Wants(event string) bool {
+	return event == EventPing || slices.Contains(s.Events, "*") || slices.Contains(s.Events, event)
+}
+
+// Delivery is one event sent, or to be sent, to one subscription.
+type Delivery struct {
+	ID          string          `json:"id"`
+	Event       string          `json:"event"`
+	State       string          `json:"state"`
+	Attempts    int             `json:"attempts"`
+	LastStatus  int             `json:"last_status,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Event is the body posted to subscribers.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Service stores subscriptions and delivers events to them.
+type Service struct {
+	db     *store.Store
+	queue  jobs.Enqueuer
+	cfg    config.Webhooks
+	client *http.Client
+}
+
+// New returns a Service enqueueing deliveries on queue. Register Deliver
+// as the KindDeliver handler on the instances that should send them.
+func New(db *store.Store, queue jobs.Enqueuer, cfg config.Webhooks, m *httpclient.Metrics) *Service {
+	return &Service{db: db, queue: queue, cfg: cfg, client: newClient(cfg, m)}
+}
+
+// Subscribe creates a subscription to events for owner in the tenant of
+// ctx and returns it with its signing secret.
+func (s *Service) Subscribe(ctx context.Context, owner, rawURL string, events []string) (*Subscription, error) {
+	if err := s.checkURL(rawURL); err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if e == "" || strings.ContainsFunc(e, isSpace) {
+			return nil, apperror.Newf(apperror.KindBadRequest, "invalid event %q", e)
+		}
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("hooks: generate secret: %w", err)
+	}
+	sub := &Subscription{
+		ID:        id.New(),
+		Owner:     owner,
+		URL:       rawURL,
+		Events:    slices.Compact(slices.Sorted(slices.Values(events))),
+		Secret:    SecretPrefix + base64.RawURLEncoding.EncodeToString(raw),
+		CreatedAt: time.Now().UTC(),
+	}
+	_, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`INSERT INTO webhook_subscriptions (id, tenant_id, owner, url, events, secret, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		sub.ID, tenant.ID(ctx), sub.Owner, sub.URL, strings.Join(sub.Events, " "), sub.Secret, sub.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("hooks: subscribe: %w", err)
+	}
+	return sub, nil
+}
+
+// checkURL accepts absolute http and https URLs. Whether the host is
+// allowed is decided when connecting, since DNS may change in between.
+func (s *Service) checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return apperror.BadRequest("webhook url must be an absolute http or https url")
+	}
+	return nil
+}
+
+// List returns the subscriptions of owner, newest first.
+func (s *Service) List(ctx context.Context, owner string) ([]Subscription, error) {
+	rows, err := s.db.Writer(ctx).QueryContext(ctx, s.db.Rebind(
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions
+		 WHERE tenant_id = ? AND owner = ? ORDER BY created_at DESC, id`), tenant.ID(ctx), owner)
+	if err != nil {
+		return nil, fmt.Errorf("hooks: list: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("hooks: list: %w", err)
+		}
+		list = append(list, *sub)
+	}
+	return list, rows.Err()
+}
+
+// Get returns the subscription id of owner.
+func (s *Service) Get(ctx context.Context, owner, id string) (*Subscription, error) {
+	sub, err := scanSubscription(s.db.Writer(ctx).QueryRowContext(ctx, s.db.Rebind(
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions
+		 WHERE tenant_id = ? AND owner = ? AND id = ?`), tenant.ID(ctx), owner, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hooks: get: %w", err)
+	}
+	return sub, nil
+}
+
+// Unsubscribe deletes the subscription id of owner with its deliveries;
+// queued deliveries are dropped when they come up.
+func (s *Service) Unsubscribe(ctx context.Context, owner, id string) error {
+	res, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`DELETE FROM webhook_subscriptions WHERE tenant_id = ? AND owner = ? AND id = ?`),
+		tenant.ID(ctx), owner, id)
+	if err != nil {
+		return fmt.Errorf("hooks: unsubscribe: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Publish queues event for every subscription in the tenant of ctx that
+// wants it. Delivery happens in the background, so subscribers cannot
+// slow down or fail the caller.
+func (s *Service) Publish(ctx context.Context, event string, data any) error {
+	rows, err := s.db.Writer(ctx).QueryContext(ctx, s.db.Rebind(
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE tenant_id = ?`), tenant.ID(ctx))
+	if err != nil {
+		return fmt.Errorf("hooks: publish %s: %w", event, err)
+	}
+	var targets []*Subscription
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("hooks: publish %s: %w", event, err)
+		}
+		if sub.Wants(event) {
+			targets = append(targets, sub)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("hooks: publish %s: %w", event, err)
+	}
+
+	for _, sub := range targets {
+		if _, err := s.enqueue(ctx, sub, event, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping queues a ping event for the subscription id of owner.
+func (s *Service) Ping(ctx context.Context, owner, id string) (*Delivery, error) {
+	sub, err := s.Get(ctx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.enqueue(ctx, sub, EventPing, map[string]string{"subscription_id": sub.ID})
+}
+
+// enqueue stores a delivery of event to sub and queues the job sending it.
+// The body is fixed here so that every attempt posts the same bytes.
+func (s *Service) enqueue(ctx context.Context, sub *Subscription, event string, data any) (*Delivery, error) {
+	d := &Delivery{ID: id.New(), Event: event, State: StatePending, CreatedAt: time.Now().UTC()}
+	body, err := json.Marshal(Event{ID: d.ID, Type: event, CreatedAt: d.CreatedAt, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("hooks: encode %s: %w", event, err)
+	}
+	d.Payload = body
+
+	_, err = s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`INSERT INTO webhook_deliveries (id, subscription_id, event, payload, state, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`),
+		d.ID, sub.ID, d.Event, string(body), d.State, d.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("hooks: store delivery: %w", err)
+	}
+	if err := s.schedule(ctx, d.ID); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *Service) schedule(ctx context.Context, deliveryID string) error {
+	_, err := s.queue.Enqueue(ctx, KindDeliver, deliverJob{DeliveryID: deliveryID},
+		jobs.WithMaxAttempts(s.cfg.MaxAttempts))
+	if err != nil {
+		return fmt.Errorf("hooks: schedule delivery: %w", err)
+	}
+	return nil
+}
+
+// Deliveries returns the page of deliveries of the subscription id of owner
+// that spec selects, newest first by default.
+func (s *Service) Deliveries(ctx context.Context, owner, id string, spec *query.Spec) ([]Delivery, query.Page, error) {
+	if _, err := s.Get(ctx, owner, id); err != nil {
+		return nil, query.Page{}, err
+	}
+	q, args := spec.Build(`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE subscription_id = ?`, id)
+	rows, err := s.db.Writer(ctx).QueryContext(ctx, s.db.Rebind(q), args...)
+	if err != nil {
+		return nil, query.Page{}, fmt.Errorf("hooks: deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, query.Page{}, fmt.Errorf("hooks: deliveries: %w", err)
+		}
+		list = append(list, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, query.Page{}, fmt.Errorf("hooks: deliveries: %w", err)
+	}
+	list, page := query.Paginate(spec, list, func(d Delivery, field string) any {
+		switch field {
+		case "attempts":
+			return d.Attempts
+		case "created_at":
+			return d.CreatedAt
+		}
+		return d.ID
+	})
+	return list, page, nil
+}
+
+// Redeliver queues delivery deliveryID of the subscription id of owner
+// again, typically after it was dead-lettered.
+func (s *Service) Redeliver(ctx context.Context, owner, id, deliveryID string) (*Delivery, error) {
+	if _, err := s.Get(ctx, owner, id); err != nil {
+		return nil, err
+	}
+	d, err := scanDelivery(s.db.Writer(ctx).QueryRowContext(ctx, s.db.Rebind(
+		`UPDATE webhook_deliveries SET state = ? WHERE subscription_id = ? AND id = ?
+		 RETURNING `+deliveryColumns), StatePending, id, deliveryID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hooks: redeliver: %w", err)
+	}
+	if err := s.schedule(ctx, d.ID); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "webhook redelivery queued", "delivery_id", d.ID, "subscription_id", id)
+	return d, nil
+}
+
+const (
+	subscriptionColumns = `id, owner, url, events, secret, created_at`
+	deliveryColumns     = `id, event, state, attempts, last_status, last_error, payload, created_at, delivered_at`
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(s scanner) (*Subscription, error) {
+	var (
+		sub    Subscription
+		events string
+	)
+	if err := s.Scan(&sub.ID, &sub.Owner, &sub.URL, &events, &sub.Secret, &sub.CreatedAt); err != nil {
+		return nil, err
+	}
+	sub.Events = strings.Fields(events)
+	return &sub, nil
+}
+
+func scanDelivery(s scanner) (*Delivery, error) {
+	var (
+		d         Delivery
+		status    sql.NullInt64
+		lastErr   sql.NullString
+		payload   string
+		delivered sql.NullTime
+	)
+	err := s.Scan(&d.ID, &d.Event, &d.State, &d.Attempts, &status, &lastErr, &payload, &d.CreatedAt, &delivered)
+	if err != nil {
+		return nil, err
+	}
+	d.LastStatus = int(status.Int64)
+	d.LastError = lastErr.String
+	d.Payload = json.RawMessage(payload)
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	return &d, nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
@@ -0,0 +1,203 @@
+// Package httpcache implements HTTP caching for API routes: conditional
+// GET with ETags and 304 responses, and an optional shared cache of full
+// GET responses with per-route TTLs and tag-based invalidation.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/tenant"
+)
+
+// HeaderCache reports whether a response came from the cache.
+const HeaderCache = "X-Cache"
+
+// maxStored is the largest response body that is cached.
+const maxStored = 1 << 20
+
+// Cache stores full GET responses. A nil *Cache hands out pass-through
+// handlers, so routes can be wrapped unconditionally.
+type Cache struct {
+	store      Store
+	defaultTTL time.Duration
+	// onInvalidate are called with the tags invalidated.
+	onInvalidate []func(ctx context.Context, tags []string)
+}
+
+// New returns a Cache keeping entries in store for defaultTTL unless a
+// route asks for another TTL.
+func New(store Store, defaultTTL time.Duration) *Cache {
+	return &Cache{store: store, defaultTTL: defaultTTL}
+}
+
+// entry is a cached response.
+type entry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Handler caches successful GET responses of the route for ttl, or the
+// default TTL when ttl is zero. Entries are keyed by tenant, request URI,
+// Accept header and locale, and are dropped when any of tags is
+// invalidated.
+// It must run after authorization checks and only wrap handlers whose
+// response does not depend on the caller.
+func (c *Cache) Handler(ttl time.Duration, tags ...string) gin.HandlerFunc {
+	if c == nil {
+		return func(ctx *gin.Context) { ctx.Next() }
+	}
+	if ttl == 0 {
+		ttl = c.defaultTTL
+	}
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method != http.MethodGet && ctx.Request.Method != http.MethodHead {
+			ctx.Next()
+			return
+		}
+		logger := logging.FromContext(ctx.Request.Context())
+		key, err := c.key(ctx, tags)
+		if err != nil {
+			logger.Warn("response cache unavailable", "error", err)
+			ctx.Next()
+			return
+		}
+
+		if ctx.GetHeader("Cache-Control") != "no-cache" {
+			if e, ok := c.lookup(ctx.Request.Context(), key); ok {
+				h := ctx.Writer.Header()
+				for name, values := range e.Header {
+					h[name] = values
+				}
+				h.Set(HeaderCache, "HIT")
+				ctx.Data(e.Status, h.Get("Content-Type"), e.Body)
+				ctx.Abort()
+				return
+			}
+		}
+
+		ctx.Writer.Header().Set(HeaderCache, "MISS")
+		rec := &recorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = rec
+		ctx.Next()
+		ctx.Writer = rec.ResponseWriter
+
+		if ctx.Request.Method != http.MethodGet || rec.Status() != http.StatusOK || rec.overflow || len(ctx.Errors) > 0 {
+			return
+		}
+		header := rec.Header().Clone()
+		for _, name := range []string{HeaderCache, "Set-Cookie", logging.HeaderRequestID} {
+			header.Del(name)
+		}
+		b, _ := json.Marshal(entry{Status: rec.Status(), Header: header, Body: rec.body.Bytes()})
+		if err := c.store.Set(ctx.Request.Context(), key, b, ttl); err != nil {
+			logger.Warn("response cache write failed", "error", err)
+		}
+	}
+}
+
+// Invalidate drops every cached response tagged with any of tags.
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.Evict(ctx, tags...); err != nil {
+		return err
+	}
+	for _, fn := range c.onInvalidate {
+		fn(ctx, tags)
+	}
+	return nil
+}
+
+// OnInvalidate registers fn to be called with the tags Invalidate drops,
+// such as to tell other instances to drop them too. It must be called
+// before the cache is shared.
+func (c *Cache) OnInvalidate(fn func(ctx context.Context, tags []string)) {
+	c.onInvalidate = append(c.onInvalidate, fn)
+}
+
+// Evict drops the responses tagged with any of tags without calling the
+// OnInvalidate functions, for invalidations made elsewhere.
+func (c *Cache) Evict(ctx context.Context, tags ...string) error {
+	var errs []error
+	for _, tag := range tags {
+		errs = append(errs, c.store.Set(ctx, "tag:"+tag, []byte(rand.Text()), 0))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Cache) lookup(ctx context.Context, key string) (*entry, bool) {
+	b, err := c.store.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrMiss) {
+			logging.FromContext(ctx).Warn("response cache read failed", "error", err)
+		}
+		return nil, false
+	}
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+// key folds the current version of each tag into the entry key, so that
+// invalidating a tag orphans its entries until they expire.
+func (c *Cache) key(ctx *gin.Context, tags []string) (string, error) {
+	h := sha256.New()
+	for _, part := range []string{tenant.ID(ctx.Request.Context()), ctx.Request.URL.RequestURI(), ctx.GetHeader("Accept"), i18n.Locale(ctx.Request.Context())} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	for _, tag := range tags {
+		v, err := c.store.Get(ctx.Request.Context(), "tag:"+tag)
+		if err != nil && !errors.Is(err, ErrMiss) {
+			return "", err
+		}
+		h.Write(v)
+		h.Write([]byte{0})
+	}
+	return "resp:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// recorder copies the response body while it is written.
+type recorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.record(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.record([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *recorder) record(b []byte) {
+	if r.overflow {
+		return
+	}
+	if r.body.Len()+len(b) > maxStored {
+		r.overflow = true
+		r.body.Reset()
+		return
+	}
+	r.body.Write(b)
+}
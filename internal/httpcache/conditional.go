@@ -0,0 +1,121 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Conditional answers GET and HEAD requests with 304 Not Modified when the
+// client's If-None-Match or If-Modified-Since validator is still current.
+// Successful responses without an ETag get a weak one derived from the
+// body, so the body is buffered until the handler returns.
+func Conditional() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK, size: -1}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		w.finish(c.Request)
+	}
+}
+
+// bufferedWriter holds the response back until finish.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	size   int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && w.size < 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.size < 0 {
+		w.size = 0
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, _ := w.buf.Write(b)
+	w.size += n
+	return n, nil
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int   { return w.status }
+func (w *bufferedWriter) Size() int     { return w.size }
+func (w *bufferedWriter) Written() bool { return w.size >= 0 }
+func (w *bufferedWriter) Flush()        {}
+
+// finish writes the buffered response, or 304 when the client has it.
+// Nothing is written when the handler wrote nothing, which leaves errors
+// to the error middleware.
+func (w *bufferedWriter) finish(r *http.Request) {
+	if !w.Written() {
+		return
+	}
+	h := w.Header()
+	if w.status == http.StatusOK {
+		etag := h.Get("ETag")
+		if etag == "" {
+			etag = weakETag(w.buf.Bytes())
+			h.Set("ETag", etag)
+		}
+		if notModified(r, etag, h.Get("Last-Modified")) {
+			for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+				h.Del(name)
+			}
+			w.ResponseWriter.WriteHeader(http.StatusNotModified)
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
+
+func weakETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + base64.RawURLEncoding.EncodeToString(sum[:12]) + `"`
+}
+
+// notModified applies RFC 9110: If-None-Match wins over If-Modified-Since,
+// and comparison is weak.
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for tag := range strings.SplitSeq(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified == "" {
+		return false
+	}
+	lm, err := http.ParseTime(lastModified)
+	return err == nil && !lm.Truncate(time.Second).After(ims)
+}
@@ -0,0 +1,129 @@
+package httpcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/kv"
+)
+
+// ErrMiss is returned by a Store for absent or expired keys.
+var ErrMiss = errors.New("httpcache: miss")
+
+// Store holds cached entries. A zero ttl keeps the value until evicted.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// maxEntries caps the memory store; it is simply emptied when full.
+const maxEntries = 10000
+
+// MemoryStore keeps entries in process memory, per instance.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || (!e.expires.IsZero() && time.Now().After(e.expires)) {
+		return nil, ErrMiss
+	}
+	return e.value, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= maxEntries {
+		clear(s.entries)
+	}
+	e := memoryEntry{value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	s.entries[key] = e
+	return nil
+}
+
+// RedisStore keeps entries in Redis, shared by every instance.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client, prefix: "httpcache:"}
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("httpcache: redis get: %w", err)
+	}
+	return v, nil
+}
+
+// Set implements Store.
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.client.Set(ctx, s.prefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("httpcache: redis set: %w", err)
+	}
+	return nil
+}
+
+// KVStore keeps entries in a bucket of the embedded store, kept across
+// restarts of a single node.
+type KVStore struct {
+	bucket *kv.Bucket
+}
+
+// NewKVStore returns a Store backed by db.
+func NewKVStore(db *kv.DB) *KVStore {
+	return &KVStore{bucket: db.Bucket("httpcache")}
+}
+
+// Get implements Store.
+func (s *KVStore) Get(_ context.Context, key string) ([]byte, error) {
+	v, err := s.bucket.Get(key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, ErrMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("httpcache: kv get: %w", err)
+	}
+	return v, nil
+}
+
+// Set implements Store.
+func (s *KVStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.bucket.Put(key, value, ttl); err != nil {
+		return fmt.Errorf("httpcache: kv set: %w", err)
+	}
+	return nil
+}
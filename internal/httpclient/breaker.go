@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the host while its circuit
+// is open.
+var ErrCircuitOpen = errors.New("httpclient: circuit open")
+
+type state int
+
+const (
+	closed state = iota
+	halfOpen
+	open
+)
+
+func (s state) String() string {
+	switch s {
+	case halfOpen:
+		return "half-open"
+	case open:
+		return "open"
+	}
+	return "closed"
+}
+
+// breaker counts consecutive failures of one host. Once open it rejects
+// requests until the cooldown has passed, then lets a single probe through:
+// its success closes the circuit, its failure opens it again.
+type breaker struct {
+	failures int
+	cooldown time.Duration
+	onChange func(state)
+
+	mu       sync.Mutex
+	state    state
+	count    int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case open:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.set(halfOpen)
+		b.probing = true
+		return nil
+	case halfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *breaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if ok {
+		b.count = 0
+		b.set(closed)
+		return
+	}
+	b.count++
+	if b.state == halfOpen || b.count >= b.failures {
+		b.openedAt = time.Now()
+		b.set(open)
+	}
+}
+
+// abandon ends an attempt without judging the host, so a cancelled probe
+// lets the next request probe instead.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) set(s state) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	if b.onChange != nil {
+		b.onChange(s)
+	}
+}
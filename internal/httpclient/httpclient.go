@@ -0,0 +1,96 @@
+// Package httpclient builds HTTP clients for calling downstream services.
+// Requests are traced, retried with jittered exponential backoff when that
+// is safe, and guarded by a circuit breaker per host so a failing
+// dependency is not hammered. Requests to services that authenticate
+// callers by request signatures can be signed with a Signer.
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"go-flylike-example/internal/tracing"
+)
+
+type options struct {
+	base       http.RoundTripper
+	timeout    time.Duration
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	failures   int
+	cooldown   time.Duration
+	metrics    *Metrics
+	signer     *Signer
+}
+
+// Option customises a client or transport.
+type Option func(*options)
+
+// WithTimeout sets the overall client timeout, retries included
+// (default 30s). It has no effect on NewTransport.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithRetries sets how many times a failed idempotent request is retried
+// (default 2). Zero disables retries.
+func WithRetries(n int) Option {
+	return func(o *options) { o.retries = n }
+}
+
+// WithBackoff bounds the randomised delay between retries (default 100ms
+// doubling up to 2s).
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *options) { o.minBackoff, o.maxBackoff = min, max }
+}
+
+// WithBreaker opens a host's circuit after failures consecutive failures
+// and probes it again after cooldown (default 5 and 30s). Zero failures
+// disables the breaker.
+func WithBreaker(failures int, cooldown time.Duration) Option {
+	return func(o *options) { o.failures, o.cooldown = failures, cooldown }
+}
+
+// WithMetrics records requests, retries and breaker state on m.
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// WithBase sets the transport performing the actual requests (default: a
+// tracing wrapper around a clone of http.DefaultTransport).
+func WithBase(rt http.RoundTripper) Option {
+	return func(o *options) { o.base = rt }
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		timeout:    30 * time.Second,
+		retries:    2,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 2 * time.Second,
+		failures:   5,
+		cooldown:   30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.base == nil {
+		base := http.DefaultTransport.(*http.Transport).Clone()
+		base.MaxIdleConnsPerHost = 32
+		o.base = tracing.Transport(base)
+	}
+	return o
+}
+
+// New returns a client with retries and circuit breaking.
+func New(opts ...Option) *http.Client {
+	o := newOptions(opts)
+	return &http.Client{Transport: newTransport(o), Timeout: o.timeout}
+}
+
+// NewTransport returns the resilient round tripper on its own, for use
+// with clients or proxies that manage timeouts themselves.
+func NewTransport(opts ...Option) http.RoundTripper {
+	return newTransport(newOptions(opts))
+}
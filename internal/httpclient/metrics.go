@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are the collectors shared by every client built WithMetrics.
+// A nil *Metrics records nothing.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	retries  *prometheus.CounterVec
+	state    *prometheus.GaugeVec
+}
+
+// NewMetrics creates the outbound HTTP collectors and registers them on reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "httpclient",
+			Name:      "requests_total",
+			Help:      "Outbound HTTP attempts by host and result (status code, error or circuit_open).",
+		}, []string{"host", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "httpclient",
+			Name:      "request_duration_seconds",
+			Help:      "Outbound HTTP attempt latency in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"host"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "httpclient",
+			Name:      "retries_total",
+			Help:      "Outbound HTTP requests retried.",
+		}, []string{"host"}),
+		state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "httpclient",
+			Name:      "circuit_state",
+			Help:      "Circuit breaker state per host: 0 closed, 1 half-open, 2 open.",
+		}, []string{"host"}),
+	}
+	reg.MustRegister(m.requests, m.duration, m.retries, m.state)
+	return m
+}
+
+func (m *Metrics) observe(host string, resp *http.Response, err error, d time.Duration) {
+	if m == nil {
+		return
+	}
+	result := "error"
+	switch {
+	case err == ErrCircuitOpen:
+		m.requests.WithLabelValues(host, "circuit_open").Inc()
+		return
+	case err == nil:
+		result = strconv.Itoa(resp.StatusCode)
+	}
+	m.requests.WithLabelValues(host, result).Inc()
+	m.duration.WithLabelValues(host).Observe(d.Seconds())
+}
+
+func (m *Metrics) retried(host string) {
+	if m != nil {
+		m.retries.WithLabelValues(host).Inc()
+	}
+}
+
+func (m *Metrics) circuit(host string, s state) {
+	if m != nil {
+		m.state.WithLabelValues(host).Set(float64(s))
+	}
+}
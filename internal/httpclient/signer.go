@@ -0,0 +1,42 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go-flylike-example/internal/reqsign"
+)
+
+// Signer signs requests for services that verify them with reqsign. Its
+// key can be rotated while clients use it: requests are signed with the
+// key current when they are sent, retries included.
+type Signer struct {
+	key atomic.Pointer[reqsign.Key]
+}
+
+// NewSigner returns a Signer signing with key.
+func NewSigner(key reqsign.Key) *Signer {
+	s := &Signer{}
+	s.key.Store(&key)
+	return s
+}
+
+// Rotate has the following requests signed with key.
+func (s *Signer) Rotate(key reqsign.Key) {
+	s.key.Store(&key)
+}
+
+// WithSigner signs every request, and every retry afresh, with s.
+func WithSigner(s *Signer) Option {
+	return func(o *options) { o.signer = s }
+}
+
+// sign returns a signed copy of req, leaving req as the caller made it.
+func (s *Signer) sign(req *http.Request) (*http.Request, error) {
+	signed := req.Clone(req.Context())
+	if err := reqsign.Sign(signed, *s.key.Load(), time.Now()); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
@@ -0,0 +1,161 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type transport struct {
+	opts *options
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func newTransport(o *options) *transport {
+	return &transport{opts: o, breakers: make(map[string]*breaker)}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	b := t.breaker(host)
+	retries := t.opts.retries
+	if !replayable(req) {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			var err error
+			if req, err = rewind(req); err != nil {
+				return nil, err
+			}
+		}
+
+		var (
+			resp *http.Response
+			err  error
+		)
+		send := req
+		if t.opts.signer != nil {
+			if send, err = t.opts.signer.sign(req); err != nil {
+				return nil, err
+			}
+		}
+		if b != nil {
+			if err = b.allow(); err != nil {
+				t.opts.metrics.observe(host, nil, err, 0)
+				return nil, err
+			}
+		}
+		start := time.Now()
+		resp, err = t.opts.base.RoundTrip(send)
+		t.opts.metrics.observe(host, resp, err, time.Since(start))
+		if b != nil {
+			if req.Context().Err() != nil {
+				// The caller gave up, which says nothing about the host.
+				b.abandon()
+			} else {
+				b.record(err == nil && resp.StatusCode < 500)
+			}
+		}
+
+		if attempt == retries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		wait := t.backoff(attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		t.opts.metrics.retried(host)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, context.Cause(req.Context())
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *transport) breaker(host string) *breaker {
+	if t.opts.failures <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{
+			failures: t.opts.failures,
+			cooldown: t.opts.cooldown,
+			onChange: func(s state) { t.opts.metrics.circuit(host, s) },
+		}
+		t.breakers[host] = b
+	}
+	return b
+}
+
+// backoff picks a random delay up to an exponentially growing ceiling
+// ("full jitter"), or honours a short Retry-After from the server.
+func (t *transport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if d := time.Duration(s) * time.Second; d <= t.opts.maxBackoff {
+				return d
+			}
+		}
+	}
+	ceiling := t.opts.minBackoff << attempt
+	if ceiling <= 0 || ceiling > t.opts.maxBackoff {
+		ceiling = t.opts.maxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// replayable reports whether req may be sent more than once: its method
+// must be idempotent and its body, if any, reproducible.
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		// Errors caused by the caller giving up are final.
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
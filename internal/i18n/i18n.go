@@ -0,0 +1,172 @@
+// Package i18n localizes the messages the API sends to people. Messages are
+// keyed by their English text, which is also the fmt format of the English
+// rendering, and translated by the JSON catalogs under locales/, one per
+// language and named by its BCP 47 tag. Each request is answered in the
+// best match of its Accept-Language header among English and the catalogs,
+// or in the default locale; keys a catalog lacks stay English.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Source is the language message keys are written in.
+var Source = language.English
+
+// catalog maps keys to their translation.
+type catalog map[string]string
+
+// Bundle holds the catalogs and negotiates between them.
+type Bundle struct {
+	// tags lists the offered locales, the default first, and catalogs
+	// holds one for each; the source language has an empty one.
+	tags     []language.Tag
+	catalogs []catalog
+	matcher  language.Matcher
+}
+
+// New loads the embedded catalogs. defaultLocale answers requests that
+// accept none of them and must be English or have a catalog.
+func New(defaultLocale string) (*Bundle, error) {
+	def, err := language.Parse(defaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("i18n: default locale: %w", err)
+	}
+	byTag := map[language.Tag]catalog{Source: {}}
+	names, err := fs.Glob(locales, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		tag, err := language.Parse(strings.TrimSuffix(path.Base(name), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("i18n: catalog %s: %w", name, err)
+		}
+		cat, err := load(name)
+		if err != nil {
+			return nil, err
+		}
+		byTag[tag] = cat
+	}
+	if _, ok := byTag[def]; !ok {
+		return nil, fmt.Errorf("i18n: no catalog for default locale %s", def)
+	}
+
+	b := &Bundle{tags: []language.Tag{def}, catalogs: []catalog{byTag[def]}}
+	others := make([]language.Tag, 0, len(byTag)-1)
+	for tag := range byTag {
+		if tag != def {
+			others = append(others, tag)
+		}
+	}
+	slices.SortFunc(others, func(a, b language.Tag) int { return strings.Compare(a.String(), b.String()) })
+	for _, tag := range others {
+		b.tags = append(b.tags, tag)
+		b.catalogs = append(b.catalogs, byTag[tag])
+	}
+	b.matcher = language.NewMatcher(b.tags)
+	return b, nil
+}
+
+// verb matches the fmt verbs of a message, which translations must keep.
+var verb = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+func load(name string) (catalog, error) {
+	b, err := locales.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cat catalog
+	if err := json.Unmarshal(b, &cat); err != nil {
+		return nil, fmt.Errorf("i18n: catalog %s: %w", name, err)
+	}
+	for key, msg := range cat {
+		if len(verb.FindAllString(key, -1)) != len(verb.FindAllString(msg, -1)) {
+			return nil, fmt.Errorf("i18n: catalog %s: %q does not take the arguments of its key %q", name, msg, key)
+		}
+	}
+	return cat, nil
+}
+
+// Locales returns the offered locales, the default first.
+func (b *Bundle) Locales() []string {
+	out := make([]string, len(b.tags))
+	for i, tag := range b.tags {
+		out[i] = tag.String()
+	}
+	return out
+}
+
+// localizer is the locale chosen for a request.
+type localizer struct {
+	tag     language.Tag
+	catalog catalog
+}
+
+type contextKey struct{}
+
+// Middleware picks the locale of each request from its Accept-Language
+// header, announces it in Content-Language and makes it available to T.
+func (b *Bundle) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := b.negotiate(c.GetHeader("Accept-Language"))
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, l))
+		h := c.Writer.Header()
+		h.Set("Content-Language", l.tag.String())
+		h.Add("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+func (b *Bundle) negotiate(accept string) *localizer {
+	// A header that does not parse is ignored, like a missing one.
+	wanted, _, _ := language.ParseAcceptLanguage(accept)
+	// The matcher falls back to the first, default, tag.
+	_, i, _ := b.matcher.Match(wanted...)
+	return &localizer{tag: b.tags[i], catalog: b.catalogs[i]}
+}
+
+// T returns the message for key in the locale of the request, formatted
+// with args like fmt.Sprintf.
+func T(c *gin.Context, key string, args ...any) string {
+	return Translate(c.Request.Context(), key, args...)
+}
+
+// Translate is T for code that has the request context rather than the
+// gin.Context. Outside a request, and for keys the catalog does not
+// translate, key itself is formatted.
+func Translate(ctx context.Context, key string, args ...any) string {
+	format := key
+	if l, ok := ctx.Value(contextKey{}).(*localizer); ok {
+		if msg, ok := l.catalog[key]; ok {
+			format = msg
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Locale returns the locale negotiated for the request ctx belongs to, or
+// "" outside one.
+func Locale(ctx context.Context) string {
+	if l, ok := ctx.Value(contextKey{}).(*localizer); ok {
+		return l.tag.String()
+	}
+	return ""
+}
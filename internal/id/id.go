@@ -0,0 +1,146 @@
+// Package id generates the IDs of stored records. IDs are ULIDs: 128 bits
+// written as 26 characters of Crockford base32, starting with the
+// millisecond they were made in, so that they sort by creation time as
+// text and in an index. Unlike auto-increment keys they need no
+// coordination, so that every region can create records on its own.
+//
+// The 80 bits after the time are this machine's node, 16 bits derived
+// from FLY_MACHINE_ID, and a sequence that starts at a random value each
+// millisecond and counts up within it. IDs of one machine are therefore
+// strictly increasing, and two machines only risk a collision if their
+// nodes and random starts agree in the same millisecond.
+package id
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encoding is the Crockford base32 alphabet ULIDs are written in.
+const encoding = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Len is the length of an ID as text.
+const Len = 26
+
+// ErrInvalid is returned by Parse for text that is not an ID.
+var ErrInvalid = errors.New("id: invalid id")
+
+// ID is a ULID.
+type ID [16]byte
+
+// String returns id as its 26 characters.
+func (id ID) String() string {
+	var b [Len]byte
+	// 130 bits of base32 hold the 128 of the ID, the first character
+	// taking its top 3.
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	for i := Len - 1; i >= 0; i-- {
+		b[i] = encoding[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(b[:])
+}
+
+// Time returns when id was made, to the millisecond.
+func (id ID) Time() time.Time {
+	var ms [8]byte
+	copy(ms[2:], id[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:]))).UTC()
+}
+
+// Node returns the node of the machine that made id.
+func (id ID) Node() uint16 {
+	return binary.BigEndian.Uint16(id[6:8])
+}
+
+// Parse reads an ID from its text, in either case.
+func Parse(s string) (ID, error) {
+	var id ID
+	if len(s) != Len {
+		return id, ErrInvalid
+	}
+	var hi, lo uint64
+	for i, r := range strings.ToUpper(s) {
+		v := strings.IndexRune(encoding, r)
+		if v < 0 || (i == 0 && v > 7) {
+			return id, ErrInvalid
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	binary.BigEndian.PutUint64(id[:8], hi)
+	binary.BigEndian.PutUint64(id[8:], lo)
+	return id, nil
+}
+
+// Generator makes the IDs of one node.
+type Generator struct {
+	node uint16
+
+	mu   sync.Mutex
+	last int64 // millisecond of the last ID
+	seq  uint64
+}
+
+// NewGenerator returns a Generator for node.
+func NewGenerator(node uint16) *Generator {
+	return &Generator{node: node}
+}
+
+// New returns the next ID. Should the clock go back, IDs keep the time of
+// the last one, so that they still increase.
+func (g *Generator) New() ID {
+	g.mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms > g.last {
+		g.last = ms
+		// The top bit is left clear, so that no millisecond runs out.
+		g.seq = random() >> 1
+	} else {
+		g.seq++
+	}
+	ms, seq := g.last, g.seq
+	g.mu.Unlock()
+
+	var id ID
+	var t [8]byte
+	binary.BigEndian.PutUint64(t[:], uint64(ms))
+	copy(id[:6], t[2:])
+	binary.BigEndian.PutUint16(id[6:8], g.node)
+	binary.BigEndian.PutUint64(id[8:], seq)
+	return id
+}
+
+// Node is the node of the machine machineID: a hash of it, or a random
+// node when it is empty, as when not running on Fly.
+func Node(machineID string) uint16 {
+	if machineID == "" {
+		return uint16(random())
+	}
+	h := fnv.New32a()
+	h.Write([]byte(machineID))
+	v := h.Sum32()
+	return uint16(v>>16 ^ v)
+}
+
+func random() uint64 {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// Default makes the IDs of this process, with the node of FLY_MACHINE_ID.
+var Default = NewGenerator(Node(os.Getenv("FLY_MACHINE_ID")))
+
+// New returns the text of a new ID from Default.
+func New() string {
+	return Default.New().String()
+}
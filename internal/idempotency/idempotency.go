@@ -0,0 +1,181 @@
+// Package idempotency implements the Idempotency-Key header for unsafe
+// requests: the first request with a key runs and its response is stored,
+// retries with the same key get that response replayed instead of
+// repeating the side effects.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/tenant"
+)
+
+const (
+	// Header carries the client's key.
+	Header = "Idempotency-Key"
+	// HeaderReplayed marks a response served from the store.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLen = 255
+	// maxStored is the largest response body that is stored; requests
+	// with bigger responses are not protected.
+	maxStored = 1 << 20
+)
+
+// storedHeaders are replayed along with the body; the rest describe the
+// original exchange rather than the resource.
+var storedHeaders = []string{"Content-Type", "Location", "ETag", "Last-Modified"}
+
+// Middleware honours Idempotency-Key on POST and PATCH requests. Keys are
+// scoped to the tenant, the caller and the route, and reusing one for a
+// different request is refused with 422. Only written responses below 500
+// are stored: a failed or error-rendered request may be retried for real.
+func Middleware(s Store, cfg config.Idempotency) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		method := c.Request.Method
+		if key == "" || (method != http.MethodPost && method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLen {
+			c.Error(apperror.BadRequest("idempotency key too long"))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.Error(apperror.PayloadTooLarge("request body too large"))
+			} else {
+				c.Error(apperror.BadRequest("failed to read request body"))
+			}
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		id := scopedKey(c, key)
+		rec := &Record{Fingerprint: fingerprint(c.Request, body)}
+		prev, err := s.Reserve(ctx, id, rec, cfg.Lock)
+		if err != nil {
+			c.Error(&apperror.Error{Kind: apperror.KindUnavailable, Message: "idempotency store unavailable", Err: err})
+			c.Abort()
+			return
+		}
+		if prev != nil {
+			replay(c, prev, rec.Fingerprint)
+			return
+		}
+
+		w := &recorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if !w.Written() || w.Status() >= http.StatusInternalServerError || w.overflow {
+			if err := s.Release(ctx, id); err != nil {
+				slog.WarnContext(ctx, "idempotency key not released", "error", err)
+			}
+			return
+		}
+		rec.Status = w.Status()
+		rec.Body = w.buf.Bytes()
+		rec.Header = http.Header{}
+		for _, name := range storedHeaders {
+			if v := w.Header().Get(name); v != "" {
+				rec.Header.Set(name, v)
+			}
+		}
+		if err := s.Complete(ctx, id, rec, cfg.TTL); err != nil {
+			slog.ErrorContext(ctx, "idempotent response not stored", "error", err)
+		}
+	}
+}
+
+func replay(c *gin.Context, prev *Record, fp string) {
+	switch {
+	case prev.Fingerprint != fp:
+		c.Error(apperror.Unprocessable("idempotency key was already used for a different request"))
+		c.Abort()
+	case prev.Status == 0:
+		c.Header("Retry-After", "1")
+		c.Error(apperror.Conflict("a request with this idempotency key is in progress"))
+		c.Abort()
+	default:
+		h := c.Writer.Header()
+		for name, values := range prev.Header {
+			h[name] = values
+		}
+		h.Set(HeaderReplayed, "true")
+		c.Writer.WriteHeader(prev.Status)
+		_, _ = c.Writer.Write(prev.Body)
+		c.Abort()
+	}
+}
+
+// scopedKey keeps one client's keys from colliding with, or revealing the
+// responses of, another's.
+func scopedKey(c *gin.Context, key string) string {
+	caller := "ip:" + c.ClientIP()
+	if claims, ok := auth.ClaimsFrom(c); ok {
+		caller = "sub:" + claims.Subject
+	}
+	return digest(tenant.ID(c.Request.Context()), caller, c.Request.Method, c.FullPath(), key)
+}
+
+func fingerprint(r *http.Request, body []byte) string {
+	return digest(r.Method, r.URL.RequestURI(), string(body))
+}
+
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder keeps a copy of the response body.
+type recorder struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.capture(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.capture([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *recorder) capture(b []byte) {
+	if r.overflow {
+		return
+	}
+	if r.buf.Len()+len(b) > maxStored {
+		r.overflow = true
+		r.buf = bytes.Buffer{}
+		return
+	}
+	r.buf.Write(b)
+}
@@ -0,0 +1,166 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/store"
+)
+
+// Record is what is remembered about a key: the request it was first used
+// with and, once that finished, its response.
+type Record struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status,omitempty"` // 0 while in flight
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// Store persists records.
+type Store interface {
+	// Reserve claims key for rec for up to lock. When the key is already
+	// taken, the existing record is returned instead.
+	Reserve(ctx context.Context, key string, rec *Record, lock time.Duration) (*Record, error)
+	// Complete stores the finished record for ttl.
+	Complete(ctx context.Context, key string, rec *Record, ttl time.Duration) error
+	// Release forgets key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// SQLStore keeps records in the idempotency_keys table.
+type SQLStore struct {
+	db *store.Store
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *store.Store) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Reserve implements Store.
+func (s *SQLStore) Reserve(ctx context.Context, key string, rec *Record, lock time.Duration) (*Record, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	// An expired record, such as the reservation of a crashed instance,
+	// no longer counts.
+	if _, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`DELETE FROM idempotency_keys WHERE id = ? AND expires_at < ?`), key, now.Unix()); err != nil {
+		return nil, fmt.Errorf("idempotency: reserve: %w", err)
+	}
+	_, err = s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`INSERT INTO idempotency_keys (id, record, expires_at) VALUES (?, ?, ?)`),
+		key, string(data), now.Add(lock).Unix())
+	if err == nil {
+		return nil, nil
+	}
+	if !store.IsUniqueViolation(err) {
+		return nil, fmt.Errorf("idempotency: reserve: %w", err)
+	}
+
+	var existing string
+	err = s.db.Writer(ctx).QueryRowContext(ctx, s.db.Rebind(
+		`SELECT record FROM idempotency_keys WHERE id = ?`), key).Scan(&existing)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released in the meantime; let the client retry.
+		return &Record{Fingerprint: rec.Fingerprint}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("idempotency: reserve: %w", err)
+	}
+	var prev Record
+	if err := json.Unmarshal([]byte(existing), &prev); err != nil {
+		return nil, fmt.Errorf("idempotency: decode record: %w", err)
+	}
+	return &prev, nil
+}
+
+// Complete implements Store.
+func (s *SQLStore) Complete(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`UPDATE idempotency_keys SET record = ?, expires_at = ? WHERE id = ?`),
+		string(data), time.Now().Add(ttl).Unix(), key); err != nil {
+		return fmt.Errorf("idempotency: complete: %w", err)
+	}
+	return nil
+}
+
+// Release implements Store.
+func (s *SQLStore) Release(ctx context.Context, key string) error {
+	if _, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`DELETE FROM idempotency_keys WHERE id = ?`), key); err != nil {
+		return fmt.Errorf("idempotency: release: %w", err)
+	}
+	return nil
+}
+
+// RedisStore keeps records in Redis, expiring them there.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client, prefix: "idempotency:"}
+}
+
+// Reserve implements Store.
+func (s *RedisStore) Reserve(ctx context.Context, key string, rec *Record, lock time.Duration) (*Record, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.client.SetNX(ctx, s.prefix+key, data, lock).Result()
+	if err != nil {
+		return nil, fmt.Errorf("idempotency: redis reserve: %w", err)
+	}
+	if ok {
+		return nil, nil
+	}
+	existing, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return &Record{Fingerprint: rec.Fingerprint}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("idempotency: redis reserve: %w", err)
+	}
+	var prev Record
+	if err := json.Unmarshal(existing, &prev); err != nil {
+		return nil, fmt.Errorf("idempotency: decode record: %w", err)
+	}
+	return &prev, nil
+}
+
+// Complete implements Store.
+func (s *RedisStore) Complete(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Set(ctx, s.prefix+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("idempotency: redis complete: %w", err)
+	}
+	return nil
+}
+
+// Release implements Store.
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+		return fmt.Errorf("idempotency: redis release: %w", err)
+	}
+	return nil
+}
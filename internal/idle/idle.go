@@ -0,0 +1,89 @@
+// Package idle notices when an instance has stopped serving anyone, so that
+// it can give its Fly machine back: exit, so the Fly proxy's autostop
+// finds it stopped and autostart boots it on the next request, or ask the
+// Machines API to stop or suspend it. An instance is busy while a request
+// is in flight or a realtime connection is open.
+package idle
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ignored are the paths of health probes and metric scrapes, which arrive
+// whether anyone uses the instance or not.
+var Ignored = []string{"/healthz", "/readyz", "/health", "/metrics"}
+
+// Tracker counts the requests in flight and remembers when the last one
+// ended.
+type Tracker struct {
+	inflight atomic.Int64
+	last     atomic.Int64 // unix nanoseconds
+	open     []func() int
+}
+
+// New returns a Tracker that also counts the long-lived connections
+// reported by open, such as the clients of the realtime hub.
+func New(open ...func() int) *Tracker {
+	t := &Tracker{open: open}
+	t.touch()
+	return t
+}
+
+func (t *Tracker) touch() {
+	t.last.Store(time.Now().UnixNano())
+}
+
+// Middleware counts the requests of the router it is used on, except for
+// the Ignored paths.
+func (t *Tracker) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if slices.Contains(Ignored, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		t.inflight.Add(1)
+		t.touch()
+		defer func() {
+			t.touch()
+			t.inflight.Add(-1)
+		}()
+		c.Next()
+	}
+}
+
+// Idle returns how long nothing has been served, zero while busy.
+func (t *Tracker) Idle() time.Duration {
+	busy := t.inflight.Load() > 0
+	for _, open := range t.open {
+		busy = busy || open() > 0
+	}
+	if busy {
+		t.touch()
+		return 0
+	}
+	return time.Since(time.Unix(0, t.last.Load()))
+}
+
+// Watch calls onIdle whenever the instance has been idle for timeout,
+// until ctx is done. The idle period starts over when onIdle returns, as it
+// does after a suspended machine resumes.
+func (t *Tracker) Watch(ctx context.Context, timeout time.Duration, onIdle func(ctx context.Context)) {
+	tick := time.NewTicker(max(timeout/10, time.Second))
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if t.Idle() >= timeout {
+				onIdle(ctx)
+				t.touch()
+			}
+		}
+	}
+}
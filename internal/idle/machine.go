@@ -0,0 +1,63 @@
+package idle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"go-flylike-example/internal/config"
+)
+
+// Machine stops or suspends the Fly machine the process runs on through the
+// Machines API.
+type Machine struct {
+	client *http.Client
+	base   string
+	token  string
+}
+
+// NewMachine returns a Machine for the one named by FLY_APP_NAME and
+// FLY_MACHINE_ID, which Fly sets on every machine.
+func NewMachine(cfg config.Autostop, client *http.Client) (*Machine, error) {
+	app, id := os.Getenv("FLY_APP_NAME"), os.Getenv("FLY_MACHINE_ID")
+	if app == "" || id == "" {
+		return nil, errors.New("idle: FLY_APP_NAME and FLY_MACHINE_ID are not set; not running on a Fly machine")
+	}
+	base := strings.TrimSuffix(cfg.APIURL, "/") + "/v1/apps/" + url.PathEscape(app) + "/machines/" + url.PathEscape(id)
+	return &Machine{client: client, base: base, token: cfg.APIToken}, nil
+}
+
+// Stop asks Fly to stop the machine, which then signals the process to
+// shut down as on any stop.
+func (m *Machine) Stop(ctx context.Context) error {
+	return m.post(ctx, "stop")
+}
+
+// Suspend asks Fly to snapshot the machine and pause it. The call returns
+// once the machine runs again, or fails when the snapshot cut it off.
+func (m *Machine) Suspend(ctx context.Context) error {
+	return m.post(ctx, "suspend")
+}
+
+func (m *Machine) post(ctx context.Context, action string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.base+"/"+action, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("idle: machine %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("idle: machine %s: %s: %s", action, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
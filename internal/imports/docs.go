@@ -0,0 +1,37 @@
+package imports
+
+import (
+	"net/http"
+	"strings"
+
+	"go-flylike-example/internal/openapi"
+	"go-flylike-example/internal/operations"
+)
+
+type importRequest struct {
+	File openapi.Binary `json:"file" binding:"required"`
+}
+
+// Operations documents the routes of Register.
+func (im *Importer) Operations() []openapi.Operation {
+	tags := []string{"imports"}
+	var ops []openapi.Operation
+	for _, r := range im.resources {
+		ops = append(ops, openapi.Operation{
+			ID: "import" + strings.ToUpper(r.name[:1]) + r.name[1:], Method: http.MethodPost, Path: "/" + r.name, Tags: tags, Summary: "Import " + r.name, Auth: true,
+			Description: "Requires the " + r.perm + " permission. The body is a CSV file whose header names columns of " +
+				strings.Join(r.columns, ", ") + ", or a JSONL file of objects with those members; a multipart form sends it " +
+				"as file. The import runs as an operation, whose progress and, once done, result with the row errors are polled. " +
+				"Files with invalid rows are not imported unless skip_invalid is set.",
+			Query: []openapi.Param{
+				{Name: "format", Description: "csv or jsonl; by default from the content type or file name"},
+				{Name: "dry_run", Description: "true to only validate the file"},
+				{Name: "skip_invalid", Description: "true to import the valid rows of a file with invalid ones"},
+			},
+			Request: importRequest{}, RequestType: "multipart/form-data",
+			Response: openapi.Envelope(operations.Operation{}), Status: http.StatusAccepted,
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType},
+		})
+	}
+	return ops
+}
@@ -0,0 +1,154 @@
+package imports
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/rbac"
+)
+
+var errTooLarge = apperror.PayloadTooLarge("file too large")
+
+// Register mounts one route per resource on g:
+//
+//	POST /:resource   start importing the file in the body, for subjects
+//	                  holding the resource's permission
+//
+// The file is the body, or the "file" part of a multipart form. Its format
+// is ?format=, or follows from its content type or file name. ?dry_run=true
+// only validates it; ?skip_invalid=true imports the valid rows of a file
+// with invalid ones. The request is answered with the operation, which
+// g must run in a transaction for it to start.
+func (im *Importer) Register(g *gin.RouterGroup) {
+	g.Use(auth.Required())
+	for _, r := range im.resources {
+		g.POST("/"+r.name, rbac.Require(r.perm), im.handle(r))
+	}
+}
+
+func (im *Importer) handle(r *Resource) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dryRun, err := boolQuery(c, "dry_run")
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		skipInvalid, err := boolQuery(c, "skip_invalid")
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		data, format, err := im.read(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		claims, _ := auth.ClaimsFrom(c)
+		fileID, err := im.save(c.Request.Context(), claims.Subject, r, format, data)
+		if err != nil {
+			c.Error(apperror.Internal(err))
+			return
+		}
+		im.ops.Start(c, Kind, input{File: fileID, Resource: r.name, Format: format, DryRun: dryRun, SkipInvalid: skipInvalid})
+	}
+}
+
+// read returns the file of the request and its format.
+func (im *Importer) read(c *gin.Context) ([]byte, string, error) {
+	maxBytes := im.live.Load().Imports.MaxBytes
+	var (
+		body        io.Reader = c.Request.Body
+		contentType           = c.ContentType()
+		name        string
+	)
+	if strings.HasPrefix(contentType, "multipart/") {
+		mr, err := c.Request.MultipartReader()
+		if err != nil {
+			return nil, "", apperror.BadRequest("malformed multipart body")
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil, "", apperror.BadRequest("file is required")
+			}
+			if err != nil {
+				return nil, "", bodyError(err)
+			}
+			if part.FormName() == "file" {
+				body, name = part, part.FileName()
+				contentType, _, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+				break
+			}
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, "", bodyError(err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", errTooLarge
+	}
+	if len(data) == 0 {
+		return nil, "", apperror.BadRequest("file is empty")
+	}
+	format, err := formatOf(c.Query("format"), contentType, name)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, format, nil
+}
+
+// formatOf picks the format of a file: the one asked for, else the one of
+// its content type, else the one of its file name extension.
+func formatOf(asked, contentType, name string) (string, error) {
+	switch asked {
+	case CSV, JSONL:
+		return asked, nil
+	case "":
+	default:
+		return "", apperror.BadRequest("format must be csv or jsonl")
+	}
+	switch contentType {
+	case "text/csv":
+		return CSV, nil
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return JSONL, nil
+	}
+	switch path.Ext(name) {
+	case ".csv":
+		return CSV, nil
+	case ".jsonl", ".ndjson":
+		return JSONL, nil
+	}
+	return "", apperror.Newf(apperror.KindUnsupportedMediaType, "file type %s not allowed", contentType)
+}
+
+func boolQuery(c *gin.Context, key string) (bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, apperror.Newf(apperror.KindBadRequest, "%s must be true or false", key)
+	}
+	return v, nil
+}
+
+// bodyError maps a failed body read to 413 when the body limit cut it off.
+func bodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return errTooLarge
+	}
+	return apperror.BadRequest("malformed multipart body")
+}
@@ -0,0 +1,423 @@
+// Package imports loads CSV and JSONL files into resources. A file is
+// uploaded with the request and stored, and the import runs as an
+// operation, which clients poll for its progress and, once done, for the
+// rows it imported and the errors of the others.
+//
+// An import first validates every row against the resource's schema, as a
+// request creating it would, and writes nothing if any row is invalid,
+// unless asked to skip invalid rows; a dry run stops there, reporting the
+// errors. Valid rows are then written in batches, each in a transaction
+// that also records how far the import got, so that an import whose job
+// is retried, such as after a restart, continues after the last batch
+// written instead of writing its rows twice.
+package imports
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/operations"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+	"go-flylike-example/internal/validation"
+)
+
+// Kind is the operation kind imports run as.
+const Kind = "import"
+
+// File formats.
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+// Import phases, as reported in Progress.
+const (
+	PhaseValidating = "validating"
+	PhaseImporting  = "importing"
+)
+
+// batchSize is how many rows are written per transaction.
+const batchSize = 100
+
+// progressInterval is how often validation reports its progress.
+const progressInterval = time.Second
+
+// ErrFileNotFound fails imports whose file is gone, such as one removed by
+// the cleanup after its operation was abandoned.
+var ErrFileNotFound = apperror.Gone("import file not found")
+
+// RowError is what is wrong with a row. Line is the line of the file the
+// row ends on.
+type RowError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Progress is how far an import got.
+type Progress struct {
+	Phase string `json:"phase"`
+	// Rows is how many rows were read in the phase, of Total once known.
+	Rows     int `json:"rows"`
+	Total    int `json:"total,omitempty"`
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
+	// Line is the last line written, where a retried import continues.
+	Line int `json:"line,omitempty"`
+}
+
+// Result is the outcome of an import.
+type Result struct {
+	Resource string `json:"resource"`
+	DryRun   bool   `json:"dry_run"`
+	Rows     int    `json:"rows"`
+	Invalid  int    `json:"invalid"`
+	// Imported rows were written; Failed rows were valid but refused when
+	// written, such as for a duplicate email.
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
+	// Errors lists the first errors found, of Invalid plus Failed.
+	Errors    []RowError `json:"errors,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// Resource is a resource rows can be imported into.
+type Resource struct {
+	name    string
+	perm    string
+	columns []string
+	// decode turns the fields of a row into a valid value to insert.
+	decode func(fields map[string]any) (any, []validation.FieldError, error)
+	insert func(ctx context.Context, v any) error
+}
+
+// Table returns the resource name, imported by subjects holding perm. Its
+// rows decode as T, by the JSON names of its fields, are validated by its
+// binding tags and are written with insert.
+func Table[T any](name, perm string, insert func(ctx context.Context, row *T) error) *Resource {
+	r := &Resource{name: name, perm: perm}
+	t := reflect.TypeFor[T]()
+	for f := range t.Fields() {
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); f.IsExported() && tag != "-" {
+			r.columns = append(r.columns, cmp.Or(tag, f.Name))
+		}
+	}
+	r.decode = func(fields map[string]any) (any, []validation.FieldError, error) {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		row := new(T)
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(row); err != nil {
+			return nil, nil, err
+		}
+		return row, validation.Struct(row), nil
+	}
+	r.insert = func(ctx context.Context, v any) error { return insert(ctx, v.(*T)) }
+	return r
+}
+
+// input is what an import operation is started with.
+type input struct {
+	File        string `json:"file"`
+	Resource    string `json:"resource"`
+	Format      string `json:"format"`
+	DryRun      bool   `json:"dry_run"`
+	SkipInvalid bool   `json:"skip_invalid"`
+}
+
+// Importer stores uploaded files and runs their imports.
+type Importer struct {
+	db        *store.Store
+	ops       *operations.Service
+	live      *config.Live
+	resources []*Resource
+}
+
+// New returns an Importer into resources and registers the operation kind
+// running its imports with ops.
+func New(db *store.Store, ops *operations.Service, live *config.Live, resources ...*Resource) *Importer {
+	im := &Importer{db: db, ops: ops, live: live, resources: resources}
+	ops.Handle(Kind, im.run)
+	return im
+}
+
+func (im *Importer) resource(name string) *Resource {
+	i := slices.IndexFunc(im.resources, func(r *Resource) bool { return r.name == name })
+	if i < 0 {
+		return nil
+	}
+	return im.resources[i]
+}
+
+// save stores the file of an import of r for owner and returns its id.
+func (im *Importer) save(ctx context.Context, owner string, r *Resource, format string, data []byte) (string, error) {
+	fileID := id.New()
+	if _, err := im.db.Writer(ctx).ExecContext(ctx, im.db.Rebind(
+		`INSERT INTO import_files (id, owner, tenant_id, resource, format, data, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		fileID, owner, tenant.ID(ctx), r.name, format, string(data), time.Now().Unix()); err != nil {
+		return "", fmt.Errorf("imports: save: %w", err)
+	}
+	return fileID, nil
+}
+
+func (im *Importer) load(ctx context.Context, fileID string) ([]byte, error) {
+	var data string
+	err := im.db.Writer(ctx).QueryRowContext(ctx, im.db.Rebind(
+		`SELECT data FROM import_files WHERE id = ?`), fileID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imports: load %s: %w", fileID, err)
+	}
+	return []byte(data), nil
+}
+
+// run is the operations.Func of Kind.
+func (im *Importer) run(ctx context.Context, op *operations.Operation) (any, error) {
+	var in input
+	if err := op.Decode(&in); err != nil {
+		return nil, err
+	}
+	r := im.resource(in.Resource)
+	if r == nil {
+		return nil, apperror.Newf(apperror.KindBadRequest, "unknown import resource %s", in.Resource)
+	}
+	data, err := im.load(ctx, in.File)
+	if err != nil {
+		return nil, err
+	}
+	maxErrors := im.live.Load().Imports.MaxErrors
+	res := &Result{Resource: r.name, DryRun: in.DryRun}
+	addError := func(e RowError) {
+		if len(res.Errors) < maxErrors {
+			res.Errors = append(res.Errors, e)
+		} else {
+			res.Truncated = true
+		}
+	}
+
+	// A retried import that got to writing validated the file already.
+	var prev Progress
+	if op.Progress != nil {
+		_ = json.Unmarshal(op.Progress, &prev)
+	}
+	invalid := map[int]bool{}
+	progress := Progress{Phase: PhaseValidating}
+	reported := time.Now()
+	for rec, err := range records(in.Format, data, r.columns) {
+		if err != nil {
+			return nil, err
+		}
+		res.Rows++
+		progress.Rows++
+		if rec.problem != "" {
+			invalid[rec.line] = true
+			addError(RowError{Line: rec.line, Message: rec.problem})
+		} else if _, ferrs, err := r.decode(rec.fields); err != nil {
+			invalid[rec.line] = true
+			addError(RowError{Line: rec.line, Message: "row could not be decoded"})
+		} else if len(ferrs) > 0 {
+			invalid[rec.line] = true
+			for _, fe := range ferrs {
+				addError(RowError{Line: rec.line, Field: fe.Field, Message: fe.Message})
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if prev.Phase != PhaseImporting && time.Since(reported) >= progressInterval {
+			if err := im.ops.Progress(ctx, op, progress); err != nil {
+				return nil, err
+			}
+			reported = time.Now()
+		}
+	}
+	res.Invalid = len(invalid)
+	if in.DryRun || (res.Invalid > 0 && !in.SkipInvalid) {
+		progress.Total = res.Rows
+		if err := im.done(ctx, op, in, &progress); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	progress = Progress{Phase: PhaseImporting, Total: res.Rows - res.Invalid}
+	if prev.Phase == PhaseImporting {
+		progress = prev
+	}
+	var batch []record
+	flush := func() error {
+		err := im.db.InTx(ctx, func(ctx context.Context) error {
+			for _, rec := range batch {
+				v, _, err := r.decode(rec.fields)
+				if err != nil {
+					return err
+				}
+				err = r.insert(ctx, v)
+				var appErr *apperror.Error
+				if errors.As(err, &appErr) && appErr.Kind != apperror.KindInternal {
+					progress.Failed++
+					addError(RowError{Line: rec.line, Message: appErr.Message})
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				progress.Imported++
+			}
+			progress.Rows += len(batch)
+			progress.Line = batch[len(batch)-1].line
+			return im.ops.Progress(ctx, op, progress)
+		})
+		batch = batch[:0]
+		return err
+	}
+	for rec, err := range records(in.Format, data, r.columns) {
+		if err != nil {
+			return nil, err
+		}
+		if invalid[rec.line] || rec.line <= progress.Line {
+			continue
+		}
+		batch = append(batch, rec)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	res.Imported, res.Failed = progress.Imported, progress.Failed
+	if err := im.done(ctx, op, in, &progress); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("import done", "resource", r.name, "rows", res.Rows,
+		"imported", res.Imported, "invalid", res.Invalid, "failed", res.Failed)
+	return res, nil
+}
+
+// done records the final progress and removes the file, which the result
+// of the operation replaces.
+func (im *Importer) done(ctx context.Context, op *operations.Operation, in input, p *Progress) error {
+	if err := im.ops.Progress(ctx, op, p); err != nil {
+		return err
+	}
+	if _, err := im.db.Writer(ctx).ExecContext(ctx, im.db.Rebind(`DELETE FROM import_files WHERE id = ?`), in.File); err != nil {
+		return fmt.Errorf("imports: remove %s: %w", in.File, err)
+	}
+	return nil
+}
+
+// record is a row of a file. problem says what is wrong with a row that
+// could not be read.
+type record struct {
+	line    int
+	fields  map[string]any
+	problem string
+}
+
+// records yields the rows of data. A CSV file starts with a header naming
+// the columns of its rows, which must be among columns; its values are
+// strings. A JSONL file holds a JSON object per line. An error is returned
+// for files that cannot be read at all.
+func records(format string, data []byte, columns []string) iter.Seq2[record, error] {
+	if format == CSV {
+		return csvRecords(data, columns)
+	}
+	return jsonlRecords(data)
+}
+
+func csvRecords(data []byte, columns []string) iter.Seq2[record, error] {
+	return func(yield func(record, error) bool) {
+		cr := csv.NewReader(bytes.NewReader(data))
+		cr.ReuseRecord = true
+		header, err := cr.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			yield(record{}, apperror.BadRequest("the csv header could not be read"))
+			return
+		}
+		header = slices.Clone(header)
+		for i, name := range header {
+			name = strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))
+			if !slices.Contains(columns, name) {
+				yield(record{}, apperror.Newf(apperror.KindBadRequest, "unknown column %s", name))
+				return
+			}
+			header[i] = name
+		}
+		for {
+			values, err := cr.Read()
+			if err == io.EOF {
+				return
+			}
+			line, _ := cr.FieldPos(0)
+			rec := record{line: line}
+			var perr *csv.ParseError
+			switch {
+			case errors.As(err, &perr) && errors.Is(perr.Err, csv.ErrFieldCount):
+				rec.line = perr.Line
+				rec.problem = fmt.Sprintf("row has %d fields but the header has %d", len(values), len(header))
+			case err != nil:
+				// The rest of the file cannot be split into rows.
+				yield(record{}, apperror.Newf(apperror.KindBadRequest, "the csv file is malformed: %v", err))
+				return
+			default:
+				rec.fields = make(map[string]any, len(header))
+				for i, name := range header {
+					rec.fields[name] = values[i]
+				}
+			}
+			if !yield(rec, nil) {
+				return
+			}
+		}
+	}
+}
+
+func jsonlRecords(data []byte) iter.Seq2[record, error] {
+	return func(yield func(record, error) bool) {
+		line := 0
+		for raw := range bytes.Lines(data) {
+			line++
+			raw = bytes.TrimSpace(raw)
+			if len(raw) == 0 {
+				continue
+			}
+			rec := record{line: line}
+			if err := json.Unmarshal(raw, &rec.fields); err != nil || rec.fields == nil {
+				rec.fields, rec.problem = nil, "line is not a JSON object"
+			}
+			if !yield(rec, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package imports
+
+import (
+	"context"
+
+	"go-flylike-example/internal/users"
+)
+
+// PermUsers guards imports of users.
+const PermUsers = "users:import"
+
+// userRow is a row of a users import, validated as a request creating the
+// user is.
+type userRow struct {
+	Name  string `json:"name" binding:"required,notblank,max=100"`
+	Email string `json:"email" binding:"required,email,max=254"`
+}
+
+// Users is the import of users into the tenant. Rows whose email is
+// already registered fail.
+func Users(repo *users.Repository) *Resource {
+	return Table("users", PermUsers, func(ctx context.Context, row *userRow) error {
+		_, err := repo.Create(ctx, row.Name, row.Email)
+		return err
+	})
+}
@@ -0,0 +1,146 @@
+// Package ipfilter admits or refuses requests by client address, against
+// allow and deny lists of CIDR ranges that can be replaced at runtime.
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+)
+
+// HeaderFlyClientIP carries the client address set by the Fly proxy.
+const HeaderFlyClientIP = "Fly-Client-IP"
+
+// ErrForbidden is returned for addresses the lists refuse.
+var ErrForbidden = apperror.Forbidden("client address not allowed")
+
+// Filter applies the current lists to every request.
+type Filter struct {
+	rules atomic.Pointer[rules]
+}
+
+type rules struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// New compiles the lists; entries are CIDR ranges or single addresses.
+// Deny wins over allow, and an empty allow list admits every address not
+// denied.
+func New(allow, deny []string) (*Filter, error) {
+	f := &Filter{}
+	if err := f.Set(allow, deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Set replaces the lists; requests in flight keep the old ones.
+func (f *Filter) Set(allow, deny []string) error {
+	var (
+		r   rules
+		err error
+	)
+	if r.allow, err = ParsePrefixes(allow); err != nil {
+		return err
+	}
+	if r.deny, err = ParsePrefixes(deny); err != nil {
+		return err
+	}
+	f.rules.Store(&r)
+	return nil
+}
+
+// ParsePrefixes parses CIDR ranges, turning single addresses into one-address
+// ranges.
+func ParsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if strings.Contains(s, "/") {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, fmt.Errorf("ipfilter: %w", err)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("ipfilter: %w", err)
+		}
+		a = a.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(a, a.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Allowed reports whether the lists admit addr.
+func (f *Filter) Allowed(addr netip.Addr) bool {
+	r := f.rules.Load()
+	return r.allowed(addr.Unmap())
+}
+
+func (r *rules) allowed(addr netip.Addr) bool {
+	if !addr.IsValid() || contains(r.deny, addr) {
+		return false
+	}
+	return len(r.allow) == 0 || contains(r.allow, addr)
+}
+
+// TrustProxies makes r resolve c.ClientIP from Fly-Client-IP, or else from
+// X-Forwarded-For walked from the right past trusted proxies, but only for
+// requests whose peer is in trusted. Gin otherwise trusts every peer, so
+// any client could name its own address. It must be called before r
+// serves requests.
+func TrustProxies(r *gin.Engine, trusted []string) error {
+	r.ForwardedByClientIP = true
+	r.RemoteIPHeaders = []string{HeaderFlyClientIP, "X-Forwarded-For"}
+	if len(trusted) == 0 {
+		trusted = nil // gin trusts no proxy for nil only
+	}
+	if err := r.SetTrustedProxies(trusted); err != nil {
+		return fmt.Errorf("ipfilter: %w", err)
+	}
+	return nil
+}
+
+// Middleware refuses requests from addresses the lists do not admit with
+// 403. The address is c.ClientIP, as configured by TrustProxies.
+func (f *Filter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := f.rules.Load()
+		if len(r.allow) == 0 && len(r.deny) == 0 {
+			c.Next()
+			return
+		}
+		if !r.allowed(parseAddr(c.ClientIP())) {
+			c.Error(ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func parseAddr(s string) netip.Addr {
+	a, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return a.Unmap()
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
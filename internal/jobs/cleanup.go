@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-flylike-example/internal/store"
+)
+
+// KindCleanup purges expired refresh tokens, account tokens and
+// idempotency records, and finished jobs, delivered outbox events and
+// finished operations.
+const KindCleanup = "cleanup"
+
+// CleanupRetention is how long finished jobs, delivered outbox events and
+// finished operations are kept for inspection.
+const CleanupRetention = 7 * 24 * time.Hour
+
+// CleanupHandler returns the handler for KindCleanup.
+func CleanupHandler(db *store.Store) Handler {
+	return func(ctx context.Context, _ *Job) error {
+		now := time.Now()
+		res, err := db.Writer(ctx).ExecContext(ctx, db.Rebind(
+			`DELETE FROM refresh_tokens WHERE expires_at < ?`), now.Unix())
+		if err != nil {
+			return fmt.Errorf("cleanup refresh tokens: %w", err)
+		}
+		tokens, _ := res.RowsAffected()
+
+		// Used verification and reset tokens are kept until they expire too.
+		res, err = db.Writer(ctx).ExecContext(ctx, db.Rebind(
+			`DELETE FROM user_tokens WHERE expires_at < ?`), now.Unix())
+		if err != nil {
+			return fmt.Errorf("cleanup user tokens: %w", err)
+		}
+		userTokens, _ := res.RowsAffected()
+
+		res, err = db.Writer(ctx).ExecContext(ctx, db.Rebind(
+			`DELETE FROM idempotency_keys WHERE expires_at < ?`), now.Unix())
+		if err != nil {
+			return fmt.Errorf("cleanup idempotency keys: %w", err)
+		}
+		keys, _ := res.RowsAffected()
+
+		res, err = db.Writer(ctx).ExecContext(ctx, db.Rebind(
+			`DELETE FROM jobs WHERE state IN (?, ?) AND updated_at < ?`),
+			StateDone, StateFailed, now.Add(-CleanupRetention).Unix())
+		if err != nil {
+			return fmt.Errorf("cleanup jobs: %w", err)
+		}
+		done, _ := res.RowsAffected()
+
+		res, err = db.Writer(ctx).ExecContext(ctx, db.Rebind(
+			`DELETE FROM outbox WHERE delivered_at < ?`), now.Add(-CleanupRetention).Unix())
+		if err != nil {
+			return fmt.Errorf("cleanup outbox: %w", err)
+		}
+		events, _ := res.RowsAffected()
+
+		// Operations are kept for clients still to poll their outcome.
+		res, err = db.Writer(ctx).ExecContext(ctx, db.Rebind(
+			`DELETE FROM operations WHERE state IN ('succeeded', 'failed') AND updated_at < ?`),
+			now.Add(-CleanupRetention).Unix())
+		if err != nil {
+			return fmt.Errorf("cleanup operations: %w", err)
+		}
+		ops, _ := res.RowsAffected()
+
+		// Import files are removed when their import is done; these are
+		// left over from imports that failed.
+		res, err = db.Writer(ctx).ExecContext(ctx, db.Rebind(
+			`DELETE FROM import_files WHERE created_at < ?`), now.Add(-CleanupRetention).Unix())
+		if err != nil {
+			return fmt.Errorf("cleanup import files: %w", err)
+		}
+		imports, _ := res.RowsAffected()
+
+		slog.InfoContext(ctx, "cleanup finished", "refresh_tokens", tokens, "user_tokens", userTokens, "idempotency_keys", keys, "jobs", done, "outbox", events, "operations", ops, "import_files", imports)
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+// Package jobs runs background tasks from a queue persisted in the
+// database, so enqueued work survives restarts. A pool of workers claims
+// due jobs, retries failures with exponential backoff and drains in-flight
+// jobs on shutdown.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand/v2"
+	"time"
+)
+
+// Job states as stored in the jobs table.
+const (
+	StatePending = "pending"
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed" // attempts exhausted; kept for inspection
+)
+
+// Job is a unit of background work.
+type Job struct {
+	ID          string
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int // including the current one
+	MaxAttempts int
+	RunAt       time.Time
+}
+
+// Decode unmarshals the payload into v.
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler processes one job. Returning an error schedules a retry until the
+// job runs out of attempts.
+type Handler func(ctx context.Context, job *Job) error
+
+// Enqueuer is what producers depend on to schedule work.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, kind string, payload any, opts ...Option) (string, error)
+}
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+}
+
+// Option customises a single Enqueue call.
+type Option func(*enqueueOptions)
+
+// WithDelay runs the job no earlier than d from now.
+func WithDelay(d time.Duration) Option {
+	return func(o *enqueueOptions) { o.runAt = time.Now().Add(d) }
+}
+
+// WithRunAt runs the job no earlier than t.
+func WithRunAt(t time.Time) Option {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// WithMaxAttempts overrides the configured attempt limit.
+func WithMaxAttempts(n int) Option {
+	return func(o *enqueueOptions) { o.maxAttempts = n }
+}
+
+// Backoff returns the delay before retry number attempt (1-based):
+// 1s, 2s, 4s, ... capped at one hour, with up to 20% random jitter so
+// failing jobs do not retry in lockstep.
+func Backoff(attempt int) time.Duration {
+	d := time.Second << min(attempt-1, 12)
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d + time.Duration(mrand.Int64N(int64(d)/5+1))
+}
+
+func encode(payload any) (string, error) {
+	if raw, ok := payload.(json.RawMessage); ok {
+		return string(raw), nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("jobs: encode payload: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,310 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/journal"
+	"go-flylike-example/internal/store"
+)
+
+// Queue stores jobs in the database and runs them on a worker pool.
+type Queue struct {
+	db  *store.Store
+	cfg config.Jobs
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// New returns a Queue; call Start to begin processing.
+func New(db *store.Store, cfg config.Jobs) *Queue {
+	return &Queue{
+		db:       db,
+		cfg:      cfg,
+		handlers: make(map[string]Handler),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Register sets the handler for jobs of kind. Jobs whose kind has no
+// handler on this instance are left for other instances.
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Enqueue implements Enqueuer.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any, opts ...Option) (string, error) {
+	o := enqueueOptions{runAt: time.Now(), maxAttempts: q.cfg.MaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	data, err := encode(payload)
+	if err != nil {
+		return "", err
+	}
+
+	jobID := id.New()
+	now := time.Now().Unix()
+	_, err = q.db.Writer(ctx).ExecContext(ctx, q.db.Rebind(
+		`INSERT INTO jobs (id, kind, payload, state, attempts, max_attempts, run_at, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)`),
+		jobID, kind, data, StatePending, o.maxAttempts, o.runAt.Unix(), now, now)
+	if err != nil {
+		return "", fmt.Errorf("jobs: enqueue %s: %w", kind, err)
+	}
+	return jobID, nil
+}
+
+// Run runs a job of kind with payload once, in the calling goroutine and
+// within the lease, without storing it, for one-off runs from the command
+// line. Its error is returned rather than retried.
+func (q *Queue) Run(ctx context.Context, kind string, payload json.RawMessage) error {
+	q.mu.RLock()
+	h := q.handlers[kind]
+	kinds := slices.Sorted(maps.Keys(q.handlers))
+	q.mu.RUnlock()
+	if h == nil {
+		return fmt.Errorf("jobs: no handler for %q, known kinds: %s", kind, strings.Join(kinds, ", "))
+	}
+	jobCtx, cancel := context.WithTimeout(ctx, q.cfg.Lease)
+	defer cancel()
+	job := &Job{ID: id.New(), Kind: kind, Payload: payload, Attempts: 1, MaxAttempts: 1, RunAt: time.Now()}
+	return safeRun(jobCtx, h, job)
+}
+
+// Start launches the configured number of workers. A concurrency of zero
+// leaves this instance as a producer only.
+func (q *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	for i := 0; i < q.cfg.Concurrency; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+	slog.Info("job workers started", "concurrency", q.cfg.Concurrency)
+}
+
+// Shutdown stops claiming new jobs and waits for running ones to finish.
+// If ctx expires first the remaining jobs are cancelled and released back
+// to the queue.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	finished := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		if q.cancel != nil {
+			q.cancel()
+		}
+		<-finished
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) worker(ctx context.Context) {
+	defer q.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-timer.C:
+		}
+
+		job, err := q.claim(ctx)
+		switch {
+		case err != nil:
+			slog.Error("job claim failed", "error", err)
+			timer.Reset(q.cfg.PollInterval)
+		case job == nil:
+			timer.Reset(q.cfg.PollInterval)
+		default:
+			q.run(ctx, job)
+			// More work is likely queued; look again immediately.
+			timer.Reset(0)
+		}
+	}
+}
+
+// claim atomically reserves the oldest due job, including jobs whose lease
+// expired because their worker died.
+func (q *Queue) claim(ctx context.Context) (*Job, error) {
+	q.mu.RLock()
+	kinds := make([]any, 0, len(q.handlers))
+	for k := range q.handlers {
+		kinds = append(kinds, k)
+	}
+	q.mu.RUnlock()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	lock := ""
+	if q.db.Dialect() == store.Postgres {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+	query := `UPDATE jobs SET state = ?, attempts = attempts + 1, locked_until = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE ((state = ? AND run_at <= ?) OR (state = ? AND locked_until < ?))
+			  AND kind IN (` + placeholders(len(kinds)) + `)
+			ORDER BY run_at
+			LIMIT 1` + lock + `
+		)
+		RETURNING id, kind, payload, attempts, max_attempts, run_at`
+
+	args := []any{StateRunning, now.Add(q.cfg.Lease).Unix(), now.Unix(),
+		StatePending, now.Unix(), StateRunning, now.Unix()}
+	args = append(args, kinds...)
+
+	var (
+		job     Job
+		payload string
+		runAt   int64
+	)
+	err := q.db.Writer(ctx).QueryRowContext(ctx, q.db.Rebind(query), args...).
+		Scan(&job.ID, &job.Kind, &payload, &job.Attempts, &job.MaxAttempts, &runAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jobs: claim: %w", err)
+	}
+	job.Payload = []byte(payload)
+	job.RunAt = time.Unix(runAt, 0)
+	return &job, nil
+}
+
+func (q *Queue) run(ctx context.Context, job *Job) {
+	q.mu.RLock()
+	h := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	logger := slog.With("job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	done := journal.Track(journal.KindJob, job.ID, journaled{Kind: job.Kind, Attempt: job.Attempts})
+	defer done()
+	jobCtx, cancel := context.WithTimeout(ctx, q.cfg.Lease)
+	defer cancel()
+
+	start := time.Now()
+	err := safeRun(jobCtx, h, job)
+
+	// Bookkeeping must succeed even when ctx was cancelled by Shutdown.
+	bg := context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		logger.Info("job done", "duration", time.Since(start).String())
+		q.finish(bg, job, StateDone, "", 0)
+	case ctx.Err() != nil:
+		logger.Warn("job interrupted by shutdown, releasing", "error", err)
+		q.release(bg, job)
+	case job.Attempts >= job.MaxAttempts:
+		logger.Error("job failed permanently", "error", err)
+		q.finish(bg, job, StateFailed, err.Error(), 0)
+	default:
+		delay := Backoff(job.Attempts)
+		logger.Warn("job failed, retrying", "error", err, "retry_in", delay.String())
+		q.finish(bg, job, StatePending, err.Error(), delay)
+	}
+}
+
+func safeRun(ctx context.Context, h Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return h(ctx, job)
+}
+
+func (q *Queue) finish(ctx context.Context, job *Job, state, lastErr string, retryIn time.Duration) {
+	now := time.Now()
+	var errVal any
+	if lastErr != "" {
+		errVal = lastErr
+	}
+	_, err := q.db.Writer(ctx).ExecContext(ctx, q.db.Rebind(
+		`UPDATE jobs SET state = ?, locked_until = NULL, last_error = ?, run_at = ?, updated_at = ? WHERE id = ?`),
+		state, errVal, now.Add(retryIn).Unix(), now.Unix(), job.ID)
+	if err != nil {
+		slog.Error("job state update failed", "job_id", job.ID, "error", err)
+	}
+}
+
+// release hands an interrupted job back without consuming an attempt.
+func (q *Queue) release(ctx context.Context, job *Job) {
+	_, err := q.db.Writer(ctx).ExecContext(ctx, q.db.Rebind(
+		`UPDATE jobs SET state = ?, attempts = attempts - 1, locked_until = NULL, updated_at = ? WHERE id = ?`),
+		StatePending, time.Now().Unix(), job.ID)
+	if err != nil {
+		slog.Error("job release failed", "job_id", job.ID, "error", err)
+	}
+}
+
+// journaled is what the journal records of a running job.
+type journaled struct {
+	Kind    string `json:"kind"`
+	Attempt int    `json:"attempt"`
+}
+
+// Recover hands back the jobs a previous run of the instance left running,
+// like release: they are due again right away instead of once their lease
+// ran out. Jobs that finished since, or were claimed again, are left as
+// they are.
+func (q *Queue) Recover(ctx context.Context, entries []journal.Entry) error {
+	for _, e := range entries {
+		var j journaled
+		if err := json.Unmarshal(e.Detail, &j); err != nil {
+			return fmt.Errorf("jobs: recover %s: %w", e.ID, err)
+		}
+		now := time.Now().Unix()
+		_, err := q.db.Writer(ctx).ExecContext(ctx, q.db.Rebind(
+			`UPDATE jobs SET state = ?, attempts = attempts - 1, locked_until = NULL, run_at = ?, updated_at = ?
+			 WHERE id = ? AND state = ? AND attempts = ?`),
+			StatePending, now, now, e.ID, StateRunning, j.Attempt)
+		if err != nil {
+			return fmt.Errorf("jobs: recover %s: %w", e.ID, err)
+		}
+	}
+	return nil
+}
+
+func placeholders(n int) string {
+	b := make([]byte, 0, n*2)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, '?')
+	}
+	return string(b)
+}
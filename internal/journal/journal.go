@@ -0,0 +1,332 @@
+// Package journal lets the instance recover from crashes by recording the
+// work it has in flight: running jobs, open transactions and events not
+// yet handled. The parts doing that work Track it; a Journal writes what
+// is tracked to disk or object storage every few seconds while it changes,
+// and once more as the shutdown report, when the process stops or its main
+// goroutine panics. On the next start Start reads the last journal of the
+// instance and hands each entry to the recoverer of its kind, such as
+// requeueing a job, before the workers start.
+//
+// A journal written while the process is killed, or runs out of memory,
+// is at most one interval old, so recovery is at least once: recoverers
+// must tolerate entries whose work had finished since, which they do by
+// checking the state the entry names before changing it.
+package journal
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-flylike-example/internal/buildinfo"
+	"go-flylike-example/internal/config"
+)
+
+// Kinds of the work the server tracks.
+const (
+	KindJob         = "job"
+	KindTransaction = "transaction"
+	KindEvent       = "event"
+)
+
+// Entry is one piece of work in flight. Detail is what its recoverer
+// needs, as JSON.
+type Entry struct {
+	Kind   string          `json:"kind"`
+	ID     string          `json:"id"`
+	Since  time.Time       `json:"since"`
+	Detail json.RawMessage `json:"detail,omitempty"`
+}
+
+// Recoverer hands back the entries of one kind that a previous run left.
+type Recoverer func(ctx context.Context, entries []Entry) error
+
+// Reasons a report was written for.
+const (
+	ReasonCheckpoint = "checkpoint"
+	ReasonShutdown   = "shutdown"
+	ReasonPanic      = "panic"
+)
+
+// Report is a written journal. A checkpoint found on startup means the
+// previous run ended without writing its shutdown report.
+type Report struct {
+	Reason   string         `json:"reason"`
+	Error    string         `json:"error,omitempty"`
+	Stack    string         `json:"stack,omitempty"`
+	Instance string         `json:"instance"`
+	Version  string         `json:"version"`
+	Started  time.Time      `json:"started"`
+	Written  time.Time      `json:"written"`
+	Counts   map[string]int `json:"counts"`
+	Entries  []Entry        `json:"entries"`
+}
+
+var (
+	tracking atomic.Bool
+	version  atomic.Uint64
+
+	mu      sync.Mutex
+	next    uint64
+	entries = map[uint64]Entry{}
+)
+
+// Track records work of kind until done is called, which may be called
+// more than once. detail is encoded right away; it is dropped if it cannot
+// be. Until a Journal started, nothing is recorded.
+func Track(kind, id string, detail any) (done func()) {
+	if !tracking.Load() {
+		return func() {}
+	}
+	e := Entry{Kind: kind, ID: id, Since: time.Now().UTC()}
+	if detail != nil {
+		e.Detail, _ = json.Marshal(detail)
+	}
+	mu.Lock()
+	next++
+	key := next
+	entries[key] = e
+	mu.Unlock()
+	version.Add(1)
+	return func() {
+		mu.Lock()
+		_, ok := entries[key]
+		delete(entries, key)
+		mu.Unlock()
+		if ok {
+			version.Add(1)
+		}
+	}
+}
+
+// Snapshot returns the work in flight, oldest first.
+func Snapshot() []Entry {
+	mu.Lock()
+	s := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		s = append(s, e)
+	}
+	mu.Unlock()
+	slices.SortFunc(s, func(a, b Entry) int {
+		return cmp.Or(a.Since.Compare(b.Since), cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.ID, b.ID))
+	})
+	return s
+}
+
+// Journal writes the reports of this instance.
+type Journal struct {
+	store    storage
+	dir      string
+	interval time.Duration
+	logger   *slog.Logger
+	name     string
+	instance string
+	started  time.Time
+
+	// mu serialises writes, so that a checkpoint never overwrites the
+	// shutdown report.
+	mu      sync.Mutex
+	written uint64
+	final   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns the journal of cfg, kept in the bucket of the uploads object
+// storage when cfg names one.
+func New(cfg config.Journal, uploads config.Uploads, logger *slog.Logger) (*Journal, error) {
+	instance := os.Getenv("FLY_MACHINE_ID")
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	if instance == "" {
+		instance = "local"
+	}
+	j := &Journal{
+		store:    dirStore(cfg.Dir),
+		dir:      cfg.Dir,
+		interval: cfg.Interval,
+		logger:   logger,
+		name:     instance + ".json",
+		instance: instance,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if cfg.Bucket != "" {
+		s, err := newBucketStore(uploads, cfg.Bucket)
+		if err != nil {
+			return nil, err
+		}
+		j.store = s
+	}
+	return j, nil
+}
+
+// Start recovers what the last journal of the instance names, through the
+// recoverer of each kind, then starts tracking and checkpointing. Entries
+// of kinds without a recoverer are logged as lost. Recovery failures are
+// logged too rather than stopping the start, which would only repeat.
+func (j *Journal) Start(ctx context.Context, recoverers map[string]Recoverer) error {
+	j.crashOutput()
+	b, err := j.store.get(ctx, j.name)
+	if err != nil {
+		return fmt.Errorf("journal: read: %w", err)
+	}
+	if b != nil {
+		j.recover(ctx, b, recoverers)
+		if err := j.store.remove(ctx, j.name); err != nil {
+			return fmt.Errorf("journal: remove recovered report: %w", err)
+		}
+	}
+	j.started = time.Now().UTC()
+	tracking.Store(true)
+	go j.run()
+	return nil
+}
+
+func (j *Journal) recover(ctx context.Context, b []byte, recoverers map[string]Recoverer) {
+	var r Report
+	if err := json.Unmarshal(b, &r); err != nil {
+		j.logger.Error("journal unreadable, nothing recovered", "error", err)
+		return
+	}
+	attrs := []any{"reason", r.Reason, "version", r.Version, "written", r.Written, "entries", len(r.Entries)}
+	switch r.Reason {
+	case ReasonShutdown:
+		j.logger.Info("previous run shut down", attrs...)
+	case ReasonPanic:
+		j.logger.Error("previous run panicked", append(attrs, "error", r.Error)...)
+	default:
+		j.logger.Error("previous run ended without shutting down", attrs...)
+	}
+
+	byKind := map[string][]Entry{}
+	for _, e := range r.Entries {
+		byKind[e.Kind] = append(byKind[e.Kind], e)
+	}
+	for _, kind := range slices.Sorted(maps.Keys(byKind)) {
+		es := byKind[kind]
+		rec, ok := recoverers[kind]
+		if !ok {
+			for _, e := range es {
+				j.logger.Warn("in-flight work lost", "kind", e.Kind, "id", e.ID, "since", e.Since, "detail", string(e.Detail))
+			}
+			continue
+		}
+		if err := rec(ctx, es); err != nil {
+			j.logger.Error("in-flight work not recovered", "kind", kind, "entries", len(es), "error", err)
+			continue
+		}
+		j.logger.Info("in-flight work recovered", "kind", kind, "entries", len(es))
+	}
+}
+
+// crashOutput logs what the last fatal error of the process wrote to the
+// crash log in the journal directory, and sends those of this run there.
+func (j *Journal) crashOutput() {
+	path := filepath.Join(j.dir, "crash.log")
+	if prev, err := os.ReadFile(path); err == nil && len(prev) > 0 {
+		j.logger.Error("previous run crashed", "output", string(prev[:min(len(prev), 4<<10)]), "file", path)
+	}
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		j.logger.Warn("crash output not set", "error", err)
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		j.logger.Warn("crash output not set", "error", err)
+		return
+	}
+	defer f.Close()
+	if err := debug.SetCrashOutput(f, debug.CrashOptions{}); err != nil {
+		j.logger.Warn("crash output not set", "error", err)
+	}
+}
+
+// run writes a checkpoint every interval in which the work in flight
+// changed.
+func (j *Journal) run() {
+	defer close(j.done)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+		if version.Load() == j.written {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), j.interval)
+		if err := j.write(ctx, Report{Reason: ReasonCheckpoint}); err != nil {
+			j.logger.Warn("journal checkpoint failed", "error", err)
+		}
+		cancel()
+	}
+}
+
+// Stop stops checkpointing and writes the shutdown report, with what is
+// still in flight once the workers stopped.
+func (j *Journal) Stop(ctx context.Context) error {
+	close(j.stop)
+	<-j.done
+	r := Report{Reason: ReasonShutdown}
+	if err := j.write(ctx, r); err != nil {
+		return fmt.Errorf("journal: shutdown report: %w", err)
+	}
+	j.logger.Info("shutdown report written", "entries", len(Snapshot()))
+	return nil
+}
+
+// Panicking writes the panic report when the calling goroutine panics, and
+// panics on. Defer it at the top of main; panics of other goroutines end
+// the process before any journal is written, and are in the crash log.
+func (j *Journal) Panicking() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := j.write(ctx, Report{Reason: ReasonPanic, Error: fmt.Sprint(v), Stack: string(debug.Stack())}); err != nil {
+		j.logger.Error("panic report not written", "error", err)
+	}
+	cancel()
+	panic(v)
+}
+
+func (j *Journal) write(ctx context.Context, r Report) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.final {
+		return nil
+	}
+	v := version.Load()
+	r.Entries = Snapshot()
+	r.Counts = map[string]int{}
+	for _, e := range r.Entries {
+		r.Counts[e.Kind]++
+	}
+	r.Instance, r.Version, r.Started, r.Written = j.instance, buildinfo.Get().Version, j.started, time.Now().UTC()
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := j.store.put(ctx, j.name, append(b, '\n')); err != nil {
+		return err
+	}
+	j.written = v
+	j.final = r.Reason != ReasonCheckpoint
+	return nil
+}
@@ -0,0 +1,116 @@
+package journal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"go-flylike-example/internal/config"
+)
+
+// storage keeps reports by name. get returns nil for a missing report.
+type storage interface {
+	put(ctx context.Context, name string, b []byte) error
+	get(ctx context.Context, name string) ([]byte, error)
+	remove(ctx context.Context, name string) error
+}
+
+// dirStore keeps reports as files of a directory.
+type dirStore string
+
+// put replaces the report atomically, so that a crash while writing
+// leaves the previous one.
+func (d dirStore) put(_ context.Context, name string, b []byte) error {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(string(d), name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(string(d), name))
+}
+
+func (d dirStore) get(_ context.Context, name string) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(string(d), name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (d dirStore) remove(_ context.Context, name string) error {
+	if err := os.Remove(filepath.Join(string(d), name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// bucketStore keeps reports as objects under journal/ in a bucket.
+type bucketStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// newBucketStore connects to bucket at the endpoint of uploads, with its
+// credentials.
+func newBucketStore(uploads config.Uploads, bucket string) (*bucketStore, error) {
+	u, err := url.Parse(uploads.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("journal: invalid endpoint %q", uploads.Endpoint)
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(uploads.AccessKeyID, uploads.SecretAccessKey, ""),
+		Secure: u.Scheme != "http",
+		Region: uploads.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	return &bucketStore{client: client, bucket: bucket}, nil
+}
+
+func (s *bucketStore) put(ctx context.Context, name string, b []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, "journal/"+name, bytes.NewReader(b), int64(len(b)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	return err
+}
+
+func (s *bucketStore) get(ctx context.Context, name string) ([]byte, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, "journal/"+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	b, err := io.ReadAll(obj)
+	if resp := minio.ToErrorResponse(err); resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (s *bucketStore) remove(ctx context.Context, name string) error {
+	return s.client.RemoveObject(ctx, s.bucket, "journal/"+name, minio.RemoveObjectOptions{})
+}
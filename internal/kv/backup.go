@@ -0,0 +1,107 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.etcd.io/bbolt"
+
+	"go-flylike-example/internal/config"
+)
+
+// Snapshots are named by their time under kv/, so that they sort by it.
+const (
+	snapshotPrefix = "kv/"
+	snapshotLayout = "20060102T150405Z"
+)
+
+// backups keeps snapshots of the file as objects of a bucket.
+type backups struct {
+	client *minio.Client
+	bucket string
+	keep   int
+}
+
+// newBackups connects to bucket at the endpoint of uploads, with its
+// credentials.
+func newBackups(uploads config.Uploads, bucket string, keep int) (*backups, error) {
+	u, err := url.Parse(uploads.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("kv: invalid endpoint %q", uploads.Endpoint)
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(uploads.AccessKeyID, uploads.SecretAccessKey, ""),
+		Secure: u.Scheme != "http",
+		Region: uploads.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kv: %w", err)
+	}
+	return &backups{client: client, bucket: bucket, keep: keep}, nil
+}
+
+// save uploads a consistent copy of db, then removes the snapshots beyond
+// the last keep. It returns the name of the snapshot.
+func (b *backups) save(ctx context.Context, db *bbolt.DB) (string, error) {
+	tmp := db.Path() + ".snapshot"
+	defer os.Remove(tmp)
+	// A read transaction sees the file as of its start while writes go on.
+	if err := db.View(func(tx *bbolt.Tx) error { return tx.CopyFile(tmp, 0o600) }); err != nil {
+		return "", err
+	}
+	name := snapshotPrefix + time.Now().UTC().Format(snapshotLayout) + ".db"
+	if _, err := b.client.FPutObject(ctx, b.bucket, name, tmp, minio.PutObjectOptions{ContentType: "application/octet-stream"}); err != nil {
+		return "", err
+	}
+	names, err := b.list(ctx)
+	if err != nil {
+		return name, err
+	}
+	for _, old := range names[:max(len(names)-b.keep, 0)] {
+		if err := b.client.RemoveObject(ctx, b.bucket, old, minio.RemoveObjectOptions{}); err != nil {
+			return name, err
+		}
+	}
+	return name, nil
+}
+
+// restore writes the latest snapshot to path, and returns its name, empty
+// when there is none.
+func (b *backups) restore(ctx context.Context, path string) (string, error) {
+	names, err := b.list(ctx)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+	latest := names[len(names)-1]
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// Downloaded aside, so that a failed restore does not leave a partial
+	// file that would be opened as the store.
+	tmp := path + ".restore"
+	defer os.Remove(tmp)
+	if err := b.client.FGetObject(ctx, b.bucket, latest, tmp, minio.GetObjectOptions{}); err != nil {
+		return "", err
+	}
+	return latest, os.Rename(tmp, path)
+}
+
+// list returns the names of the snapshots, oldest first.
+func (b *backups) list(ctx context.Context) ([]string, error) {
+	var names []string
+	for obj := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: snapshotPrefix}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		names = append(names, obj.Key)
+	}
+	slices.Sort(names)
+	return names, nil
+}
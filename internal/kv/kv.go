@@ -0,0 +1,294 @@
+// Package kv is the embedded key-value store of single-node deployments:
+// one bbolt file holding, a bucket each, the state that the stores of
+// other packages keep in Redis when there is one, so that it survives
+// restarts without an external service. Values may expire; expired ones
+// are never returned and are swept every minute. With a backup bucket the
+// file is snapshotted to object storage, and restored from the latest
+// snapshot when it is missing at startup, such as on a new volume.
+//
+// bbolt locks the file, so only one process opens it at a time.
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"go-flylike-example/internal/config"
+)
+
+// ErrNotFound is returned for absent or expired keys.
+var ErrNotFound = errors.New("kv: not found")
+
+// sweepInterval is how often expired values are deleted.
+const sweepInterval = time.Minute
+
+// DB is an open store.
+type DB struct {
+	bolt     *bbolt.DB
+	logger   *slog.Logger
+	backups  *backups // nil without a backup bucket
+	interval time.Duration
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// Open opens the store of cfg, first restoring the latest snapshot when
+// the file is missing and cfg has a backup bucket of the uploads storage.
+func Open(ctx context.Context, cfg config.KV, uploads config.Uploads, logger *slog.Logger) (*DB, error) {
+	d := &DB{logger: logger, interval: cfg.BackupInterval}
+	if cfg.BackupBucket != "" {
+		b, err := newBackups(uploads, cfg.BackupBucket, cfg.BackupKeep)
+		if err != nil {
+			return nil, err
+		}
+		d.backups = b
+		if _, err := os.Stat(cfg.Path); errors.Is(err, fs.ErrNotExist) {
+			name, err := b.restore(ctx, cfg.Path)
+			if err != nil {
+				return nil, fmt.Errorf("kv: restore: %w", err)
+			}
+			if name != "" {
+				logger.Info("kv restored from snapshot", "snapshot", name)
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("kv: %w", err)
+	}
+	db, err := bbolt.Open(cfg.Path, 0o600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("kv: open %s: %w", cfg.Path, err)
+	}
+	d.bolt = db
+	return d, nil
+}
+
+// Start sweeps expired values and, with a backup bucket, saves snapshots
+// in the background until Shutdown.
+func (d *DB) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.stop = cancel
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.run(ctx)
+	}()
+}
+
+func (d *DB) run(ctx context.Context) {
+	sweep := time.NewTicker(sweepInterval)
+	defer sweep.Stop()
+	var backup <-chan time.Time
+	if d.backups != nil {
+		t := time.NewTicker(d.interval)
+		defer t.Stop()
+		backup = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sweep.C:
+			if err := d.sweep(time.Now()); err != nil {
+				d.logger.Warn("kv sweep failed", "error", err)
+			}
+		case <-backup:
+			d.backup(ctx)
+		}
+	}
+}
+
+// Shutdown stops the background work, saves a last snapshot when there is
+// a backup bucket and closes the file.
+func (d *DB) Shutdown(ctx context.Context) error {
+	if d.stop != nil {
+		d.stop()
+		d.wg.Wait()
+	}
+	if d.backups != nil {
+		d.backup(ctx)
+	}
+	return d.bolt.Close()
+}
+
+func (d *DB) backup(ctx context.Context) {
+	start := time.Now()
+	name, err := d.backups.save(ctx, d.bolt)
+	if err != nil {
+		d.logger.Error("kv snapshot failed", "error", err)
+		return
+	}
+	d.logger.Info("kv snapshot saved", "snapshot", name, "duration", time.Since(start).String())
+}
+
+// sweep deletes the values expired at now from every bucket.
+func (d *DB) sweep(now time.Time) error {
+	return d.bolt.Update(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(_ []byte, b *bbolt.Bucket) error {
+			var expired [][]byte
+			err := b.ForEach(func(k, v []byte) error {
+				if _, ok := decode(v, now); !ok {
+					expired = append(expired, k)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range expired {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Bucket returns the bucket name, which is created on the first write.
+func (d *DB) Bucket(name string) *Bucket {
+	return &Bucket{db: d.bolt, name: []byte(name)}
+}
+
+// Bucket is a namespace of keys.
+type Bucket struct {
+	db   *bbolt.DB
+	name []byte
+}
+
+// Get returns the value of key.
+func (b *Bucket) Get(key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bk := tx.Bucket(b.name)
+		if bk == nil {
+			return ErrNotFound
+		}
+		v, ok := decode(bk.Get([]byte(key)), time.Now())
+		if !ok {
+			return ErrNotFound
+		}
+		value = clone(v)
+		return nil
+	})
+	return value, err
+}
+
+// Extend returns the value of key and has it expire ttl from now.
+func (b *Bucket) Extend(key string, ttl time.Duration) ([]byte, error) {
+	var value []byte
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		bk := tx.Bucket(b.name)
+		if bk == nil {
+			return ErrNotFound
+		}
+		v, ok := decode(bk.Get([]byte(key)), time.Now())
+		if !ok {
+			return ErrNotFound
+		}
+		value = clone(v)
+		return bk.Put([]byte(key), encode(value, ttl))
+	})
+	return value, err
+}
+
+// Put sets key to value for ttl, or until deleted with a zero ttl.
+func (b *Bucket) Put(key string, value []byte, ttl time.Duration) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bk, err := tx.CreateBucketIfNotExists(b.name)
+		if err != nil {
+			return err
+		}
+		return bk.Put([]byte(key), encode(value, ttl))
+	})
+}
+
+// Add sets key like Put unless it holds a value, and reports whether it
+// did.
+func (b *Bucket) Add(key string, value []byte, ttl time.Duration) (bool, error) {
+	added := false
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		bk, err := tx.CreateBucketIfNotExists(b.name)
+		if err != nil {
+			return err
+		}
+		if _, ok := decode(bk.Get([]byte(key)), time.Now()); ok {
+			return nil
+		}
+		added = true
+		return bk.Put([]byte(key), encode(value, ttl))
+	})
+	return added, err
+}
+
+// Delete removes key.
+func (b *Bucket) Delete(key string) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bk := tx.Bucket(b.name)
+		if bk == nil {
+			return nil
+		}
+		return bk.Delete([]byte(key))
+	})
+}
+
+// All returns every value of the bucket by key.
+func (b *Bucket) All() (map[string][]byte, error) {
+	values := map[string][]byte{}
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bk := tx.Bucket(b.name)
+		if bk == nil {
+			return nil
+		}
+		now := time.Now()
+		return bk.ForEach(func(k, v []byte) error {
+			if v, ok := decode(v, now); ok {
+				values[string(k)] = clone(v)
+			}
+			return nil
+		})
+	})
+	return values, err
+}
+
+// encode prefixes value with its expiry in Unix nanoseconds, zero for
+// none.
+func encode(value []byte, ttl time.Duration) []byte {
+	var expires int64
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).UnixNano()
+	}
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b, uint64(expires))
+	copy(b[8:], value)
+	return b
+}
+
+// decode returns the value of an encoded entry unless it is missing or
+// expired at now.
+func decode(b []byte, now time.Time) ([]byte, bool) {
+	if len(b) < 8 {
+		return nil, false
+	}
+	if expires := int64(binary.BigEndian.Uint64(b)); expires != 0 && now.UnixNano() >= expires {
+		return nil, false
+	}
+	return b[8:], true
+}
+
+// clone copies v out of the memory map, where it is only valid during the
+// transaction.
+func clone(v []byte) []byte {
+	return append([]byte{}, v...)
+}
@@ -0,0 +1,61 @@
+// Package limits bounds how much a single request may consume: the size of
+// its body and the time its handler may run.
+package limits
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+)
+
+// BodyLimit refuses bodies larger than n bytes with 413. A declared
+// Content-Length is checked up front; chunked bodies fail when the handler
+// reads past the limit. Zero disables the limit.
+func BodyLimit(n int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if n <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > n {
+			// Close the connection instead of draining a huge body.
+			c.Header("Connection", "close")
+			c.Error(apperror.PayloadTooLarge("request body too large"))
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+		c.Next()
+	}
+}
+
+// Timeout gives the rest of the chain a deadline of d. Handlers pass the
+// request context to the database, Redis and outbound HTTP calls, which
+// give up once it expires; if that happens before anything is written the
+// request fails with 504. Zero disables the timeout.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		parent := c.Request.Context()
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		// Middleware further out renders errors after cancel has run, and
+		// must not mistake the cancelled deadline for a departed client.
+		c.Request = c.Request.WithContext(parent)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.Error(apperror.GatewayTimeout("request deadline exceeded"))
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/redact"
+)
+
+// Bodies logs the request and response bodies of a sample of requests as
+// one "request body" line each, next to the access log line sharing its
+// request ID. Bodies are cut at the configured size; JSON and form bodies
+// are logged with sensitive members blanked and only when complete, so that
+// a cut cannot expose a value, and other types as text or as their size.
+// WebSocket upgrades are skipped. Reloads apply to the next request.
+func Bodies(logger *slog.Logger, live *config.Live) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := live.Load().BodyLog
+		if !cfg.Enabled || !sampled(cfg.SamplePercent) || !below(c.Request.URL.Path, cfg.Paths) ||
+			c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		var request []byte
+		requestCut := false
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			request, requestCut, _ = PeekBody(c.Request, cfg.MaxBytes)
+		}
+		w := &bodyRecorder{ResponseWriter: c.Writer, max: cfg.MaxBytes}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", w.Status()),
+			slog.String("request_id", c.GetString(ContextKeyRequestID)),
+		}
+		if len(request) > 0 || requestCut {
+			attrs = append(attrs, body("request_body", c.GetHeader("Content-Type"), request, requestCut, cfg.Redact))
+		}
+		if w.Size() > 0 {
+			attrs = append(attrs, body("response_body", w.Header().Get("Content-Type"), w.buf.Bytes(), w.cut, cfg.Redact))
+		}
+		logger.LogAttrs(c.Request.Context(), slog.LevelInfo, "request body", attrs...)
+	}
+}
+
+func sampled(percent float64) bool {
+	return percent >= 100 || rand.Float64()*100 < percent
+}
+
+func below(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// body renders b, of the given content type, as a log attribute.
+func body(key, contentType string, b []byte, cut bool, names []string) slog.Attr {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		if cut {
+			return slog.String(key, fmt.Sprintf("[json over %d bytes]", len(b)))
+		}
+		if doc := redact.JSON(b, names); doc != nil {
+			return slog.Any(key, doc)
+		}
+		return slog.String(key, fmt.Sprintf("[invalid json, %d bytes]", len(b)))
+	case mt == "application/x-www-form-urlencoded":
+		if cut {
+			return slog.String(key, fmt.Sprintf("[form over %d bytes]", len(b)))
+		}
+		if form, err := url.ParseQuery(string(b)); err == nil {
+			for k := range form {
+				if redact.Sensitive(k, names) {
+					form[k] = []string{redact.Placeholder}
+				}
+			}
+			return slog.String(key, form.Encode())
+		}
+	case strings.HasPrefix(mt, "text/") || mt == "":
+	default:
+		return slog.String(key, fmt.Sprintf("[%s, %d bytes]", mt, len(b)))
+	}
+	if cut {
+		return slog.String(key, string(b)+"…")
+	}
+	return slog.String(key, string(b))
+}
+
+// PeekBody reads up to max bytes of the request body and puts them back in
+// front of the rest, so that handlers still read all of it. It reports
+// whether the body was longer, and the error failing the read, if any.
+func PeekBody(r *http.Request, max int) ([]byte, bool, error) {
+	head, err := io.ReadAll(io.LimitReader(r.Body, int64(max)+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(head) > max {
+		return head[:max], true, nil
+	}
+	return head, false, nil
+}
+
+// bodyRecorder keeps the first max bytes of the response body.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+	max int
+	cut bool
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.capture(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *bodyRecorder) WriteString(s string) (int, error) {
+	r.capture([]byte(s))
+	return r.ResponseWriter.WriteString(s)
+}
+
+func (r *bodyRecorder) capture(b []byte) {
+	if room := r.max - r.buf.Len(); len(b) > room {
+		b = b[:max(room, 0)]
+		r.cut = true
+	}
+	r.buf.Write(b)
+}
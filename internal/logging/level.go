@@ -0,0 +1,195 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The level can be overridden for a while, such as to debug an incident,
+// and so can the routes whose requests are logged at every level; both go
+// back on their own.
+var (
+	overrides     sync.Mutex
+	configured    slog.Level
+	overrideUntil time.Time
+	overrideTimer *time.Timer
+	routes        = map[string]*debugRoute{}
+)
+
+type debugRoute struct {
+	until time.Time
+	timer *time.Timer
+}
+
+// Override sets the minimum level to lvl for d, after which the configured
+// level is back.
+func Override(lvl string, d time.Duration) error {
+	l, err := ParseLevel(lvl)
+	if err != nil {
+		return err
+	}
+	overrides.Lock()
+	defer overrides.Unlock()
+	endOverride()
+	level.Set(l)
+	overrideUntil = time.Now().Add(d)
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		overrides.Lock()
+		defer overrides.Unlock()
+		if overrideTimer != t {
+			return
+		}
+		overrideTimer, overrideUntil = nil, time.Time{}
+		level.Set(configured)
+		slog.Info("log level override expired", "level", levelName(configured))
+	})
+	overrideTimer = t
+	return nil
+}
+
+// EndOverride puts the configured level back.
+func EndOverride() {
+	overrides.Lock()
+	defer overrides.Unlock()
+	endOverride()
+	level.Set(configured)
+}
+
+// endOverride stops the override timer; overrides must be held.
+func endOverride() {
+	if overrideTimer != nil {
+		overrideTimer.Stop()
+	}
+	overrideTimer, overrideUntil = nil, time.Time{}
+}
+
+// DebugRoute logs the requests to route, a route template such as
+// /api/v2/users/:id, at every level for d.
+func DebugRoute(route string, d time.Duration) {
+	overrides.Lock()
+	defer overrides.Unlock()
+	if prev := routes[route]; prev != nil {
+		prev.timer.Stop()
+	}
+	r := &debugRoute{until: time.Now().Add(d)}
+	r.timer = time.AfterFunc(d, func() {
+		overrides.Lock()
+		defer overrides.Unlock()
+		if routes[route] != r {
+			return
+		}
+		delete(routes, route)
+		slog.Info("route debug logging expired", "route", route)
+	})
+	routes[route] = r
+}
+
+// EndDebugRoute stops debugging route, and reports whether it was.
+func EndDebugRoute(route string) bool {
+	overrides.Lock()
+	defer overrides.Unlock()
+	r := routes[route]
+	if r == nil {
+		return false
+	}
+	r.timer.Stop()
+	delete(routes, route)
+	return true
+}
+
+// Levels are the levels in effect.
+type Levels struct {
+	Level      string `json:"level"`
+	Configured string `json:"configured"`
+	// Until is when an override of Level ends.
+	Until  *time.Time    `json:"until,omitempty"`
+	Routes []RouteLevels `json:"debug_routes"`
+}
+
+// RouteLevels is a route being debugged.
+type RouteLevels struct {
+	Route string    `json:"route"`
+	Until time.Time `json:"until"`
+}
+
+// Status returns the levels in effect.
+func Status() Levels {
+	overrides.Lock()
+	defer overrides.Unlock()
+	s := Levels{Level: levelName(level.Level()), Configured: levelName(configured), Routes: []RouteLevels{}}
+	if overrideTimer != nil {
+		until := overrideUntil
+		s.Until = &until
+	}
+	for route, r := range routes {
+		s.Routes = append(s.Routes, RouteLevels{Route: route, Until: r.until})
+	}
+	slices.SortFunc(s.Routes, func(a, b RouteLevels) int { return strings.Compare(a.Route, b.Route) })
+	return s
+}
+
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
+
+type debugKey struct{}
+
+// RouteDebug marks the requests to the routes being debugged, so that
+// what is logged for them passes at every level. It goes after the router
+// matched the route, in the global middleware.
+func RouteDebug() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		overrides.Lock()
+		on := routes[c.FullPath()] != nil
+		overrides.Unlock()
+		if on {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), debugKey{}, true))
+		}
+		c.Next()
+	}
+}
+
+func debugging(ctx context.Context) bool {
+	on, _ := ctx.Value(debugKey{}).(bool)
+	return on
+}
+
+// leveled passes the records at the current level or above, and all of
+// those logged with the context of a request being debugged.
+type leveled struct {
+	slog.Handler
+}
+
+func (h leveled) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= level.Level() || debugging(ctx)
+}
+
+func (h leveled) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return leveled{h.Handler.WithAttrs(attrs)}
+}
+
+func (h leveled) WithGroup(name string) slog.Handler {
+	return leveled{h.Handler.WithGroup(name)}
+}
+
+// verbose passes records at every level.
+type verbose struct {
+	slog.Handler
+}
+
+func (h verbose) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h verbose) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return verbose{h.Handler.WithAttrs(attrs)}
+}
+
+func (h verbose) WithGroup(name string) slog.Handler {
+	return verbose{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,135 @@
+// Package logging configures structured JSON logging and provides the Gin
+// middleware that assigns request IDs and writes access logs.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go-flylike-example/internal/secrets"
+)
+
+var level = new(slog.LevelVar)
+
+// Setup installs a JSON slog handler writing to w as the process-wide
+// default logger. The standard library log package is routed through it too.
+// Records are scrubbed of the secrets the configuration holds. They are
+// also handed to exporters, such as the OTLP one of the telemetry package.
+func Setup(w io.Writer, lvl string, exporters ...slog.Handler) (*slog.Logger, error) {
+	if err := SetLevel(lvl); err != nil {
+		return nil, err
+	}
+	var h slog.Handler = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	if len(exporters) > 0 {
+		h = fanout(append([]slog.Handler{h}, exporters...))
+	}
+	// The level is checked by leveled, which also lets requests to the
+	// routes being debugged through.
+	logger := slog.New(leveled{secrets.Handler(h)})
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// ParseLevel converts debug, info, warn or error to a slog.Level.
+func ParseLevel(lvl string) (slog.Level, error) {
+	switch strings.ToLower(lvl) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("logging: unknown level %q", lvl)
+}
+
+// SetLevel changes the minimum level of the default logger at runtime, as
+// configured, ending any override.
+func SetLevel(lvl string) error {
+	l, err := ParseLevel(lvl)
+	if err != nil {
+		return err
+	}
+	overrides.Lock()
+	defer overrides.Unlock()
+	configured = l
+	endOverride()
+	level.Set(l)
+	return nil
+}
+
+// Level returns the current minimum level.
+func Level() slog.Level {
+	return level.Level()
+}
+
+type ctxKey struct{}
+
+// WithRequestID stores the request ID on ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestIDFrom returns the request ID stored on ctx, if any.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger annotated with the request ID
+// carried by ctx. In a request to a route being debugged, it logs at every
+// level.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if debugging(ctx) {
+		logger = slog.New(verbose{logger.Handler()})
+	}
+	if id := RequestIDFrom(ctx); id != "" {
+		return logger.With("request_id", id)
+	}
+	return logger
+}
+
+// fanout hands every record to each of its handlers.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
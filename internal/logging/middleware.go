@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-flylike-example/api"
+	"go-flylike-example/internal/redact"
+)
+
+// HeaderRequestID is the header used to receive and propagate request IDs.
+const HeaderRequestID = api.HeaderRequestID
+
+// ContextKeyRequestID is the gin.Context key holding the request ID.
+const ContextKeyRequestID = "request_id"
+
+const maxRequestIDLen = 128
+
+// RequestID reuses a well-formed incoming X-Request-ID or generates a new
+// one, echoes it on the response and stores it on the request context.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(ContextKeyRequestID, id)
+		c.Header(HeaderRequestID, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// AccessLog writes one JSON line per request once the handler chain is done.
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("user_agent", c.Request.UserAgent()),
+			slog.String("request_id", c.GetString(ContextKeyRequestID)),
+		}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			attrs = append(attrs,
+				slog.String("trace_id", sc.TraceID().String()),
+				slog.String("span_id", sc.SpanID().String()),
+			)
+		}
+		if peer := c.RemoteIP(); peer != c.ClientIP() {
+			// Behind a trusted proxy, say which one forwarded the request.
+			attrs = append(attrs, slog.String("peer_ip", peer))
+		}
+		if state := c.Request.TLS; state != nil && len(state.VerifiedChains) > 0 {
+			// The caller authenticated with a client certificate.
+			attrs = append(attrs, slog.String("client_cert", state.VerifiedChains[0][0].Subject.CommonName))
+		}
+		// Signed links would be usable by anyone reading the log.
+		if q, _ := url.ParseQuery(query); len(q) > 0 {
+			if query := redact.Query(q, nil); query != "" {
+				attrs = append(attrs, slog.String("query", query))
+			}
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		lvl := slog.LevelInfo
+		switch {
+		case status >= 500:
+			lvl = slog.LevelError
+		case status >= 400:
+			lvl = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), lvl, "request", attrs...)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
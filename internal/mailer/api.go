@@ -0,0 +1,207 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/signer"
+
+	"go-flylike-example/internal/config"
+)
+
+// maxErrorBody is how much of a failed API response is kept in the error.
+const maxErrorBody = 512
+
+// SES sends through the Amazon SES v2 API.
+type SES struct {
+	cfg    config.Mail
+	client *http.Client
+	url    string
+}
+
+// NewSES returns an SES sender for cfg.Region.
+func NewSES(cfg config.Mail, client *http.Client) *SES {
+	base := cfg.Endpoint
+	if base == "" {
+		base = "https://email." + cfg.Region + ".amazonaws.com"
+	}
+	return &SES{cfg: cfg, client: client, url: strings.TrimRight(base, "/") + "/v2/email/outbound-emails"}
+}
+
+// Send implements Sender.
+func (s *SES) Send(ctx context.Context, m *Message) error {
+	type content struct {
+		Data    string `json:"Data"`
+		Charset string `json:"Charset"`
+	}
+	body := map[string]any{}
+	if m.Text != "" {
+		body["Text"] = content{m.Text, "UTF-8"}
+	}
+	if m.HTML != "" {
+		body["Html"] = content{m.HTML, "UTF-8"}
+	}
+	req := map[string]any{
+		"FromEmailAddress": m.From,
+		"Destination":      map[string]any{"ToAddresses": m.To},
+		"Content": map[string]any{"Simple": map[string]any{
+			"Subject": content{m.Subject, "UTF-8"},
+			"Body":    body,
+		}},
+	}
+	if m.ReplyTo != "" {
+		req["ReplyToAddresses"] = []string{m.ReplyTo}
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	sum := sha256.Sum256(payload)
+	r.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	r = signer.SignV4WithServiceType(*r, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, "", s.cfg.Region, "ses")
+	return do(s.client, r)
+}
+
+// SendGrid sends through the SendGrid v3 mail API.
+type SendGrid struct {
+	cfg    config.Mail
+	client *http.Client
+	url    string
+}
+
+// NewSendGrid returns a SendGrid sender.
+func NewSendGrid(cfg config.Mail, client *http.Client) *SendGrid {
+	base := cfg.Endpoint
+	if base == "" {
+		base = "https://api.sendgrid.com"
+	}
+	return &SendGrid{cfg: cfg, client: client, url: strings.TrimRight(base, "/") + "/v3/mail/send"}
+}
+
+// Send implements Sender.
+func (s *SendGrid) Send(ctx context.Context, m *Message) error {
+	type address struct {
+		Email string `json:"email"`
+		Name  string `json:"name,omitempty"`
+	}
+	parse := func(raw string) (address, error) {
+		a, err := mail.ParseAddress(raw)
+		if err != nil {
+			return address{}, err
+		}
+		return address{Email: a.Address, Name: a.Name}, nil
+	}
+	from, err := parse(m.From)
+	if err != nil {
+		return err
+	}
+	var to []address
+	for _, raw := range m.To {
+		a, err := parse(raw)
+		if err != nil {
+			return err
+		}
+		to = append(to, a)
+	}
+	var parts []map[string]string
+	if m.Text != "" {
+		parts = append(parts, map[string]string{"type": "text/plain", "value": m.Text})
+	}
+	if m.HTML != "" {
+		parts = append(parts, map[string]string{"type": "text/html", "value": m.HTML})
+	}
+	req := map[string]any{
+		"personalizations": []map[string]any{{"to": to}},
+		"from":             from,
+		"subject":          m.Subject,
+		"content":          parts,
+	}
+	if m.ReplyTo != "" {
+		replyTo, err := parse(m.ReplyTo)
+		if err != nil {
+			return err
+		}
+		req["reply_to"] = replyTo
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	return do(s.client, r)
+}
+
+// Mailgun sends through the Mailgun messages API.
+type Mailgun struct {
+	cfg    config.Mail
+	client *http.Client
+	url    string
+}
+
+// NewMailgun returns a Mailgun sender for cfg.Domain.
+func NewMailgun(cfg config.Mail, client *http.Client) *Mailgun {
+	base := cfg.Endpoint
+	if base == "" {
+		base = "https://api.mailgun.net"
+	}
+	return &Mailgun{cfg: cfg, client: client, url: strings.TrimRight(base, "/") + "/v3/" + url.PathEscape(cfg.Domain) + "/messages"}
+}
+
+// Send implements Sender.
+func (s *Mailgun) Send(ctx context.Context, m *Message) error {
+	form := url.Values{"from": {m.From}, "to": m.To, "subject": {m.Subject}}
+	if m.Text != "" {
+		form.Set("text", m.Text)
+	}
+	if m.HTML != "" {
+		form.Set("html", m.HTML)
+	}
+	if m.ReplyTo != "" {
+		form.Set("h:Reply-To", m.ReplyTo)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("api", s.cfg.APIKey)
+	return do(s.client, r)
+}
+
+// do sends r and turns a response other than 2xx into an error carrying
+// the start of the provider's answer.
+func do(client *http.Client, r *http.Request) error {
+	resp, err := client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+	err = fmt.Errorf("provider answered %s", resp.Status)
+	if snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody)); len(bytes.TrimSpace(snippet)) > 0 {
+		err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(snippet))
+	}
+	return err
+}
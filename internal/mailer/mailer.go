@@ -0,0 +1,153 @@
+// Package mailer sends email through a pluggable provider: SMTP, Amazon
+// SES, SendGrid or Mailgun. Messages are rendered from HTML and plain-text
+// templates and handed to the job queue, so a slow or failing provider
+// never holds up a request and failed sends are retried.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/httpclient"
+	"go-flylike-example/internal/jobs"
+)
+
+// KindSend is the job kind that delivers a message.
+const KindSend = "mail.send"
+
+// Message is an email ready to send. At least one of Text and HTML is set;
+// with both, clients pick the part they can display.
+type Message struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	ReplyTo string   `json:"reply_to,omitempty"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text,omitempty"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// Sender delivers a message through a provider, synchronously.
+type Sender interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// Mailer renders and queues messages, and delivers them from the queue.
+type Mailer struct {
+	cfg       config.Mail
+	queue     jobs.Enqueuer
+	sender    Sender
+	templates *Templates
+}
+
+// New returns a Mailer sending through the provider cfg names. Register
+// Deliver as the KindSend handler on the instances that should send.
+func New(cfg config.Mail, queue jobs.Enqueuer, m *httpclient.Metrics) (*Mailer, error) {
+	sender, err := NewSender(cfg, m)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithSender(cfg, queue, sender)
+}
+
+// NewWithSender returns a Mailer sending through sender instead of the
+// provider cfg names, such as a fake recording the messages of a test.
+func NewWithSender(cfg config.Mail, queue jobs.Enqueuer, sender Sender) (*Mailer, error) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return &Mailer{cfg: cfg, queue: queue, sender: sender, templates: templates}, nil
+}
+
+// NewSender returns the Sender for cfg.Provider.
+func NewSender(cfg config.Mail, m *httpclient.Metrics) (Sender, error) {
+	switch cfg.Provider {
+	case "log":
+		return LogSender{}, nil
+	case "smtp":
+		return NewSMTP(cfg), nil
+	case "ses":
+		return NewSES(cfg, newClient(cfg, m)), nil
+	case "sendgrid":
+		return NewSendGrid(cfg, newClient(cfg, m)), nil
+	case "mailgun":
+		return NewMailgun(cfg, newClient(cfg, m)), nil
+	}
+	return nil, fmt.Errorf("mailer: unknown provider %q", cfg.Provider)
+}
+
+func newClient(cfg config.Mail, m *httpclient.Metrics) *http.Client {
+	return httpclient.New(
+		httpclient.WithTimeout(cfg.Timeout),
+		httpclient.WithRetries(0), // the job queue retries with its own backoff
+		httpclient.WithMetrics(m),
+	)
+}
+
+// Send queues m for delivery, from the configured sender unless m names
+// one.
+func (s *Mailer) Send(ctx context.Context, m *Message) error {
+	if m.From == "" {
+		m.From = s.cfg.From
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+	if _, err := s.queue.Enqueue(ctx, KindSend, m, jobs.WithMaxAttempts(s.cfg.MaxAttempts)); err != nil {
+		return fmt.Errorf("mailer: enqueue: %w", err)
+	}
+	return nil
+}
+
+// SendTemplate renders the template name with data and queues the result
+// for to. Rendering happens now, so template errors reach the caller.
+func (s *Mailer) SendTemplate(ctx context.Context, to, name string, data any) error {
+	m, err := s.templates.Render(name, data)
+	if err != nil {
+		return err
+	}
+	m.To = []string{to}
+	return s.Send(ctx, m)
+}
+
+// Deliver is the jobs.Handler for KindSend.
+func (s *Mailer) Deliver(ctx context.Context, job *jobs.Job) error {
+	var m Message
+	if err := job.Decode(&m); err != nil {
+		return fmt.Errorf("mailer: decode job: %w", err)
+	}
+	if err := s.sender.Send(ctx, &m); err != nil {
+		return fmt.Errorf("mailer: send via %s: %w", s.cfg.Provider, err)
+	}
+	slog.InfoContext(ctx, "mail sent", "provider", s.cfg.Provider, "subject", m.Subject, "recipients", len(m.To))
+	return nil
+}
+
+func (m *Message) validate() error {
+	if len(m.To) == 0 {
+		return fmt.Errorf("mailer: message has no recipients")
+	}
+	for _, addr := range append([]string{m.From}, m.To...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("mailer: address %q: %w", addr, err)
+		}
+	}
+	if m.Text == "" && m.HTML == "" {
+		return fmt.Errorf("mailer: message has no body")
+	}
+	return nil
+}
+
+// LogSender logs messages instead of sending them, for development.
+type LogSender struct{}
+
+// Send implements Sender.
+func (LogSender) Send(ctx context.Context, m *Message) error {
+	slog.InfoContext(ctx, "mail not sent, logging only",
+		"from", m.From, "to", m.To, "subject", m.Subject, "text", m.Text)
+	return nil
+}
@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Templates are the emails the mailer can render. Each one is a pair of
+// files in templates/: <name>.txt, the plain-text body, which also defines
+// the "subject" template, and <name>.html, the HTML body, rendered inside
+// layout.html with html/template so that data is escaped.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates.
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	names, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range names {
+		name := strings.TrimSuffix(path.Base(file), ".txt")
+		text, err := texttemplate.ParseFS(templateFS, file)
+		if err != nil {
+			return nil, fmt.Errorf("mailer: template %s: %w", name, err)
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("mailer: template %s defines no subject", name)
+		}
+		html, err := htmltemplate.ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("mailer: template %s: %w", name, err)
+		}
+		t.text[name], t.html[name] = text, html
+	}
+	return t, nil
+}
+
+// Render executes the template name with data.
+func (t *Templates) Render(name string, data any) (*Message, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return nil, fmt.Errorf("mailer: unknown template %q", name)
+	}
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("mailer: render %s subject: %w", name, err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("mailer: render %s text: %w", name, err)
+	}
+	if err := t.html[name].ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return nil, fmt.Errorf("mailer: render %s html: %w", name, err)
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
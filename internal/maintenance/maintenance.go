@@ -0,0 +1,149 @@
+// Package maintenance switches the server into maintenance mode, in which
+// it refuses traffic with 503 while health probes keep answering, so that
+// deploy tooling can quiesce an instance before running migrations.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/validation"
+)
+
+// ErrMaintenance is reported by the readiness check once the grace window
+// has passed.
+var ErrMaintenance = errors.New("in maintenance")
+
+// Exempt are the paths that keep answering in maintenance mode.
+var Exempt = []string{"/healthz", "/readyz", "/health", "/metrics"}
+
+// Status describes the current mode for the admin API.
+type Status struct {
+	Enabled bool       `json:"enabled"`
+	Since   *time.Time `json:"since,omitempty"`
+	// GraceEnds is when readiness starts failing.
+	GraceEnds  *time.Time `json:"grace_ends,omitempty"`
+	Message    string     `json:"message,omitempty"`
+	RetryAfter string     `json:"retry_after"`
+}
+
+// Mode holds whether maintenance is on. Retry-After, grace and the message
+// are read from the live configuration on every request.
+type Mode struct {
+	cfg *config.Live
+
+	mu      sync.RWMutex
+	since   time.Time // zero when off
+	message string    // overrides the configured message while on
+}
+
+// New returns a Mode, on if the configuration says so.
+func New(cfg *config.Live) *Mode {
+	m := &Mode{cfg: cfg}
+	if cfg.Load().Maintenance.Enabled {
+		m.Enable("")
+	}
+	return m
+}
+
+// Enable switches maintenance on. A non-empty message replaces the
+// configured one until it is switched off; enabling it again keeps the
+// original start time so the grace window is not extended.
+func (m *Mode) Enable(message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.since.IsZero() {
+		m.since = time.Now()
+		slog.Warn("maintenance mode on")
+	}
+	m.message = message
+}
+
+// Disable switches maintenance off.
+func (m *Mode) Disable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.since.IsZero() {
+		slog.Info("maintenance mode off", "duration", time.Since(m.since).Round(time.Second).String())
+	}
+	m.since, m.message = time.Time{}, ""
+}
+
+// Toggle switches maintenance on when off and off when on.
+func (m *Mode) Toggle() {
+	if m.Enabled() {
+		m.Disable()
+	} else {
+		m.Enable("")
+	}
+}
+
+// Enabled reports whether maintenance is on.
+func (m *Mode) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return !m.since.IsZero()
+}
+
+// Status returns the current state.
+func (m *Mode) Status() Status {
+	cfg := m.cfg.Load().Maintenance
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := Status{RetryAfter: cfg.RetryAfter.String()}
+	if !m.since.IsZero() {
+		since, ends := m.since, m.since.Add(cfg.Grace)
+		st.Enabled, st.Since, st.GraceEnds = true, &since, &ends
+		st.Message = m.messageLocked(cfg)
+	}
+	return st
+}
+
+func (m *Mode) messageLocked(cfg config.Maintenance) string {
+	if m.message != "" {
+		return m.message
+	}
+	return cfg.Message
+}
+
+// Check is a readiness check that fails once maintenance has been on for
+// longer than the grace window.
+func (m *Mode) Check(context.Context) error {
+	grace := m.cfg.Load().Maintenance.Grace
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.since.IsZero() && time.Since(m.since) >= grace {
+		return ErrMaintenance
+	}
+	return nil
+}
+
+// Middleware answers every request outside Exempt with 503 and Retry-After
+// while maintenance is on.
+func (m *Mode) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.Enabled() || slices.Contains(Exempt, strings.TrimSuffix(c.Request.URL.Path, "/")) {
+			c.Next()
+			return
+		}
+		cfg := m.cfg.Load().Maintenance
+		m.mu.RLock()
+		msg := m.messageLocked(cfg)
+		m.mu.RUnlock()
+		c.Header("Retry-After", strconv.Itoa(int(cfg.RetryAfter.Round(time.Second)/time.Second)))
+		// Written here rather than through c.Error: refusing traffic is
+		// intended and must not fill the error log and samples.
+		validation.Abort(c, validation.NewProblem(http.StatusServiceUnavailable, i18n.T(c, msg)))
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package maintenance
+
+import "context"
+
+// Watch does nothing on platforms without SIGUSR1.
+func (m *Mode) Watch(ctx context.Context) {}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package maintenance
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Watch toggles maintenance mode on SIGUSR1 until ctx is done.
+func (m *Mode) Watch(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			slog.Info("maintenance toggle requested", "trigger", "signal")
+			m.Toggle()
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package metrics exposes Prometheus instrumentation for the HTTP and gRPC
+// servers.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"go-flylike-example/internal/slo"
+)
+
+// unmatchedRoute labels requests that did not match a registered route, so
+// scanners probing random paths cannot blow up label cardinality.
+const unmatchedRoute = "unmatched"
+
+// Metrics owns a Prometheus registry and the HTTP collectors recorded by
+// Middleware. Other subsystems can register their own collectors on
+// Registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	inFlight     prometheus.Gauge
+
+	rpcHandled  *prometheus.CounterVec
+	rpcDuration *prometheus.HistogramVec
+
+	objectives *slo.Tracker
+}
+
+// New creates the HTTP and gRPC collectors on a fresh registry that also includes the
+// standard Go runtime and process collectors.
+func New() *Metrics {
+	labels := []string{"method", "route", "status"}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "http",
+			Name:      "requests_total",
+			Help:      "Total number of HTTP requests processed.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "http",
+			Name:      "response_size_bytes",
+			Help:      "Size of HTTP response bodies in bytes.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of HTTP requests currently being served.",
+		}),
+		rpcHandled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grpc",
+			Name:      "server_handled_total",
+			Help:      "Total number of gRPC calls completed.",
+		}, []string{"service", "method", "code"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "grpc",
+			Name:      "server_handling_seconds",
+			Help:      "gRPC call latency in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "method"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.duration, m.responseSize, m.inFlight,
+		m.rpcHandled, m.rpcDuration,
+	)
+	return m
+}
+
+// Registry returns the registry served by Handler.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// TrackObjectives has Middleware count every request against the service
+// level objectives of t too. It must be called before serving.
+func (m *Metrics) TrackObjectives(t *slo.Tracker) {
+	m.objectives = t
+}
+
+// Middleware records request count, latency, response size and in-flight
+// requests labelled by method, route template and status code.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		lv := []string{c.Request.Method, route, strconv.Itoa(c.Writer.Status())}
+
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		elapsed := time.Since(start)
+		m.requests.WithLabelValues(lv...).Inc()
+		m.duration.WithLabelValues(lv...).Observe(elapsed.Seconds())
+		m.responseSize.WithLabelValues(lv...).Observe(float64(size))
+		if m.objectives != nil {
+			m.objectives.Observe(c.Request.Method, route, c.Writer.Status(), elapsed)
+		}
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+	return gin.WrapH(h)
+}
@@ -0,0 +1,250 @@
+// Package mirror copies a sample of live requests to a shadow deployment,
+// so that a new version can be validated against real traffic before it
+// takes over. Copies are sent in the background by a fixed set of senders
+// and their responses thrown away: production requests never wait for the
+// shadow, and copies are dropped rather than queued without bound when it
+// falls behind. Credentials never leave, and sensitive members of bodies
+// and query strings are blanked as in the body log.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/redact"
+)
+
+// HeaderMirrored marks mirrored requests, so that the shadow can tell them
+// apart and never mirrors them again.
+const HeaderMirrored = "X-Mirrored"
+
+// stripped are never mirrored: credentials, and the hop-by-hop headers the
+// client set for this connection only.
+var stripped = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", apikeys.HeaderAPIKey, "X-CSRF-Token",
+	"Connection", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Mirror sends copies of requests to the shadow.
+type Mirror struct {
+	cfg    config.Mirror
+	target *url.URL
+	client *http.Client
+	queue  chan *http.Request
+
+	dropped prometheus.Counter
+	wg      sync.WaitGroup
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// New returns a Mirror sending to cfg.URL with client, counting dropped
+// copies on reg, or nil when mirroring is off.
+func New(cfg config.Mirror, client *http.Client, reg prometheus.Registerer) (*Mirror, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	target, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mirror{
+		cfg:    cfg,
+		target: target,
+		client: client,
+		queue:  make(chan *http.Request, cfg.Queue),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "mirror",
+			Name:      "dropped_total",
+			Help:      "Mirrored requests dropped because the queue was full.",
+		}),
+		stop: make(chan struct{}),
+	}
+	reg.MustRegister(m.dropped)
+	return m, nil
+}
+
+// Start launches the senders.
+func (m *Mirror) Start() {
+	for range m.cfg.Concurrency {
+		m.wg.Add(1)
+		go m.send()
+	}
+	slog.Info("traffic mirroring started", "url", m.target.Redacted(), "sample_percent", m.cfg.SamplePercent)
+}
+
+// Shutdown stops taking copies and waits for the queued ones to be sent,
+// or for ctx to expire.
+func (m *Mirror) Shutdown(ctx context.Context) error {
+	m.once.Do(func() { close(m.stop) })
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Mirror) send() {
+	defer m.wg.Done()
+	for {
+		select {
+		case req := <-m.queue:
+			m.do(req)
+		case <-m.stop:
+			for {
+				select {
+				case req := <-m.queue:
+					m.do(req)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *Mirror) do(req *http.Request) {
+	resp, err := m.client.Do(req)
+	if err != nil {
+		slog.Debug("mirrored request failed", "path", req.URL.Path, "error", err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// Middleware queues a copy of each sampled request before handing it on.
+// The body is read up to the limit and put back for the handler.
+func (m *Mirror) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := c.Request
+		if !m.sampled(r) {
+			c.Next()
+			return
+		}
+		var body []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			head, cut, err := logging.PeekBody(r, m.cfg.MaxBytes)
+			if cut || err != nil {
+				c.Next()
+				return
+			}
+			body = head
+		}
+		if req := m.copy(c, body); req != nil {
+			select {
+			case <-m.stop:
+			case m.queue <- req:
+			default:
+				m.dropped.Inc()
+			}
+		}
+		c.Next()
+	}
+}
+
+func (m *Mirror) sampled(r *http.Request) bool {
+	if r.Header.Get(HeaderMirrored) != "" || r.Header.Get("Upgrade") != "" ||
+		r.ContentLength > int64(m.cfg.MaxBytes) {
+		return false
+	}
+	if len(m.cfg.Paths) > 0 && !below(r.URL.Path, m.cfg.Paths) {
+		return false
+	}
+	return m.cfg.SamplePercent >= 100 || rand.Float64()*100 < m.cfg.SamplePercent
+}
+
+func below(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// copy builds the shadow's request: the same method, path and headers,
+// less credentials and signed link parameters, with sensitive values
+// blanked. It returns nil for
+// requests that cannot be made safe to send.
+func (m *Mirror) copy(c *gin.Context, body []byte) *http.Request {
+	r := c.Request
+	u := *m.target
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	if r.URL.RawQuery != "" {
+		u.RawQuery = redact.Query(r.URL.Query(), m.cfg.Redact)
+	}
+	body, ok := m.redactBody(r.Header.Get("Content-Type"), body)
+	if !ok {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	req.Header = r.Header.Clone()
+	for _, name := range stripped {
+		req.Header.Del(name)
+	}
+	for _, name := range m.cfg.StripHeaders {
+		req.Header.Del(name)
+	}
+	req.Header.Set(HeaderMirrored, "true")
+	req.Header.Set("X-Forwarded-For", c.ClientIP())
+	if id := logging.RequestIDFrom(r.Context()); id != "" {
+		req.Header.Set(logging.HeaderRequestID, id)
+	}
+	req.Host = ""
+	return req
+}
+
+// redactBody blanks the sensitive members of JSON and form bodies, and
+// reports false for such bodies that do not parse. Bodies that look like
+// JSON count as JSON whatever their type, since most handlers read them
+// as such. Bodies of other types are sent as they are.
+func (m *Mirror) redactBody(contentType string, body []byte) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, true
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "application/json" || strings.HasSuffix(mt, "+json") || trimmed[0] == '{' || trimmed[0] == '[':
+		doc := redact.JSON(body, m.cfg.Redact)
+		return doc, doc != nil
+	case mt == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, false
+		}
+		for k := range form {
+			if redact.Sensitive(k, m.cfg.Redact) {
+				form[k] = []string{redact.Placeholder}
+			}
+		}
+		return []byte(form.Encode()), true
+	}
+	return body, true
+}
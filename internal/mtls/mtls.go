@@ -0,0 +1,150 @@
+// Package mtls authenticates the services calling the admin listener by
+// their TLS client certificates, which unlike a shared token identify the
+// caller and can be issued, rotated and revoked per service by the
+// internal CA. The TLS handshake verifies the certificate chain; the
+// middleware then checks the certificate's SANs against the allowlist and
+// records who the caller is for the handlers and the logs.
+package mtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+)
+
+// Identity is who a client certificate was issued to.
+type Identity struct {
+	// Subject is the certificate's subject common name.
+	Subject  string   `json:"subject"`
+	DNSNames []string `json:"dns_names,omitempty"`
+	URIs     []string `json:"uris,omitempty"`
+	Emails   []string `json:"emails,omitempty"`
+	// Fingerprint is the hex SHA-256 of the certificate, which names it
+	// unambiguously, such as for revocation.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// SANs returns the DNS, URI and email SANs of the certificate.
+func (id *Identity) SANs() []string {
+	sans := make([]string, 0, len(id.DNSNames)+len(id.URIs)+len(id.Emails))
+	sans = append(sans, id.DNSNames...)
+	sans = append(sans, id.URIs...)
+	return append(sans, id.Emails...)
+}
+
+// Name is how the caller is referred to: its first URI SAN, as with SPIFFE
+// IDs, else its first DNS SAN, else its subject.
+func (id *Identity) Name() string {
+	switch {
+	case len(id.URIs) > 0:
+		return id.URIs[0]
+	case len(id.DNSNames) > 0:
+		return id.DNSNames[0]
+	}
+	return id.Subject
+}
+
+func identityOf(cert *x509.Certificate) *Identity {
+	sum := sha256.Sum256(cert.Raw)
+	id := &Identity{
+		Subject:     cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		Emails:      cert.EmailAddresses,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity of the client certificate the request
+// of ctx was authenticated with.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// ServerConfig returns the TLS configuration of the admin listener, or nil
+// when it serves plain HTTP. With a client CA bundle, the handshake
+// requires a client certificate that chains to one of its CAs.
+func ServerConfig(cfg config.Admin) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("mtls: load key pair: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mtls: read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mtls: no certificates in %s", cfg.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// Require admits only requests that presented a verified client
+// certificate with a SAN matching one of the allow patterns, or any
+// verified certificate when allow is empty, and puts its identity in the
+// request context.
+func Require(allow []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			c.Error(apperror.Unauthorized("client certificate required"))
+			c.Abort()
+			return
+		}
+		id := identityOf(state.VerifiedChains[0][0])
+		if len(allow) > 0 && !allowed(id, allow) {
+			logging.FromContext(c.Request.Context()).Warn("client certificate not allowed",
+				"subject", id.Subject, "sans", id.SANs(), "fingerprint", id.Fingerprint)
+			c.Error(apperror.Forbidden("client certificate not allowed"))
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+func allowed(id *Identity, allow []string) bool {
+	for _, san := range id.SANs() {
+		for _, pattern := range allow {
+			if ok, _ := path.Match(pattern, san); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
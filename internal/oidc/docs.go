@@ -0,0 +1,20 @@
+package oidc
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"auth"}
+	return []openapi.Operation{
+		{ID: "startSocialLogin", Method: http.MethodGet, Path: "/:provider/login", Tags: tags, Summary: "Start social login",
+			Description: "Redirects to the identity provider. `return_to` names the local path to land on afterwards.",
+			Status:      http.StatusFound, Errors: []int{http.StatusNotFound}},
+		{ID: "finishSocialLogin", Method: http.MethodGet, Path: "/:provider/callback", Tags: tags, Summary: "Finish social login",
+			Description: "Called by the identity provider; signs the browser session in and redirects.",
+			Status:      http.StatusFound, Errors: []int{http.StatusBadRequest, http.StatusBadGateway}},
+	}
+}
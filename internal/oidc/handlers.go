@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/session"
+)
+
+// Session keys written by a successful login. SessionUser holds
+// "<provider>:<subject>".
+const (
+	SessionUser     = "user"
+	SessionEmail    = "email"
+	SessionName     = "name"
+	SessionProvider = "provider"
+)
+
+const (
+	pendingKey = "oidc.pending"
+	// pendingTTL bounds how long a user may take at the provider.
+	pendingTTL = 10 * time.Minute
+)
+
+// pending is the login attempt remembered between redirect and callback.
+type pending struct {
+	Provider string    `json:"provider"`
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	ReturnTo string    `json:"return_to"`
+	Started  time.Time `json:"started"`
+}
+
+// Register mounts /:provider/login and /:provider/callback on g, which
+// must run the session middleware.
+func (s *Service) Register(g *gin.RouterGroup) {
+	g.GET("/:provider/login", s.handleLogin)
+	g.GET("/:provider/callback", s.handleCallback)
+}
+
+func (s *Service) provider(c *gin.Context) (*provider, bool) {
+	p, ok := s.providers[c.Param("provider")]
+	if !ok {
+		c.Error(apperror.NotFound("unknown identity provider"))
+	}
+	return p, ok
+}
+
+// handleLogin redirects to the provider. The optional return_to query
+// parameter is a local path to land on after logging in.
+func (s *Service) handleLogin(c *gin.Context) {
+	p, ok := s.provider(c)
+	if !ok {
+		return
+	}
+	ctx := s.context(c.Request.Context())
+	if err := p.prepare(ctx); err != nil {
+		c.Error(&apperror.Error{Kind: apperror.KindBadGateway, Message: "identity provider unavailable", Err: err})
+		return
+	}
+
+	pend := pending{
+		Provider: p.name,
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: oauth2.GenerateVerifier(),
+		ReturnTo: localPath(c.Query("return_to")),
+		Started:  time.Now(),
+	}
+	data, _ := json.Marshal(pend)
+	session.From(c).Set(pendingKey, string(data))
+
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(pend.Verifier)}
+	if p.issuer != "" {
+		opts = append(opts, gooidc.Nonce(pend.Nonce))
+	}
+	c.Redirect(http.StatusFound, p.oauth.AuthCodeURL(pend.State, opts...))
+}
+
+// handleCallback completes the flow: it checks the state against the
+// session, redeems the code with the PKCE verifier and stores the identity
+// in a fresh session.
+func (s *Service) handleCallback(c *gin.Context) {
+	p, ok := s.provider(c)
+	if !ok {
+		return
+	}
+	sess := session.From(c)
+	var pend pending
+	raw := sess.Get(pendingKey)
+	if raw != "" {
+		// Every attempt is single use, whatever its outcome.
+		sess.Delete(pendingKey)
+	}
+	if json.Unmarshal([]byte(raw), &pend) != nil || pend.Provider != p.name || time.Since(pend.Started) > pendingTTL {
+		c.Error(apperror.BadRequest("login attempt expired or unknown, please start again"))
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		c.Error(apperror.Newf(apperror.KindUnauthorized, "identity provider refused login: %s", e))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(pend.State)) != 1 {
+		c.Error(apperror.BadRequest("invalid login state"))
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.Error(apperror.BadRequest("missing authorization code"))
+		return
+	}
+
+	ctx := s.context(c.Request.Context())
+	if err := p.prepare(ctx); err != nil {
+		c.Error(&apperror.Error{Kind: apperror.KindBadGateway, Message: "identity provider unavailable", Err: err})
+		return
+	}
+	tok, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(pend.Verifier))
+	if err != nil {
+		var re *oauth2.RetrieveError
+		if errors.As(err, &re) {
+			c.Error(&apperror.Error{Kind: apperror.KindUnauthorized, Message: "authorization code rejected", Err: err})
+		} else {
+			c.Error(&apperror.Error{Kind: apperror.KindBadGateway, Message: "identity provider unavailable", Err: err})
+		}
+		return
+	}
+	id, err := p.identify(ctx, p, tok, pend.Nonce)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// New privileges, new session ID.
+	sess.Regenerate()
+	sess.Set(SessionUser, id.Provider+":"+id.Subject)
+	sess.Set(SessionProvider, id.Provider)
+	sess.Set(SessionEmail, id.Email)
+	sess.Set(SessionName, id.Name)
+	c.Redirect(http.StatusFound, pend.ReturnTo)
+}
+
+// localPath keeps return_to on this site so the login cannot be used as an
+// open redirect.
+func localPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return "/"
+	}
+	return p
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,102 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+
+	"go-flylike-example/internal/apperror"
+)
+
+var errUnverified = apperror.Unauthorized("identity could not be verified")
+
+// identifyOIDC verifies the ID token returned with tok, including the
+// nonce bound to the login attempt.
+func identifyOIDC(ctx context.Context, p *provider, tok *oauth2.Token, nonce string) (*Identity, error) {
+	raw, _ := tok.Extra("id_token").(string)
+	if raw == "" {
+		return nil, &apperror.Error{Kind: apperror.KindBadGateway, Message: "identity provider returned no id token",
+			Err: fmt.Errorf("oidc %s: no id_token in token response", p.name)}
+	}
+	idt, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, &apperror.Error{Kind: errUnverified.Kind, Message: errUnverified.Message, Err: err}
+	}
+	if idt.Nonce != nonce {
+		return nil, errUnverified
+	}
+	var claims struct {
+		Email    string `json:"email"`
+		Verified *bool  `json:"email_verified"`
+		Name     string `json:"name"`
+	}
+	if err := idt.Claims(&claims); err != nil {
+		return nil, &apperror.Error{Kind: errUnverified.Kind, Message: errUnverified.Message, Err: err}
+	}
+	id := &Identity{Provider: p.name, Subject: idt.Subject, Name: claims.Name}
+	// An unverified address could belong to someone else.
+	if claims.Verified == nil || *claims.Verified {
+		id.Email = claims.Email
+	}
+	return id, nil
+}
+
+// identifyGitHub reads the account behind tok from the GitHub API, which
+// does not speak OpenID Connect.
+func identifyGitHub(ctx context.Context, p *provider, tok *oauth2.Token, _ string) (*Identity, error) {
+	client := p.oauth.Client(ctx, tok)
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user", &user); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	id := &Identity{Provider: p.name, Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if id.Name == "" {
+		id.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			id.Email = e.Email
+		}
+	}
+	return id, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("status %s", resp.Status)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(dst)
+		}
+	}
+	if err != nil {
+		return &apperror.Error{Kind: apperror.KindBadGateway, Message: "identity provider unavailable",
+			Err: fmt.Errorf("oidc: GET %s: %w", url, err)}
+	}
+	return nil
+}
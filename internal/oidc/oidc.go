@@ -0,0 +1,108 @@
+// Package oidc implements password-less login with external identity
+// providers: the OAuth 2.0 authorization-code flow with PKCE and state
+// validation, OpenID Connect ID token verification where the provider
+// supports it, and a browser session for the resulting identity.
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+
+	"go-flylike-example/internal/config"
+)
+
+// googleIssuer is used for the "google" provider when no issuer is set.
+const googleIssuer = "https://accounts.google.com"
+
+// Identity is the user a provider vouched for.
+type Identity struct {
+	Provider string
+	Subject  string
+	Email    string
+	Name     string
+}
+
+// Service runs the login flow for the configured providers.
+type Service struct {
+	providers map[string]*provider
+	client    *http.Client
+}
+
+type provider struct {
+	name     string
+	issuer   string // empty for plain OAuth 2.0 providers
+	oauth    oauth2.Config
+	identify func(ctx context.Context, p *provider, tok *oauth2.Token, nonce string) (*Identity, error)
+
+	// Discovery runs on first use so that an unreachable provider does
+	// not keep the server from starting.
+	mu       sync.Mutex
+	verifier *gooidc.IDTokenVerifier
+}
+
+// New prepares the providers in cfg. Calls to providers go through client.
+func New(cfg config.OIDC, client *http.Client) *Service {
+	s := &Service{providers: make(map[string]*provider), client: client}
+	base := strings.TrimSuffix(cfg.RedirectBaseURL, "/")
+	for name, pc := range cfg.Providers {
+		p := &provider{
+			name:   name,
+			issuer: pc.IssuerURL,
+			oauth: oauth2.Config{
+				ClientID:     pc.ClientID,
+				ClientSecret: pc.ClientSecret,
+				RedirectURL:  base + "/auth/oidc/" + name + "/callback",
+				Scopes:       pc.Scopes,
+			},
+			identify: identifyOIDC,
+		}
+		switch {
+		case name == "github" && pc.IssuerURL == "":
+			p.oauth.Endpoint = github.Endpoint
+			p.identify = identifyGitHub
+			if len(p.oauth.Scopes) == 0 {
+				p.oauth.Scopes = []string{"read:user", "user:email"}
+			}
+		case name == "google" && pc.IssuerURL == "":
+			p.issuer = googleIssuer
+		}
+		if p.issuer != "" && len(p.oauth.Scopes) == 0 {
+			p.oauth.Scopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+		}
+		s.providers[name] = p
+	}
+	return s
+}
+
+// context makes the oauth2 and oidc libraries use the service's client.
+func (s *Service) context(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.client)
+	return gooidc.ClientContext(ctx, s.client)
+}
+
+// prepare discovers the endpoints and signing keys of OpenID Connect
+// providers. A failed discovery is retried on the next login.
+func (p *provider) prepare(ctx context.Context) error {
+	if p.issuer == "" {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.verifier != nil {
+		return nil
+	}
+	disc, err := gooidc.NewProvider(ctx, p.issuer)
+	if err != nil {
+		return fmt.Errorf("oidc: discover %s: %w", p.name, err)
+	}
+	p.oauth.Endpoint = disc.Endpoint()
+	p.verifier = disc.Verifier(&gooidc.Config{ClientID: p.oauth.ClientID})
+	return nil
+}
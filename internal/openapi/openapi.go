@@ -0,0 +1,269 @@
+// Package openapi builds an OpenAPI 3 document from a typed registry of
+// operations. Packages describe their routes next to Register, with request
+// and response values whose Go types are turned into schemas, and the
+// router mounts the result at /openapi.json with a Swagger UI at /docs.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/validation"
+)
+
+// Operation describes one route. Path is relative to the prefix passed to
+// Add and uses gin's :param syntax. ID, unique in the document and in
+// lower camel case, names the method of the route in generated clients.
+type Operation struct {
+	ID          string
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Auth marks operations needing a bearer token or API key; Scope is the
+	// API key scope they check, if any.
+	Auth  bool
+	Scope string
+	// Query documents the query parameters.
+	Query []Param
+	// Request is a value of the body type; RequestType defaults to JSON.
+	Request     any
+	RequestType string
+	// Response is a value of the success body type, sent with Status
+	// (default 200). A nil Response documents an empty body. ResponseType
+	// defaults to JSON; streams of values, such as NDJSON, document the
+	// type of one value.
+	Response     any
+	ResponseType string
+	Status       int
+	// Errors lists the problem+json statuses worth documenting.
+	Errors []int
+}
+
+// Param is a query parameter; its values are strings.
+type Param struct {
+	Name        string
+	Description string
+}
+
+// Document collects operations. It is safe for concurrent use.
+type Document struct {
+	title   string
+	version string
+
+	mu  sync.Mutex
+	ops map[string]map[string]operation // path, lower-case method
+}
+
+// New returns an empty Document for the API named title at version.
+func New(title, version string) *Document {
+	return &Document{title: title, version: version, ops: make(map[string]map[string]operation)}
+}
+
+// Add registers ops below prefix, typically the group's BasePath.
+func (d *Document) Add(prefix string, ops ...Operation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, op := range ops {
+		path := ginToOpenAPI(strings.TrimSuffix(prefix+op.Path, "/"))
+		if path == "" {
+			path = "/"
+		}
+		if d.ops[path] == nil {
+			d.ops[path] = make(map[string]operation)
+		}
+		d.ops[path][strings.ToLower(op.Method)] = build(path, op)
+	}
+}
+
+// Documented reports whether method and the gin path are described.
+func (d *Document) Documented(method, path string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.ops[ginToOpenAPI(path)][strings.ToLower(method)]
+	return ok
+}
+
+var (
+	ginParam  = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+	pathParam = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+)
+
+func ginToOpenAPI(path string) string {
+	return ginParam.ReplaceAllString(path, "{$1}")
+}
+
+func build(path string, op Operation) operation {
+	out := operation{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Deprecated:  op.Deprecated,
+		Responses:   map[string]response{},
+	}
+	if op.Scope != "" {
+		scope := "API key scope: `" + op.Scope + "`."
+		if out.Description == "" {
+			out.Description = scope
+		} else {
+			out.Description += "\n\n" + scope
+		}
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		out.Parameters = append(out.Parameters, parameter{
+			Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	for _, q := range op.Query {
+		out.Parameters = append(out.Parameters, parameter{
+			Name: q.Name, In: "query", Description: q.Description, Schema: &Schema{Type: "string"},
+		})
+	}
+	if op.Request != nil {
+		ct := op.RequestType
+		if ct == "" {
+			ct = "application/json"
+		}
+		out.RequestBody = &requestBody{Required: true, Content: map[string]mediaType{ct: {Schema: SchemaOf(op.Request)}}}
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		ct := op.ResponseType
+		if ct == "" {
+			ct = "application/json"
+		}
+		ok.Content = map[string]mediaType{ct: {Schema: SchemaOf(op.Response)}}
+	}
+	out.Responses[strconv.Itoa(status)] = ok
+
+	errs := op.Errors
+	if op.Auth {
+		out.Security = []map[string][]string{{"bearer": {}}, {"apiKey": {}}}
+		errs = append(errs, http.StatusUnauthorized)
+	}
+	if op.Request != nil {
+		errs = append(errs, http.StatusBadRequest)
+	}
+	for _, code := range errs {
+		out.Responses[strconv.Itoa(code)] = response{
+			Description: http.StatusText(code),
+			Content:     map[string]mediaType{"application/problem+json": {Schema: &Schema{Ref: "#/components/schemas/Problem"}}},
+		}
+	}
+	return out
+}
+
+// document is the serialised form.
+type document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       info                            `json:"info"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components components                      `json:"components"`
+	Tags       []tag                           `json:"tags,omitempty"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type tag struct {
+	Name string `json:"name"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+func (d *Document) snapshot() document {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	doc := document{
+		OpenAPI: "3.0.3",
+		Info:    info{Title: d.title, Version: d.version},
+		Paths:   d.ops,
+		Components: components{
+			Schemas: map[string]*Schema{"Problem": SchemaOf(validation.Problem{})},
+			SecuritySchemes: map[string]securityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+				"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+	}
+	seen := map[string]bool{}
+	for _, methods := range d.ops {
+		for _, op := range methods {
+			for _, t := range op.Tags {
+				if !seen[t] {
+					seen[t] = true
+					doc.Tags = append(doc.Tags, tag{Name: t})
+				}
+			}
+		}
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+// Handler serves the document as JSON.
+func (d *Document) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-cache")
+		c.JSON(http.StatusOK, d.snapshot())
+	}
+}
@@ -0,0 +1,210 @@
+package openapi
+
+import (
+	"encoding/json"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI schema object the generator uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// Binary is a file field of a multipart request.
+type Binary struct{}
+
+// envelope is the {"status", "message", "data"} wrapper of v2 responses,
+// with a "meta" member on paginated lists.
+type envelope struct{ data, meta any }
+
+// Envelope documents a v2 response whose data member is of data's type;
+// a nil data documents a response without one.
+func Envelope(data any) any {
+	return envelope{data: data}
+}
+
+// Paginated documents a v2 list response whose data member is of data's
+// type and whose meta member, describing the page, is of meta's.
+func Paginated(data, meta any) any {
+	return envelope{data: data, meta: meta}
+}
+
+var (
+	timeType   = reflect.TypeFor[time.Time]()
+	binaryType = reflect.TypeFor[Binary]()
+	rawType    = reflect.TypeFor[json.RawMessage]()
+)
+
+// SchemaOf derives a schema from the type of v, following json tags and
+// the binding tags used for validation.
+func SchemaOf(v any) *Schema {
+	if e, ok := v.(envelope); ok {
+		s := &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"status":  {Type: "string"},
+				"message": {Type: "string"},
+			},
+			Required: []string{"status", "message"},
+		}
+		if e.data != nil {
+			s.Properties["data"] = SchemaOf(e.data)
+			s.Required = append(s.Required, "data")
+		}
+		if e.meta != nil {
+			s.Properties["meta"] = SchemaOf(e.meta)
+			s.Required = append(s.Required, "meta")
+		}
+		return s
+	}
+	return schemaFor(reflect.TypeOf(v))
+}
+
+func schemaFor(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Pointer {
+		s := schemaFor(t.Elem())
+		s.Nullable = true
+		return s
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case binaryType:
+		return &Schema{Type: "string", Format: "binary"}
+	case rawType:
+		return &Schema{} // any JSON value
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		// Exported types keep their name, for generated clients.
+		if token.IsExported(t.Name()) {
+			s.Title = t.Name()
+		}
+		addFields(s, t)
+		return s
+	}
+	return &Schema{}
+}
+
+func addFields(s *Schema, t reflect.Type) {
+	for f := range t.Fields() {
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(s, ft)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := schemaFor(f.Type)
+		required := applyBinding(fs, f.Tag.Get("binding"))
+		if required || (!strings.Contains(opts, "omitempty") && f.Tag.Get("binding") == "" && f.Type.Kind() != reflect.Pointer) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// applyBinding maps validator rules onto s and reports whether the field
+// is required. Rules after dive apply to the elements.
+func applyBinding(s *Schema, tag string) bool {
+	required := false
+	target := s
+	for rule := range strings.SplitSeq(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if target == s {
+				required = true
+			}
+		case "dive":
+			if s.Items != nil {
+				target = s.Items
+			}
+		case "email":
+			target.Format = "email"
+		case "url", "http_url":
+			target.Format = "uri"
+		case "max", "min", "gte", "lte":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			setBound(target, name, n)
+		}
+	}
+	return required
+}
+
+func setBound(s *Schema, rule string, n float64) {
+	i := int(n)
+	upper := rule == "max" || rule == "lte"
+	switch s.Type {
+	case "string":
+		if upper {
+			s.MaxLength = &i
+		} else {
+			s.MinLength = &i
+		}
+	case "array":
+		if upper {
+			s.MaxItems = &i
+		}
+	case "integer", "number":
+		if upper {
+			s.Maximum = &n
+		} else {
+			s.Minimum = &n
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIVersion pins the Swagger UI release loaded from the CDN.
+const swaggerUIVersion = "5.17.14"
+
+var uiPage = template.Must(template.New("ui").Parse(`<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: {{.SpecURL}}, dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`))
+
+// UI serves a Swagger UI page for the document at specURL.
+func (d *Document) UI(specURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = uiPage.Execute(c.Writer, map[string]string{
+			"Title":   d.title,
+			"Version": swaggerUIVersion,
+			"SpecURL": specURL,
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package operations
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"operations"}
+	return []openapi.Operation{
+		{ID: "getOperation", Method: http.MethodGet, Path: "/:id", Tags: tags, Summary: "Get a long-running operation", Auth: true,
+			Description: "Routes doing long work answer 202 with the operation and its URL here in Location. Poll it, waiting as long as Retry-After says, until its state is succeeded, with the result, or failed, with the error.",
+			Response:    openapi.Envelope(Operation{}), Errors: []int{http.StatusNotFound}},
+	}
+}
+
+// StreamOperations documents the routes of RegisterStream.
+func StreamOperations() []openapi.Operation {
+	tags := []string{"operations"}
+	return []openapi.Operation{
+		{ID: "followOperation", Method: http.MethodGet, Path: "/:id/events", Tags: tags, Summary: "Follow a long-running operation", Auth: true,
+			Description: "Server-Sent Events: an operation event with the operation now and whenever its state changes. The stream ends once the operation is done.",
+			Response:    Operation{}, ResponseType: "text/event-stream", Errors: []int{http.StatusNotFound}},
+	}
+}
@@ -0,0 +1,149 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/render"
+)
+
+// pollInterval is how often a stream looks for changes, and what clients
+// polling are told to wait with Retry-After.
+const pollInterval = time.Second
+
+// heartbeatPeriod is how often a stream with nothing to report sends a
+// comment line, as the realtime streams do.
+const heartbeatPeriod = 15 * time.Second
+
+// Start starts an operation of kind with input for the authenticated
+// caller and answers the request with 202 Accepted, the operation and its
+// Location. Handlers call it instead of doing work that takes long; the
+// operation is only run if the request's transaction commits.
+func (s *Service) Start(c *gin.Context, kind string, input any) {
+	claims, ok := auth.ClaimsFrom(c)
+	if !ok {
+		c.Error(apperror.Unauthorized("authentication required"))
+		return
+	}
+	op, err := s.Create(c.Request.Context(), claims.Subject, kind, input)
+	if err != nil {
+		c.Error(apperror.Internal(err))
+		return
+	}
+	c.Header("Location", Path+"/"+op.ID)
+	c.Header("Retry-After", retryAfter())
+	c.JSON(http.StatusAccepted, render.OK(i18n.T(c, "operation accepted"), op))
+}
+
+// Register mounts the polling route on g for authenticated callers, who
+// only see their own operations:
+//
+//	GET /:id   the operation; Retry-After is set until it is done
+func (s *Service) Register(g *gin.RouterGroup) {
+	g.Use(auth.Required())
+	g.GET("/:id", s.handleGet)
+}
+
+// RegisterStream mounts the streaming route on g, which must not buffer
+// responses or bound them in time:
+//
+//	GET /:id/events   the operation as Server-Sent Events until it is done
+func (s *Service) RegisterStream(g *gin.RouterGroup) {
+	g.Use(auth.Required())
+	g.GET("/:id/events", s.handleEvents)
+}
+
+func (s *Service) handleGet(c *gin.Context) {
+	claims, _ := auth.ClaimsFrom(c)
+	op, err := s.Get(c.Request.Context(), claims.Subject, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !op.Done() {
+		c.Header("Retry-After", retryAfter())
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "operation found"), op))
+}
+
+// handleEvents sends an operation event with the operation when the
+// stream starts and whenever its state changes, and ends the stream once
+// it is done.
+func (s *Service) handleEvents(c *gin.Context) {
+	claims, _ := auth.ClaimsFrom(c)
+	ctx := c.Request.Context()
+	op, err := s.Get(ctx, claims.Subject, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// The server's WriteTimeout would otherwise cut the stream off.
+	rc := http.NewResponseController(c.Writer)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+
+	var last []byte
+	for {
+		data, err := json.Marshal(op)
+		if err != nil {
+			return
+		}
+		if !bytes.Equal(data, last) {
+			if _, err := fmt.Fprintf(c.Writer, "event: operation\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			last = data
+		}
+		if op.Done() {
+			return
+		}
+
+	wait:
+		for {
+			select {
+			case <-poll.C:
+				break wait
+			case <-heartbeat.C:
+				if _, err := io.WriteString(c.Writer, ": ping\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+		if op, err = s.Get(ctx, claims.Subject, op.ID); err != nil {
+			return
+		}
+	}
+}
+
+func retryAfter() string {
+	return fmt.Sprint(int(pollInterval / time.Second))
+}
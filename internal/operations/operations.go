@@ -0,0 +1,286 @@
+// Package operations runs long requests in the background. A handler whose
+// work takes longer than clients and proxies are willing to wait starts an
+// operation instead: the request is answered at once with 202 Accepted and
+// a Location to poll, and the work runs on the job queue, surviving
+// restarts and retried like any job. Clients then poll the operation or
+// follow its state as Server-Sent Events until it succeeded, with the
+// result, or failed, with the error.
+package operations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/jobs"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+)
+
+// KindRun is the job kind operations run as.
+const KindRun = "operation.run"
+
+// Path is where operations are polled.
+const Path = "/api/v2/operations"
+
+// Operation states.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// ErrNotFound is returned for unknown operations and for those of another
+// owner, which are not revealed.
+var ErrNotFound = apperror.NotFound("operation not found")
+
+// Operation is a unit of long-running work and its outcome.
+type Operation struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// State is pending, running, succeeded or failed.
+	State string `json:"state"`
+	// Result is what the work returned, once it succeeded.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is why it failed.
+	Error string `json:"error,omitempty"`
+	// Progress is what the work last reported of how far it got.
+	Progress  json.RawMessage `json:"progress,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+
+	owner  string
+	tenant string
+	input  json.RawMessage
+}
+
+// Done reports whether op has finished, successfully or not.
+func (op *Operation) Done() bool {
+	return op.State == StateSucceeded || op.State == StateFailed
+}
+
+// Owner returns the subject that started op.
+func (op *Operation) Owner() string { return op.owner }
+
+// Decode unmarshals the input op was started with into v.
+func (op *Operation) Decode(v any) error {
+	return json.Unmarshal(op.input, v)
+}
+
+// Func does the work of an operation kind and returns its result, which
+// must marshal to JSON. An error fails the operation once its job is out
+// of attempts; the message of an apperror.Error is shown to the client,
+// other errors only as "operation failed". Funcs run in the tenant of the
+// request that started them, for up to the job lease.
+type Func func(ctx context.Context, op *Operation) (any, error)
+
+// Service starts, runs and reports operations.
+type Service struct {
+	db    *store.Store
+	queue jobs.Enqueuer
+
+	mu    sync.RWMutex
+	funcs map[string]Func
+}
+
+// New returns a Service running operations through queue. Register Run
+// with the queue as the handler of KindRun.
+func New(db *store.Store, queue jobs.Enqueuer) *Service {
+	return &Service{db: db, queue: queue, funcs: make(map[string]Func)}
+}
+
+// Handle registers fn to do the work of operations of kind.
+func (s *Service) Handle(kind string, fn Func) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.funcs[kind] = fn
+}
+
+type runPayload struct {
+	ID string `json:"id"`
+}
+
+// Create records a pending operation of kind for owner and enqueues its
+// job. Both go through the transaction of ctx, so an operation is never
+// started for a request that is rolled back.
+func (s *Service) Create(ctx context.Context, owner, kind string, input any) (*Operation, error) {
+	in, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("operations: create %s: %w", kind, err)
+	}
+	// Seconds, as stored.
+	now := time.Now().UTC().Truncate(time.Second)
+	op := &Operation{
+		ID:        id.New(),
+		Kind:      kind,
+		State:     StatePending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		owner:     owner,
+		tenant:    tenant.ID(ctx),
+		input:     in,
+	}
+	_, err = s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`INSERT INTO operations (id, kind, owner, tenant_id, state, input, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		op.ID, op.Kind, op.owner, op.tenant, op.State, string(in), now.Unix(), now.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("operations: create %s: %w", kind, err)
+	}
+	if _, err := s.queue.Enqueue(ctx, KindRun, runPayload{ID: op.ID}); err != nil {
+		return nil, fmt.Errorf("operations: create %s: %w", kind, err)
+	}
+	return op, nil
+}
+
+// Get returns the operation id of owner.
+func (s *Service) Get(ctx context.Context, owner, id string) (*Operation, error) {
+	op, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.owner != owner {
+		return nil, ErrNotFound
+	}
+	return op, nil
+}
+
+func (s *Service) load(ctx context.Context, id string) (*Operation, error) {
+	var (
+		op                 Operation
+		input              string
+		result, failure    sql.NullString
+		progress           sql.NullString
+		created, updatedAt int64
+	)
+	err := s.db.Writer(ctx).QueryRowContext(ctx, s.db.Rebind(
+		`SELECT id, kind, owner, tenant_id, state, input, result, error, progress, created_at, updated_at FROM operations WHERE id = ?`), id).
+		Scan(&op.ID, &op.Kind, &op.owner, &op.tenant, &op.State, &input, &result, &failure, &progress, &created, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("operations: get %s: %w", id, err)
+	}
+	op.input = json.RawMessage(input)
+	if result.Valid {
+		op.Result = json.RawMessage(result.String)
+	}
+	op.Error = failure.String
+	if progress.Valid {
+		op.Progress = json.RawMessage(progress.String)
+	}
+	op.CreatedAt = time.Unix(created, 0).UTC()
+	op.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+	return &op, nil
+}
+
+// Run is the jobs handler of KindRun.
+func (s *Service) Run(ctx context.Context, job *jobs.Job) error {
+	var p runPayload
+	if err := job.Decode(&p); err != nil {
+		return err
+	}
+	op, err := s.load(ctx, p.ID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if op.Done() {
+		return nil
+	}
+	s.mu.RLock()
+	fn := s.funcs[op.Kind]
+	s.mu.RUnlock()
+	if fn == nil {
+		return s.finish(ctx, op, StateFailed, nil, fmt.Sprintf("unknown operation %s", op.Kind))
+	}
+	if err := s.update(ctx, op.ID, StateRunning, nil, nil); err != nil {
+		return err
+	}
+
+	if op.tenant != "" {
+		ctx = tenant.WithTenant(ctx, &tenant.Tenant{ID: op.tenant})
+	}
+	result, err := safeRun(ctx, fn, op)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(result); err == nil {
+			return s.finish(ctx, op, StateSucceeded, data, "")
+		}
+	}
+	if job.Attempts < job.MaxAttempts && ctx.Err() == nil {
+		// Pending again until the job is retried.
+		if uerr := s.update(ctx, op.ID, StatePending, nil, nil); uerr != nil {
+			logging.FromContext(ctx).Warn("operation state not updated", "id", op.ID, "error", uerr)
+		}
+		return err
+	}
+	msg := "operation failed"
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) && appErr.Kind != apperror.KindInternal {
+		msg = appErr.Message
+	}
+	if ferr := s.finish(ctx, op, StateFailed, nil, msg); ferr != nil {
+		return errors.Join(err, ferr)
+	}
+	return err
+}
+
+func safeRun(ctx context.Context, fn Func, op *Operation) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return fn(ctx, op)
+}
+
+func (s *Service) finish(ctx context.Context, op *Operation, state string, result []byte, failure string) error {
+	var res, fail any
+	if result != nil {
+		res = string(result)
+	}
+	if failure != "" {
+		fail = failure
+	}
+	// The outcome is recorded even when the job ran out of time.
+	return s.update(context.WithoutCancel(ctx), op.ID, state, res, fail)
+}
+
+// Progress records v, which must marshal to JSON, as how far op got, for
+// clients polling it. It goes through the transaction of ctx, so that work
+// done in steps can checkpoint its progress with each step and, when its
+// job is retried, continue from op.Progress instead of starting over.
+func (s *Service) Progress(ctx context.Context, op *Operation, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("operations: progress %s: %w", op.ID, err)
+	}
+	if _, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`UPDATE operations SET progress = ?, updated_at = ? WHERE id = ?`), string(data), time.Now().Unix(), op.ID); err != nil {
+		return fmt.Errorf("operations: progress %s: %w", op.ID, err)
+	}
+	op.Progress = data
+	return nil
+}
+
+func (s *Service) update(ctx context.Context, id, state string, result, failure any) error {
+	_, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`UPDATE operations SET state = ?, result = ?, error = ?, updated_at = ? WHERE id = ?`),
+		state, result, failure, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("operations: update %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package outbox publishes domain events reliably. Instead of sending an
+// event to the broker next to a database write, which loses the event when
+// the process dies in between or publishes one for a write that is then
+// rolled back, the event is written to the outbox table through the
+// transaction of the write, and a relay publishes it to the bus once it is
+// committed. Delivery is at least once: an event whose publication was not
+// recorded is published again, keeping its message ID for consumers to
+// deduplicate.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/bus"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/journal"
+	"go-flylike-example/internal/store"
+)
+
+// lease is how long a relay owns the events it claimed; those of a relay
+// that died are published by another once it runs out.
+const lease = 30 * time.Second
+
+// Outbox writes events to the outbox table and relays them to the bus.
+type Outbox struct {
+	db     *store.Store
+	broker bus.Broker
+	cfg    config.Bus
+}
+
+// New returns an Outbox relaying to broker.
+func New(db *store.Store, broker bus.Broker, cfg config.Bus) *Outbox {
+	return &Outbox{db: db, broker: broker, cfg: cfg}
+}
+
+// Publish records data as an event of type event on topic, like
+// bus.Publish. It is written through the transaction ctx carries, and so
+// only published if that transaction commits.
+func (o *Outbox) Publish(ctx context.Context, topic, event, key string, data any) error {
+	m, err := bus.NewMessage(ctx, topic, event, key, data)
+	if err != nil {
+		return err
+	}
+	headers, err := json.Marshal(m.Headers)
+	if err != nil {
+		return fmt.Errorf("outbox: publish %s: %w", event, err)
+	}
+	_, err = o.db.Writer(ctx).ExecContext(ctx, o.db.Rebind(
+		`INSERT INTO outbox (id, topic, type, msg_key, tenant_id, headers, data, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		m.ID, m.Topic, m.Type, m.Key, m.Tenant, string(headers), string(m.Data), m.Time.UnixNano())
+	if err != nil {
+		return fmt.Errorf("outbox: publish %s: %w", event, err)
+	}
+	return nil
+}
+
+// Run relays committed events to the bus in the order they were written
+// until ctx is done. When the broker fails the relay backs off, and the
+// failed event and those after it are published again on the next round.
+func (o *Outbox) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := o.relay(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			failures = min(failures+1, 6)
+			delay := min(o.cfg.OutboxPollInterval<<failures, time.Minute)
+			slog.Warn("outbox relay failed", "error", err, "retry_in", delay.String())
+			timer.Reset(delay)
+		case n == o.cfg.OutboxBatch:
+			// More are likely waiting; look again immediately.
+			failures = 0
+			timer.Reset(0)
+		default:
+			failures = 0
+			timer.Reset(o.cfg.OutboxPollInterval)
+		}
+	}
+}
+
+// relay publishes one batch of events and returns how many it claimed.
+func (o *Outbox) relay(ctx context.Context) (int, error) {
+	batch, err := o.claim(ctx)
+	if err != nil || len(batch) == 0 {
+		return 0, err
+	}
+	for i, m := range batch {
+		if err := o.broker.Publish(ctx, m); err != nil {
+			o.release(batch[i:], err)
+			return len(batch), fmt.Errorf("outbox: publish %s: %w", m.ID, err)
+		}
+		// The event is out; should this fail, it is merely sent again.
+		if _, err := o.db.Writer(ctx).ExecContext(context.WithoutCancel(ctx), o.db.Rebind(
+			`UPDATE outbox SET delivered_at = ?, locked_until = NULL, last_error = NULL WHERE id = ?`),
+			time.Now().Unix(), m.ID); err != nil {
+			slog.Error("outbox delivery not recorded", "id", m.ID, "error", err)
+		}
+	}
+	return len(batch), nil
+}
+
+// claim reserves the oldest undelivered events, including those a dead
+// relay left behind, for lease.
+func (o *Outbox) claim(ctx context.Context) ([]*bus.Message, error) {
+	now := time.Now()
+	lock := ""
+	if o.db.Dialect() == store.Postgres {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+	rows, err := o.db.Writer(ctx).QueryContext(ctx, o.db.Rebind(
+		`UPDATE outbox SET locked_until = ?, attempts = attempts + 1
+		 WHERE id IN (
+			SELECT id FROM outbox
+			WHERE delivered_at IS NULL AND (locked_until IS NULL OR locked_until < ?)
+			ORDER BY created_at, id
+			LIMIT ?`+lock+`
+		 )
+		 RETURNING id, topic, type, msg_key, tenant_id, headers, data, created_at`),
+		now.Add(lease).Unix(), now.Unix(), o.cfg.OutboxBatch)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: claim: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []*bus.Message
+	for rows.Next() {
+		var (
+			m             bus.Message
+			headers, data string
+			created       int64
+		)
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Type, &m.Key, &m.Tenant, &headers, &data, &created); err != nil {
+			return nil, fmt.Errorf("outbox: claim: %w", err)
+		}
+		if err := json.Unmarshal([]byte(headers), &m.Headers); err != nil {
+			return nil, fmt.Errorf("outbox: claim %s: %w", m.ID, err)
+		}
+		m.Data = json.RawMessage(data)
+		m.Time = time.Unix(0, created).UTC()
+		batch = append(batch, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("outbox: claim: %w", err)
+	}
+	// RETURNING has no order of its own.
+	slices.SortFunc(batch, func(a, b *bus.Message) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return batch, nil
+}
+
+// Recover publishes again the events that a previous run of the instance
+// had handed to the in-process broker but no consumer had handled, which
+// the journal recorded, keeping their IDs. An event is recorded once per
+// consumer group, and goes to all of them again.
+func (o *Outbox) Recover(ctx context.Context, entries []journal.Entry) error {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if seen[e.ID] {
+			continue
+		}
+		seen[e.ID] = true
+		var m bus.Message
+		if err := json.Unmarshal(e.Detail, &m); err != nil {
+			return fmt.Errorf("outbox: recover %s: %w", e.ID, err)
+		}
+		res, err := o.db.Writer(ctx).ExecContext(ctx, o.db.Rebind(
+			`UPDATE outbox SET delivered_at = NULL, locked_until = NULL WHERE id = ?`), m.ID)
+		if err != nil {
+			return fmt.Errorf("outbox: recover %s: %w", m.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			continue
+		}
+		// Published to the bus directly, or cleaned up already.
+		headers, err := json.Marshal(m.Headers)
+		if err != nil {
+			return fmt.Errorf("outbox: recover %s: %w", m.ID, err)
+		}
+		if _, err := o.db.Writer(ctx).ExecContext(ctx, o.db.Rebind(
+			`INSERT INTO outbox (id, topic, type, msg_key, tenant_id, headers, data, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			m.ID, m.Topic, m.Type, m.Key, m.Tenant, string(headers), string(m.Data), m.Time.UnixNano()); err != nil {
+			return fmt.Errorf("outbox: recover %s: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// release hands events back for the next round after cause stopped the
+// relay.
+func (o *Outbox) release(batch []*bus.Message, cause error) {
+	args := []any{cause.Error()}
+	for _, m := range batch {
+		args = append(args, m.ID)
+	}
+	ctx := context.Background()
+	_, err := o.db.Writer(ctx).ExecContext(ctx, o.db.Rebind(
+		`UPDATE outbox SET locked_until = NULL, last_error = ? WHERE id IN (?`+strings.Repeat(", ?", len(batch)-1)+`)`),
+		args...)
+	if err != nil {
+		slog.Error("outbox release failed", "error", err)
+	}
+}
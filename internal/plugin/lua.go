@@ -0,0 +1,160 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// unsafeGlobals are the functions of the base library that reach beyond
+// the script: loading code from files or strings, and modules.
+var unsafeGlobals = []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage"}
+
+// luaPlugin is a Lua script defining the global functions on_request and
+// on_response, which reach the host API through the host table. A Lua
+// state runs one call at a time, so the plugin keeps a pool of them, each
+// having run the script once.
+type luaPlugin struct {
+	proto *lua.FunctionProto
+	pool  sync.Pool
+}
+
+// luaState is a Lua state of the pool and the call it is running.
+type luaState struct {
+	L    *lua.LState
+	call *Call
+}
+
+func loadLua(file string) (*luaPlugin, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	chunk, err := parse.Parse(f, file)
+	if err != nil {
+		return nil, err
+	}
+	proto, err := lua.Compile(chunk, file)
+	if err != nil {
+		return nil, err
+	}
+	p := &luaPlugin{proto: proto}
+	// Run the script once now, so that its errors surface at startup.
+	st, err := p.newState()
+	if err != nil {
+		return nil, err
+	}
+	p.pool.Put(st)
+	return p, nil
+}
+
+func (p *luaPlugin) newState() (*luaState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true, CallStackSize: 256, RegistrySize: 1024 * 16})
+	for name, open := range map[string]lua.LGFunction{
+		lua.BaseLibName:   lua.OpenBase,
+		lua.TabLibName:    lua.OpenTable,
+		lua.StringLibName: lua.OpenString,
+		lua.MathLibName:   lua.OpenMath,
+	} {
+		L.Push(L.NewFunction(open))
+		L.Push(lua.LString(name))
+		L.Call(1, 0)
+	}
+	for _, name := range unsafeGlobals {
+		L.SetGlobal(name, lua.LNil)
+	}
+	st := &luaState{L: L}
+	L.SetGlobal("host", L.SetFuncs(L.NewTable(), st.hostAPI()))
+
+	L.Push(L.NewFunctionFromProto(p.proto))
+	if err := L.PCall(0, lua.MultRet, nil); err != nil {
+		L.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+func (st *luaState) hostAPI() map[string]lua.LGFunction {
+	return map[string]lua.LGFunction{
+		"header": func(L *lua.LState) int {
+			v, ok := st.call.Header(L.CheckString(1))
+			if !ok {
+				L.Push(lua.LNil)
+			} else {
+				L.Push(lua.LString(v))
+			}
+			return 1
+		},
+		"method": func(L *lua.LState) int {
+			L.Push(lua.LString(st.call.Method()))
+			return 1
+		},
+		"path": func(L *lua.LState) int {
+			L.Push(lua.LString(st.call.Path()))
+			return 1
+		},
+		"status": func(L *lua.LState) int {
+			L.Push(lua.LNumber(st.call.Status()))
+			return 1
+		},
+		"set_status": func(L *lua.LState) int {
+			if err := st.call.SetStatus(L.CheckInt(1), L.OptString(2, "")); err != nil {
+				L.RaiseError("%s", err.Error())
+			}
+			return 0
+		},
+		"set_header": func(L *lua.LState) int {
+			if err := st.call.SetHeader(L.CheckString(1), L.CheckString(2)); err != nil {
+				L.RaiseError("%s", err.Error())
+			}
+			return 0
+		},
+		"log": func(L *lua.LState) int {
+			st.call.Log(L.CheckString(1), L.CheckString(2))
+			return 0
+		},
+	}
+}
+
+func (p *luaPlugin) run(ctx context.Context, call *Call, phase Phase) error {
+	st, ok := p.pool.Get().(*luaState)
+	if !ok {
+		var err error
+		if st, err = p.newState(); err != nil {
+			return err
+		}
+	}
+	fn := st.L.GetGlobal("on_" + string(phase))
+	if fn.Type() != lua.LTFunction {
+		p.pool.Put(st)
+		return nil
+	}
+
+	st.call = call
+	st.L.SetContext(ctx)
+	err := st.L.CallByParam(lua.P{Fn: fn, Protect: true})
+	st.L.RemoveContext()
+	st.call = nil
+	if err != nil {
+		// A state interrupted mid-call is not trusted again.
+		st.L.Close()
+		return fmt.Errorf("on_%s: %w", phase, err)
+	}
+	p.pool.Put(st)
+	return nil
+}
+
+func (p *luaPlugin) close(context.Context) error {
+	for {
+		st, ok := p.pool.Get().(*luaState)
+		if !ok {
+			return nil
+		}
+		st.L.Close()
+	}
+}
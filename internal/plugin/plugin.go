@@ -0,0 +1,211 @@
+// Package plugin runs request hooks that operators supply as Lua scripts or
+// WebAssembly modules, so that custom routing or admission rules need no
+// fork of the binary. A plugin may define a request hook, run before the
+// handler, and a response hook, run after it. Both see the request through
+// a small host API: they can read its method, path and headers and the
+// response status, write logs, and the request hook can set response
+// headers and answer the request itself by setting a status. Plugins have
+// no file, network or process access, and every hook call is bounded by a
+// timeout; a request hook that fails fails the request, so that a broken
+// admission rule never lets requests through.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/validation"
+)
+
+// plugin is a loaded script or module.
+type plugin interface {
+	// run calls the hook of phase, if the plugin defines one.
+	run(ctx context.Context, call *Call, phase Phase) error
+	close(ctx context.Context) error
+}
+
+// Phase is when a hook runs.
+type Phase string
+
+const (
+	// PhaseRequest hooks run before the handler.
+	PhaseRequest Phase = "request"
+	// PhaseResponse hooks run after it.
+	PhaseResponse Phase = "response"
+)
+
+var errStatusTooLate = errors.New("status can only be set by a request hook")
+
+// Call is the host API of one hook call.
+type Call struct {
+	c      *gin.Context
+	plugin string
+	phase  Phase
+	// status and message are what the hook answered the request with.
+	status  int
+	message string
+}
+
+// Header returns the named request header.
+func (call *Call) Header(name string) (string, bool) {
+	v := call.c.Request.Header.Values(name)
+	if len(v) == 0 {
+		return "", false
+	}
+	return strings.Join(v, ", "), true
+}
+
+// Method returns the request method.
+func (call *Call) Method() string { return call.c.Request.Method }
+
+// Path returns the request path.
+func (call *Call) Path() string { return call.c.Request.URL.Path }
+
+// Status returns the response status, 0 before the handler ran.
+func (call *Call) Status() int {
+	if call.phase == PhaseRequest {
+		return 0
+	}
+	return call.c.Writer.Status()
+}
+
+// SetStatus answers the request with code instead of running the handler,
+// with message as the detail of an error status.
+func (call *Call) SetStatus(code int, message string) error {
+	if call.phase != PhaseRequest {
+		return errStatusTooLate
+	}
+	if code < 200 || code > 599 {
+		return fmt.Errorf("invalid status %d", code)
+	}
+	call.status, call.message = code, message
+	return nil
+}
+
+// SetHeader sets a response header.
+func (call *Call) SetHeader(name, value string) error {
+	if call.phase != PhaseRequest {
+		return errors.New("headers can only be set by a request hook")
+	}
+	call.c.Header(name, value)
+	return nil
+}
+
+// Log writes msg at level, one of debug, info, warn and error, to the log
+// of the request.
+func (call *Call) Log(level, msg string) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		l = slog.LevelInfo
+	}
+	slog.Log(call.c.Request.Context(), l, msg, "plugin", call.plugin, "phase", call.phase)
+}
+
+// Plugins are the plugins loaded from the configuration.
+type Plugins struct {
+	names   []string
+	loaded  []plugin
+	wasm    *wasmRuntime
+	timeout time.Duration
+}
+
+// Load loads the plugins of cfg, or returns nil when there are none.
+func Load(ctx context.Context, cfg config.Plugins) (*Plugins, error) {
+	if len(cfg.Files) == 0 {
+		return nil, nil
+	}
+	p := &Plugins{timeout: cfg.Timeout}
+	for _, file := range cfg.Files {
+		var (
+			pl  plugin
+			err error
+		)
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".lua":
+			pl, err = loadLua(file)
+		case ".wasm":
+			if p.wasm == nil {
+				if p.wasm, err = newWasmRuntime(ctx, cfg.MaxMemory); err != nil {
+					break
+				}
+			}
+			pl, err = p.wasm.load(ctx, file)
+		default:
+			err = errors.New("unsupported plugin type")
+		}
+		if err != nil {
+			_ = p.Close(ctx)
+			return nil, fmt.Errorf("plugin: load %s: %w", file, err)
+		}
+		p.names = append(p.names, filepath.Base(file))
+		p.loaded = append(p.loaded, pl)
+	}
+	slog.Info("plugins loaded", "plugins", p.names)
+	return p, nil
+}
+
+// Middleware runs the request hooks in the order the plugins were listed,
+// stopping at the first that answers the request, and the response hooks
+// in reverse once the handler is done.
+func (p *Plugins) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for i, pl := range p.loaded {
+			call := &Call{c: c, plugin: p.names[i], phase: PhaseRequest}
+			if err := p.run(c, pl, call); err != nil {
+				c.Error(apperror.Internal(fmt.Errorf("plugin %s: %w", call.plugin, err)))
+				c.Abort()
+				return
+			}
+			if call.status == 0 {
+				continue
+			}
+			if call.status >= http.StatusBadRequest {
+				msg := call.message
+				if msg == "" {
+					msg = http.StatusText(call.status)
+				}
+				validation.Abort(c, validation.NewProblem(call.status, msg))
+			} else {
+				c.AbortWithStatus(call.status)
+			}
+			return
+		}
+
+		c.Next()
+
+		for i := len(p.loaded) - 1; i >= 0; i-- {
+			call := &Call{c: c, plugin: p.names[i], phase: PhaseResponse}
+			if err := p.run(c, p.loaded[i], call); err != nil {
+				slog.WarnContext(c.Request.Context(), "plugin response hook failed", "plugin", call.plugin, "error", err)
+			}
+		}
+	}
+}
+
+func (p *Plugins) run(c *gin.Context, pl plugin, call *Call) error {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), p.timeout)
+	defer cancel()
+	return pl.run(ctx, call, call.phase)
+}
+
+// Close releases the plugins.
+func (p *Plugins) Close(ctx context.Context) error {
+	var errs []error
+	for _, pl := range p.loaded {
+		errs = append(errs, pl.close(ctx))
+	}
+	if p.wasm != nil {
+		errs = append(errs, p.wasm.rt.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
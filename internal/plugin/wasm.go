@@ -0,0 +1,198 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmPageSize is the size of a page of WebAssembly memory.
+const wasmPageSize = 64 << 10
+
+// WASM log levels, as passed to host.log.
+var wasmLevels = []string{"debug", "info", "warn", "error"}
+
+type callKey struct{}
+
+// wasmRuntime compiles and runs the WASM plugins. Modules import the
+// host API from the module "host"; strings are passed as a pointer and a
+// length into the memory of the module, and host functions returning one
+// copy it to a buffer the module provides, returning its full length, or
+// -1 when there is none:
+//
+//	header(name_ptr, name_len, buf_ptr, buf_len i32) i32
+//	method(buf_ptr, buf_len i32) i32
+//	path(buf_ptr, buf_len i32) i32
+//	status() i32
+//	set_status(code, msg_ptr, msg_len i32)
+//	set_header(name_ptr, name_len, value_ptr, value_len i32)
+//	log(level, msg_ptr, msg_len i32)
+//
+// where level is 0 for debug up to 3 for error. Hooks are the exports
+// on_request and on_response, taking and returning nothing. WASI is
+// provided without files or environment, so that reactors built by TinyGo
+// or by Go for wasip1 work.
+type wasmRuntime struct {
+	rt wazero.Runtime
+}
+
+func newWasmRuntime(ctx context.Context, maxMemory int) (*wasmRuntime, error) {
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(maxMemory/wasmPageSize)).
+		WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		_ = rt.Close(ctx)
+		return nil, err
+	}
+	_, err := rt.NewHostModuleBuilder("host").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, namePtr, nameLen, buf, bufLen uint32) int32 {
+		v, ok := current(ctx).Header(read(m, namePtr, nameLen))
+		if !ok {
+			return -1
+		}
+		return write(m, buf, bufLen, v)
+	}).Export("header").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+		return write(m, buf, bufLen, current(ctx).Method())
+	}).Export("method").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, buf, bufLen uint32) int32 {
+		return write(m, buf, bufLen, current(ctx).Path())
+	}).Export("path").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context) int32 {
+		return int32(current(ctx).Status())
+	}).Export("status").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, code int32, msgPtr, msgLen uint32) {
+		if err := current(ctx).SetStatus(int(code), read(m, msgPtr, msgLen)); err != nil {
+			panic(err)
+		}
+	}).Export("set_status").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, namePtr, nameLen, valuePtr, valueLen uint32) {
+		if err := current(ctx).SetHeader(read(m, namePtr, nameLen), read(m, valuePtr, valueLen)); err != nil {
+			panic(err)
+		}
+	}).Export("set_header").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, level int32, msgPtr, msgLen uint32) {
+		name := "info"
+		if level >= 0 && int(level) < len(wasmLevels) {
+			name = wasmLevels[level]
+		}
+		current(ctx).Log(name, read(m, msgPtr, msgLen))
+	}).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		_ = rt.Close(ctx)
+		return nil, err
+	}
+	return &wasmRuntime{rt: rt}, nil
+}
+
+func current(ctx context.Context) *Call {
+	return ctx.Value(callKey{}).(*Call)
+}
+
+func read(m api.Module, ptr, n uint32) string {
+	b, ok := m.Memory().Read(ptr, n)
+	if !ok {
+		panic(fmt.Errorf("string at %d+%d out of memory", ptr, n))
+	}
+	return string(b)
+}
+
+func write(m api.Module, buf, bufLen uint32, s string) int32 {
+	n := min(uint32(len(s)), bufLen)
+	if !m.Memory().Write(buf, []byte(s[:n])) {
+		panic(fmt.Errorf("buffer at %d+%d out of memory", buf, n))
+	}
+	return int32(len(s))
+}
+
+// maxIdleInstances bounds the instances a WASM plugin keeps between calls.
+const maxIdleInstances = 64
+
+// wasmPlugin is a compiled module. Instances run one call at a time and
+// are kept for the next, so a module's globals may outlive a call but must
+// not be relied upon. Unlike a sync.Pool, idle holds on to them until they
+// are closed: the runtime would keep them alive anyway.
+type wasmPlugin struct {
+	rt       wazero.Runtime
+	compiled wazero.CompiledModule
+	hooks    map[Phase]bool
+	idle     chan api.Module
+}
+
+func (w *wasmRuntime) load(ctx context.Context, file string) (*wasmPlugin, error) {
+	bin, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := w.rt.CompileModule(ctx, bin)
+	if err != nil {
+		return nil, err
+	}
+	p := &wasmPlugin{rt: w.rt, compiled: compiled, hooks: map[Phase]bool{}, idle: make(chan api.Module, maxIdleInstances)}
+	for _, phase := range []Phase{PhaseRequest, PhaseResponse} {
+		_, p.hooks[phase] = compiled.ExportedFunctions()["on_"+string(phase)]
+	}
+	if !p.hooks[PhaseRequest] && !p.hooks[PhaseResponse] {
+		_ = compiled.Close(ctx)
+		return nil, errors.New("module exports neither on_request nor on_response")
+	}
+	// Instantiate once now, so that a module that cannot start fails at
+	// startup.
+	m, err := p.instantiate(ctx)
+	if err != nil {
+		_ = compiled.Close(ctx)
+		return nil, err
+	}
+	p.idle <- m
+	return p, nil
+}
+
+func (p *wasmPlugin) instantiate(ctx context.Context) (api.Module, error) {
+	return p.rt.InstantiateModule(context.WithoutCancel(ctx), p.compiled,
+		wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+}
+
+func (p *wasmPlugin) run(ctx context.Context, call *Call, phase Phase) error {
+	if !p.hooks[phase] {
+		return nil
+	}
+	var m api.Module
+	select {
+	case m = <-p.idle:
+	default:
+		var err error
+		if m, err = p.instantiate(ctx); err != nil {
+			return err
+		}
+	}
+	_, err := m.ExportedFunction("on_" + string(phase)).Call(context.WithValue(ctx, callKey{}, call))
+	if err != nil {
+		// The instance is closed if the call timed out, and in an unknown
+		// state if it trapped.
+		_ = m.Close(context.WithoutCancel(ctx))
+		return fmt.Errorf("on_%s: %w", phase, err)
+	}
+	select {
+	case p.idle <- m:
+	default:
+		_ = m.Close(context.WithoutCancel(ctx))
+	}
+	return nil
+}
+
+func (p *wasmPlugin) close(ctx context.Context) error {
+	for {
+		select {
+		case m := <-p.idle:
+			_ = m.Close(ctx)
+		default:
+			return p.compiled.Close(ctx)
+		}
+	}
+}
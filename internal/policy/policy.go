@@ -0,0 +1,198 @@
+// Package policy attaches middleware to routes from the configuration
+// rather than code: each config.Policy names a path pattern and the
+// requirements, such as authentication, a permission or a stricter rate
+// limit, that matching requests must go through. The policies are
+// compiled once at startup.
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/cors"
+	"go-flylike-example/internal/httpcache"
+	"go-flylike-example/internal/ratelimit"
+	"go-flylike-example/internal/rbac"
+)
+
+const chainKey = "policy.chain"
+
+// Deps are the services requirements are built on. Either may be nil when
+// no policy needs it.
+type Deps struct {
+	Limiter ratelimit.Limiter
+	Cache   *httpcache.Cache
+}
+
+// Engine runs the policies matching each request.
+type Engine struct {
+	rules []rule
+	// slots is the most handlers a request can go through: that of every
+	// policy together.
+	slots int
+}
+
+type rule struct {
+	segments []string
+	subtree  bool
+	methods  map[string]bool
+	handlers []gin.HandlerFunc
+}
+
+// New compiles the policies of cfg.
+func New(cfg *config.Config, d Deps) (*Engine, error) {
+	e := &Engine{}
+	for _, p := range cfg.Policies {
+		r, err := compile(cfg, p, d)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", p.Match, err)
+		}
+		e.rules = append(e.rules, r)
+		e.slots += len(r.handlers)
+	}
+	return e, nil
+}
+
+func compile(cfg *config.Config, p config.Policy, d Deps) (rule, error) {
+	r := rule{segments: strings.Split(strings.TrimPrefix(p.Match, "/"), "/")}
+	if last := len(r.segments) - 1; r.segments[last] == "*" {
+		r.segments, r.subtree = r.segments[:last], true
+	}
+	for _, seg := range r.segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return rule{}, err
+		}
+	}
+	if len(p.Methods) > 0 {
+		r.methods = make(map[string]bool, len(p.Methods))
+		for _, m := range p.Methods {
+			r.methods[strings.ToUpper(m)] = true
+		}
+	}
+	for _, req := range p.Require {
+		h, err := requirement(cfg, req, d)
+		if err != nil {
+			return rule{}, err
+		}
+		r.handlers = append(r.handlers, h)
+	}
+	return r, nil
+}
+
+// requirement builds the handler of one entry of config.Policy.Require.
+func requirement(cfg *config.Config, req string, d Deps) (gin.HandlerFunc, error) {
+	kind, arg, _ := strings.Cut(req, ":")
+	switch kind {
+	case "auth":
+		return auth.Required(), nil
+	case "rbac":
+		return rbac.Require(arg), nil
+	case "scope":
+		return apikeys.RequireScope(arg), nil
+	case "ratelimit":
+		p, ok := cfg.RateLimit.Policies[arg]
+		if !ok || d.Limiter == nil {
+			return nil, fmt.Errorf("rate limit policy %q unavailable", arg)
+		}
+		prefix := "policy:" + arg + ":"
+		return ratelimit.PolicyMiddleware(d.Limiter, func(c *gin.Context) string {
+			return prefix + ratelimit.ByToken(c)
+		}, ratelimit.Policy{Rate: p.Rate, Burst: p.Burst}), nil
+	case "cors":
+		p, ok := cfg.CORS.Policies[arg]
+		if !ok {
+			return nil, fmt.Errorf("unknown cors policy %q", arg)
+		}
+		c, err := cors.New(p)
+		if err != nil {
+			return nil, err
+		}
+		return c.Middleware(), nil
+	case "cache":
+		if d.Cache == nil {
+			return nil, fmt.Errorf("cache requires the response cache")
+		}
+		var ttl time.Duration
+		if arg != "" {
+			var err error
+			if ttl, err = time.ParseDuration(arg); err != nil {
+				return nil, err
+			}
+		}
+		return d.Cache.Handler(ttl), nil
+	}
+	return nil, fmt.Errorf("unknown requirement %q", req)
+}
+
+// Middleware returns the handlers to install on the router, after
+// authentication so that the requirements see its result. Gin chains are
+// fixed when routes are registered, so the engine takes a number of
+// slots: the first finds the policies matching the request, and slot i
+// then runs their i-th handler, or nothing once they are exhausted.
+func (e *Engine) Middleware() []gin.HandlerFunc {
+	if e.slots == 0 {
+		return nil
+	}
+	slots := make([]gin.HandlerFunc, e.slots)
+	slots[0] = func(c *gin.Context) {
+		chain := e.match(c.Request)
+		c.Set(chainKey, chain)
+		run(c, chain, 0)
+	}
+	for i := 1; i < e.slots; i++ {
+		slots[i] = func(c *gin.Context) {
+			v, _ := c.Get(chainKey)
+			chain, _ := v.([]gin.HandlerFunc)
+			run(c, chain, i)
+		}
+	}
+	return slots
+}
+
+func run(c *gin.Context, chain []gin.HandlerFunc, i int) {
+	if i < len(chain) {
+		chain[i](c)
+	}
+}
+
+// match returns the handlers of every policy matching r, in the order the
+// policies are configured.
+func (e *Engine) match(r *http.Request) []gin.HandlerFunc {
+	var chain []gin.HandlerFunc
+	segments := strings.Split(strings.TrimPrefix(path.Clean(r.URL.Path), "/"), "/")
+	for _, rule := range e.rules {
+		if rule.methods != nil && !rule.methods[r.Method] {
+			continue
+		}
+		if rule.matches(segments) {
+			chain = append(chain, rule.handlers...)
+		}
+	}
+	return chain
+}
+
+// matches reports whether the request path, split into segments, falls
+// under the rule. A subtree rule needs at least one segment below its
+// prefix.
+func (r rule) matches(segments []string) bool {
+	if len(segments) < len(r.segments) || (!r.subtree && len(segments) != len(r.segments)) {
+		return false
+	}
+	if r.subtree && (len(segments) == len(r.segments) || segments[len(r.segments)] == "") {
+		return false
+	}
+	for i, seg := range r.segments {
+		if ok, _ := path.Match(seg, segments[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
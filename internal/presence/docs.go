@@ -0,0 +1,16 @@
+package presence
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{ID: "listPresence", Method: http.MethodGet, Path: "/:channel", Tags: []string{"presence"}, Summary: "List the users present in a channel", Auth: true,
+			Description: "Users with a WebSocket or SSE stream open on the channel, or seen on one within the presence TTL.",
+			Response:    openapi.Envelope([]Member{}), Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	}
+}
@@ -0,0 +1,28 @@
+package presence
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/render"
+)
+
+// Register mounts the presence queries on g:
+//
+//	GET /:channel   users present in channel, by user
+func (s *Service) Register(g *gin.RouterGroup) {
+	g.Use(auth.Required())
+	g.GET("/:channel", s.handleMembers)
+}
+
+func (s *Service) handleMembers(c *gin.Context) {
+	list, err := s.Members(c.Request.Context(), c.Param("channel"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "presence listed"), list))
+}
@@ -0,0 +1,145 @@
+// Package presence tracks which users are connected to the realtime
+// endpoints, per channel. Every open WebSocket or SSE stream that names a
+// channel keeps its user present with a heartbeat; a user leaves with their
+// last connection, or once none was seen for the TTL, for example after an
+// instance crashed. Joins and leaves are published to the message bus.
+package presence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/bus"
+)
+
+// Topic is where joins and leaves are published, as EventJoined and
+// EventLeft messages carrying a Change keyed by channel.
+const (
+	Topic       = "presence"
+	EventJoined = "presence.joined"
+	EventLeft   = "presence.left"
+)
+
+// ErrInvalidChannel is returned for channel names that are empty, too long
+// or hold other characters than letters, digits, '.', '_' and '-'.
+var ErrInvalidChannel = apperror.BadRequest("invalid channel name")
+
+var channelName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Change is a user joining or leaving a channel.
+type Change struct {
+	Channel string    `json:"channel"`
+	User    string    `json:"user"`
+	Time    time.Time `json:"time"`
+}
+
+// Service records presence in a Store and announces changes on the bus.
+type Service struct {
+	store  Store
+	broker bus.Broker
+	ttl    time.Duration
+}
+
+// New returns a Service. store must have been made with the same ttl.
+func New(store Store, broker bus.Broker, ttl time.Duration) *Service {
+	return &Service{store: store, broker: broker, ttl: ttl}
+}
+
+// Join makes user present in channel until leave is called, refreshing the
+// presence every third of the TTL meanwhile. It implements
+// realtime.Tracker.
+func (s *Service) Join(ctx context.Context, channel, user string) (leave func(), err error) {
+	if !channelName.MatchString(channel) {
+		return nil, ErrInvalidChannel
+	}
+	// The heartbeats outlive the request but keep its values for logging.
+	ctx = context.WithoutCancel(ctx)
+	conn := newConnID()
+	s.touch(ctx, channel, user, conn)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.touch(ctx, channel, user, conn)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		left, err := s.store.Leave(ctx, channel, user, conn, time.Now())
+		if err != nil {
+			slog.WarnContext(ctx, "presence not updated", "channel", channel, "error", err)
+			return
+		}
+		if left {
+			s.publish(ctx, EventLeft, Change{Channel: channel, User: user, Time: time.Now().UTC()})
+		}
+	}, nil
+}
+
+func (s *Service) touch(ctx context.Context, channel, user, conn string) {
+	now := time.Now()
+	joined, err := s.store.Touch(ctx, channel, user, conn, now)
+	if err != nil {
+		slog.WarnContext(ctx, "presence not updated", "channel", channel, "error", err)
+		return
+	}
+	if joined {
+		s.publish(ctx, EventJoined, Change{Channel: channel, User: user, Time: now.UTC()})
+	}
+}
+
+func (s *Service) publish(ctx context.Context, event string, ch Change) {
+	if err := bus.Publish(ctx, s.broker, Topic, event, ch.Channel, ch); err != nil {
+		slog.WarnContext(ctx, "presence change not published", "event", event, "error", err)
+	}
+}
+
+// Members lists the users present in channel.
+func (s *Service) Members(ctx context.Context, channel string) ([]Member, error) {
+	if !channelName.MatchString(channel) {
+		return nil, ErrInvalidChannel
+	}
+	return s.store.Members(ctx, channel, time.Now())
+}
+
+// Run expires lapsed users every half TTL and publishes their leaving,
+// until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			gone, err := s.store.Expire(ctx, time.Now())
+			if err != nil {
+				slog.WarnContext(ctx, "presence expiry failed", "error", err)
+			}
+			for _, ch := range gone {
+				s.publish(ctx, EventLeft, ch)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func newConnID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,253 @@
+package presence
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Member is a user present in a channel.
+type Member struct {
+	User     string    `json:"user"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Store remembers the connections of each user in each channel. A user is
+// present while one of their connections was seen within the TTL the store
+// was made with.
+type Store interface {
+	// Touch records that conn of user was seen in channel at now, and
+	// reports whether that made the user join.
+	Touch(ctx context.Context, channel, user, conn string, now time.Time) (bool, error)
+	// Leave forgets conn, and reports whether the user left with it.
+	Leave(ctx context.Context, channel, user, conn string, now time.Time) (bool, error)
+	// Members lists the users present in channel.
+	Members(ctx context.Context, channel string, now time.Time) ([]Member, error)
+	// Expire removes the users none of whose connections was seen within
+	// the TTL, such as those of a crashed instance, and returns them. Each
+	// is returned once, however many instances expire.
+	Expire(ctx context.Context, now time.Time) ([]Change, error)
+}
+
+// MemoryStore keeps presence in process memory, for single instances.
+type MemoryStore struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	// channels maps channel, user and connection to when it was seen.
+	channels map[string]map[string]map[string]time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, channels: map[string]map[string]map[string]time.Time{}}
+}
+
+// Touch implements Store.
+func (s *MemoryStore) Touch(_ context.Context, channel, user, conn string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, ok := s.channels[channel]
+	if !ok {
+		users = map[string]map[string]time.Time{}
+		s.channels[channel] = users
+	}
+	conns, ok := users[user]
+	if !ok {
+		conns = map[string]time.Time{}
+		users[user] = conns
+	}
+	conns[conn] = now
+	return !ok, nil
+}
+
+// Leave implements Store.
+func (s *MemoryStore) Leave(_ context.Context, channel, user, conn string, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conns, ok := s.channels[channel][user]
+	if !ok {
+		return false, nil
+	}
+	delete(conns, conn)
+	for _, seen := range conns {
+		if now.Sub(seen) <= s.ttl {
+			return false, nil
+		}
+	}
+	s.remove(channel, user)
+	return true, nil
+}
+
+func (s *MemoryStore) remove(channel, user string) {
+	delete(s.channels[channel], user)
+	if len(s.channels[channel]) == 0 {
+		delete(s.channels, channel)
+	}
+}
+
+// Members implements Store.
+func (s *MemoryStore) Members(_ context.Context, channel string, now time.Time) ([]Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Member{}
+	for user, conns := range s.channels[channel] {
+		m := Member{User: user}
+		for _, seen := range conns {
+			if seen.After(m.LastSeen) {
+				m.LastSeen = seen
+			}
+		}
+		if now.Sub(m.LastSeen) <= s.ttl {
+			list = append(list, m)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].User < list[j].User })
+	return list, nil
+}
+
+// Expire implements Store.
+func (s *MemoryStore) Expire(_ context.Context, now time.Time) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var gone []Change
+	for channel, users := range s.channels {
+	user:
+		for user, conns := range users {
+			for _, seen := range conns {
+				if now.Sub(seen) <= s.ttl {
+					continue user
+				}
+			}
+			s.remove(channel, user)
+			gone = append(gone, Change{Channel: channel, User: user, Time: now.UTC()})
+		}
+	}
+	return gone, nil
+}
+
+var touch = redis.NewScript(`
+local prev = redis.call('ZSCORE', KEYS[1], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('SADD', KEYS[3], ARGV[5])
+if prev then
+  return 0
+end
+return 1
+`)
+
+var leave = redis.NewScript(`
+redis.call('HDEL', KEYS[2], ARGV[2])
+for _, seen in ipairs(redis.call('HVALS', KEYS[2])) do
+  if tonumber(seen) >= tonumber(ARGV[3]) then
+    return 0
+  end
+end
+redis.call('DEL', KEYS[2])
+return redis.call('ZREM', KEYS[1], ARGV[1])
+`)
+
+var expire = redis.NewScript(`
+local gone = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', '(' .. ARGV[1])
+for _, user in ipairs(gone) do
+  redis.call('ZREM', KEYS[1], user)
+  redis.call('DEL', ARGV[2] .. user)
+end
+if redis.call('ZCARD', KEYS[1]) == 0 then
+  redis.call('SREM', KEYS[2], ARGV[3])
+end
+return gone
+`)
+
+// RedisStore shares presence between instances through Redis: a sorted
+// set of users per channel, scored by when each was last seen, a hash per
+// user and channel of when each connection was, and a set of the channels
+// for Expire.
+type RedisStore struct {
+	client redis.Cmdable
+	ttl    time.Duration
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, ttl: ttl, prefix: "presence:"}
+}
+
+func (s *RedisStore) users(channel string) string { return s.prefix + "users:" + channel }
+func (s *RedisStore) channels() string            { return s.prefix + "channels" }
+
+// conns is the hash of user in channel. Channel names hold no colon, so
+// keys of different channels cannot collide.
+func (s *RedisStore) conns(channel, user string) string {
+	return s.prefix + "conns:" + channel + ":" + user
+}
+
+// Touch implements Store. Keys outlive the TTL a little so that Expire
+// still finds the users of a crashed instance.
+func (s *RedisStore) Touch(ctx context.Context, channel, user, conn string, now time.Time) (bool, error) {
+	joined, err := touch.Run(ctx, s.client,
+		[]string{s.users(channel), s.conns(channel, user), s.channels()},
+		user, conn, now.UnixMilli(), (3 * s.ttl).Milliseconds(), channel).Int()
+	if err != nil {
+		return false, fmt.Errorf("presence: redis touch: %w", err)
+	}
+	return joined == 1, nil
+}
+
+// Leave implements Store.
+func (s *RedisStore) Leave(ctx context.Context, channel, user, conn string, now time.Time) (bool, error) {
+	left, err := leave.Run(ctx, s.client, []string{s.users(channel), s.conns(channel, user)},
+		user, conn, now.Add(-s.ttl).UnixMilli()).Int()
+	if err != nil {
+		return false, fmt.Errorf("presence: redis leave: %w", err)
+	}
+	return left == 1, nil
+}
+
+// Members implements Store.
+func (s *RedisStore) Members(ctx context.Context, channel string, now time.Time) ([]Member, error) {
+	res, err := s.client.ZRangeByScoreWithScores(ctx, s.users(channel), &redis.ZRangeBy{
+		Min: strconv.FormatInt(now.Add(-s.ttl).UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("presence: redis members: %w", err)
+	}
+	list := make([]Member, 0, len(res))
+	for _, z := range res {
+		user, _ := z.Member.(string)
+		list = append(list, Member{User: user, LastSeen: time.UnixMilli(int64(z.Score)).UTC()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].User < list[j].User })
+	return list, nil
+}
+
+// Expire implements Store.
+func (s *RedisStore) Expire(ctx context.Context, now time.Time) ([]Change, error) {
+	channels, err := s.client.SMembers(ctx, s.channels()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("presence: redis expire: %w", err)
+	}
+	cutoff := now.Add(-s.ttl).UnixMilli()
+	var gone []Change
+	for _, channel := range channels {
+		users, err := expire.Run(ctx, s.client, []string{s.users(channel), s.channels()},
+			cutoff, s.conns(channel, ""), channel).StringSlice()
+		if err != nil {
+			return gone, fmt.Errorf("presence: redis expire: %w", err)
+		}
+		for _, user := range users {
+			gone = append(gone, Change{Channel: channel, User: user, Time: now.UTC()})
+		}
+	}
+	return gone, nil
+}
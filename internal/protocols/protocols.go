@@ -0,0 +1,86 @@
+// Package protocols selects the HTTP versions the API is served with:
+// HTTP/1.1 always, HTTP/2 over TLS, HTTP/2 without TLS (h2c) for internal
+// traffic, and experimentally HTTP/3 over QUIC, which browsers and mobile
+// clients discover through the Alt-Svc header of the TCP responses.
+package protocols
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+
+	"go-flylike-example/internal/config"
+)
+
+// Apply sets the protocols srv accepts on TCP. h2c takes prior knowledge
+// or the HTTP/1.1 Upgrade handshake, so plain HTTP/1.1 clients are served
+// as before.
+func Apply(srv *http.Server, cfg config.Protocols) {
+	if !cfg.H2C {
+		return
+	}
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	srv.Protocols = p
+}
+
+// NewHTTP3 returns an HTTP/3 server mirroring srv, which must be set up for
+// TLS, or nil when cfg leaves HTTP/3 off. It listens on the UDP port of
+// cfg.HTTP3Addr, or of srv.Addr when that is empty.
+func NewHTTP3(srv *http.Server, cfg config.Protocols, logger *slog.Logger) *http3.Server {
+	if !cfg.HTTP3 {
+		return nil
+	}
+	addr := cfg.HTTP3Addr
+	if addr == "" {
+		addr = srv.Addr
+	}
+	return &http3.Server{
+		Addr:           addr,
+		Handler:        srv.Handler,
+		TLSConfig:      http3.ConfigureTLSConfig(srv.TLSConfig),
+		QUICConfig:     &quic.Config{MaxIdleTimeout: srv.IdleTimeout},
+		IdleTimeout:    srv.IdleTimeout,
+		MaxHeaderBytes: srv.MaxHeaderBytes,
+		Logger:         logger,
+	}
+}
+
+// AltSvc advertises h3 on the HTTPS responses of next, so that clients
+// switch to HTTP/3 on their next connection. Until h3 listens nothing is
+// advertised.
+func AltSvc(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && r.ProtoMajor < 3 {
+			_ = h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Shutdown returns a stop function for h3. After the GOAWAY clients close
+// their connections once their requests are done, but those of clients
+// that went away, common on lossy mobile networks, linger until the idle
+// timeout. Whatever is still open after half of the time ctx leaves is
+// closed, so that the components stopped after the listeners keep the
+// other half.
+func Shutdown(h3 *http3.Server) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Until(deadline)/2)
+			defer cancel()
+		}
+		if err := h3.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,151 @@
+// Package proxy lets the server act as a lightweight API gateway: each
+// configured prefix is forwarded to an upstream service with a timeout
+// budget, header rewriting, and the retries and circuit breaking of
+// httpclient. Bodies are streamed in both directions.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/httpclient"
+	"go-flylike-example/internal/logging"
+)
+
+// DefaultTimeout is the budget for routes that do not set one.
+const DefaultTimeout = 30 * time.Second
+
+// Gateway holds one reverse proxy per configured route.
+type Gateway struct {
+	routes []*route
+}
+
+type route struct {
+	cfg    config.ProxyRoute
+	target *url.URL
+	proxy  *httputil.ReverseProxy
+}
+
+type ginContextKey struct{}
+
+// New builds the reverse proxies for routes, recording upstream calls on m.
+func New(routes []config.ProxyRoute, m *httpclient.Metrics) (*Gateway, error) {
+	g := &Gateway{}
+	for _, rc := range routes {
+		rt, err := newRoute(rc, m)
+		if err != nil {
+			return nil, err
+		}
+		g.routes = append(g.routes, rt)
+	}
+	return g, nil
+}
+
+// Forward returns a handler sending every request it sees to the upstream
+// of rc, as a gateway route would, whatever its prefix.
+func Forward(rc config.ProxyRoute, m *httpclient.Metrics) (gin.HandlerFunc, error) {
+	rt, err := newRoute(rc, m)
+	if err != nil {
+		return nil, err
+	}
+	return rt.serve, nil
+}
+
+func newRoute(rc config.ProxyRoute, m *httpclient.Metrics) (*route, error) {
+	target, err := url.Parse(rc.Upstream)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %s: %w", rc.Prefix, err)
+	}
+	if rc.Timeout == 0 {
+		rc.Timeout = DefaultTimeout
+	}
+	rt := &route{cfg: rc, target: target}
+	rt.proxy = &httputil.ReverseProxy{
+		Rewrite:   rt.rewrite,
+		Transport: httpclient.NewTransport(httpclient.WithRetries(rc.Retries), httpclient.WithMetrics(m)),
+		// Flush immediately so streamed responses such as SSE are not
+		// held back in a buffer.
+		FlushInterval: -1,
+		ErrorHandler:  rt.handleError,
+	}
+	return rt, nil
+}
+
+// Register mounts every route on r, matching the prefix itself and
+// everything below it.
+func (g *Gateway) Register(r gin.IRouter) {
+	for _, rt := range g.routes {
+		r.Any(rt.cfg.Prefix, rt.serve)
+		r.Any(rt.cfg.Prefix+"/*path", rt.serve)
+	}
+}
+
+func (rt *route) serve(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), rt.cfg.Timeout)
+	defer cancel()
+	// The route budget, not the server's WriteTimeout, bounds the response.
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(rt.cfg.Timeout))
+	ctx = context.WithValue(ctx, ginContextKey{}, c)
+
+	rt.proxy.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
+
+func (rt *route) rewrite(pr *httputil.ProxyRequest) {
+	if rt.cfg.StripPrefix {
+		pr.Out.URL.Path = strings.TrimPrefix(pr.Out.URL.Path, rt.cfg.Prefix)
+		pr.Out.URL.RawPath = strings.TrimPrefix(pr.Out.URL.RawPath, rt.cfg.Prefix)
+	}
+	pr.SetURL(rt.target)
+	pr.SetXForwarded()
+	if c, ok := pr.In.Context().Value(ginContextKey{}).(*gin.Context); ok {
+		// Name the client as resolved through the trusted proxies rather
+		// than the proxy this server was reached through.
+		pr.Out.Header.Set("X-Forwarded-For", c.ClientIP())
+	}
+
+	h := pr.Out.Header
+	if id := logging.RequestIDFrom(pr.In.Context()); id != "" {
+		h.Set(logging.HeaderRequestID, id)
+	}
+	for _, name := range rt.cfg.RemoveHeaders {
+		h.Del(name)
+	}
+	for name, value := range rt.cfg.SetHeaders {
+		h.Set(name, value)
+	}
+}
+
+// handleError reports upstream failures through the error middleware
+// instead of the proxy's bare 502.
+func (rt *route) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	c, _ := r.Context().Value(ginContextKey{}).(*gin.Context)
+	if c == nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	switch {
+	case errors.Is(c.Request.Context().Err(), context.Canceled):
+		// The client went away; there is nobody to answer.
+		c.Abort()
+	case errors.Is(err, httpclient.ErrCircuitOpen):
+		c.Error(&apperror.Error{Kind: apperror.KindUnavailable, Message: "upstream temporarily unavailable",
+			Err: fmt.Errorf("proxy %s: %w", rt.target.Host, err)})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.Error(&apperror.Error{Kind: apperror.KindGatewayTimeout, Message: "upstream timed out",
+			Err: fmt.Errorf("proxy %s: %w", rt.target.Host, err)})
+	default:
+		c.Error(&apperror.Error{Kind: apperror.KindBadGateway, Message: "upstream unavailable",
+			Err: fmt.Errorf("proxy %s: %w", rt.target.Host, err)})
+	}
+}
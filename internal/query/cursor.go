@@ -0,0 +1,125 @@
+package query
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	"go-flylike-example/api"
+)
+
+// Page places a page of results, as the meta member of list responses.
+// NextCursor is set whenever more rows follow, so that a client can switch
+// from pages to the cursor at any point.
+type Page = api.Page
+
+// cursor is the content of a cursor token: the sort it was made for and
+// the sort key of the last row of its page.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+var errCursor = errors.New("query: invalid cursor")
+
+// Paginate drops the extra row Build asked for from items and describes the
+// page. value returns the named field of an item; the sort fields of the
+// last item make the next cursor.
+func Paginate[T any](s *Spec, items []T, value func(item T, field string) any) ([]T, Page) {
+	p := Page{Limit: s.Limit, Page: s.Page}
+	if len(items) <= s.Limit {
+		return items, p
+	}
+	items = items[:s.Limit]
+	p.HasMore = true
+
+	last := items[len(items)-1]
+	c := cursor{Sort: s.sortKey()}
+	for _, o := range s.Sort {
+		v := value(last, o.Field)
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339Nano)
+		}
+		c.Values = append(c.Values, v)
+	}
+	raw, _ := json.Marshal(c)
+	p.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	return items, p
+}
+
+func (s *Spec) sortKey() string {
+	parts := make([]string, len(s.Sort))
+	for i, o := range s.Sort {
+		parts[i] = o.Field
+		if o.Desc {
+			parts[i] = "-" + o.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// decodeCursor returns the sort key a cursor carries, typed after the sort
+// fields. A cursor made for another sort is refused.
+func (s *Spec) decodeCursor(token string) ([]any, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var c cursor
+	if err := dec.Decode(&c); err != nil || c.Sort != s.sortKey() || len(c.Values) != len(s.Sort) {
+		return nil, errCursor
+	}
+	after := make([]any, len(c.Values))
+	for i, o := range s.Sort {
+		v, err := parseValue(s.opts.Fields[o.Field].Type, fmt.Sprint(c.Values[i]))
+		if err != nil {
+			return nil, errCursor
+		}
+		after[i] = v
+	}
+	return after, nil
+}
+
+// All yields every row s selects from the cursor on, whatever its limit
+// and page, reading batch rows at a time with fetch and continuing after
+// the last row of each batch. No query stays open between batches, so a
+// consumer that falls behind holds no database connection. value returns
+// the named field of an item, as for Paginate.
+func All[T any](s *Spec, batch int, fetch func(s *Spec) ([]T, error), value func(item T, field string) any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		next := *s
+		next.Limit, next.Page = batch, 0
+		for {
+			items, err := fetch(&next)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			more := len(items) > batch
+			if more {
+				items = items[:batch]
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if !more {
+				return
+			}
+			last := items[len(items)-1]
+			next.after = make([]any, len(next.Sort))
+			for i, o := range next.Sort {
+				next.after[i] = value(last, o.Field)
+			}
+		}
+	}
+}
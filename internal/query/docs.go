@@ -0,0 +1,36 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Params documents the list parameters opts allows.
+func (o *Options) Params() []openapi.Param {
+	s := &Spec{opts: o}
+	params := []openapi.Param{
+		{Name: "limit", Description: fmt.Sprintf("Page size, 1 to %d (default %d).", o.MaxLimit, o.DefaultLimit)},
+		{Name: "page", Description: "1-based page number; cannot be combined with `cursor`."},
+		{Name: "cursor", Description: "The `meta.next_cursor` of the previous page, for the same sort."},
+		{Name: "sort", Description: fmt.Sprintf("Comma-separated fields, `-` for descending (default `%s`): %s.",
+			o.Sort, s.names(isSortable))},
+	}
+	var names []string
+	for name := range o.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		f := o.Fields[name]
+		if len(f.Ops) == 0 {
+			continue
+		}
+		params = append(params, openapi.Param{
+			Name:        "filter[" + name + "]",
+			Description: fmt.Sprintf("Filter on %s; `filter[%s][op]` with op one of %s.", name, name, joinOps(f.Ops)),
+		})
+	}
+	return params
+}
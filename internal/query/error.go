@@ -0,0 +1,63 @@
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/validation"
+)
+
+// Error lists the list parameters a request got wrong.
+type Error struct {
+	Errors []validation.FieldError
+	// messages holds the format and arguments of each message, for Bind
+	// to translate.
+	messages []message
+}
+
+type message struct {
+	format string
+	args   []any
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return "query: " + strings.Join(msgs, "; ")
+}
+
+func (e *Error) add(field, rule, msg string) {
+	e.Errors = append(e.Errors, validation.FieldError{Field: field, Rule: rule, Message: msg})
+	e.messages = append(e.messages, message{format: msg})
+}
+
+func (e *Error) addf(field, rule, format string, args ...any) {
+	e.Errors = append(e.Errors, validation.FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	e.messages = append(e.messages, message{format: format, args: args})
+}
+
+// Bind parses the list parameters of the request. On failure it writes a
+// validation problem naming each bad parameter and returns false, so
+// handlers can simply return.
+func Bind(c *gin.Context, opts *Options) (*Spec, bool) {
+	s, err := Parse(c.Request.URL.Query(), opts)
+	if err != nil {
+		p := validation.NewProblem(http.StatusUnprocessableEntity, i18n.T(c, "the request contains invalid query parameters"))
+		p.Type = "/problems/validation"
+		p.Title = "Validation failed"
+		qerr := err.(*Error)
+		for i, fe := range qerr.Errors {
+			fe.Message = i18n.T(c, qerr.messages[i].format, qerr.messages[i].args...)
+			p.Errors = append(p.Errors, fe)
+		}
+		validation.Abort(c, p)
+		return nil, false
+	}
+	return s, true
+}
@@ -0,0 +1,283 @@
+// Package query parses the pagination, sorting and filtering parameters of
+// list endpoints into a Spec, checked against what each endpoint allows,
+// and turns the Spec into SQL:
+//
+//	?limit=20&page=2              offset pagination
+//	?limit=20&cursor=<token>      keyset pagination from an opaque cursor
+//	?sort=-created_at,name        sort fields, "-" for descending
+//	?filter[name]=ann             equality
+//	?filter[created_at][gte]=...  other operators
+//
+// Cursors carry the sort key of the last row returned, so cursor pages
+// neither skip nor repeat rows while others are inserted.
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of a field's values.
+type Type int
+
+// Field types.
+const (
+	String Type = iota
+	Int
+	Time // RFC 3339
+	Bool
+)
+
+// Op is a filter operator.
+type Op string
+
+// Filter operators. Contains matches a substring case-insensitively; In
+// takes a comma-separated list.
+const (
+	Eq       Op = "eq"
+	Ne       Op = "ne"
+	Lt       Op = "lt"
+	Lte      Op = "lte"
+	Gt       Op = "gt"
+	Gte      Op = "gte"
+	Contains Op = "contains"
+	In       Op = "in"
+)
+
+var sqlOps = map[Op]string{Eq: "=", Ne: "<>", Lt: "<", Lte: "<=", Gt: ">", Gte: ">="}
+
+// maxIn bounds the values of an In filter.
+const maxIn = 100
+
+// Field is a field a list endpoint exposes for sorting or filtering.
+type Field struct {
+	// Column is the SQL expression behind the field. It must not be
+	// nullable if the field is sortable.
+	Column string
+	Type   Type
+	Sort   bool
+	// Ops are the filter operators allowed; none leaves the field
+	// unfilterable.
+	Ops []Op
+}
+
+// Options is what a list endpoint allows. Fields are keyed by the name
+// used in query parameters.
+type Options struct {
+	Fields map[string]Field
+	// Key names the unique field that orders rows with equal sort values;
+	// it ends every sort, ascending unless given.
+	Key string
+	// Sort is used when the request has none, in the ?sort syntax.
+	Sort         string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Sort is one sort field.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Filter is one filter condition; Values holds a single value except for
+// In.
+type Filter struct {
+	Field  string
+	Op     Op
+	Values []any
+}
+
+// Spec is a parsed and validated list request.
+type Spec struct {
+	opts *Options
+
+	Limit int
+	// Page is the 1-based page of offset pagination; it is 0 when paging
+	// by cursor.
+	Page    int
+	Sort    []Sort
+	Filters []Filter
+	// after is the sort key decoded from the cursor.
+	after []any
+}
+
+// Parse reads the list parameters from v. A violation is reported as an
+// *Error listing every offending parameter.
+func Parse(v url.Values, opts *Options) (*Spec, error) {
+	s := &Spec{opts: opts, Limit: opts.DefaultLimit}
+	errs := &Error{}
+
+	if raw := v.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > opts.MaxLimit {
+			errs.addf("limit", "range", "must be a number from 1 to %d", opts.MaxLimit)
+		} else {
+			s.Limit = n
+		}
+	}
+
+	sort := v.Get("sort")
+	if sort == "" {
+		sort = opts.Sort
+	}
+	s.parseSort(sort, errs)
+	s.parseFilters(v, errs)
+
+	cursor, page := v.Get("cursor"), v.Get("page")
+	switch {
+	case cursor != "" && page != "":
+		errs.add("cursor", "excluded_with", "cannot be combined with page")
+	case cursor != "":
+		after, err := s.decodeCursor(cursor)
+		if err != nil {
+			errs.add("cursor", "cursor", "is not a cursor of this listing and sort")
+		}
+		s.after = after
+	case page != "":
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			errs.add("page", "min", "must be a number from 1")
+		}
+		s.Page = n
+	default:
+		s.Page = 1
+	}
+
+	if len(errs.Errors) > 0 {
+		return nil, errs
+	}
+	return s, nil
+}
+
+func (s *Spec) parseSort(raw string, errs *Error) {
+	seen := map[string]bool{}
+	for part := range strings.SplitSeq(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, desc := strings.CutPrefix(part, "-")
+		if f, ok := s.opts.Fields[name]; !ok || !f.Sort {
+			errs.addf("sort", "sort_field", "cannot sort by %q; sortable fields are %s", name, s.names(isSortable))
+			continue
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		s.Sort = append(s.Sort, Sort{Field: name, Desc: desc})
+	}
+	if !seen[s.opts.Key] {
+		s.Sort = append(s.Sort, Sort{Field: s.opts.Key})
+	}
+}
+
+func (s *Spec) parseFilters(v url.Values, errs *Error) {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	// Sorted so that the SQL, and any cache keyed on it, is stable.
+	slices.Sort(keys)
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, "filter[")
+		if !ok {
+			continue
+		}
+		name, rest, ok := strings.Cut(rest, "]")
+		if !ok {
+			errs.add(key, "filter", "must be filter[field] or filter[field][operator]")
+			continue
+		}
+		op := Eq
+		if rest != "" {
+			o, ok := strings.CutPrefix(rest, "[")
+			if !ok || !strings.HasSuffix(o, "]") {
+				errs.add(key, "filter", "must be filter[field] or filter[field][operator]")
+				continue
+			}
+			op = Op(strings.TrimSuffix(o, "]"))
+		}
+		f, ok := s.opts.Fields[name]
+		if !ok || len(f.Ops) == 0 {
+			errs.addf(key, "filter_field", "cannot filter by %q; filterable fields are %s", name, s.names(isFilterable))
+			continue
+		}
+		if !slices.Contains(f.Ops, op) {
+			errs.addf(key, "filter_op", "%s supports %s", name, joinOps(f.Ops))
+			continue
+		}
+
+		raw := []string{v.Get(key)}
+		if op == In {
+			raw = strings.Split(raw[0], ",")
+			if len(raw) > maxIn {
+				errs.addf(key, "max", "must list at most %d values", maxIn)
+				continue
+			}
+		}
+		filter := Filter{Field: name, Op: op}
+		for _, r := range raw {
+			value, err := parseValue(f.Type, strings.TrimSpace(r))
+			if err != nil {
+				errs.add(key, "format", err.Error())
+				break
+			}
+			filter.Values = append(filter.Values, value)
+		}
+		if len(filter.Values) == len(raw) {
+			s.Filters = append(s.Filters, filter)
+		}
+	}
+}
+
+func parseValue(t Type, raw string) (any, error) {
+	switch t {
+	case Int:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return n, nil
+	case Time:
+		ts, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be an RFC 3339 time")
+		}
+		return ts.UTC(), nil
+	case Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+func isSortable(f Field) bool   { return f.Sort }
+func isFilterable(f Field) bool { return len(f.Ops) > 0 }
+
+func (s *Spec) names(keep func(Field) bool) string {
+	var names []string
+	for name, f := range s.opts.Fields {
+		if keep(f) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+func joinOps(ops []Op) string {
+	s := make([]string, len(ops))
+	for i, op := range ops {
+		s[i] = string(op)
+	}
+	return strings.Join(s, ", ")
+}
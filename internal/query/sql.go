@@ -0,0 +1,83 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Build appends the filters, cursor, order and limit of s to query, a
+// SELECT ending in the WHERE clause the caller always applies, and returns
+// it with args extended to match. Placeholders are "?", for store.Rebind.
+// One row more than s.Limit is asked for, so that Paginate can tell whether
+// another page follows.
+func (s *Spec) Build(query string, args ...any) (string, []any) {
+	var b strings.Builder
+	b.WriteString(query)
+	for _, f := range s.Filters {
+		col := s.opts.Fields[f.Field].Column
+		switch f.Op {
+		case In:
+			b.WriteString(" AND " + col + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(f.Values)), ", ") + ")")
+			args = append(args, f.Values...)
+		case Contains:
+			b.WriteString(" AND lower(" + col + `) LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(strings.ToLower(fmt.Sprint(f.Values[0])))+"%")
+		default:
+			b.WriteString(" AND " + col + " " + sqlOps[f.Op] + " ?")
+			args = append(args, f.Values[0])
+		}
+	}
+	if s.after != nil {
+		cond, after := s.keyset()
+		b.WriteString(" AND (" + cond + ")")
+		args = append(args, after...)
+	}
+
+	b.WriteString(" ORDER BY ")
+	for i, o := range s.Sort {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(s.opts.Fields[o.Field].Column)
+		if o.Desc {
+			b.WriteString(" DESC")
+		}
+	}
+	b.WriteString(" LIMIT ?")
+	args = append(args, s.Limit+1)
+	if s.Page > 1 {
+		b.WriteString(" OFFSET ?")
+		args = append(args, (s.Page-1)*s.Limit)
+	}
+	return b.String(), args
+}
+
+// keyset returns the condition selecting rows after the cursor in sort
+// order: (a > ?) OR (a = ? AND b > ?) OR ..., with < for descending fields.
+func (s *Spec) keyset() (string, []any) {
+	var (
+		ors  []string
+		args []any
+	)
+	for i, o := range s.Sort {
+		var ands []string
+		for j := range i {
+			ands = append(ands, s.opts.Fields[s.Sort[j].Field].Column+" = ?")
+			args = append(args, s.after[j])
+		}
+		op := " > ?"
+		if o.Desc {
+			op = " < ?"
+		}
+		ands = append(ands, s.opts.Fields[o.Field].Column+op)
+		args = append(args, s.after[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return strings.Join(ors, " OR "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,17 @@
+package quota
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{ID: "getUsage", Method: http.MethodGet, Path: "", Tags: []string{"quota"}, Summary: "Get the caller's usage", Auth: true,
+			Description: "Usage and monthly limits of the caller's tenant and user; a limit of 0 is unlimited.",
+			Query:       []openapi.Param{{Name: "period", Description: "month as YYYY-MM, the current one by default"}},
+			Response:    openapi.Envelope([]Usage{}), Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	}
+}
@@ -0,0 +1,141 @@
+package quota
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/jobs"
+	"go-flylike-example/internal/tenant"
+)
+
+// settingPrefix starts the tenant settings that override a limit, such as
+// quota.requests.
+const settingPrefix = "quota."
+
+var exceeded = map[string]*apperror.Error{
+	Requests: apperror.TooManyRequests("monthly request quota exceeded"),
+	Storage:  apperror.PaymentRequired("monthly storage quota exceeded"),
+	Jobs:     apperror.PaymentRequired("monthly job quota exceeded"),
+}
+
+// Enforcer charges usage to the tenant and user of a request. A nil
+// Enforcer charges nothing.
+type Enforcer struct {
+	store Store
+	live  *config.Live
+}
+
+// New returns an Enforcer counting in store, with the limits of the
+// current configuration.
+func New(store Store, live *config.Live) *Enforcer {
+	return &Enforcer{store: store, live: live}
+}
+
+type userKey struct{}
+
+// WithUser returns ctx charging usage to the user subject, for work done on
+// a user's behalf without their credentials.
+func WithUser(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, userKey{}, subject)
+}
+
+// user returns the subject usage of the request is charged to: the owner
+// of an API key, or the authenticated subject.
+func user(c *gin.Context) string {
+	if k, ok := apikeys.FromContext(c); ok {
+		return k.Owner
+	}
+	if claims, ok := auth.ClaimsFrom(c); ok {
+		return claims.Subject
+	}
+	return ""
+}
+
+// counters returns the counters of the tenant and user ctx belongs to.
+// The tenant comes first, so that transactions lock rows in one order.
+func (e *Enforcer) counters(ctx context.Context, metric string) []Counter {
+	cfg := e.live.Load().Quota
+	var out []Counter
+	if t, ok := tenant.FromContext(ctx); ok {
+		l := limit(cfg.Tenant, metric)
+		if v, ok := t.Settings[settingPrefix+metric]; ok {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+				l = n
+			}
+		}
+		out = append(out, Counter{Scope: TenantScope(t.ID), Limit: l})
+	}
+	if sub, ok := ctx.Value(userKey{}).(string); ok {
+		out = append(out, Counter{Scope: UserScope(sub), Limit: limit(cfg.User, metric)})
+	}
+	return out
+}
+
+// Charge adds n of metric to the tenant and user of ctx, or returns the
+// error to answer with when that would exceed a limit. If the store fails
+// the usage goes uncounted: an unavailable store must not take the API
+// down.
+func (e *Enforcer) Charge(ctx context.Context, metric string, n int64) error {
+	if e == nil {
+		return nil
+	}
+	counters := e.counters(ctx, metric)
+	if len(counters) == 0 {
+		return nil
+	}
+	ok, err := e.store.Add(ctx, Period(time.Now()), metric, n, counters)
+	if err != nil {
+		slog.WarnContext(ctx, "quota store unavailable", "metric", metric, "error", err)
+		return nil
+	}
+	if !ok {
+		return exceeded[metric]
+	}
+	return nil
+}
+
+// Middleware charges every request to its tenant and user, answering 429
+// with a Retry-After of the month's end once the request quota is used up.
+// It remembers the user on the request context for later charges.
+func (e *Enforcer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sub := user(c); sub != "" {
+			c.Request = c.Request.WithContext(WithUser(c.Request.Context(), sub))
+		}
+		if err := e.Charge(c.Request.Context(), Requests, 1); err != nil {
+			now := time.Now()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(Reset(now).Sub(now).Seconds()))))
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Jobs returns an Enqueuer charging each job enqueued to the tenant and
+// user of its context before handing it to next.
+func (e *Enforcer) Jobs(next jobs.Enqueuer) jobs.Enqueuer {
+	return &enqueuer{next: next, e: e}
+}
+
+type enqueuer struct {
+	next jobs.Enqueuer
+	e    *Enforcer
+}
+
+func (q *enqueuer) Enqueue(ctx context.Context, kind string, payload any, opts ...jobs.Option) (string, error) {
+	if err := q.e.Charge(ctx, Jobs, 1); err != nil {
+		return "", err
+	}
+	return q.next.Enqueue(ctx, kind, payload, opts...)
+}
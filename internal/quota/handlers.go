@@ -0,0 +1,82 @@
+package quota
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/render"
+)
+
+// Usage is what one scope used in a period, and its limits where known.
+type Usage struct {
+	Scope   string           `json:"scope"`
+	Period  string           `json:"period"`
+	Used    map[string]int64 `json:"used"`
+	Limits  map[string]int64 `json:"limits,omitempty"`
+	ResetAt time.Time        `json:"reset_at"`
+}
+
+// Usage returns what scope used in period, with every metric present.
+func (e *Enforcer) Usage(ctx context.Context, scope, period string) (*Usage, error) {
+	start, err := ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	used, err := e.store.Usage(ctx, scope, period)
+	if err != nil {
+		return nil, err
+	}
+	u := &Usage{Scope: scope, Period: period, Used: make(map[string]int64, len(Metrics)), ResetAt: Reset(start)}
+	for _, metric := range Metrics {
+		u.Used[metric] = used[metric]
+	}
+	return u, nil
+}
+
+// ParsePeriod returns the start of a period written as YYYY-MM.
+func ParsePeriod(period string) (time.Time, error) {
+	t, err := time.Parse("2006-01", period)
+	if err != nil {
+		return time.Time{}, apperror.BadRequest("period must be a month as YYYY-MM")
+	}
+	return t, nil
+}
+
+// Register mounts the usage API of the caller on g:
+//
+//	GET /   usage and limits of the caller's tenant and user, for the
+//	        current month or ?period=YYYY-MM
+func (e *Enforcer) Register(g *gin.RouterGroup) {
+	g.Use(auth.Required())
+	g.GET("", e.handleUsage)
+}
+
+func (e *Enforcer) handleUsage(c *gin.Context) {
+	ctx := c.Request.Context()
+	period := c.DefaultQuery("period", Period(time.Now()))
+	// The counters of each metric name the same scopes, in the same order.
+	limits := map[string][]Counter{}
+	for _, metric := range Metrics {
+		limits[metric] = e.counters(ctx, metric)
+	}
+	list := []*Usage{}
+	for i, ctr := range limits[Requests] {
+		u, err := e.Usage(ctx, ctr.Scope, period)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		u.Limits = make(map[string]int64, len(Metrics))
+		for _, metric := range Metrics {
+			u.Limits[metric] = limits[metric][i].Limit
+		}
+		list = append(list, u)
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "usage found"), list))
+}
@@ -0,0 +1,66 @@
+// Package quota counts what tenants and users consume each month and
+// enforces the configured limits. Counters are kept per scope, period and
+// metric by a Store; the SQL store is the authoritative record billing
+// reads. Exceeding the request limit answers 429 until the month resets,
+// exceeding the storage or job limit answers 402.
+package quota
+
+import (
+	"context"
+	"time"
+
+	"go-flylike-example/internal/config"
+)
+
+// Metrics counted per scope.
+const (
+	Requests = "requests"
+	Storage  = "storage"
+	Jobs     = "jobs"
+)
+
+// Metrics lists every metric, in the order the usage API reports them.
+var Metrics = []string{Requests, Storage, Jobs}
+
+// Counter is the counter of one scope, such as "tenant:<id>", and the
+// limit it must stay within; a Limit of 0 leaves it unlimited.
+type Counter struct {
+	Scope string
+	Limit int64
+}
+
+// Store keeps the usage counters.
+type Store interface {
+	// Add adds n to metric in period on every counter, or on none of
+	// them when any would go over its limit, and reports whether it did.
+	Add(ctx context.Context, period, metric string, n int64, counters []Counter) (bool, error)
+	// Usage returns the amount of each metric scope used in period.
+	Usage(ctx context.Context, scope, period string) (map[string]int64, error)
+}
+
+// TenantScope and UserScope name the counters of a tenant and a user.
+func TenantScope(id string) string    { return "tenant:" + id }
+func UserScope(subject string) string { return "user:" + subject }
+
+// Period returns the month t falls in, as counters are keyed by.
+func Period(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Reset returns when the period t falls in ends.
+func Reset(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func limit(l config.QuotaLimits, metric string) int64 {
+	switch metric {
+	case Requests:
+		return l.Requests
+	case Storage:
+		return l.Storage
+	case Jobs:
+		return l.Jobs
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// retention is how long Redis keeps a month's counters after it ended,
+// long enough for the invoice run to read them.
+const retention = 92 * 24 * time.Hour
+
+// addAll raises a field of every hash in KEYS by ARGV[2] unless one of them
+// would go over its limit, ARGV[4] onwards. ARGV[1] is the metric and
+// ARGV[3] when the hashes expire.
+var addAll = redis.NewScript(`
+local metric = ARGV[1]
+local n = tonumber(ARGV[2])
+for i, key in ipairs(KEYS) do
+  local limit = tonumber(ARGV[i + 3])
+  local used = tonumber(redis.call('HGET', key, metric)) or 0
+  if limit > 0 and used + n > limit then
+    return 0
+  end
+end
+for _, key in ipairs(KEYS) do
+  redis.call('HINCRBY', key, metric, n)
+  redis.call('EXPIREAT', key, ARGV[3])
+end
+return 1
+`)
+
+// RedisStore keeps the counters of each scope and period in a hash.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client, prefix: "quota:"}
+}
+
+func (s *RedisStore) key(scope, period string) string {
+	return s.prefix + scope + ":" + period
+}
+
+// Add implements Store.
+func (s *RedisStore) Add(ctx context.Context, period, metric string, n int64, counters []Counter) (bool, error) {
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		return false, fmt.Errorf("quota: redis add: %w", err)
+	}
+	keys := make([]string, len(counters))
+	args := []any{metric, n, Reset(start).Add(retention).Unix()}
+	for i, ctr := range counters {
+		keys[i] = s.key(ctr.Scope, period)
+		args = append(args, ctr.Limit)
+	}
+	added, err := addAll.Run(ctx, s.client, keys, args...).Int()
+	if err != nil {
+		return false, fmt.Errorf("quota: redis add: %w", err)
+	}
+	return added == 1, nil
+}
+
+// Usage implements Store.
+func (s *RedisStore) Usage(ctx context.Context, scope, period string) (map[string]int64, error) {
+	fields, err := s.client.HGetAll(ctx, s.key(scope, period)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("quota: redis usage: %w", err)
+	}
+	used := make(map[string]int64, len(fields))
+	for metric, v := range fields {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("quota: redis usage: %w", err)
+		}
+		used[metric] = n
+	}
+	return used, nil
+}
@@ -0,0 +1,91 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-flylike-example/internal/store"
+)
+
+// SQLStore keeps the counters in the quota_usage table, which works the
+// same on Postgres and SQLite.
+type SQLStore struct {
+	db *store.Store
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *store.Store) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Add implements Store. Each counter is raised by an upsert that only
+// updates a row staying within its limit, all in one transaction, so
+// concurrent requests cannot take a counter past it.
+func (s *SQLStore) Add(ctx context.Context, period, metric string, n int64, counters []Counter) (bool, error) {
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		tx := s.db.Writer(ctx)
+		now := time.Now().Unix()
+		for _, ctr := range counters {
+			if ctr.Limit > 0 && n > ctr.Limit {
+				return errOverLimit
+			}
+			query := `INSERT INTO quota_usage (scope, period, metric, amount, updated_at) VALUES (?, ?, ?, ?, ?)
+				 ON CONFLICT (scope, period, metric) DO UPDATE SET amount = quota_usage.amount + excluded.amount, updated_at = excluded.updated_at`
+			args := []any{ctr.Scope, period, metric, n, now}
+			if ctr.Limit > 0 {
+				query += ` WHERE quota_usage.amount + excluded.amount <= ?`
+				args = append(args, ctr.Limit)
+			}
+			res, err := tx.ExecContext(ctx, s.db.Rebind(query), args...)
+			if err != nil {
+				return err
+			}
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if rows == 0 {
+				return errOverLimit
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errOverLimit) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("quota: sql add: %w", err)
+	}
+	return true, nil
+}
+
+// errOverLimit undoes the counters already raised when one is full.
+var errOverLimit = errors.New("quota: over limit")
+
+// Usage implements Store. It reads the primary, since billing relies on
+// it being current.
+func (s *SQLStore) Usage(ctx context.Context, scope, period string) (map[string]int64, error) {
+	rows, err := s.db.Writer(ctx).QueryContext(ctx, s.db.Rebind(
+		`SELECT metric, amount FROM quota_usage WHERE scope = ? AND period = ?`), scope, period)
+	if err != nil {
+		return nil, fmt.Errorf("quota: sql usage: %w", err)
+	}
+	defer rows.Close()
+	used := map[string]int64{}
+	for rows.Next() {
+		var (
+			metric string
+			amount int64
+		)
+		if err := rows.Scan(&metric, &amount); err != nil {
+			return nil, fmt.Errorf("quota: sql usage: %w", err)
+		}
+		used[metric] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("quota: sql usage: %w", err)
+	}
+	return used, nil
+}
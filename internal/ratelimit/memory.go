@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// Memory keeps buckets in process memory. Limits are per instance.
+type Memory struct {
+	policy Policy
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	ttl    time.Duration
+}
+
+// NewMemory returns an in-memory limiter enforcing p.
+func NewMemory(p Policy) *Memory {
+	return &Memory{policy: p, buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// Allow implements Limiter.
+func (m *Memory) Allow(ctx context.Context, key string) (Result, error) {
+	m.mu.Lock()
+	p := m.policy
+	m.mu.Unlock()
+	return m.AllowPolicy(ctx, key, p)
+}
+
+// AllowPolicy implements Limiter.
+func (m *Memory) AllowPolicy(_ context.Context, key string, p Policy) (Result, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(p.Burst), last: now}
+		m.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(p.Burst), b.tokens+elapsed*p.Rate)
+	b.last = now
+	b.ttl = p.ttl()
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return p.result(allowed, b.tokens), nil
+}
+
+// sweep drops buckets that would have refilled completely by now.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.last) > b.ttl {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+// SetPolicy implements Limiter.
+func (m *Memory) SetPolicy(p Policy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = p
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+)
+
+// KeyFunc derives the bucket key for a request.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP keys buckets on the client IP.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByToken keys buckets on the authenticated subject, that of a verified
+// access token or API key, and falls back to the client IP. Credentials
+// that failed verification do not count: a client could otherwise get a
+// fresh bucket with every made-up token.
+func ByToken(c *gin.Context) string {
+	if claims, ok := auth.ClaimsFrom(c); ok {
+		return "sub:" + claims.Subject
+	}
+	return ByIP(c)
+}
+
+const policyKey = "ratelimit.policy"
+
+// SetPolicy makes Middleware enforce p instead of the limiter's policy for
+// the current request, for example the limits attached to an API key.
+func SetPolicy(c *gin.Context, p Policy) {
+	c.Set(policyKey, p)
+}
+
+// Middleware enforces l on every request, emitting X-RateLimit-* headers
+// and answering 429 once the bucket is empty. If the backend fails the
+// request is let through: an unavailable limiter must not take the API down.
+func Middleware(l Limiter, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var (
+			res Result
+			err error
+		)
+		if p, ok := c.Get(policyKey); ok {
+			res, err = l.AllowPolicy(c.Request.Context(), key(c), p.(Policy))
+		} else {
+			res, err = l.Allow(c.Request.Context(), key(c))
+		}
+		enforce(c, res, err)
+	}
+}
+
+// PolicyMiddleware is Middleware enforcing p regardless of the limiter's
+// policy and SetPolicy, for limits attached to some routes only. key
+// should set its buckets apart from those of other policies.
+func PolicyMiddleware(l Limiter, key KeyFunc, p Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := l.AllowPolicy(c.Request.Context(), key(c), p)
+		enforce(c, res, err)
+	}
+}
+
+func enforce(c *gin.Context, res Result, err error) {
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "rate limiter unavailable", "error", err)
+		c.Next()
+		return
+	}
+
+	h := c.Writer.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		c.Error(apperror.TooManyRequests("rate limit exceeded"))
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
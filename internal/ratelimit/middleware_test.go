@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"go-flylike-example/internal/auth"
+)
+
+func TestByToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		claims  *auth.Claims
+		invalid bool
+		want    string
+	}{
+		{"anonymous", nil, nil, false, "ip:192.0.2.1"},
+		{"verified", http.Header{"Authorization": {"Bearer good"}}, &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"}}, false, "sub:alice"},
+		{"invalid bearer token", http.Header{"Authorization": {"Bearer made-up"}}, nil, true, "ip:192.0.2.1"},
+		{"invalid api key", http.Header{"X-Api-Key": {"fk_made-up"}}, nil, true, "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v2/account/register", nil)
+			c.Request.RemoteAddr = "192.0.2.1:1234"
+			for k, v := range tt.header {
+				c.Request.Header[k] = v
+			}
+			if tt.claims != nil {
+				auth.SetClaims(c, tt.claims)
+			}
+			if tt.invalid {
+				auth.SetError(c, auth.ErrInvalidToken)
+			}
+			if got := ByToken(c); got != tt.want {
+				t.Errorf("ByToken = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
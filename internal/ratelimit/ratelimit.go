@@ -0,0 +1,64 @@
+// Package ratelimit implements token-bucket rate limiting keyed by client IP
+// or API credential, with an in-memory backend for single instances and a
+// Redis backend shared across instances.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limiter decides whether one more request for key is allowed.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	// AllowPolicy is Allow with p instead of the limiter's own policy,
+	// for credentials that carry their own limits.
+	AllowPolicy(ctx context.Context, key string, p Policy) (Result, error)
+	// SetPolicy changes the limits at runtime. Existing buckets keep
+	// their tokens, capped at the new burst.
+	SetPolicy(p Policy)
+}
+
+// Result describes the bucket state after a call to Allow.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, when not allowed
+}
+
+// Policy is a token bucket refilled at Rate tokens per second up to Burst.
+type Policy struct {
+	Rate  float64
+	Burst int
+}
+
+// result derives the client-facing numbers from the tokens left in a
+// bucket after the request was accounted for.
+func (p Policy) result(allowed bool, tokens float64) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     p.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(p.Burst) - tokens) / p.Rate),
+	}
+	if !allowed {
+		r.RetryAfter = seconds((1 - tokens) / p.Rate)
+	}
+	return r
+}
+
+// ttl is how long an untouched bucket takes to refill completely; after
+// that it is indistinguishable from a new one and can be forgotten.
+func (p Policy) ttl() time.Duration {
+	return seconds(float64(p.Burst) / p.Rate)
+}
+
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket refills and takes from a bucket stored as a hash, atomically.
+// Time comes from the Redis server so instances with skewed clocks agree.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis keeps buckets in Redis so every instance shares the same limits.
+type Redis struct {
+	client redis.Scripter
+	policy atomic.Pointer[Policy]
+	prefix string
+}
+
+// NewRedis returns a Redis-backed limiter enforcing p.
+func NewRedis(client redis.Scripter, p Policy) *Redis {
+	r := &Redis{client: client, prefix: "ratelimit:"}
+	r.SetPolicy(p)
+	return r
+}
+
+// SetPolicy implements Limiter.
+func (r *Redis) SetPolicy(p Policy) {
+	r.policy.Store(&p)
+}
+
+// Allow implements Limiter.
+func (r *Redis) Allow(ctx context.Context, key string) (Result, error) {
+	return r.AllowPolicy(ctx, key, *r.policy.Load())
+}
+
+// AllowPolicy implements Limiter.
+func (r *Redis) AllowPolicy(ctx context.Context, key string, policy Policy) (Result, error) {
+	ttl := policy.ttl().Milliseconds() + 1000
+	res, err := tokenBucket.Run(ctx, r.client, []string{r.prefix + key},
+		policy.Rate, policy.Burst, ttl).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: redis: %w", err)
+	}
+	if len(res) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: redis: unexpected reply %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	s, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: redis: %w", err)
+	}
+	return policy.result(allowed == 1, tokens), nil
+}
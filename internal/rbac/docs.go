@@ -0,0 +1,31 @@
+package rbac
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"rbac"}
+	desc := "Requires the `" + PermManage + "` permission."
+	forbidden := []int{http.StatusForbidden}
+	notFound := []int{http.StatusForbidden, http.StatusNotFound}
+	return []openapi.Operation{
+		{ID: "listRoles", Method: http.MethodGet, Path: "/roles", Tags: tags, Summary: "List roles", Description: desc, Auth: true,
+			Response: openapi.Envelope([]Role{}), Errors: forbidden},
+		{ID: "putRole", Method: http.MethodPut, Path: "/roles/:role", Tags: tags, Summary: "Create or replace a role", Auth: true,
+			Description: desc + " Honours `If-Match` with the role's version as ETag, and `If-None-Match: *` to only create.",
+			Request:     putRoleRequest{}, Response: openapi.Envelope(Role{}),
+			Errors: []int{http.StatusForbidden, http.StatusConflict, http.StatusPreconditionFailed, http.StatusPreconditionRequired}},
+		{ID: "deleteRole", Method: http.MethodDelete, Path: "/roles/:role", Tags: tags, Summary: "Delete a role", Description: desc, Auth: true,
+			Response: openapi.Envelope(nil), Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+		{ID: "listSubjectRoles", Method: http.MethodGet, Path: "/subjects/:subject/roles", Tags: tags, Summary: "List the roles of a subject", Description: desc, Auth: true,
+			Response: openapi.Envelope([]string{}), Errors: forbidden},
+		{ID: "assignRole", Method: http.MethodPut, Path: "/subjects/:subject/roles/:role", Tags: tags, Summary: "Assign a role", Description: desc, Auth: true,
+			Response: openapi.Envelope(nil), Errors: notFound},
+		{ID: "unassignRole", Method: http.MethodDelete, Path: "/subjects/:subject/roles/:role", Tags: tags, Summary: "Unassign a role", Description: desc, Auth: true,
+			Response: openapi.Envelope(nil), Errors: forbidden},
+	}
+}
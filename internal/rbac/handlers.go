@@ -0,0 +1,99 @@
+package rbac
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/precondition"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+// PermManage guards the role administration API.
+const PermManage = "rbac:manage"
+
+type putRoleRequest struct {
+	Description string   `json:"description" binding:"max=200"`
+	Permissions []string `json:"permissions" binding:"required,max=100,dive,required,max=100"`
+}
+
+// Register mounts the role administration API on g:
+//
+//	GET    /roles                       list roles
+//	PUT    /roles/:role                 create or replace a role
+//	DELETE /roles/:role                 delete a role
+//	GET    /subjects/:subject/roles     roles of a subject
+//	PUT    /subjects/:subject/roles/:role
+//	DELETE /subjects/:subject/roles/:role
+func (s *Service) Register(g *gin.RouterGroup) {
+	g.Use(Require(PermManage))
+	g.GET("/roles", s.handleRoles)
+	g.PUT("/roles/:role", s.handlePutRole)
+	g.DELETE("/roles/:role", s.handleDeleteRole)
+	g.GET("/subjects/:subject/roles", s.handleSubjectRoles)
+	g.PUT("/subjects/:subject/roles/:role", s.handleAssign)
+	g.DELETE("/subjects/:subject/roles/:role", s.handleUnassign)
+}
+
+func (s *Service) handleRoles(c *gin.Context) {
+	roles, err := s.Roles(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("roles listed", roles))
+}
+
+func (s *Service) handlePutRole(c *gin.Context) {
+	var req putRoleRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	chk, ok := precondition.From(c)
+	if !ok {
+		return
+	}
+	role := Role{Name: c.Param("role"), Description: req.Description, Permissions: req.Permissions}
+	version, err := s.PutRole(c.Request.Context(), role, chk)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	role.Version = version
+	precondition.Set(c, version)
+	c.JSON(http.StatusOK, render.OK("role saved", role))
+}
+
+func (s *Service) handleDeleteRole(c *gin.Context) {
+	if err := s.DeleteRole(c.Request.Context(), c.Param("role")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("role deleted", nil))
+}
+
+func (s *Service) handleSubjectRoles(c *gin.Context) {
+	roles, err := s.SubjectRoles(c.Request.Context(), c.Param("subject"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("roles listed", roles))
+}
+
+func (s *Service) handleAssign(c *gin.Context) {
+	if err := s.Assign(c.Request.Context(), c.Param("subject"), c.Param("role")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("role assigned", nil))
+}
+
+func (s *Service) handleUnassign(c *gin.Context) {
+	if err := s.Unassign(c.Request.Context(), c.Param("subject"), c.Param("role")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK("role unassigned", nil))
+}
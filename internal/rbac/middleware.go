@@ -0,0 +1,62 @@
+package rbac
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+)
+
+const serviceKey = "rbac.service"
+
+var errNoService = errors.New("rbac: Require used without the rbac middleware")
+
+// Middleware makes s available to Require. Install it once on the router.
+func (s *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(serviceKey, s)
+		c.Next()
+	}
+}
+
+// Require rejects requests whose subject lacks perm. Unauthenticated
+// requests are handled like auth.Required.
+func Require(perm string) gin.HandlerFunc {
+	required := auth.Required()
+	return func(c *gin.Context) {
+		if _, ok := auth.ClaimsFrom(c); !ok {
+			required(c)
+			return
+		}
+		if err := Check(c, perm); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Check is Require for handlers that only know from the request whether
+// perm is needed: it returns the error Require would reject it with.
+func Check(c *gin.Context, perm string) error {
+	claims, ok := auth.ClaimsFrom(c)
+	if !ok {
+		return apperror.Unauthorized("authentication required")
+	}
+	v, _ := c.Get(serviceKey)
+	s, ok := v.(*Service)
+	if !ok {
+		return apperror.Internal(errNoService)
+	}
+	allowed, err := s.Allowed(c.Request.Context(), claims.Subject, perm)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperror.Newf(apperror.KindForbidden, "missing permission %s", perm)
+	}
+	return nil
+}
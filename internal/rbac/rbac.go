@@ -0,0 +1,332 @@
+// Package rbac implements role-based access control. Roles bundle
+// permissions and are assigned to auth subjects; both are kept in the
+// database, and route groups enforce them with Require.
+package rbac
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/precondition"
+	"go-flylike-example/internal/store"
+)
+
+// AdminRole is created by the migrations with every permission and cannot
+// be deleted.
+const AdminRole = "admin"
+
+var (
+	// ErrRoleNotFound is returned for unknown roles.
+	ErrRoleNotFound = apperror.NotFound("role not found")
+	// ErrBuiltinRole is returned when deleting AdminRole.
+	ErrBuiltinRole = apperror.Conflict("the admin role cannot be deleted")
+)
+
+// Role is a named set of permissions.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	// Version is raised by every change; it is the ETag of the role.
+	Version int64 `json:"version"`
+}
+
+// cacheTTL bounds how long permissions of a subject are reused; changes
+// made through this instance take effect immediately, those made through
+// other instances within cacheTTL.
+const cacheTTL = 30 * time.Second
+
+// maxCached caps the cache; it is simply emptied when full.
+const maxCached = 4096
+
+type cached struct {
+	perms   []string
+	expires time.Time
+}
+
+// Service stores roles and answers permission checks.
+type Service struct {
+	db *store.Store
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+// New returns a Service backed by db.
+func New(db *store.Store) *Service {
+	return &Service{db: db, cache: make(map[string]cached)}
+}
+
+// Allowed reports whether subject holds perm through any of its roles.
+func (s *Service) Allowed(ctx context.Context, subject, perm string) (bool, error) {
+	perms, err := s.permissions(ctx, subject)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range perms {
+		if Match(p, perm) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Match reports whether the granted permission covers perm.
+func Match(granted, perm string) bool {
+	if granted == "*" || granted == perm {
+		return true
+	}
+	resource, ok := strings.CutSuffix(granted, ":*")
+	return ok && strings.HasPrefix(perm, resource+":")
+}
+
+func (s *Service) permissions(ctx context.Context, subject string) ([]string, error) {
+	s.mu.Lock()
+	if c, ok := s.cache[subject]; ok && time.Now().Before(c.expires) {
+		s.mu.Unlock()
+		return c.perms, nil
+	}
+	s.mu.Unlock()
+
+	rows, err := s.db.Writer(ctx).QueryContext(ctx, s.db.Rebind(
+		`SELECT DISTINCT rp.permission FROM user_roles ur
+		 JOIN role_permissions rp ON rp.role = ur.role
+		 WHERE ur.subject = ?`), subject)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: permissions: %w", err)
+	}
+	perms, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: permissions: %w", err)
+	}
+
+	s.mu.Lock()
+	if len(s.cache) >= maxCached {
+		clear(s.cache)
+	}
+	s.cache[subject] = cached{perms: perms, expires: time.Now().Add(cacheTTL)}
+	s.mu.Unlock()
+	return perms, nil
+}
+
+// invalidate forgets cached permissions after a change.
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	clear(s.cache)
+	s.mu.Unlock()
+}
+
+// Roles returns every role with its permissions.
+func (s *Service) Roles(ctx context.Context) ([]Role, error) {
+	rows, err := s.db.Writer(ctx).QueryContext(ctx,
+		`SELECT r.name, r.description, r.version, COALESCE(rp.permission, '') FROM roles r
+		 LEFT JOIN role_permissions rp ON rp.role = r.name
+		 ORDER BY r.name, rp.permission`)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: roles: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Role{}
+	for rows.Next() {
+		var (
+			name, desc, perm string
+			version          int64
+		)
+		if err := rows.Scan(&name, &desc, &version, &perm); err != nil {
+			return nil, fmt.Errorf("rbac: roles: %w", err)
+		}
+		if len(list) == 0 || list[len(list)-1].Name != name {
+			list = append(list, Role{Name: name, Description: desc, Permissions: []string{}, Version: version})
+		}
+		if perm != "" {
+			last := &list[len(list)-1]
+			last.Permissions = append(last.Permissions, perm)
+		}
+	}
+	return list, rows.Err()
+}
+
+// PutRole creates role or replaces its description and permissions, if
+// the role as it is passes chk, and returns its new version.
+func (s *Service) PutRole(ctx context.Context, role Role, chk precondition.Check) (int64, error) {
+	for _, p := range role.Permissions {
+		if p == "" || strings.ContainsAny(p, " \t\r\n") {
+			return 0, apperror.Newf(apperror.KindBadRequest, "invalid permission %q", p)
+		}
+	}
+	var version int64
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		tx := s.db.Writer(ctx)
+		err := tx.QueryRowContext(ctx, s.db.Rebind(
+			`SELECT version FROM roles WHERE name = ?`), role.Name).Scan(&version)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := chk.Verify(version); err != nil {
+			return err
+		}
+		// Both statements only apply to the role as read, so a concurrent
+		// change fails the precondition rather than being overwritten.
+		var res sql.Result
+		if version == 0 {
+			res, err = tx.ExecContext(ctx, s.db.Rebind(
+				`INSERT INTO roles (name, description, version) VALUES (?, ?, 1) ON CONFLICT DO NOTHING`),
+				role.Name, role.Description)
+		} else {
+			res, err = tx.ExecContext(ctx, s.db.Rebind(
+				`UPDATE roles SET description = ?, version = version + 1 WHERE name = ? AND version = ?`),
+				role.Description, role.Name, version)
+		}
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return precondition.ErrFailed
+		}
+		version++
+		if _, err := tx.ExecContext(ctx, s.db.Rebind(
+			`DELETE FROM role_permissions WHERE role = ?`), role.Name); err != nil {
+			return err
+		}
+		for _, p := range role.Permissions {
+			if _, err := tx.ExecContext(ctx, s.db.Rebind(
+				`INSERT INTO role_permissions (role, permission) VALUES (?, ?) ON CONFLICT DO NOTHING`),
+				role.Name, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, precondition.ErrFailed) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("rbac: put role: %w", err)
+	}
+	s.invalidate()
+	return version, nil
+}
+
+// DeleteRole removes role and every assignment of it.
+func (s *Service) DeleteRole(ctx context.Context, role string) error {
+	if role == AdminRole {
+		return ErrBuiltinRole
+	}
+	err := s.db.InTx(ctx, func(ctx context.Context) error {
+		tx := s.db.Writer(ctx)
+		res, err := tx.ExecContext(ctx, s.db.Rebind(`DELETE FROM roles WHERE name = ?`), role)
+		if err != nil {
+			return fmt.Errorf("rbac: delete role: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrRoleNotFound
+		}
+		for _, q := range []string{
+			`DELETE FROM role_permissions WHERE role = ?`,
+			`DELETE FROM user_roles WHERE role = ?`,
+		} {
+			if _, err := tx.ExecContext(ctx, s.db.Rebind(q), role); err != nil {
+				return fmt.Errorf("rbac: delete role: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// SubjectRoles returns the names of the roles assigned to subject.
+func (s *Service) SubjectRoles(ctx context.Context, subject string) ([]string, error) {
+	rows, err := s.db.Writer(ctx).QueryContext(ctx, s.db.Rebind(
+		`SELECT role FROM user_roles WHERE subject = ? ORDER BY role`), subject)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: subject roles: %w", err)
+	}
+	roles, err := scanStrings(rows)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: subject roles: %w", err)
+	}
+	return roles, nil
+}
+
+// RolesOf returns the role names of each of subjects, like SubjectRoles in
+// a single query. Subjects without roles map to an empty list.
+func (s *Service) RolesOf(ctx context.Context, subjects []string) (map[string][]string, error) {
+	roles := make(map[string][]string, len(subjects))
+	if len(subjects) == 0 {
+		return roles, nil
+	}
+	args := make([]any, len(subjects))
+	for i, subject := range subjects {
+		args[i] = subject
+		roles[subject] = []string{}
+	}
+	rows, err := s.db.Writer(ctx).QueryContext(ctx, s.db.Rebind(
+		`SELECT subject, role FROM user_roles WHERE subject IN (?`+strings.Repeat(", ?", len(subjects)-1)+`) ORDER BY subject, role`),
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: roles of: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var subject, role string
+		if err := rows.Scan(&subject, &role); err != nil {
+			return nil, fmt.Errorf("rbac: roles of: %w", err)
+		}
+		roles[subject] = append(roles[subject], role)
+	}
+	return roles, rows.Err()
+}
+
+// Assign grants role to subject. Assigning a role twice is not an error.
+func (s *Service) Assign(ctx context.Context, subject, role string) error {
+	var exists int
+	err := s.db.Writer(ctx).QueryRowContext(ctx, s.db.Rebind(
+		`SELECT 1 FROM roles WHERE name = ?`), role).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("rbac: assign: %w", err)
+	}
+	if _, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`INSERT INTO user_roles (subject, role, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`),
+		subject, role, time.Now().UTC()); err != nil {
+		return fmt.Errorf("rbac: assign: %w", err)
+	}
+	s.invalidate()
+	return nil
+}
+
+// Unassign takes role away from subject.
+func (s *Service) Unassign(ctx context.Context, subject, role string) error {
+	if _, err := s.db.Writer(ctx).ExecContext(ctx, s.db.Rebind(
+		`DELETE FROM user_roles WHERE subject = ? AND role = ?`), subject, role); err != nil {
+		return fmt.Errorf("rbac: unassign: %w", err)
+	}
+	s.invalidate()
+	return nil
+}
+
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	list := []string{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,224 @@
+// Package realtime provides a broadcast hub for realtime clients and the
+// WebSocket, Server-Sent Events and long-polling transports that attach
+// browsers to it.
+//
+// A client may name a channel. It then receives the broadcasts and the
+// events published to its channel, and what it sends is published there;
+// a client naming none receives every event and sends broadcasts.
+package realtime
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+)
+
+// ErrClosed is returned when using a hub that has been shut down.
+var ErrClosed = errors.New("realtime: hub closed")
+
+// ErrInvalidChannel is returned for channel names that are too long or hold
+// other characters than letters, digits, '.', '_' and '-'.
+var ErrInvalidChannel = apperror.BadRequest("invalid channel name")
+
+var channelName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// sendQueueSize bounds the per-client outbound queue. Clients that fall
+// further behind are disconnected rather than slowing down the broadcast.
+const sendQueueSize = 256
+
+// historySize is how many recent events are kept for clients resuming
+// with Last-Event-ID.
+const historySize = 256
+
+// Event is a broadcast message with its hub-assigned sequence number.
+// Channel is empty for broadcasts.
+type Event struct {
+	ID      uint64
+	Channel string
+	Data    []byte
+}
+
+// Hub fans messages out to every connected client.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+	lastID  uint64
+	history []Event
+	tracker Tracker
+}
+
+// Tracker is told which authenticated users are connected to a channel.
+// Join returns the function to call once the connection ended, or an
+// error when the channel is not acceptable.
+type Tracker interface {
+	Join(ctx context.Context, channel, user string) (leave func(), err error)
+}
+
+// Track makes clients that name a channel with ?channel= join it on t for
+// as long as they are connected. It must be called before serving.
+func (h *Hub) Track(t Tracker) {
+	h.tracker = t
+}
+
+// channelOf returns the channel the request c names with ?channel=, empty
+// when none.
+func channelOf(c *gin.Context) (string, error) {
+	channel := c.Query("channel")
+	if channel != "" && !channelName.MatchString(channel) {
+		return "", ErrInvalidChannel
+	}
+	return channel, nil
+}
+
+// join joins the client of c to channel, if tracked.
+func (h *Hub) join(c *gin.Context, channel string) (leave func(), err error) {
+	claims, ok := auth.ClaimsFrom(c)
+	if h.tracker == nil || channel == "" || !ok {
+		return func() {}, nil
+	}
+	return h.tracker.Join(c.Request.Context(), channel, claims.Subject)
+}
+
+// client is one connected subscriber with its own send queue.
+type client struct {
+	channel string
+	send    chan Event
+	once    sync.Once
+}
+
+// wants reports whether ev is for the client.
+func (c *client) wants(ev Event) bool {
+	return c.channel == "" || ev.Channel == "" || ev.Channel == c.channel
+}
+
+func (c *client) close() {
+	c.once.Do(func() { close(c.send) })
+}
+
+// NewHub returns an empty hub.
+func NewHub() *Hub {
+	return &Hub{clients: make(map[*client]struct{})}
+}
+
+// Broadcast queues msg for every client. It never blocks: a client whose
+// queue is full is dropped.
+func (h *Hub) Broadcast(msg []byte) {
+	h.Publish("", msg)
+}
+
+// Publish queues msg for the clients of channel, and those naming none,
+// like Broadcast. It returns the ID of the event.
+func (h *Hub) Publish(channel string, msg []byte) uint64 {
+	h.mu.Lock()
+	h.lastID++
+	ev := Event{ID: h.lastID, Channel: channel, Data: msg}
+	if len(h.history) == historySize {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:historySize-1]
+	}
+	h.history = append(h.history, ev)
+
+	var slow []*client
+	for c := range h.clients {
+		if !c.wants(ev) {
+			continue
+		}
+		select {
+		case c.send <- ev:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, c := range slow {
+		h.unregister(c)
+	}
+	return ev.ID
+}
+
+// Len returns the number of connected clients.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+func (h *Hub) register(channel string) (*client, error) {
+	c, _, _, err := h.registerSince(channel, 0)
+	return c, err
+}
+
+// registerSince registers a client of channel and returns the retained
+// events for it newer than lastID, and the ID of the last event published.
+// Holding the lock across both steps means no event is missed or delivered
+// twice.
+func (h *Hub) registerSince(channel string, lastID uint64) (c *client, backlog []Event, head uint64, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, nil, 0, ErrClosed
+	}
+	c = &client{channel: channel, send: make(chan Event, sendQueueSize)}
+	if lastID > 0 {
+		for _, ev := range h.history {
+			if ev.ID > lastID && c.wants(ev) {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	h.clients[c] = struct{}{}
+	h.wg.Add(1)
+	return c, backlog, h.lastID, nil
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// done must be called exactly once per registered client when its
+// connection has been torn down.
+func (h *Hub) done(c *client) {
+	h.unregister(c)
+	h.wg.Done()
+}
+
+// Shutdown stops accepting clients, closes every send queue so connections
+// say goodbye, and waits until they are gone or ctx expires.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+		delete(h.clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.close()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
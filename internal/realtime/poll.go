@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/render"
+)
+
+// Poll is the answer to a long poll: the events for the channel, oldest
+// first, and the cursor to send with the next poll.
+type Poll struct {
+	Events []PolledEvent `json:"events"`
+	Cursor uint64        `json:"cursor"`
+}
+
+// PolledEvent is an event of a Poll.
+type PolledEvent struct {
+	ID   uint64 `json:"id"`
+	Data string `json:"data"`
+}
+
+// ServePoll returns the handler of GET /poll/:channel, for clients behind
+// proxies that break streaming. A poll is answered at once with the
+// retained events for the channel newer than its ?cursor=, or else held
+// until one is published, for at most maxWait or the shorter ?timeout=
+// it asks for. Without a cursor, it waits for the next event. An empty
+// answer carries the cursor to continue from, so polls pick up where the
+// last one stopped as long as the hub still retains the events.
+//
+// Unlike a stream, a poll does not keep its user present in the channel:
+// the user would leave between polls.
+func (h *Hub) ServePoll(maxWait time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		channel := c.Param("channel")
+		if !channelName.MatchString(channel) {
+			c.Error(ErrInvalidChannel)
+			c.Abort()
+			return
+		}
+		var cursor uint64
+		if v := c.Query("cursor"); v != "" {
+			var err error
+			if cursor, err = strconv.ParseUint(v, 10, 64); err != nil {
+				c.Error(apperror.BadRequest("invalid cursor"))
+				c.Abort()
+				return
+			}
+		}
+		wait := maxWait
+		if v := c.Query("timeout"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				c.Error(apperror.BadRequest("invalid timeout"))
+				c.Abort()
+				return
+			}
+			wait = min(wait, d)
+		}
+
+		cl, events, head, err := h.registerSince(channel, cursor)
+		if err != nil {
+			c.Error(apperror.Unavailable("realtime hub is shutting down"))
+			c.Abort()
+			return
+		}
+		defer h.done(cl)
+
+		if len(events) == 0 && wait > 0 {
+			// The server's WriteTimeout would otherwise cut a long hold off.
+			_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(wait + writeWait))
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case ev, ok := <-cl.send:
+				if ok {
+					events = append(events, ev)
+				}
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+		// Take what else is queued, which the next poll would otherwise
+		// get again.
+	drain:
+		for {
+			select {
+			case ev, ok := <-cl.send:
+				if !ok {
+					break drain
+				}
+				events = append(events, ev)
+			default:
+				break drain
+			}
+		}
+
+		poll := Poll{Events: make([]PolledEvent, len(events)), Cursor: head}
+		for i, ev := range events {
+			poll.Events[i] = PolledEvent{ID: ev.ID, Data: string(ev.Data)}
+			poll.Cursor = max(poll.Cursor, ev.ID)
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, render.OK(i18n.T(c, "events"), poll))
+	}
+}
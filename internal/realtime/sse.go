@@ -0,0 +1,118 @@
+package realtime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/logging"
+)
+
+// heartbeatPeriod is how often an idle stream sends a comment line so that
+// proxies and load balancers do not close it.
+const heartbeatPeriod = 15 * time.Second
+
+// ServeSSE streams hub events as Server-Sent Events. A reconnecting client
+// that sends Last-Event-ID first receives the retained events it missed.
+// Like ServeWS, it keeps an authenticated client present in its channel.
+func (h *Hub) ServeSSE(c *gin.Context) {
+	lastID, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	channel, err := channelOf(c)
+	if err != nil {
+		c.Error(err)
+		c.Abort()
+		return
+	}
+
+	cl, backlog, _, err := h.registerSince(channel, lastID)
+	if err != nil {
+		c.Error(apperror.Unavailable("realtime hub is shutting down"))
+		c.Abort()
+		return
+	}
+	defer h.done(cl)
+	leave, err := h.join(c, channel)
+	if err != nil {
+		c.Error(err)
+		c.Abort()
+		return
+	}
+	defer leave()
+
+	// The server's WriteTimeout would otherwise cut the stream off.
+	rc := http.NewResponseController(c.Writer)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	logger := logging.FromContext(c.Request.Context())
+	logger.Debug("sse connected", "clients", h.Len(), "last_event_id", lastID, "replayed", len(backlog))
+	defer logger.Debug("sse disconnected")
+
+	w := c.Writer
+	for _, ev := range backlog {
+		if err := writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case ev, ok := <-cl.send:
+			if !ok {
+				// Dropped as a slow client or the hub is shutting down;
+				// the browser reconnects with Last-Event-ID.
+				return
+			}
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes ev in the text/event-stream format, splitting
+// multi-line payloads into one data field per line.
+func writeEvent(w io.Writer, ev Event) error {
+	if _, err := fmt.Fprintf(w, "id: %d\n", ev.ID); err != nil {
+		return err
+	}
+	if len(ev.Data) == 0 {
+		_, err := io.WriteString(w, "data:\n\n")
+		return err
+	}
+	for line := range bytes.Lines(ev.Data) {
+		line = bytes.TrimRight(line, "\r\n")
+		if _, err := fmt.Fprintf(w, "data: %s\n", line); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
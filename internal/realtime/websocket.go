@@ -0,0 +1,120 @@
+package realtime
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/logging"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 64 << 10
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// ServeWS upgrades the request to a WebSocket and attaches it to the hub.
+// Text messages sent by the client are published to its channel, or
+// broadcast to every client. An authenticated client naming a channel is
+// present in it until it disconnects.
+func (h *Hub) ServeWS(c *gin.Context) {
+	channel, err := channelOf(c)
+	if err != nil {
+		c.Error(err)
+		c.Abort()
+		return
+	}
+	cl, err := h.register(channel)
+	if err != nil {
+		c.Error(apperror.Unavailable("realtime hub is shutting down"))
+		c.Abort()
+		return
+	}
+
+	leave, err := h.join(c, channel)
+	if err != nil {
+		h.done(cl)
+		c.Error(err)
+		c.Abort()
+		return
+	}
+	defer leave()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response.
+		h.done(cl)
+		return
+	}
+
+	logger := logging.FromContext(c.Request.Context())
+	logger.Debug("websocket connected", "clients", h.Len())
+
+	go h.writePump(conn, cl, logger)
+	h.readPump(conn, cl)
+}
+
+// readPump consumes client frames until the connection fails. It owns the
+// teardown of the client.
+func (h *Hub) readPump(conn *websocket.Conn, cl *client) {
+	defer h.done(cl)
+
+	conn.SetReadLimit(maxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if typ == websocket.TextMessage {
+			h.Publish(cl.channel, msg)
+		}
+	}
+}
+
+// writePump drains the client's queue and keeps the connection alive with
+// pings. A closed queue means the hub dropped the client or is shutting
+// down, so it sends a close frame and closes the connection, which in turn
+// ends readPump.
+func (h *Hub) writePump(conn *websocket.Conn, cl *client, logger *slog.Logger) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+		logger.Debug("websocket disconnected")
+	}()
+
+	for {
+		select {
+		case ev, ok := <-cl.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "connection closed by server"))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, ev.Data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
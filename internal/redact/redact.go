@@ -0,0 +1,97 @@
+// Package redact blanks the values of sensitive members in JSON documents,
+// such as passwords and tokens, before they are stored or logged.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"net/url"
+	"strings"
+)
+
+// Placeholder replaces the values of sensitive members.
+const Placeholder = "[redacted]"
+
+// signed are the query parameters of signed links, as signedurl adds them.
+// A link grants access on its own until it expires, so they are dropped
+// whatever the configured names.
+var signed = []string{"expires", "key", "signature"}
+
+// Query returns the encoding of q without the parameters of signed links
+// and with the values of those named by names replaced.
+func Query(q url.Values, names []string) string {
+	q = maps.Clone(q)
+	for _, name := range signed {
+		q.Del(name)
+	}
+	for k := range q {
+		if Sensitive(k, names) {
+			q[k] = []string{Placeholder}
+		}
+	}
+	return q.Encode()
+}
+
+// JSON returns the JSON document body with the values of the members
+// named by names replaced, or nil if body is empty or not JSON.
+func JSON(body []byte, names []string) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil
+	}
+	return marshal(walk(doc, names))
+}
+
+// Value is JSON for the encoding of v.
+func Value(v any, names []string) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return JSON(b, names)
+}
+
+func walk(v any, names []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if Sensitive(key, names) {
+				v[key] = Placeholder
+				continue
+			}
+			v[key] = walk(value, names)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = walk(item, names)
+		}
+	}
+	return v
+}
+
+// Sensitive reports whether key is one of names or ends in "_" and one of
+// them, so that "password" also covers "new_password". Case is ignored.
+func Sensitive(key string, names []string) bool {
+	key = strings.ToLower(key)
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if key == name || strings.HasSuffix(key, "_"+name) {
+			return true
+		}
+	}
+	return false
+}
+
+func marshal(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
@@ -0,0 +1,135 @@
+// Package region implements the multi-region request routing pattern used on
+// Fly-style platforms: writes that land on a replica region are answered
+// with a fly-replay header so the platform proxy re-runs them in the primary
+// region, and reads may be served from a regional database replica.
+package region
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/store"
+)
+
+const (
+	// HeaderReplay asks the platform proxy to replay the request elsewhere.
+	HeaderReplay = "Fly-Replay"
+	// HeaderReplaySrc is added by the proxy to requests it has replayed.
+	HeaderReplaySrc = "Fly-Replay-Src"
+	// HeaderRegion carries the edge region that received the request.
+	HeaderRegion = "Fly-Region"
+	// CookieReadPrimary holds the Unix time until which the client's reads
+	// go to the primary database, so that it sees its own writes.
+	CookieReadPrimary = "read_primary_until"
+)
+
+// Current returns the region this instance runs in (FLY_REGION).
+func Current() string {
+	return os.Getenv("FLY_REGION")
+}
+
+// Primary returns the region that accepts writes (PRIMARY_REGION).
+func Primary() string {
+	return os.Getenv("PRIMARY_REGION")
+}
+
+// IsPrimary reports whether this instance runs in the primary region. With
+// no primary configured every instance is treated as primary, which keeps
+// single-region and local deployments working unchanged.
+func IsPrimary() bool {
+	primary := Primary()
+	return primary == "" || primary == Current()
+}
+
+// Edge returns the edge region that received the request, falling back to
+// the current region when the header is absent.
+func Edge(c *gin.Context) string {
+	if r := c.GetHeader(HeaderRegion); r != "" {
+		return r
+	}
+	return Current()
+}
+
+// Replayed reports whether the platform proxy already replayed this request.
+func Replayed(c *gin.Context) bool {
+	return c.GetHeader(HeaderReplaySrc) != ""
+}
+
+// ReplayTo aborts the request with a fly-replay header pointing at region.
+func ReplayTo(c *gin.Context, region string) {
+	c.Header(HeaderReplay, "region="+region)
+	c.AbortWithStatusJSON(http.StatusConflict, render.Envelope{Status: "replay", Message: "request replayed to region " + region})
+}
+
+// PrimaryWrites replays mutating requests to the primary region when this
+// instance is a replica. Requests that were already replayed are served
+// locally to avoid replay loops.
+func PrimaryWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsPrimary() || !isWrite(c.Request.Method) || Replayed(c) {
+			c.Next()
+			return
+		}
+		ReplayTo(c, Primary())
+	}
+}
+
+// Database returns cfg as this instance should use it: "{region}" in the
+// replica URL is replaced with the current region, and the primary region
+// reads from the primary directly.
+func Database(cfg config.Database) config.Database {
+	if IsPrimary() {
+		cfg.ReplicaURL = ""
+		return cfg
+	}
+	cfg.ReplicaURL = strings.ReplaceAll(cfg.ReplicaURL, "{region}", Current())
+	return cfg
+}
+
+// ReadYourWrites pins a client's reads to the primary database for window
+// after each of its writes, covering the time the replica may take to
+// catch up. The deadline travels in a cookie because the write may have
+// been replayed to another instance. Failed writes pin reads too, which
+// costs a few primary reads and keeps the middleware off the response path.
+func ReadYourWrites(window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v, err := c.Cookie(CookieReadPrimary); err == nil {
+			if until, err := strconv.ParseInt(v, 10, 64); err == nil && time.Now().Unix() < until {
+				c.Request = c.Request.WithContext(store.WithPrimary(c.Request.Context()))
+			}
+		}
+		if !isWrite(c.Request.Method) {
+			c.Next()
+			return
+		}
+		until := time.Now().Add(window).Unix() + 1
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(CookieReadPrimary, strconv.FormatInt(until, 10), int(window.Seconds())+1, "/", "", false, true)
+		c.Next()
+	}
+}
+
+// Info serves the region topology as seen by this instance.
+func Info(c *gin.Context) {
+	c.JSON(http.StatusOK, render.OK("region info", gin.H{
+		"region":     Current(),
+		"primary":    Primary(),
+		"is_primary": IsPrimary(),
+		"edge":       Edge(c),
+	}))
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,17 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Params documents the sparse fieldset parameters of fs.
+func (fs *Fieldset) Params() []openapi.Param {
+	return []openapi.Param{{
+		Name: "fields[" + fs.Type + "]",
+		Description: fmt.Sprintf("Comma-separated fields to send, of %s; %s always, all by default. `fields` works too.",
+			strings.Join(fs.Allowed, ", "), strings.Join(fs.Key, ", ")),
+	}}
+}
@@ -0,0 +1,117 @@
+package render
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/validation"
+)
+
+// fieldsKey is the gin context key of the fields Select picked.
+const fieldsKey = "render.fields"
+
+// Fieldset is the fields of a resource type clients may pick with sparse
+// fieldsets, JSON:API style: ?fields[<Type>]=name,email, or ?fields= for
+// the resource of the route, names the JSON members of the data to send.
+// Key members go out whatever is picked.
+type Fieldset struct {
+	Type    string
+	Allowed []string
+	Key     []string
+}
+
+// Select reads the fields the request picks of fs, for Negotiate to send
+// only those of the data. On failure it writes a validation problem naming
+// the unknown fields and returns false, so handlers can simply return.
+func Select(c *gin.Context, fs *Fieldset) bool {
+	param := "fields[" + fs.Type + "]"
+	raw, ok := c.GetQuery(param)
+	if !ok {
+		param = "fields"
+		if raw, ok = c.GetQuery(param); !ok {
+			return true
+		}
+	}
+	picked := slices.Clone(fs.Key)
+	var errs []validation.FieldError
+	for name := range strings.SplitSeq(raw, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case !slices.Contains(fs.Allowed, name):
+			errs = append(errs, validation.FieldError{Field: param, Rule: "fields",
+				Message: i18n.T(c, "cannot select %q; selectable fields are %s", name, strings.Join(fs.Allowed, ", "))})
+		case !slices.Contains(picked, name):
+			picked = append(picked, name)
+		}
+	}
+	if len(errs) > 0 {
+		p := validation.NewProblem(http.StatusUnprocessableEntity, i18n.T(c, "the request contains invalid query parameters"))
+		p.Type = "/problems/validation"
+		p.Title = "Validation failed"
+		p.Errors = errs
+		validation.Abort(c, p)
+		return false
+	}
+	c.Set(fieldsKey, picked)
+	return true
+}
+
+// sparse returns data, a struct, a pointer to one or a slice of either,
+// with only the members named by fields, as maps; only the values of those
+// members are read, so the others are never encoded. Other data is
+// returned as is.
+func sparse(data any, fields []string) any {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Slice {
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = pick(v.Index(i), fields)
+		}
+		return items
+	}
+	return pick(v, fields)
+}
+
+func pick(v reflect.Value, fields []string) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return v.Interface()
+	}
+	out := make(map[string]any, len(fields))
+	for f := range v.Type().Fields() {
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" || !slices.Contains(fields, name) {
+			continue
+		}
+		fv := v.FieldByIndex(f.Index)
+		if strings.Contains(opts, "omitempty") && empty(fv) {
+			continue
+		}
+		out[name] = fv.Interface()
+	}
+	return out
+}
+
+// empty reports whether encoding/json omits v under omitempty.
+func empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+// Package render writes responses in the format the client prefers in its
+// Accept header, JSON, XML, MessagePack or Protobuf, and binds request
+// bodies from the same formats by Content-Type. JSON is the default and the
+// answer whenever nothing else is acceptable. Errors stay problem+json.
+package render
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+
+	"go-flylike-example/api"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/validation"
+)
+
+// Media types beyond JSON. MessagePack and Protobuf go by two names each;
+// the first is the one sent.
+const (
+	MIMEXML       = binding.MIMEXML
+	MIMEMsgPack   = binding.MIMEMSGPACK2
+	MIMEProtobuf  = binding.MIMEPROTOBUF
+	mimeXML2      = binding.MIMEXML2
+	mimeMsgPack2  = binding.MIMEMSGPACK
+	mimeProtobuf2 = "application/protobuf"
+)
+
+var (
+	offers      = []string{binding.MIMEJSON, MIMEXML, mimeXML2, MIMEMsgPack, mimeMsgPack2}
+	protoOffers = append(offers[:len(offers):len(offers)], MIMEProtobuf, mimeProtobuf2)
+)
+
+// Envelope is the {"status", "message", "data", "meta"} body of the
+// platform endpoints, in every format but Protobuf.
+type Envelope = api.Envelope[any]
+
+// OK returns the envelope of a successful response; data may be nil.
+func OK(message string, data any) Envelope {
+	return Envelope{Status: api.StatusOK, Message: message, Data: data}
+}
+
+// Negotiate writes body with status in the format Accept prefers. pb is the
+// Protobuf form of the response, usually its data without the envelope;
+// Protobuf is only offered when it is set. JSON and MessagePack data only
+// carries the fields Select picked, if it was called; XML and Protobuf,
+// whose schemas are fixed, are sent whole.
+func Negotiate(c *gin.Context, status int, body Envelope, pb proto.Message) {
+	c.Writer.Header().Add("Vary", "Accept")
+	available := offers
+	if pb != nil {
+		available = protoOffers
+	}
+	format := c.NegotiateFormat(available...)
+	if fields, ok := c.Get(fieldsKey); ok && body.Data != nil {
+		switch format {
+		case MIMEXML, mimeXML2, MIMEProtobuf, mimeProtobuf2:
+		default:
+			body.Data = sparse(body.Data, fields.([]string))
+		}
+	}
+	switch format {
+	case MIMEXML, mimeXML2:
+		c.XML(status, body)
+	case MIMEMsgPack, mimeMsgPack2:
+		c.Render(status, msgPack{body})
+	case MIMEProtobuf, mimeProtobuf2:
+		c.ProtoBuf(status, pb)
+	default:
+		c.JSON(status, body)
+	}
+}
+
+// msgPackHandle writes times as the standard timestamp extension rather
+// than the codec's own encoding, which other MessagePack libraries cannot
+// read.
+var msgPackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+// msgPack renders MessagePack with msgPackHandle.
+type msgPack struct{ data any }
+
+func (r msgPack) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return codec.NewEncoder(w, msgPackHandle).Encode(r.data)
+}
+
+func (msgPack) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", MIMEMsgPack)
+}
+
+// Bind decodes the request body into obj by its Content-Type and validates
+// it, writing a problem response and returning false on failure. JSON is
+// assumed without a Content-Type. Protobuf bodies are accepted when msg is
+// set: they are decoded into msg, which fill then copies into obj.
+func Bind(c *gin.Context, obj any, msg proto.Message, fill func()) bool {
+	switch c.ContentType() {
+	case "", binding.MIMEJSON:
+		return validation.BindJSON(c, obj)
+	case MIMEXML, mimeXML2, MIMEMsgPack, mimeMsgPack2:
+		return validation.Bind(c, obj)
+	case MIMEProtobuf, mimeProtobuf2:
+		if msg != nil {
+			return validation.BindProto(c, msg, obj, fill)
+		}
+	}
+	c.Error(apperror.UnsupportedMediaType("unsupported content type"))
+	c.Abort()
+	return false
+}
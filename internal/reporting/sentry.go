@@ -0,0 +1,97 @@
+// Package reporting sends server errors to an error tracker. Sentry
+// implements apperror.Reporter, so apperror.Middleware hands it every 5xx
+// response and recovered panic with the request they happened in.
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/buildinfo"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/region"
+	"go-flylike-example/internal/tenant"
+)
+
+// Sentry reports errors to a Sentry project.
+type Sentry struct {
+	client *sentry.Client
+}
+
+// NewSentry returns a Sentry client for cfg.DSN.
+func NewSentry(cfg config.Sentry) (*Sentry, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     release(cfg.Release),
+		SampleRate:  cfg.SampleRate,
+		ServerName:  region.Current(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reporting: sentry: %w", err)
+	}
+	return &Sentry{client: client}, nil
+}
+
+// release names the running build as Sentry groups releases: the
+// configured name, or the version and short commit.
+func release(name string) string {
+	if name != "" {
+		return name
+	}
+	info := buildinfo.Get()
+	if len(info.Commit) >= 12 {
+		return info.Version + "+" + info.Commit[:12]
+	}
+	return info.Version
+}
+
+// Report sends r with the request, the authenticated subject as the user and
+// the route, status, request ID, region and tenant as tags. Sentry leaves
+// cookies and credentials out of the request it stores.
+func (s *Sentry) Report(c *gin.Context, r apperror.Report) {
+	scope := sentry.NewScope()
+	scope.SetRequest(c.Request)
+	scope.SetTags(map[string]string{
+		"route":      c.FullPath(),
+		"status":     strconv.Itoa(r.Status),
+		"request_id": logging.RequestIDFrom(c.Request.Context()),
+		"region":     region.Current(),
+	})
+	if id := tenant.ID(c.Request.Context()); id != "" {
+		scope.SetTag("tenant", id)
+	}
+	if claims, ok := auth.ClaimsFrom(c); ok {
+		scope.SetUser(sentry.User{ID: claims.Subject})
+	} else if k, ok := apikeys.FromContext(c); ok {
+		scope.SetUser(sentry.User{ID: k.Owner})
+		scope.SetTag("api_key", k.ID)
+	}
+
+	level := sentry.LevelError
+	if r.Panic {
+		level = sentry.LevelFatal
+		scope.SetTag("panic", "true")
+	}
+	// The *apperror.Error in the chain carries the stack it was raised
+	// with, which Sentry reads through its StackTrace method.
+	event := s.client.EventFromException(r.Err, level)
+	s.client.CaptureEvent(event, &sentry.EventHint{OriginalException: r.Err, Context: c.Request.Context()}, scope)
+}
+
+// Flush waits until the queued reports were sent or ctx is done.
+func (s *Sentry) Flush(ctx context.Context) error {
+	if !s.client.FlushWithContext(ctx) {
+		return errors.New("reporting: sentry reports not sent in time")
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+// Package reqsign authenticates requests between services by a signature
+// over the request itself, in the manner of AWS Signature Version 4: the
+// caller hashes the body, puts the hash and the time in headers and signs
+// a canonical form of the method, path, query, those headers and the host
+// with HMAC-SHA256 under a key shared with this server, naming the key in
+// the Authorization header:
+//
+//	Authorization: HMAC-SHA256 Credential=<key id>, SignedHeaders=host;x-content-sha256;x-signature-date, Signature=<hex>
+//
+// Nothing the signature covers can be changed on the way without
+// invalidating it, TLS or not, and a captured request is only accepted
+// within the tolerance around its time. Keys are looked up by ID, so a
+// caller rotates by being given a new key, signing with it and having the
+// old one removed. httpclient.WithSigner signs the requests of a client.
+package reqsign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+)
+
+const (
+	// Algorithm starts the Authorization header of signed requests.
+	Algorithm = "HMAC-SHA256"
+	// HeaderDate carries the time of signing, as DateFormat.
+	HeaderDate = "X-Signature-Date"
+	// HeaderContentSHA256 carries the hex SHA-256 of the body.
+	HeaderContentSHA256 = "X-Content-SHA256"
+	// DateFormat is the format of HeaderDate, in UTC.
+	DateFormat = "20060102T150405Z"
+	// SubjectPrefix starts the auth subject of signed requests.
+	SubjectPrefix = "service:"
+)
+
+// required are the headers every signature must cover.
+var required = []string{"host", "x-content-sha256", "x-signature-date"}
+
+var (
+	// ErrInvalid is returned for signatures that are malformed, made with
+	// an unknown key or not matching the request.
+	ErrInvalid = apperror.Unauthorized("invalid request signature")
+	// ErrExpired is returned for requests signed outside the tolerance.
+	ErrExpired = apperror.Unauthorized("request signature outside the tolerance window")
+)
+
+// Key is a signing key.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKey returns the key of an "id:secret" entry.
+func ParseKey(entry string) (Key, error) {
+	id, secret, _ := strings.Cut(entry, ":")
+	if id == "" || secret == "" {
+		return Key{}, fmt.Errorf("reqsign: key must be id:secret")
+	}
+	return Key{ID: id, Secret: []byte(secret)}, nil
+}
+
+// Sign signs req with key at now, setting HeaderDate, HeaderContentSHA256
+// and Authorization. The body is read to be hashed and replaced, unless
+// GetBody can provide a copy.
+func Sign(req *http.Request, key Key, now time.Time) error {
+	body, err := bodyOf(req)
+	if err != nil {
+		return fmt.Errorf("reqsign: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	date := now.UTC().Format(DateFormat)
+	req.Header.Set(HeaderContentSHA256, hex.EncodeToString(sum[:]))
+	req.Header.Set(HeaderDate, date)
+	sig := signature(key.Secret, req, required, date)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, key.ID, strings.Join(required, ";"), sig))
+	return nil
+}
+
+func bodyOf(req *http.Request) ([]byte, error) {
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+		return nil, nil
+	case req.GetBody != nil:
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	return body, nil
+}
+
+// Verifier checks signed requests against its keys.
+type Verifier struct {
+	keys      map[string][]byte
+	tolerance time.Duration
+	maxBody   int64
+}
+
+// New returns the Verifier of the keys of cfg, which reads bodies of up
+// to maxBody bytes to hash them, any size with zero, or nil when there are
+// no keys.
+func New(cfg config.RequestSigning, maxBody int64) (*Verifier, error) {
+	if len(cfg.Secrets) == 0 {
+		return nil, nil
+	}
+	v := &Verifier{keys: map[string][]byte{}, tolerance: cfg.Tolerance, maxBody: maxBody}
+	for _, entry := range cfg.Secrets {
+		k, err := ParseKey(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := v.keys[k.ID]; dup {
+			return nil, fmt.Errorf("reqsign: key %s given twice", k.ID)
+		}
+		v.keys[k.ID] = k.Secret
+	}
+	return v, nil
+}
+
+// Verify checks that req with body is signed by a key of v within the
+// tolerance of now, and returns the ID of the key.
+func (v *Verifier) Verify(req *http.Request, body []byte, now time.Time) (string, error) {
+	params, ok := strings.CutPrefix(req.Header.Get("Authorization"), Algorithm+" ")
+	if !ok {
+		return "", ErrInvalid
+	}
+	var id, signed, sig string
+	for part := range strings.SplitSeq(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "Credential":
+			id = value
+		case "SignedHeaders":
+			signed = value
+		case "Signature":
+			sig = value
+		}
+	}
+	secret, ok := v.keys[id]
+	if !ok {
+		return "", ErrInvalid
+	}
+	headers := strings.Split(strings.ToLower(signed), ";")
+	for _, h := range required {
+		if !slices.Contains(headers, h) {
+			return "", ErrInvalid
+		}
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	date := req.Header.Get(HeaderDate)
+	want, _ := hex.DecodeString(signature(secret, req, headers, date))
+	if !hmac.Equal(got, want) {
+		return "", ErrInvalid
+	}
+	// The hash and the time are only trusted once the signature is.
+	sum := sha256.Sum256(body)
+	if !strings.EqualFold(req.Header.Get(HeaderContentSHA256), hex.EncodeToString(sum[:])) {
+		return "", ErrInvalid
+	}
+	at, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if d := now.Sub(at); d > v.tolerance || d < -v.tolerance {
+		return "", ErrExpired
+	}
+	return id, nil
+}
+
+// Middleware authenticates signed requests. A valid signature satisfies
+// auth.Required with the subject "service:<key id>"; an invalid one is
+// reported by auth.Required like a bad token. Requests not signed are
+// left to the other credentials. It must run before the JWT middleware.
+func (v *Verifier) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.GetHeader("Authorization"), Algorithm+" ") {
+			c.Next()
+			return
+		}
+		body, ok := v.read(c)
+		if !ok {
+			return
+		}
+		id, err := v.Verify(c.Request, body, time.Now())
+		if err != nil {
+			auth.SetError(c, err)
+		} else {
+			auth.SetClaims(c, &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: SubjectPrefix + id}})
+		}
+		c.Next()
+	}
+}
+
+// read reads the body to hash it and hands the handlers a fresh reader
+// over it. It aborts and returns false when that fails.
+func (v *Verifier) read(c *gin.Context) ([]byte, bool) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil, true
+	}
+	r := c.Request.Body
+	if v.maxBody > 0 {
+		if c.Request.ContentLength > v.maxBody {
+			c.Error(apperror.PayloadTooLarge("request body too large"))
+			c.Abort()
+			return nil, false
+		}
+		r = http.MaxBytesReader(c.Writer, r, v.maxBody)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(apperror.PayloadTooLarge("request body too large"))
+		} else {
+			c.Error(apperror.BadRequest("failed to read request body"))
+		}
+		c.Abort()
+		return nil, false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// signature returns the hex HMAC of the string to sign: the algorithm, the
+// date and the hash of the canonical request.
+func signature(secret []byte, req *http.Request, headers []string, date string) string {
+	canonical := sha256.Sum256([]byte(canonicalRequest(req, headers)))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(Algorithm + "\n" + date + "\n" + hex.EncodeToString(canonical[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalRequest lays out what is signed of req: the method, the escaped
+// path, the query in the order Encode sorts it, each header of headers as
+// "name:value", the header names and the body hash.
+func canonicalRequest(req *http.Request, headers []string) string {
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	b.WriteString(pathOf(req.URL) + "\n")
+	b.WriteString(req.URL.Query().Encode() + "\n")
+	for _, h := range headers {
+		value := strings.Join(req.Header.Values(h), ",")
+		if h == "host" {
+			value = hostOf(req)
+		}
+		b.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	b.WriteString("\n" + strings.Join(headers, ";") + "\n")
+	b.WriteString(req.Header.Get(HeaderContentSHA256))
+	return b.String()
+}
+
+// pathOf returns the path of u as sent in a request, which always starts
+// with a slash.
+func pathOf(u *url.URL) string {
+	p := u.EscapedPath()
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
+
+// hostOf returns the host the request is for: Host as sent by a client, or
+// as received by a server.
+func hostOf(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
@@ -0,0 +1,188 @@
+package reqsign
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+)
+
+const body = `{"kind":"sync"}`
+
+var key = Key{ID: "billing", Secret: []byte("0123456789abcdef0123456789abcdef")}
+
+func verifier(t *testing.T) *Verifier {
+	t.Helper()
+	v, err := New(config.RequestSigning{
+		Secrets:   []string{key.ID + ":" + string(key.Secret), "reports:fedcba9876543210fedcba9876543210"},
+		Tolerance: 5 * time.Minute,
+	}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// signed returns a request signed with k at at.
+func signed(t *testing.T, k Key, at time.Time) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "http://api.internal/api/v2/jobs?priority=high&queue=default", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := Sign(req, k, at); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	v := verifier(t)
+	tests := []struct {
+		name   string
+		at     time.Time // of the signature
+		key    Key
+		change func(r *http.Request)
+		body   string
+		want   error
+	}{
+		{name: "valid"},
+		{name: "unsigned header changed", change: func(r *http.Request) { r.Header.Set("Content-Type", "text/plain") }},
+		{name: "within the tolerance", at: now.Add(-4 * time.Minute)},
+		{name: "ahead within the tolerance", at: now.Add(4 * time.Minute)},
+		{name: "too old", at: now.Add(-6 * time.Minute), want: ErrExpired},
+		{name: "too far ahead", at: now.Add(6 * time.Minute), want: ErrExpired},
+		{name: "other method", change: func(r *http.Request) { r.Method = http.MethodPut }, want: ErrInvalid},
+		{name: "other path", change: func(r *http.Request) { r.URL.Path = "/api/v2/users" }, want: ErrInvalid},
+		{name: "other query", change: func(r *http.Request) { r.URL.RawQuery = "priority=low&queue=default" }, want: ErrInvalid},
+		{name: "added query parameter", change: func(r *http.Request) { r.URL.RawQuery += "&x=1" }, want: ErrInvalid},
+		{name: "other host", change: func(r *http.Request) { r.Host = "evil.example.com" }, want: ErrInvalid},
+		{name: "other body", body: `{"kind":"purge"}`, want: ErrInvalid},
+		{name: "other body and hash", body: `{"kind":"purge"}`, change: func(r *http.Request) {
+			sum := sha256.Sum256([]byte(`{"kind":"purge"}`))
+			r.Header.Set(HeaderContentSHA256, hex.EncodeToString(sum[:]))
+		}, want: ErrInvalid},
+		{name: "other date", change: func(r *http.Request) {
+			r.Header.Set(HeaderDate, now.Add(time.Minute).UTC().Format(DateFormat))
+		}, want: ErrInvalid},
+		{name: "unknown key", key: Key{ID: "payroll", Secret: key.Secret}, want: ErrInvalid},
+		{name: "other secret", key: Key{ID: key.ID, Secret: []byte("another secret of thirty-two bytes")}, want: ErrInvalid},
+		{name: "key of another caller", change: func(r *http.Request) {
+			r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), "Credential=billing", "Credential=reports", 1))
+		}, want: ErrInvalid},
+		{name: "required header left out", change: func(r *http.Request) {
+			r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), "host;", "", 1))
+		}, want: ErrInvalid},
+		{name: "malformed signature", change: func(r *http.Request) {
+			a := r.Header.Get("Authorization")
+			r.Header.Set("Authorization", a[:strings.Index(a, "Signature=")]+"Signature=zz")
+		}, want: ErrInvalid},
+		{name: "other algorithm", change: func(r *http.Request) {
+			r.Header.Set("Authorization", strings.Replace(r.Header.Get("Authorization"), Algorithm, "HMAC-SHA1", 1))
+		}, want: ErrInvalid},
+		{name: "bearer token", change: func(r *http.Request) { r.Header.Set("Authorization", "Bearer abc") }, want: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, k, b := now, key, body
+			if !tt.at.IsZero() {
+				at = tt.at
+			}
+			if tt.key.ID != "" {
+				k = tt.key
+			}
+			if tt.body != "" {
+				b = tt.body
+			}
+			req := signed(t, k, at)
+			if tt.change != nil {
+				tt.change(req)
+			}
+			id, err := v.Verify(req, []byte(b), now)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+			if err == nil && id != key.ID {
+				t.Errorf("key = %q, want %q", id, key.ID)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(verifier(t).Middleware())
+	r.POST("/api/v2/jobs", func(c *gin.Context) {
+		subject := ""
+		if claims, ok := auth.ClaimsFrom(c); ok {
+			subject = claims.Subject
+		}
+		b, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, subject+" "+string(b))
+	})
+
+	tests := []struct {
+		name string
+		req  func() *http.Request
+		want string
+	}{
+		{"signed", func() *http.Request { return signed(t, key, time.Now()) }, SubjectPrefix + key.ID + " " + body},
+		{"tampered", func() *http.Request {
+			req := signed(t, key, time.Now())
+			req.URL.RawQuery = "priority=low"
+			return req
+		}, " " + body},
+		{"unsigned", func() *http.Request {
+			req := signed(t, key, time.Now())
+			req.Header.Del("Authorization")
+			return req
+		}, " " + body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req()
+			// As received by a server.
+			req.RequestURI = req.URL.RequestURI()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if got := w.Body.String(); got != tt.want {
+				t.Fatalf("handler saw %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		ok      bool
+	}{
+		{"none", nil, true},
+		{"one", []string{"a:secret"}, true},
+		{"without id", []string{":secret"}, false},
+		{"without secret", []string{"a:"}, false},
+		{"without separator", []string{"secret"}, false},
+		{"duplicate id", []string{"a:one", "a:two"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(config.RequestSigning{Secrets: tt.secrets}, 0); (err == nil) != tt.ok {
+				t.Fatalf("New = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// Package restart implements zero-downtime binary upgrades on a single
+// host. On Upgrade the running process starts the binary again, handing
+// it every listening socket; once the new process reports ready, Exit is
+// closed and the old process drains and stops while the new one keeps
+// accepting on the same ports.
+package restart
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+)
+
+const (
+	envListeners = "FLYLIKE_UPGRADE_LISTENERS"
+	envReadyFD   = "FLYLIKE_UPGRADE_READY_FD"
+	// firstFD is the descriptor of the first ExtraFiles entry.
+	firstFD = 3
+	// settle is how long the old process keeps serving connections it
+	// accepted just before the handover, so their first request is read
+	// before shutdown starts; net/http drops requests read afterwards.
+	settle = 500 * time.Millisecond
+)
+
+var (
+	// ErrDisabled is returned by Upgrade when restarts are not enabled.
+	ErrDisabled = apperror.Conflict("zero-downtime restarts are disabled")
+	// ErrInProgress is returned by Upgrade while another one is running.
+	ErrInProgress = apperror.Conflict("a restart is already in progress")
+)
+
+// Upgrader hands listeners over to a new process.
+type Upgrader struct {
+	cfg config.Restart
+	exe string
+
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	listeners []*drainListener
+	ready     *os.File // set in a process started by Upgrade
+	upgrading bool
+
+	exit     chan struct{}
+	exitOnce sync.Once
+}
+
+// New returns an Upgrader. In a process started by Upgrade it picks up
+// the inherited sockets; with restarts disabled Listen simply binds.
+func New(cfg config.Restart) (*Upgrader, error) {
+	u := &Upgrader{cfg: cfg, inherited: make(map[string]*os.File), exit: make(chan struct{})}
+	if !cfg.Enabled {
+		return u, nil
+	}
+	if !supported {
+		return nil, fmt.Errorf("restart: not supported on this platform")
+	}
+	exe, err := executable()
+	if err != nil {
+		return nil, fmt.Errorf("restart: %w", err)
+	}
+	u.exe = exe
+
+	if keys := os.Getenv(envListeners); keys != "" {
+		for i, key := range strings.Split(keys, ",") {
+			u.inherited[key] = os.NewFile(uintptr(firstFD+i), key)
+		}
+	}
+	if v := os.Getenv(envReadyFD); v != "" {
+		fd, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("restart: %s: %w", envReadyFD, err)
+		}
+		u.ready = os.NewFile(uintptr(fd), "ready")
+	}
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envReadyFD)
+	return u, nil
+}
+
+// executable returns the path the binary was started from, so that an
+// upgrade runs whatever has since been installed there.
+func executable() (string, error) {
+	name := os.Args[0]
+	if !strings.Contains(name, string(filepath.Separator)) {
+		return exec.LookPath(name)
+	}
+	return filepath.Abs(name)
+}
+
+// Listen returns an inherited listener for network and addr, or binds a
+// new one. After a handover it stops accepting without closing the socket,
+// which the new process shares, and reports net.ErrClosed once closed.
+func (u *Upgrader) Listen(network, addr string) (net.Listener, error) {
+	key := network + "|" + addr
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var (
+		ln  net.Listener
+		err error
+	)
+	if f, ok := u.inherited[key]; ok {
+		delete(u.inherited, key)
+		ln, err = net.FileListener(f)
+		f.Close()
+	} else {
+		ln, err = net.Listen(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !u.cfg.Enabled {
+		return ln, nil
+	}
+	dl := &drainListener{Listener: ln, key: key, closed: make(chan struct{})}
+	u.listeners = append(u.listeners, dl)
+	return dl, nil
+}
+
+// Ready reports to the parent process, if any, that this process serves
+// traffic now, and writes the PID file. Call it once every listener is
+// open.
+func (u *Upgrader) Ready() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	// Sockets a previous version listened on and this one does not.
+	for key, f := range u.inherited {
+		f.Close()
+		delete(u.inherited, key)
+	}
+	if u.cfg.PIDFile != "" {
+		if err := writePIDFile(u.cfg.PIDFile); err != nil {
+			return err
+		}
+	}
+	if u.ready == nil {
+		return nil
+	}
+	_, err := u.ready.Write([]byte{1})
+	u.ready.Close()
+	u.ready = nil
+	if err != nil {
+		return fmt.Errorf("restart: notify parent: %w", err)
+	}
+	return nil
+}
+
+// Exit is closed once a new process has taken over; the caller should
+// then shut down gracefully.
+func (u *Upgrader) Exit() <-chan struct{} {
+	return u.exit
+}
+
+// Upgrade starts a new process with the current listeners and waits until
+// it is ready. On failure the new process is killed and this one carries
+// on serving.
+func (u *Upgrader) Upgrade() error {
+	if !u.cfg.Enabled {
+		return ErrDisabled
+	}
+	u.mu.Lock()
+	if u.upgrading {
+		u.mu.Unlock()
+		return ErrInProgress
+	}
+	u.upgrading = true
+	files, keys, err := u.files()
+	u.mu.Unlock()
+	defer func() {
+		u.mu.Lock()
+		u.upgrading = false
+		u.mu.Unlock()
+	}()
+	defer closeAll(files)
+	if err != nil {
+		return err
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("restart: %w", err)
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(u.exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		envListeners+"="+strings.Join(keys, ","),
+		envReadyFD+"="+strconv.Itoa(firstFD+len(files)))
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("restart: start %s: %w", u.exe, err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	ready := make(chan error, 1)
+	go func() {
+		_ = readyR.SetReadDeadline(time.Now().Add(u.cfg.Timeout))
+		b := make([]byte, 1)
+		_, err := readyR.Read(b)
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err == nil {
+			u.handover()
+			return nil
+		}
+		_ = cmd.Process.Kill()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("restart: new process not ready after %s", u.cfg.Timeout)
+		}
+		return fmt.Errorf("restart: new process failed before becoming ready: %w", err)
+	case err := <-exited:
+		return fmt.Errorf("restart: new process exited before becoming ready: %v", err)
+	}
+}
+
+// files duplicates the listening sockets for the new process.
+func (u *Upgrader) files() ([]*os.File, []string, error) {
+	var (
+		files []*os.File
+		keys  []string
+	)
+	for _, l := range u.listeners {
+		fl, ok := l.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return files, nil, fmt.Errorf("restart: %s cannot be passed on", l.key)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return files, nil, fmt.Errorf("restart: %s: %w", l.key, err)
+		}
+		files = append(files, f)
+		keys = append(keys, l.key)
+	}
+	return files, keys, nil
+}
+
+// handover stops accepting and closes Exit after the settle delay.
+func (u *Upgrader) handover() {
+	u.mu.Lock()
+	for _, l := range u.listeners {
+		l.drain()
+	}
+	u.mu.Unlock()
+	time.AfterFunc(settle, func() {
+		u.exitOnce.Do(func() { close(u.exit) })
+	})
+}
+
+// drainListener can stop accepting while the socket stays open.
+type drainListener struct {
+	net.Listener
+	key string
+
+	draining  atomic.Bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *drainListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil && l.draining.Load() {
+		// The deadline set by drain; wait for the server to close us.
+		<-l.closed
+		return nil, net.ErrClosed
+	}
+	return c, err
+}
+
+func (l *drainListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+func (l *drainListener) drain() {
+	l.draining.Store(true)
+	if d, ok := l.Listener.(interface{ SetDeadline(time.Time) error }); ok {
+		_ = d.SetDeadline(time.Unix(1, 0))
+	}
+}
+
+func closeAll(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func writePIDFile(path string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		return fmt.Errorf("restart: pid file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("restart: pid file: %w", err)
+	}
+	return nil
+}
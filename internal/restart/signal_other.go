@@ -0,0 +1,10 @@
+//go:build !unix
+
+package restart
+
+import "context"
+
+const supported = false
+
+// Watch does nothing on platforms without SIGUSR2.
+func (u *Upgrader) Watch(ctx context.Context) {}
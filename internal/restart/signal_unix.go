@@ -0,0 +1,31 @@
+//go:build unix
+
+package restart
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const supported = true
+
+// Watch upgrades on SIGUSR2 until ctx is done.
+func (u *Upgrader) Watch(ctx context.Context) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR2)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			slog.Info("restart requested", "trigger", "signal")
+			if err := u.Upgrade(); err != nil {
+				slog.Error("restart failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+)
+
+// Sunset describes the retirement schedule of an API version.
+type Sunset struct {
+	DeprecatedAt time.Time // zero: not deprecated
+	SunsetAt     time.Time // zero: no removal date announced
+	Successor    string    // path of the replacing version, e.g. /api/v2
+	Docs         string    // optional migration guide URL
+}
+
+// Deprecation advertises the schedule on every response using the
+// Deprecation (RFC 9745), Sunset (RFC 8594) and Link headers. After the
+// sunset date requests are refused with 410 Gone.
+func Deprecation(s Sunset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.DeprecatedAt.IsZero() {
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("Deprecation", "@"+strconv.FormatInt(s.DeprecatedAt.Unix(), 10))
+		if !s.SunsetAt.IsZero() {
+			h.Set("Sunset", s.SunsetAt.UTC().Format(http.TimeFormat))
+		}
+		if s.Successor != "" {
+			h.Add("Link", "<"+s.Successor+`>; rel="successor-version"`)
+		}
+		if s.Docs != "" {
+			h.Add("Link", "<"+s.Docs+`>; rel="deprecation"`)
+		}
+
+		if !s.SunsetAt.IsZero() && time.Now().After(s.SunsetAt) {
+			c.Error(apperror.Newf(apperror.KindGone, "this API version has been retired, use %s", s.Successor))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/users"
+)
+
+func v1Operations(deprecated bool) []openapi.Operation {
+	tags := []string{"users (v1)"}
+	return []openapi.Operation{
+		{ID: "listUsersV1", Method: http.MethodGet, Path: "/users", Tags: tags, Summary: "List users", Deprecated: deprecated,
+			Auth: true, Scope: users.PermRead, Response: struct {
+				Users []users.User `json:"users"`
+			}{}},
+		{ID: "getUserV1", Method: http.MethodGet, Path: "/users/:id", Tags: tags, Summary: "Get a user", Deprecated: deprecated,
+			Auth: true, Scope: users.PermRead, Response: users.User{}},
+	}
+}
+
+func v2Operations() []openapi.Operation {
+	tags := []string{"users"}
+	write := "Requires the " + users.PermWrite + " permission."
+	return []openapi.Operation{
+		{ID: "listUsers", Method: http.MethodGet, Path: "/users", Tags: tags, Summary: "List users",
+			Auth: true, Scope: users.PermRead, Query: append(users.ListOptions.Params(), users.Fields.Params()...),
+			Response: openapi.Paginated([]users.User{}, query.Page{}), Errors: []int{http.StatusUnprocessableEntity}},
+		{ID: "getUser", Method: http.MethodGet, Path: "/users/:id", Tags: tags, Summary: "Get a user",
+			Auth: true, Scope: users.PermRead, Query: users.Fields.Params(), Response: openapi.Envelope(users.User{}),
+			Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity}},
+		{ID: "createUser", Method: http.MethodPost, Path: "/users", Tags: tags, Summary: "Create a user", Description: write,
+			Auth: true, Scope: users.PermWrite, Query: users.Fields.Params(), Request: createUserRequest{}, Response: openapi.Envelope(users.User{}),
+			Status: http.StatusCreated, Errors: []int{http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity}},
+		{ID: "deleteUser", Method: http.MethodDelete, Path: "/users/:id", Tags: tags, Summary: "Delete a user",
+			Description: write + " The user is soft-deleted and logged out everywhere; its email can be registered again.",
+			Auth:        true, Scope: users.PermWrite, Response: openapi.Envelope(nil), Errors: []int{http.StatusForbidden, http.StatusNotFound}},
+		{ID: "evaluateFlags", Method: http.MethodGet, Path: "/flags", Tags: []string{"flags"}, Summary: "Evaluate feature flags for the caller",
+			Description: "Callers are bucketed on their subject, tenant or IP, in that order.",
+			Response:    openapi.Envelope(map[string]bool{})},
+	}
+}
+
+func v2StreamOperations() []openapi.Operation {
+	return []openapi.Operation{
+		{ID: "streamUsers", Method: http.MethodGet, Path: "/users/stream", Tags: []string{"users"}, Summary: "Stream every user",
+			Description: "One user per line, in the order and with the filters of `GET /users`, starting after `cursor` if given. " +
+				"`Accept: application/json` gets a JSON array instead. An error after the first user ends the stream " +
+				"with the `X-Stream-Error` trailer.",
+			Auth: true, Scope: users.PermRead, Query: users.ListOptions.Params(),
+			Response: users.User{}, ResponseType: "application/x-ndjson", Errors: []int{http.StatusUnprocessableEntity}},
+	}
+}
+
+func sessionOperations() []openapi.Operation {
+	tags := []string{"session"}
+	return []openapi.Operation{
+		{ID: "getSession", Method: http.MethodGet, Path: "", Tags: tags, Summary: "Count visits and get the CSRF token",
+			Response: openapi.Envelope(struct {
+				CSRFToken string            `json:"csrf_token"`
+				Values    map[string]string `json:"values"`
+			}{})},
+		{ID: "endSession", Method: http.MethodDelete, Path: "", Tags: tags, Summary: "End the browser session",
+			Description: "Requires the `X-CSRF-Token` header.", Response: openapi.Envelope(nil),
+			Errors: []int{http.StatusForbidden}},
+	}
+}
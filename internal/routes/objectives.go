@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/slo"
+)
+
+// objectives are the service level objectives of the routes clients depend
+// on most. The latency thresholds leave the reads, served from the cache or
+// one query, far less time than the writes and the login, which hashes the
+// password.
+func objectives() []slo.Objective {
+	return []slo.Objective{
+		{Name: "login", Method: http.MethodPost, Route: "/auth/login", Availability: 0.999, Latency: 0.99, Threshold: time.Second},
+		{Name: "refresh_token", Method: http.MethodPost, Route: "/auth/refresh", Availability: 0.999, Latency: 0.99, Threshold: 300 * time.Millisecond},
+		{Name: "list_users", Method: http.MethodGet, Route: "/api/v2/users", Availability: 0.999, Latency: 0.99, Threshold: 300 * time.Millisecond},
+		{Name: "get_user", Method: http.MethodGet, Route: "/api/v2/users/:id", Availability: 0.999, Latency: 0.99, Threshold: 200 * time.Millisecond},
+		{Name: "create_user", Method: http.MethodPost, Route: "/api/v2/users", Availability: 0.999, Latency: 0.99, Threshold: 500 * time.Millisecond},
+		{Name: "delete_user", Method: http.MethodDelete, Route: "/api/v2/users/:id", Availability: 0.999, Latency: 0.99, Threshold: 500 * time.Millisecond},
+		// Retiring, so kept to availability.
+		{Name: "list_users_v1", Method: http.MethodGet, Route: "/api/v1/users", Availability: 0.99},
+	}
+}
+
+// declareObjectives hands the objectives to t, and flags those whose route
+// is not registered, which would never see a request.
+func declareObjectives(r *gin.Engine, t *slo.Tracker) {
+	if err := t.Declare(objectives()...); err != nil {
+		slog.Error("service level objectives not declared", "error", err)
+		return
+	}
+	registered := map[string]bool{}
+	for _, ri := range r.Routes() {
+		registered[ri.Method+" "+ri.Path] = true
+	}
+	for _, o := range t.Declared() {
+		if !registered[o.Method+" "+o.Route] {
+			slog.Warn("service level objective of an unknown route", "objective", o.Name, "method", o.Method, "route", o.Route)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/users"
+	"go-flylike-example/internal/validation"
+)
+
+// userForm is the body of the new user form.
+type userForm struct {
+	Name  string `form:"name" binding:"required,notblank,max=100"`
+	Email string `form:"email" binding:"required,email,max=254"`
+}
+
+// userList is the data of the users/index page.
+type userList struct {
+	Users []users.User
+	// Next links the following page, if any.
+	Next string
+}
+
+// formField is an input of a form page, as the "field" partial renders it.
+type formField struct {
+	Name, Label, Type, Value string
+	Errors                   []string
+}
+
+// registerPages mounts server-rendered pages showing the users of the v2
+// API as HTML. They read and write through the same repository and list
+// parameters, so a page and its JSON counterpart always agree. Invalid
+// forms are rendered again with the messages next to their fields, and a
+// created user is answered with a redirect to its page.
+func registerPages(g *gin.RouterGroup, d Deps) {
+	g.GET("/users", func(c *gin.Context) {
+		spec, ok := query.Bind(c, users.ListOptions)
+		if !ok {
+			return
+		}
+		list, page, err := d.Users.ListPage(c.Request.Context(), spec)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		data := userList{Users: list}
+		if page.HasMore && page.NextCursor != "" {
+			q := c.Request.URL.Query()
+			q.Del("page")
+			q.Set("cursor", page.NextCursor)
+			data.Next = c.Request.URL.Path + "?" + q.Encode()
+		}
+		d.Views.HTML(c, http.StatusOK, "users/index", data)
+	})
+
+	g.GET("/users/new", func(c *gin.Context) {
+		renderUserForm(c, d, http.StatusOK, userForm{}, nil)
+	})
+
+	g.POST("/users", func(c *gin.Context) {
+		var form userForm
+		invalid, ok := validation.BindForm(c, &form)
+		if !ok {
+			return
+		}
+		if len(invalid) > 0 {
+			renderUserForm(c, d, http.StatusUnprocessableEntity, form, invalid)
+			return
+		}
+		u, err := d.Users.Create(c.Request.Context(), form.Name, form.Email)
+		if errors.Is(err, users.ErrEmailTaken) {
+			renderUserForm(c, d, http.StatusConflict, form, []validation.FieldError{
+				{Field: "email", Rule: "unique", Message: apperror.From(err).Localize(c.Request.Context())},
+			})
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/ui/users/"+u.ID)
+	})
+
+	g.GET("/users/:id", func(c *gin.Context) {
+		u, err := d.Users.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		d.Views.HTML(c, http.StatusOK, "users/show", u)
+	})
+}
+
+func renderUserForm(c *gin.Context, d Deps, status int, form userForm, invalid []validation.FieldError) {
+	messages := map[string][]string{}
+	for _, fe := range invalid {
+		messages[fe.Field] = append(messages[fe.Field], fe.Message)
+	}
+	d.Views.HTML(c, status, "users/new", gin.H{"Fields": []formField{
+		{Name: "name", Label: i18n.T(c, "Name"), Type: "text", Value: form.Name, Errors: messages["name"]},
+		{Name: "email", Label: i18n.T(c, "Email"), Type: "email", Value: form.Email, Errors: messages["email"]},
+	}})
+}
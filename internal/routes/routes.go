@@ -0,0 +1,395 @@
+// Package routes registers every HTTP route and assembles the middleware
+// stack of each route group, including the versioned /api/v1 and /api/v2
+// groups.
+package routes
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/admission"
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/audit"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/bandwidth"
+	"go-flylike-example/internal/batch"
+	"go-flylike-example/internal/buildinfo"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/export"
+	"go-flylike-example/internal/featureflag"
+	"go-flylike-example/internal/geo"
+	"go-flylike-example/internal/graph"
+	"go-flylike-example/internal/health"
+	"go-flylike-example/internal/hooks"
+	"go-flylike-example/internal/httpcache"
+	"go-flylike-example/internal/idempotency"
+	"go-flylike-example/internal/imports"
+	"go-flylike-example/internal/jobs"
+	"go-flylike-example/internal/limits"
+	"go-flylike-example/internal/metrics"
+	"go-flylike-example/internal/oidc"
+	"go-flylike-example/internal/openapi"
+	"go-flylike-example/internal/operations"
+	"go-flylike-example/internal/precondition"
+	"go-flylike-example/internal/presence"
+	"go-flylike-example/internal/proxy"
+	"go-flylike-example/internal/quota"
+	"go-flylike-example/internal/ratelimit"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/realtime"
+	"go-flylike-example/internal/region"
+	"go-flylike-example/internal/secheaders"
+	"go-flylike-example/internal/session"
+	"go-flylike-example/internal/slo"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+	"go-flylike-example/internal/uploads"
+	"go-flylike-example/internal/users"
+	"go-flylike-example/internal/views"
+	"go-flylike-example/internal/web"
+	"go-flylike-example/internal/webhooks"
+)
+
+// Deps are the services route handlers depend on.
+type Deps struct {
+	Config      *config.Live
+	Health      *health.Checker
+	Metrics     *metrics.Metrics
+	Auth        *auth.Service
+	APIKeys     *apikeys.Repository
+	OIDC        *oidc.Service
+	RBAC        *rbac.Service
+	Flags       *featureflag.Service
+	Idempotency idempotency.Store // nil disables Idempotency-Key handling
+	Limiter     ratelimit.Limiter // nil disables rate limiting
+	Cache       *httpcache.Cache  // nil disables the response cache
+	Quota       *quota.Enforcer   // nil disables usage quotas
+	Admission   *admission.Queue  // nil admits every request at once
+	Hub         *realtime.Hub
+	Presence    *presence.Service // nil disables the presence queries
+	Geo         *geo.Locator
+	SLO         *slo.Tracker // nil tracks no objectives
+	Users       *users.Repository
+	DB          *store.Store // nil disables request transactions
+	Accounts    *users.Accounts
+	Sessions    *session.Manager
+	Jobs        jobs.Enqueuer
+	Tenants     *tenant.Repository
+	Uploads     *uploads.Handler // nil answers uploads with 503
+	Web         *web.Handler     // nil disables the frontend
+	Views       *views.Renderer  // nil disables the pages below /ui
+	Gateway     *proxy.Gateway
+	Webhooks    *hooks.Service
+	Audit       *audit.Log
+	Operations  *operations.Service
+	Exports     *export.Exporter
+	Imports     *imports.Importer
+	// Replays remembers inbound webhook deliveries; GitHubEvents receives
+	// verified GitHub webhooks.
+	Replays      webhooks.Store
+	GitHubEvents func(ctx context.Context, e *webhooks.GitHubEvent) error
+}
+
+// maxReportBytes bounds a CSP violation report; browsers send a few
+// hundred bytes per violation.
+const maxReportBytes = 64 << 10
+
+// APIPrefixes are the path prefixes owned by the backend; the SPA fallback
+// never answers below them.
+var APIPrefixes = []string{"/api/", "/auth/", "/session", "/webhooks/", "/csp-report", "/events", "/ws", "/poll/", "/presence/", "/graphql", "/metrics", "/openapi.json", "/docs", "/ui/", "/version", "/files/"}
+
+// Register mounts all routes on r and describes the API ones in the
+// OpenAPI document served at /openapi.json.
+func Register(r *gin.Engine, d Deps) {
+	docs := openapi.New("go-flylike-example", buildinfo.Get().Version)
+	r.GET("/openapi.json", docs.Handler())
+	r.GET("/docs", docs.UI("/openapi.json"))
+
+	// Metrics served by the admin listener stay off the public one, and
+	// metrics only pushed are not served at all.
+	if cfg := d.Config.Load(); !cfg.Admin.Metrics && cfg.Telemetry.Scraped() {
+		r.GET("/metrics", d.Metrics.Handler())
+	}
+	r.GET("/healthz", d.Health.LivenessHandler())
+	r.GET("/readyz", d.Health.ReadinessHandler())
+	// Kept for deployments whose health check still points at /health.
+	r.GET("/health", d.Health.ReadinessHandler())
+
+	r.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong from my-web-app 1!"})
+	})
+	r.GET("/region", region.Info)
+	r.GET("/version", versionInfo(d.Flags))
+
+	reports := []gin.HandlerFunc{limits.BodyLimit(maxReportBytes)}
+	if d.Limiter != nil {
+		reports = append(reports, ratelimit.Middleware(d.Limiter, ratelimit.ByIP))
+	}
+	r.POST(secheaders.ReportPath, append(reports, secheaders.Report)...)
+	r.GET("/ws", d.Hub.ServeWS)
+	r.GET("/events", d.Hub.ServeSSE)
+	r.GET("/poll/:channel", d.Hub.ServePoll(d.Config.Load().Realtime.PollTimeout))
+	if d.Presence != nil {
+		presenceGroup := r.Group("/presence", apiMiddleware(d)...)
+		d.Presence.Register(presenceGroup)
+		docs.Add(presenceGroup.BasePath(), presence.Operations()...)
+	}
+
+	authGroup := r.Group("/auth", bounded(d)...)
+	// Password logins find the user within the tenant, as registration
+	// stores it.
+	if cfg := d.Config.Load().Tenancy; cfg.Enabled {
+		authGroup.Use(tenant.Middleware(d.Tenants, cfg))
+	}
+	authGroup.Use(writeRouting(d)...)
+	if d.Limiter != nil {
+		authGroup.Use(ratelimit.Middleware(d.Limiter, ratelimit.ByIP))
+	}
+	authGroup.Use(audit.Middleware(d.Audit, d.Config), apperror.Recover())
+	d.Auth.Register(authGroup)
+	docs.Add(authGroup.BasePath(), auth.Operations()...)
+	oidcGroup := authGroup.Group("/oidc", d.Sessions.Middleware())
+	d.OIDC.Register(oidcGroup)
+	docs.Add(oidcGroup.BasePath(), oidc.Operations()...)
+
+	sessionGroup := r.Group("/session", bounded(d)...)
+	sessionGroup.Use(d.Sessions.Middleware(), session.CSRF(), audit.Middleware(d.Audit, d.Config), apperror.Recover())
+	registerSession(sessionGroup)
+	docs.Add(sessionGroup.BasePath(), sessionOperations()...)
+
+	api := d.Config.Load().API
+	v1 := r.Group("/api/v1", apiMiddleware(d, Deprecation(Sunset{
+		DeprecatedAt: api.V1DeprecatedAt,
+		SunsetAt:     api.V1SunsetAt,
+		Successor:    "/api/v2",
+		Docs:         api.DocsURL,
+	}))...)
+	registerV1(v1, d)
+	docs.Add(v1.BasePath(), v1Operations(!api.V1DeprecatedAt.IsZero())...)
+	v1.POST("/batch", batch.Handler(r, d.Config))
+	docs.Add(v1.BasePath(), batch.Operations()...)
+	registerV2(r.Group("/api/v2", apiMiddleware(d)...), d, docs)
+	geoGroup := r.Group("/api/v2", apiMiddleware(d)...)
+	d.Geo.Register(geoGroup)
+	docs.Add(geoGroup.BasePath(), geo.Operations()...)
+	uploadGroup := r.Group("/api/v2/uploads", uploadMiddleware(d)...)
+	d.Uploads.Register(uploadGroup)
+	docs.Add(uploadGroup.BasePath(), uploads.Operations()...)
+	sharedGroup := r.Group(uploads.SharedPath, uploadMiddleware(d)...)
+	d.Uploads.RegisterShared(sharedGroup)
+	docs.Add(sharedGroup.BasePath(), uploads.SharedOperations()...)
+	registerV2Streams(r.Group("/api/v2", streamMiddleware(d)...), d, docs)
+	opsGroup := r.Group(operations.Path, apiMiddleware(d)...)
+	d.Operations.Register(opsGroup)
+	docs.Add(opsGroup.BasePath(), operations.Operations()...)
+	opsStreams := r.Group(operations.Path, streamMiddleware(d)...)
+	d.Operations.RegisterStream(opsStreams)
+	docs.Add(opsStreams.BasePath(), operations.StreamOperations()...)
+	exports := r.Group("/api/v1/export", streamMiddleware(d)...)
+	d.Exports.Register(exports)
+	docs.Add(exports.BasePath(), d.Exports.Operations()...)
+	importGroup := r.Group("/api/v2/imports", importMiddleware(d)...)
+	d.Imports.Register(importGroup)
+	docs.Add(importGroup.BasePath(), d.Imports.Operations()...)
+
+	if cfg := d.Config.Load().GraphQL; cfg.Enabled {
+		gql := r.Group("/graphql", apiMiddleware(d)...)
+		graph.New(cfg, &graph.Resolver{Repository: d.Users, Accounts: d.Accounts, RBAC: d.RBAC, Sessions: d.Auth}).Register(gql)
+		docs.Add(gql.BasePath(), graph.Operations()...)
+	}
+
+	if secrets := d.Config.Load().Webhooks.GitHubSecrets; len(secrets) > 0 {
+		inbound := r.Group("/webhooks", bounded(d)...)
+		inbound.POST("/github", webhooks.Verify(webhooks.GitHub{Secrets: secrets}, d.Replays, webhooks.GitHubReplayWindow),
+			webhooks.GitHubReceiver(d.GitHubEvents))
+		docs.Add(inbound.BasePath(), webhooks.Operations()...)
+	}
+
+	if d.Views != nil {
+		r.GET("/ui/assets/*file", d.Views.Assets())
+		registerPages(r.Group("/ui", pageMiddleware(d)...), d)
+	}
+
+	d.Gateway.Register(r)
+
+	if d.Web != nil {
+		r.NoRoute(d.Web.Handle)
+	}
+	warnUndocumented(r, docs)
+	if d.SLO != nil {
+		declareObjectives(r, d.SLO)
+	}
+}
+
+// warnUndocumented flags API routes the OpenAPI document does not cover,
+// so that the document does not silently fall behind the handlers.
+func warnUndocumented(r *gin.Engine, docs *openapi.Document) {
+	for _, ri := range r.Routes() {
+		if !strings.HasPrefix(ri.Path, "/api/") && !strings.HasPrefix(ri.Path, "/auth/") && !strings.HasPrefix(ri.Path, "/session") {
+			continue
+		}
+		if !docs.Documented(ri.Method, ri.Path) {
+			slog.Warn("route missing from the openapi document", "method", ri.Method, "path", ri.Path)
+		}
+	}
+}
+
+// apiMiddleware is the stack shared by every API version; version-specific
+// handlers go first so, for example, a retired version is refused before
+// it consumes rate limit tokens.
+func apiMiddleware(d Deps, version ...gin.HandlerFunc) []gin.HandlerFunc {
+	stack := append(bounded(d), version...)
+	stack = append(stack, precondition.Middleware(d.Config))
+	if cfg := d.Config.Load().Tenancy; cfg.Enabled {
+		stack = append(stack, tenant.Middleware(d.Tenants, cfg))
+	}
+	stack = append(stack, writeRouting(d)...)
+	if d.Limiter != nil {
+		stack = append(stack, ratelimit.Middleware(d.Limiter, ratelimit.ByToken))
+	}
+	// After the limiter, so that throttled requests are not billed.
+	if d.Quota != nil {
+		stack = append(stack, d.Quota.Middleware())
+	}
+	// After the limiter, so that retries still count against the caller.
+	if d.Idempotency != nil {
+		stack = append(stack, idempotency.Middleware(d.Idempotency, d.Config.Load().Idempotency))
+	}
+	// After idempotency, so that replays, which change nothing, are not
+	// recorded again. Handler panics become errors that both see.
+	stack = append(stack, audit.Middleware(d.Audit, d.Config))
+	// Inside the audit log, which records rolled back requests too.
+	if d.DB != nil {
+		stack = append(stack, d.DB.Transaction())
+	}
+	return append(stack, httpcache.Conditional(), apperror.Recover())
+}
+
+// uploadMiddleware stands in for apiMiddleware on the upload routes: the
+// body limit follows the upload size, the budget suits large files, and the
+// idempotency middleware is left out because it buffers the body.
+func uploadMiddleware(d Deps) []gin.HandlerFunc {
+	cfg := d.Config.Load()
+	stack := []gin.HandlerFunc{
+		limits.BodyLimit(cfg.Uploads.MaxBytes + uploads.Overhead),
+		limits.Timeout(cfg.Uploads.Timeout),
+	}
+	if cfg.Tenancy.Enabled {
+		stack = append(stack, tenant.Middleware(d.Tenants, cfg.Tenancy))
+	}
+	stack = append(stack, writeRouting(d)...)
+	if d.Limiter != nil {
+		stack = append(stack, ratelimit.Middleware(d.Limiter, ratelimit.ByToken))
+	}
+	if d.Quota != nil {
+		stack = append(stack, d.Quota.Middleware())
+	}
+	stack = append(stack, admit(d)...)
+	return append(stack, audit.Middleware(d.Audit, d.Config), apperror.Recover())
+}
+
+// importMiddleware stands in for apiMiddleware on the import routes: the
+// body limit follows the import size and the budget the upload one, and the
+// idempotency middleware is left out because it buffers the body. The
+// transaction stays, so an import only starts once its file is stored.
+func importMiddleware(d Deps) []gin.HandlerFunc {
+	cfg := d.Config.Load()
+	stack := []gin.HandlerFunc{
+		limits.BodyLimit(cfg.Imports.MaxBytes + uploads.Overhead),
+		limits.Timeout(cfg.Uploads.Timeout),
+	}
+	if cfg.Tenancy.Enabled {
+		stack = append(stack, tenant.Middleware(d.Tenants, cfg.Tenancy))
+	}
+	stack = append(stack, writeRouting(d)...)
+	if d.Limiter != nil {
+		stack = append(stack, ratelimit.Middleware(d.Limiter, ratelimit.ByToken))
+	}
+	if d.Quota != nil {
+		stack = append(stack, d.Quota.Middleware())
+	}
+	stack = append(stack, admit(d)...)
+	stack = append(stack, audit.Middleware(d.Audit, d.Config))
+	if d.DB != nil {
+		stack = append(stack, d.DB.Transaction())
+	}
+	return append(stack, apperror.Recover())
+}
+
+// streamMiddleware stands in for apiMiddleware on the routes streaming
+// whole result sets: they run for as long as the client keeps reading, and
+// conditional requests are left out because they buffer the response.
+func streamMiddleware(d Deps) []gin.HandlerFunc {
+	cfg := d.Config.Load()
+	stack := []gin.HandlerFunc{limits.BodyLimit(cfg.Limits.MaxBodyBytes), bandwidth.Throttled()}
+	if cfg.Tenancy.Enabled {
+		stack = append(stack, tenant.Middleware(d.Tenants, cfg.Tenancy))
+	}
+	stack = append(stack, writeRouting(d)...)
+	if d.Limiter != nil {
+		stack = append(stack, ratelimit.Middleware(d.Limiter, ratelimit.ByToken))
+	}
+	if d.Quota != nil {
+		stack = append(stack, d.Quota.Middleware())
+	}
+	stack = append(stack, admit(d)...)
+	return append(stack, audit.Middleware(d.Audit, d.Config), apperror.Recover())
+}
+
+// pageMiddleware is the stack of the HTML pages: browsers hold a session
+// rather than a token, so forms are checked for the CSRF token and callers
+// are limited by address.
+func pageMiddleware(d Deps) []gin.HandlerFunc {
+	stack := bounded(d)
+	if cfg := d.Config.Load().Tenancy; cfg.Enabled {
+		stack = append(stack, tenant.Middleware(d.Tenants, cfg))
+	}
+	stack = append(stack, writeRouting(d)...)
+	if d.Limiter != nil {
+		stack = append(stack, ratelimit.Middleware(d.Limiter, ratelimit.ByIP))
+	}
+	return append(stack, d.Sessions.Middleware(), session.CSRF(), audit.Middleware(d.Audit, d.Config), apperror.Recover())
+}
+
+// admit queues the requests of the expensive groups, uploads, imports and
+// streams, for a slot of their priority class. It goes after the limiter
+// and quota, so refused requests do not wait, and before the audit log and
+// transaction, so waiting requests hold neither.
+func admit(d Deps) []gin.HandlerFunc {
+	if d.Admission == nil {
+		return nil
+	}
+	return []gin.HandlerFunc{d.Admission.Middleware()}
+}
+
+// writeRouting sends writes to the primary the way the database is set up:
+// replayed there by the platform proxy, or executed locally over the
+// network in "forward" mode. With a read replica, clients read the primary
+// for a while after writing.
+func writeRouting(d Deps) []gin.HandlerFunc {
+	cfg := d.Config.Load().Database
+	var stack []gin.HandlerFunc
+	if cfg.WriteMode != "forward" {
+		stack = append(stack, region.PrimaryWrites())
+	}
+	if cfg.ReplicaURL != "" {
+		stack = append(stack, region.ReadYourWrites(cfg.MaxReplicaLag))
+	}
+	return stack
+}
+
+// bounded limits body size and handler time for request/response routes.
+// Streaming endpoints (/ws, /events, /poll, the gateway, result streams) are
+// deliberately exempt.
+func bounded(d Deps) []gin.HandlerFunc {
+	cfg := d.Config.Load()
+	return []gin.HandlerFunc{
+		limits.BodyLimit(cfg.Limits.MaxBodyBytes),
+		limits.Timeout(cfg.Timeouts.Handler),
+	}
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/session"
+)
+
+// registerSession mounts a small browser-session demo: GET counts visits
+// and hands out the CSRF token, DELETE ends the session.
+func registerSession(g *gin.RouterGroup) {
+	g.GET("", func(c *gin.Context) {
+		s := session.From(c)
+		visits, _ := strconv.Atoi(s.Get("visits"))
+		s.Set("visits", strconv.Itoa(visits+1))
+		c.JSON(http.StatusOK, render.OK(i18n.T(c, "session active"), gin.H{
+			"csrf_token": s.CSRFToken(),
+			"values":     s.Values(),
+		}))
+	})
+
+	g.DELETE("", func(c *gin.Context) {
+		session.From(c).Destroy()
+		c.JSON(http.StatusOK, render.OK(i18n.T(c, "session ended"), nil))
+	})
+}
@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/users"
+)
+
+// v1 returns bare resources and {"error": "..."} bodies. It is kept for
+// existing clients; new fields only land in v2.
+func registerV1(g *gin.RouterGroup, d Deps) {
+	// Users carry their email, so reading them takes authentication.
+	g.GET("/users", auth.Required(), apikeys.RequireScope(users.PermRead), d.Cache.Handler(0, users.CacheTag), func(c *gin.Context) {
+		list, err := d.Users.List(c.Request.Context())
+		if err != nil {
+			v1Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"users": list})
+	})
+
+	g.GET("/users/:id", auth.Required(), apikeys.RequireScope(users.PermRead), d.Cache.Handler(0, users.CacheTag), func(c *gin.Context) {
+		u, err := d.Users.Get(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, users.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			v1Error(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, u)
+	})
+}
+
+// v1Error logs err and answers it in the v1 error shape, with the status
+// apperror assigns, so that expired deadlines still read as 504.
+func v1Error(c *gin.Context, err error) {
+	c.Error(err)
+	e := apperror.From(err)
+	c.JSON(e.Status(), gin.H{"error": e.Message})
+}
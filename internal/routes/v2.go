@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/audit"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/hooks"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/openapi"
+	"go-flylike-example/internal/precondition"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/quota"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/rpc"
+	"go-flylike-example/internal/rpc/userspb"
+	"go-flylike-example/internal/stream"
+	"go-flylike-example/internal/users"
+)
+
+type createUserRequest struct {
+	Name  string `json:"name" xml:"name" binding:"required,notblank,max=100"`
+	Email string `json:"email" xml:"email" binding:"required,email,max=254"`
+}
+
+// v2 wraps every response in the {"status", "message", "data"} envelope
+// used by the platform endpoints; errors are rendered by apperror. The
+// users routes speak every format of the render package.
+func registerV2(g *gin.RouterGroup, d Deps, docs *openapi.Document) {
+	docs.Add(g.BasePath(), v2Operations()...)
+
+	// Users carry their email, so reading them takes authentication.
+	g.GET("/users", auth.Required(), apikeys.RequireScope(users.PermRead), d.Cache.Handler(0, users.CacheTag), func(c *gin.Context) {
+		spec, ok := query.Bind(c, users.ListOptions)
+		if !ok || !render.Select(c, users.Fields) {
+			return
+		}
+		list, page, err := d.Users.ListPage(c.Request.Context(), spec)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		body := render.OK(i18n.T(c, "users listed"), list)
+		body.Meta = &page
+		render.Negotiate(c, http.StatusOK, body, rpc.UserPageProto(list, page))
+	})
+
+	g.GET("/users/:id", auth.Required(), apikeys.RequireScope(users.PermRead), d.Cache.Handler(0, users.CacheTag), func(c *gin.Context) {
+		if !render.Select(c, users.Fields) {
+			return
+		}
+		u, err := d.Users.Get(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		precondition.Set(c, u.Version)
+		render.Negotiate(c, http.StatusOK, render.OK(i18n.T(c, "user found"), u), rpc.UserProto(u))
+	})
+
+	g.POST("/users", auth.Required(), rbac.Require(users.PermWrite), apikeys.RequireScope(users.PermWrite), func(c *gin.Context) {
+		var (
+			req createUserRequest
+			msg userspb.CreateUserRequest
+		)
+		if !render.Select(c, users.Fields) || !render.Bind(c, &req, &msg, func() { req.Name, req.Email = msg.GetName(), msg.GetEmail() }) {
+			return
+		}
+		u, err := d.Users.Create(c.Request.Context(), req.Name, req.Email)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		precondition.Set(c, u.Version)
+		render.Negotiate(c, http.StatusCreated, render.OK(i18n.T(c, "user created"), u), rpc.UserProto(u))
+	})
+
+	g.DELETE("/users/:id", auth.Required(), rbac.Require(users.PermWrite), apikeys.RequireScope(users.PermWrite), func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if err := d.Users.Delete(ctx, c.Param("id")); err != nil {
+			c.Error(err)
+			return
+		}
+		if err := d.Auth.RevokeSubject(context.WithoutCancel(ctx), users.Subject(c.Param("id"))); err != nil {
+			c.Error(err)
+			return
+		}
+		render.Negotiate(c, http.StatusOK, render.OK(i18n.T(c, "user deleted"), nil), nil)
+	})
+
+	g.GET("/flags", d.Flags.Handler())
+
+	account := g.Group("/account")
+	d.Accounts.Register(account)
+	docs.Add(account.BasePath(), users.Operations()...)
+
+	keys := g.Group("/api-keys")
+	d.APIKeys.Register(keys)
+	docs.Add(keys.BasePath(), apikeys.Operations()...)
+	roles := g.Group("/rbac")
+	d.RBAC.Register(roles)
+	docs.Add(roles.BasePath(), rbac.Operations()...)
+	auditLog := g.Group("/audit")
+	d.Audit.Register(auditLog)
+	docs.Add(auditLog.BasePath(), audit.Operations()...)
+	webhooks := g.Group("/webhooks")
+	d.Webhooks.Register(webhooks)
+	docs.Add(webhooks.BasePath(), hooks.Operations()...)
+	if d.Quota != nil {
+		usage := g.Group("/usage")
+		d.Quota.Register(usage)
+		docs.Add(usage.BasePath(), quota.Operations()...)
+	}
+}
+
+// registerV2Streams mounts the v2 routes that stream whole result sets, as
+// NDJSON unless the client only accepts JSON, which gets one array. They
+// take the filters and sort of the matching list routes.
+func registerV2Streams(g *gin.RouterGroup, d Deps, docs *openapi.Document) {
+	docs.Add(g.BasePath(), v2StreamOperations()...)
+
+	g.GET("/users/stream", auth.Required(), apikeys.RequireScope(users.PermRead), func(c *gin.Context) {
+		spec, ok := query.Bind(c, users.ListOptions)
+		if !ok {
+			return
+		}
+		list := d.Users.Stream(c.Request.Context(), spec)
+		if c.NegotiateFormat(stream.ContentTypeNDJSON, gin.MIMEJSON) == gin.MIMEJSON {
+			stream.JSONArray(c, list)
+			return
+		}
+		stream.NDJSON(c, list)
+	})
+}
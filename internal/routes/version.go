@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/buildinfo"
+	"go-flylike-example/internal/featureflag"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/render"
+)
+
+// versionInfo answers GET /version with the build of this instance and the
+// feature flags it has on, for deploy verification. Features maps each
+// enabled flag to the percentage of callers it is rolled out to.
+func versionInfo(flags *featureflag.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		features := map[string]int{}
+		for _, f := range flags.Flags(c.Request.Context()) {
+			if f.Enabled && f.Percentage > 0 {
+				features[f.Name] = f.Percentage
+			}
+		}
+		c.JSON(http.StatusOK, render.OK(i18n.T(c, "build info"), struct {
+			buildinfo.Info
+			Semver   bool           `json:"semver"`
+			Features map[string]int `json:"features"`
+		}{buildinfo.Get(), buildinfo.Get().Semver(), features}))
+	}
+}
@@ -0,0 +1,161 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/rpc/userspb"
+	"go-flylike-example/internal/tenant"
+	"go-flylike-example/internal/users"
+)
+
+// Auth is what calls are authenticated and authorized with: the tokens,
+// API keys, roles and tenants of the HTTP API.
+type Auth struct {
+	Tokens  *auth.Service
+	Keys    *apikeys.Repository
+	RBAC    *rbac.Service
+	Tenants *tenant.Repository
+	Tenancy config.Tenancy
+}
+
+// requirement is what a method takes of its callers besides
+// authentication: an API key scope, which only narrows API keys as
+// apikeys.RequireScope does, and a permission, as rbac.Require.
+type requirement struct {
+	scope string
+	perm  string
+}
+
+// requirements are those of the methods of the users service. Methods of
+// other services, except the public ones, only take authentication.
+var requirements = map[string]requirement{
+	userspb.UserService_ListUsers_FullMethodName:  {scope: users.PermRead},
+	userspb.UserService_GetUser_FullMethodName:    {scope: users.PermRead},
+	userspb.UserService_CreateUser_FullMethodName: {scope: users.PermWrite, perm: users.PermWrite},
+}
+
+// public are the services anybody may call: health checks and reflection,
+// which describes the API but none of its data.
+var public = []string{"/grpc.health.v1.Health/", "/grpc.reflection."}
+
+// UnaryServerInterceptor resolves the tenant and the caller of each call
+// from its metadata, as the tenant, API key and JWT middleware do from
+// headers, and refuses calls the caller may not make.
+func (a *Auth) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.admit(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming variant of UnaryServerInterceptor.
+func (a *Auth) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.admit(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// admit returns ctx carrying the tenant of the call, or an error refusing
+// the call to method.
+func (a *Auth) admit(ctx context.Context, method string) (context.Context, error) {
+	for _, prefix := range public {
+		if strings.HasPrefix(method, prefix) {
+			return ctx, nil
+		}
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if a.Tenancy.Enabled {
+		var named string
+		if a.Tenancy.Header != "" {
+			named = first(md, strings.ToLower(a.Tenancy.Header))
+		}
+		t, err := tenant.Resolve(ctx, a.Tenants, a.Tenancy, named, first(md, ":authority"))
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			ctx = tenant.WithTenant(ctx, t)
+		}
+	}
+
+	claims, key, err := a.caller(ctx, md)
+	if err != nil {
+		return nil, err
+	}
+	need := requirements[method]
+	if key != nil && need.scope != "" && !key.HasScope(need.scope) {
+		return nil, apperror.Newf(apperror.KindForbidden, "api key lacks scope %s", need.scope)
+	}
+	if need.perm != "" {
+		allowed, err := a.RBAC.Allowed(ctx, claims.Subject, need.perm)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, apperror.Newf(apperror.KindForbidden, "missing permission %s", need.perm)
+		}
+	}
+	return ctx, nil
+}
+
+// caller returns the claims of the API key or access token of md, and the
+// key if it was one.
+func (a *Auth) caller(ctx context.Context, md metadata.MD) (*auth.Claims, *apikeys.Key, error) {
+	token, _ := strings.CutPrefix(first(md, "authorization"), "Bearer ")
+	secret := strings.TrimSpace(first(md, strings.ToLower(apikeys.HeaderAPIKey)))
+	if secret == "" && strings.HasPrefix(token, apikeys.Prefix) {
+		secret = token
+	}
+	if secret != "" {
+		k, err := a.Keys.Authenticate(ctx, secret)
+		if errors.Is(err, apikeys.ErrInvalidKey) {
+			return nil, nil, apperror.Unauthorized("invalid or expired access token")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: apikeys.SubjectPrefix + k.ID}}, k, nil
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return nil, nil, apperror.Unauthorized("authentication required")
+	}
+	claims, err := a.Tokens.Verify(token)
+	if err != nil {
+		return nil, nil, apperror.Unauthorized("invalid or expired access token")
+	}
+	return claims, nil, nil
+}
+
+// first returns the first value of key in md, or "".
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// contextStream overrides the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
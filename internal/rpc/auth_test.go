@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/metrics"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/rpc/userspb"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+	"go-flylike-example/internal/users"
+)
+
+// callers are the credentials of the metadata TestAuth sends.
+type callers struct {
+	admin, user string // access tokens
+	reader      string // API key with users:read
+	writer      string // API key with users:read and users:write
+}
+
+// serve starts a server on an in-memory database with tenancy on, and
+// returns a connection to it and the credentials of its callers.
+func serve(t *testing.T) (*grpc.ClientConn, callers) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.URL = "file:rpc-" + rand.Text() + "?mode=memory&cache=shared"
+	cfg.Auth.JWTSecret = rand.Text() + rand.Text()
+	db, err := store.Open(t.Context(), cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB().ExecContext(t.Context(),
+		`INSERT INTO tenants (id, slug, name, settings) VALUES ('t_acme', 'acme', 'Acme Inc', '{}')`); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := auth.New(cfg.Auth, db, auth.CredentialsChain{
+		auth.StaticCredentials{Username: "alice", Password: "alice-password"},
+		auth.StaticCredentials{Username: "bob", Password: "bob-password"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := rbac.New(db)
+	if err := roles.Assign(t.Context(), "alice", rbac.AdminRole); err != nil {
+		t.Fatal(err)
+	}
+	keys := apikeys.NewRepository(db)
+	var c callers
+	for _, who := range []struct {
+		token    *string
+		name     string
+		password string
+	}{{&c.admin, "alice", "alice-password"}, {&c.user, "bob", "bob-password"}} {
+		pair, err := tokens.Login(t.Context(), who.name, who.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*who.token = pair.AccessToken
+	}
+	if _, c.reader, err = keys.Create(t.Context(), "alice", apikeys.NewKey{Name: "reader", Scopes: []string{users.PermRead}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, c.writer, err = keys.Create(t.Context(), "alice", apikeys.NewKey{Name: "writer", Scopes: []string{users.PermRead, users.PermWrite}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tenancy := cfg.Tenancy
+	tenancy.Enabled = true
+	srv := New(slog.New(slog.DiscardHandler), metrics.New(), users.NewRepository(db),
+		&Auth{Tokens: tokens, Keys: keys, RBAC: roles, Tenants: tenant.NewRepository(db), Tenancy: tenancy})
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, c
+}
+
+func TestAuth(t *testing.T) {
+	conn, c := serve(t)
+	client := userspb.NewUserServiceClient(conn)
+	list := func(ctx context.Context) error {
+		_, err := client.ListUsers(ctx, &userspb.ListUsersRequest{})
+		return err
+	}
+	create := func(ctx context.Context) error {
+		_, err := client.CreateUser(ctx, &userspb.CreateUserRequest{Name: "Ada", Email: rand.Text() + "@example.com"})
+		return err
+	}
+
+	tests := []struct {
+		name string
+		md   []string
+		call func(context.Context) error
+		want codes.Code
+	}{
+		{"list anonymously", nil, list, codes.Unauthenticated},
+		{"list with a bad token", []string{"authorization", "Bearer nope"}, list, codes.Unauthenticated},
+		{"list with a bad key", []string{"x-api-key", apikeys.Prefix + "nope"}, list, codes.Unauthenticated},
+		{"list as a user", []string{"authorization", "Bearer " + c.user}, list, codes.OK},
+		{"list with a read key", []string{"x-api-key", c.reader}, list, codes.OK},
+		{"list with a read key as bearer", []string{"authorization", "Bearer " + c.reader}, list, codes.OK},
+		{"list in a tenant", []string{"authorization", "Bearer " + c.user, "x-tenant", "acme"}, list, codes.OK},
+		{"list in an unknown tenant", []string{"authorization", "Bearer " + c.user, "x-tenant", "nobody"}, list, codes.NotFound},
+		{"create anonymously", nil, create, codes.Unauthenticated},
+		{"create as a user", []string{"authorization", "Bearer " + c.user}, create, codes.PermissionDenied},
+		{"create as an admin", []string{"authorization", "Bearer " + c.admin}, create, codes.OK},
+		{"create with a read key", []string{"x-api-key", c.reader}, create, codes.PermissionDenied},
+		// Scopes only narrow: the key's own subject lacks the permission.
+		{"create with a write key", []string{"x-api-key", c.writer}, create, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := t.Context()
+			if tt.md != nil {
+				ctx = metadata.AppendToOutgoingContext(ctx, tt.md...)
+			}
+			if got := status.Code(tt.call(ctx)); got != tt.want {
+				t.Fatalf("code = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthPublic(t *testing.T) {
+	conn, _ := serve(t)
+	resp, err := healthpb.NewHealthClient(conn).Check(t.Context(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %s, want SERVING", resp.GetStatus())
+	}
+}
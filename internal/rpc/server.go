@@ -0,0 +1,91 @@
+// Package rpc serves the gRPC API next to the HTTP server. It shares the
+// HTTP stack's logging, metrics, error handling and authentication through
+// interceptors.
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=go-flylike-example --go-grpc_out=../.. --go-grpc_opt=module=go-flylike-example users/v1/users.proto
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+	healthgrpc "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/metrics"
+	"go-flylike-example/internal/rpc/userspb"
+	"go-flylike-example/internal/users"
+)
+
+// Server is the gRPC server with the standard health and reflection
+// services registered.
+type Server struct {
+	grpc   *grpc.Server
+	health *healthgrpc.Server
+}
+
+// New builds the server, authenticating calls with a. Interceptors run
+// outermost first: logging sees the final status code produced by the
+// error interceptor, which also maps the refusals of a.
+func New(logger *slog.Logger, m *metrics.Metrics, repo *users.Repository, a *Auth) *Server {
+	s := &Server{
+		grpc: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(
+				logging.UnaryServerInterceptor(logger),
+				m.UnaryServerInterceptor(),
+				apperror.UnaryServerInterceptor(),
+				a.UnaryServerInterceptor(),
+			),
+			grpc.ChainStreamInterceptor(
+				logging.StreamServerInterceptor(logger),
+				m.StreamServerInterceptor(),
+				apperror.StreamServerInterceptor(),
+				a.StreamServerInterceptor(),
+			),
+		),
+		health: healthgrpc.NewServer(),
+	}
+
+	userspb.RegisterUserServiceServer(s.grpc, &userService{repo: repo})
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus(userspb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+// Serve accepts connections on lis until Shutdown. Like http.Server it
+// returns nil after a graceful stop.
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.grpc.Serve(lis); err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
+}
+
+// Shutdown reports NOT_SERVING so health-checking clients move away, then
+// waits for in-flight calls to finish. When ctx expires first, remaining
+// calls are cancelled and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		<-stopped
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/rpc/userspb"
+	"go-flylike-example/internal/users"
+)
+
+// userService implements userspb.UserServiceServer on the users repository
+// also used by the REST API.
+type userService struct {
+	userspb.UnimplementedUserServiceServer
+	repo *users.Repository
+}
+
+func (s *userService) ListUsers(ctx context.Context, _ *userspb.ListUsersRequest) (*userspb.ListUsersResponse, error) {
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &userspb.ListUsersResponse{Users: make([]*userspb.User, 0, len(list))}
+	for i := range list {
+		resp.Users = append(resp.Users, UserProto(&list[i]))
+	}
+	return resp, nil
+}
+
+func (s *userService) GetUser(ctx context.Context, req *userspb.GetUserRequest) (*userspb.User, error) {
+	if req.GetId() == "" {
+		return nil, apperror.BadRequest("id is required")
+	}
+	u, err := s.repo.Get(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return UserProto(u), nil
+}
+
+// CreateUser applies the same rules as the v2 REST endpoint.
+func (s *userService) CreateUser(ctx context.Context, req *userspb.CreateUserRequest) (*userspb.User, error) {
+	name := strings.TrimSpace(req.GetName())
+	if name == "" || len(req.GetName()) > 100 {
+		return nil, apperror.BadRequest("name is required and must be at most 100 characters")
+	}
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil || len(req.GetEmail()) > 254 {
+		return nil, apperror.BadRequest("email must be a valid address")
+	}
+	u, err := s.repo.Create(ctx, req.GetName(), req.GetEmail())
+	if err != nil {
+		return nil, err
+	}
+	return UserProto(u), nil
+}
+
+// UserProto converts u to its Protobuf form, which HTTP clients can ask
+// for too.
+func UserProto(u *users.User) *userspb.User {
+	pb := &userspb.User{
+		Id:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+	if u.EmailVerifiedAt != nil {
+		pb.EmailVerifiedAt = timestamppb.New(*u.EmailVerifiedAt)
+	}
+	return pb
+}
+
+// UserPageProto converts a page of users to its Protobuf form.
+func UserPageProto(list []users.User, page query.Page) *userspb.UserPage {
+	pb := &userspb.UserPage{
+		Users:      make([]*userspb.User, 0, len(list)),
+		Limit:      int32(page.Limit),
+		Page:       int32(page.Page),
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
+	}
+	for i := range list {
+		pb.Users = append(pb.Users, UserProto(&list[i]))
+	}
+	return pb
+}
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/store"
+)
+
+// leaseName names the scheduler's lease in Redis and in the leases table.
+const leaseName = "scheduler"
+
+// Elector decides which instance is the leader.
+type Elector interface {
+	// Acquire takes the lease, or renews it when this instance already
+	// holds it, for ttl and reports whether this instance is the leader.
+	Acquire(ctx context.Context, ttl time.Duration) (bool, error)
+	// Release gives the lease up if this instance holds it.
+	Release(ctx context.Context) error
+}
+
+// InstanceID identifies this process among the instances competing for the
+// lease: the Fly machine id or host name, plus a random suffix so that a
+// restarted process does not inherit its predecessor's lease.
+func InstanceID() string {
+	host := os.Getenv("FLY_MACHINE_ID")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// RedisElector holds the lease as a Redis key with an expiry.
+type RedisElector struct {
+	client redis.Cmdable
+	key    string
+	id     string
+}
+
+// NewRedisElector returns an Elector competing as id.
+func NewRedisElector(client redis.Cmdable, id string) *RedisElector {
+	return &RedisElector{client: client, key: "lease:" + leaseName, id: id}
+}
+
+// renewScript extends the key only while it still names the caller.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes the key only while it still names the caller.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Acquire implements Elector.
+func (e *RedisElector) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	ok, err := e.client.SetNX(ctx, e.key, e.id, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("scheduler: redis lease: %w", err)
+	}
+	if ok {
+		return true, nil
+	}
+	n, err := renewScript.Run(ctx, e.client, []string{e.key}, e.id, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("scheduler: redis lease: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Release implements Elector.
+func (e *RedisElector) Release(ctx context.Context) error {
+	if err := releaseScript.Run(ctx, e.client, []string{e.key}, e.id).Err(); err != nil {
+		return fmt.Errorf("scheduler: redis release: %w", err)
+	}
+	return nil
+}
+
+// SQLElector holds the lease as a row of the leases table, which works the
+// same on Postgres and SQLite.
+type SQLElector struct {
+	db *store.Store
+	id string
+}
+
+// NewSQLElector returns an Elector competing as id.
+func NewSQLElector(db *store.Store, id string) *SQLElector {
+	return &SQLElector{db: db, id: id}
+}
+
+// Acquire implements Elector. The upsert only overwrites a row the caller
+// holds or one that has expired, so at most one instance gets a row back.
+func (e *SQLElector) Acquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := e.db.Writer(ctx).ExecContext(ctx, e.db.Rebind(
+		`INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		 WHERE leases.holder = excluded.holder OR leases.expires_at < ?`),
+		leaseName, e.id, now.Add(ttl).Unix(), now.Unix())
+	if err != nil {
+		return false, fmt.Errorf("scheduler: sql lease: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("scheduler: sql lease: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Release implements Elector.
+func (e *SQLElector) Release(ctx context.Context) error {
+	_, err := e.db.Writer(ctx).ExecContext(ctx, e.db.Rebind(
+		`DELETE FROM leases WHERE name = ? AND holder = ?`), leaseName, e.id)
+	if err != nil {
+		return fmt.Errorf("scheduler: sql release: %w", err)
+	}
+	return nil
+}
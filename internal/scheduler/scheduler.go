@@ -0,0 +1,315 @@
+// Package scheduler runs tasks on cron schedules inside the server process.
+// In a deployment of several instances they elect a leader through a lease
+// in Redis or the database, and only the leader runs scheduled tasks; any
+// instance can run a task on demand.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+)
+
+// tick is how often due tasks are looked for.
+const tick = time.Second
+
+// Off disables a task when given as its schedule.
+const Off = "off"
+
+var (
+	// ErrNotFound is returned for unknown task names.
+	ErrNotFound = apperror.NotFound("scheduled task not found")
+	// ErrRunning is returned when triggering a task that is still running.
+	ErrRunning = apperror.Conflict("scheduled task is already running")
+)
+
+// Func is the body of a task. Its context is cancelled when the scheduler
+// shuts down and the drain timeout runs out.
+type Func func(ctx context.Context) error
+
+// Status describes a task for the admin API.
+type Status struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Running      bool       `json:"running"`
+}
+
+type task struct {
+	name     string
+	spec     string
+	schedule cron.Schedule // nil when off
+	fn       Func
+
+	mu           sync.Mutex
+	next         time.Time
+	running      bool
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastErr      string
+}
+
+// Scheduler runs registered tasks while this instance is the leader.
+type Scheduler struct {
+	cfg     config.Scheduler
+	elector Elector
+
+	mu    sync.RWMutex
+	tasks map[string]*task
+
+	started  atomic.Bool
+	leader   atomic.Bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	loopDone chan struct{}
+	running  sync.WaitGroup
+}
+
+// New returns a Scheduler electing its leader through elector; call Start
+// once the tasks are added.
+func New(cfg config.Scheduler, elector Elector) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		cfg:      cfg,
+		elector:  elector,
+		tasks:    make(map[string]*task),
+		ctx:      ctx,
+		cancel:   cancel,
+		stop:     make(chan struct{}),
+		loopDone: make(chan struct{}),
+	}
+}
+
+// Add registers fn under name to run on spec, a five-field cron expression
+// or a descriptor such as "@hourly" or "@every 10m". A schedule configured
+// for name takes precedence over spec.
+func (s *Scheduler) Add(name, spec string, fn Func) error {
+	if override, ok := s.cfg.Schedules[strings.ToLower(name)]; ok {
+		spec = override
+	}
+	t := &task{name: name, spec: spec, fn: fn}
+	if spec != Off {
+		sched, err := cron.ParseStandard(spec)
+		if err != nil {
+			return fmt.Errorf("scheduler: task %s: %w", name, err)
+		}
+		t.schedule = sched
+		t.next = sched.Next(time.Now())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, dup := s.tasks[name]; dup {
+		return fmt.Errorf("scheduler: task %s added twice", name)
+	}
+	s.tasks[name] = t
+	return nil
+}
+
+// Start begins competing for leadership and running due tasks. Without
+// Start, tasks only run when triggered.
+func (s *Scheduler) Start() {
+	s.started.Store(true)
+	go s.loop()
+	slog.Info("scheduler started", "tasks", len(s.tasks))
+}
+
+// Leader reports whether this instance currently runs scheduled tasks.
+func (s *Scheduler) Leader() bool {
+	return s.leader.Load()
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.loopDone)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	var renewed time.Time
+	for {
+		now := time.Now()
+		// Renew at a third of the lease, so two renewals may fail before
+		// another instance can take over.
+		if now.Sub(renewed) >= s.cfg.Lease/3 {
+			s.elect()
+			renewed = now
+		}
+		s.runDue(now)
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) elect() {
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.Lease/3)
+	defer cancel()
+	leader, err := s.elector.Acquire(ctx, s.cfg.Lease)
+	if err != nil {
+		// Without a confirmed lease another instance may take over, so
+		// stop running tasks rather than risk running them twice.
+		slog.Error("scheduler lease not renewed", "error", err)
+		leader = false
+	}
+	if was := s.leader.Swap(leader); was != leader {
+		if leader {
+			slog.Info("scheduler leadership acquired")
+		} else {
+			slog.Info("scheduler leadership lost")
+		}
+	}
+}
+
+// runDue starts the tasks whose time has come. Followers advance the
+// schedule too, so that next run times stay meaningful everywhere.
+func (s *Scheduler) runDue(now time.Time) {
+	leader := s.leader.Load()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.tasks {
+		t.mu.Lock()
+		due := t.schedule != nil && !t.next.After(now)
+		if due {
+			t.next = t.schedule.Next(now)
+		}
+		start := due && leader && !t.running
+		if due && leader && t.running {
+			slog.Warn("scheduled task skipped, previous run still going", "task", t.name)
+		}
+		if start {
+			t.running = true
+		}
+		t.mu.Unlock()
+		if start {
+			s.running.Add(1)
+			go s.run(t, "schedule")
+		}
+	}
+}
+
+// Trigger runs the task name now, in the background, whichever instance
+// leads.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.RLock()
+	t, ok := s.tasks[name]
+	s.mu.RUnlock()
+	if !ok {
+		return ErrNotFound
+	}
+	t.mu.Lock()
+	if t.running {
+		t.mu.Unlock()
+		return ErrRunning
+	}
+	t.running = true
+	t.mu.Unlock()
+	s.running.Add(1)
+	go s.run(t, "manual")
+	return nil
+}
+
+func (s *Scheduler) run(t *task, trigger string) {
+	defer s.running.Done()
+	logger := slog.With("task", t.name, "trigger", trigger)
+	start := time.Now()
+	err := safeRun(s.ctx, t.fn)
+	d := time.Since(start)
+
+	t.mu.Lock()
+	t.running = false
+	t.lastRun, t.lastDuration, t.lastErr = start, d, ""
+	if err != nil {
+		t.lastErr = err.Error()
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		logger.Error("scheduled task failed", "error", err, "duration", d.String())
+		return
+	}
+	logger.Info("scheduled task done", "duration", d.String())
+}
+
+func safeRun(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return fn(ctx)
+}
+
+// Tasks returns the state of every task, ordered by name.
+func (s *Scheduler) Tasks() []Status {
+	s.mu.RLock()
+	list := make([]Status, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		t.mu.Lock()
+		st := Status{Name: t.name, Schedule: t.spec, Running: t.running, LastError: t.lastErr}
+		if t.schedule != nil {
+			next := t.next
+			st.NextRun = &next
+		}
+		if !t.lastRun.IsZero() {
+			last := t.lastRun
+			st.LastRun = &last
+			st.LastDuration = t.lastDuration.String()
+		}
+		t.mu.Unlock()
+		list = append(list, st)
+	}
+	s.mu.RUnlock()
+	slices.SortFunc(list, func(a, b Status) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Shutdown stops scheduling, waits for running tasks and gives up the
+// lease so another instance can lead right away. If ctx expires first the
+// tasks are cancelled.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	if s.started.Load() {
+		<-s.loopDone
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(finished)
+	}()
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		s.cancel()
+		<-finished
+		err = ctx.Err()
+	}
+
+	if s.leader.Load() {
+		if rerr := s.elector.Release(context.WithoutCancel(ctx)); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+	}
+	s.cancel()
+	return err
+}
@@ -0,0 +1,329 @@
+package sdk
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"path"
+	"slices"
+	"strings"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Go returns the source of a Go package named pkg with a client of the API
+// s describes.
+func Go(s *Spec, pkg string) ([]byte, error) {
+	g := &goGen{names: newNamer()}
+	methods, skips := s.methods()
+	var body strings.Builder
+	for _, m := range methods {
+		g.method(&body, m)
+	}
+	var decls strings.Builder
+	for i := 0; i < len(g.names.order); i++ {
+		name := g.names.order[i]
+		g.decl(&decls, name, g.names.decls[name])
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by sdkgen from the OpenAPI document of %s. DO NOT EDIT.\n\n", s.Info.Title)
+	fmt.Fprintf(&b, "// Package %s is a generated client of the %s API.\n", pkg, s.Info.Title)
+	if len(skips) > 0 {
+		b.WriteString("// It leaves out:\n//\n")
+		for _, sk := range skips {
+			fmt.Fprintf(&b, "//   - %s %s: %s\n", sk.verb, sk.path, sk.reason)
+		}
+	}
+	fmt.Fprintf(&b, "package %s\n\nimport (\n", pkg)
+	imports := []string{"bytes", "context", "encoding/json", "errors", "fmt", "io", "mime", "net/http", "net/url"}
+	if g.time {
+		imports = append(imports, "time")
+	}
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "\t%q\n", imp)
+	}
+	b.WriteString("\n\t\"go-flylike-example/api\"\n)\n")
+	b.WriteString(goRuntime)
+	b.WriteString(body.String())
+	b.WriteString(decls.String())
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("sdk: format go client: %w", err)
+	}
+	return src, nil
+}
+
+type goGen struct {
+	names *namer
+	time  bool
+}
+
+// typ returns the Go type of s, declaring the objects it holds, named hint
+// when they have no title.
+func (g *goGen) typ(s *openapi.Schema, hint, where string) string {
+	if s == nil {
+		return "any"
+	}
+	if s.Ref != "" {
+		if name := path.Base(s.Ref); slices.Contains(shared, name) {
+			return "api." + name
+		}
+		return "any"
+	}
+	var t string
+	switch {
+	case slices.Contains(shared, s.Title):
+		t = "api." + s.Title
+	case s.Type == "string" && s.Format == "date-time":
+		g.time = true
+		t = "time.Time"
+	case s.Type == "string" && s.Format == "byte":
+		return "[]byte"
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" && s.Format == "int64":
+		t = "int64"
+	case s.Type == "integer":
+		t = "int"
+	case s.Type == "number":
+		t = "float64"
+	case s.Type == "boolean":
+		t = "bool"
+	case s.Type == "array":
+		return "[]" + g.typ(s.Items, hint+"Item", "an item of "+where)
+	case object(s):
+		t = g.names.declare(s, hint, where)
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		return "map[string]" + g.typ(s.AdditionalProperties, hint+"Value", "a value of "+where)
+	case s.Type == "object":
+		return "map[string]any"
+	default:
+		return "any"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+func (g *goGen) decl(b *strings.Builder, name string, s *openapi.Schema) {
+	fmt.Fprintf(b, "\n// %s is %s.\ntype %s struct {\n", name, g.names.docs[name], name)
+	for _, member := range members(s) {
+		tag := member
+		if !slices.Contains(s.Required, member) {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(b, "\t%s %s `json:%q`\n", exported(member), g.typ(s.Properties[member], name+exported(member), "the "+member+" member of "+name), tag)
+	}
+	b.WriteString("}\n")
+}
+
+func (g *goGen) method(b *strings.Builder, m method) {
+	name := exported(m.id)
+	params := []string{"ctx context.Context"}
+	for _, p := range m.pathParams {
+		params = append(params, goParam(p)+" string")
+	}
+	bodyArg := "nil"
+	if m.body != nil {
+		params = append(params, "body "+g.typ(m.body, name+"Request", "the request body of "+name))
+		bodyArg = "body"
+	}
+	queryArg := "nil"
+	if len(m.query) > 0 {
+		params = append(params, "query url.Values")
+		queryArg = "query"
+	}
+
+	fmt.Fprintf(b, "\n// %s calls %s %s: %s.", name, m.verb, m.path, strings.TrimSuffix(m.summary, "."))
+	if len(m.query) > 0 {
+		fmt.Fprintf(b, "\n// The query takes %s.", strings.Join(m.query, ", "))
+	}
+	if m.result == resultRaw {
+		fmt.Fprintf(b, "\n// The response is %s, for the caller to read and close.", m.accept)
+	}
+	if m.deprecated {
+		b.WriteString("\n//\n// Deprecated: the route is deprecated.")
+	}
+	b.WriteString("\n")
+
+	path := goPath(m.path)
+	accept := "application/json"
+	switch m.result {
+	case resultNone:
+		fmt.Fprintf(b, "func (c *Client) %s(%s) error {\n", name, strings.Join(params, ", "))
+		fmt.Fprintf(b, "\tresp, err := c.do(ctx, %q, %s, %s, %s, %q)\n", m.verb, path, queryArg, bodyArg, accept)
+		b.WriteString("\tif err != nil {\n\t\treturn err\n\t}\n\treturn resp.Body.Close()\n}\n")
+	case resultRaw:
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (*http.Response, error) {\n", name, strings.Join(params, ", "))
+		fmt.Fprintf(b, "\treturn c.do(ctx, %q, %s, %s, %s, %q)\n}\n", m.verb, path, queryArg, bodyArg, m.accept)
+	case resultEnvelope:
+		data := "any"
+		if m.data != nil {
+			data = g.typ(m.data, name+"Data", "the data of "+name)
+		}
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (*api.Envelope[%s], error) {\n", name, strings.Join(params, ", "), data)
+		fmt.Fprintf(b, "\tresp, err := c.do(ctx, %q, %s, %s, %s, %q)\n", m.verb, path, queryArg, bodyArg, accept)
+		fmt.Fprintf(b, "\treturn decodeEnvelope[%s](resp, err)\n}\n", data)
+	case resultJSON:
+		typ := g.typ(m.data, name+"Response", "the response of "+name)
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(params, ", "), typ)
+		fmt.Fprintf(b, "\tresp, err := c.do(ctx, %q, %s, %s, %s, %q)\n", m.verb, path, queryArg, bodyArg, accept)
+		fmt.Fprintf(b, "\treturn decodeJSON[%s](resp, err)\n}\n", typ)
+	}
+}
+
+// goParam returns the Go parameter name of a path parameter.
+func goParam(name string) string {
+	p := exported(name)
+	p = strings.ToLower(p[:1]) + p[1:]
+	if p == "iD" {
+		p = "id"
+	}
+	if token.IsKeyword(p) {
+		p += "Param"
+	}
+	return p
+}
+
+// goPath returns the Go expression of path with its parameters escaped.
+func goPath(path string) string {
+	var parts []string
+	last := 0
+	for _, loc := range pathParam.FindAllStringSubmatchIndex(path, -1) {
+		if loc[0] > last {
+			parts = append(parts, fmt.Sprintf("%q", path[last:loc[0]]))
+		}
+		parts = append(parts, "url.PathEscape("+goParam(path[loc[2]:loc[3]])+")")
+		last = loc[1]
+	}
+	if last < len(path) {
+		parts = append(parts, fmt.Sprintf("%q", path[last:]))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// goRuntime is the part of the client that does not depend on the routes.
+const goRuntime = `
+// Client calls the API. Methods returning an envelope return it on
+// failure too, with the problem of the response as its Error, which is
+// also the error returned; the request ID is set either way.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	header  http.Header
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with h rather than http.DefaultClient.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithToken authenticates the requests with the bearer token.
+func WithToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithAPIKey authenticates the requests with the API key.
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithHeader sends the header with every request.
+func WithHeader(name, value string) Option {
+	return func(c *Client) { c.header.Set(name, value) }
+}
+
+// New returns a Client of the API at baseURL, such as
+// "https://example.fly.dev".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: baseURL, http: http.DefaultClient, header: http.Header{}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// do sends a request, with body as JSON unless nil, and returns the
+// response when it succeeded, or the problem of the response as an
+// *api.Problem.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, accept string) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+		r = bytes.NewReader(b)
+	}
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", accept)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		return nil, problem(resp)
+	}
+	return resp, nil
+}
+
+// problem reads the problem document of a failed response; responses
+// without one get a problem of their status.
+func problem(resp *http.Response) *api.Problem {
+	p := &api.Problem{Type: "about:blank", Title: http.StatusText(resp.StatusCode), Status: resp.StatusCode}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == api.ProblemType {
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(p)
+	}
+	if p.RequestID == "" {
+		p.RequestID = resp.Header.Get(api.HeaderRequestID)
+	}
+	return p
+}
+
+func decodeEnvelope[T any](resp *http.Response, err error) (*api.Envelope[T], error) {
+	if err != nil {
+		if p, ok := errors.AsType[*api.Problem](err); ok {
+			return &api.Envelope[T]{Error: p, RequestID: p.RequestID}, err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var env api.Envelope[T]
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	env.RequestID = resp.Header.Get(api.HeaderRequestID)
+	return &env, nil
+}
+
+func decodeJSON[T any](resp *http.Response, err error) (T, error) {
+	var v T
+	if err != nil {
+		return v, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return v, fmt.Errorf("decode response: %w", err)
+	}
+	return v, nil
+}
+`
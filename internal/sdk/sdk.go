@@ -0,0 +1,298 @@
+// Package sdk generates API clients from the OpenAPI document the server
+// builds from its route registry: a Go package whose methods return the
+// typed envelopes of package api, or a TypeScript module. Methods are named
+// after the operationId of their route and types after the schema titles,
+// the names of the Go types the schemas were derived from; untitled types
+// are named after where they appear.
+//
+// Operations with a JSON body, or none, are covered. Those answering with
+// JSON get typed results, those streaming another format, such as exports
+// and event streams, return the raw response; multipart uploads and
+// redirects are left out, as are routes without an operationId.
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Spec is the part of an OpenAPI document the generators read.
+type Spec struct {
+	Info struct {
+		Title string `json:"title"`
+	} `json:"info"`
+	Paths      map[string]map[string]*specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*openapi.Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	OperationID string `json:"operationId"`
+	Summary     string `json:"summary"`
+	Deprecated  bool   `json:"deprecated"`
+	Parameters  []struct {
+		Name        string `json:"name"`
+		In          string `json:"in"`
+		Description string `json:"description"`
+	} `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *openapi.Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema *openapi.Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+// Parse reads an OpenAPI document.
+func Parse(doc []byte) (*Spec, error) {
+	var s Spec
+	if err := json.Unmarshal(doc, &s); err != nil {
+		return nil, fmt.Errorf("sdk: parse document: %w", err)
+	}
+	return &s, nil
+}
+
+// result is how a method returns the success response.
+type result int
+
+const (
+	resultNone     result = iota // no body
+	resultEnvelope               // an api.Envelope of the data type
+	resultJSON                   // a JSON value of the type
+	resultRaw                    // the response, for the caller to read
+)
+
+// method is one generated client method.
+type method struct {
+	id         string
+	verb, path string
+	summary    string
+	deprecated bool
+	pathParams []string
+	query      []string
+	body       *openapi.Schema
+	result     result
+	data       *openapi.Schema // of resultEnvelope and resultJSON
+	meta       bool            // resultEnvelope with a page
+	accept     string          // of resultRaw
+}
+
+// skipped is an operation a generator leaves out, with why.
+type skipped struct {
+	verb, path, reason string
+}
+
+var pathParam = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// methods returns the methods of s in path and method order, and the
+// operations they leave out.
+func (s *Spec) methods() ([]method, []skipped) {
+	paths := make([]string, 0, len(s.Paths))
+	for p := range s.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var out []method
+	var skips []skipped
+	for _, path := range paths {
+		verbs := make([]string, 0, len(s.Paths[path]))
+		for v := range s.Paths[path] {
+			verbs = append(verbs, v)
+		}
+		sort.Strings(verbs)
+		for _, v := range verbs {
+			op := s.Paths[path][v]
+			m, reason := newMethod(strings.ToUpper(v), path, op)
+			if reason != "" {
+				skips = append(skips, skipped{strings.ToUpper(v), path, reason})
+				continue
+			}
+			out = append(out, m)
+		}
+	}
+	return out, skips
+}
+
+func newMethod(verb, path string, op *specOperation) (method, string) {
+	m := method{id: op.OperationID, verb: verb, path: path, summary: op.Summary, deprecated: op.Deprecated}
+	if m.id == "" {
+		return m, "no operationId"
+	}
+	for _, sub := range pathParam.FindAllStringSubmatch(path, -1) {
+		m.pathParams = append(m.pathParams, sub[1])
+	}
+	for _, p := range op.Parameters {
+		if p.In == "query" {
+			m.query = append(m.query, p.Name)
+		}
+	}
+	if op.RequestBody != nil {
+		mt, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			return m, "request body is not JSON"
+		}
+		m.body = mt.Schema
+	}
+	codes := make([]int, 0, len(op.Responses))
+	for code := range op.Responses {
+		if n, err := strconv.Atoi(code); err == nil && n < 400 {
+			codes = append(codes, n)
+		}
+	}
+	if len(codes) == 0 {
+		return m, "no success response"
+	}
+	code := slices.Min(codes)
+	if code >= 300 {
+		return m, "redirects"
+	}
+	content := op.Responses[strconv.Itoa(code)].Content
+	if len(content) == 0 || code == http.StatusNoContent {
+		m.result = resultNone
+		return m, ""
+	}
+	if mt, ok := content["application/json"]; ok {
+		if data, meta, ok := envelope(mt.Schema); ok {
+			m.result, m.data, m.meta = resultEnvelope, data, meta
+		} else {
+			m.result, m.data = resultJSON, mt.Schema
+		}
+		return m, ""
+	}
+	for ct := range content {
+		m.result, m.accept = resultRaw, ct
+	}
+	return m, ""
+}
+
+// envelope reports whether s is the schema of an api.Envelope, returning
+// the schema of its data, nil without, and whether it has a page.
+func envelope(s *openapi.Schema) (*openapi.Schema, bool, bool) {
+	if s == nil || s.Type != "object" || s.Properties["status"] == nil || s.Properties["message"] == nil {
+		return nil, false, false
+	}
+	for name := range s.Properties {
+		switch name {
+		case "status", "message", "data", "meta":
+		default:
+			return nil, false, false
+		}
+	}
+	return s.Properties["data"], s.Properties["meta"] != nil, true
+}
+
+// shared are the titles of the types package api declares; they are not
+// generated again.
+var shared = []string{"Problem", "FieldError", "Page"}
+
+// exported returns name with its first letter in upper case, and the
+// letters of initialisms too.
+func exported(name string) string {
+	var b strings.Builder
+	for _, w := range words(name) {
+		if up := strings.ToUpper(w); slices.Contains(initialisms, up) {
+			b.WriteString(up)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+var initialisms = []string{"API", "CSRF", "CSV", "HTML", "HTTP", "ID", "IDS", "IP", "JSON", "JWT", "OIDC", "SQL", "TTL", "URI", "URL", "UUID"}
+
+// words splits an identifier into words at underscores, dashes and the
+// lower to upper case changes of camel case.
+func words(name string) []string {
+	var out []string
+	for part := range strings.FieldsFuncSeq(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' || r == '.' }) {
+		start := 0
+		for i := 1; i < len(part); i++ {
+			if part[i] >= 'A' && part[i] <= 'Z' && part[i-1] >= 'a' && part[i-1] <= 'z' {
+				out = append(out, part[start:i])
+				start = i
+			}
+		}
+		out = append(out, part[start:])
+	}
+	return out
+}
+
+// namer names the types of a client: titled schemas after their title,
+// untitled objects after where they appear, keeping one declaration per
+// name and shape.
+type namer struct {
+	decls map[string]*openapi.Schema
+	docs  map[string]string
+	order []string
+	seen  map[string]string // name, and the shape it was given for
+}
+
+func newNamer() *namer {
+	return &namer{decls: map[string]*openapi.Schema{}, docs: map[string]string{}, seen: map[string]string{}}
+}
+
+// declare returns the name of the declared type of the object s, with hint
+// the name from where it appears and where what it is there, such as "the
+// data of GetUser".
+func (n *namer) declare(s *openapi.Schema, hint, where string) string {
+	name := hint
+	if token.IsExported(s.Title) {
+		name = s.Title
+	}
+	shape, _ := json.Marshal(s)
+	for i := 2; ; i++ {
+		prev, ok := n.seen[name]
+		if !ok {
+			break
+		}
+		if prev == string(shape) {
+			return name
+		}
+		// Another type of the same name: fall back on the hint, then on
+		// numbering.
+		if name != hint {
+			name = hint
+			continue
+		}
+		name = hint + strconv.Itoa(i)
+	}
+	n.seen[name] = string(shape)
+	n.decls[name] = s
+	n.docs[name] = where
+	if name == s.Title {
+		n.docs[name] = "the " + name + " type of the API"
+	}
+	n.order = append(n.order, name)
+	return name
+}
+
+// object reports whether s is an object with members, which gets a
+// declared type.
+func object(s *openapi.Schema) bool {
+	return s != nil && s.Type == "object" && len(s.Properties) > 0
+}
+
+// members returns the member names of the object s in order.
+func members(s *openapi.Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,268 @@
+package sdk
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// TypeScript returns the source of a TypeScript module with a client of
+// the API s describes, for browsers and Node.js 18 and later.
+func TypeScript(s *Spec) []byte {
+	g := &tsGen{names: newNamer()}
+	methods, skips := s.methods()
+	var body strings.Builder
+	for _, m := range methods {
+		g.method(&body, m)
+	}
+	var decls strings.Builder
+	for i := 0; i < len(g.names.order); i++ {
+		name := g.names.order[i]
+		g.decl(&decls, name, g.names.decls[name])
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by sdkgen from the OpenAPI document of %s. DO NOT EDIT.\n", s.Info.Title)
+	if len(skips) > 0 {
+		b.WriteString("//\n// Left out:\n")
+		for _, sk := range skips {
+			fmt.Fprintf(&b, "//   - %s %s: %s\n", sk.verb, sk.path, sk.reason)
+		}
+	}
+	b.WriteString(tsRuntime)
+	b.WriteString(body.String())
+	b.WriteString("}\n")
+	b.WriteString(decls.String())
+	return []byte(b.String())
+}
+
+type tsGen struct {
+	names *namer
+}
+
+// typ returns the TypeScript type of s, declaring the objects it holds,
+// named hint when they have no title.
+func (g *tsGen) typ(s *openapi.Schema, hint, where string) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref != "" {
+		if name := path.Base(s.Ref); slices.Contains(shared, name) {
+			return name
+		}
+		return "unknown"
+	}
+	var t string
+	switch {
+	case slices.Contains(shared, s.Title):
+		t = s.Title
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer", s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		item := g.typ(s.Items, hint+"Item", "an item of "+where)
+		if strings.Contains(item, " ") {
+			item = "(" + item + ")"
+		}
+		t = item + "[]"
+	case object(s):
+		t = g.names.declare(s, hint, where)
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		t = "Record<string, " + g.typ(s.AdditionalProperties, hint+"Value", "a value of "+where) + ">"
+	case s.Type == "object":
+		t = "Record<string, unknown>"
+	default:
+		return "unknown"
+	}
+	if s.Nullable {
+		return t + " | null"
+	}
+	return t
+}
+
+var tsIdent = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func (g *tsGen) decl(b *strings.Builder, name string, s *openapi.Schema) {
+	fmt.Fprintf(b, "\n/** %s is %s. */\nexport interface %s {\n", name, g.names.docs[name], name)
+	for _, member := range members(s) {
+		key := member
+		if !tsIdent.MatchString(key) {
+			key = fmt.Sprintf("%q", key)
+		}
+		if !slices.Contains(s.Required, member) {
+			key += "?"
+		}
+		fmt.Fprintf(b, "  %s: %s;\n", key, g.typ(s.Properties[member], name+exported(member), "the "+member+" member of "+name))
+	}
+	b.WriteString("}\n")
+}
+
+func (g *tsGen) method(b *strings.Builder, m method) {
+	name := exported(m.id)
+	var params []string
+	for _, p := range m.pathParams {
+		params = append(params, goParam(p)+": string")
+	}
+	bodyArg := "undefined"
+	if m.body != nil {
+		params = append(params, "body: "+g.typ(m.body, name+"Request", "the request body of "+name))
+		bodyArg = "body"
+	}
+	queryArg := "undefined"
+	if len(m.query) > 0 {
+		params = append(params, "query?: Query")
+		queryArg = "query"
+	}
+
+	fmt.Fprintf(b, "\n  /**\n   * %s %s: %s.", m.verb, m.path, strings.TrimSuffix(m.summary, "."))
+	if len(m.query) > 0 {
+		fmt.Fprintf(b, "\n   * The query takes %s.", strings.Join(m.query, ", "))
+	}
+	if m.deprecated {
+		b.WriteString("\n   * @deprecated The route is deprecated.")
+	}
+	b.WriteString("\n   */\n")
+
+	id := m.id
+	route := tsPath(m.path)
+	switch m.result {
+	case resultNone:
+		fmt.Fprintf(b, "  async %s(%s): Promise<void> {\n", id, strings.Join(params, ", "))
+		fmt.Fprintf(b, "    await this.request(%q, %s, %s, %s);\n  }\n", m.verb, route, queryArg, bodyArg)
+	case resultRaw:
+		fmt.Fprintf(b, "  %s(%s): Promise<Response> {\n", id, strings.Join(params, ", "))
+		fmt.Fprintf(b, "    return this.request(%q, %s, %s, %s, %q);\n  }\n", m.verb, route, queryArg, bodyArg, m.accept)
+	case resultEnvelope:
+		data := "undefined"
+		if m.data != nil {
+			data = g.typ(m.data, name+"Data", "the data of "+name)
+		}
+		fmt.Fprintf(b, "  %s(%s): Promise<Envelope<%s>> {\n", id, strings.Join(params, ", "), data)
+		fmt.Fprintf(b, "    return this.envelope(%q, %s, %s, %s);\n  }\n", m.verb, route, queryArg, bodyArg)
+	case resultJSON:
+		typ := g.typ(m.data, name+"Response", "the response of "+name)
+		fmt.Fprintf(b, "  async %s(%s): Promise<%s> {\n", id, strings.Join(params, ", "), typ)
+		fmt.Fprintf(b, "    return (await this.request(%q, %s, %s, %s)).json();\n  }\n", m.verb, route, queryArg, bodyArg)
+	}
+}
+
+// tsPath returns the TypeScript expression of path with its parameters
+// escaped.
+func tsPath(path string) string {
+	if !pathParam.MatchString(path) {
+		return fmt.Sprintf("%q", path)
+	}
+	return "`" + pathParam.ReplaceAllStringFunc(path, func(p string) string {
+		return "${encodeURIComponent(" + goParam(p[1:len(p)-1]) + ")}"
+	}) + "`"
+}
+
+// tsRuntime is the part of the client that does not depend on the routes;
+// the class it opens is closed after the methods.
+const tsRuntime = `
+/** The query parameters of a request; arrays repeat the parameter. */
+export type Query = Record<string, string | string[]>;
+
+/** The page of a paginated list. */
+export interface Page {
+  limit: number;
+  page?: number;
+  next_cursor?: string;
+  has_more: boolean;
+}
+
+/** One violated constraint of an invalid request. */
+export interface FieldError {
+  field: string;
+  rule: string;
+  param?: string;
+  message: string;
+}
+
+/** The RFC 7807 problem document of a failed request. */
+export interface Problem {
+  type: string;
+  title: string;
+  status: number;
+  detail?: string;
+  instance?: string;
+  errors?: FieldError[];
+  request_id?: string;
+}
+
+/** The body of the API responses, with the request ID of the response. */
+export interface Envelope<T> {
+  status: string;
+  message: string;
+  data?: T;
+  meta?: Page;
+  error?: Problem;
+  request_id?: string;
+}
+
+/** Thrown for failed requests, with the problem of the response. */
+export class APIError extends Error {
+  constructor(readonly problem: Problem) {
+    super(` + "`${problem.status} ${problem.title}${problem.detail ? \": \" + problem.detail : \"\"}`" + `);
+  }
+}
+
+export interface ClientOptions {
+  /** A bearer token to authenticate the requests with. */
+  token?: string;
+  /** An API key to authenticate the requests with. */
+  apiKey?: string;
+  /** Headers sent with every request. */
+  headers?: Record<string, string>;
+  /** The fetch to send the requests with, globalThis.fetch by default. */
+  fetch?: typeof fetch;
+}
+
+/** A client of the API at baseURL, such as "https://example.fly.dev". */
+export class Client {
+  private readonly headers: Record<string, string>;
+  private readonly fetch: typeof fetch;
+
+  constructor(private readonly baseURL: string, options: ClientOptions = {}) {
+    this.headers = { ...options.headers };
+    if (options.token) this.headers["Authorization"] = ` + "`Bearer ${options.token}`" + `;
+    if (options.apiKey) this.headers["X-API-Key"] = options.apiKey;
+    this.fetch = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  /** Sends a request and returns its response, or throws an APIError when it failed. */
+  async request(method: string, path: string, query?: Query, body?: unknown, accept = "application/json"): Promise<Response> {
+    let url = this.baseURL + path;
+    if (query) {
+      const params = new URLSearchParams();
+      for (const [name, value] of Object.entries(query)) {
+        for (const v of Array.isArray(value) ? value : [value]) params.append(name, v);
+      }
+      if (params.size > 0) url += "?" + params.toString();
+    }
+    const headers: Record<string, string> = { ...this.headers, Accept: accept };
+    if (body !== undefined) headers["Content-Type"] = "application/json";
+    const resp = await this.fetch(url, { method, headers, body: body === undefined ? undefined : JSON.stringify(body) });
+    if (resp.status >= 300) {
+      let problem: Problem = { type: "about:blank", title: resp.statusText, status: resp.status };
+      if ((resp.headers.get("Content-Type") ?? "").startsWith("application/problem+json")) problem = await resp.json();
+      problem.request_id ??= resp.headers.get("X-Request-ID") ?? undefined;
+      throw new APIError(problem);
+    }
+    return resp;
+  }
+
+  private async envelope<T>(method: string, path: string, query?: Query, body?: unknown): Promise<Envelope<T>> {
+    const resp = await this.request(method, path, query, body);
+    const env: Envelope<T> = await resp.json();
+    env.request_id = resp.headers.get("X-Request-ID") ?? undefined;
+    return env;
+  }
+`
@@ -0,0 +1,200 @@
+// Package secheaders sets the security headers browsers act on (CSP, HSTS,
+// content sniffing, framing, referrers and permissions) and collects the
+// CSP violations they report. The policy comes from the config subsystem
+// and can be swapped at runtime.
+package secheaders
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+)
+
+// ReportPath is where browsers post CSP violation reports.
+const ReportPath = "/csp-report"
+
+// reportGroup names ReportPath in the Reporting-Endpoints header.
+const reportGroup = "csp"
+
+// Headers applies the current policy to every response.
+type Headers struct {
+	policy atomic.Pointer[policy]
+}
+
+type policy struct {
+	enabled bool
+	cspName string
+	csp     string
+	routes  []route // longest prefix first
+	static  [][2]string
+}
+
+type route struct {
+	prefix string
+	csp    string
+}
+
+// New compiles cfg.
+func New(cfg config.Security) *Headers {
+	h := &Headers{}
+	h.SetConfig(cfg)
+	return h
+}
+
+// SetConfig replaces the policy; requests in flight keep the old one.
+func (h *Headers) SetConfig(cfg config.Security) {
+	p := &policy{
+		enabled: cfg.Enabled,
+		cspName: "Content-Security-Policy",
+		csp:     withReporting(cfg.CSP),
+	}
+	if cfg.CSPReportOnly {
+		p.cspName = "Content-Security-Policy-Report-Only"
+	}
+	for prefix, csp := range cfg.CSPRoutes {
+		p.routes = append(p.routes, route{prefix: prefix, csp: withReporting(csp)})
+	}
+	slices.SortFunc(p.routes, func(a, b route) int { return cmp.Compare(len(b.prefix), len(a.prefix)) })
+
+	if cfg.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+		p.static = append(p.static, [2]string{"Strict-Transport-Security", hsts})
+	}
+	for name, value := range map[string]string{
+		"X-Content-Type-Options": cfg.ContentTypeOptions,
+		"X-Frame-Options":        cfg.FrameOptions,
+		"Referrer-Policy":        cfg.ReferrerPolicy,
+		"Permissions-Policy":     cfg.PermissionsPolicy,
+	} {
+		if value != "" {
+			p.static = append(p.static, [2]string{name, value})
+		}
+	}
+	h.policy.Store(p)
+}
+
+// withReporting points csp at ReportPath unless it names its own endpoint.
+func withReporting(csp string) string {
+	csp = strings.TrimRight(strings.TrimSpace(csp), ";")
+	if csp == "" || strings.Contains(csp, "report-uri") || strings.Contains(csp, "report-to") {
+		return csp
+	}
+	return csp + "; report-uri " + ReportPath + "; report-to " + reportGroup
+}
+
+// Middleware sets the headers before the handler runs, so handlers can
+// still replace them. Browsers ignore Strict-Transport-Security over plain
+// HTTP, so it is sent regardless of how TLS is terminated.
+func (h *Headers) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := h.policy.Load()
+		if !p.enabled {
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		for _, kv := range p.static {
+			header.Set(kv[0], kv[1])
+		}
+		if csp := p.cspFor(c.Request.URL.Path); csp != "" {
+			header.Set(p.cspName, csp)
+			header.Set("Reporting-Endpoints", reportGroup+`="`+ReportPath+`"`)
+		}
+		c.Next()
+	}
+}
+
+func (p *policy) cspFor(path string) string {
+	for _, r := range p.routes {
+		if strings.HasPrefix(path, r.prefix) {
+			return r.csp
+		}
+	}
+	return p.csp
+}
+
+// violation is the body of a legacy application/csp-report report.
+type violation struct {
+	DocumentURI        string `json:"document-uri"`
+	EffectiveDirective string `json:"effective-directive"`
+	ViolatedDirective  string `json:"violated-directive"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	Disposition        string `json:"disposition"`
+}
+
+// report is an entry of an application/reports+json batch.
+type report struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// Report logs the violations of a CSP report, in either the legacy
+// report-uri format or the Reporting API's, and answers 204. Malformed
+// reports are dropped silently: browsers do not look at the answer.
+func Report(c *gin.Context) {
+	logger := slog.With("client_ip", c.ClientIP(), "user_agent", c.Request.UserAgent())
+	if strings.HasPrefix(c.ContentType(), "application/reports+json") {
+		var batch []report
+		if err := json.NewDecoder(c.Request.Body).Decode(&batch); err == nil {
+			for _, r := range batch {
+				if r.Type != "csp-violation" {
+					continue
+				}
+				logger.Warn("csp violation",
+					"document", r.Body.DocumentURL,
+					"directive", r.Body.EffectiveDirective,
+					"blocked", r.Body.BlockedURL,
+					"source", location(r.Body.SourceFile, r.Body.LineNumber),
+					"disposition", r.Body.Disposition,
+				)
+			}
+		}
+	} else {
+		var body struct {
+			Report violation `json:"csp-report"`
+		}
+		if err := json.NewDecoder(c.Request.Body).Decode(&body); err == nil {
+			v := body.Report
+			logger.Warn("csp violation",
+				"document", v.DocumentURI,
+				"directive", cmp.Or(v.EffectiveDirective, v.ViolatedDirective),
+				"blocked", v.BlockedURI,
+				"source", location(v.SourceFile, v.LineNumber),
+				"disposition", v.Disposition,
+			)
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func location(file string, line int) string {
+	if file == "" || line == 0 {
+		return file
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
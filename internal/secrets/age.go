@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// maxPlaintext bounds a decrypted value; configuration values are short.
+const maxPlaintext = 64 << 10
+
+// ageKeys decrypts age values with any of its identities.
+type ageKeys struct {
+	identities []age.Identity
+}
+
+// newAge reads the identities of files, in the format of age-keygen.
+func newAge(files []string) (*ageKeys, error) {
+	a := &ageKeys{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: age identities: %w", err)
+		}
+		ids, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("secrets: age identities %s: %w", file, err)
+		}
+		a.identities = append(a.identities, ids...)
+	}
+	return a, nil
+}
+
+// Decrypt implements Decrypter.
+func (a *ageKeys) Decrypt(_ context.Context, ciphertext []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), a.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: age: %w", err)
+	}
+	plaintext, err := io.ReadAll(io.LimitReader(r, maxPlaintext))
+	if err != nil {
+		return nil, fmt.Errorf("secrets: age: %w", err)
+	}
+	return plaintext, nil
+}
+
+// EncryptAge returns plaintext encrypted to every recipient, age public
+// keys such as "age1...", as an age value.
+func EncryptAge(plaintext []byte, recipients ...string) (string, error) {
+	rs, err := age.ParseRecipients(strings.NewReader(strings.Join(recipients, "\n")))
+	if err != nil {
+		return "", fmt.Errorf("secrets: age recipients: %w", err)
+	}
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, rs...)
+	if err != nil {
+		return "", fmt.Errorf("secrets: age: %w", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return "", fmt.Errorf("secrets: age: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("secrets: age: %w", err)
+	}
+	return Encode("age", b.Bytes()), nil
+}
@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+// KMSOptions configures AWS KMS. Endpoint replaces the regional endpoint,
+// https://kms.<region>.amazonaws.com, e.g. for LocalStack.
+type KMSOptions struct {
+	Region          string
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// KMS decrypts awskms values with the AWS KMS API.
+type KMS struct {
+	opts   KMSOptions
+	url    string
+	client *http.Client
+}
+
+// NewKMS returns a KMS client sending with client, or one with a timeout
+// of ten seconds when nil.
+func NewKMS(opts KMSOptions, client *http.Client) *KMS {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	url := opts.Endpoint
+	if url == "" {
+		url = "https://kms." + opts.Region + ".amazonaws.com"
+	}
+	return &KMS{opts: opts, url: strings.TrimRight(url, "/") + "/", client: client}
+}
+
+// Decrypt implements Decrypter. The ciphertext blob names its key, so
+// values encrypted with rotated key material keep decrypting.
+func (k *KMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	if err := k.call(ctx, "Decrypt", map[string]any{"CiphertextBlob": ciphertext}, &out); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// Encrypt returns plaintext encrypted with the KMS key keyID, an ID, ARN
+// or alias such as "alias/app", as an awskms value.
+func (k *KMS) Encrypt(ctx context.Context, keyID string, plaintext []byte) (string, error) {
+	var out struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	if err := k.call(ctx, "Encrypt", map[string]any{"KeyId": keyID, "Plaintext": plaintext}, &out); err != nil {
+		return "", err
+	}
+	return Encode("awskms", out.CiphertextBlob), nil
+}
+
+// call sends action with the JSON protocol of KMS, where binary members
+// are base64 strings as encoding/json writes []byte.
+func (k *KMS) call(ctx context.Context, action string, in, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	r.Header.Set("X-Amz-Target", "TrentService."+action)
+	sum := sha256.Sum256(payload)
+	r.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	r = signer.SignV4WithServiceType(*r, k.opts.AccessKeyID, k.opts.SecretAccessKey, k.opts.SessionToken, k.opts.Region, "kms")
+	resp, err := k.client.Do(r)
+	if err != nil {
+		return fmt.Errorf("secrets: kms %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("secrets: kms %s: %w", action, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &e)
+		return fmt.Errorf("secrets: kms %s: %s: %s %s", action, resp.Status, e.Type, e.Message)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("secrets: kms %s: %w", action, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// minScrubbed is the length below which remembered values are not
+// scrubbed: shorter ones would blank common words rather than secrets.
+const minScrubbed = 6
+
+// Scrubbed replaces remembered values.
+const Scrubbed = "[redacted]"
+
+// scrubber is an immutable set of remembered values.
+type scrubber struct {
+	values   []string
+	replacer *strings.Replacer
+}
+
+var (
+	rememberMu sync.Mutex
+	known      atomic.Pointer[scrubber]
+)
+
+// Remember has value scrubbed from now on. Values stay remembered once
+// rotated, as copies of them may outlive the configuration.
+func Remember(value string) {
+	if len(value) < minScrubbed {
+		return
+	}
+	rememberMu.Lock()
+	defer rememberMu.Unlock()
+	var values []string
+	if s := known.Load(); s != nil {
+		if slices.Contains(s.values, value) {
+			return
+		}
+		values = slices.Clone(s.values)
+	}
+	values = append(values, value)
+	// Longest first, so that a value holding another is blanked whole.
+	slices.SortFunc(values, func(a, b string) int { return len(b) - len(a) })
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, Scrubbed)
+	}
+	known.Store(&scrubber{values: values, replacer: strings.NewReplacer(pairs...)})
+}
+
+// Known reports whether s holds a remembered value.
+func Known(s string) bool {
+	sc := known.Load()
+	if sc == nil {
+		return false
+	}
+	for _, v := range sc.values {
+		if strings.Contains(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scrub returns s with the remembered values it holds replaced.
+func Scrub(s string) string {
+	if !Known(s) {
+		return s
+	}
+	return known.Load().replacer.Replace(s)
+}
+
+// Handler returns a handler scrubbing the remembered values from the
+// messages and attributes of the records it passes to h.
+func Handler(h slog.Handler) slog.Handler {
+	return &scrubHandler{h}
+}
+
+type scrubHandler struct {
+	next slog.Handler
+}
+
+func (h *scrubHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.next.Enabled(ctx, l)
+}
+
+func (h *scrubHandler) Handle(ctx context.Context, r slog.Record) error {
+	if known.Load() == nil {
+		return h.next.Handle(ctx, r)
+	}
+	out := slog.NewRecord(r.Time, r.Level, Scrub(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(scrubAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *scrubHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	scrubbed := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		scrubbed[i] = scrubAttr(a)
+	}
+	return &scrubHandler{h.next.WithAttrs(scrubbed)}
+}
+
+func (h *scrubHandler) WithGroup(name string) slog.Handler {
+	return &scrubHandler{h.next.WithGroup(name)}
+}
+
+// scrubAttr scrubs the strings of a, and the text of other values, such
+// as errors, that hold a remembered value.
+func scrubAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		scrubbed := make([]slog.Attr, len(group))
+		for i, g := range group {
+			scrubbed[i] = scrubAttr(g)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(scrubbed...)}
+	case slog.KindAny:
+		if s := fmt.Sprint(v.Any()); Known(s) {
+			return slog.String(a.Key, Scrub(s))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
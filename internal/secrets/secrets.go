@@ -0,0 +1,151 @@
+// Package secrets decrypts the configuration values written as
+// "enc:<scheme>:<ciphertext>", the ciphertext in standard base64: age
+// values, scheme "age", are decrypted with the identities of the files in
+// Options.AgeIdentities, and AWS KMS ciphertext blobs, scheme "awskms", by
+// KMS. Listing a new identity next to the old one keeps values readable
+// while they are re-encrypted to the new key; KMS rotates its key material
+// on its own.
+//
+// Every value revealed is remembered, so that Scrub and Handler blank it
+// wherever it shows up later: in log records and configuration dumps.
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Prefix marks encrypted values.
+const Prefix = "enc:"
+
+// Decrypter decrypts the ciphertexts of one scheme.
+type Decrypter interface {
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// Options configures the schemes of a Keyring: age with identity files,
+// AWS KMS once it has a region.
+type Options struct {
+	AgeIdentities []string
+	KMS           KMSOptions
+}
+
+// Keyring decrypts values by scheme.
+type Keyring struct {
+	schemes map[string]Decrypter
+}
+
+// NewKeyring returns a Keyring for opts, reading the age identities.
+func NewKeyring(opts Options) (*Keyring, error) {
+	k := &Keyring{schemes: map[string]Decrypter{}}
+	if len(opts.AgeIdentities) > 0 {
+		a, err := newAge(opts.AgeIdentities)
+		if err != nil {
+			return nil, err
+		}
+		k.Register("age", a)
+	}
+	if opts.KMS.Region != "" {
+		k.Register("awskms", NewKMS(opts.KMS, nil))
+	}
+	return k, nil
+}
+
+// Register decrypts the values of scheme with d.
+func (k *Keyring) Register(scheme string, d Decrypter) {
+	k.schemes[scheme] = d
+}
+
+// Encrypted reports whether value is an encrypted value.
+func Encrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Encode returns the value of ciphertext in scheme.
+func Encode(scheme string, ciphertext []byte) string {
+	return Prefix + scheme + ":" + base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+// Decrypt returns the plaintext of the encrypted value, and remembers it.
+func (k *Keyring) Decrypt(ctx context.Context, value string) (string, error) {
+	scheme, data, ok := strings.Cut(strings.TrimPrefix(value, Prefix), ":")
+	if !ok {
+		return "", fmt.Errorf("secrets: value is not %s<scheme>:<ciphertext>", Prefix)
+	}
+	d, ok := k.schemes[scheme]
+	if !ok {
+		return "", fmt.Errorf("secrets: no key configured for %s values", scheme)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("secrets: %s value is not base64: %w", scheme, err)
+	}
+	plaintext, err := d.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	Remember(string(plaintext))
+	return string(plaintext), nil
+}
+
+// Reveal decrypts, in place, the encrypted strings held by the struct v
+// points to: in fields, slices and map values. Errors name the value by
+// the yaml keys leading to it.
+func (k *Keyring) Reveal(ctx context.Context, v any) error {
+	return k.reveal(ctx, reflect.ValueOf(v), "")
+}
+
+func (k *Keyring) reveal(ctx context.Context, v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return k.reveal(ctx, v.Elem(), path)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := k.reveal(ctx, v.Field(i), name); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			if err := k.reveal(ctx, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map values are not addressable: reveal a copy and store it.
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := k.reveal(ctx, elem, fmt.Sprintf("%s.%v", path, key)); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		if s := v.String(); Encrypted(s) {
+			plaintext, err := k.Decrypt(ctx, s)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			v.SetString(plaintext)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package server_test
+
+import (
+	"testing"
+
+	"go-flylike-example/internal/contract/contracttest"
+)
+
+// TestContract replays the exchanges recorded in testdata/contract against
+// the server, checking them against its OpenAPI document.
+func TestContract(t *testing.T) {
+	contracttest.Run(t, "testdata/contract")
+}
@@ -0,0 +1,832 @@
+// Package server assembles the application: the router with its middleware
+// and routes, and the services and workers behind them. A Server is built
+// from the configuration, the database and the collaborators of Deps, so
+// it runs the same whether main hands it the production ones or an
+// integration test hands it an in-memory SQLite database and fakes; the
+// test then sends requests to it with httptest, without binding a port.
+//
+// What a Server does not own is the process around it: it never listens,
+// and its workers only run once Start added them to an app.App.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/admin"
+	"go-flylike-example/internal/admission"
+	"go-flylike-example/internal/apikeys"
+	"go-flylike-example/internal/app"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/audit"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/bandwidth"
+	"go-flylike-example/internal/buildinfo"
+	"go-flylike-example/internal/bus"
+	"go-flylike-example/internal/cache"
+	"go-flylike-example/internal/cachebus"
+	"go-flylike-example/internal/canary"
+	"go-flylike-example/internal/chaos"
+	"go-flylike-example/internal/compression"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/contract"
+	"go-flylike-example/internal/cors"
+	"go-flylike-example/internal/export"
+	"go-flylike-example/internal/featureflag"
+	"go-flylike-example/internal/geo"
+	"go-flylike-example/internal/health"
+	"go-flylike-example/internal/hooks"
+	"go-flylike-example/internal/httpcache"
+	"go-flylike-example/internal/httpclient"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/idempotency"
+	"go-flylike-example/internal/idle"
+	"go-flylike-example/internal/imports"
+	"go-flylike-example/internal/ipfilter"
+	"go-flylike-example/internal/jobs"
+	"go-flylike-example/internal/journal"
+	"go-flylike-example/internal/kv"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/mailer"
+	"go-flylike-example/internal/maintenance"
+	"go-flylike-example/internal/metrics"
+	"go-flylike-example/internal/mirror"
+	"go-flylike-example/internal/oidc"
+	"go-flylike-example/internal/operations"
+	"go-flylike-example/internal/outbox"
+	"go-flylike-example/internal/plugin"
+	"go-flylike-example/internal/policy"
+	"go-flylike-example/internal/presence"
+	"go-flylike-example/internal/proxy"
+	"go-flylike-example/internal/quota"
+	"go-flylike-example/internal/ratelimit"
+	"go-flylike-example/internal/rbac"
+	"go-flylike-example/internal/realtime"
+	"go-flylike-example/internal/reqsign"
+	"go-flylike-example/internal/routes"
+	"go-flylike-example/internal/rpc"
+	"go-flylike-example/internal/scheduler"
+	"go-flylike-example/internal/secheaders"
+	"go-flylike-example/internal/session"
+	"go-flylike-example/internal/shed"
+	"go-flylike-example/internal/signedurl"
+	"go-flylike-example/internal/slo"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+	"go-flylike-example/internal/tracing"
+	"go-flylike-example/internal/uploads"
+	"go-flylike-example/internal/users"
+	"go-flylike-example/internal/validation"
+	"go-flylike-example/internal/views"
+	"go-flylike-example/internal/web"
+	"go-flylike-example/internal/webhooks"
+)
+
+// Deps are the collaborators of a Server that live outside the process or
+// are shared between instances. Each one left nil is built from the
+// configuration, in Redis when the Server has a client and in the database
+// or memory otherwise, as in production; tests set the ones they fake.
+type Deps struct {
+	// Reporter, when set, is sent the server errors of requests.
+	Reporter apperror.Reporter
+	Metrics  *metrics.Metrics
+	// Credentials check logins, after the demo user; by default the
+	// passwords of users.
+	Credentials auth.Credentials
+	Broker      bus.Broker
+	Mail        mailer.Sender
+	Limiter     ratelimit.Limiter
+	Idempotency idempotency.Store
+	Sessions    session.Store
+	Flags       featureflag.Store
+	Presence    presence.Store
+	Replays     webhooks.Store
+	Elector     scheduler.Elector
+}
+
+// Server is the assembled application. It is the http.Handler of the
+// public listener.
+type Server struct {
+	router   *gin.Engine
+	live     *config.Live
+	db       *store.Store
+	reporter apperror.Reporter
+
+	checker     *health.Checker
+	metrics     *metrics.Metrics
+	users       *users.Repository
+	queue       *jobs.Queue
+	sched       *scheduler.Scheduler
+	consumers   *bus.Runner
+	events      *outbox.Outbox
+	shadow      *mirror.Mirror
+	plugins     *plugin.Plugins
+	cacheBus    *cachebus.Bus
+	presence    *presence.Service
+	hub         *realtime.Hub
+	activity    *idle.Tracker
+	mode        *maintenance.Mode
+	flags       *featureflag.Service
+	mail        *mailer.Mailer
+	injector    *chaos.Injector
+	usage       *quota.Enforcer
+	adminFilter *ipfilter.Filter
+	shedder     *shed.Shedder
+	journal     *journal.Journal
+	objectives  *slo.Tracker
+	kv          *kv.DB
+	callers     *rpc.Auth
+}
+
+// New builds the Server of live's configuration on db, sharing state
+// through rdb when it is set, and logging to logger. The schema of db
+// must be migrated.
+func New(live *config.Live, db *store.Store, rdb *redis.Client, logger *slog.Logger, d Deps) (*Server, error) {
+	cfg := live.Load()
+	s := &Server{live: live, db: db, reporter: d.Reporter, metrics: d.Metrics}
+	if s.metrics == nil {
+		s.metrics = metrics.New()
+	}
+	m := s.metrics
+	// The routes declare their objectives as they are registered.
+	s.objectives = slo.New(cfg.SLO, m.Registry())
+	m.TrackObjectives(s.objectives)
+
+	s.users = users.NewRepository(db)
+	userRepo := s.users
+	if d.Credentials == nil {
+		d.Credentials = users.NewCredentials(userRepo, live)
+	}
+	authSvc, err := auth.New(cfg.Auth, db, auth.CredentialsChain{
+		auth.StaticCredentials{Username: cfg.Auth.DemoUser, Password: cfg.Auth.DemoPassword},
+		d.Credentials,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+
+	if err := validation.Setup(); err != nil {
+		return nil, err
+	}
+	locales, err := i18n.New(cfg.DefaultLocale)
+	if err != nil {
+		return nil, fmt.Errorf("i18n: %w", err)
+	}
+
+	s.queue = jobs.New(db, cfg.Jobs)
+	queue := s.queue
+	queue.Register(jobs.KindCleanup, jobs.CleanupHandler(db))
+
+	if d.Elector == nil {
+		d.Elector = newElector(rdb, db)
+	}
+	s.sched = scheduler.New(cfg.Scheduler, d.Elector)
+	// The task only enqueues the job, so a slow cleanup never holds up
+	// the scheduler and failures are retried by the queue.
+	err = s.sched.Add(jobs.KindCleanup, "@hourly", func(ctx context.Context) error {
+		_, err := queue.Enqueue(ctx, jobs.KindCleanup, struct{}{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scheduler: %w", err)
+	}
+
+	router := gin.New()
+	s.router = router
+	// Code handed the *gin.Context as a context.Context sees the request's
+	// deadline and cancellation.
+	router.ContextWithFallback = true
+	if err := ipfilter.TrustProxies(router, cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	router.Use(tracing.Middleware(), logging.RequestID(), logging.RouteDebug(), logging.AccessLog(logger))
+	// Locations are only looked up for the requests that ask for them.
+	locator, err := geo.New(live)
+	if err != nil {
+		return nil, err
+	}
+	router.Use(locator.Middleware())
+
+	// Realtime clients keep an instance busy as much as requests do.
+	s.hub = realtime.NewHub()
+	hub := s.hub
+	if cfg.Autostop.After > 0 {
+		s.activity = idle.New(hub.Len)
+		router.Use(s.activity.Middleware())
+	}
+
+	corsPolicy, err := cors.New(cfg.CORS)
+	if err != nil {
+		return nil, fmt.Errorf("cors: %w", err)
+	}
+
+	ipf := cfg.IPFilter
+	publicFilter, err := ipfilter.New(ipf.Allow, ipf.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("ip filter: %w", err)
+	}
+	s.adminFilter, err = ipfilter.New(ipf.AdminAllow, nil)
+	if err != nil {
+		return nil, fmt.Errorf("admin ip filter: %w", err)
+	}
+
+	keyRepo := apikeys.NewRepository(db)
+	// Signatures cover the body, which is read for it before the limits of
+	// the routes apply, so it is held to the general one.
+	signatures, err := reqsign.New(cfg.RequestSigning, cfg.Limits.MaxBodyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("request signing: %w", err)
+	}
+	rbacSvc := rbac.New(db)
+	for _, subject := range cfg.RBAC.Admins {
+		if err := rbacSvc.Assign(context.Background(), subject, rbac.AdminRole); err != nil {
+			return nil, fmt.Errorf("rbac: grant admin to %s: %w", subject, err)
+		}
+	}
+
+	if cfg.KV.Path != "" {
+		if s.kv, err = kv.Open(context.Background(), cfg.KV, cfg.Uploads, logger); err != nil {
+			return nil, err
+		}
+	}
+	if d.Flags == nil {
+		d.Flags = newFlagStore(rdb, s.kv)
+	}
+	s.flags = featureflag.New(live, d.Flags)
+
+	// Invalidations reach the caches of the other instances through the
+	// cache bus, when there is one.
+	s.cacheBus, err = cachebus.New(cfg.CacheBus, rdb)
+	if err != nil {
+		return nil, fmt.Errorf("cache bus: %w", err)
+	}
+	if cfg.DataCache.Enabled {
+		dataCache := newDataCache(cfg.DataCache, rdb, s.kv, cache.NewMetrics(m.Registry()))
+		keyCache := dataCache("api_keys")
+		if cfg.DataCache.Backend == "memory" {
+			s.cacheBus.Data(keyCache)
+		}
+		keyRepo.CacheLookups(keyCache)
+	}
+	s.mode = maintenance.New(live)
+	secHeaders := secheaders.New(cfg.Security)
+	// Security headers go on every response, errors included. Bandwidth is
+	// throttled beneath compression, so that it counts the bytes sent.
+	// Compression wraps the error middleware, so that problem responses are
+	// encoded too. Body logging and the contract recorder sit between the
+	// two, so that they see problem responses before they are encoded. The
+	// locale is negotiated before any error can be rendered. Refused
+	// addresses are turned away before anything else runs. CORS runs before
+	// authentication, so that preflights, which carry no credentials, are
+	// answered. CORS also runs before maintenance, so that browsers can read
+	// its 503. Load is shed after CORS and before authentication, which is
+	// work. API keys are checked ahead of JWTs, since both may arrive as
+	// bearer tokens.
+	router.Use(m.Middleware(), buildinfo.Middleware(), secHeaders.Middleware(), bandwidth.New(cfg.Bandwidth).Middleware(), compression.Middleware(cfg.Compression), logging.Bodies(logger, live), contract.Recorder(live), locales.Middleware(), apperror.Middleware(d.Reporter), publicFilter.Middleware(),
+		corsPolicy.Middleware())
+	if s.shedder = shed.New(live, m.Registry()); s.shedder != nil {
+		router.Use(s.shedder.Middleware())
+	}
+	router.Use(s.mode.Middleware())
+	if signatures != nil {
+		router.Use(signatures.Middleware())
+	}
+	router.Use(apikeys.Middleware(keyRepo), authSvc.Middleware(), rbacSvc.Middleware(), s.flags.Middleware())
+	if cfg.Chaos.Enabled {
+		// Faults hit requests once they are authenticated, and are
+		// rendered like any other error.
+		s.injector = chaos.New(cfg.Chaos.Headers)
+		router.Use(s.injector.Middleware())
+		logger.Warn("fault injection enabled", "headers", cfg.Chaos.Headers)
+	}
+
+	s.checker = health.New()
+	s.checker.AddReadiness("database", db.Ping, 0)
+	s.checker.AddReadiness("maintenance", s.mode.Check, 0)
+	if rdb != nil {
+		s.checker.AddReadiness("redis", func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		}, 0)
+	}
+
+	limiter := d.Limiter
+	if limiter == nil {
+		limiter = newLimiter(cfg.RateLimit, rdb)
+	}
+	live.OnReload(func(old, new *config.Config) {
+		if !reflect.DeepEqual(old.CORS, new.CORS) {
+			if err := corsPolicy.SetConfig(new.CORS); err != nil {
+				logger.Error("cors policy not changed", "error", err)
+			}
+		}
+		if !reflect.DeepEqual(old.Security, new.Security) {
+			secHeaders.SetConfig(new.Security)
+		}
+		if ipf := new.IPFilter; !reflect.DeepEqual(old.IPFilter, ipf) {
+			if err := publicFilter.Set(ipf.Allow, ipf.Deny); err != nil {
+				logger.Error("ip filter not changed", "error", err)
+			}
+			if err := s.adminFilter.Set(ipf.AdminAllow, nil); err != nil {
+				logger.Error("admin ip filter not changed", "error", err)
+			}
+		}
+		if old.Maintenance.Enabled != new.Maintenance.Enabled {
+			if new.Maintenance.Enabled {
+				s.mode.Enable("")
+			} else {
+				s.mode.Disable()
+			}
+		}
+		if limiter != nil && (old.RateLimit.Rate != new.RateLimit.Rate || old.RateLimit.Burst != new.RateLimit.Burst) {
+			limiter.SetPolicy(ratelimit.Policy{Rate: new.RateLimit.Rate, Burst: new.RateLimit.Burst})
+		}
+	})
+
+	respCache := newResponseCache(cfg.Cache, rdb, s.kv)
+	if cfg.Cache.Backend == "memory" {
+		s.cacheBus.Responses(respCache)
+	}
+	userRepo.OnChange(func(ctx context.Context) {
+		if err := respCache.Invalidate(ctx, users.CacheTag); err != nil {
+			logging.FromContext(ctx).Warn("response cache invalidation failed", "error", err)
+		}
+	})
+
+	// Route policies from the config run once requests are authenticated,
+	// like the requirements routes attach in code.
+	policies, err := policy.New(cfg, policy.Deps{Limiter: limiter, Cache: respCache})
+	if err != nil {
+		return nil, fmt.Errorf("route policies: %w", err)
+	}
+	router.Use(policies.Middleware()...)
+
+	// Plugins see requests as the route policies left them.
+	s.plugins, err = plugin.Load(context.Background(), cfg.Plugins)
+	if err != nil {
+		return nil, fmt.Errorf("plugins: %w", err)
+	}
+	if s.plugins != nil {
+		router.Use(s.plugins.Middleware())
+	}
+
+	// Jobs enqueued on behalf of a request count against its quota.
+	s.usage = newQuota(cfg.Quota, live, rdb, db)
+	var enqueuer jobs.Enqueuer = queue
+	if s.usage != nil {
+		enqueuer = s.usage.Jobs(queue)
+	}
+
+	clientMetrics := httpclient.NewMetrics(m.Registry())
+	// Mirroring copies what passed authentication and the route policies,
+	// the traffic this instance actually serves.
+	s.shadow, err = mirror.New(cfg.Mirror, httpclient.New(httpclient.WithTimeout(cfg.Mirror.Timeout),
+		httpclient.WithRetries(0), httpclient.WithMetrics(clientMetrics)), m.Registry())
+	if err != nil {
+		return nil, fmt.Errorf("traffic mirroring: %w", err)
+	}
+	if s.shadow != nil {
+		router.Use(s.shadow.Middleware())
+	}
+	// Canary routing follows mirroring, so that both variants are mirrored.
+	canaries, err := canary.New(live, clientMetrics, m.Registry())
+	if err != nil {
+		return nil, fmt.Errorf("canary routing: %w", err)
+	}
+	if canaries != nil {
+		router.Use(canaries.Middleware())
+	}
+	hookSvc := hooks.New(db, enqueuer, cfg.Webhooks, clientMetrics)
+	queue.Register(hooks.KindDeliver, hookSvc.Deliver)
+	if d.Mail == nil {
+		if d.Mail, err = mailer.NewSender(cfg.Mail, clientMetrics); err != nil {
+			return nil, fmt.Errorf("mailer: %w", err)
+		}
+	}
+	s.mail, err = mailer.NewWithSender(cfg.Mail, enqueuer, d.Mail)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %w", err)
+	}
+	queue.Register(mailer.KindSend, s.mail.Deliver)
+	ops := operations.New(db, enqueuer)
+	queue.Register(operations.KindRun, ops.Run)
+	accounts := users.NewAccounts(userRepo, live, s.mail, authSvc)
+
+	broker := d.Broker
+	if broker == nil {
+		if broker, err = bus.NewBroker(context.Background(), cfg.Bus); err != nil {
+			return nil, fmt.Errorf("message bus %s: %w", cfg.Bus.Backend, err)
+		}
+	}
+	s.consumers = bus.NewRunner(broker, cfg.Bus)
+	s.consumers.Register(bus.Consumer{Name: "webhooks", Topic: users.Topic, Handle: fanOutWebhooks(hookSvc)})
+	if cfg.Presence.Enabled {
+		if d.Presence == nil {
+			d.Presence = newPresenceStore(rdb, cfg.Presence.TTL)
+		}
+		s.presence = presence.New(d.Presence, broker, cfg.Presence.TTL)
+		hub.Track(s.presence)
+	}
+	// Domain events go through the outbox, so that they are published if
+	// and only if the change they describe is committed.
+	s.events = outbox.New(db, broker, cfg.Bus)
+	userRepo.OnCreate(func(ctx context.Context, u *users.User) error {
+		return s.events.Publish(ctx, users.Topic, users.EventCreated, u.ID, u)
+	})
+	if cfg.Journal.Dir != "" {
+		if s.journal, err = journal.New(cfg.Journal, cfg.Uploads, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	gateway, err := proxy.New(cfg.Proxy, clientMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+
+	var uploadHandler *uploads.Handler
+	if cfg.Uploads.Bucket != "" {
+		storage, err := uploads.NewStorage(cfg.Uploads)
+		if err != nil {
+			return nil, fmt.Errorf("upload storage: %w", err)
+		}
+		uploadHandler = uploads.NewHandler(storage, cfg.Uploads)
+		uploadHandler.ChargeStorage(s.usage)
+		// Processing is the server's work, not charged to the uploader's
+		// job quota like the jobs of their requests.
+		processor := uploads.NewProcessor(storage, cfg.Uploads, queue, s.events)
+		if cfg.Uploads.ClamAVAddr != "" {
+			processor.AddScanner(uploads.ClamAV{Addr: cfg.Uploads.ClamAVAddr})
+		}
+		queue.Register(uploads.KindScan, processor.Scan)
+		queue.Register(uploads.KindThumbnail, processor.Thumbnail)
+		queue.Register(uploads.KindComplete, processor.Complete)
+		uploadHandler.Process(processor)
+		links, err := signedurl.New(cfg.SignedURLs)
+		if err != nil {
+			return nil, fmt.Errorf("signed urls: %w", err)
+		}
+		if links != nil {
+			uploadHandler.ShareLinks(links, cfg.SignedURLs)
+		}
+	}
+
+	var webHandler *web.Handler
+	if cfg.Web.Enabled {
+		webHandler, err = web.New(web.Dist(), cfg.Web.SPA, routes.APIPrefixes...)
+		if err != nil {
+			return nil, fmt.Errorf("web assets: %w", err)
+		}
+	}
+
+	var viewRenderer *views.Renderer
+	if cfg.Views.Enabled {
+		fsys, reload := views.Embedded(), false
+		if cfg.Views.Dir != "" {
+			fsys, reload = os.DirFS(cfg.Views.Dir), true
+		}
+		viewRenderer, err = views.New(fsys, reload)
+		if err != nil {
+			return nil, fmt.Errorf("page templates: %w", err)
+		}
+	}
+
+	if d.Idempotency == nil {
+		d.Idempotency = newIdempotencyStore(rdb, db)
+	}
+	if d.Sessions == nil {
+		d.Sessions = newSessionStore(rdb, s.kv)
+	}
+	if d.Replays == nil {
+		d.Replays = newReplayStore(rdb, s.kv)
+	}
+	auditLog := audit.NewLog(db)
+	tenants := tenant.NewRepository(db)
+	s.callers = &rpc.Auth{Tokens: authSvc, Keys: keyRepo, RBAC: rbacSvc, Tenants: tenants}
+	if cfg.Tenancy.Enabled {
+		s.callers.Tenancy = cfg.Tenancy
+	}
+	routes.Register(router, routes.Deps{
+		Config:       live,
+		Health:       s.checker,
+		Metrics:      m,
+		Auth:         authSvc,
+		APIKeys:      keyRepo,
+		RBAC:         rbacSvc,
+		Flags:        s.flags,
+		Idempotency:  d.Idempotency,
+		OIDC:         oidc.New(cfg.OIDC, httpclient.New(httpclient.WithMetrics(clientMetrics))),
+		Limiter:      limiter,
+		Cache:        respCache,
+		Hub:          hub,
+		Presence:     s.presence,
+		Geo:          locator,
+		SLO:          s.objectives,
+		Users:        userRepo,
+		DB:           db,
+		Accounts:     accounts,
+		Sessions:     session.NewManager(d.Sessions, cfg.Session),
+		Jobs:         enqueuer,
+		Quota:        s.usage,
+		Admission:    admission.New(live, m.Registry()),
+		Tenants:      tenants,
+		Uploads:      uploadHandler,
+		Web:          webHandler,
+		Views:        viewRenderer,
+		Gateway:      gateway,
+		Webhooks:     hookSvc,
+		Audit:        auditLog,
+		Operations:   ops,
+		Exports:      export.New(export.Users(userRepo), export.Audit(auditLog)),
+		Imports:      imports.New(db, ops, live, imports.Users(userRepo)),
+		Replays:      d.Replays,
+		GitHubEvents: broadcastGitHub(hub),
+	})
+	return s, nil
+}
+
+// ServeHTTP serves the public routes.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Start adds the workers of s to a, in dependency order, and returns the
+// names of those the listeners must start after and stop before: the
+// workers requests hand work to, and those flushing what requests left.
+// Until Start, a Server serves requests but runs no jobs or consumers.
+func (s *Server) Start(a *app.App) []string {
+	cfg := s.live.Load()
+	workers := []string{"jobs", "bus"}
+	var recovered []app.Option
+	if s.journal != nil {
+		// Recovery hands work back before any worker starts, and the
+		// shutdown report lists what was left once they all stopped.
+		a.Add("journal", app.Hook{
+			OnStart: func(ctx context.Context) error {
+				return s.journal.Start(ctx, map[string]journal.Recoverer{
+					journal.KindJob:   s.queue.Recover,
+					journal.KindEvent: s.events.Recover,
+				})
+			},
+			OnStop: s.journal.Stop,
+		})
+		recovered = append(recovered, app.After("journal"))
+	}
+	a.Add("jobs", app.Hook{
+		OnStart: func(context.Context) error { s.queue.Start(); return nil },
+		OnStop:  s.queue.Shutdown,
+	}, recovered...)
+	a.Add("bus", app.Hook{OnStart: s.consumers.Start, OnStop: s.consumers.Shutdown}, app.After("jobs"))
+	a.Add("outbox", app.Background(s.events.Run), app.After("bus"))
+	if cfg.Scheduler.Enabled {
+		a.Add("scheduler", app.Hook{
+			OnStart: func(context.Context) error { s.sched.Start(); return nil },
+			OnStop:  s.sched.Shutdown,
+		}, app.After("jobs"))
+		workers = append(workers, "scheduler")
+	}
+	if s.shadow != nil {
+		// Stopped after the listeners, so the copies of the last requests
+		// are sent too.
+		a.Add("mirror", app.Hook{
+			OnStart: func(context.Context) error { s.shadow.Start(); return nil },
+			OnStop:  s.shadow.Shutdown,
+		})
+		workers = append(workers, "mirror")
+	}
+	if s.plugins != nil {
+		a.Add("plugins", app.Hook{OnStop: s.plugins.Close})
+		workers = append(workers, "plugins")
+	}
+	if s.cacheBus != nil {
+		a.Add("cache_bus", app.Background(s.cacheBus.Run))
+		workers = append(workers, "cache_bus")
+	}
+	if s.presence != nil {
+		a.Add("presence", app.Background(s.presence.Run), app.After("bus"))
+	}
+	if s.kv != nil {
+		// Stopped after the listeners, so the last snapshot has what the
+		// last requests stored.
+		a.Add("kv", app.Hook{
+			OnStart: func(context.Context) error { s.kv.Start(); return nil },
+			OnStop:  s.kv.Shutdown,
+		})
+		workers = append(workers, "kv")
+	}
+	a.Add("maintenance", app.Background(s.mode.Watch))
+	a.Add("slo", app.Background(s.objectives.Run))
+	if s.shedder != nil {
+		a.Add("shedding", app.Background(s.shedder.Run))
+	}
+	return workers
+}
+
+// Admin returns the handler of the admin listener of cfg, which restarts
+// the process through restarter.
+func (s *Server) Admin(cfg config.Admin, logger *slog.Logger, restarter admin.Restarter) http.Handler {
+	var scrape gin.HandlerFunc
+	if cfg.Metrics {
+		scrape = s.metrics.Handler()
+	}
+	return admin.NewHandler(cfg, logger, admin.Deps{
+		Config:         s.live,
+		Restarter:      restarter,
+		Flags:          s.flags,
+		Replica:        s.db.Replica,
+		Scheduler:      s.sched,
+		Maintenance:    s.mode,
+		Mailer:         s.mail,
+		Chaos:          s.injector,
+		Quota:          s.usage,
+		Metrics:        scrape,
+		IPFilter:       s.adminFilter,
+		TrustedProxies: s.live.Load().TrustedProxies,
+		Routes:         s.router.Routes,
+		Reporter:       s.reporter,
+		SLO:            s.objectives,
+	})
+}
+
+// Health returns the liveness and readiness checks of s, for the process
+// to add its own to.
+func (s *Server) Health() *health.Checker { return s.checker }
+
+// Metrics returns the metrics s records.
+func (s *Server) Metrics() *metrics.Metrics { return s.metrics }
+
+// Routes returns the routes of the public router.
+func (s *Server) Routes() gin.RoutesInfo { return s.router.Routes() }
+
+// Jobs returns the job queue, with the handlers of every kind registered.
+func (s *Server) Jobs() *jobs.Queue { return s.queue }
+
+// Users returns the users repository, which the gRPC API serves too.
+func (s *Server) Users() *users.Repository { return s.users }
+
+// Callers returns what the gRPC API authenticates its callers with, the
+// credentials, roles and tenants of the HTTP API.
+func (s *Server) Callers() *rpc.Auth { return s.callers }
+
+// Hub returns the realtime hub, whose connections the process closes after
+// the listener stopped.
+func (s *Server) Hub() *realtime.Hub { return s.hub }
+
+// Journal returns the recovery journal, or nil when it is off.
+func (s *Server) Journal() *journal.Journal { return s.journal }
+
+// Activity returns what tracks whether the instance is idle, or nil when
+// autostop is off.
+func (s *Server) Activity() *idle.Tracker { return s.activity }
+
+// fanOutWebhooks queues the webhook deliveries of the domain events it
+// consumes, in the tenant they were published in.
+func fanOutWebhooks(hookSvc *hooks.Service) bus.Handler {
+	return func(ctx context.Context, m *bus.Message) error {
+		return hookSvc.Publish(ctx, m.Type, m.Data)
+	}
+}
+
+// newLimiter builds the configured rate limiter, or nil when disabled.
+func newLimiter(cfg config.RateLimit, rdb *redis.Client) ratelimit.Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	policy := ratelimit.Policy{Rate: cfg.Rate, Burst: cfg.Burst}
+	if cfg.Backend == "redis" {
+		return ratelimit.NewRedis(rdb, policy)
+	}
+	return ratelimit.NewMemory(policy)
+}
+
+// newResponseCache returns nil when the response cache is disabled, which
+// leaves cached routes passing straight through.
+func newResponseCache(cfg config.Cache, rdb *redis.Client, db *kv.DB) *httpcache.Cache {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Backend == "redis" {
+		return httpcache.New(httpcache.NewRedisStore(rdb), cfg.TTL)
+	}
+	if cfg.Backend == "kv" {
+		return httpcache.New(httpcache.NewKVStore(db), cfg.TTL)
+	}
+	return httpcache.New(httpcache.NewMemoryStore(), cfg.TTL)
+}
+
+// newQuota returns nil when quotas are disabled, which leaves usage
+// uncounted.
+func newQuota(cfg config.Quota, live *config.Live, rdb *redis.Client, db *store.Store) *quota.Enforcer {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Backend == "redis" {
+		return quota.New(quota.NewRedisStore(rdb), live)
+	}
+	return quota.New(quota.NewSQLStore(db), live)
+}
+
+// newDataCache returns a constructor of the lookup caches, which share one
+// store and the configured TTL, jitter and codec.
+func newDataCache(cfg config.DataCache, rdb *redis.Client, db *kv.DB, metrics *cache.Metrics) func(name string) *cache.Cache {
+	var st cache.Store = cache.NewMemoryStore()
+	switch cfg.Backend {
+	case "redis":
+		st = cache.NewRedisStore(rdb)
+	case "kv":
+		st = cache.NewKVStore(db)
+	}
+	// Validate has checked the codec name.
+	codec, _ := cache.CodecByName(cfg.Codec)
+	return func(name string) *cache.Cache {
+		return cache.New(name, st, cache.WithTTL(cfg.TTL), cache.WithJitter(cfg.Jitter),
+			cache.WithCodec(codec), cache.WithMetrics(metrics))
+	}
+}
+
+// newIdempotencyStore prefers Redis, which expires records by itself, and
+// falls back to the database.
+func newIdempotencyStore(rdb *redis.Client, db *store.Store) idempotency.Store {
+	if rdb != nil {
+		return idempotency.NewRedisStore(rdb)
+	}
+	return idempotency.NewSQLStore(db)
+}
+
+// newPresenceStore shares presence through Redis when available.
+func newPresenceStore(rdb *redis.Client, ttl time.Duration) presence.Store {
+	if rdb != nil {
+		return presence.NewRedisStore(rdb, ttl)
+	}
+	return presence.NewMemoryStore(ttl)
+}
+
+// newFlagStore shares feature flag overrides through Redis when available,
+// and keeps them in the embedded store otherwise, when there is one.
+func newFlagStore(rdb *redis.Client, db *kv.DB) featureflag.Store {
+	if rdb != nil {
+		return featureflag.NewRedisStore(rdb)
+	}
+	if db != nil {
+		return featureflag.NewKVStore(db)
+	}
+	return featureflag.NewMemoryStore()
+}
+
+// broadcastGitHub relays verified GitHub webhooks to the realtime clients,
+// so that they see repository activity as it happens.
+func broadcastGitHub(hub *realtime.Hub) func(context.Context, *webhooks.GitHubEvent) error {
+	return func(_ context.Context, e *webhooks.GitHubEvent) error {
+		msg, err := json.Marshal(gin.H{"type": "github." + e.Event, "repository": e.Repository.FullName,
+			"sender": e.Sender.Login, "action": e.Action, "ref": e.Ref})
+		if err != nil {
+			return err
+		}
+		hub.Broadcast(msg)
+		return nil
+	}
+}
+
+// newElector elects the scheduler leader through Redis when available and
+// through the database otherwise.
+func newElector(rdb *redis.Client, db *store.Store) scheduler.Elector {
+	id := scheduler.InstanceID()
+	if rdb != nil {
+		return scheduler.NewRedisElector(rdb, id)
+	}
+	return scheduler.NewSQLElector(db, id)
+}
+
+// newReplayStore shares seen webhook deliveries through Redis when
+// available, so a replay to another instance is caught too.
+func newReplayStore(rdb *redis.Client, db *kv.DB) webhooks.Store {
+	if rdb != nil {
+		return webhooks.NewRedisStore(rdb)
+	}
+	if db != nil {
+		return webhooks.NewKVStore(db)
+	}
+	return webhooks.NewMemoryStore()
+}
+
+// newSessionStore keeps sessions in Redis when available, or else in the
+// embedded store of a single node. The memory store only suits
+// single-instance development.
+func newSessionStore(rdb *redis.Client, db *kv.DB) session.Store {
+	if rdb != nil {
+		return session.NewRedisStore(rdb)
+	}
+	if db != nil {
+		return session.NewKVStore(db)
+	}
+	slog.Warn("REDIS_URL not set; sessions are kept in memory and not shared between instances")
+	return session.NewMemoryStore()
+}
@@ -0,0 +1,157 @@
+package session
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+)
+
+// HeaderCSRF carries the CSRF token on unsafe requests; form posts may use
+// the csrf_token field instead.
+const HeaderCSRF = "X-CSRF-Token"
+
+const contextKey = "session"
+
+// Manager loads sessions from cookies and saves them back to a Store.
+type Manager struct {
+	store Store
+	cfg   config.Session
+}
+
+// NewManager returns a Manager using store and the cookie settings in cfg.
+func NewManager(store Store, cfg config.Session) *Manager {
+	return &Manager{store: store, cfg: cfg}
+}
+
+// Middleware attaches the request's session to the context. Existing
+// sessions have their expiry extended on every request; new sessions are
+// only persisted, and their cookie only sent, once something is stored.
+// Handlers must modify the session before writing the response body.
+func (m *Manager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		s := m.load(c)
+		s.changed = func(s *Session) { m.writeCookie(c, s) }
+		if !s.isNew {
+			m.writeCookie(c, s)
+		}
+		c.Set(contextKey, s)
+
+		c.Next()
+
+		if s.previous != "" {
+			if err := m.store.Delete(ctx, s.previous); err != nil {
+				slog.WarnContext(ctx, "session delete failed", "error", err)
+			}
+		}
+		switch {
+		case s.destroyed:
+			if !s.isNew {
+				if err := m.store.Delete(ctx, s.id); err != nil {
+					slog.WarnContext(ctx, "session delete failed", "error", err)
+				}
+			}
+		case s.dirty:
+			data, _ := json.Marshal(record{Values: s.values, CSRF: s.csrf})
+			if err := m.store.Set(ctx, s.id, data, m.cfg.TTL); err != nil {
+				slog.ErrorContext(ctx, "session save failed", "error", err)
+			}
+		}
+	}
+}
+
+func (m *Manager) load(c *gin.Context) *Session {
+	id, err := c.Cookie(m.cfg.CookieName)
+	if err != nil || id == "" {
+		return newSession()
+	}
+	data, err := m.store.Get(c.Request.Context(), id, m.cfg.TTL)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			slog.WarnContext(c.Request.Context(), "session load failed", "error", err)
+		}
+		return newSession()
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return newSession()
+	}
+	if rec.Values == nil {
+		rec.Values = map[string]string{}
+	}
+	return &Session{id: id, values: rec.Values, csrf: rec.CSRF}
+}
+
+func (m *Manager) writeCookie(c *gin.Context, s *Session) {
+	cookie := &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    s.id,
+		Path:     "/",
+		Domain:   m.cfg.Domain,
+		MaxAge:   int(m.cfg.TTL / time.Second),
+		Secure:   m.cfg.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if s.destroyed {
+		cookie.Value, cookie.MaxAge = "", -1
+	}
+	// Replace our own cookie if the session changed twice, keeping any
+	// cookies set by other handlers.
+	h := c.Writer.Header()
+	prefix := m.cfg.CookieName + "="
+	kept := h.Values("Set-Cookie")[:0:0]
+	for _, v := range h.Values("Set-Cookie") {
+		if !strings.HasPrefix(v, prefix) {
+			kept = append(kept, v)
+		}
+	}
+	h["Set-Cookie"] = append(kept, cookie.String())
+}
+
+// From returns the session attached by Middleware.
+func From(c *gin.Context) *Session {
+	if v, ok := c.Get(contextKey); ok {
+		if s, ok := v.(*Session); ok {
+			return s
+		}
+	}
+	return newSession()
+}
+
+// CSRF rejects unsafe requests on an existing session unless they echo the
+// session's CSRF token. Requests without a session carry no ambient
+// authority and pass through.
+func CSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		s := From(c)
+		if s.IsNew() {
+			c.Next()
+			return
+		}
+		token := c.GetHeader(HeaderCSRF)
+		if token == "" {
+			token = c.PostForm("csrf_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRFToken())) != 1 {
+			c.Error(apperror.Forbidden("missing or invalid CSRF token"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,91 @@
+// Package session provides cookie-based server-side sessions with sliding
+// expiration and per-session CSRF tokens.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"maps"
+)
+
+// Session is the state attached to one browser. Mutations are persisted
+// after the handler chain finishes.
+type Session struct {
+	id     string
+	values map[string]string
+	csrf   string
+
+	isNew     bool
+	dirty     bool
+	destroyed bool
+	previous  string // id replaced by Regenerate, deleted on save
+	changed   func(*Session)
+}
+
+type record struct {
+	Values map[string]string `json:"values"`
+	CSRF   string            `json:"csrf"`
+}
+
+func newSession() *Session {
+	return &Session{id: randomToken(), values: map[string]string{}, csrf: randomToken(), isNew: true}
+}
+
+// ID returns the session identifier.
+func (s *Session) ID() string { return s.id }
+
+// IsNew reports whether the session was created during this request.
+func (s *Session) IsNew() bool { return s.isNew }
+
+// Get returns the value stored under key.
+func (s *Session) Get(key string) string { return s.values[key] }
+
+// Values returns a copy of all stored values.
+func (s *Session) Values() map[string]string { return maps.Clone(s.values) }
+
+// Set stores value under key.
+func (s *Session) Set(key, value string) {
+	s.values[key] = value
+	s.touch()
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	delete(s.values, key)
+	s.touch()
+}
+
+// CSRFToken returns the token that unsafe requests must echo back.
+func (s *Session) CSRFToken() string { return s.csrf }
+
+// Regenerate issues a new session ID and CSRF token while keeping the
+// values. Call it when privileges change, e.g. on login, to prevent
+// session fixation.
+func (s *Session) Regenerate() {
+	if !s.isNew && s.previous == "" {
+		s.previous = s.id
+	}
+	s.id = randomToken()
+	s.csrf = randomToken()
+	s.touch()
+}
+
+// Destroy deletes the session and expires its cookie.
+func (s *Session) Destroy() {
+	s.destroyed = true
+	s.values = map[string]string{}
+	s.touch()
+}
+
+func (s *Session) touch() {
+	s.dirty = true
+	if s.changed != nil {
+		s.changed(s)
+	}
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,156 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/kv"
+)
+
+// ErrNotFound is returned by a Store for unknown or expired sessions.
+var ErrNotFound = errors.New("session: not found")
+
+// Store persists encoded session data. Get also extends the expiry, which
+// implements sliding expiration.
+type Store interface {
+	Get(ctx context.Context, id string, ttl time.Duration) ([]byte, error)
+	Set(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+}
+
+// RedisStore keeps sessions in Redis so every instance sees them.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client, prefix: "session:"}
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, id string, ttl time.Duration) ([]byte, error) {
+	data, err := s.client.GetEx(ctx, s.prefix+id, ttl).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session: redis get: %w", err)
+	}
+	return data, nil
+}
+
+// Set implements Store.
+func (s *RedisStore) Set(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	if err := s.client.Set(ctx, s.prefix+id, data, ttl).Err(); err != nil {
+		return fmt.Errorf("session: redis set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	if err := s.client.Del(ctx, s.prefix+id).Err(); err != nil {
+		return fmt.Errorf("session: redis delete: %w", err)
+	}
+	return nil
+}
+
+// MemoryStore keeps sessions in process memory. It is meant for local
+// development: sessions are lost on restart and not shared between
+// instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, id string, ttl time.Duration) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[id]
+	if !ok || time.Now().After(e.expires) {
+		delete(s.sessions, id)
+		return nil, ErrNotFound
+	}
+	e.expires = time.Now().Add(ttl)
+	s.sessions[id] = e
+	return e.data, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, e := range s.sessions {
+		if now.After(e.expires) {
+			delete(s.sessions, k)
+		}
+	}
+	s.sessions[id] = memoryEntry{data: data, expires: now.Add(ttl)}
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// KVStore keeps sessions in a bucket of the embedded store, kept across
+// restarts of a single node.
+type KVStore struct {
+	bucket *kv.Bucket
+}
+
+// NewKVStore returns a Store backed by db.
+func NewKVStore(db *kv.DB) *KVStore {
+	return &KVStore{bucket: db.Bucket("session")}
+}
+
+// Get implements Store.
+func (s *KVStore) Get(_ context.Context, id string, ttl time.Duration) ([]byte, error) {
+	data, err := s.bucket.Extend(id, ttl)
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session: kv get: %w", err)
+	}
+	return data, nil
+}
+
+// Set implements Store.
+func (s *KVStore) Set(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	if err := s.bucket.Put(id, data, ttl); err != nil {
+		return fmt.Errorf("session: kv set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *KVStore) Delete(_ context.Context, id string) error {
+	if err := s.bucket.Delete(id); err != nil {
+		return fmt.Errorf("session: kv delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package shed sheds load: when the instance is overloaded it refuses the
+// requests that matter least with 503, so that the others are still served
+// in time instead of all of them slowing down until the machine falls
+// over. Pressure is sampled every second from the goroutine count, memory
+// use against GOMEMLIMIT and the recent latency of normal requests, each
+// as a multiple of its threshold; the highest one is the pressure.
+//
+// Low-priority requests are shed once the pressure reaches 1, and normal
+// ones once it reaches the configured NormalAt. Critical requests, such as
+// the health probes, are always admitted, as is the admin listener, which
+// does not go through the middleware.
+package shed
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/maintenance"
+	"go-flylike-example/internal/validation"
+)
+
+// Priority is how much a request matters when the instance is overloaded.
+type Priority int
+
+// Priorities, from the first shed to never shed.
+const (
+	Low Priority = iota
+	Normal
+	Critical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Low:
+		return "low"
+	case Critical:
+		return "critical"
+	}
+	return "normal"
+}
+
+// HeaderPriority lets clients lower the priority of their requests, such
+// as batch jobs that can wait; it never raises it.
+const HeaderPriority = "X-Priority"
+
+// Classify returns the priority of the request under cfg: critical below
+// CriticalPaths and for the health probes, low below LowPaths or when
+// asked with HeaderPriority, and normal otherwise.
+func Classify(r *http.Request, cfg config.Shedding) Priority {
+	path := r.URL.Path
+	if slices.Contains(maintenance.Exempt, strings.TrimSuffix(path, "/")) || below(path, cfg.CriticalPaths) {
+		return Critical
+	}
+	if below(path, cfg.LowPaths) || strings.EqualFold(r.Header.Get(HeaderPriority), "low") {
+		return Low
+	}
+	return Normal
+}
+
+func below(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleInterval is how often the pressure is sampled.
+const sampleInterval = time.Second
+
+// maxLatencies bounds the latencies kept for the percentile; the oldest
+// are dropped past it, as are those older than the window.
+const maxLatencies = 1024
+
+type latency struct {
+	at time.Time
+	d  time.Duration
+}
+
+// Shedder measures the pressure on the instance and refuses requests by
+// priority while there is too much.
+type Shedder struct {
+	live *config.Live
+
+	// pressure is the last sample, as math.Float64bits.
+	pressure atomic.Uint64
+
+	mu        sync.Mutex
+	latencies []latency
+	next      int
+
+	shed  *prometheus.CounterVec
+	gauge prometheus.Gauge
+}
+
+// New returns a Shedder for the shedding configuration of live, recording
+// on reg, or nil when shedding is off. Run samples the pressure.
+func New(live *config.Live, reg prometheus.Registerer) *Shedder {
+	if !live.Load().Shedding.Enabled {
+		return nil
+	}
+	s := &Shedder{
+		live:      live,
+		latencies: make([]latency, 0, maxLatencies),
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "shed",
+			Name:      "requests_total",
+			Help:      "Requests refused under load, by priority.",
+		}, []string{"priority"}),
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "shed",
+			Name:      "pressure",
+			Help:      "Load on the instance as a multiple of the shedding thresholds; requests are shed from 1.",
+		}),
+	}
+	reg.MustRegister(s.shed, s.gauge)
+	return s
+}
+
+// Pressure returns the last sampled pressure.
+func (s *Shedder) Pressure() float64 {
+	return math.Float64frombits(s.pressure.Load())
+}
+
+// Run samples the pressure until ctx is done, logging when shedding starts
+// and stops.
+func (s *Shedder) Run(ctx context.Context) {
+	t := time.NewTicker(sampleInterval)
+	defer t.Stop()
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		cfg := s.live.Load().Shedding
+		before := s.Pressure()
+		gr, mem, lat := 0.0, 0.0, 0.0
+		if cfg.MaxGoroutines > 0 {
+			gr = float64(runtime.NumGoroutine()) / float64(cfg.MaxGoroutines)
+		}
+		// debug.SetMemoryLimit with a negative limit only reads it; none
+		// set is math.MaxInt64.
+		if limit := debug.SetMemoryLimit(-1); cfg.MemoryRatio > 0 && limit < math.MaxInt64 {
+			metrics.Read(samples)
+			used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+			mem = float64(used) / float64(limit) / cfg.MemoryRatio
+		}
+		if cfg.MaxLatency > 0 {
+			lat = float64(s.p99(cfg.Window)) / float64(cfg.MaxLatency)
+		}
+		p := max(gr, mem, lat)
+		s.pressure.Store(math.Float64bits(p))
+		s.gauge.Set(p)
+		switch {
+		case p >= 1 && before < 1:
+			slog.Warn("shedding load", "pressure", round(p), "goroutines", round(gr), "memory", round(mem), "latency", round(lat))
+		case p < 1 && before >= 1:
+			slog.Info("load shedding stopped", "pressure", round(p))
+		}
+	}
+}
+
+func round(f float64) float64 { return math.Round(f*100) / 100 }
+
+// p99 returns the 99th percentile latency of the normal requests that
+// finished within window, zero without any.
+func (s *Shedder) p99(window time.Duration) time.Duration {
+	since := time.Now().Add(-window)
+	s.mu.Lock()
+	ds := make([]time.Duration, 0, len(s.latencies))
+	for _, l := range s.latencies {
+		if l.at.After(since) {
+			ds = append(ds, l.d)
+		}
+	}
+	s.mu.Unlock()
+	if len(ds) == 0 {
+		return 0
+	}
+	slices.Sort(ds)
+	return ds[(len(ds)-1)*99/100]
+}
+
+func (s *Shedder) record(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := latency{at: time.Now(), d: d}
+	if len(s.latencies) < maxLatencies {
+		s.latencies = append(s.latencies, l)
+		return
+	}
+	s.latencies[s.next] = l
+	s.next = (s.next + 1) % maxLatencies
+}
+
+// Middleware answers the requests the current pressure sheds with 503 and
+// Retry-After, and records the latency of the normal requests it admits:
+// low-priority ones are long by nature, such as exports and streams.
+func (s *Shedder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.live.Load().Shedding
+		prio := Classify(c.Request, cfg)
+		if p := s.Pressure(); prio == Low && p >= 1 || prio == Normal && p >= cfg.NormalAt {
+			s.shed.WithLabelValues(prio.String()).Inc()
+			c.Header("Retry-After", strconv.Itoa(int(cfg.RetryAfter.Round(time.Second)/time.Second)))
+			// Written here rather than through c.Error: shedding is
+			// intended and must not fill the error log and samples.
+			validation.Abort(c, validation.NewProblem(http.StatusServiceUnavailable, i18n.T(c, "the server is overloaded, try again later")))
+			return
+		}
+		if prio != Normal {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		s.record(time.Since(start))
+	}
+}
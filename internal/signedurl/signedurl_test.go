@@ -0,0 +1,159 @@
+package signedurl
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/redact"
+)
+
+var (
+	current  = Key{ID: "k2", Secret: []byte("0123456789abcdef0123456789abcdef")}
+	previous = Key{ID: "k1", Secret: []byte("fedcba9876543210fedcba9876543210")}
+)
+
+func ring(t *testing.T, keys ...Key) *KeyRing {
+	t.Helper()
+	k, err := NewKeyRing(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// edit returns a copy of u with its query changed by f.
+func edit(u *url.URL, f func(q url.Values)) *url.URL {
+	c := *u
+	q := c.Query()
+	f(q)
+	c.RawQuery = q.Encode()
+	return &c
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	k := ring(t, current, previous)
+	u, _ := url.Parse("https://api.example.com/api/v2/uploads/abc/content?download=1")
+	link := k.Sign(http.MethodGet, u, now.Add(time.Hour))
+	byPrevious := ring(t, previous).Sign(http.MethodGet, u, now.Add(time.Hour))
+	byStranger := ring(t, Key{ID: current.ID, Secret: []byte("another secret of thirty-two bytes")}).
+		Sign(http.MethodGet, u, now.Add(time.Hour))
+	otherPath := *link
+	otherPath.Path = "/api/v2/uploads/xyz/content"
+
+	tests := []struct {
+		name   string
+		method string
+		link   *url.URL
+		at     time.Time
+		want   error
+	}{
+		{"valid", http.MethodGet, link, now, nil},
+		{"head of a get link", http.MethodHead, link, now, nil},
+		{"signed by a previous key", http.MethodGet, byPrevious, now, nil},
+		{"just before expiry", http.MethodGet, link, now.Add(time.Hour - time.Second), nil},
+		{"at expiry", http.MethodGet, link, now.Add(time.Hour), ErrExpired},
+		{"other method", http.MethodDelete, link, now, ErrInvalid},
+		{"other path", http.MethodGet, &otherPath, now, ErrInvalid},
+		{"changed parameter", http.MethodGet, edit(link, func(q url.Values) { q.Set("download", "0") }), now, ErrInvalid},
+		{"added parameter", http.MethodGet, edit(link, func(q url.Values) { q.Set("inline", "1") }), now, ErrInvalid},
+		{"removed parameter", http.MethodGet, edit(link, func(q url.Values) { q.Del("download") }), now, ErrInvalid},
+		{"extended expiry", http.MethodGet, edit(link, func(q url.Values) {
+			q.Set(ParamExpires, strconv.FormatInt(now.Add(24*time.Hour).Unix(), 10))
+		}), now.Add(2 * time.Hour), ErrInvalid},
+		{"other key id", http.MethodGet, edit(link, func(q url.Values) { q.Set(ParamKey, previous.ID) }), now, ErrInvalid},
+		{"unknown key id", http.MethodGet, edit(link, func(q url.Values) { q.Set(ParamKey, "k0") }), now, ErrInvalid},
+		{"signed by a key of the same id", http.MethodGet, byStranger, now, ErrInvalid},
+		{"unsigned", http.MethodGet, u, now, ErrInvalid},
+		{"malformed signature", http.MethodGet, edit(link, func(q url.Values) { q.Set(ParamSignature, "not base64!") }), now, ErrInvalid},
+		{"empty signature", http.MethodGet, edit(link, func(q url.Values) { q.Set(ParamSignature, "") }), now, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := k.Verify(tt.method, tt.link, tt.at); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRotation(t *testing.T) {
+	now := time.Now()
+	u, _ := url.Parse("/api/v2/uploads/abc/content")
+	old := ring(t, previous).Sign(http.MethodGet, u, now.Add(time.Hour))
+
+	tests := []struct {
+		name string
+		ring *KeyRing
+		want error
+	}{
+		{"before rotation", ring(t, previous), nil},
+		{"new key first", ring(t, current, previous), nil},
+		{"old key removed", ring(t, current), ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ring.Verify(http.MethodGet, old, now); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if got := ring(t, current, previous).Sign(http.MethodGet, u, now).Query().Get(ParamKey); got != current.ID {
+		t.Errorf("signed with key %s, want %s", got, current.ID)
+	}
+}
+
+func TestNewKeyRing(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []Key
+		ok   bool
+	}{
+		{"one", []Key{current}, true},
+		{"two", []Key{current, previous}, true},
+		{"none", nil, false},
+		{"without id", []Key{{Secret: current.Secret}}, false},
+		{"without secret", []Key{{ID: "k3"}}, false},
+		{"duplicate id", []Key{current, {ID: current.ID, Secret: previous.Secret}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyRing(tt.keys...); (err == nil) != tt.ok {
+				t.Fatalf("NewKeyRing = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// TestRedacted checks that logs, mirrored copies and contract fixtures,
+// which all go through redact.Query, never carry a usable link.
+func TestRedacted(t *testing.T) {
+	u, _ := url.Parse("/api/v2/uploads/abc/content?download=1&token=t")
+	link := ring(t, current).Sign(http.MethodGet, u, time.Now().Add(time.Hour))
+	tests := []struct {
+		name  string
+		names []string
+		want  string
+	}{
+		{"no names", nil, "download=1&token=t"},
+		{"default names", config.Default().Mirror.Redact, "download=1&token=%5Bredacted%5D"},
+		{"other names", []string{"download"}, "download=%5Bredacted%5D&token=t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact.Query(link.Query(), tt.names); got != tt.want {
+				t.Fatalf("Query = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	for _, p := range []string{ParamExpires, ParamKey, ParamSignature} {
+		if !link.Query().Has(p) {
+			t.Errorf("link has no %s parameter", p)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package slo
+
+import "time"
+
+// counts are the requests of a span and how many of them were bad.
+type counts struct {
+	total, bad uint64
+}
+
+// ring counts requests in consecutive buckets of one size; a bucket is
+// reused once it is older than the ring is long.
+type ring struct {
+	size    time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	// index is the number of the span of size since the epoch the counts
+	// are of.
+	index int64
+	counts
+}
+
+func newRing(size time.Duration, n int) ring {
+	return ring{size: size, buckets: make([]bucket, n)}
+}
+
+// span is how far back the ring counts.
+func (r *ring) span() time.Duration {
+	return r.size * time.Duration(len(r.buckets))
+}
+
+func (r *ring) add(now time.Time, bad bool) {
+	i := now.UnixNano() / int64(r.size)
+	b := &r.buckets[i%int64(len(r.buckets))]
+	if b.index != i {
+		*b = bucket{index: i}
+	}
+	b.total++
+	if bad {
+		b.bad++
+	}
+}
+
+// sum returns the counts of the buckets within d of now, the current one
+// included.
+func (r *ring) sum(now time.Time, d time.Duration) counts {
+	last := now.UnixNano() / int64(r.size)
+	n := min(int64((d+r.size-1)/r.size), int64(len(r.buckets)))
+	var c counts
+	for _, b := range r.buckets {
+		if b.index > last-n && b.index <= last {
+			c.total += b.total
+			c.bad += b.bad
+		}
+	}
+	return c
+}
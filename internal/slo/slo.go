@@ -0,0 +1,363 @@
+// Package slo tracks service level objectives where the requests are
+// served, rather than in dashboards: routes declare their objectives, an
+// availability target, the share of requests not failing with a server
+// error, and a latency one, the share of the others answered within a
+// threshold, and the metrics middleware reports the outcome of every
+// request. From these the Tracker computes what is left of the error
+// budget of each objective over the period, how fast it burns over the
+// windows of the classic multiwindow alerts, and which of those alerts
+// fire. The numbers are exported as gauges and served at /admin/slo.
+//
+// Counts are kept per instance in memory, by minute for the last six hours
+// and by hour for the period, so a restart begins the budget afresh.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-flylike-example/internal/config"
+)
+
+// Indicators an objective can set a target for.
+const (
+	Availability = "availability"
+	Latency      = "latency"
+)
+
+// Objective is what a route promises. A zero target sets no objective on
+// that indicator.
+type Objective struct {
+	// Name labels the metrics, such as "list_users".
+	Name string
+	// Method and Route name the route, Route as registered, such as
+	// /api/v2/users/:id.
+	Method string
+	Route  string
+	// Availability is the share of requests that must not fail with a
+	// server error, such as 0.999.
+	Availability float64
+	// Latency is the share of the requests that did not fail that must be
+	// answered within Threshold, such as 0.99.
+	Latency   float64
+	Threshold time.Duration
+}
+
+// window is a span burn rates are computed over.
+type window struct {
+	name string
+	d    time.Duration
+}
+
+var windows = []window{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+	{"3d", 72 * time.Hour},
+}
+
+// rule is a multiwindow burn-rate alert: it fires while both windows burn
+// faster than burn. The rules are those of the SRE workbook for a 30-day
+// period: a page when 2% of the budget goes in an hour or 5% in six hours,
+// a ticket when 10% goes in three days.
+type rule struct {
+	severity    string
+	long, short string
+	burn        float64
+}
+
+var rules = []rule{
+	{"page", "1h", "5m", 14.4},
+	{"page", "6h", "30m", 6},
+	{"ticket", "3d", "6h", 1},
+}
+
+// evaluateInterval is how often the gauges and alerts are brought up to
+// date.
+const evaluateInterval = 30 * time.Second
+
+// Tracker counts the requests of the declared objectives.
+type Tracker struct {
+	period time.Duration
+
+	mu      sync.RWMutex
+	byRoute map[string]*tracked
+	order   []*tracked
+
+	target *prometheus.GaugeVec
+	budget *prometheus.GaugeVec
+	burn   *prometheus.GaugeVec
+	alert  *prometheus.GaugeVec
+}
+
+type tracked struct {
+	Objective
+	indicators []*indicator
+}
+
+// indicator counts the requests of one indicator of an objective.
+type indicator struct {
+	name   string
+	target float64
+
+	mu      sync.Mutex
+	minutes ring
+	hours   ring
+	// firing is the severity of the alert that fired last, if any.
+	firing string
+}
+
+// New returns a Tracker over the period of cfg, recording on reg.
+func New(cfg config.SLO, reg prometheus.Registerer) *Tracker {
+	t := &Tracker{
+		period:  cfg.Period,
+		byRoute: map[string]*tracked{},
+		target: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "slo",
+			Name:      "target",
+			Help:      "Share of good requests an objective promises.",
+		}, []string{"objective", "sli"}),
+		budget: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "slo",
+			Name:      "error_budget_remaining",
+			Help:      "Share of the error budget of the period left; negative once overspent.",
+		}, []string{"objective", "sli"}),
+		burn: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "slo",
+			Name:      "burn_rate",
+			Help:      "How many times faster than sustainable the error budget burns over the window.",
+		}, []string{"objective", "sli", "window"}),
+		alert: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "slo",
+			Name:      "alert",
+			Help:      "1 while the burn-rate alert of the severity fires.",
+		}, []string{"objective", "sli", "severity"}),
+	}
+	reg.MustRegister(t.target, t.budget, t.burn, t.alert)
+	return t
+}
+
+// Declare adds objectives, each on a route of its own.
+func (t *Tracker) Declare(objectives ...Objective) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hours := int(max(t.period, windows[len(windows)-1].d).Hours() + 1)
+	for _, o := range objectives {
+		if o.Name == "" || o.Method == "" || o.Route == "" {
+			return fmt.Errorf("slo: objective needs a name, a method and a route")
+		}
+		key := o.Method + " " + o.Route
+		if prev, dup := t.byRoute[key]; dup {
+			return fmt.Errorf("slo: %s already has objective %s", key, prev.Name)
+		}
+		switch {
+		case o.Availability == 0 && o.Latency == 0:
+			return fmt.Errorf("slo: %s sets no target", o.Name)
+		case o.Availability < 0 || o.Availability >= 1 || o.Latency < 0 || o.Latency >= 1:
+			return fmt.Errorf("slo: %s targets must be between 0 and 1", o.Name)
+		case o.Latency > 0 && o.Threshold <= 0:
+			return fmt.Errorf("slo: %s latency target needs a threshold", o.Name)
+		}
+		tr := &tracked{Objective: o}
+		for _, ind := range []struct {
+			name   string
+			target float64
+		}{{Availability, o.Availability}, {Latency, o.Latency}} {
+			if ind.target == 0 {
+				continue
+			}
+			tr.indicators = append(tr.indicators, &indicator{
+				name:    ind.name,
+				target:  ind.target,
+				minutes: newRing(time.Minute, 6*60),
+				hours:   newRing(time.Hour, hours),
+			})
+			t.target.WithLabelValues(o.Name, ind.name).Set(ind.target)
+		}
+		t.byRoute[key] = tr
+		t.order = append(t.order, tr)
+	}
+	return nil
+}
+
+// Declared returns the declared objectives, in the order of declaration.
+func (t *Tracker) Declared() []Objective {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	objectives := make([]Objective, len(t.order))
+	for i, tr := range t.order {
+		objectives[i] = tr.Objective
+	}
+	return objectives
+}
+
+// Observe counts a request to route that was answered with status after d.
+// Routes without an objective are ignored.
+func (t *Tracker) Observe(method, route string, status int, d time.Duration) {
+	t.mu.RLock()
+	tr := t.byRoute[method+" "+route]
+	t.mu.RUnlock()
+	if tr == nil {
+		return
+	}
+	now := time.Now()
+	failed := status >= http.StatusInternalServerError
+	for _, ind := range tr.indicators {
+		switch {
+		case ind.name == Availability:
+			ind.add(now, failed)
+		case !failed:
+			ind.add(now, d > tr.Threshold)
+		}
+	}
+}
+
+func (ind *indicator) add(now time.Time, bad bool) {
+	ind.mu.Lock()
+	defer ind.mu.Unlock()
+	ind.minutes.add(now, bad)
+	ind.hours.add(now, bad)
+}
+
+// Status is where an objective stands.
+type Status struct {
+	Name       string      `json:"name"`
+	Method     string      `json:"method"`
+	Route      string      `json:"route"`
+	Indicators []Indicator `json:"indicators"`
+}
+
+// Indicator is where one indicator of an objective stands over the period.
+type Indicator struct {
+	SLI       string  `json:"sli"`
+	Target    float64 `json:"target"`
+	Threshold string  `json:"threshold,omitempty"`
+	Period    string  `json:"period"`
+	Requests  uint64  `json:"requests"`
+	Bad       uint64  `json:"bad"`
+	// BudgetRemaining is the share of the error budget left, negative once
+	// overspent.
+	BudgetRemaining float64            `json:"budget_remaining"`
+	BurnRates       map[string]float64 `json:"burn_rates"`
+	// Alert is the severity of the burn-rate alert firing, if any.
+	Alert string `json:"alert,omitempty"`
+}
+
+// Report returns where every objective stands now.
+func (t *Tracker) Report() []Status {
+	t.mu.RLock()
+	order := t.order
+	t.mu.RUnlock()
+	now := time.Now()
+	report := make([]Status, 0, len(order))
+	for _, tr := range order {
+		s := Status{Name: tr.Name, Method: tr.Method, Route: tr.Route}
+		for _, ind := range tr.indicators {
+			s.Indicators = append(s.Indicators, t.indicator(tr, ind, now))
+		}
+		report = append(report, s)
+	}
+	return report
+}
+
+func (t *Tracker) indicator(tr *tracked, ind *indicator, now time.Time) Indicator {
+	ind.mu.Lock()
+	defer ind.mu.Unlock()
+	total := ind.hours.sum(now, t.period)
+	out := Indicator{
+		SLI:             ind.name,
+		Target:          ind.target,
+		Period:          t.period.String(),
+		Requests:        total.total,
+		Bad:             total.bad,
+		BudgetRemaining: 1 - burnRate(total, ind.target),
+		BurnRates:       map[string]float64{},
+	}
+	if ind.name == Latency {
+		out.Threshold = tr.Threshold.String()
+	}
+	for _, w := range windows {
+		r := &ind.minutes
+		if w.d > ind.minutes.span() {
+			r = &ind.hours
+		}
+		out.BurnRates[w.name] = round(burnRate(r.sum(now, w.d), ind.target))
+	}
+	for _, rl := range rules {
+		if out.BurnRates[rl.long] > rl.burn && out.BurnRates[rl.short] > rl.burn {
+			out.Alert = rl.severity
+			break
+		}
+	}
+	out.BudgetRemaining = round(out.BudgetRemaining)
+	return out
+}
+
+// burnRate is the share of bad requests in c as a multiple of the share
+// the target allows.
+func burnRate(c counts, target float64) float64 {
+	if c.total == 0 {
+		return 0
+	}
+	return float64(c.bad) / float64(c.total) / (1 - target)
+}
+
+func round(f float64) float64 { return math.Round(f*1000) / 1000 }
+
+// Run brings the gauges up to date every evaluateInterval until ctx is
+// done, logging when a burn-rate alert starts and stops firing.
+func (t *Tracker) Run(ctx context.Context) {
+	tick := time.NewTicker(evaluateInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		t.evaluate()
+	}
+}
+
+func (t *Tracker) evaluate() {
+	t.mu.RLock()
+	order := t.order
+	t.mu.RUnlock()
+	now := time.Now()
+	for _, tr := range order {
+		for _, ind := range tr.indicators {
+			s := t.indicator(tr, ind, now)
+			t.budget.WithLabelValues(tr.Name, ind.name).Set(s.BudgetRemaining)
+			for _, w := range windows {
+				t.burn.WithLabelValues(tr.Name, ind.name, w.name).Set(s.BurnRates[w.name])
+			}
+			for _, severity := range []string{"page", "ticket"} {
+				v := 0.0
+				if s.Alert == severity {
+					v = 1
+				}
+				t.alert.WithLabelValues(tr.Name, ind.name, severity).Set(v)
+			}
+
+			ind.mu.Lock()
+			before := ind.firing
+			ind.firing = s.Alert
+			ind.mu.Unlock()
+			switch {
+			case s.Alert != "" && s.Alert != before:
+				slog.Warn("slo burn rate alert", "objective", tr.Name, "sli", ind.name, "severity", s.Alert,
+					"burn_rates", s.BurnRates, "budget_remaining", s.BudgetRemaining)
+			case s.Alert == "" && before != "":
+				slog.Info("slo burn rate alert resolved", "objective", tr.Name, "sli", ind.name, "severity", before)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/journal"
+	"go-flylike-example/internal/logging"
+)
+
+const txWriterKey = "store.tx_writer"
+
+// Transaction runs every POST, PUT, PATCH and DELETE request in a
+// transaction of the primary, which the repositories join through Writer,
+// Reader and InTx. It is committed when the handler succeeds with a status
+// below 400 and rolled back when it fails, records an error or panics, or
+// the client goes away. The response is held back until the commit, so a
+// client is never told of writes that did not happen; if the commit fails
+// the request fails with 500 instead.
+//
+// It must run inside the error and panic middleware and outside anything
+// that should be recorded whatever the outcome, such as the audit log.
+// Streaming handlers call SkipTransaction before writing.
+func (s *Store) Transaction() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		parent := c.Request.Context()
+		tx, err := s.db.BeginTx(parent, nil)
+		if err != nil {
+			c.Error(apperror.Internal(fmt.Errorf("store: begin: %w", err)))
+			c.Abort()
+			return
+		}
+		// A transaction the process dies in is rolled back by the
+		// database; the journal names the request that lost its writes.
+		done := journal.Track(journal.KindTransaction, logging.RequestIDFrom(parent), map[string]string{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+		})
+		defer done()
+		st := &txState{tx: tx}
+		w := &txWriter{ResponseWriter: c.Writer, state: st, status: http.StatusOK, size: -1}
+		c.Request = c.Request.WithContext(context.WithValue(parent, txKey{}, st))
+		c.Writer = w
+		c.Set(txWriterKey, w)
+		defer func() {
+			// Middleware further out sees the request as it came in.
+			c.Request = c.Request.WithContext(parent)
+			if r := recover(); r != nil {
+				_ = tx.Rollback()
+				c.Writer = w.ResponseWriter
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		c.Writer = w.ResponseWriter
+		if st.skipped {
+			return
+		}
+		if w.status >= http.StatusBadRequest || len(c.Errors) > 0 {
+			_ = tx.Rollback()
+			w.flush()
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.Error(apperror.Internal(fmt.Errorf("store: commit: %w", err)))
+			return
+		}
+		w.flush()
+	}
+}
+
+// SkipTransaction gives up the transaction of the request, for handlers
+// that stream their response and so cannot wait for a commit. Nothing
+// written through the transaction so far is kept; later writes go to the
+// pool directly.
+func SkipTransaction(c *gin.Context) {
+	v, ok := c.Get(txWriterKey)
+	if !ok {
+		return
+	}
+	w := v.(*txWriter)
+	if w.state.skipped {
+		return
+	}
+	w.state.skipped = true
+	_ = w.state.tx.Rollback()
+	w.flush()
+	c.Writer = w.ResponseWriter
+}
+
+// txWriter holds the response back until the transaction is settled.
+type txWriter struct {
+	gin.ResponseWriter
+	state  *txState
+	status int
+	size   int
+	buf    bytes.Buffer
+}
+
+func (w *txWriter) WriteHeader(code int) {
+	if code > 0 && w.size < 0 {
+		w.status = code
+	}
+}
+
+func (w *txWriter) WriteHeaderNow() {
+	if w.size < 0 {
+		w.size = 0
+	}
+}
+
+func (w *txWriter) Write(b []byte) (int, error) {
+	if w.state.skipped {
+		return w.ResponseWriter.Write(b)
+	}
+	w.WriteHeaderNow()
+	n, _ := w.buf.Write(b)
+	w.size += n
+	return n, nil
+}
+
+func (w *txWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *txWriter) Status() int   { return w.status }
+func (w *txWriter) Size() int     { return w.size }
+func (w *txWriter) Written() bool { return w.size >= 0 }
+func (w *txWriter) Flush()        {}
+
+// flush writes the held response. A status set without writing is passed
+// on unwritten, leaving the body to the error middleware as usual.
+func (w *txWriter) flush() {
+	if !w.Written() {
+		w.ResponseWriter.WriteHeader(w.status)
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// sqliteLockLease is how long the migration lock of SQLite is held at most:
+// a leader that crashed without releasing it cannot block the others for
+// longer. Migrations must finish within it.
+const sqliteLockLease = 10 * time.Minute
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate applies every pending embedded migration. Instances sharing the
+// database take turns under a lock, an advisory lock on Postgres: the
+// first applies the migrations while the others wait, until ctx is done,
+// and find nothing left to apply.
+func (s *Store) Migrate(ctx context.Context) error {
+	locker, err := s.locker()
+	if err != nil {
+		return err
+	}
+	provider, err := s.migrator(goose.WithSessionLocker(locker))
+	if err != nil {
+		return err
+	}
+	results, err := provider.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	for _, r := range results {
+		slog.Info("migration applied", "version", r.Source.Version, "file", r.Source.Path, "duration", r.Duration.String())
+	}
+	return nil
+}
+
+// SchemaVersion returns the current database version and the newest version
+// embedded in the binary.
+func (s *Store) SchemaVersion(ctx context.Context) (current, latest int64, err error) {
+	provider, err := s.migrator()
+	if err != nil {
+		return 0, 0, err
+	}
+	if current, err = provider.GetDBVersion(ctx); err != nil {
+		return 0, 0, fmt.Errorf("store: schema version: %w", err)
+	}
+	sources := provider.ListSources()
+	if len(sources) > 0 {
+		latest = sources[len(sources)-1].Version
+	}
+	return current, latest, nil
+}
+
+// WaitForSchema waits, polling every interval until ctx is done, for the
+// database to be at the newest embedded migration, for instances that
+// leave migrating to another. A schema already ahead is accepted, as
+// during a rolling deploy of a newer binary.
+func (s *Store) WaitForSchema(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		current, latest, err := s.SchemaVersion(ctx)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "schema version unknown", "error", err)
+		case current >= latest:
+			if current > latest {
+				slog.WarnContext(ctx, "database schema newer than the binary", "version", current, "expected", latest)
+			}
+			return nil
+		default:
+			slog.InfoContext(ctx, "waiting for migrations", "version", current, "expected", latest)
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return fmt.Errorf("store: wait for schema: %w", ctx.Err())
+		}
+	}
+}
+
+func (s *Store) migrator(opts ...goose.ProviderOption) (*goose.Provider, error) {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	dialect := goose.DialectPostgres
+	if s.dialect == SQLite {
+		dialect = goose.DialectSQLite3
+	}
+	provider, err := goose.NewProvider(dialect, s.db, sub, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("store: migrations: %w", err)
+	}
+	return provider, nil
+}
+
+// locker returns the lock migrations are applied under. The wait for it
+// is bounded by the context of Migrate only.
+func (s *Store) locker() (lock.SessionLocker, error) {
+	if s.dialect == SQLite {
+		return sqliteLocker{lease: sqliteLockLease}, nil
+	}
+	locker, err := lock.NewPostgresSessionLocker(lock.WithLockTimeout(1, math.MaxUint32))
+	if err != nil {
+		return nil, fmt.Errorf("store: migration lock: %w", err)
+	}
+	return locker, nil
+}
+
+// sqliteLocker is the migration lock of SQLite, which has no advisory
+// locks: a row of a table, held until it is deleted or its lease expires.
+type sqliteLocker struct {
+	lease time.Duration
+}
+
+func (l sqliteLocker) SessionLock(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS migration_lock (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		locked_at INTEGER NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("store: migration lock: %w", err)
+	}
+	for {
+		now := time.Now()
+		res, err := conn.ExecContext(ctx, `INSERT INTO migration_lock (id, locked_at) VALUES (1, ?)
+			ON CONFLICT (id) DO UPDATE SET locked_at = excluded.locked_at
+			WHERE migration_lock.locked_at < ?`, now.Unix(), now.Add(-l.lease).Unix())
+		if err != nil {
+			return fmt.Errorf("store: migration lock: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			return nil
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return fmt.Errorf("store: migration lock: %w", ctx.Err())
+		}
+	}
+}
+
+func (l sqliteLocker) SessionUnlock(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, `DELETE FROM migration_lock WHERE id = 1`); err != nil {
+		return fmt.Errorf("store: migration unlock: %w", err)
+	}
+	return nil
+}
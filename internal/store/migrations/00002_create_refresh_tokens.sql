@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE refresh_tokens (
+    token_hash TEXT PRIMARY KEY,
+    subject    TEXT NOT NULL,
+    family     TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    revoked_at BIGINT
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id           TEXT PRIMARY KEY,
+    kind         TEXT NOT NULL,
+    payload      TEXT NOT NULL,
+    state        TEXT NOT NULL DEFAULT 'pending',
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at       BIGINT NOT NULL,
+    locked_until BIGINT,
+    last_error   TEXT,
+    created_at   BIGINT NOT NULL,
+    updated_at   BIGINT NOT NULL
+);
+
+CREATE INDEX jobs_fetch_idx ON jobs (state, run_at);
+
+-- +goose Down
+DROP TABLE jobs;
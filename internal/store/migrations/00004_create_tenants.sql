@@ -0,0 +1,41 @@
+-- +goose Up
+CREATE TABLE tenants (
+    id         TEXT PRIMARY KEY,
+    slug       TEXT NOT NULL UNIQUE,
+    name       TEXT NOT NULL,
+    settings   TEXT NOT NULL DEFAULT '{}',
+    active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Users become tenant-scoped; '' is the default tenant used when tenancy
+-- is disabled. The table is rebuilt because SQLite cannot alter
+-- constraints, and email uniqueness is now per tenant.
+CREATE TABLE users_scoped (
+    id         TEXT PRIMARY KEY,
+    tenant_id  TEXT NOT NULL DEFAULT '',
+    name       TEXT NOT NULL,
+    email      TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, email)
+);
+INSERT INTO users_scoped (id, name, email, created_at, updated_at)
+    SELECT id, name, email, created_at, updated_at FROM users;
+DROP TABLE users;
+ALTER TABLE users_scoped RENAME TO users;
+
+-- +goose Down
+CREATE TABLE users_global (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    email      TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO users_global (id, name, email, created_at, updated_at)
+    SELECT id, name, email, created_at, updated_at FROM users WHERE tenant_id = '';
+DROP TABLE users;
+ALTER TABLE users_global RENAME TO users;
+
+DROP TABLE tenants;
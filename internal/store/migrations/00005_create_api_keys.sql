@@ -0,0 +1,22 @@
+-- +goose Up
+-- Only a SHA-256 hash of each key is stored; prefix is the public part
+-- shown in listings so owners can tell their keys apart. Scopes are
+-- space-separated. A zero rate means the server-wide rate limit applies.
+CREATE TABLE api_keys (
+    id           TEXT PRIMARY KEY,
+    owner        TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    prefix       TEXT NOT NULL,
+    key_hash     TEXT NOT NULL UNIQUE,
+    scopes       TEXT NOT NULL DEFAULT '',
+    rate         DOUBLE PRECISION NOT NULL DEFAULT 0,
+    burst        INTEGER NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at   TIMESTAMP
+);
+
+CREATE INDEX api_keys_owner_idx ON api_keys (owner);
+
+-- +goose Down
+DROP TABLE api_keys;
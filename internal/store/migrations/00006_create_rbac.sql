@@ -0,0 +1,30 @@
+-- +goose Up
+-- Subjects are auth subjects: user names, "apikey:<id>", and so on. A
+-- permission is "resource:action"; "resource:*" and "*" are wildcards.
+CREATE TABLE roles (
+    name        TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role       TEXT NOT NULL,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE user_roles (
+    subject    TEXT NOT NULL,
+    role       TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (subject, role)
+);
+
+CREATE INDEX user_roles_role_idx ON user_roles (role);
+
+INSERT INTO roles (name, description) VALUES ('admin', 'Every permission');
+INSERT INTO role_permissions (role, permission) VALUES ('admin', '*');
+
+-- +goose Down
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE roles;
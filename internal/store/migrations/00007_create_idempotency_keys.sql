@@ -0,0 +1,13 @@
+-- +goose Up
+-- id is a hash of the client's key and the request it was sent with;
+-- record is the JSON-encoded state, including the stored response.
+CREATE TABLE idempotency_keys (
+    id         TEXT PRIMARY KEY,
+    record     TEXT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_idx ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- A lease is held by one instance until expires_at (Unix seconds) unless
+-- it renews it; the scheduler uses the "scheduler" lease to elect the
+-- instance that runs cron tasks.
+CREATE TABLE leases (
+    name       TEXT PRIMARY KEY,
+    holder     TEXT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE leases;
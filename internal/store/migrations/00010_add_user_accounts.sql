@@ -0,0 +1,52 @@
+-- +goose Up
+-- Users get a password and can verify their email address and delete
+-- their account. Deleted users are kept, so their email becomes free for a
+-- new registration: uniqueness moves to a partial index, which needs the
+-- table rebuilt on SQLite. Emails compare case-insensitively.
+CREATE TABLE users_accounts (
+    id                TEXT PRIMARY KEY,
+    tenant_id         TEXT NOT NULL DEFAULT '',
+    name              TEXT NOT NULL,
+    email             TEXT NOT NULL,
+    password_hash     TEXT NOT NULL DEFAULT '',
+    email_verified_at TIMESTAMP,
+    deleted_at        TIMESTAMP,
+    created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+INSERT INTO users_accounts (id, tenant_id, name, email, created_at, updated_at)
+    SELECT id, tenant_id, name, email, created_at, updated_at FROM users;
+DROP TABLE users;
+ALTER TABLE users_accounts RENAME TO users;
+CREATE UNIQUE INDEX users_tenant_email_idx ON users (tenant_id, lower(email)) WHERE deleted_at IS NULL;
+
+-- Email verification and password reset tokens, stored as SHA-256 hashes.
+-- email is the address the token was sent to.
+CREATE TABLE user_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    purpose    TEXT NOT NULL,
+    email      TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    used_at    BIGINT
+);
+
+CREATE INDEX user_tokens_user_idx ON user_tokens (user_id, purpose);
+
+-- +goose Down
+DROP TABLE user_tokens;
+
+CREATE TABLE users_scoped (
+    id         TEXT PRIMARY KEY,
+    tenant_id  TEXT NOT NULL DEFAULT '',
+    name       TEXT NOT NULL,
+    email      TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, email)
+);
+INSERT INTO users_scoped (id, tenant_id, name, email, created_at, updated_at)
+    SELECT id, tenant_id, name, email, created_at, updated_at FROM users WHERE deleted_at IS NULL;
+DROP TABLE users;
+ALTER TABLE users_scoped RENAME TO users;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- One row per mutating API request. The application only ever inserts
+-- into this table; on Postgres, grant its role INSERT and SELECT alone to
+-- make that binding. before_state, after_state and request hold redacted
+-- JSON; actor is the auth subject, empty for anonymous callers.
+CREATE TABLE audit_log (
+    id           TEXT PRIMARY KEY,
+    tenant_id    TEXT NOT NULL DEFAULT '',
+    actor        TEXT NOT NULL,
+    method       TEXT NOT NULL,
+    route        TEXT NOT NULL,
+    path         TEXT NOT NULL,
+    status       INTEGER NOT NULL,
+    request_id   TEXT NOT NULL,
+    client_ip    TEXT NOT NULL,
+    request      TEXT,
+    before_state TEXT,
+    after_state  TEXT,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_tenant_created_idx ON audit_log (tenant_id, created_at);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor, created_at);
+
+-- +goose Down
+DROP TABLE audit_log;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Monthly usage counters for quota enforcement and billing. scope is
+-- "tenant:<id>" or "user:<subject>", period the UTC month as YYYY-MM and
+-- metric one of requests, storage (bytes uploaded) or jobs.
+CREATE TABLE quota_usage (
+    scope      TEXT NOT NULL,
+    period     TEXT NOT NULL,
+    metric     TEXT NOT NULL,
+    amount     BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL,
+    PRIMARY KEY (scope, period, metric)
+);
+
+-- +goose Down
+DROP TABLE quota_usage;
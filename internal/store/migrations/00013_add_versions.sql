@@ -0,0 +1,9 @@
+-- +goose Up
+-- Mutable resources carry a version, raised by every write, which clients
+-- see as the ETag and send back in If-Match.
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE roles ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE roles DROP COLUMN version;
+ALTER TABLE users DROP COLUMN version;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Events written in the transaction of the change they describe, and
+-- published to the bus by the outbox relay afterwards. created_at is in
+-- nanoseconds, to keep the order of events written in the same second.
+CREATE TABLE outbox (
+    id           TEXT PRIMARY KEY,
+    topic        TEXT NOT NULL,
+    type         TEXT NOT NULL,
+    msg_key      TEXT NOT NULL DEFAULT '',
+    tenant_id    TEXT NOT NULL DEFAULT '',
+    headers      TEXT NOT NULL DEFAULT '{}',
+    data         TEXT NOT NULL,
+    created_at   BIGINT NOT NULL,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    locked_until BIGINT,
+    last_error   TEXT,
+    delivered_at BIGINT
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (delivered_at, created_at);
+
+-- +goose Down
+DROP TABLE outbox;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Long-running work started by a request and run on the job queue. input
+-- and result are JSON; error is the message shown to the client.
+CREATE TABLE operations (
+    id         TEXT PRIMARY KEY,
+    kind       TEXT NOT NULL,
+    owner      TEXT NOT NULL,
+    tenant_id  TEXT NOT NULL DEFAULT '',
+    state      TEXT NOT NULL,
+    input      TEXT NOT NULL DEFAULT 'null',
+    result     TEXT,
+    error      TEXT,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
+
+CREATE INDEX operations_owner_idx ON operations (owner, created_at);
+
+-- +goose Down
+DROP TABLE operations;
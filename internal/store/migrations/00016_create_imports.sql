@@ -0,0 +1,19 @@
+-- +goose Up
+-- Files uploaded for import, kept until their import operation is done.
+-- data is the CSV or JSONL text.
+CREATE TABLE import_files (
+    id         TEXT PRIMARY KEY,
+    owner      TEXT NOT NULL,
+    tenant_id  TEXT NOT NULL DEFAULT '',
+    resource   TEXT NOT NULL,
+    format     TEXT NOT NULL,
+    data       TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+-- How far a running operation got, as JSON.
+ALTER TABLE operations ADD COLUMN progress TEXT;
+
+-- +goose Down
+ALTER TABLE operations DROP COLUMN progress;
+DROP TABLE import_files;
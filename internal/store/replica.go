@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lagCheckInterval is how often the replica's health and lag are sampled.
+const lagCheckInterval = time.Second
+
+// postgresLagQuery reports how far a streaming replica is behind, in
+// seconds. A replica that has replayed everything it received reports 0
+// even when the primary has been idle for a while.
+const postgresLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// ReplicaStatus describes the read replica as last sampled.
+type ReplicaStatus struct {
+	Configured bool          `json:"configured"`
+	Healthy    bool          `json:"healthy"`
+	Lag        time.Duration `json:"lag_ns"`
+	MaxLag     time.Duration `json:"max_lag_ns"`
+}
+
+// replica is a read-only pool whose lag is watched in the background.
+type replica struct {
+	db      *sql.DB
+	dialect Dialect
+	maxLag  time.Duration
+
+	usable atomic.Bool
+	lag    atomic.Int64
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+func openReplica(ctx context.Context, db *sql.DB, dialect Dialect, maxLag time.Duration) *replica {
+	r := &replica{db: db, dialect: dialect, maxLag: maxLag, stop: make(chan struct{})}
+	r.check(ctx)
+	r.done.Go(r.watch)
+	return r
+}
+
+func (r *replica) watch() {
+	t := time.NewTicker(lagCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), lagCheckInterval)
+			r.check(ctx)
+			cancel()
+		}
+	}
+}
+
+// check samples the replica and switches reads to or away from it.
+func (r *replica) check(ctx context.Context) {
+	lag, err := r.measure(ctx)
+	ok := err == nil && lag <= r.maxLag
+	r.lag.Store(int64(lag))
+	if was := r.usable.Swap(ok); was != ok {
+		if ok {
+			slog.Info("read replica in use", "lag", lag.String())
+		} else {
+			slog.Warn("read replica unusable, reading from the primary", "lag", lag.String(), "error", err)
+		}
+	}
+}
+
+func (r *replica) measure(ctx context.Context) (time.Duration, error) {
+	if r.dialect != Postgres {
+		// A replicated SQLite file (LiteFS) is local; there is nothing to
+		// measure beyond reachability.
+		return 0, r.db.PingContext(ctx)
+	}
+	var seconds float64
+	if err := r.db.QueryRowContext(ctx, postgresLagQuery).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("store: replica lag: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (r *replica) close() error {
+	close(r.stop)
+	r.done.Wait()
+	return r.db.Close()
+}
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so that Reader returns the primary, for reads that
+// must observe the caller's own recent writes.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Reader returns where reads should go: the transaction ctx carries, so
+// that they see its writes, the replica while it is healthy and within the
+// lag budget, and the primary otherwise or when ctx asks for it.
+func (s *Store) Reader(ctx context.Context) Querier {
+	if st := stateFrom(ctx); st != nil {
+		return st.tx
+	}
+	if s.replica == nil || !s.replica.usable.Load() {
+		return s.db
+	}
+	if forced, _ := ctx.Value(primaryKey{}).(bool); forced {
+		return s.db
+	}
+	return s.replica.db
+}
+
+// Replica returns the state of the read replica.
+func (s *Store) Replica() ReplicaStatus {
+	if s.replica == nil {
+		return ReplicaStatus{}
+	}
+	return ReplicaStatus{
+		Configured: true,
+		Healthy:    s.replica.usable.Load(),
+		Lag:        time.Duration(s.replica.lag.Load()),
+		MaxLag:     s.replica.maxLag,
+	}
+}
@@ -0,0 +1,176 @@
+// Package store owns the database connection pool. Postgres is accessed
+// through pgx's database/sql driver and SQLite through the pure-Go modernc
+// driver, so local development needs no external database.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"go-flylike-example/internal/config"
+)
+
+// Dialect identifies the SQL flavour of the underlying database.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// sqlitePragmas are applied to every SQLite connection: WAL for concurrent
+// readers, a busy timeout instead of immediate SQLITE_BUSY errors, and
+// enforced foreign keys.
+const sqlitePragmas = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+
+// Store wraps the connection pool of the primary and, optionally, of a
+// read replica.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	replica *replica // nil without a replica
+	maxIdle int
+}
+
+// Open connects to the database described by cfg and verifies the
+// connection.
+func Open(ctx context.Context, cfg config.Database) (*Store, error) {
+	dialect, db, err := openPool(cfg.URL, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: ping: %w", err)
+	}
+	s := &Store{db: db, dialect: dialect, maxIdle: cfg.MaxIdleConns}
+
+	if cfg.ReplicaURL != "" {
+		// An unreachable replica is not fatal: reads use the primary until
+		// it becomes healthy.
+		replicaDialect, rdb, err := openPool(cfg.ReplicaURL, cfg)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("store: replica: %w", err)
+		}
+		if replicaDialect != dialect {
+			db.Close()
+			rdb.Close()
+			return nil, fmt.Errorf("store: replica is %s but the primary is %s", replicaDialect, dialect)
+		}
+		s.replica = openReplica(ctx, rdb, dialect, cfg.MaxReplicaLag)
+	}
+	return s, nil
+}
+
+func openPool(rawURL string, cfg config.Database) (Dialect, *sql.DB, error) {
+	dialect, driver, dsn, err := parseURL(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return "", nil, fmt.Errorf("store: open: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return dialect, db, nil
+}
+
+// DB returns the primary pool, which every write must use. Writes on behalf
+// of a request go through Writer instead, to join its transaction.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// CloseIdle closes the idle connections of the pools, which a machine
+// about to be suspended would otherwise resume with after the database
+// dropped them. The pools keep working and open new ones as needed.
+func (s *Store) CloseIdle() {
+	pools := []*sql.DB{s.db}
+	if s.replica != nil {
+		pools = append(pools, s.replica.db)
+	}
+	for _, db := range pools {
+		db.SetMaxIdleConns(0)
+		db.SetMaxIdleConns(s.maxIdle)
+	}
+}
+
+// Dialect returns the SQL flavour of the database.
+func (s *Store) Dialect() Dialect {
+	return s.dialect
+}
+
+// Ping checks that the database is reachable. It matches health.CheckFunc.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the pools.
+func (s *Store) Close() error {
+	if s.replica != nil {
+		_ = s.replica.close()
+	}
+	return s.db.Close()
+}
+
+// Rebind rewrites ? placeholders into the dialect's native form, so queries
+// can be written once for both databases.
+func (s *Store) Rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func parseURL(raw string) (dialect Dialect, driver, dsn string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", "", fmt.Errorf("store: invalid database url: %w", err)
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return Postgres, "pgx", raw, nil
+	case "sqlite", "sqlite3", "file", "":
+		path := strings.TrimPrefix(raw, u.Scheme+"://")
+		path = strings.TrimPrefix(path, "file:")
+		path, query, _ := strings.Cut(path, "?")
+		if path != ":memory:" {
+			if dir := filepath.Dir(path); dir != "." {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					return "", "", "", fmt.Errorf("store: create %s: %w", dir, err)
+				}
+			}
+		}
+		dsn := "file:" + path + "?" + sqlitePragmas
+		if query != "" {
+			dsn += "&" + query
+		}
+		return SQLite, "sqlite", dsn, nil
+	}
+	return "", "", "", fmt.Errorf("store: unsupported database scheme %q", u.Scheme)
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Querier is what the pools and transactions have in common.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// txState is the transaction a context carries, that of a request or of an
+// InTx call. It is not safe for concurrent use.
+type txState struct {
+	tx *sql.Tx
+	// savepoints numbers the savepoints of nested InTx calls.
+	savepoints int
+	// skipped is set once the request gave its transaction up.
+	skipped bool
+}
+
+func stateFrom(ctx context.Context) *txState {
+	st, _ := ctx.Value(txKey{}).(*txState)
+	if st == nil || st.skipped {
+		return nil
+	}
+	return st
+}
+
+// InTransaction reports whether ctx carries a transaction.
+func InTransaction(ctx context.Context) bool {
+	return stateFrom(ctx) != nil
+}
+
+// Writer returns where writes should go: the transaction ctx carries, or
+// the primary pool.
+func (s *Store) Writer(ctx context.Context) Querier {
+	if st := stateFrom(ctx); st != nil {
+		return st.tx
+	}
+	return s.db
+}
+
+// InTx runs fn in a transaction, which fn reaches through Writer and
+// Reader with the context it is given, and commits it unless fn fails.
+// Within the transaction of ctx, fn gets a savepoint instead: its writes
+// are undone if it fails and otherwise committed with the rest.
+func (s *Store) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if st := stateFrom(ctx); st != nil {
+		st.savepoints++
+		name := "sp" + strconv.Itoa(st.savepoints)
+		if _, err := st.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+			return fmt.Errorf("store: savepoint: %w", err)
+		}
+		if err := fn(ctx); err != nil {
+			if _, rerr := st.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rerr != nil {
+				return errors.Join(err, fmt.Errorf("store: rollback to savepoint: %w", rerr))
+			}
+			return err
+		}
+		if _, err := st.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+			return fmt.Errorf("store: release savepoint: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("store: begin: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx})); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package stream writes result sets of any size as they are produced
+// instead of buffering them: as NDJSON, one JSON value per line, or as a
+// JSON array sent in chunks. Output is flushed whenever its buffer fills or
+// a value has waited flushInterval, and every write waits for the client
+// to take the data, so a slow reader slows the producer down rather than
+// growing memory. A client that takes nothing for stallTimeout is dropped.
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"iter"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/logging"
+)
+
+// ContentTypeNDJSON is the media type of newline-delimited JSON.
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// TrailerError is the trailer naming why a stream ended early. The status
+// line is long gone by then, so clients should check it, or for NDJSON
+// that the body ends with a newline, before trusting that they got every
+// value.
+const TrailerError = "X-Stream-Error"
+
+const (
+	bufferSize    = 32 << 10
+	flushInterval = 100 * time.Millisecond
+	stallTimeout  = 30 * time.Second
+)
+
+type format struct {
+	contentType string
+	open, close string
+	// sep goes between values and end after each.
+	sep, end string
+}
+
+var (
+	ndjson    = format{contentType: ContentTypeNDJSON, end: "\n"}
+	jsonArray = format{contentType: "application/json; charset=utf-8", open: "[", close: "]", sep: ","}
+)
+
+// NDJSON writes values as newline-delimited JSON with status 200. An error
+// from values before the first one is rendered like any handler error;
+// later it ends the stream, with TrailerError set.
+func NDJSON[T any](c *gin.Context, values iter.Seq2[T, error]) {
+	write(c, ndjson, values)
+}
+
+// JSONArray writes values as one JSON array with status 200, handling
+// errors like NDJSON.
+func JSONArray[T any](c *gin.Context, values iter.Seq2[T, error]) {
+	write(c, jsonArray, values)
+}
+
+func write[T any](c *gin.Context, f format, values iter.Seq2[T, error]) {
+	ctx := c.Request.Context()
+	w := NewWriter(c)
+	buf := bufio.NewWriterSize(w, bufferSize)
+	flushed := time.Now()
+	flush := func() error {
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		flushed = time.Now()
+		return w.Flush()
+	}
+
+	n := 0
+	for v, err := range values {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			if n == 0 {
+				c.Error(err)
+				return
+			}
+			Fail(c, flush, err)
+			return
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			if n == 0 {
+				c.Error(apperror.Internal(err))
+				return
+			}
+			Fail(c, flush, err)
+			return
+		}
+		if n == 0 {
+			start(c, f)
+			buf.WriteString(f.open)
+		} else {
+			buf.WriteString(f.sep)
+		}
+		n++
+		buf.Write(b)
+		if _, err := buf.WriteString(f.end); err != nil {
+			gone(ctx, err)
+			return
+		}
+		if time.Since(flushed) >= flushInterval {
+			if err := flush(); err != nil {
+				gone(ctx, err)
+				return
+			}
+		}
+	}
+	if n == 0 {
+		start(c, f)
+		buf.WriteString(f.open)
+	}
+	buf.WriteString(f.close)
+	if err := flush(); err != nil {
+		gone(ctx, err)
+	}
+}
+
+// start sends the headers. The stream is bounded by stallTimeout between
+// writes rather than by the server's WriteTimeout.
+func start(c *gin.Context, f format) {
+	h := c.Writer.Header()
+	h.Set("Content-Type", f.contentType)
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Accel-Buffering", "no")
+	h.Set("Trailer", TrailerError)
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+}
+
+// Fail sends what was produced, with flush, and ends the stream with err
+// in the TrailerError trailer, which the response must announce. The
+// error is recorded on c for the access log.
+func Fail(c *gin.Context, flush func() error, err error) {
+	ctx := c.Request.Context()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		gone(ctx, err)
+		return
+	}
+	e := apperror.From(err)
+	logging.FromContext(ctx).Error("stream failed", "error", err.Error(), "status", e.Status())
+	_ = flush()
+	c.Writer.Header().Set(TrailerError, e.Localize(ctx))
+	c.Error(err)
+}
+
+func gone(ctx context.Context, err error) {
+	logging.FromContext(ctx).Debug("stream client gone", "error", err)
+}
+
+// Writer writes a response of any length, giving every write to the
+// client stallTimeout to complete instead of the server's WriteTimeout for
+// the whole response.
+type Writer struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// NewWriter returns a Writer for the response of c.
+func NewWriter(c *gin.Context) *Writer {
+	return &Writer{w: c.Writer, rc: http.NewResponseController(c.Writer)}
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	_ = w.rc.SetWriteDeadline(time.Now().Add(stallTimeout))
+	return w.w.Write(b)
+}
+
+// Flush sends what was written to the client.
+func (w *Writer) Flush() error {
+	_ = w.rc.SetWriteDeadline(time.Now().Add(stallTimeout))
+	return w.rc.Flush()
+}
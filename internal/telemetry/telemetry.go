@@ -0,0 +1,64 @@
+// Package telemetry pushes metrics and logs over OTLP/HTTP, for platforms
+// where nothing can scrape /metrics. It adds no instrumentation of its
+// own: the metrics pushed are the collectors of the Prometheus registry,
+// gathered at every interval through the Prometheus bridge, and the logs
+// are the records of the default logger, request logs included, so the
+// pull and push pipelines carry the same data. The collector is set with
+// the standard OTEL_EXPORTER_OTLP_* variables, as for tracing.
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	prombridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"go-flylike-example/internal/tracing"
+)
+
+// Metrics pushes the metrics of reg every interval until the returned
+// function, which pushes them a last time, is called on exit.
+func Metrics(ctx context.Context, reg prometheus.Gatherer, interval time.Duration) (func(context.Context) error, error) {
+	exporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tracing.Resource()
+	if err != nil {
+		return nil, err
+	}
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(prombridge.NewMetricProducer(prombridge.WithGatherer(reg))),
+	)
+	// Not installed globally: the instrumentation libraries would record
+	// the HTTP metrics a second time, under other names.
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+	return mp.Shutdown, nil
+}
+
+// Logs returns a handler pushing the records it is given in batches, for
+// logging.Setup, and the function flushing them, to be called on exit
+// after the last record.
+func Logs(ctx context.Context) (slog.Handler, func(context.Context) error, error) {
+	exporter, err := otlploghttp.New(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := tracing.Resource()
+	if err != nil {
+		return nil, nil, err
+	}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(res),
+	)
+	return otelslog.NewHandler(tracing.ServiceName(), otelslog.WithLoggerProvider(lp)), lp.Shutdown, nil
+}
@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/config"
+)
+
+// ContextKeyTenant is the gin.Context key holding the resolved *Tenant.
+const ContextKeyTenant = "tenant"
+
+// Middleware resolves the tenant named by the configured header or the
+// request's subdomain and stores it on the request context. Unknown
+// tenants are refused with 404.
+func Middleware(repo *Repository, cfg config.Tenancy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var named string
+		if cfg.Header != "" {
+			named = c.GetHeader(cfg.Header)
+		}
+		t, err := Resolve(c.Request.Context(), repo, cfg, named, c.Request.Host)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if t != nil {
+			c.Set(ContextKeyTenant, t)
+			c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), t))
+		}
+		c.Next()
+	}
+}
+
+// Resolve returns the tenant named by the value of the configured header,
+// or by the subdomain of host, or nil for the default tenant. Unknown
+// tenants are ErrNotFound, and naming none is ErrRequired when cfg
+// requires a tenant.
+func Resolve(ctx context.Context, repo *Repository, cfg config.Tenancy, named, host string) (*Tenant, error) {
+	slug := slugFrom(named, host, "."+strings.TrimPrefix(strings.ToLower(cfg.BaseDomain), "."))
+	if slug == "" {
+		if cfg.Required {
+			return nil, ErrRequired
+		}
+		return nil, nil
+	}
+	return repo.Lookup(ctx, slug)
+}
+
+// slugFrom prefers the explicit header and falls back to the single label
+// in front of base, so "acme.example.com" yields "acme" while the apex
+// and deeper names yield "".
+func slugFrom(named, host, base string) string {
+	if v := strings.TrimSpace(named); v != "" {
+		return strings.ToLower(v)
+	}
+	if base == "." {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	label, ok := strings.CutSuffix(host, base)
+	if !ok || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
@@ -0,0 +1,127 @@
+// Package tenant resolves the tenant a request belongs to and carries it
+// on the request context, where the data layer reads it to scope queries.
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/store"
+)
+
+var (
+	// ErrNotFound is returned for unknown or inactive tenants.
+	ErrNotFound = apperror.NotFound("tenant not found")
+	// ErrRequired is returned when tenancy is required and the request
+	// names no tenant.
+	ErrRequired = apperror.BadRequest("tenant required")
+)
+
+// Tenant is a customer as stored in the tenants table.
+type Tenant struct {
+	ID       string            `json:"id"`
+	Slug     string            `json:"slug"`
+	Name     string            `json:"name"`
+	Settings map[string]string `json:"settings"`
+}
+
+type ctxKey struct{}
+
+// WithTenant stores t on ctx.
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext returns the tenant carried by ctx, if any.
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(ctxKey{}).(*Tenant)
+	return t, ok
+}
+
+// ID returns the ID of the tenant carried by ctx, or "" for the default
+// tenant. Repositories filter on it.
+func ID(ctx context.Context) string {
+	if t, ok := FromContext(ctx); ok {
+		return t.ID
+	}
+	return ""
+}
+
+// cacheTTL bounds how long a resolved tenant is reused before it is read
+// from the database again.
+const cacheTTL = 30 * time.Second
+
+// maxCached caps the cache; it is simply emptied when full.
+const maxCached = 1024
+
+type cached struct {
+	tenant  *Tenant
+	expires time.Time
+}
+
+// Repository looks tenants up by slug, caching results briefly so that
+// resolving the tenant does not cost a query on every request.
+type Repository struct {
+	db *store.Store
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+// NewRepository returns a Repository backed by db.
+func NewRepository(db *store.Store) *Repository {
+	return &Repository{db: db, cache: make(map[string]cached)}
+}
+
+// Lookup returns the active tenant with the given slug.
+func (r *Repository) Lookup(ctx context.Context, slug string) (*Tenant, error) {
+	r.mu.Lock()
+	if c, ok := r.cache[slug]; ok && time.Now().Before(c.expires) {
+		r.mu.Unlock()
+		if c.tenant == nil {
+			return nil, ErrNotFound
+		}
+		return c.tenant, nil
+	}
+	r.mu.Unlock()
+
+	t, err := r.load(ctx, slug)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	// Misses are cached too, so probing random subdomains stays cheap.
+	r.mu.Lock()
+	if len(r.cache) >= maxCached {
+		clear(r.cache)
+	}
+	r.cache[slug] = cached{tenant: t, expires: time.Now().Add(cacheTTL)}
+	r.mu.Unlock()
+	return t, err
+}
+
+func (r *Repository) load(ctx context.Context, slug string) (*Tenant, error) {
+	var (
+		t        Tenant
+		settings string
+	)
+	err := r.db.Reader(ctx).QueryRowContext(ctx, r.db.Rebind(
+		`SELECT id, slug, name, settings FROM tenants WHERE slug = ? AND active`), slug).
+		Scan(&t.ID, &t.Slug, &t.Name, &settings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tenant: lookup: %w", err)
+	}
+	if err := json.Unmarshal([]byte(settings), &t.Settings); err != nil {
+		return nil, fmt.Errorf("tenant: %s settings: %w", slug, err)
+	}
+	return &t, nil
+}
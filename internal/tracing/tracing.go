@@ -0,0 +1,98 @@
+// Package tracing wires OpenTelemetry distributed tracing: an OTLP/HTTP
+// exporter configured through the standard OTEL_* environment variables,
+// Gin server middleware and an instrumented client transport.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// DefaultServiceName is used when OTEL_SERVICE_NAME is not set.
+const DefaultServiceName = "go-flylike-example"
+
+// Enabled reports whether an OTLP endpoint is configured and the exporter
+// has not been switched off with OTEL_TRACES_EXPORTER=none.
+func Enabled() bool {
+	if os.Getenv("OTEL_TRACES_EXPORTER") == "none" || os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// ServiceName returns the service name reported on spans.
+func ServiceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return DefaultServiceName
+}
+
+// Resource describes this service to the collector, on spans and on the
+// metrics and logs of the telemetry package alike.
+func Resource() (*resource.Resource, error) {
+	return resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(ServiceName()),
+	))
+}
+
+// Setup installs the global tracer provider and W3C trace-context/baggage
+// propagators. When tracing is not enabled only the propagators are
+// installed, so incoming trace context is still forwarded downstream.
+// The returned function flushes pending spans and must be called on exit.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := Resource()
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Middleware starts a server span for every request, continuing any trace
+// context found in the incoming headers.
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(ServiceName())
+}
+
+// Transport wraps base so outbound requests create client spans and carry
+// the trace context. A nil base uses http.DefaultTransport.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
+
+// Client returns an http.Client using Transport.
+func Client() *http.Client {
+	return &http.Client{Transport: Transport(nil)}
+}
@@ -0,0 +1,75 @@
+package uploads
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// clamChunk is the size of the chunks streamed to clamd.
+const clamChunk = 64 << 10
+
+// ClamAV scans files with a clamd daemon listening on TCP, streaming them
+// with the INSTREAM command. The daemon's StreamMaxLength must allow the
+// largest upload.
+type ClamAV struct {
+	Addr string // host:port
+}
+
+// Scan implements Scanner. A signature found rejects the file.
+func (s ClamAV) Scan(ctx context.Context, obj *Object, r io.Reader) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("uploads: clamav: %w", err)
+	}
+	defer conn.Close()
+	// Jobs run with a deadline, their lease.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriterSize(conn, clamChunk+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return fmt.Errorf("uploads: clamav: %w", err)
+	}
+	buf := make([]byte, clamChunk)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			_ = binary.Write(w, binary.BigEndian, uint32(n))
+			if _, err := w.Write(buf[:n]); err != nil {
+				return fmt.Errorf("uploads: clamav: %w", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// A zero-length chunk ends the stream.
+	_ = binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("uploads: clamav: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("uploads: clamav: %w", err)
+	}
+	// "stream: OK", "stream: <signature> FOUND" or "<message> ERROR".
+	reply = strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), "\x00")
+	switch {
+	case reply == "OK":
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return fmt.Errorf("%w: %s", ErrRejected, strings.TrimSuffix(reply, " FOUND"))
+	}
+	return fmt.Errorf("uploads: clamav: %s", reply)
+}
@@ -0,0 +1,57 @@
+package uploads
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+	"go-flylike-example/internal/signedurl"
+)
+
+type uploadRequest struct {
+	File openapi.Binary `json:"file" binding:"required"`
+}
+
+// Operations documents the routes of Register.
+func Operations() []openapi.Operation {
+	tags := []string{"uploads"}
+	return []openapi.Operation{
+		{ID: "upload", Method: http.MethodPost, Path: "", Tags: tags, Summary: "Upload a file", Auth: true,
+			Description: "The file is streamed to object storage; the response carries a signed download URL. It is then processed in the background: scanned, given a thumbnail if it is an image, and its state changes from processing to ready. While a scan is pending there is no URL.",
+			Request:     uploadRequest{}, RequestType: "multipart/form-data",
+			Response: openapi.Envelope(uploaded{}), Status: http.StatusCreated,
+			Errors: []int{http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusServiceUnavailable}},
+		{ID: "getUpload", Method: http.MethodGet, Path: "/:id", Tags: tags, Summary: "Get an upload and a fresh download URL", Auth: true,
+			Description: "Images that were processed also have a thumbnail URL.",
+			Response:    openapi.Envelope(uploaded{}), Errors: []int{http.StatusNotFound}},
+		{ID: "deleteUpload", Method: http.MethodDelete, Path: "/:id", Tags: tags, Summary: "Delete an upload", Auth: true,
+			Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+		{ID: "createUploadLink", Method: http.MethodPost, Path: "/links", Tags: tags, Summary: "Create a link to upload files as the caller", Auth: true,
+			Description: "Anybody holding the link can upload files, which belong to and count against the quota of the caller, until it expires.",
+			Request:     linkRequest{}, Response: openapi.Envelope(link{}), Status: http.StatusCreated,
+			Errors: []int{http.StatusBadRequest, http.StatusServiceUnavailable}},
+		{ID: "createDownloadLink", Method: http.MethodPost, Path: "/:id/links", Tags: tags, Summary: "Create a link to download an upload", Auth: true,
+			Description: "Anybody holding the link can download the file until it expires.",
+			Request:     linkRequest{}, Response: openapi.Envelope(link{}), Status: http.StatusCreated,
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusServiceUnavailable}},
+	}
+}
+
+// SharedOperations documents the routes of RegisterShared.
+func SharedOperations() []openapi.Operation {
+	tags := []string{"uploads"}
+	query := []openapi.Param{
+		{Name: "owner", Description: "Set by the link."},
+		{Name: signedurl.ParamExpires, Description: "Set by the link."},
+		{Name: signedurl.ParamKey, Description: "Set by the link."},
+		{Name: signedurl.ParamSignature, Description: "Set by the link."},
+	}
+	return []openapi.Operation{
+		{ID: "uploadShared", Method: http.MethodPost, Path: "", Tags: tags, Summary: "Upload a file through an upload link", Query: query,
+			Request: uploadRequest{}, RequestType: "multipart/form-data",
+			Response: openapi.Envelope(uploaded{}), Status: http.StatusCreated,
+			Errors: []int{http.StatusForbidden, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType}},
+		{ID: "downloadShared", Method: http.MethodGet, Path: "/:id", Tags: tags, Summary: "Download a file through a download link", Query: query,
+			Description: "Redirects to a short-lived URL of the file in object storage.",
+			Status:      http.StatusFound, Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+	}
+}
@@ -0,0 +1,427 @@
+package uploads
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/quota"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/signedurl"
+)
+
+// FileField is the multipart field carrying the file.
+const FileField = "file"
+
+// sniffLen is how much of a file content type detection looks at.
+const sniffLen = 512
+
+// SharedPath is where the endpoints of signed links are mounted.
+const SharedPath = "/files/uploads"
+
+// Overhead is allowed on top of the file size for the multipart framing
+// when the body size is limited.
+const Overhead = 64 << 10
+
+var errTooLarge = apperror.PayloadTooLarge("file too large")
+
+// Handler serves the upload endpoints.
+type Handler struct {
+	storage *Storage
+	cfg     config.Uploads
+	quota   *quota.Enforcer
+	links   *signedurl.KeyRing
+	linkCfg config.SignedURLs
+	process *Processor
+}
+
+// NewHandler returns a Handler storing files in storage.
+func NewHandler(storage *Storage, cfg config.Uploads) *Handler {
+	return &Handler{storage: storage, cfg: cfg}
+}
+
+// ChargeStorage charges the size of every upload to the storage quota of
+// the uploader; a file that does not fit is deleted again.
+func (h *Handler) ChargeStorage(q *quota.Enforcer) {
+	h.quota = q
+}
+
+// ShareLinks lets owners hand out signed links to download their uploads
+// and to upload files on their behalf, signed with ring.
+func (h *Handler) ShareLinks(ring *signedurl.KeyRing, cfg config.SignedURLs) {
+	h.links, h.linkCfg = ring, cfg
+}
+
+// Process has every stored file go through the steps of p. Files stay in
+// StateProcessing until done, and while p scans them they have no download
+// URL.
+func (h *Handler) Process(p *Processor) {
+	h.process = p
+}
+
+// errScanning is returned for downloads of files not yet scanned.
+var errScanning = apperror.Conflict("upload is still being scanned")
+
+type uploaded struct {
+	*Object
+	URL          string     `json:"url,omitempty"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// Register mounts the endpoints on g. A nil Handler answers 503, so the
+// routes exist even without a bucket.
+func (h *Handler) Register(g *gin.RouterGroup) {
+	g.Use(auth.Required())
+	if h == nil {
+		g.POST("", unavailable)
+		g.GET("/:id", unavailable)
+		g.DELETE("/:id", unavailable)
+		g.POST("/links", unavailable)
+		g.POST("/:id/links", unavailable)
+		return
+	}
+	g.POST("", h.handleUpload)
+	g.GET("/:id", h.handleGet)
+	g.DELETE("/:id", h.handleDelete)
+	g.POST("/links", h.handleUploadLink)
+	g.POST("/:id/links", h.handleDownloadLink)
+}
+
+// RegisterShared mounts the endpoints signed links lead to on g, which
+// carries no authentication: the link stands in for it.
+func (h *Handler) RegisterShared(g *gin.RouterGroup) {
+	if h == nil || h.links == nil {
+		g.POST("", unavailable)
+		g.GET("/:id", unavailable)
+		return
+	}
+	g.Use(h.links.Middleware())
+	g.POST("", h.handleSharedUpload)
+	g.GET("/:id", h.handleSharedDownload)
+}
+
+func unavailable(c *gin.Context) {
+	c.Error(apperror.Unavailable("uploads are not configured"))
+}
+
+func owner(c *gin.Context) string {
+	claims, _ := auth.ClaimsFrom(c)
+	return claims.Subject
+}
+
+func (h *Handler) handleUpload(c *gin.Context) {
+	h.receive(c, owner(c))
+}
+
+// receive streams the first FileField part of a multipart body to the
+// bucket as a file of owner. Other fields are skipped.
+func (h *Handler) receive(c *gin.Context, owner string) {
+	// The server's read and write timeouts suit small requests, not files.
+	rc := http.NewResponseController(c.Writer)
+	deadline := time.Now().Add(h.cfg.Timeout)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.Error(apperror.BadRequest("expected a multipart/form-data body"))
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.Error(apperror.Newf(apperror.KindBadRequest, "missing %s field", FileField))
+			return
+		}
+		if err != nil {
+			c.Error(bodyError(err))
+			return
+		}
+		if part.FormName() != FileField || part.FileName() == "" {
+			_ = part.Close()
+			continue
+		}
+		h.store(c, owner, part.FileName(), part)
+		_ = part.Close()
+		return
+	}
+}
+
+func (h *Handler) store(c *gin.Context, owner, filename string, r io.Reader) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.Error(bodyError(err))
+		return
+	}
+	if len(head) == 0 {
+		c.Error(apperror.BadRequest("file is empty"))
+		return
+	}
+	// The declared part type is up to the client; what is in the bytes
+	// decides.
+	contentType := http.DetectContentType(head)
+	if !allowed(h.cfg.AllowedTypes, contentType) {
+		c.Error(apperror.Newf(apperror.KindUnsupportedMediaType, "file type %s not allowed", mediaType(contentType)))
+		return
+	}
+
+	obj := &Object{
+		ID:          id.New(),
+		Name:        cleanName(filename),
+		ContentType: contentType,
+		CreatedAt:   time.Now().UTC(),
+		Owner:       owner,
+	}
+	if h.process != nil {
+		obj.State = StateProcessing
+	}
+	lr := &limitedReader{r: br, n: h.cfg.MaxBytes}
+	if err := h.storage.Put(c.Request.Context(), obj, lr, -1); err != nil {
+		if lr.exceeded {
+			c.Error(errTooLarge)
+			return
+		}
+		c.Error(&apperror.Error{Kind: apperror.KindBadGateway, Message: "storage unavailable", Err: err})
+		return
+	}
+	// The size is only known once the file is stored.
+	if err := h.quota.Charge(c.Request.Context(), quota.Storage, obj.Size); err != nil {
+		if err := h.storage.Delete(context.WithoutCancel(c.Request.Context()), obj.Owner, obj.ID); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("upload over quota not deleted", "id", obj.ID, "error", err)
+		}
+		c.Error(err)
+		return
+	}
+	if h.process != nil {
+		// A file never processed would stay withheld, so it is not kept.
+		if err := h.process.Start(c.Request.Context(), obj); err != nil {
+			if err := h.storage.Delete(context.WithoutCancel(c.Request.Context()), obj.Owner, obj.ID); err != nil {
+				logging.FromContext(c.Request.Context()).Warn("unprocessed upload not deleted", "id", obj.ID, "error", err)
+			}
+			c.Error(apperror.Internal(err))
+			return
+		}
+	}
+	h.respond(c, http.StatusCreated, "file uploaded", obj)
+}
+
+func (h *Handler) handleGet(c *gin.Context) {
+	obj, err := h.storage.Stat(c.Request.Context(), owner(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.respond(c, http.StatusOK, "upload found", obj)
+}
+
+func (h *Handler) handleDelete(c *gin.Context) {
+	if err := h.storage.Delete(c.Request.Context(), owner(c), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// linkRequest asks for a link valid for TTL, the configured default when
+// zero.
+type linkRequest struct {
+	TTL string `json:"ttl,omitempty" example:"24h"`
+}
+
+type link struct {
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleDownloadLink hands out a link to download an upload of the caller,
+// for sharing it with somebody without an account.
+func (h *Handler) handleDownloadLink(c *gin.Context) {
+	obj, err := h.storage.Stat(c.Request.Context(), owner(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if h.withheld(obj) {
+		c.Error(errScanning)
+		return
+	}
+	h.link(c, http.MethodGet, obj.ID, obj.Owner)
+}
+
+// handleUploadLink hands out a link to upload one or more files that
+// belong to the caller, for collecting them from somebody without an
+// account.
+func (h *Handler) handleUploadLink(c *gin.Context) {
+	h.link(c, http.MethodPost, "", owner(c))
+}
+
+func (h *Handler) link(c *gin.Context, method, id, owner string) {
+	if h.links == nil {
+		c.Error(apperror.Unavailable("signed links are not configured"))
+		return
+	}
+	var req linkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperror.BadRequest("invalid link request"))
+			return
+		}
+	}
+	ttl := h.linkCfg.TTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > h.linkCfg.MaxTTL {
+			c.Error(apperror.Newf(apperror.KindBadRequest, "ttl must be a duration up to %s", h.linkCfg.MaxTTL))
+			return
+		}
+		ttl = d
+	}
+	u, err := url.Parse(h.linkCfg.BaseURL)
+	if err != nil {
+		c.Error(apperror.Internal(err))
+		return
+	}
+	u = u.JoinPath(SharedPath, id)
+	u.RawQuery = url.Values{"owner": {owner}}.Encode()
+	expires := time.Now().Add(ttl)
+	c.JSON(http.StatusCreated, render.OK(i18n.T(c, "link created"), link{
+		URL:       h.links.Sign(method, u, expires).String(),
+		Method:    method,
+		ExpiresAt: expires.UTC().Truncate(time.Second),
+	}))
+}
+
+// handleSharedDownload redirects the holder of a download link to the file.
+func (h *Handler) handleSharedDownload(c *gin.Context) {
+	obj, err := h.storage.Stat(c.Request.Context(), c.Query("owner"), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if h.withheld(obj) {
+		c.Error(errScanning)
+		return
+	}
+	u, err := h.storage.SignedURL(c.Request.Context(), obj, h.cfg.URLTTL)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, u)
+}
+
+// handleSharedUpload stores a file sent through an upload link as the
+// owner the link was made by, counting against their storage quota.
+func (h *Handler) handleSharedUpload(c *gin.Context) {
+	owner := c.Query("owner")
+	c.Request = c.Request.WithContext(quota.WithUser(c.Request.Context(), owner))
+	h.receive(c, owner)
+}
+
+func (h *Handler) respond(c *gin.Context, status int, message string, obj *Object) {
+	res := uploaded{Object: obj}
+	if !h.withheld(obj) {
+		u, err := h.storage.SignedURL(c.Request.Context(), obj, h.cfg.URLTTL)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if obj.Thumbnail {
+			if res.ThumbnailURL, err = h.storage.SignedThumbnailURL(c.Request.Context(), obj, h.cfg.URLTTL); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+		expires := time.Now().Add(h.cfg.URLTTL).UTC()
+		res.URL, res.ExpiresAt = u, &expires
+	}
+	c.JSON(status, render.OK(i18n.T(c, message), res))
+}
+
+// withheld reports whether obj may not be downloaded yet.
+func (h *Handler) withheld(obj *Object) bool {
+	return obj.State == StateProcessing && h.process.scans()
+}
+
+// allowed reports whether contentType matches one of patterns.
+func allowed(patterns []string, contentType string) bool {
+	mt := mediaType(contentType)
+	for _, pattern := range patterns {
+		if pattern == "*/*" || pattern == mt {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mt, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func mediaType(contentType string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt
+}
+
+// bodyError maps a failed body read to 413 when the body limit cut it off.
+func bodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return errTooLarge
+	}
+	return apperror.BadRequest("malformed multipart body")
+}
+
+func cleanName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		return "file"
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+// limitedReader fails once more than n bytes were read, so an oversized
+// file aborts the multipart upload instead of being truncated.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		l.exceeded = true
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		l.exceeded = true
+		return n, errTooLarge
+	}
+	return n, err
+}
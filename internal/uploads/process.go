@@ -0,0 +1,231 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/jobs"
+)
+
+// Job kinds of the processing steps, run in this order once a file is
+// stored. Each step is a job of its own, retried on its own, that enqueues
+// the next when it succeeds; steps with nothing to do for a file are
+// skipped.
+const (
+	KindScan      = "upload.scan"
+	KindThumbnail = "upload.thumbnail"
+	KindComplete  = "upload.complete"
+)
+
+// Topic and event types of the upload events.
+const (
+	Topic          = "uploads"
+	EventCompleted = "upload.completed"
+	EventRejected  = "upload.rejected"
+)
+
+// ErrRejected is wrapped by scanners refusing a file, such as
+// fmt.Errorf("%w: %s", ErrRejected, signature); other errors are retried.
+var ErrRejected = errors.New("uploads: rejected")
+
+// Scanner inspects the content of stored files before they are released.
+// It may set obj.ContentType to a more precise type than the sniffed one,
+// which is then checked against the allowed types again.
+type Scanner interface {
+	Scan(ctx context.Context, obj *Object, r io.Reader) error
+}
+
+// Publisher publishes domain events, such as the outbox.
+type Publisher interface {
+	Publish(ctx context.Context, topic, event, key string, data any) error
+}
+
+// Event is the data of the upload events.
+type Event struct {
+	ID          string `json:"id"`
+	Owner       string `json:"owner"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Thumbnail   bool   `json:"thumbnail"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// step is the payload of the processing jobs.
+type step struct {
+	ID        string `json:"id"`
+	Owner     string `json:"owner"`
+	Thumbnail bool   `json:"thumbnail,omitempty"`
+}
+
+// Processor runs the processing steps of stored files.
+type Processor struct {
+	storage  *Storage
+	cfg      config.Uploads
+	queue    jobs.Enqueuer
+	events   Publisher
+	scanners []Scanner
+}
+
+// NewProcessor returns a Processor of the files in storage, enqueuing its
+// steps on queue and publishing their outcome to events. Register Scan,
+// Thumbnail and Complete as the handlers of their kinds.
+func NewProcessor(storage *Storage, cfg config.Uploads, queue jobs.Enqueuer, events Publisher) *Processor {
+	return &Processor{storage: storage, cfg: cfg, queue: queue, events: events}
+}
+
+// AddScanner has every file scanned by s, after the scanners added before.
+// Files are withheld from download until they are scanned.
+func (p *Processor) AddScanner(s Scanner) {
+	p.scanners = append(p.scanners, s)
+}
+
+// Start enqueues the first step for obj, just stored.
+func (p *Processor) Start(ctx context.Context, obj *Object) error {
+	return p.enqueueAfter(ctx, "", obj)
+}
+
+// scans reports whether files are withheld until scanned.
+func (p *Processor) scans() bool {
+	return p != nil && len(p.scanners) > 0
+}
+
+// Scan is the jobs.Handler for KindScan.
+func (p *Processor) Scan(ctx context.Context, job *jobs.Job) error {
+	obj, err := p.load(ctx, job)
+	if obj == nil {
+		return err
+	}
+	sniffed := obj.ContentType
+	for _, s := range p.scanners {
+		if err := p.scanWith(ctx, s, obj); errors.Is(err, ErrRejected) {
+			return p.reject(ctx, obj, strings.TrimPrefix(err.Error(), ErrRejected.Error()+": "))
+		} else if err != nil {
+			return err
+		}
+	}
+	if obj.ContentType != sniffed {
+		if !allowed(p.cfg.AllowedTypes, obj.ContentType) {
+			return p.reject(ctx, obj, fmt.Sprintf("file type %s not allowed", mediaType(obj.ContentType)))
+		}
+		if err := p.storage.Update(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return p.enqueueAfter(ctx, KindScan, obj)
+}
+
+func (p *Processor) scanWith(ctx context.Context, s Scanner, obj *Object) error {
+	r, err := p.storage.Open(ctx, obj)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return s.Scan(ctx, obj, r)
+}
+
+// Thumbnail is the jobs.Handler for KindThumbnail. Images that cannot be
+// decoded are left without a thumbnail.
+func (p *Processor) Thumbnail(ctx context.Context, job *jobs.Job) error {
+	obj, err := p.load(ctx, job)
+	if obj == nil {
+		return err
+	}
+	jpeg, err := p.thumbnail(ctx, obj)
+	switch {
+	case errors.Is(err, errUndecodable):
+		slog.InfoContext(ctx, "upload thumbnail skipped", "id", obj.ID, "error", err)
+	case err != nil:
+		return err
+	default:
+		if err := p.storage.PutThumbnail(ctx, obj, jpeg); err != nil {
+			return err
+		}
+		obj.Thumbnail = true
+	}
+	return p.enqueueAfter(ctx, KindThumbnail, obj)
+}
+
+// Complete is the jobs.Handler for KindComplete. It marks the file ready
+// and publishes EventCompleted.
+func (p *Processor) Complete(ctx context.Context, job *jobs.Job) error {
+	obj, err := p.load(ctx, job)
+	if obj == nil {
+		return err
+	}
+	obj.State = StateReady
+	if err := p.storage.Update(ctx, obj); err != nil {
+		return err
+	}
+	return p.events.Publish(ctx, Topic, EventCompleted, obj.ID, event(obj, ""))
+}
+
+// load returns the file of job, or nil without an error when it was
+// deleted in the meantime: there is nothing left to do.
+func (p *Processor) load(ctx context.Context, job *jobs.Job) (*Object, error) {
+	var s step
+	if err := job.Decode(&s); err != nil {
+		return nil, fmt.Errorf("uploads: decode %s: %w", job.Kind, err)
+	}
+	obj, err := p.storage.Stat(ctx, s.Owner, s.ID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	obj.Thumbnail = obj.Thumbnail || s.Thumbnail
+	return obj, nil
+}
+
+// reject publishes EventRejected and deletes obj. The event goes first, so
+// that a failed delete, retried, can only publish it twice, never lose it.
+func (p *Processor) reject(ctx context.Context, obj *Object, reason string) error {
+	slog.WarnContext(ctx, "upload rejected", "id", obj.ID, "reason", reason)
+	if err := p.events.Publish(ctx, Topic, EventRejected, obj.ID, event(obj, reason)); err != nil {
+		return err
+	}
+	return p.storage.remove(ctx, obj.ID)
+}
+
+// steps returns the kinds of the steps obj goes through.
+func (p *Processor) steps(obj *Object) []string {
+	var kinds []string
+	if len(p.scanners) > 0 {
+		kinds = append(kinds, KindScan)
+	}
+	if p.cfg.ThumbnailSize > 0 && thumbnailable(obj.ContentType) {
+		kinds = append(kinds, KindThumbnail)
+	}
+	return append(kinds, KindComplete)
+}
+
+// enqueueAfter enqueues the step of obj following the step of kind, the
+// first with an empty kind.
+func (p *Processor) enqueueAfter(ctx context.Context, kind string, obj *Object) error {
+	steps := p.steps(obj)
+	next := steps[slices.Index(steps, kind)+1]
+	payload := step{ID: obj.ID, Owner: obj.Owner, Thumbnail: obj.Thumbnail}
+	if _, err := p.queue.Enqueue(ctx, next, payload, jobs.WithMaxAttempts(p.cfg.ProcessAttempts)); err != nil {
+		return fmt.Errorf("uploads: enqueue %s: %w", next, err)
+	}
+	return nil
+}
+
+func event(obj *Object, reason string) Event {
+	return Event{
+		ID:          obj.ID,
+		Owner:       obj.Owner,
+		Name:        obj.Name,
+		ContentType: obj.ContentType,
+		Size:        obj.Size,
+		Thumbnail:   obj.Thumbnail,
+		Reason:      reason,
+	}
+}
@@ -0,0 +1,250 @@
+// Package uploads accepts file uploads and stores them in S3-compatible
+// object storage. Multipart bodies are streamed to the bucket part by part,
+// so a file is never held in memory whole, and downloads are served from
+// short-lived signed URLs instead of through the server.
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/config"
+)
+
+// ErrNotFound is returned for unknown uploads and for uploads of another
+// owner, which are not revealed.
+var ErrNotFound = apperror.NotFound("upload not found")
+
+// partSize is the chunk buffered per multipart part; 5 MiB is the smallest
+// part S3 accepts.
+const partSize = 5 << 20
+
+// The metadata of uploads is stored as x-amz-meta-* headers.
+const (
+	metaOwner     = "Owner"
+	metaName      = "Name"
+	metaState     = "State"
+	metaThumbnail = "Thumbnail"
+	metaCreated   = "Created"
+)
+
+// Upload states. Files stored before processing existed have no state and
+// are ready.
+const (
+	StateProcessing = "processing"
+	StateReady      = "ready"
+)
+
+// Object describes a stored upload.
+type Object struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	State       string    `json:"state"`
+	CreatedAt   time.Time `json:"created_at"`
+	Owner       string    `json:"-"`
+	Thumbnail   bool      `json:"-"`
+}
+
+// Storage reads and writes uploads in one bucket.
+type Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewStorage connects to the bucket of cfg. The endpoint is a URL; plain
+// http is only meant for a local MinIO.
+func NewStorage(cfg config.Uploads) (*Storage, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("uploads: invalid endpoint %q", cfg.Endpoint)
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: u.Scheme != "http",
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("uploads: %w", err)
+	}
+	return &Storage{client: client, bucket: cfg.Bucket}, nil
+}
+
+// Put streams r into the bucket under id. size is -1 when unknown.
+func (s *Storage) Put(ctx context.Context, obj *Object, r io.Reader, size int64) error {
+	info, err := s.client.PutObject(ctx, s.bucket, objectKey(obj.ID), r, size, minio.PutObjectOptions{
+		ContentType:        obj.ContentType,
+		ContentDisposition: contentDisposition(obj.Name),
+		UserMetadata:       metadata(obj),
+		PartSize:           partSize,
+	})
+	if err != nil {
+		return fmt.Errorf("uploads: put %s: %w", obj.ID, err)
+	}
+	obj.Size = info.Size
+	return nil
+}
+
+// Stat returns the upload id if it belongs to owner.
+func (s *Storage) Stat(ctx context.Context, owner, id string) (*Object, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, objectKey(id), minio.StatObjectOptions{})
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("uploads: stat %s: %w", id, err)
+	}
+	if info.Metadata.Get("X-Amz-Meta-"+metaOwner) != owner {
+		return nil, ErrNotFound
+	}
+	name, _ := url.QueryUnescape(info.Metadata.Get("X-Amz-Meta-" + metaName))
+	state := info.Metadata.Get("X-Amz-Meta-" + metaState)
+	if state == "" {
+		state = StateReady
+	}
+	// Update copies the object, which resets LastModified, so the time of
+	// the upload is kept in the metadata.
+	created := info.LastModified
+	if t, err := time.Parse(time.RFC3339Nano, info.Metadata.Get("X-Amz-Meta-"+metaCreated)); err == nil {
+		created = t
+	}
+	return &Object{
+		ID:          id,
+		Name:        name,
+		ContentType: info.ContentType,
+		Size:        info.Size,
+		State:       state,
+		CreatedAt:   created,
+		Owner:       owner,
+		Thumbnail:   info.Metadata.Get("X-Amz-Meta-"+metaThumbnail) != "",
+	}, nil
+}
+
+// Open returns the content of obj.
+func (s *Storage) Open(ctx context.Context, obj *Object) (io.ReadSeekCloser, error) {
+	r, err := s.client.GetObject(ctx, s.bucket, objectKey(obj.ID), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("uploads: get %s: %w", obj.ID, err)
+	}
+	return r, nil
+}
+
+// Update replaces the content type, state and thumbnail flag stored for
+// obj. S3 cannot change metadata in place, so the object is copied onto
+// itself, within the store.
+func (s *Storage) Update(ctx context.Context, obj *Object) error {
+	_, err := s.client.CopyObject(ctx, minio.CopyDestOptions{
+		Bucket:             s.bucket,
+		Object:             objectKey(obj.ID),
+		UserMetadata:       metadata(obj),
+		ReplaceMetadata:    true,
+		ContentType:        obj.ContentType,
+		ContentDisposition: contentDisposition(obj.Name),
+	}, minio.CopySrcOptions{Bucket: s.bucket, Object: objectKey(obj.ID)})
+	if err != nil {
+		return fmt.Errorf("uploads: update %s: %w", obj.ID, err)
+	}
+	return nil
+}
+
+// PutThumbnail stores the JPEG thumbnail of obj.
+func (s *Storage) PutThumbnail(ctx context.Context, obj *Object, jpeg []byte) error {
+	_, err := s.client.PutObject(ctx, s.bucket, thumbnailKey(obj.ID), bytes.NewReader(jpeg), int64(len(jpeg)), minio.PutObjectOptions{
+		ContentType: "image/jpeg",
+	})
+	if err != nil {
+		return fmt.Errorf("uploads: put thumbnail %s: %w", obj.ID, err)
+	}
+	return nil
+}
+
+// Delete removes the upload id of owner, with its thumbnail.
+func (s *Storage) Delete(ctx context.Context, owner, id string) error {
+	if _, err := s.Stat(ctx, owner, id); err != nil {
+		return err
+	}
+	return s.remove(ctx, id)
+}
+
+func (s *Storage) remove(ctx context.Context, id string) error {
+	// Removing a missing key succeeds, so there need not be a thumbnail.
+	for _, key := range []string{thumbnailKey(id), objectKey(id)} {
+		if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("uploads: delete %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// SignedURL returns a download URL for obj valid for ttl.
+func (s *Storage) SignedURL(ctx context.Context, obj *Object, ttl time.Duration) (string, error) {
+	return s.sign(ctx, objectKey(obj.ID), ttl)
+}
+
+// SignedThumbnailURL returns a URL of the thumbnail of obj valid for ttl.
+func (s *Storage) SignedThumbnailURL(ctx context.Context, obj *Object, ttl time.Duration) (string, error) {
+	return s.sign(ctx, thumbnailKey(obj.ID), ttl)
+}
+
+func (s *Storage) sign(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, nil)
+	if err != nil {
+		return "", fmt.Errorf("uploads: sign %s: %w", key, err)
+	}
+	return u.String(), nil
+}
+
+func objectKey(id string) string {
+	return "uploads/" + id
+}
+
+func thumbnailKey(id string) string {
+	return "thumbnails/" + id + ".jpg"
+}
+
+// metadata returns the x-amz-meta-* headers of obj.
+func metadata(obj *Object) map[string]string {
+	meta := map[string]string{
+		metaOwner:   obj.Owner,
+		metaName:    url.QueryEscape(obj.Name),
+		metaCreated: obj.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if obj.State != "" {
+		meta[metaState] = obj.State
+	}
+	if obj.Thumbnail {
+		meta[metaThumbnail] = "1"
+	}
+	return meta
+}
+
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	resp := minio.ToErrorResponse(err)
+	return resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound
+}
+
+// contentDisposition makes browsers download the file under its original
+// name rather than render it on the storage origin.
+func contentDisposition(name string) string {
+	ascii := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, ascii, url.PathEscape(name))
+}
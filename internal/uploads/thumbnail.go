@@ -0,0 +1,106 @@
+package uploads
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoders of image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels bounds the images given a thumbnail, which are decoded whole:
+// a small file can declare a huge image.
+const maxPixels = 50_000_000
+
+// thumbnailQuality is the JPEG quality of thumbnails.
+const thumbnailQuality = 80
+
+var errUndecodable = errors.New("uploads: image cannot be decoded")
+
+// thumbnailable reports whether files of contentType get a thumbnail.
+func thumbnailable(contentType string) bool {
+	switch mediaType(contentType) {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// thumbnail returns obj scaled down to fit ThumbnailSize, as JPEG. Images
+// already that small keep their size.
+func (p *Processor) thumbnail(ctx context.Context, obj *Object) ([]byte, error) {
+	rc, err := p.storage.Open(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	r := &source{ReadSeeker: rc}
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, r.failure(err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", errUndecodable, cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, r.failure(err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(src, p.cfg.ThumbnailSize), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// source records the errors of reading an image, which decoders return
+// like those of its content.
+type source struct {
+	io.ReadSeeker
+	err error
+}
+
+func (s *source) Read(p []byte) (int, error) {
+	n, err := s.ReadSeeker.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// failure tells a failed read, which is retried, from a broken image,
+// which retrying does not mend, as the cause of the decoding error err.
+func (s *source) failure(err error) error {
+	if s.err != nil {
+		return s.err
+	}
+	return fmt.Errorf("%w: %v", errUndecodable, err)
+}
+
+// scale returns src fitted into size by size pixels, on white: JPEG has no
+// transparency.
+func scale(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	switch {
+	case w <= size && h <= size:
+	case w >= h:
+		w, h = size, max(h*size/w, 1)
+	default:
+		w, h = max(w*size/h, 1), size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
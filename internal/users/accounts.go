@@ -0,0 +1,453 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/audit"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/precondition"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+)
+
+// SubjectPrefix starts the token subject of users logged in with a
+// password; the user id follows.
+const SubjectPrefix = "user:"
+
+// Token purposes, as stored in user_tokens.
+const (
+	purposeVerify = "verify"
+	purposeReset  = "reset"
+)
+
+var (
+	// ErrRegistrationClosed is returned by SignUp when registration is off.
+	ErrRegistrationClosed = apperror.Forbidden("registration is closed")
+	// ErrInvalidToken is returned for unknown, used or expired
+	// verification and reset tokens.
+	ErrInvalidToken = apperror.BadRequest("invalid or expired token")
+	// ErrWrongPassword is returned when the current password given to
+	// ChangePassword does not match.
+	ErrWrongPassword = apperror.Forbidden("current password is incorrect")
+	// ErrEmailNotVerified is returned by a login with the right password
+	// while verification is required and still pending.
+	ErrEmailNotVerified = apperror.Forbidden("email address not verified")
+)
+
+// Mailer sends templated email; *mailer.Mailer implements it.
+type Mailer interface {
+	SendTemplate(ctx context.Context, to, name string, data any) error
+}
+
+// Sessions revokes the refresh tokens of a subject; *auth.Service
+// implements it.
+type Sessions interface {
+	RevokeSubject(ctx context.Context, subject string) error
+}
+
+// Accounts implements the self-service account flows on top of the
+// repository. Account owners are addressed by the user id their access
+// token carries, so unlike Repository these queries are not scoped to a
+// tenant; only finding an account by email is.
+type Accounts struct {
+	users    *Repository
+	db       *store.Store
+	live     *config.Live
+	mail     Mailer
+	sessions Sessions
+}
+
+// NewAccounts returns Accounts for the users of repo, sending email through
+// mail and ending sessions through sessions when a password is reset or an
+// account deleted.
+func NewAccounts(repo *Repository, live *config.Live, mail Mailer, sessions Sessions) *Accounts {
+	return &Accounts{users: repo, db: repo.db, live: live, mail: mail, sessions: sessions}
+}
+
+// Subject returns the token subject of the user with the given id.
+func Subject(id string) string {
+	return SubjectPrefix + id
+}
+
+// SignUp creates a user with a password and emails a link that verifies
+// its address.
+func (a *Accounts) SignUp(ctx context.Context, name, email, password string) (*User, error) {
+	if !a.live.Load().Accounts.Registration {
+		return nil, ErrRegistrationClosed
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	u, err := a.users.create(ctx, name, email, hash)
+	if err != nil {
+		return nil, err
+	}
+	// The account exists now; a failed email can be resent.
+	if err := a.SendVerification(context.WithoutCancel(ctx), u); err != nil {
+		logging.FromContext(ctx).Error("verification email not sent", "user", u.ID, "error", err)
+	}
+	return u, nil
+}
+
+// Get returns the user with the given id, whichever tenant it is in.
+func (a *Accounts) Get(ctx context.Context, id string) (*User, error) {
+	var u User
+	err := scan(a.db.Reader(ctx).QueryRowContext(ctx, a.db.Rebind(
+		`SELECT `+columns+` FROM users WHERE id = ? AND deleted_at IS NULL`), id), &u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("users: get: %w", err)
+	}
+	return &u, nil
+}
+
+// SendVerification emails u a link that verifies its current address.
+func (a *Accounts) SendVerification(ctx context.Context, u *User) error {
+	cfg := a.live.Load().Accounts
+	token, err := a.issue(ctx, u, purposeVerify, cfg.VerifyTTL)
+	if err != nil {
+		return err
+	}
+	return a.mail.SendTemplate(ctx, u.Email, "verify_email", map[string]any{
+		"Name":      u.Name,
+		"URL":       link(cfg.LinkBaseURL, "/verify-email", token),
+		"ExpiresIn": humanize(cfg.VerifyTTL),
+	})
+}
+
+// VerifyEmail marks the address a verification token was sent to as
+// verified, unless the user has changed it since.
+func (a *Accounts) VerifyEmail(ctx context.Context, token string) error {
+	id, email, err := a.consume(ctx, token, purposeVerify)
+	if err != nil {
+		return err
+	}
+	if err := a.markVerified(ctx, id, email); err != nil {
+		return err
+	}
+	a.users.changed(context.WithoutCancel(ctx))
+	return nil
+}
+
+func (a *Accounts) markVerified(ctx context.Context, id, email string) error {
+	now := time.Now().UTC()
+	res, err := a.db.Writer(ctx).ExecContext(ctx, a.db.Rebind(
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?), updated_at = ?, version = version + 1
+		 WHERE id = ? AND email = ? AND deleted_at IS NULL`),
+		now, now, id, email)
+	if err != nil {
+		return fmt.Errorf("users: verify email: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// RequestPasswordReset emails a reset link to the user registered with
+// email in the current tenant. It succeeds whether or not there is one, so
+// that callers cannot probe which addresses are registered.
+func (a *Accounts) RequestPasswordReset(ctx context.Context, email string) error {
+	var u User
+	err := scan(a.db.Reader(ctx).QueryRowContext(ctx, a.db.Rebind(
+		`SELECT `+columns+` FROM users WHERE tenant_id = ? AND lower(email) = ? AND deleted_at IS NULL`),
+		tenant.ID(ctx), NormalizeEmail(email)), &u)
+	if errors.Is(err, sql.ErrNoRows) {
+		logging.FromContext(ctx).Info("password reset requested for an unknown email")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("users: find by email: %w", err)
+	}
+
+	cfg := a.live.Load().Accounts
+	token, err := a.issue(ctx, &u, purposeReset, cfg.ResetTTL)
+	if err != nil {
+		return err
+	}
+	return a.mail.SendTemplate(ctx, u.Email, "password_reset", map[string]any{
+		"Name":      u.Name,
+		"URL":       link(cfg.LinkBaseURL, "/reset-password", token),
+		"ExpiresIn": humanize(cfg.ResetTTL),
+	})
+}
+
+// ResetPassword sets a new password with a reset token and logs the user
+// out everywhere. Receiving the token proves the address, so it counts as
+// verified too.
+func (a *Accounts) ResetPassword(ctx context.Context, token, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	id, email, err := a.consume(ctx, token, purposeReset)
+	if err != nil {
+		return err
+	}
+	if err := a.setPassword(ctx, id, hash); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := a.markVerified(ctx, id, email); err != nil && !errors.Is(err, ErrInvalidToken) {
+		return err
+	}
+	// Any other reset link sent before is void now.
+	if err := a.expireTokens(ctx, id, purposeReset); err != nil {
+		return err
+	}
+	a.users.changed(ctx)
+	return a.revokeSessions(ctx, id)
+}
+
+// ChangePassword replaces the password of the user with the given id after
+// checking the current one. Other sessions stay logged in.
+func (a *Accounts) ChangePassword(ctx context.Context, id, current, next string) error {
+	var stored string
+	err := a.db.Reader(ctx).QueryRowContext(ctx, a.db.Rebind(
+		`SELECT password_hash FROM users WHERE id = ? AND deleted_at IS NULL`), id).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("users: load password: %w", err)
+	}
+	ok, err := CheckPassword(stored, current)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWrongPassword
+	}
+	hash, err := HashPassword(next)
+	if err != nil {
+		return err
+	}
+	return a.setPassword(ctx, id, hash)
+}
+
+func (a *Accounts) setPassword(ctx context.Context, id, hash string) error {
+	res, err := a.db.Writer(ctx).ExecContext(ctx, a.db.Rebind(
+		`UPDATE users SET password_hash = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL`),
+		hash, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("users: set password: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Update changes the name and, if given, the email of the user with the
+// given id; nil leaves a field alone. A new email must be verified again,
+// and a verification link is sent to it. The user must pass chk, and is
+// only updated if nobody changed it since it was read.
+func (a *Accounts) Update(ctx context.Context, id string, name, email *string, chk precondition.Check) (*User, error) {
+	u, err := a.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := chk.Verify(u.Version); err != nil {
+		return nil, err
+	}
+	audit.Before(ctx, *u)
+	emailChanged := email != nil && NormalizeEmail(*email) != u.Email
+	if name != nil {
+		u.Name = *name
+	}
+	if emailChanged {
+		u.Email = NormalizeEmail(*email)
+		u.EmailVerifiedAt = nil
+	}
+	u.UpdatedAt = time.Now().UTC()
+
+	res, err := a.db.Writer(ctx).ExecContext(ctx, a.db.Rebind(
+		`UPDATE users SET name = ?, email = ?, email_verified_at = ?, updated_at = ?, version = version + 1
+		 WHERE id = ? AND version = ? AND deleted_at IS NULL`),
+		u.Name, u.Email, u.EmailVerifiedAt, u.UpdatedAt, id, u.Version)
+	if store.IsUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("users: update: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, precondition.ErrFailed
+	}
+	u.Version++
+	ctx = context.WithoutCancel(ctx)
+	a.users.changed(ctx)
+	if emailChanged {
+		if err := a.SendVerification(ctx, u); err != nil {
+			logging.FromContext(ctx).Error("verification email not sent", "user", u.ID, "error", err)
+		}
+	}
+	return u, nil
+}
+
+// Delete soft-deletes the user with the given id and logs it out
+// everywhere.
+func (a *Accounts) Delete(ctx context.Context, id string) error {
+	if audit.Recording(ctx) {
+		if u, err := a.Get(ctx, id); err == nil {
+			audit.Before(ctx, u)
+		}
+	}
+	now := time.Now().UTC()
+	res, err := a.db.Writer(ctx).ExecContext(ctx, a.db.Rebind(
+		`UPDATE users SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL`), now, now, id)
+	if err != nil {
+		return fmt.Errorf("users: delete: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	ctx = context.WithoutCancel(ctx)
+	a.users.changed(ctx)
+	return a.revokeSessions(ctx, id)
+}
+
+func (a *Accounts) revokeSessions(ctx context.Context, id string) error {
+	return a.sessions.RevokeSubject(ctx, Subject(id))
+}
+
+// Credentials logs users in by email and password, within the tenant of the
+// request. Their subject is Subject of the user id.
+type Credentials struct {
+	db   *store.Store
+	live *config.Live
+}
+
+// NewCredentials returns Credentials checking the users of repo.
+func NewCredentials(repo *Repository, live *config.Live) *Credentials {
+	return &Credentials{db: repo.db, live: live}
+}
+
+// Verify implements auth.Credentials.
+func (c *Credentials) Verify(ctx context.Context, email, password string) (string, error) {
+	var (
+		id, hash string
+		verified sql.NullTime
+	)
+	err := c.db.Reader(ctx).QueryRowContext(ctx, c.db.Rebind(
+		`SELECT id, password_hash, email_verified_at FROM users
+		 WHERE tenant_id = ? AND lower(email) = ? AND deleted_at IS NULL`),
+		tenant.ID(ctx), NormalizeEmail(email)).Scan(&id, &hash, &verified)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, _ = CheckPassword(dummyHash, password)
+		return "", auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", fmt.Errorf("users: find by email: %w", err)
+	}
+	ok, err := CheckPassword(hash, password)
+	if err != nil {
+		slog.ErrorContext(ctx, "stored password hash unreadable", "user", id, "error", err)
+		return "", auth.ErrInvalidCredentials
+	}
+	if !ok {
+		return "", auth.ErrInvalidCredentials
+	}
+	if !verified.Valid && c.live.Load().Accounts.RequireVerified {
+		return "", ErrEmailNotVerified
+	}
+	return Subject(id), nil
+}
+
+// issue stores a new single-use token for u and returns it.
+func (a *Accounts) issue(ctx context.Context, u *User, purpose string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("users: generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	now := time.Now()
+	_, err := a.db.Writer(ctx).ExecContext(ctx, a.db.Rebind(
+		`INSERT INTO user_tokens (token_hash, user_id, purpose, email, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`),
+		hashToken(token), u.ID, purpose, u.Email, now.Unix(), now.Add(ttl).Unix())
+	if err != nil {
+		return "", fmt.Errorf("users: store token: %w", err)
+	}
+	return token, nil
+}
+
+// consume uses up a token issued for purpose and returns the user and
+// email it was issued for.
+func (a *Accounts) consume(ctx context.Context, token, purpose string) (id, email string, err error) {
+	err = a.db.InTx(ctx, func(ctx context.Context) error {
+		tx := a.db.Writer(ctx)
+		now := time.Now().Unix()
+		err := tx.QueryRowContext(ctx, a.db.Rebind(
+			`SELECT user_id, email FROM user_tokens
+			 WHERE token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`),
+			hashToken(token), purpose, now).Scan(&id, &email)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return fmt.Errorf("users: load token: %w", err)
+		}
+		res, err := tx.ExecContext(ctx, a.db.Rebind(
+			`UPDATE user_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL`), now, hashToken(token))
+		if err != nil {
+			return fmt.Errorf("users: use token: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			return ErrInvalidToken
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return id, email, nil
+}
+
+func (a *Accounts) expireTokens(ctx context.Context, id, purpose string) error {
+	_, err := a.db.Writer(ctx).ExecContext(ctx, a.db.Rebind(
+		`UPDATE user_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`),
+		time.Now().Unix(), id, purpose)
+	if err != nil {
+		return fmt.Errorf("users: expire tokens: %w", err)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// link returns base+path with the token as its query.
+func link(base, path, token string) string {
+	return strings.TrimSuffix(base, "/") + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// humanize spells d out for an email, "48 hours" or "30 minutes".
+func humanize(d time.Duration) string {
+	unit, n := "minute", int64(d/time.Minute)
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, n = "hour", int64(d/time.Hour)
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
@@ -0,0 +1,39 @@
+package users
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the routes of Accounts.Register.
+func Operations() []openapi.Operation {
+	tags := []string{"account"}
+	return []openapi.Operation{
+		{ID: "registerAccount", Method: http.MethodPost, Path: "/register", Tags: tags, Summary: "Register an account",
+			Description: "Emails a link to verify the address; log in at /auth/login with the email and password.",
+			Request:     registerRequest{}, Response: openapi.Envelope(User{}), Status: http.StatusCreated,
+			Errors: []int{http.StatusForbidden, http.StatusConflict}},
+		{ID: "verifyEmail", Method: http.MethodPost, Path: "/verify-email", Tags: tags, Summary: "Verify an email address",
+			Description: "Takes the token from the verification link.",
+			Request:     tokenRequest{}, Response: openapi.Envelope(nil), Errors: []int{http.StatusBadRequest}},
+		{ID: "forgotPassword", Method: http.MethodPost, Path: "/password/forgot", Tags: tags, Summary: "Email a password reset link",
+			Description: "Answers the same whether or not the email is registered.",
+			Request:     forgotRequest{}, Response: openapi.Envelope(nil), Status: http.StatusAccepted},
+		{ID: "resetPassword", Method: http.MethodPost, Path: "/password/reset", Tags: tags, Summary: "Reset a password",
+			Description: "Takes the token from the reset link, and logs the account out everywhere.",
+			Request:     resetRequest{}, Response: openapi.Envelope(nil), Errors: []int{http.StatusBadRequest}},
+		{ID: "getAccount", Method: http.MethodGet, Path: "", Tags: tags, Summary: "Get your account", Auth: true,
+			Response: openapi.Envelope(User{}), Errors: []int{http.StatusForbidden}},
+		{ID: "updateAccount", Method: http.MethodPatch, Path: "", Tags: tags, Summary: "Update your name or email", Auth: true,
+			Description: "A new email must be verified again. Honours `If-Match` with the ETag of the account.",
+			Request:     updateRequest{}, Response: openapi.Envelope(User{}),
+			Errors: []int{http.StatusForbidden, http.StatusConflict, http.StatusPreconditionFailed, http.StatusPreconditionRequired}},
+		{ID: "deleteAccount", Method: http.MethodDelete, Path: "", Tags: tags, Summary: "Delete your account", Auth: true,
+			Response: openapi.Envelope(nil), Errors: []int{http.StatusForbidden}},
+		{ID: "changePassword", Method: http.MethodPut, Path: "/password", Tags: tags, Summary: "Change your password", Auth: true,
+			Request: passwordRequest{}, Response: openapi.Envelope(nil), Errors: []int{http.StatusForbidden}},
+		{ID: "resendVerification", Method: http.MethodPost, Path: "/verify-email/resend", Tags: tags, Summary: "Resend the verification email", Auth: true,
+			Response: openapi.Envelope(nil), Status: http.StatusAccepted, Errors: []int{http.StatusForbidden, http.StatusConflict}},
+	}
+}
@@ -0,0 +1,195 @@
+package users
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/auth"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/precondition"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/validation"
+)
+
+type registerRequest struct {
+	Name     string `json:"name" binding:"required,notblank,max=100"`
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required,min=10,max=128"`
+}
+
+type tokenRequest struct {
+	Token string `json:"token" binding:"required,max=128"`
+}
+
+type forgotRequest struct {
+	Email string `json:"email" binding:"required,email,max=254"`
+}
+
+type resetRequest struct {
+	Token    string `json:"token" binding:"required,max=128"`
+	Password string `json:"password" binding:"required,min=10,max=128"`
+}
+
+type updateRequest struct {
+	Name  *string `json:"name" binding:"omitempty,notblank,max=100"`
+	Email *string `json:"email" binding:"omitempty,email,max=254"`
+}
+
+type passwordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required,max=128"`
+	NewPassword     string `json:"new_password" binding:"required,min=10,max=128"`
+}
+
+// Register mounts the account endpoints on g. Registration, verification
+// and password reset are public; the profile endpoints need the access
+// token of a password login.
+func (a *Accounts) Register(g *gin.RouterGroup) {
+	g.POST("/register", a.handleRegister)
+	g.POST("/verify-email", a.handleVerify)
+	g.POST("/password/forgot", a.handleForgot)
+	g.POST("/password/reset", a.handleReset)
+
+	me := g.Group("", auth.Required(), accountOnly())
+	me.GET("", a.handleGet)
+	me.PATCH("", a.handleUpdate)
+	me.DELETE("", a.handleDelete)
+	me.PUT("/password", a.handleChangePassword)
+	me.POST("/verify-email/resend", a.handleResend)
+}
+
+// userIDKey holds the user id of the logged-in account in the gin context.
+const userIDKey = "users.id"
+
+// accountOnly refuses subjects that are not users, such as the demo login
+// and API keys, and stores the user id for the handlers.
+func accountOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, _ := auth.ClaimsFrom(c)
+		id, ok := strings.CutPrefix(claims.Subject, SubjectPrefix)
+		if !ok {
+			c.Error(apperror.Forbidden("not logged in with a user account"))
+			c.Abort()
+			return
+		}
+		c.Set(userIDKey, id)
+		c.Next()
+	}
+}
+
+func (a *Accounts) handleRegister(c *gin.Context) {
+	var req registerRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	u, err := a.SignUp(c.Request.Context(), req.Name, req.Email, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, render.OK(i18n.T(c, "account registered, check your email"), u))
+}
+
+func (a *Accounts) handleVerify(c *gin.Context) {
+	var req tokenRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if err := a.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "email verified"), nil))
+}
+
+func (a *Accounts) handleForgot(c *gin.Context) {
+	var req forgotRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if err := a.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, render.OK(i18n.T(c, "if the email is registered, a reset link is on its way"), nil))
+}
+
+func (a *Accounts) handleReset(c *gin.Context) {
+	var req resetRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if err := a.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "password reset, log in again"), nil))
+}
+
+func (a *Accounts) handleGet(c *gin.Context) {
+	u, err := a.Get(c.Request.Context(), c.GetString(userIDKey))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	precondition.Set(c, u.Version)
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "account found"), u))
+}
+
+func (a *Accounts) handleUpdate(c *gin.Context) {
+	var req updateRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	chk, ok := precondition.From(c)
+	if !ok {
+		return
+	}
+	u, err := a.Update(c.Request.Context(), c.GetString(userIDKey), req.Name, req.Email, chk)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	precondition.Set(c, u.Version)
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "account updated"), u))
+}
+
+func (a *Accounts) handleDelete(c *gin.Context) {
+	if err := a.Delete(c.Request.Context(), c.GetString(userIDKey)); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "account deleted"), nil))
+}
+
+func (a *Accounts) handleChangePassword(c *gin.Context) {
+	var req passwordRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if err := a.ChangePassword(c.Request.Context(), c.GetString(userIDKey), req.CurrentPassword, req.NewPassword); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, render.OK(i18n.T(c, "password changed"), nil))
+}
+
+func (a *Accounts) handleResend(c *gin.Context) {
+	ctx := c.Request.Context()
+	u, err := a.Get(ctx, c.GetString(userIDKey))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if u.EmailVerifiedAt != nil {
+		c.Error(apperror.Conflict("email already verified"))
+		return
+	}
+	if err := a.SendVerification(ctx, u); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, render.OK(i18n.T(c, "verification email sent"), nil))
+}
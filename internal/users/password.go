@@ -0,0 +1,77 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters for new hashes: OWASP's recommendation of 19 MiB, two
+// passes and one lane, which keeps a login affordable on a small machine.
+// Each hash records its own parameters, so they can be raised later
+// without invalidating existing passwords.
+const (
+	hashMemory  = 19 * 1024 // KiB
+	hashTime    = 2
+	hashThreads = 1
+	hashSaltLen = 16
+	hashKeyLen  = 32
+)
+
+var errMalformedHash = errors.New("users: malformed password hash")
+
+// HashPassword returns the argon2id hash of password in the PHC string
+// format, "$argon2id$v=19$m=…,t=…,p=…$<salt>$<key>".
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, hashSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("users: generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, hashTime, hashMemory, hashThreads, hashKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, hashMemory, hashTime, hashThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches hash, a value returned by
+// HashPassword. An empty hash, as for users without a password, matches
+// nothing.
+func CheckPassword(hash, password string) (bool, error) {
+	if hash == "" {
+		return false, nil
+	}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errMalformedHash
+	}
+	var (
+		version        int
+		memory, passes uint32
+		threads        uint8
+	)
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &passes, &threads); err != nil || passes == 0 || threads == 0 {
+		return false, errMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, errMalformedHash
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) == 0 {
+		return false, errMalformedHash
+	}
+	got := argon2.IDKey([]byte(password), salt, passes, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
+
+// dummyHash is checked against when no user matches a login, so that the
+// response takes as long as for a wrong password and does not reveal which
+// emails are registered.
+var dummyHash, _ = HashPassword("not a password")
@@ -0,0 +1,330 @@
+// Package users holds the user resource and its persistence, and the
+// self-service accounts built on it: registration with a password, email
+// verification, password reset and deletion.
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/audit"
+	"go-flylike-example/internal/id"
+	"go-flylike-example/internal/query"
+	"go-flylike-example/internal/render"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/tenant"
+)
+
+// CacheTag tags cached user responses; it is invalidated on every write.
+const CacheTag = "users"
+
+// Permissions of the users routes that write, also the API key scopes of
+// them; reading takes PermRead as a scope only.
+const (
+	PermRead  = "users:read"
+	PermWrite = "users:write"
+)
+
+// Topic is the bus topic user events are published to.
+const Topic = "users"
+
+// EventPrefix starts the names of the events published to Topic.
+const EventPrefix = "user."
+
+// EventCreated is the event published for new users.
+const EventCreated = EventPrefix + "created"
+
+var (
+	// ErrNotFound is returned when no user matches.
+	ErrNotFound = apperror.NotFound("user not found")
+	// ErrEmailTaken is returned when another user already has the email.
+	ErrEmailTaken = apperror.Conflict("email already registered")
+)
+
+// User is an account as stored in the users table. Deleted users stay in
+// the table but are never returned.
+type User struct {
+	ID              string     `json:"id" xml:"id"`
+	Name            string     `json:"name" xml:"name"`
+	Email           string     `json:"email" xml:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" xml:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at" xml:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" xml:"updated_at"`
+	// Version is raised by every change; it is the ETag of the user.
+	Version int64 `json:"version" xml:"version"`
+}
+
+// Fields are the fields of users clients may pick with sparse fieldsets;
+// the id is always sent.
+var Fields = &render.Fieldset{
+	Type:    "users",
+	Allowed: []string{"id", "name", "email", "email_verified_at", "created_at", "updated_at", "version"},
+	Key:     []string{"id"},
+}
+
+// columns are the users columns scan reads, in order.
+const columns = "id, name, email, email_verified_at, created_at, updated_at, version"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(row scanner, u *User) error {
+	var verified sql.NullTime
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &verified, &u.CreatedAt, &u.UpdatedAt, &u.Version); err != nil {
+		return err
+	}
+	if verified.Valid {
+		u.EmailVerifiedAt = &verified.Time
+	}
+	return nil
+}
+
+// Repository reads and writes users. Every query is scoped to the tenant
+// carried by the context.
+type Repository struct {
+	db       *store.Store
+	onChange []func(ctx context.Context)
+	onCreate []func(ctx context.Context, u *User) error
+}
+
+// NewRepository returns a Repository backed by db.
+func NewRepository(db *store.Store) *Repository {
+	return &Repository{db: db}
+}
+
+// OnChange registers fn to run after every successful write, whichever
+// transport it came through. It must be called before the repository is
+// shared.
+func (r *Repository) OnChange(fn func(ctx context.Context)) {
+	r.onChange = append(r.onChange, fn)
+}
+
+// OnCreate registers fn to run with every user created, in the
+// transaction of the insert: an error undoes the creation. Like OnChange
+// it must be called before the repository is shared.
+func (r *Repository) OnCreate(fn func(ctx context.Context, u *User) error) {
+	r.onCreate = append(r.onCreate, fn)
+}
+
+func (r *Repository) changed(ctx context.Context) {
+	for _, fn := range r.onChange {
+		fn(ctx)
+	}
+}
+
+// List returns every user ordered by creation time.
+func (r *Repository) List(ctx context.Context) ([]User, error) {
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, r.db.Rebind(
+		`SELECT `+columns+` FROM users WHERE tenant_id = ? AND deleted_at IS NULL ORDER BY created_at, id`),
+		tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("users: list: %w", err)
+	}
+	defer rows.Close()
+
+	list := []User{}
+	for rows.Next() {
+		var u User
+		if err := scan(rows, &u); err != nil {
+			return nil, fmt.Errorf("users: list: %w", err)
+		}
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+// ListOptions are the sort and filter fields of paginated user lists.
+var ListOptions = &query.Options{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Sort: true, Ops: []query.Op{query.Eq, query.In}},
+		"name":       {Column: "name", Sort: true, Ops: []query.Op{query.Eq, query.Contains}},
+		"email":      {Column: "email", Sort: true, Ops: []query.Op{query.Eq, query.Contains}},
+		"created_at": {Column: "created_at", Type: query.Time, Sort: true, Ops: []query.Op{query.Gt, query.Gte, query.Lt, query.Lte}},
+		"updated_at": {Column: "updated_at", Type: query.Time, Sort: true, Ops: []query.Op{query.Gt, query.Gte, query.Lt, query.Lte}},
+	},
+	Key:          "id",
+	Sort:         "created_at",
+	DefaultLimit: 50,
+	MaxLimit:     200,
+}
+
+// ListPage returns the page of users spec selects.
+func (r *Repository) ListPage(ctx context.Context, spec *query.Spec) ([]User, query.Page, error) {
+	list, err := r.list(ctx, spec)
+	if err != nil {
+		return nil, query.Page{}, err
+	}
+	list, page := query.Paginate(spec, list, userField)
+	return list, page, nil
+}
+
+// streamBatch is how many users Stream reads per query.
+const streamBatch = 500
+
+// Stream yields every user spec selects from its cursor on, however many,
+// reading them in batches rather than all at once.
+func (r *Repository) Stream(ctx context.Context, spec *query.Spec) iter.Seq2[User, error] {
+	return query.All(spec, streamBatch, func(s *query.Spec) ([]User, error) {
+		return r.list(ctx, s)
+	}, userField)
+}
+
+// Revision returns a value that changes with every write to the users of
+// the tenant, deletions included, for exports to tell whether the users
+// are still as they read them.
+func (r *Repository) Revision(ctx context.Context) (string, error) {
+	var n, versions int64
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, r.db.Rebind(
+		`SELECT COUNT(*), COALESCE(SUM(version), 0) FROM users WHERE tenant_id = ?`), tenant.ID(ctx)).Scan(&n, &versions); err != nil {
+		return "", fmt.Errorf("users: revision: %w", err)
+	}
+	return fmt.Sprintf("%d-%d", n, versions), nil
+}
+
+func (r *Repository) list(ctx context.Context, spec *query.Spec) ([]User, error) {
+	q, args := spec.Build(`SELECT `+columns+` FROM users WHERE tenant_id = ? AND deleted_at IS NULL`, tenant.ID(ctx))
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, r.db.Rebind(q), args...)
+	if err != nil {
+		return nil, fmt.Errorf("users: list: %w", err)
+	}
+	defer rows.Close()
+
+	list := []User{}
+	for rows.Next() {
+		var u User
+		if err := scan(rows, &u); err != nil {
+			return nil, fmt.Errorf("users: list: %w", err)
+		}
+		list = append(list, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("users: list: %w", err)
+	}
+	return list, nil
+}
+
+func userField(u User, field string) any {
+	switch field {
+	case "name":
+		return u.Name
+	case "email":
+		return u.Email
+	case "created_at":
+		return u.CreatedAt
+	case "updated_at":
+		return u.UpdatedAt
+	}
+	return u.ID
+}
+
+// Get returns the user with the given id.
+func (r *Repository) Get(ctx context.Context, id string) (*User, error) {
+	var u User
+	err := scan(r.db.Reader(ctx).QueryRowContext(ctx, r.db.Rebind(
+		`SELECT `+columns+` FROM users WHERE tenant_id = ? AND id = ? AND deleted_at IS NULL`),
+		tenant.ID(ctx), id), &u)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("users: get: %w", err)
+	}
+	return &u, nil
+}
+
+// GetMany returns the users with the given ids, keyed by id; ids that match
+// no user are left out.
+func (r *Repository) GetMany(ctx context.Context, ids []string) (map[string]*User, error) {
+	found := make(map[string]*User, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+	args := []any{tenant.ID(ctx)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, r.db.Rebind(
+		`SELECT `+columns+` FROM users WHERE tenant_id = ? AND id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) AND deleted_at IS NULL`),
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("users: get many: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u := &User{}
+		if err := scan(rows, u); err != nil {
+			return nil, fmt.Errorf("users: get many: %w", err)
+		}
+		found[u.ID] = u
+	}
+	return found, rows.Err()
+}
+
+// Create inserts a new user without a password; it can set one through a
+// password reset.
+func (r *Repository) Create(ctx context.Context, name, email string) (*User, error) {
+	return r.create(ctx, name, email, "")
+}
+
+func (r *Repository) create(ctx context.Context, name, email, passwordHash string) (*User, error) {
+	now := time.Now().UTC()
+	u := &User{ID: id.New(), Name: name, Email: NormalizeEmail(email), CreatedAt: now, UpdatedAt: now, Version: 1}
+	err := r.db.InTx(ctx, func(ctx context.Context) error {
+		_, err := r.db.Writer(ctx).ExecContext(ctx, r.db.Rebind(
+			`INSERT INTO users (id, tenant_id, name, email, password_hash, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+			u.ID, tenant.ID(ctx), u.Name, u.Email, passwordHash, u.CreatedAt, u.UpdatedAt)
+		if store.IsUniqueViolation(err) {
+			return ErrEmailTaken
+		}
+		if err != nil {
+			return fmt.Errorf("users: create: %w", err)
+		}
+		for _, fn := range r.onCreate {
+			if err := fn(ctx, u); err != nil {
+				return fmt.Errorf("users: create: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The user exists now whether or not the caller is still waiting.
+	r.changed(context.WithoutCancel(ctx))
+	return u, nil
+}
+
+// Delete soft-deletes the user with the given id: it disappears from every
+// query and its email address can be registered again.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	if audit.Recording(ctx) {
+		if u, err := r.Get(ctx, id); err == nil {
+			audit.Before(ctx, u)
+		}
+	}
+	now := time.Now().UTC()
+	res, err := r.db.Writer(ctx).ExecContext(ctx, r.db.Rebind(
+		`UPDATE users SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE tenant_id = ? AND id = ? AND deleted_at IS NULL`),
+		now, now, tenant.ID(ctx), id)
+	if err != nil {
+		return fmt.Errorf("users: delete: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	r.changed(context.WithoutCancel(ctx))
+	return nil
+}
+
+// NormalizeEmail returns email as stored: trimmed and lower-cased, since
+// addresses are compared case-insensitively.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,47 @@
+// Package validation binds request bodies with go-playground/validator
+// struct tags and reports failures as RFC 7807 application/problem+json
+// documents listing every field-level violation.
+package validation
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/api"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/logging"
+)
+
+// ContentType is the media type of problem documents.
+const ContentType = api.ProblemType
+
+// Problem is an RFC 7807 problem details document.
+type Problem = api.Problem
+
+// FieldError describes one violated constraint.
+type FieldError = api.FieldError
+
+// NewProblem returns a problem of the generic about:blank type whose title
+// is the status text.
+func NewProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Abort writes p, with the ID of the request, and stops the handler chain.
+// The title is translated into the locale of the request; the detail and
+// field messages are the caller's to translate.
+func Abort(c *gin.Context, p Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	p.RequestID = c.GetString(logging.ContextKeyRequestID)
+	p.Title = i18n.T(c, p.Title)
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
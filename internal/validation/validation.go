@@ -0,0 +1,206 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/protobuf/proto"
+
+	"go-flylike-example/internal/i18n"
+)
+
+// Setup configures Gin's validator to report JSON field names and registers
+// the custom rules used by request structs. It must run before any request
+// is bound.
+func Setup() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("validation: unexpected validator engine %T", binding.Validator.Engine())
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "form", "uri", "header"} {
+			name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return f.Name
+	})
+	return v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimFunc(fl.Field().String(), unicode.IsSpace) != ""
+	})
+}
+
+// Bind decodes the request body according to its Content-Type into obj and
+// validates it. On failure it writes a problem response and returns false,
+// so handlers can simply return.
+func Bind(c *gin.Context, obj any) bool {
+	return handle(c, c.ShouldBind(obj))
+}
+
+// BindJSON is Bind for handlers that only accept JSON.
+func BindJSON(c *gin.Context, obj any) bool {
+	return handle(c, c.ShouldBindJSON(obj))
+}
+
+// BindProto decodes a Protobuf request body into msg, has fill copy it
+// into obj and validates obj like Bind, for handlers whose request struct
+// has a Protobuf counterpart.
+func BindProto(c *gin.Context, msg proto.Message, obj any, fill func()) bool {
+	if err := c.ShouldBindWith(msg, binding.ProtoBuf); err != nil {
+		return handle(c, err)
+	}
+	fill()
+	return handle(c, binding.Validator.ValidateStruct(obj))
+}
+
+// BindQuery binds and validates query parameters.
+func BindQuery(c *gin.Context, obj any) bool {
+	return handle(c, c.ShouldBindQuery(obj))
+}
+
+// BindURI binds and validates path parameters.
+func BindURI(c *gin.Context, obj any) bool {
+	return handle(c, c.ShouldBindUri(obj))
+}
+
+// BindForm is Bind for handlers that answer invalid input with the form
+// again, such as HTML pages: the field violations are returned, with their
+// messages translated, instead of written. Bodies that cannot be decoded
+// still get a problem response and ok false.
+func BindForm(c *gin.Context, obj any) (fields []FieldError, ok bool) {
+	err := c.ShouldBind(obj)
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, handle(c, err)
+	}
+	for _, fe := range verrs {
+		fields = append(fields, fieldError(fe, func(key string, args ...any) string {
+			return i18n.T(c, key, args...)
+		}))
+	}
+	return fields, true
+}
+
+// Struct validates obj by its binding tags like Bind and returns what it
+// got wrong, for transports that do not bind requests through gin.
+func Struct(obj any) []FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(binding.Validator.ValidateStruct(obj), &verrs) {
+		return nil
+	}
+	errs := make([]FieldError, len(verrs))
+	for i, fe := range verrs {
+		errs[i] = fieldError(fe, fmt.Sprintf)
+	}
+	return errs
+}
+
+func handle(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		p := NewProblem(http.StatusUnprocessableEntity, i18n.T(c, "the request contains invalid fields"))
+		p.Type = "/problems/validation"
+		p.Title = "Validation failed"
+		for _, fe := range verrs {
+			p.Errors = append(p.Errors, fieldError(fe, func(key string, args ...any) string {
+				return i18n.T(c, key, args...)
+			}))
+		}
+		Abort(c, p)
+		return false
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		p := NewProblem(http.StatusRequestEntityTooLarge,
+			i18n.T(c, "the request body must not exceed %d bytes", tooLarge.Limit))
+		p.Type = "/problems/payload-too-large"
+		Abort(c, p)
+		return false
+	}
+
+	detail := "the request body could not be decoded"
+	if errors.Is(err, io.EOF) {
+		detail = "the request body must not be empty"
+	}
+	p := NewProblem(http.StatusBadRequest, i18n.T(c, detail))
+	p.Type = "/problems/malformed-request"
+	Abort(c, p)
+	return false
+}
+
+// fieldError describes fe with its message rendered by format, which is
+// fmt.Sprintf or a translation of it.
+func fieldError(fe validator.FieldError, format func(key string, args ...any) string) FieldError {
+	// Namespace is "CreateUserRequest.address.city"; drop the struct name.
+	field := fe.Namespace()
+	if _, rest, ok := strings.Cut(field, "."); ok {
+		field = rest
+	}
+	key, args := message(fe)
+	return FieldError{
+		Field:   field,
+		Rule:    fe.Tag(),
+		Param:   fe.Param(),
+		Message: format(key, args...),
+	}
+}
+
+// message returns the format of the message for fe and its arguments.
+func message(fe validator.FieldError) (string, []any) {
+	switch fe.Tag() {
+	case "required":
+		return "is required", nil
+	case "notblank":
+		return "must not be blank", nil
+	case "email":
+		return "must be a valid email address", nil
+	case "url", "uri":
+		return "must be a valid URL", nil
+	case "uuid", "uuid4":
+		return "must be a valid UUID", nil
+	case "oneof":
+		return "must be one of: %s", []any{strings.ReplaceAll(fe.Param(), " ", ", ")}
+	case "min", "gte":
+		if isSized(fe.Kind()) {
+			return "must contain at least %s characters or items", []any{fe.Param()}
+		}
+		return "must be at least %s", []any{fe.Param()}
+	case "max", "lte":
+		if isSized(fe.Kind()) {
+			return "must contain at most %s characters or items", []any{fe.Param()}
+		}
+		return "must be at most %s", []any{fe.Param()}
+	case "len":
+		return "must have length %s", []any{fe.Param()}
+	case "gt":
+		return "must be greater than %s", []any{fe.Param()}
+	case "lt":
+		return "must be less than %s", []any{fe.Param()}
+	}
+	return "failed the %s rule", []any{fe.Tag()}
+}
+
+func isSized(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+// Package views renders server-side HTML pages with html/template. Pages
+// live under templates/pages and are each parsed together with the layout
+// in templates/layouts/base.html and every partial in templates/partials,
+// so a page only defines the blocks it fills, such as "title" and
+// "content". Requests made by htmx (HX-Request) get the "content" block
+// alone, for swapping into a page already shown.
+package views
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/i18n"
+	"go-flylike-example/internal/session"
+)
+
+//go:embed templates assets
+var embedded embed.FS
+
+// Embedded returns the templates and assets built into the binary.
+func Embedded() fs.FS {
+	return embedded
+}
+
+const (
+	layout   = "templates/layouts/base.html"
+	partials = "templates/partials/*.html"
+	pagesDir = "templates/pages"
+)
+
+// funcs are available to every template.
+var funcs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// Renderer renders the pages of a file system laid out like this package.
+type Renderer struct {
+	fsys   fs.FS
+	reload bool
+	pages  map[string]*template.Template
+}
+
+// New parses every page of fsys, so that a broken template fails startup.
+// With reload set each page is parsed again whenever it is rendered, which
+// picks up edits to files on disk.
+func New(fsys fs.FS, reload bool) (*Renderer, error) {
+	r := &Renderer{fsys: fsys, reload: reload, pages: map[string]*template.Template{}}
+	err := fs.WalkDir(fsys, pagesDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".html" {
+			return err
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(p, pagesDir+"/"), ".html")
+		t, err := r.parse(name)
+		if err != nil {
+			return err
+		}
+		r.pages[name] = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Renderer) parse(name string) (*template.Template, error) {
+	t, err := template.New(path.Base(layout)).Funcs(funcs).ParseFS(r.fsys, layout, partials, pagesDir+"/"+name+".html")
+	if err != nil {
+		return nil, fmt.Errorf("views: page %s: %w", name, err)
+	}
+	return t, nil
+}
+
+func (r *Renderer) page(name string) (*template.Template, error) {
+	if r.reload {
+		return r.parse(name)
+	}
+	t, ok := r.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("views: no page %s", name)
+	}
+	return t, nil
+}
+
+// Page is the dot of every template: the data of the handler, along with
+// what layouts and forms need from the request.
+type Page struct {
+	Data any
+	// Lang is the locale the page is rendered in.
+	Lang string
+	// Path is the path of the request, for marking the current link.
+	Path string
+	// CSRFToken goes into forms as the csrf_token field.
+	CSRFToken string
+	ctx       context.Context
+}
+
+// T translates key into the locale of the request, like i18n.T.
+func (p Page) T(key string, args ...any) string {
+	return i18n.Translate(p.ctx, key, args...)
+}
+
+// HTML renders the named page, such as "users/index", with data and writes
+// it with status. The page is rendered in full before anything is written,
+// so a template error still becomes an error response.
+func (r *Renderer) HTML(c *gin.Context, status int, name string, data any) {
+	t, err := r.page(name)
+	if err != nil {
+		c.Error(apperror.Internal(err))
+		return
+	}
+	root := path.Base(layout)
+	c.Writer.Header().Add("Vary", "HX-Request")
+	if c.GetHeader("HX-Request") == "true" {
+		root = "content"
+	}
+	var buf bytes.Buffer
+	p := Page{
+		Data:      data,
+		Lang:      i18n.Locale(c.Request.Context()),
+		Path:      c.Request.URL.Path,
+		CSRFToken: session.From(c).CSRFToken(),
+		ctx:       c.Request.Context(),
+	}
+	if err := t.ExecuteTemplate(&buf, root, p); err != nil {
+		c.Error(apperror.Internal(fmt.Errorf("views: page %s: %w", name, err)))
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Assets serves the files under assets, for a route ending in *file.
+func (r *Renderer) Assets() gin.HandlerFunc {
+	sub, err := fs.Sub(r.fsys, "assets")
+	if err != nil {
+		panic(err)
+	}
+	files := http.FS(sub)
+	return func(c *gin.Context) {
+		c.FileFromFS(c.Param("file"), files)
+	}
+}
@@ -0,0 +1,8 @@
+fetch("/region")
+  .then((res) => res.json())
+  .then((body) => {
+    document.getElementById("region").textContent = body.data?.region || "unknown";
+  })
+  .catch(() => {
+    document.getElementById("region").textContent = "unavailable";
+  });
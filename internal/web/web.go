@@ -0,0 +1,223 @@
+// Package web serves the frontend bundled into the binary. Assets are
+// compressed with gzip and brotli once at startup, and in SPA mode unknown
+// paths fall back to index.html so client-side routing works.
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+)
+
+//go:embed dist
+var dist embed.FS
+
+// Dist returns the embedded frontend build.
+func Dist() fs.FS {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// minCompressSize is the smallest asset worth compressing.
+const minCompressSize = 1024
+
+// asset is one file with its precomputed encodings.
+type asset struct {
+	contentType string
+	etag        string
+	raw         []byte
+	gzip        []byte // nil when not smaller than raw
+	brotli      []byte
+}
+
+// Handler serves the files of an fs.FS.
+type Handler struct {
+	assets   map[string]*asset
+	spa      bool
+	reserved []string
+}
+
+// New loads every file of fsys into memory. With spa set, GET requests for
+// unknown paths outside the reserved prefixes are answered with index.html.
+func New(fsys fs.FS, spa bool, reserved ...string) (*Handler, error) {
+	h := &Handler{assets: make(map[string]*asset), spa: spa, reserved: reserved}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		a, err := newAsset(name, b)
+		if err != nil {
+			return fmt.Errorf("web: %s: %w", name, err)
+		}
+		h.assets["/"+name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if spa && h.assets["/index.html"] == nil {
+		return nil, fmt.Errorf("web: spa mode requires an index.html")
+	}
+	return h, nil
+}
+
+func newAsset(name string, b []byte) (*asset, error) {
+	sum := sha256.Sum256(b)
+	a := &asset{
+		contentType: mime.TypeByExtension(path.Ext(name)),
+		etag:        hex.EncodeToString(sum[:8]),
+		raw:         b,
+	}
+	if a.contentType == "" {
+		a.contentType = http.DetectContentType(b)
+	}
+	if len(b) < minCompressSize || !compressible(a.contentType) {
+		return a, nil
+	}
+
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err := compress(zw, b); err != nil {
+		return nil, err
+	}
+	if buf.Len() < len(b) {
+		a.gzip = bytes.Clone(buf.Bytes())
+	}
+
+	buf.Reset()
+	if err := compress(brotli.NewWriterLevel(&buf, brotli.BestCompression), b); err != nil {
+		return nil, err
+	}
+	if buf.Len() < len(b) {
+		a.brotli = bytes.Clone(buf.Bytes())
+	}
+	return a, nil
+}
+
+func compress(w io.WriteCloser, b []byte) error {
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func compressible(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mt, "text/"):
+		return true
+	case mt == "application/javascript", mt == "application/json", mt == "application/wasm",
+		mt == "image/svg+xml", mt == "application/manifest+json":
+		return true
+	}
+	return false
+}
+
+// Handle serves the requested asset. It is meant to be installed as the
+// router's NoRoute handler so that registered routes always win.
+func (h *Handler) Handle(c *gin.Context) {
+	method := c.Request.Method
+	p := c.Request.URL.Path
+	if (method != http.MethodGet && method != http.MethodHead) || h.isReserved(p) {
+		c.Error(apperror.NotFound("route not found"))
+		return
+	}
+
+	if strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	a, ok := h.assets[p]
+	if !ok && h.spa && path.Ext(p) == "" {
+		// Client-side route. Missing files such as a stale bundle
+		// reference stay 404 rather than receiving HTML.
+		a, ok, p = h.assets["/index.html"], true, "/index.html"
+	}
+	if !ok {
+		c.Error(apperror.NotFound("file not found"))
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", a.contentType)
+	header.Set("Cache-Control", cacheControl(p))
+	header.Add("Vary", "Accept-Encoding")
+
+	body, encoding := a.raw, ""
+	accept := c.GetHeader("Accept-Encoding")
+	switch {
+	case a.brotli != nil && accepts(accept, "br"):
+		body, encoding = a.brotli, "br"
+	case a.gzip != nil && accepts(accept, "gzip"):
+		body, encoding = a.gzip, "gzip"
+	}
+	etag := a.etag
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+		etag += "-" + encoding
+	}
+	header.Set("ETag", strconv.Quote(etag))
+
+	http.ServeContent(c.Writer, c.Request, p, time.Time{}, bytes.NewReader(body))
+}
+
+func (h *Handler) isReserved(p string) bool {
+	for _, prefix := range h.reserved {
+		if p == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl lets browsers keep fingerprinted build output forever and
+// revalidate everything else, so a deploy is picked up on the next load.
+func cacheControl(p string) string {
+	switch {
+	case p == "/index.html":
+		return "no-cache"
+	case strings.HasPrefix(p, "/assets/"):
+		return "public, max-age=31536000, immutable"
+	default:
+		return "public, max-age=0, must-revalidate"
+	}
+}
+
+// accepts reports whether an Accept-Encoding header allows enc.
+func accepts(header, enc string) bool {
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), enc) && strings.TrimSpace(name) != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
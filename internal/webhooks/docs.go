@@ -0,0 +1,18 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"go-flylike-example/internal/openapi"
+)
+
+// Operations documents the GitHub receiver, relative to its group.
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{ID: "receiveGitHubWebhook", Method: http.MethodPost, Path: "/github", Tags: []string{"inbound-webhooks"}, Summary: "Receive a GitHub webhook",
+			Description: "Requires a valid X-Hub-Signature-256 header for one of the configured secrets. " +
+				"Deliveries already received, by their signature, are acknowledged without being processed again.",
+			Request: GitHubEvent{}, Response: openapi.Envelope(nil), Status: http.StatusAccepted,
+			Errors: []int{http.StatusUnauthorized, http.StatusUnsupportedMediaType}},
+	}
+}
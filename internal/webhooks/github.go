@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/render"
+)
+
+// GitHubEvent is the part of a GitHub webhook payload the receiver reads.
+type GitHubEvent struct {
+	Delivery   string `json:"delivery"`
+	Event      string `json:"event"`
+	Action     string `json:"action,omitempty"`
+	Ref        string `json:"ref,omitempty"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Commits []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	} `json:"commits,omitempty"`
+}
+
+// GitHubReceiver answers GitHub webhooks that passed Verify with a GitHub
+// verifier. Pings, sent when a hook is created, are acknowledged; other
+// events are decoded and handed to fn, whose failure makes GitHub retry.
+// Hooks must use the application/json content type.
+func GitHubReceiver(fn func(ctx context.Context, e *GitHubEvent) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mt, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); mt != "application/json" {
+			c.Error(apperror.UnsupportedMediaType("github webhooks must be sent as application/json"))
+			return
+		}
+		var e GitHubEvent
+		if err := json.Unmarshal(Body(c), &e); err != nil {
+			c.Error(apperror.BadRequest("malformed github payload"))
+			return
+		}
+		e.Delivery = c.GetHeader(HeaderGitHubDelivery)
+		e.Event = c.GetHeader(HeaderGitHubEvent)
+
+		logging.FromContext(c.Request.Context()).Info("github webhook received",
+			"event", e.Event, "delivery_id", e.Delivery, "repository", e.Repository.FullName)
+		if e.Event == "ping" {
+			c.JSON(http.StatusOK, render.OK("pong", nil))
+			return
+		}
+		if err := fn(c.Request.Context(), &e); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusAccepted, render.OK("webhook accepted", nil))
+	}
+}
@@ -0,0 +1,114 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/render"
+)
+
+const bodyKey = "webhooks.body"
+
+// RawBody reads the request body once and keeps the bytes on the context,
+// then hands handlers a fresh reader over them. Binding drains
+// c.Request.Body, and a signature is only valid over the exact bytes sent,
+// not over a re-encoded struct. Bodies are bounded by limits.BodyLimit.
+func RawBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if capture(c) {
+			c.Next()
+		}
+	}
+}
+
+// Body returns the bytes captured by RawBody or Verify.
+func Body(c *gin.Context) []byte {
+	b, _ := c.Get(bodyKey)
+	body, _ := b.([]byte)
+	return body
+}
+
+// capture stores the body on c unless an earlier middleware did. It
+// aborts and returns false when the body cannot be read.
+func capture(c *gin.Context) bool {
+	if _, ok := c.Get(bodyKey); ok {
+		return true
+	}
+	var body []byte
+	if c.Request.Body != nil {
+		b, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.Error(apperror.PayloadTooLarge("request body too large"))
+			} else {
+				c.Error(apperror.BadRequest("failed to read request body"))
+			}
+			c.Abort()
+			return false
+		}
+		body = b
+	}
+	c.Set(bodyKey, body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return true
+}
+
+// Verify captures the raw body and rejects requests v does not accept
+// with 401. Deliveries whose id was seen within ttl are acknowledged with
+// 200 without reaching the handler: senders retry when an answer gets
+// lost, and a replayed capture must not run twice. A delivery the handler
+// fails with a 5xx is forgotten, so the sender's retry goes through. ttl
+// should cover the verifier's tolerance on both sides of now.
+func Verify(v Verifier, seen Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !capture(c) {
+			return
+		}
+		id, err := v.Verify(c.Request.Header, Body(c), time.Now())
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		first, err := seen.First(c.Request.Context(), id, ttl)
+		if err != nil {
+			c.Error(&apperror.Error{Kind: apperror.KindUnavailable, Message: "webhook replay store unavailable", Err: err})
+			c.Abort()
+			return
+		}
+		if !first {
+			logging.FromContext(c.Request.Context()).Info("duplicate webhook delivery ignored", "delivery_id", id)
+			c.AbortWithStatusJSON(http.StatusOK, render.OK("webhook already received", nil))
+			return
+		}
+		c.Next()
+		if failed(c) {
+			if err := seen.Forget(context.WithoutCancel(c.Request.Context()), id); err != nil {
+				logging.FromContext(c.Request.Context()).Warn("webhook delivery not forgotten", "delivery_id", id, "error", err)
+			}
+		}
+	}
+}
+
+// failed reports whether the handler answered, or is about to answer
+// through the error middleware, with a server error.
+func failed(c *gin.Context) bool {
+	if c.Writer.Written() {
+		return c.Writer.Status() >= http.StatusInternalServerError
+	}
+	for _, err := range c.Errors {
+		if apperror.From(err.Err).Status() >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	return false
+}
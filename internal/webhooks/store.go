@@ -0,0 +1,113 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/kv"
+)
+
+// Store remembers delivery ids. First reports whether id is new and, if
+// so, records it for ttl; Forget drops it again so that a retry of a
+// delivery whose handling failed is processed.
+type Store interface {
+	First(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	Forget(ctx context.Context, id string) error
+}
+
+// maxEntries caps the memory store; it is simply emptied when full.
+const maxEntries = 100000
+
+// MemoryStore keeps delivery ids in process memory, per instance.
+type MemoryStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{seen: make(map[string]time.Time)}
+}
+
+// First implements Store.
+func (s *MemoryStore) First(_ context.Context, id string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if expires, ok := s.seen[id]; ok && now.Before(expires) {
+		return false, nil
+	}
+	if len(s.seen) >= maxEntries {
+		clear(s.seen)
+	}
+	s.seen[id] = now.Add(ttl)
+	return true, nil
+}
+
+// Forget implements Store.
+func (s *MemoryStore) Forget(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seen, id)
+	return nil
+}
+
+// RedisStore keeps delivery ids in Redis, shared by every instance.
+type RedisStore struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisStore returns a Store backed by client.
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client, prefix: "webhooks:seen:"}
+}
+
+// First implements Store.
+func (s *RedisStore) First(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, s.prefix+id, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("webhooks: redis setnx: %w", err)
+	}
+	return ok, nil
+}
+
+// Forget implements Store.
+func (s *RedisStore) Forget(ctx context.Context, id string) error {
+	if err := s.client.Del(ctx, s.prefix+id).Err(); err != nil {
+		return fmt.Errorf("webhooks: redis del: %w", err)
+	}
+	return nil
+}
+
+// KVStore keeps delivery ids in a bucket of the embedded store, kept
+// across restarts of a single node.
+type KVStore struct {
+	bucket *kv.Bucket
+}
+
+// NewKVStore returns a Store backed by db.
+func NewKVStore(db *kv.DB) *KVStore {
+	return &KVStore{bucket: db.Bucket("webhooks_seen")}
+}
+
+// First implements Store.
+func (s *KVStore) First(_ context.Context, id string, ttl time.Duration) (bool, error) {
+	ok, err := s.bucket.Add(id, nil, ttl)
+	if err != nil {
+		return false, fmt.Errorf("webhooks: kv add: %w", err)
+	}
+	return ok, nil
+}
+
+// Forget implements Store.
+func (s *KVStore) Forget(_ context.Context, id string) error {
+	if err := s.bucket.Delete(id); err != nil {
+		return fmt.Errorf("webhooks: kv delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package webhooks verifies webhooks sent to this server by third parties.
+// Signatures are HMAC-SHA256 over the exact bytes received, compared in
+// constant time; timestamped schemes reject deliveries outside a tolerance
+// window, and the signatures of deliveries are remembered so a captured
+// request cannot be replayed within it.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-flylike-example/internal/apperror"
+)
+
+var (
+	// ErrMissingSignature is returned when the signature header is absent
+	// or malformed.
+	ErrMissingSignature = apperror.Unauthorized("webhook signature missing")
+	// ErrInvalidSignature is returned when no secret produces the
+	// signature.
+	ErrInvalidSignature = apperror.Unauthorized("webhook signature invalid")
+	// ErrExpired is returned for timestamps outside the tolerance window.
+	ErrExpired = apperror.Unauthorized("webhook timestamp outside the tolerance window")
+)
+
+// Verifier checks the signature of an inbound webhook over its raw body.
+// It returns an id unique to the delivery, which Verify uses to refuse
+// replays. The id must be derived from what the signature covers, so a
+// sender of a captured request cannot change it.
+type Verifier interface {
+	Verify(h http.Header, body []byte, now time.Time) (id string, err error)
+}
+
+// Timestamped verifies "t=<unix seconds>,v1=<hex>" signatures, where v1 is
+// the HMAC-SHA256 of "<t>.<body>". Stripe signs this way in the
+// Stripe-Signature header, and so does this server's own outbound webhooks
+// package in Webhook-Signature. Several v1 values may be present while the
+// sender rotates secrets.
+type Timestamped struct {
+	Header string
+	// Secrets are tried in order, so an old and a new secret can both be
+	// accepted during a rotation.
+	Secrets   []string
+	Tolerance time.Duration
+}
+
+// Verify implements Verifier. The signature itself is the delivery id:
+// it changes with every timestamp.
+func (v Timestamped) Verify(h http.Header, body []byte, now time.Time) (string, error) {
+	var (
+		ts   string
+		sigs [][]byte
+	)
+	for part := range strings.SplitSeq(h.Get(v.Header), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return "", ErrMissingSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > v.Tolerance || d < -v.Tolerance {
+		return "", ErrExpired
+	}
+
+	signed := make([]byte, 0, len(ts)+1+len(body))
+	signed = append(append(append(signed, ts...), '.'), body...)
+	for _, secret := range v.Secrets {
+		want := mac(secret, signed)
+		for _, sig := range sigs {
+			if hmac.Equal(sig, want) {
+				return ts + ":" + hex.EncodeToString(sig), nil
+			}
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+// GitHub verifies the X-Hub-Signature-256 header of GitHub webhooks,
+// "sha256=<hex HMAC-SHA256 of the body>". GitHub signs no timestamp, so
+// replays are caught by the signature alone; the X-GitHub-Delivery id is
+// not signed and could be changed on every replay.
+type GitHub struct {
+	Secrets []string
+}
+
+// GitHubReplayWindow is how long the signatures of GitHub deliveries are
+// remembered.
+const GitHubReplayWindow = 72 * time.Hour
+
+// GitHub request headers.
+const (
+	HeaderGitHubSignature = "X-Hub-Signature-256"
+	HeaderGitHubEvent     = "X-GitHub-Event"
+	HeaderGitHubDelivery  = "X-GitHub-Delivery"
+)
+
+// Verify implements Verifier. The signature is the delivery id: every
+// event has a body of its own, with its own delivery and time in it.
+func (v GitHub) Verify(h http.Header, body []byte, _ time.Time) (string, error) {
+	value, ok := strings.CutPrefix(h.Get(HeaderGitHubSignature), "sha256=")
+	sig, err := hex.DecodeString(value)
+	if !ok || err != nil || len(sig) != sha256.Size {
+		return "", ErrMissingSignature
+	}
+	for _, secret := range v.Secrets {
+		if hmac.Equal(sig, mac(secret, body)) {
+			return value, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+func mac(secret string, data []byte) []byte {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(data)
+	return m.Sum(nil)
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const secret = "0123456789abcdef"
+
+// delivery returns a GitHub delivery of body with the id, signed with
+// secret.
+func delivery(body, id string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/github", strings.NewReader(body))
+	req.Header.Set(HeaderGitHubSignature, "sha256="+hex.EncodeToString(mac(secret, []byte(body))))
+	req.Header.Set(HeaderGitHubDelivery, id)
+	return req
+}
+
+func TestVerifyReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		second *http.Request
+		want   int // handler runs, of two deliveries
+	}{
+		{"other event", delivery(`{"action":"closed"}`, "d2"), 2},
+		{"replay", delivery(`{"action":"opened"}`, "d1"), 1},
+		{"replay with another delivery id", delivery(`{"action":"opened"}`, "d2"), 1},
+		{"replay without a delivery id", delivery(`{"action":"opened"}`, ""), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			r := gin.New()
+			r.POST("/webhooks/github", Verify(GitHub{Secrets: []string{secret}}, NewMemoryStore(), time.Hour),
+				func(c *gin.Context) { runs++ })
+			for _, req := range []*http.Request{delivery(`{"action":"opened"}`, "d1"), tt.second} {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200", w.Code)
+				}
+			}
+			if runs != tt.want {
+				t.Errorf("handler ran %d times, want %d", runs, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubVerify(t *testing.T) {
+	v := GitHub{Secrets: []string{"old secret", secret}}
+	tests := []struct {
+		name   string
+		change func(h http.Header)
+		want   error
+	}{
+		{name: "valid"},
+		{name: "other secret", change: func(h http.Header) {
+			h.Set(HeaderGitHubSignature, "sha256="+hex.EncodeToString(mac("another secret", []byte("{}"))))
+		}, want: ErrInvalidSignature},
+		{name: "other algorithm", change: func(h http.Header) {
+			h.Set(HeaderGitHubSignature, "sha1="+hex.EncodeToString(mac(secret, []byte("{}"))[:20]))
+		}, want: ErrMissingSignature},
+		{name: "unsigned", change: func(h http.Header) { h.Del(HeaderGitHubSignature) }, want: ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := delivery("{}", "d1")
+			if tt.change != nil {
+				tt.change(req.Header)
+			}
+			if _, err := v.Verify(req.Header, []byte("{}"), time.Now()); !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
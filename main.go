@@ -1,21 +1,398 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"go-flylike-example/internal/app"
+	"go-flylike-example/internal/apperror"
+	"go-flylike-example/internal/buildinfo"
+	"go-flylike-example/internal/certs"
+	"go-flylike-example/internal/config"
+	"go-flylike-example/internal/httpclient"
+	"go-flylike-example/internal/idle"
+	"go-flylike-example/internal/logging"
+	"go-flylike-example/internal/metrics"
+	"go-flylike-example/internal/mtls"
+	"go-flylike-example/internal/protocols"
+	"go-flylike-example/internal/region"
+	"go-flylike-example/internal/reporting"
+	"go-flylike-example/internal/restart"
+	"go-flylike-example/internal/rpc"
+	"go-flylike-example/internal/server"
+	"go-flylike-example/internal/store"
+	"go-flylike-example/internal/telemetry"
+	"go-flylike-example/internal/tracing"
 )
 
 func main() {
-	router := gin.Default()
+	if len(os.Args) > 1 && os.Args[1] == "help" {
+		usage(os.Stdout)
+		return
+	}
+	cmd, args := lookup(os.Args[1:])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "server: unknown command %q\n\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd.run(args)
+}
 
-	router.GET("/ping", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "pong from my-web-app 1!"})
-	})
+// serve runs the server until it is signalled to stop.
+func serve(args []string) {
+	cfg, err := config.Load(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var exporters []slog.Handler
+	if cfg.Telemetry.Logs {
+		exporter, shutdownLogs, err := telemetry.Logs(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Deferred first, so run last: the records of the shutdown are
+		// pushed too.
+		defer func() {
+			if err := shutdownLogs(context.Background()); err != nil {
+				log.Print(err)
+			}
+		}()
+		exporters = append(exporters, exporter)
+	}
+	logger, err := logging.Setup(os.Stdout, cfg.LogLevel, exporters...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	build := buildinfo.Get()
+	var features []string
+	for name, on := range cfg.Features {
+		if on {
+			features = append(features, name)
+		}
+	}
+	slices.Sort(features)
+	logger.Info("starting", "version", build.Version, "commit", build.Commit, "build_time", build.BuildTime,
+		"go_version", build.GoVersion, "profile", cfg.Profile, "region", cfg.Region, "features", features)
+	if !build.Semver() && build.Version != "dev" {
+		logger.Warn("version is not a semantic version", "version", build.Version)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		logger.Error("tracing setup failed", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("tracing shutdown failed", "error", err)
+		}
+	}()
+
+	m := metrics.New()
+	if cfg.Telemetry.Pushed() {
+		shutdownMetrics, err := telemetry.Metrics(context.Background(), m.Registry(), cfg.Telemetry.Interval)
+		if err != nil {
+			logger.Error("metrics push setup failed", "error", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := shutdownMetrics(context.Background()); err != nil {
+				logger.Error("metrics push shutdown failed", "error", err)
+			}
+		}()
+	}
 
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "health check passed successfully 1!"})
+	// Without a DSN server errors are only logged.
+	var reporter apperror.Reporter
+	if cfg.Sentry.DSN != "" {
+		tracker, err := reporting.NewSentry(cfg.Sentry)
+		if err != nil {
+			logger.Error("error reporting setup failed", "error", err)
+			os.Exit(1)
+		}
+		reporter = tracker
+	}
+
+	db, err := store.Open(context.Background(), region.Database(cfg.Database))
+	if err != nil {
+		logger.Error("database connection failed", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	// Readiness fails while an instance waits for the schema: it does not
+	// listen yet.
+	if err := prepare(db, cfg.Database); err != nil {
+		logger.Error("database migration failed", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Database.MigrateOnly {
+		logger.Info("database migrated")
+		return
+	}
+
+	var rdb *redis.Client
+	if cfg.Redis.URL != "" {
+		opts, err := redis.ParseURL(cfg.Redis.URL)
+		if err != nil {
+			logger.Error("invalid redis url", "error", err)
+			os.Exit(1)
+		}
+		rdb = redis.NewClient(opts)
+		defer rdb.Close()
+	}
+
+	live := config.NewLive(cfg, args)
+	gin.DebugPrintRouteFunc = func(method, path, handler string, _ int) {
+		logger.Debug("route registered", "method", method, "path", path, "handler", handler)
+	}
+	application, err := server.New(live, db, rdb, logger, server.Deps{Reporter: reporter, Metrics: m})
+	if err != nil {
+		logger.Error("server setup failed", "error", err)
+		os.Exit(1)
+	}
+	if j := application.Journal(); j != nil {
+		defer j.Panicking()
+	}
+	live.OnReload(func(old, new *config.Config) {
+		if old.LogLevel != new.LogLevel {
+			if err := logging.SetLevel(new.LogLevel); err != nil {
+				logger.Error("log level not changed", "error", err)
+			}
+		}
 	})
 
-	router.Run(":9090")
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           application,
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+		MaxHeaderBytes:    cfg.Limits.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	tlsSetup, err := certs.Configure(cfg.TLS)
+	if err != nil {
+		logger.Error("tls setup failed", "error", err)
+		os.Exit(1)
+	}
+
+	upgrader, err := restart.New(cfg.Restart)
+	if err != nil {
+		logger.Error("restart setup failed", "error", err)
+		os.Exit(1)
+	}
+	listen := func(addr, purpose string) net.Listener {
+		ln, err := upgrader.Listen("tcp", addr)
+		if err != nil {
+			logger.Error("listen failed", "addr", addr, "purpose", purpose, "error", err)
+			os.Exit(1)
+		}
+		return ln
+	}
+
+	// Components start in dependency order and stop in reverse, so the
+	// listeners drain before the workers that requests hand work to, and
+	// readiness fails until everything is up and again once shutdown began.
+	lifecycle := app.New(logger, cfg.Timeouts.Shutdown)
+	lifecycle.OnStopping(application.Health().Shutdown)
+	application.Health().AddReadiness("lifecycle", lifecycle.Check, 0)
+
+	workers := application.Start(lifecycle)
+	if tracker, ok := reporter.(*reporting.Sentry); ok {
+		// Stopped after the listeners, so the reports of the last
+		// requests are sent too.
+		lifecycle.Add("sentry", app.Hook{OnStop: tracker.Flush})
+		workers = append(workers, "sentry")
+	}
+	lifecycle.Add("config", app.Background(func(ctx context.Context) {
+		if err := live.Watch(ctx); err != nil {
+			logger.Error("config watcher stopped", "error", err)
+		}
+	}))
+
+	servers := []string{"http"}
+	if tlsSetup != nil {
+		srv.TLSConfig = tlsSetup.TLSConfig
+		if cfg.TLS.RedirectAddr != "" {
+			redirectSrv := &http.Server{
+				Addr:              cfg.TLS.RedirectAddr,
+				Handler:           tlsSetup.RedirectHandler(cfg.Addr),
+				ReadHeaderTimeout: 10 * time.Second,
+				ErrorLog:          srv.ErrorLog,
+			}
+			ln := listen(redirectSrv.Addr, "https redirect")
+			lifecycle.Add("redirect", app.Server(func() error {
+				logger.Info("listening", "addr", redirectSrv.Addr, "purpose", "https redirect")
+				return redirectSrv.Serve(ln)
+			}, redirectSrv.Shutdown))
+			servers = append(servers, "redirect")
+		}
+	}
+
+	protocols.Apply(srv, cfg.Protocols)
+	if h3 := protocols.NewHTTP3(srv, cfg.Protocols, logger); h3 != nil {
+		pc, err := net.ListenPacket("udp", h3.Addr)
+		if err != nil {
+			logger.Error("listen failed", "addr", h3.Addr, "purpose", "http3", "error", err)
+			os.Exit(1)
+		}
+		srv.Handler = protocols.AltSvc(h3, srv.Handler)
+		lifecycle.Add("http3", app.Server(func() error {
+			logger.Info("listening", "addr", h3.Addr, "protocol", "http3")
+			return h3.Serve(pc)
+		}, protocols.Shutdown(h3)), app.After(workers...))
+		servers = append(servers, "http3")
+	}
+
+	ln := listen(cfg.Addr, "http")
+	lifecycle.Add("http", app.Server(func() error {
+		if tlsSetup != nil {
+			logger.Info("listening", "addr", cfg.Addr, "tls", tlsSetup.Mode)
+			return srv.ServeTLS(ln, "", "")
+		}
+		logger.Info("listening", "addr", cfg.Addr)
+		return srv.Serve(ln)
+	}, srv.Shutdown), app.After(workers...))
+	// Hijacked WebSocket connections are invisible to srv.Shutdown, so the
+	// hub closes them itself.
+	lifecycle.Add("websockets", app.Hook{OnStop: application.Hub().Shutdown}, app.After("http"))
+
+	if cfg.Admin.Addr != "" {
+		// No write timeout: CPU profiles and traces stream for as long as
+		// the caller asks.
+		adminSrv := &http.Server{
+			Addr:              cfg.Admin.Addr,
+			Handler:           application.Admin(cfg.Admin, logger, upgrader),
+			ReadHeaderTimeout: 10 * time.Second,
+			ErrorLog:          srv.ErrorLog,
+		}
+		adminTLS, err := mtls.ServerConfig(cfg.Admin)
+		if err != nil {
+			logger.Error("admin tls setup failed", "error", err)
+			os.Exit(1)
+		}
+		adminSrv.TLSConfig = adminTLS
+		ln := listen(adminSrv.Addr, "admin")
+		lifecycle.Add("admin", app.Server(func() error {
+			if adminTLS != nil {
+				logger.Info("listening", "addr", adminSrv.Addr, "purpose", "admin", "tls", true, "client_certs", cfg.Admin.ClientCAFile != "")
+				return adminSrv.ServeTLS(ln, "", "")
+			}
+			logger.Info("listening", "addr", adminSrv.Addr, "purpose", "admin")
+			return adminSrv.Serve(ln)
+		}, adminSrv.Shutdown), app.After(workers...))
+		servers = append(servers, "admin")
+	}
+
+	if cfg.GRPC.Addr != "" {
+		ln := listen(cfg.GRPC.Addr, "grpc")
+		rpcSrv := rpc.New(logger, application.Metrics(), application.Users(), application.Callers())
+		lifecycle.Add("grpc", app.Server(func() error {
+			logger.Info("listening", "addr", cfg.GRPC.Addr, "protocol", "grpc")
+			return rpcSrv.Serve(ln)
+		}, rpcSrv.Shutdown), app.After(workers...))
+		servers = append(servers, "grpc")
+	}
+
+	// The previous process, if any, hands over once every listener serves.
+	lifecycle.Add("restart", app.Hook{
+		OnStart: func(context.Context) error { return upgrader.Ready() },
+	}, app.After(servers...))
+	lifecycle.Add("handover", app.Background(func(ctx context.Context) {
+		select {
+		case <-upgrader.Exit():
+			logger.Info("new process took over")
+			stop()
+		case <-ctx.Done():
+		}
+	}), app.After("restart"))
+	lifecycle.Add("upgrader", app.Background(upgrader.Watch), app.After("restart"))
+	if activity := application.Activity(); activity != nil {
+		onIdle, err := newIdleAction(cfg.Autostop, logger, stop, db, reporter)
+		if err != nil {
+			logger.Error("idle setup failed", "error", err)
+			os.Exit(1)
+		}
+		lifecycle.Add("idle", app.Background(func(ctx context.Context) {
+			activity.Watch(ctx, cfg.Autostop.After, onIdle)
+		}), app.After("restart"))
+	}
+
+	if err := lifecycle.Run(ctx); err != nil {
+		logger.Error("server stopped with errors", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("server stopped")
+}
+
+// prepare migrates db, or waits for another instance to, as cfg says.
+func prepare(db *store.Store, cfg config.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.MigrationTimeout)
+	defer cancel()
+	if cfg.AutoMigrate {
+		return db.Migrate(ctx)
+	}
+	return db.WaitForSchema(ctx, 2*time.Second)
+}
+
+// newIdleAction returns what is done once the instance has been idle for
+// cfg.After: a graceful shutdown, or a request to the Machines API to
+// stop or suspend the machine.
+func newIdleAction(cfg config.Autostop, logger *slog.Logger, shutdown func(), db *store.Store, reporter apperror.Reporter) (func(context.Context), error) {
+	if cfg.Action == "exit" {
+		return func(context.Context) {
+			logger.Info("idle, shutting down", "idle", cfg.After.String())
+			shutdown()
+		}, nil
+	}
+	machine, err := idle.NewMachine(cfg, httpclient.New(httpclient.WithRetries(0), httpclient.WithBreaker(0, 0)))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Action == "stop" {
+		return func(ctx context.Context) {
+			logger.Info("idle, stopping machine", "idle", cfg.After.String())
+			if err := machine.Stop(ctx); err != nil {
+				logger.Error("machine not stopped", "error", err)
+			}
+		}, nil
+	}
+	return func(ctx context.Context) {
+		logger.Info("idle, suspending machine", "idle", cfg.After.String())
+		// What is buffered or pooled now would be lost or stale on resume.
+		if tracker, ok := reporter.(*reporting.Sentry); ok {
+			flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if err := tracker.Flush(flushCtx); err != nil {
+				logger.Warn("error reports not flushed", "error", err)
+			}
+			cancel()
+		}
+		db.CloseIdle()
+		_ = os.Stdout.Sync()
+		if err := machine.Suspend(ctx); err != nil {
+			logger.Warn("machine suspend failed or was cut off", "error", err)
+		}
+		logger.Info("resumed")
+	}, nil
 }